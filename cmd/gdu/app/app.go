@@ -1,26 +1,36 @@
 package app
 
 import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/fs"
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/term"
 
 	"github.com/dundee/gdu/v5/build"
 	"github.com/dundee/gdu/v5/internal/common"
+	"github.com/dundee/gdu/v5/internal/priority"
 	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/api"
 	"github.com/dundee/gdu/v5/pkg/device"
 	gfs "github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/dundee/gdu/v5/pkg/remove"
 	"github.com/dundee/gdu/v5/report"
 	"github.com/dundee/gdu/v5/stdout"
 	"github.com/dundee/gdu/v5/tui"
@@ -50,12 +60,28 @@ type Flags struct {
 	LogFile            string        `yaml:"log-file"`
 	InputFile          string        `yaml:"input-file"`
 	OutputFile         string        `yaml:"output-file"`
+	OutputFormat       string        `yaml:"output-format"`
 	IgnoreFromFile     string        `yaml:"ignore-from-file"`
 	StoragePath        string        `yaml:"storage-path"`
 	IgnoreDirs         []string      `yaml:"ignore-dirs"`
 	IgnoreDirPatterns  []string      `yaml:"ignore-dir-patterns"`
 	MaxCores           int           `yaml:"max-cores"`
+	Nice               int           `yaml:"nice"`
+	SchedIdle          bool          `yaml:"sched-idle"`
 	Top                int           `yaml:"top"`
+	AgeHistogram       bool          `yaml:"age-histogram"`
+	ByOwner            bool          `yaml:"by-owner"`
+	ByOwnerTop         int           `yaml:"by-owner-top"`
+	BrokenSymlinks     bool          `yaml:"broken-symlinks"`
+	Tree               int           `yaml:"tree"`
+	Diff               int           `yaml:"diff"`
+	DiffFromLabel      string        `yaml:"from-label"`
+	DiffToLabel        string        `yaml:"to-label"`
+	Offenders          Offenders     `yaml:"offenders"`
+	Duplicates         Duplicates    `yaml:"duplicates"`
+	EmptyDirs          EmptyDirs     `yaml:"empty-dirs"`
+	StartAt            string        `yaml:"start-at"`
+	KeepPrevious       bool          `yaml:"keep-previous"`
 	SequentialScanning bool          `yaml:"sequential-scanning"`
 	ShowDisks          bool          `yaml:"-"`
 	ShowApparentSize   bool          `yaml:"show-apparent-size"`
@@ -74,17 +100,63 @@ type Flags struct {
 	NoDelete           bool          `yaml:"no-delete"`
 	NoSpawnShell       bool          `yaml:"no-spawn-shell"`
 	FollowSymlinks     bool          `yaml:"follow-symlinks"`
+	VerifySymlinks     bool          `yaml:"verify-symlinks"`
+	FollowDirSymlinks  bool          `yaml:"follow-dir-symlinks"`
 	Profiling          bool          `yaml:"profiling"`
 	ConstGC            bool          `yaml:"const-gc"`
+	MemoryMode         string        `yaml:"memory-mode"`
+	GCPercent          int           `yaml:"gc-percent"`
 	UseStorage         bool          `yaml:"use-storage"`
 	ReadFromStorage    bool          `yaml:"read-from-storage"`
 	UseIncremental     bool          `yaml:"use-incremental"`
 	IncrementalPath    string        `yaml:"incremental-path"`
 	CacheMaxAge        time.Duration `yaml:"cache-max-age"`
+	CacheRetention     time.Duration `yaml:"cache-retention"`
+	PruneStale         bool          `yaml:"prune-stale"`
+	FutureSkew         time.Duration `yaml:"future-skew"`
+	TrustCachedAhead   bool          `yaml:"trust-cached-ahead"`
+	BackwardsSkew      time.Duration `yaml:"backwards-skew"`
 	ForceFullScan      bool          `yaml:"force-full-scan"`
+	RefreshPaths       []string      `yaml:"refresh-path"`
+	TrustRootMtime     bool          `yaml:"trust-root-mtime"`
+	InvalidateFrom     string        `yaml:"invalidate-from"`
+	TrustUnlisted      bool          `yaml:"trust-unlisted"`
+	ExcludeFiles       []string      `yaml:"exclude-files"`
+	HashVerify         []string      `yaml:"hash-verify"`
+	ValidationMode     string        `yaml:"validation-mode"`
+	CountCacheDir      bool          `yaml:"count-cache-dir"`
+	BirthTime          bool          `yaml:"birth-time"`
+	AllowVolatileCache bool          `yaml:"allow-volatile-cache"`
+	CacheLowMemory     bool          `yaml:"cache-low-memory"`
 	ShowCacheStats     bool          `yaml:"show-cache-stats"`
+	TraceCache         bool          `yaml:"trace-cache"`
+	StatsFile          string        `yaml:"stats-file"`
+	ScanLabel          string        `yaml:"scan-label"`
+	PostScanCmd        string        `yaml:"post-scan-cmd"`
+	PostScanStdin      bool          `yaml:"post-scan-stdin"`
+	PostScanTimeout    time.Duration `yaml:"post-scan-timeout"`
+	SelfCheck          bool          `yaml:"-"`
+	CacheFsck          bool          `yaml:"-"`
+	CacheRepair        bool          `yaml:"-"`
+	CacheInfo          bool          `yaml:"-"`
+	ClearCache         bool          `yaml:"-"`
+	CacheMaintain      bool          `yaml:"-"`
+	PruneCache         bool          `yaml:"-"`
+	DryRun             bool          `yaml:"-"`
+	CacheTop           CacheTop      `yaml:"-"`
+	CacheDump          CacheDump     `yaml:"-"`
+	ImportStorage      bool          `yaml:"-"`
+	APIListen          string        `yaml:"api-listen"`
+	APIToken           string        `yaml:"api-token"`
+	LegacyExitCode     bool          `yaml:"legacy-exit-code"`
 	MaxIOPS            int           `yaml:"max-iops"`
 	IODelay            time.Duration `yaml:"io-delay"`
+	ScanRetries        int           `yaml:"scan-retries"`
+	ScanRetryDelay     time.Duration `yaml:"scan-retry-delay"`
+	ScanWorkers        int           `yaml:"scan-workers"`
+	EstimateAbove      int           `yaml:"estimate-above"`
+	EstimateSample     int           `yaml:"estimate-sample"`
+	MinItemSize        int64         `yaml:"min-item-size"`
 	Summarize          bool          `yaml:"summarize"`
 	UseSIPrefix        bool          `yaml:"use-si-prefix"`
 	NoPrefix           bool          `yaml:"no-prefix"`
@@ -105,7 +177,15 @@ func (f *Flags) ShouldRunInNonInteractiveMode(istty bool) bool {
 		f.NoPrefix ||
 		f.NoProgress ||
 		f.Summarize ||
-		f.Top > 0
+		f.Top > 0 ||
+		f.AgeHistogram ||
+		f.ByOwner ||
+		f.BrokenSymlinks ||
+		f.Tree > 0 ||
+		f.Diff > 0 ||
+		f.Offenders.Top > 0 ||
+		f.Duplicates.Show ||
+		f.EmptyDirs.Show
 }
 
 // Style define style config
@@ -155,6 +235,45 @@ type Sorting struct {
 	Order string `yaml:"order"`
 }
 
+// Offenders defines ranking of directories by size and growth since the baseline
+type Offenders struct {
+	Top          int     `yaml:"top"`
+	Baseline     string  `yaml:"baseline"`
+	Depth        int     `yaml:"depth"`
+	SizeWeight   float64 `yaml:"size-weight"`
+	GrowthWeight float64 `yaml:"growth-weight"`
+	JSON         bool    `yaml:"json"`
+}
+
+// Duplicates defines listing of files with the same content
+type Duplicates struct {
+	Show     bool   `yaml:"show"`
+	MinSize  int64  `yaml:"min-size"`
+	Hash     string `yaml:"hash"`
+	MaxFiles int    `yaml:"max-files"`
+}
+
+// EmptyDirs defines listing of empty directories
+type EmptyDirs struct {
+	Show      bool `yaml:"show"`
+	Recursive bool `yaml:"recursive"`
+	Script    bool `yaml:"script"`
+}
+
+// CacheTop defines listing of the largest directories read from the incremental cache
+type CacheTop struct {
+	Top      int  `yaml:"top"`
+	MinDepth int  `yaml:"min-depth"`
+	MaxDepth int  `yaml:"max-depth"`
+	JSON     bool `yaml:"json"`
+}
+
+// CacheDump defines dumping of the directories of the incremental cache as JSON lines
+type CacheDump struct {
+	Output string `yaml:"output"`
+	Prefix string `yaml:"prefix"`
+}
+
 // App defines the main application
 type App struct {
 	Args        []string
@@ -192,51 +311,265 @@ func (a *App) Run() error {
 		return fmt.Errorf("--use-storage and --incremental cannot be used at once")
 	}
 
-	path := a.getPath()
-	path, err := filepath.Abs(path)
+	if a.Flags.BrokenSymlinks && !a.Flags.UseIncremental && a.Flags.InputFile == "" {
+		return fmt.Errorf("--broken-symlinks can be used only with --incremental or --input-file")
+	}
+
+	if a.Flags.EstimateAbove > 0 && !a.Flags.UseIncremental {
+		return fmt.Errorf("--estimate-above can be used only with --incremental")
+	}
+
+	if a.Flags.MinItemSize > 0 && !a.Flags.UseIncremental {
+		return fmt.Errorf("--min-item-size can be used only with --incremental")
+	}
+
+	if len(a.Flags.ExcludeFiles) > 0 {
+		if !a.Flags.UseIncremental {
+			return fmt.Errorf("--exclude-files can be used only with --incremental")
+		}
+		if err := analyze.CheckFilePatterns(a.Flags.ExcludeFiles); err != nil {
+			return err
+		}
+	}
+
+	if len(a.Flags.HashVerify) > 0 && !a.Flags.UseIncremental {
+		return fmt.Errorf("--hash-verify can be used only with --incremental")
+	}
+	if len(a.Flags.RefreshPaths) > 0 && !a.Flags.UseIncremental {
+		return fmt.Errorf("--refresh-path can be used only with --incremental")
+	}
+	if a.Flags.FollowDirSymlinks && !a.Flags.UseIncremental {
+		return fmt.Errorf("--follow-dir-symlinks can be used only with --incremental")
+	}
+	if a.Flags.FollowDirSymlinks && !a.Flags.FollowSymlinks && !a.Flags.BrokenSymlinks {
+		return fmt.Errorf("--follow-dir-symlinks can be used only with --follow-symlinks")
+	}
+
+	if a.Flags.ScanWorkers != 0 && !a.Flags.UseIncremental {
+		return fmt.Errorf("--scan-workers can be used only with --incremental")
+	}
+	if a.Flags.ScanWorkers < 0 {
+		return fmt.Errorf("--scan-workers must not be negative")
+	}
+
+	if a.Flags.Diff > 0 && !a.Flags.UseIncremental {
+		return fmt.Errorf("--diff can be used only with --incremental")
+	}
+	if a.Flags.DiffFromLabel != "" && a.Flags.Diff == 0 {
+		return fmt.Errorf("--from-label can be used only with --diff")
+	}
+	if a.Flags.DiffToLabel != "" && a.Flags.DiffFromLabel == "" {
+		return fmt.Errorf("--to-label can be used only with --from-label")
+	}
+
+	if a.Flags.StatsFile != "" && !a.Flags.UseIncremental {
+		return fmt.Errorf("--stats-file can be used only with --incremental")
+	}
+	if a.Flags.StartAt != "" && a.Flags.NonInteractive {
+		return fmt.Errorf("--start-at can be used only in interactive mode")
+	}
+
+	if a.Flags.KeepPrevious && a.Flags.NonInteractive {
+		return fmt.Errorf("--keep-previous can be used only in interactive mode")
+	}
+	if a.Flags.CacheRetention < 0 {
+		return fmt.Errorf("--cache-retention must not be negative")
+	}
+	if a.Flags.CacheRetention > 0 && !a.Flags.UseIncremental && !a.Flags.CacheMaintain {
+		return fmt.Errorf("--cache-retention can be used only with --incremental or --cache-maintain")
+	}
+	if a.Flags.CacheMaintain && a.Flags.CacheRetention == 0 {
+		return fmt.Errorf("--cache-maintain requires --cache-retention")
+	}
+	if a.Flags.PruneStale && !a.Flags.UseIncremental {
+		return fmt.Errorf("--prune-stale can be used only with --incremental")
+	}
+	if a.Flags.ScanLabel != "" && !a.Flags.UseIncremental {
+		return fmt.Errorf("--scan-label can be used only with --incremental")
+	}
+	if a.Flags.PostScanCmd != "" && !a.Flags.UseIncremental {
+		return fmt.Errorf("--post-scan-cmd can be used only with --incremental")
+	}
+	if a.Flags.PostScanStdin && a.Flags.PostScanCmd == "" {
+		return fmt.Errorf("--post-scan-stdin can be used only with --post-scan-cmd")
+	}
+	if a.Flags.PostScanTimeout != 0 && a.Flags.PostScanCmd == "" {
+		return fmt.Errorf("--post-scan-timeout can be used only with --post-scan-cmd")
+	}
+
+	if a.Flags.TrustCachedAhead && !a.Flags.UseIncremental {
+		return fmt.Errorf("--trust-cached-ahead can be used only with --incremental")
+	}
+	if a.Flags.BackwardsSkew != 0 && !a.Flags.UseIncremental {
+		return fmt.Errorf("--backwards-skew can be used only with --incremental")
+	}
+	if a.Flags.InvalidateFrom != "" && !a.Flags.UseIncremental {
+		return fmt.Errorf("--invalidate-from can be used only with --incremental")
+	}
+	if a.Flags.TrustUnlisted && a.Flags.InvalidateFrom == "" {
+		return fmt.Errorf("--trust-unlisted can be used only with --invalidate-from")
+	}
+	if a.Flags.CountCacheDir && !a.Flags.UseIncremental {
+		return fmt.Errorf("--count-cache-dir can be used only with --incremental")
+	}
+	if a.Flags.BirthTime && !a.Flags.UseIncremental {
+		return fmt.Errorf("--birth-time can be used only with --incremental")
+	}
+	if a.Flags.AllowVolatileCache && !a.Flags.UseIncremental {
+		return fmt.Errorf("--allow-volatile-cache can be used only with --incremental")
+	}
+	if (a.Flags.ScanRetries != 0 || a.Flags.ScanRetryDelay != 0) && !a.Flags.UseIncremental {
+		return fmt.Errorf("--scan-retries can be used only with --incremental")
+	}
+	if a.Flags.ScanRetries < 0 || a.Flags.ScanRetryDelay < 0 {
+		return fmt.Errorf("--scan-retries and --scan-retry-delay must not be negative")
+	}
+
+	memoryMode, err := analyze.ParseMemoryMode(a.Flags.MemoryMode)
+	if err != nil {
+		return err
+	}
+	if memoryMode != analyze.MemoryAggressive && !a.Flags.UseIncremental {
+		return fmt.Errorf("--memory-mode can be used only with --incremental")
+	}
+	if a.Flags.ConstGC && memoryMode != analyze.MemoryAggressive && memoryMode != analyze.MemoryConstant {
+		return fmt.Errorf("--const-gc and --memory-mode %s cannot be used at once", memoryMode)
+	}
+	if a.Flags.GCPercent != 0 && memoryMode != analyze.MemoryBalanced {
+		return fmt.Errorf("--gc-percent can be used only with --memory-mode balanced")
+	}
+	if a.Flags.GCPercent < 0 {
+		return fmt.Errorf("--gc-percent must be positive")
+	}
+
+	validation, err := analyze.ParseValidationMode(a.Flags.ValidationMode)
 	if err != nil {
 		return err
 	}
+	if validation != analyze.ValidateMtime && !a.Flags.UseIncremental {
+		return fmt.Errorf("--validation-mode can be used only with --incremental")
+	}
+
+	if a.Flags.Nice < 0 || a.Flags.Nice > 19 {
+		return fmt.Errorf("--nice must be between 0 and 19")
+	}
+
+	if a.Flags.Duplicates.Show && a.Flags.Duplicates.Hash != "" {
+		if err := analyze.CheckDuplicateHash(a.Flags.Duplicates.Hash); err != nil {
+			return fmt.Errorf("--duplicates-hash: %w", err)
+		}
+	}
+
+	if a.Flags.EmptyDirs.Script && !a.Flags.EmptyDirs.Show {
+		return fmt.Errorf("--empty-dirs-script can be used only with --empty-dirs")
+	}
+
+	if len(a.Args) > 1 {
+		if err := a.checkMultipleDirs(); err != nil {
+			return err
+		}
+	}
+
+	if a.Flags.SelfCheck {
+		return a.selfCheck(memoryMode)
+	}
+
+	if a.Flags.CacheFsck {
+		return a.checkCache()
+	}
+
+	if a.Flags.CacheInfo {
+		return a.printCacheInfo()
+	}
+
+	if a.Flags.DryRun && !a.Flags.ClearCache {
+		return fmt.Errorf("--dry-run can be used only with --clear-cache")
+	}
+
+	if a.Flags.ClearCache {
+		return a.clearCache()
+	}
+
+	if a.Flags.CacheMaintain {
+		return a.maintainCache()
+	}
+
+	if a.Flags.PruneCache {
+		return a.pruneCache()
+	}
+
+	if a.Flags.CacheTop.Top > 0 {
+		return a.printCacheTop()
+	}
+
+	if a.Flags.CacheDump.Prefix != "" && a.Flags.CacheDump.Output == "" {
+		return fmt.Errorf("--cache-dump-prefix can be used only with --cache-dump")
+	}
+
+	if a.Flags.CacheDump.Output != "" {
+		return a.dumpCache()
+	}
+
+	if a.Flags.ImportStorage {
+		return a.importStorage()
+	}
+
+	if a.Flags.APIListen != "" {
+		return a.serveAPI()
+	}
+
+	paths, err := a.getAbsPaths()
+	if err != nil {
+		return err
+	}
+	path := paths[0]
 
 	ui, err = a.createUI()
 	if err != nil {
 		return err
 	}
 
+	var incremental *analyze.IncrementalAnalyzer
 	if a.Flags.UseStorage {
 		ui.SetAnalyzer(analyze.CreateStoredAnalyzer(a.Flags.StoragePath))
 	}
 	if a.Flags.UseIncremental {
-		storagePath := a.Flags.IncrementalPath
-		if storagePath == "" {
-			// Default to ~/.cache/gdu/incremental
-			homeDir, err := os.UserHomeDir()
+		storagePath, err := a.incrementalStoragePath()
+		if err != nil {
+			return err
+		}
+
+		analyzer := analyze.CreateIncrementalAnalyzer(a.incrementalOptions(storagePath, memoryMode))
+		ui.SetAnalyzer(analyzer)
+		incremental = analyzer
+
+		if a.Flags.InvalidateFrom != "" {
+			invalidated, err := readPathsFile(a.Flags.InvalidateFrom)
 			if err != nil {
-				return fmt.Errorf("failed to get user home directory: %w", err)
+				return fmt.Errorf("reading --invalidate-from file: %w", err)
 			}
-			storagePath = filepath.Join(homeDir, ".cache", "gdu", "incremental")
+			analyzer.InvalidatePaths(invalidated)
 		}
 
-		analyzer := analyze.CreateIncrementalAnalyzer(analyze.IncrementalOptions{
-			StoragePath:   storagePath,
-			CacheMaxAge:   a.Flags.CacheMaxAge,
-			ForceFullScan: a.Flags.ForceFullScan,
-			MaxIOPS:       a.Flags.MaxIOPS,
-			IODelay:       a.Flags.IODelay,
-		})
-		ui.SetAnalyzer(analyzer)
+		// deletions are paced by the same throttle as the scan
+		if tuiUI, ok := ui.(*tui.UI); ok && analyzer.GetThrottle() != nil {
+			tuiUI.SetThrottledRemover(remove.NewThrottledRemover(analyzer.GetThrottle(), analyzer.InvalidateRemoved))
+		}
 	}
 	if a.Flags.SequentialScanning {
 		ui.SetAnalyzer(analyze.CreateSeqAnalyzer())
+		incremental = nil
 	}
-	if a.Flags.FollowSymlinks {
+	if a.Flags.FollowSymlinks || a.Flags.BrokenSymlinks {
 		ui.SetFollowSymlinks(true)
 	}
 	if a.Flags.ShowAnnexedSize {
 		ui.SetShowAnnexedSize(true)
 	}
-	if err := a.setNoCross(path); err != nil {
-		return err
+	for _, path := range paths {
+		if err := a.setNoCross(path); err != nil {
+			return err
+		}
 	}
 
 	ui.SetIgnoreDirPaths(a.Flags.IgnoreDirs)
@@ -258,12 +591,637 @@ func (a *App) Run() error {
 	}
 
 	a.setMaxProcs()
+	a.lowerPriority(ui)
+
+	// the terminal UI gets keys instead of signals and is not used by scripts
+	if _, interactive := ui.(*tui.UI); interactive {
+		incremental = nil
+	}
+	if incremental != nil {
+		defer cancelOnInterrupt(incremental)()
+	}
+
+	if len(paths) > 1 {
+		return a.analyzeDirs(ui.(*stdout.UI), paths)
+	}
 
 	if err := a.runAction(ui, path); err != nil {
 		return err
 	}
 
-	return ui.StartUILoop()
+	if err := ui.StartUILoop(); err != nil {
+		return err
+	}
+	if incremental != nil && !a.Flags.LegacyExitCode {
+		return scanExitError(incremental.GetScanResult())
+	}
+	return nil
+}
+
+// Exit codes of gdu reflecting the outcome of a non-interactive incremental scan
+const (
+	ExitOK            = 0   // the whole tree was read
+	ExitFailure       = 1   // no usable result, e.g. the root or the cache could not be opened
+	ExitScanErrors    = 3   // the scan finished, but some directories could not be read
+	ExitCacheDegraded = 4   // the tree is complete, but reads or writes of the cache failed
+	ExitHookFailed    = 5   // the scan was clean, but the --post-scan-cmd failed
+	ExitInterrupted   = 130 // the scan was stopped by SIGINT or SIGTERM
+)
+
+// ExitError is returned by Run when the scan did not finish cleanly
+type ExitError struct {
+	Code   int
+	Result *analyze.ScanResult
+}
+
+// Error returns description of the scan outcome
+func (e *ExitError) Error() string {
+	switch e.Code {
+	case ExitScanErrors:
+		return fmt.Sprintf("scan completed with %d read errors", e.Result.ErrorCount)
+	case ExitCacheDegraded:
+		return fmt.Sprintf("scan completed, but %d cache reads or writes failed", e.Result.CacheErrors)
+	case ExitHookFailed:
+		return fmt.Sprintf("scan completed, but the post-scan command failed: %v", e.Result.HookErr)
+	}
+	if e.Result.Err != nil {
+		return fmt.Sprintf("scan %s: %v", e.Result.Status, e.Result.Err)
+	}
+	return "scan " + e.Result.Status.String()
+}
+
+// scanExitError returns ExitError with the exit code matching result or nil if the scan was clean
+func scanExitError(result *analyze.ScanResult) error {
+	code := scanExitCode(result)
+	if code == ExitOK {
+		return nil
+	}
+	return &ExitError{Code: code, Result: result}
+}
+
+// scanExitCode returns the exit code matching result, ExitOK for nil
+func scanExitCode(result *analyze.ScanResult) int {
+	switch {
+	case result == nil:
+		return ExitOK
+	case result.Status == analyze.ScanFailed:
+		return ExitFailure
+	case result.Status == analyze.ScanCancelled:
+		return ExitInterrupted
+	case result.Status == analyze.ScanCompletedWithErrors:
+		return ExitScanErrors
+	case result.CacheErrors > 0:
+		return ExitCacheDegraded
+	case result.HookErr != nil:
+		return ExitHookFailed
+	}
+	return ExitOK
+}
+
+// cancelOnInterrupt cancels the scan of analyzer on SIGINT or SIGTERM,
+// so the partial result is reported as interrupted. Returned function stops the handling
+func cancelOnInterrupt(analyzer *analyze.IncrementalAnalyzer) func() {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-signals:
+			log.Print("Interrupted, cancelling the scan")
+			analyzer.Cancel()
+		case <-done:
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// readPathsFile returns the paths listed in the file one per line,
+// empty lines and lines starting with # are skipped
+func readPathsFile(name string) ([]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	paths := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	return paths, scanner.Err()
+}
+
+// incrementalOptions returns options of the incremental analyzer set by the flags
+func (a *App) incrementalOptions(storagePath string, memoryMode analyze.MemoryMode) analyze.IncrementalOptions {
+	validation, _ := analyze.ParseValidationMode(a.Flags.ValidationMode) // checked with the other flags
+	return analyze.IncrementalOptions{
+		StoragePath:        storagePath,
+		CacheMaxAge:        a.Flags.CacheMaxAge,
+		ForceFullScan:      a.Flags.ForceFullScan,
+		NoCross:            a.Flags.NoCross,
+		MaxIOPS:            a.Flags.MaxIOPS,
+		IODelay:            a.Flags.IODelay,
+		Workers:            a.Flags.ScanWorkers,
+		CheckAfterCrash:    true,
+		VerifySymlinks:     a.Flags.VerifySymlinks,
+		FollowDirSymlinks:  a.Flags.FollowDirSymlinks,
+		TraceDecisions:     a.Flags.TraceCache,
+		RecordDecisions:    a.Flags.Tree > 0, // marks of the tree
+		SampleThreshold:    a.Flags.EstimateAbove,
+		SampleSize:         a.Flags.EstimateSample,
+		FutureSkew:         a.Flags.FutureSkew,
+		TrustCachedAhead:   a.Flags.TrustCachedAhead,
+		BackwardsSkew:      a.Flags.BackwardsSkew,
+		TrustRootMtime:     a.Flags.TrustRootMtime,
+		TrustUnlisted:      a.Flags.TrustUnlisted,
+		MinItemSize:        a.Flags.MinItemSize,
+		ExcludeFiles:       a.Flags.ExcludeFiles,
+		CountCacheDir:      a.Flags.CountCacheDir,
+		BirthTime:          a.Flags.BirthTime,
+		AllowVolatileCache: a.Flags.AllowVolatileCache,
+		StatsFilePath:      a.Flags.StatsFile,
+		CacheRetention:     a.Flags.CacheRetention,
+		PruneStale:         a.Flags.PruneStale,
+		ScanLabel:          a.Flags.ScanLabel,
+		PostScanHook:       a.postScanHook(),
+		MemoryMode:         memoryMode,
+		GCPercent:          a.Flags.GCPercent,
+		RetryCount:         a.Flags.ScanRetries,
+		RetryDelay:         a.Flags.ScanRetryDelay,
+
+		HashVerifyPrefixes: a.Flags.HashVerify,
+		RefreshPaths:       a.Flags.RefreshPaths,
+		ValidationMode:     validation,
+		Diff:               a.Flags.Diff > 0,
+		DiffFromLabel:      a.Flags.DiffFromLabel,
+		DiffToLabel:        a.Flags.DiffToLabel,
+		StorageOptions:     a.storageOptions(),
+	}
+}
+
+// storageOptions returns options of the incremental cache database set by the flags
+func (a *App) storageOptions() analyze.StorageOptions {
+	return analyze.StorageOptions{LowMemory: a.Flags.CacheLowMemory}
+}
+
+// selfCheck scans the given directory by the incremental analyzer and by the sequential one
+// and lists the differences of the trees. Ignored directories are not applied
+func (a *App) selfCheck(memoryMode analyze.MemoryMode) error {
+	storagePath, err := a.incrementalStoragePath()
+	if err != nil {
+		return err
+	}
+	path, err := filepath.Abs(a.getPath())
+	if err != nil {
+		return err
+	}
+
+	opts := a.incrementalOptions(storagePath, memoryMode)
+	result, err := analyze.SelfCheck(path, opts, nil, analyze.CompareOptions{
+		IgnoreEstimated: opts.SampleThreshold > 0,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, mismatch := range result.Mismatches {
+		fmt.Fprintln(a.Writer, mismatch)
+	}
+	if rest := result.MismatchesDropped(); rest > 0 {
+		fmt.Fprintf(a.Writer, "...and %s more\n", common.FormatNumber(int64(rest)))
+	}
+	fmt.Fprintf(a.Writer, "Compared %d items: %d mismatches\n", result.Compared, result.Total)
+
+	if result.Total > 0 {
+		return fmt.Errorf("incremental scan of %s differs from the sequential one", path)
+	}
+	return nil
+}
+
+// incrementalStoragePath returns path of the incremental cache, ~/.cache/gdu/incremental by default
+func (a *App) incrementalStoragePath() (string, error) {
+	if a.Flags.IncrementalPath != "" {
+		return a.Flags.IncrementalPath, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".cache", "gdu", "incremental"), nil
+}
+
+// checkCache validates entries of the incremental cache (of the given directory only if there is one)
+// and removes the invalid ones if repair is requested
+func (a *App) checkCache() error {
+	storagePath, err := a.incrementalStoragePath()
+	if err != nil {
+		return err
+	}
+
+	topDir := ""
+	if len(a.Args) > 0 {
+		if topDir, err = filepath.Abs(a.Args[0]); err != nil {
+			return err
+		}
+	}
+
+	storage := analyze.NewIncrementalStorage(storagePath, topDir)
+	storage.SetStorageOptions(a.storageOptions())
+	closeFn, err := storage.Open()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	result, err := storage.CheckIntegrity(a.Flags.CacheRepair)
+	if err != nil {
+		return fmt.Errorf("checking cache: %w", err)
+	}
+
+	for _, problem := range result.Problems {
+		fmt.Fprintln(a.Writer, problem)
+	}
+	if rest := result.ProblemsDropped(); rest > 0 {
+		fmt.Fprintf(a.Writer, "...and %s more\n", common.FormatNumber(int64(rest)))
+	}
+	fmt.Fprintf(a.Writer, "Checked %d cache entries: %d invalid, %d removed\n",
+		result.Checked, result.Invalid, result.Repaired)
+
+	if result.Invalid > result.Repaired {
+		return fmt.Errorf("cache contains %d invalid entries, run with --repair to remove them",
+			result.Invalid-result.Repaired)
+	}
+	return nil
+}
+
+// clearCache removes the incremental cache (of the given directory and its subdirectories only if there is one)
+// or, with --dry-run, lists what would be removed
+func (a *App) clearCache() error {
+	storagePath, err := a.incrementalStoragePath()
+	if err != nil {
+		return err
+	}
+
+	topDir := ""
+	if len(a.Args) > 0 {
+		if topDir, err = filepath.Abs(a.Args[0]); err != nil {
+			return err
+		}
+	}
+
+	storage := analyze.NewIncrementalStorage(storagePath, topDir)
+	storage.SetStorageOptions(a.storageOptions())
+	var closeFn func()
+	if a.Flags.DryRun {
+		closeFn, err = storage.OpenReadOnly()
+	} else {
+		closeFn, err = storage.Open()
+	}
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	if a.Flags.DryRun {
+		var preview *analyze.MutationPreview
+		if topDir != "" {
+			preview, err = storage.DeleteSubtreeDryRun(topDir)
+		} else {
+			preview, err = storage.ClearCacheDryRun()
+		}
+		if err != nil {
+			return fmt.Errorf("reading cache: %w", err)
+		}
+		for _, key := range preview.Sample {
+			fmt.Fprintln(a.Writer, key)
+		}
+		if rest := preview.SampleDropped(); rest > 0 {
+			fmt.Fprintf(a.Writer, "...and %s more\n", common.FormatNumber(int64(rest)))
+		}
+		fmt.Fprintf(a.Writer, "Would remove %d cache entries (%s bytes)\n",
+			preview.Entries, common.FormatNumber(preview.Bytes))
+		return nil
+	}
+
+	var removed int
+	if topDir != "" {
+		removed, err = storage.DeleteSubtree(topDir)
+	} else {
+		removed, err = storage.ClearCache()
+	}
+	if err != nil {
+		return fmt.Errorf("clearing cache: %w", err)
+	}
+	fmt.Fprintf(a.Writer, "Removed %d cache entries\n", removed)
+	return nil
+}
+
+// maintainCache removes entries of the incremental cache not written for longer than --cache-retention
+// without scanning, e.g. from a periodic job
+func (a *App) maintainCache() error {
+	storagePath, err := a.incrementalStoragePath()
+	if err != nil {
+		return err
+	}
+
+	storage := analyze.NewIncrementalStorage(storagePath, "")
+	storage.SetStorageOptions(a.storageOptions())
+	closeFn, err := storage.Open()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	result, err := storage.PruneExpired(time.Now().Add(-a.Flags.CacheRetention))
+	if err != nil {
+		return fmt.Errorf("pruning cache: %w", err)
+	}
+	fmt.Fprintf(a.Writer, "Pruned %d cache entries and %d scan summaries older than %s\n",
+		result.Entries, result.Summaries, a.Flags.CacheRetention)
+	return nil
+}
+
+// pruneCache removes entries of the incremental cache of directories under the given directory
+// which are gone from the filesystem, without scanning
+func (a *App) pruneCache() error {
+	storagePath, err := a.incrementalStoragePath()
+	if err != nil {
+		return err
+	}
+	prefix, err := filepath.Abs(a.getPath())
+	if err != nil {
+		return err
+	}
+
+	storage := analyze.NewIncrementalStorage(storagePath, prefix)
+	storage.SetStorageOptions(a.storageOptions())
+	closeFn, err := storage.Open()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	result, err := storage.PruneStale(prefix)
+	if err != nil {
+		return fmt.Errorf("pruning cache: %w", err)
+	}
+	fmt.Fprintf(a.Writer, "Pruned %d cache entries of removed directories under %s (%d bytes)\n",
+		result.Entries, prefix, result.Bytes)
+	return nil
+}
+
+// printCacheTop lists the largest directories under the given directory read from the incremental cache
+func (a *App) printCacheTop() error {
+	storagePath, err := a.incrementalStoragePath()
+	if err != nil {
+		return err
+	}
+	prefix, err := filepath.Abs(a.getPath())
+	if err != nil {
+		return err
+	}
+
+	storage := analyze.NewIncrementalStorage(storagePath, prefix)
+	storage.SetStorageOptions(a.storageOptions())
+	closeFn, err := storage.OpenReadOnly()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	opts := a.Flags.CacheTop
+	top, err := storage.TopDirsBySize(prefix, opts.Top, opts.MinDepth, opts.MaxDepth)
+	if err != nil {
+		return fmt.Errorf("reading cache: %w", err)
+	}
+
+	if opts.JSON {
+		encoder := json.NewEncoder(a.Writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(top)
+	}
+
+	fmt.Fprintf(a.Writer, "%20s %12s  %s\n", "Usage", "Items", "Path")
+	for _, dir := range top {
+		fmt.Fprintf(a.Writer, "%20s %12s  %s\n",
+			common.FormatNumber(dir.Usage), common.FormatNumber(int64(dir.ItemCount)), dir.Path)
+	}
+	return nil
+}
+
+// dumpCache writes the directories of the incremental cache (under --cache-dump-prefix only if set)
+// as JSON lines to the file given by --cache-dump, gzip-compressed if it ends with .gz, or to stdout for -
+func (a *App) dumpCache() error {
+	storagePath, err := a.incrementalStoragePath()
+	if err != nil {
+		return err
+	}
+	prefix := a.Flags.CacheDump.Prefix
+	if prefix != "" {
+		if prefix, err = filepath.Abs(prefix); err != nil {
+			return err
+		}
+	}
+
+	storage := analyze.NewIncrementalStorage(storagePath, prefix)
+	storage.SetStorageOptions(a.storageOptions())
+	closeFn, err := storage.OpenReadOnly()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	dest := a.Flags.CacheDump.Output
+	var file *os.File
+	output := a.Writer
+	if dest != "-" {
+		file, err = os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			return fmt.Errorf("opening dump file: %w", err)
+		}
+		defer file.Close()
+		output = file
+	}
+
+	var gz *gzip.Writer
+	if strings.HasSuffix(dest, ".gz") {
+		gz = gzip.NewWriter(output)
+		output = gz
+	}
+	buffered := bufio.NewWriter(output)
+
+	if _, err := storage.DumpDirs(prefix, buffered); err != nil {
+		return fmt.Errorf("dumping cache: %w", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("writing dump: %w", err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("writing dump: %w", err)
+		}
+	}
+	if file != nil {
+		return file.Close()
+	}
+	return nil
+}
+
+// printCacheInfo shows the incremental cache entry of the given directory
+// together with the host and the version of gdu which wrote it
+func (a *App) printCacheInfo() error {
+	storagePath, err := a.incrementalStoragePath()
+	if err != nil {
+		return err
+	}
+	path, err := filepath.Abs(a.getPath())
+	if err != nil {
+		return err
+	}
+
+	storage := analyze.NewIncrementalStorage(storagePath, path)
+	storage.SetStorageOptions(a.storageOptions())
+	closeFn, err := storage.OpenReadOnly()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	version, err := storage.SchemaVersion()
+	if err != nil {
+		return fmt.Errorf("reading cache: %w", err)
+	}
+	size, err := storage.GetCacheSize()
+	if err != nil {
+		return fmt.Errorf("reading cache: %w", err)
+	}
+	fmt.Fprintf(a.Writer, "Cache:          %s\n", storagePath)
+	fmt.Fprintf(a.Writer, "Schema Version: %d\n", version)
+	fmt.Fprintf(a.Writer, "Cache Size:     %s\n", common.FormatNumber(size))
+
+	meta, err := storage.LoadDirMetadata(path)
+	if err != nil {
+		return fmt.Errorf("directory %s is not in the cache: %w", path, err)
+	}
+	fmt.Fprintf(a.Writer, "Directory:      %s\n", meta.Path)
+	fmt.Fprintf(a.Writer, "Usage:          %s\n", common.FormatNumber(meta.Usage))
+	fmt.Fprintf(a.Writer, "Items:          %s\n", common.FormatNumber(int64(meta.ItemCount)))
+	fmt.Fprintf(a.Writer, "Cached At:      %s\n", meta.CachedAt.Format(time.RFC3339))
+	fmt.Fprintf(a.Writer, "Written By:     %s\n", analyze.FormatProvenance(meta.Hostname, meta.AppVersion))
+	return nil
+}
+
+// importStorage converts the given directory stored in the persistent storage (--storage-path)
+// into entries of the incremental cache
+func (a *App) importStorage() error {
+	storagePath, err := a.incrementalStoragePath()
+	if err != nil {
+		return err
+	}
+	path, err := filepath.Abs(a.getPath())
+	if err != nil {
+		return err
+	}
+
+	storage := analyze.NewIncrementalStorage(storagePath, path)
+	storage.SetStorageOptions(a.storageOptions())
+	closeFn, err := storage.Open()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	result, err := analyze.ImportLegacyStorage(a.Flags.StoragePath, storage, path)
+	fmt.Fprintf(a.Writer, "Imported %d directories, skipped %d\n", result.Converted, result.Skipped)
+	if err != nil {
+		return fmt.Errorf("importing storage (%s): %w", a.Flags.StoragePath, err)
+	}
+	return nil
+}
+
+// serveAPI serves the HTTP API over the incremental cache until the server fails
+func (a *App) serveAPI() error {
+	storagePath, err := a.incrementalStoragePath()
+	if err != nil {
+		return err
+	}
+
+	server := api.NewServer(storagePath)
+	server.SetToken(a.Flags.APIToken)
+	go server.RunRescans(context.Background())
+
+	log.Printf("Serving API at %s", a.Flags.APIListen)
+	return http.ListenAndServe(a.Flags.APIListen, server)
+}
+
+// getAbsPaths returns absolute paths of the directories given as arguments, the current directory by default
+func (a *App) getAbsPaths() ([]string, error) {
+	if len(a.Args) <= 1 {
+		path, err := filepath.Abs(a.getPath())
+		return []string{path}, err
+	}
+
+	paths := make([]string, 0, len(a.Args))
+	for _, arg := range a.Args {
+		path, err := filepath.Abs(arg)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// checkMultipleDirs checks that the flags allow scanning of several directories given as arguments
+func (a *App) checkMultipleDirs() error {
+	switch {
+	case !a.Flags.UseIncremental:
+		return fmt.Errorf("multiple directories can be scanned only with --incremental")
+	case a.Flags.SequentialScanning:
+		return fmt.Errorf("multiple directories cannot be scanned with --sequential")
+	case a.Flags.CacheFsck || a.Flags.CacheInfo || a.Flags.ClearCache || a.Flags.CacheMaintain || a.Flags.PruneCache || a.Flags.CacheTop.Top > 0 ||
+		a.Flags.SelfCheck || a.Flags.CacheDump.Output != "" || a.Flags.ImportStorage || a.Flags.APIListen != "" || a.Flags.InputFile != "" ||
+		a.Flags.ReadFromStorage || a.Flags.ShowDisks:
+		return fmt.Errorf("multiple directories can be given only for a scan")
+	case a.Flags.OutputFile != "" || a.Flags.Offenders.JSON:
+		return fmt.Errorf("multiple directories cannot be exported into one output")
+	case !a.Flags.ShouldRunInNonInteractiveMode(a.Istty):
+		return fmt.Errorf("multiple directories can be scanned only in non-interactive mode (--non-interactive)")
+	}
+	return nil
+}
+
+// analyzeDirs scans the directories in one scan sharing the cache, each is cached under its own path.
+// The exit code reflects the worst of the directories
+func (a *App) analyzeDirs(ui *stdout.UI, paths []string) error {
+	for i, path := range paths {
+		if build.RootPathPrefix != "" {
+			paths[i] = build.RootPathPrefix + path
+		}
+		if _, err := a.PathChecker(paths[i]); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Analyzing paths: %s", strings.Join(paths, ", "))
+	result, err := ui.AnalyzePaths(paths)
+	if err != nil {
+		return fmt.Errorf("scanning dir: %w", err)
+	}
+	if a.Flags.LegacyExitCode {
+		return nil
+	}
+	return scanExitError(result)
 }
 
 func (a *App) getPath() string {
@@ -284,13 +1242,49 @@ func (a *App) setMaxProcs() {
 	log.Printf("Max cores set to %d", runtime.GOMAXPROCS(0))
 }
 
+// lowerPriority lowers CPU priority of the process if requested.
+// Settings which could not be applied are only logged
+func (a *App) lowerPriority(ui UI) {
+	if a.Flags.Nice == 0 && !a.Flags.SchedIdle {
+		return
+	}
+
+	applied, err := priority.Lower(a.Flags.Nice, a.Flags.SchedIdle)
+	if err != nil {
+		log.Printf("Warning: failed to lower priority: %v", err)
+	}
+	log.Printf("Priority set to %s", applied)
+
+	if stdoutUI, ok := ui.(*stdout.UI); ok {
+		stdoutUI.SetPriority(applied.String())
+	}
+}
+
+// binaryOutput returns true if the export should use the binary format,
+// selected by --output-format or by the extension of the output file
+func (a *App) binaryOutput() (bool, error) {
+	switch a.Flags.OutputFormat {
+	case "json":
+		return false, nil
+	case "binary":
+		return true, nil
+	case "":
+		return strings.HasSuffix(a.Flags.OutputFile, report.BinaryExtension), nil
+	default:
+		return false, fmt.Errorf("unknown output format %q (use json or binary)", a.Flags.OutputFormat)
+	}
+}
+
 func (a *App) createUI() (UI, error) {
 	var ui UI
 
 	switch {
 	case a.Flags.OutputFile != "":
+		binary, err := a.binaryOutput()
+		if err != nil {
+			return nil, err
+		}
 		var output io.Writer
-		var err error
 		if a.Flags.OutputFile == "-" {
 			output = os.Stdout
 		} else {
@@ -299,7 +1293,7 @@ func (a *App) createUI() (UI, error) {
 				return nil, fmt.Errorf("opening output file: %w", err)
 			}
 		}
-		ui = report.CreateExportUI(
+		exportUI := report.CreateExportUI(
 			a.Writer,
 			output,
 			!a.Flags.NoColor && a.Istty,
@@ -307,11 +1301,15 @@ func (a *App) createUI() (UI, error) {
 			a.Flags.ConstGC,
 			a.Flags.UseSIPrefix,
 		)
+		if binary {
+			exportUI.UseBinaryFormat()
+		}
+		ui = exportUI
 	case a.Flags.ShouldRunInNonInteractiveMode(a.Istty):
 		stdoutUI := stdout.CreateStdoutUI(
 			a.Writer,
 			!a.Flags.NoColor && a.Istty,
-			!a.Flags.NoProgress && a.Istty,
+			!a.Flags.NoProgress && a.Istty && !a.Flags.Offenders.JSON,
 			a.Flags.ShowApparentSize,
 			a.Flags.ShowRelativeSize,
 			a.Flags.Summarize,
@@ -325,6 +1323,40 @@ func (a *App) createUI() (UI, error) {
 		if a.Flags.NoUnicode {
 			stdoutUI.UseOldProgressRunes()
 		}
+		if a.Flags.AgeHistogram {
+			stdoutUI.ShowAgeHistogram()
+		}
+		if a.Flags.ByOwner {
+			stdoutUI.ShowUsageByOwner(a.Flags.ByOwnerTop)
+		}
+		if a.Flags.BrokenSymlinks {
+			stdoutUI.ShowBrokenSymlinks()
+		}
+		if a.Flags.Duplicates.Show {
+			stdoutUI.ShowDuplicates(a.duplicateOptions())
+		}
+		if a.Flags.Tree > 0 {
+			stdoutUI.ShowTree(a.Flags.Tree, a.terminalWidth())
+		}
+		if a.Flags.Diff > 0 {
+			stdoutUI.ShowDiff(a.Flags.Diff)
+		}
+		if a.Flags.EmptyDirs.Show {
+			stdoutUI.ShowEmptyDirs(a.Flags.EmptyDirs.Recursive, a.Flags.EmptyDirs.Script)
+		}
+		if a.Flags.Offenders.Top > 0 {
+			baseline, err := readOffendersBaseline(a.Flags.Offenders.Baseline)
+			if err != nil {
+				return nil, err
+			}
+			stdoutUI.ShowOffenders(analyze.OffenderOptions{
+				SizeWeight:      a.Flags.Offenders.SizeWeight,
+				GrowthWeight:    a.Flags.Offenders.GrowthWeight,
+				MaxDepth:        a.Flags.Offenders.Depth,
+				Limit:           a.Flags.Offenders.Top,
+				UseApparentSize: a.Flags.ShowApparentSize,
+			}, baseline, a.Flags.Offenders.JSON)
+		}
 		ui = stdoutUI
 	default:
 		opts := a.getOptions()
@@ -352,6 +1384,50 @@ func (a *App) createUI() (UI, error) {
 	return ui, nil
 }
 
+// terminalWidth returns number of columns of the terminal, 0 if the output is not a terminal (e.g. a pipe)
+func (a *App) terminalWidth() int {
+	if !a.Istty {
+		return 0
+	}
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
+// duplicateOptions returns options of looking for duplicates set by the flags,
+// reading of the files is paced by the same limits as the scan
+func (a *App) duplicateOptions() analyze.DuplicateOptions {
+	return analyze.DuplicateOptions{
+		MinSize:  a.Flags.Duplicates.MinSize,
+		Hash:     a.Flags.Duplicates.Hash,
+		MaxFiles: a.Flags.Duplicates.MaxFiles,
+		Throttle: analyze.NewIOThrottle(a.Flags.MaxIOPS, a.Flags.IODelay),
+	}
+}
+
+// readOffendersBaseline reads the previous generation of the tree exported as JSON,
+// empty path means there is no baseline
+func readOffendersBaseline(path string) (gfs.Item, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	input, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening offenders baseline: %w", err)
+	}
+	defer input.Close()
+
+	dir, err := report.ReadAnalysis(input)
+	if err != nil {
+		return nil, fmt.Errorf("reading offenders baseline: %w", err)
+	}
+	dir.UpdateStats(make(gfs.HardLinkedItems, 10))
+	return dir, nil
+}
+
 func (a *App) getOptions() []tui.Option {
 	var opts []tui.Option
 
@@ -450,6 +1526,20 @@ func (a *App) getOptions() []tui.Option {
 			ui.SetDeleteInParallel()
 		})
 	}
+	opts = append(opts, func(ui *tui.UI) {
+		ui.SetDuplicateOptions(a.duplicateOptions())
+		ui.SetEmptyDirsRecursive(a.Flags.EmptyDirs.Recursive)
+	})
+	if a.Flags.StartAt != "" {
+		opts = append(opts, func(ui *tui.UI) {
+			ui.SetStartAt(a.Flags.StartAt)
+		})
+	}
+	if a.Flags.KeepPrevious {
+		opts = append(opts, func(ui *tui.UI) {
+			ui.SetKeepPrevious()
+		})
+	}
 	return opts
 }
 
@@ -4,11 +4,17 @@
 package app
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/dundee/gdu/v5/internal/testdev"
 	"github.com/dundee/gdu/v5/internal/testdir"
+	"github.com/dundee/gdu/v5/pkg/analyze"
 	"github.com/dundee/gdu/v5/pkg/device"
 	"github.com/stretchr/testify/assert"
 )
@@ -121,3 +127,140 @@ func TestReadFromStorageWithErr(t *testing.T) {
 
 	assert.ErrorContains(t, err, "Key not found")
 }
+
+func TestExitCodeScanErrors(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	assert.Nil(t, os.Chmod("test_dir/nested/subnested", 0))
+	defer func() {
+		assert.Nil(t, os.Chmod("test_dir/nested/subnested", 0o755))
+	}()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: t.TempDir(), NonInteractive: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	var exitErr *ExitError
+	assert.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, ExitScanErrors, exitErr.Code)
+	assert.Equal(t, analyze.ScanCompletedWithErrors, exitErr.Result.Status)
+}
+
+func TestPostScanCmd(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	out := t.TempDir()
+	script := filepath.Join(out, "hook.sh")
+	assert.Nil(t, os.WriteFile(script, []byte("#!/bin/sh\n"+
+		"env | grep ^GDU_ | sort > \"$HOOK_OUT/env\"\n"+
+		"cat > \"$HOOK_OUT/stdin\"\n"+
+		"exit \"$HOOK_EXIT\"\n"), 0o755))
+	t.Setenv("HOOK_OUT", out)
+	t.Setenv("HOOK_EXIT", "0")
+
+	flags := &Flags{
+		LogFile: "/dev/null", UseIncremental: true, IncrementalPath: t.TempDir(),
+		NonInteractive: true, PostScanCmd: script, PostScanStdin: true,
+	}
+	_, err := runApp(flags, []string{"test_dir"}, false, testdev.DevicesInfoGetterMock{})
+	assert.Nil(t, err)
+
+	env, err := os.ReadFile(filepath.Join(out, "env"))
+	assert.Nil(t, err)
+	assert.Regexp(t, `^GDU_DIRS_RESCANNED=0
+GDU_HIT_RATE=0\.0
+GDU_ROOT=\S+/test_dir
+GDU_STATUS=completed
+GDU_TOTAL_SIZE=\d+
+$`, string(env))
+
+	stdin, err := os.ReadFile(filepath.Join(out, "stdin"))
+	assert.Nil(t, err)
+	summary := &analyze.StatsFile{}
+	assert.Nil(t, json.Unmarshal(stdin, summary))
+	assert.Equal(t, "completed", summary.Status)
+	assert.Equal(t, int64(3), summary.Stats.CacheMisses)
+	assert.Contains(t, string(env), fmt.Sprintf("GDU_TOTAL_SIZE=%d\n", summary.Usage))
+
+	// the label is passed only when given
+	flags.ScanLabel = "pre-cleanup"
+	_, err = runApp(flags, []string{"test_dir"}, false, testdev.DevicesInfoGetterMock{})
+	assert.Nil(t, err)
+	env, err = os.ReadFile(filepath.Join(out, "env"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(env), "GDU_LABEL=pre-cleanup\n")
+	flags.ScanLabel = ""
+
+	// the failure of the command is reported by the exit code, the cache stays valid
+	t.Setenv("HOOK_EXIT", "2")
+	_, err = runApp(flags, []string{"test_dir"}, false, testdev.DevicesInfoGetterMock{})
+	var exitErr *ExitError
+	assert.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, ExitHookFailed, exitErr.Code)
+	assert.ErrorContains(t, err, "post-scan command failed")
+
+	env, err = os.ReadFile(filepath.Join(out, "env"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(env), "GDU_HIT_RATE=100.0\n")
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", PostScanCmd: script},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--post-scan-cmd can be used only with --incremental")
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, PostScanStdin: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--post-scan-stdin can be used only with --post-scan-cmd")
+}
+
+func TestPostScanCmdTimeout(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	flags := &Flags{
+		LogFile: "/dev/null", UseIncremental: true, IncrementalPath: t.TempDir(),
+		NonInteractive: true, PostScanCmd: "exec sleep 10", PostScanTimeout: 100 * time.Millisecond,
+	}
+	start := time.Now()
+	_, err := runApp(flags, []string{"test_dir"}, false, testdev.DevicesInfoGetterMock{})
+	var exitErr *ExitError
+	assert.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, ExitHookFailed, exitErr.Code)
+	assert.Less(t, time.Since(start), 5*time.Second, "the command is killed")
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, PostScanTimeout: time.Second},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--post-scan-timeout can be used only with --post-scan-cmd")
+}
+
+func TestRunPostScanCmdOutput(t *testing.T) {
+	summary := &analyze.StatsFile{Root: "/", Status: "completed"}
+	hook := postScanCmd{command: "echo out; echo err >&2", timeout: time.Minute}
+
+	buff := &bytes.Buffer{}
+	hook.output = buff
+	assert.Nil(t, runPostScanCmd(hook, summary))
+	assert.Equal(t, "out\nerr\n", buff.String())
+
+	// the output is discarded in the interactive mode
+	hook.output = nil
+	assert.Nil(t, runPostScanCmd(hook, summary))
+
+	hook.command = "sleep 10"
+	hook.timeout = 100 * time.Millisecond
+	assert.ErrorContains(t, runPostScanCmd(hook, summary), `post-scan command "sleep 10" timed out after 100ms`)
+}
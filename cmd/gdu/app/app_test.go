@@ -2,16 +2,25 @@ package app
 
 import (
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/dundee/gdu/v5/build"
 	"github.com/dundee/gdu/v5/internal/testapp"
 	"github.com/dundee/gdu/v5/internal/testdev"
 	"github.com/dundee/gdu/v5/internal/testdir"
+	"github.com/dundee/gdu/v5/pkg/analyze"
 	"github.com/dundee/gdu/v5/pkg/device"
 	"github.com/stretchr/testify/assert"
 )
@@ -47,6 +56,1171 @@ func TestAnalyzePath(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestAgeHistogram(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	out, err := runApp(
+		&Flags{LogFile: "/dev/null", AgeHistogram: true},
+		[]string{"test_dir"},
+		true,
+		testdev.DevicesInfoGetterMock{},
+	)
+
+	assert.Contains(t, out, "< 30 days")
+	assert.NotContains(t, out, "nested")
+	assert.Nil(t, err)
+}
+
+func TestByOwner(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	out, err := runApp(
+		&Flags{LogFile: "/dev/null", ByOwner: true},
+		[]string{"test_dir"},
+		true,
+		testdev.DevicesInfoGetterMock{},
+	)
+
+	assert.Contains(t, out, "Owner")
+	assert.Contains(t, out, "Total")
+	assert.Nil(t, err)
+}
+
+func TestDuplicates(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	assert.NoError(t, os.WriteFile("test_dir/nested/copy", []byte("hello"), 0o600))
+
+	out, err := runApp(
+		&Flags{LogFile: "/dev/null", Duplicates: Duplicates{Show: true}},
+		[]string{"test_dir"},
+		true,
+		testdev.DevicesInfoGetterMock{},
+	)
+
+	assert.Nil(t, err)
+	assert.Contains(t, out, "2 copies of 5 B")
+	assert.Contains(t, out, "test_dir/nested/copy\n")
+	assert.Contains(t, out, "test_dir/nested/subnested/file\n")
+	assert.Contains(t, out, "1 duplicate sets")
+}
+
+func TestDuplicatesWithUnknownHash(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", Duplicates: Duplicates{Show: true, Hash: "crc"}},
+		[]string{"test_dir"},
+		true,
+		testdev.DevicesInfoGetterMock{},
+	)
+
+	assert.ErrorContains(t, err, `--duplicates-hash: unknown hash algorithm "crc"`)
+}
+
+func TestOffendersWithBaseline(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	defer func() {
+		os.Remove("baseline.json")
+	}()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", OutputFile: "baseline.json"},
+		[]string{"test_dir"},
+		true,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+
+	assert.Nil(t, os.WriteFile("test_dir/nested/file3", make([]byte, 10000), 0o600))
+	assert.Nil(t, os.Mkdir("test_dir/logs", 0o755))
+	assert.Nil(t, os.WriteFile("test_dir/logs/app.log", make([]byte, 100), 0o600))
+
+	out, err := runApp(
+		&Flags{
+			LogFile:          "/dev/null",
+			ShowApparentSize: true,
+			Offenders: Offenders{
+				Top:          5,
+				Baseline:     "baseline.json",
+				SizeWeight:   1,
+				GrowthWeight: 1,
+				JSON:         true,
+			},
+		},
+		[]string{"test_dir"},
+		true,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+
+	var offenders []analyze.Offender
+	assert.Nil(t, json.Unmarshal([]byte(out), &offenders))
+	assert.Len(t, offenders, 3)
+	assert.True(t, strings.HasSuffix(offenders[0].Path, "test_dir/nested"))
+	assert.False(t, offenders[0].New)
+	assert.GreaterOrEqual(t, offenders[0].Growth, int64(10000))
+	assert.True(t, strings.HasSuffix(offenders[1].Path, "test_dir/logs"))
+	assert.True(t, offenders[1].New)
+}
+
+func TestOffendersMissingBaseline(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", Offenders: Offenders{Top: 5, Baseline: "missing.json"}},
+		[]string{"test_dir"},
+		true,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "opening offenders baseline")
+}
+
+func TestBrokenSymlinks(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	assert.Nil(t, os.Symlink("missing", "test_dir/nested/dangling"))
+
+	out, err := runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: t.TempDir(), BrokenSymlinks: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(out), "test_dir/nested/dangling"))
+}
+
+func TestBrokenSymlinksWithoutIncremental(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", BrokenSymlinks: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--broken-symlinks can be used only with --incremental")
+}
+
+func TestEstimateWithoutIncremental(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", EstimateAbove: 1000},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--estimate-above can be used only with --incremental")
+}
+
+func TestNiceOutOfRange(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", Nice: 20},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--nice must be between 0 and 19")
+}
+
+func TestCacheFsck(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	cachePath := t.TempDir()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: cachePath, NonInteractive: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+
+	path, err := filepath.Abs("test_dir")
+	assert.Nil(t, err)
+	storage := analyze.NewIncrementalStorage(cachePath, path)
+	closeFn, err := storage.Open()
+	assert.Nil(t, err)
+	assert.Nil(t, storage.StoreDirMetadata(&analyze.IncrementalDirMetadata{Path: filepath.Join(path, "bad")}))
+	closeFn()
+
+	out, err := runApp(
+		&Flags{LogFile: "/dev/null", CacheFsck: true, IncrementalPath: cachePath},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Contains(t, out, "bad: item count 0")
+	assert.Contains(t, out, "Checked 4 cache entries: 1 invalid, 0 removed")
+	assert.ErrorContains(t, err, "run with --repair")
+
+	out, err = runApp(
+		&Flags{LogFile: "/dev/null", CacheFsck: true, CacheRepair: true, IncrementalPath: cachePath},
+		[]string{},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Contains(t, out, "Checked 4 cache entries: 1 invalid, 1 removed")
+	assert.Nil(t, err)
+}
+
+func TestSelfCheck(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	cachePath := t.TempDir()
+	flags := &Flags{LogFile: "/dev/null", SelfCheck: true, IncrementalPath: cachePath}
+
+	out, err := runApp(flags, []string{"test_dir"}, false, testdev.DevicesInfoGetterMock{})
+	assert.Nil(t, err)
+	assert.Equal(t, "Compared 5 items: 0 mismatches", out)
+
+	// the cache lies about the size of a file
+	path, err := filepath.Abs("test_dir/nested")
+	assert.Nil(t, err)
+	storage := analyze.NewIncrementalStorage(cachePath, path)
+	closeFn, err := storage.Open()
+	assert.Nil(t, err)
+	meta, err := storage.LoadDirMetadata(path)
+	assert.Nil(t, err)
+	for i := range meta.Files {
+		if meta.Files[i].Name == "file2" {
+			meta.Files[i].Size = 100
+		}
+	}
+	assert.Nil(t, storage.StoreDirMetadata(meta))
+	closeFn()
+
+	out, err = runApp(flags, []string{"test_dir"}, false, testdev.DevicesInfoGetterMock{})
+	assert.Contains(t, out, filepath.Join(path, "file2")+": size 100, reference 2")
+	assert.Contains(t, out, "Compared 5 items: 1 mismatches")
+	assert.ErrorContains(t, err, "differs from the sequential one")
+}
+
+func TestExitCodeCleanScan(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: t.TempDir(), NonInteractive: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+}
+
+func TestMultipleDirs(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	assert.Nil(t, os.Mkdir(filepath.Join(first, "nested"), 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(first, "nested", "file"), []byte("hello"), 0o600))
+	assert.Nil(t, os.WriteFile(filepath.Join(second, "other"), []byte("world!"), 0o600))
+	cachePath := t.TempDir()
+
+	out, err := runApp(
+		&Flags{
+			LogFile: "/dev/null", UseIncremental: true, IncrementalPath: cachePath,
+			NonInteractive: true, ShowCacheStats: true,
+		},
+		[]string{first, second},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Contains(t, out, first+":")
+	assert.Contains(t, out, "nested")
+	assert.Contains(t, out, second+":")
+	assert.Contains(t, out, "other")
+	assert.Contains(t, out, "Combined (2 directories):")
+	assert.Contains(t, out, "Hit Rate:         0.0% (0 hits, 3 misses)")
+
+	// every directory is cached under its own path
+	for _, path := range []string{first, filepath.Join(first, "nested"), second} {
+		storage := analyze.NewIncrementalStorage(cachePath, path)
+		closeFn, err := storage.Open()
+		assert.Nil(t, err)
+		meta, err := storage.LoadDirMetadata(path)
+		assert.Nil(t, err)
+		assert.Equal(t, path, meta.Path)
+		closeFn()
+	}
+
+	// the directories are scanned together, the diff covers both of them
+	assert.Nil(t, os.Mkdir(filepath.Join(second, "added"), 0o755))
+	out, err = runApp(
+		&Flags{
+			LogFile: "/dev/null", UseIncremental: true, IncrementalPath: cachePath,
+			NonInteractive: true, ShowCacheStats: true, Diff: 5,
+		},
+		[]string{first, second},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, strings.Count(out, "Growth"))
+	assert.Contains(t, out, filepath.Join(second, "added")+"\n")
+	assert.Contains(t, out, "1 added, 0 removed, 1 changed directories since the previous scan")
+	assert.Contains(t, out, "Hit Rate:         50.0% (1 hits, 1 misses)")
+}
+
+func TestMultipleDirsNotSupported(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags *Flags
+		istty bool
+		err   string
+	}{
+		{"classic analyzer", &Flags{NonInteractive: true}, false, "only with --incremental"},
+		{"sequential", &Flags{UseIncremental: true, SequentialScanning: true}, false, "cannot be scanned with --sequential"},
+		{"cache maintenance", &Flags{UseIncremental: true, CacheInfo: true}, false, "only for a scan"},
+		{"export", &Flags{UseIncremental: true, OutputFile: "-"}, false, "cannot be exported into one output"},
+		{"interactive", &Flags{UseIncremental: true}, true, "only in non-interactive mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.flags.LogFile = "/dev/null"
+			tt.flags.IncrementalPath = t.TempDir()
+			_, err := runApp(tt.flags, []string{"a", "b"}, tt.istty, testdev.DevicesInfoGetterMock{})
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestExitCodeCacheDegraded(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	cachePath := t.TempDir()
+	flags := &Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: cachePath, NonInteractive: true}
+
+	_, err := runApp(flags, []string{"test_dir"}, false, testdev.DevicesInfoGetterMock{})
+	assert.Nil(t, err)
+
+	// leave an invalid entry behind a crashed scan, the next scan removes it
+	corruptCache := func() {
+		path, err := filepath.Abs("test_dir")
+		assert.Nil(t, err)
+		storage := analyze.NewIncrementalStorage(cachePath, path)
+		closeFn, err := storage.Open()
+		assert.Nil(t, err)
+		assert.Nil(t, storage.StoreDirMetadata(&analyze.IncrementalDirMetadata{Path: filepath.Join(path, "bad")}))
+		assert.Nil(t, storage.MarkScanStarted())
+		closeFn()
+	}
+
+	corruptCache()
+	_, err = runApp(flags, []string{"test_dir"}, false, testdev.DevicesInfoGetterMock{})
+	var exitErr *ExitError
+	assert.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, ExitCacheDegraded, exitErr.Code)
+	assert.Equal(t, int64(1), exitErr.Result.CacheErrors)
+
+	corruptCache()
+	flags.LegacyExitCode = true
+	_, err = runApp(flags, []string{"test_dir"}, false, testdev.DevicesInfoGetterMock{})
+	assert.Nil(t, err)
+}
+
+func TestExitCodeFailure(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	cachePath := filepath.Join(t.TempDir(), "file")
+	assert.Nil(t, os.WriteFile(cachePath, []byte("not a cache"), 0o600))
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: cachePath, NonInteractive: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	var exitErr *ExitError
+	assert.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, ExitFailure, exitErr.Code)
+}
+
+func TestScanExitError(t *testing.T) {
+	tests := []struct {
+		result *analyze.ScanResult
+		code   int
+	}{
+		{&analyze.ScanResult{Status: analyze.ScanCompleted}, ExitOK},
+		{&analyze.ScanResult{Status: analyze.ScanFailed, CacheErrors: 1}, ExitFailure},
+		{&analyze.ScanResult{Status: analyze.ScanCancelled, CacheErrors: 1}, ExitInterrupted},
+		{&analyze.ScanResult{Status: analyze.ScanCompletedWithErrors, CacheErrors: 1}, ExitScanErrors},
+		{&analyze.ScanResult{Status: analyze.ScanCompleted, CacheErrors: 2}, ExitCacheDegraded},
+		{&analyze.ScanResult{Status: analyze.ScanCompleted, HookErr: errors.New("exit status 1")}, ExitHookFailed},
+		{&analyze.ScanResult{Status: analyze.ScanCompletedWithErrors, HookErr: errors.New("exit status 1")}, ExitScanErrors},
+	}
+
+	for _, tt := range tests {
+		err := scanExitError(tt.result)
+		if tt.code == ExitOK {
+			assert.Nil(t, err)
+			continue
+		}
+		var exitErr *ExitError
+		assert.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, tt.code, exitErr.Code, tt.result.Status)
+	}
+	assert.Nil(t, scanExitError(nil))
+}
+
+func TestCacheTop(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	cachePath := t.TempDir()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: cachePath, NonInteractive: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+
+	path, err := filepath.Abs("test_dir")
+	assert.Nil(t, err)
+
+	out, err := runApp(
+		&Flags{LogFile: "/dev/null", CacheTop: CacheTop{Top: 2, MinDepth: 1}, IncrementalPath: cachePath},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], "Usage")
+	assert.True(t, strings.HasSuffix(lines[1], filepath.Join(path, "nested")))
+	assert.True(t, strings.HasSuffix(lines[2], filepath.Join(path, "nested", "subnested")))
+
+	out, err = runApp(
+		&Flags{LogFile: "/dev/null", CacheTop: CacheTop{Top: 5, MaxDepth: 0, JSON: true}, IncrementalPath: cachePath},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	var top []analyze.CachedDirTotals
+	assert.Nil(t, json.Unmarshal([]byte(out), &top))
+	assert.Len(t, top, 3)
+	assert.Equal(t, path, top[0].Path)
+}
+
+func TestCacheInfo(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	cachePath := t.TempDir()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: cachePath, NonInteractive: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+
+	hostname, err := os.Hostname()
+	assert.Nil(t, err)
+
+	out, err := runApp(
+		&Flags{LogFile: "/dev/null", CacheInfo: true, IncrementalPath: cachePath},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "Schema Version: "+strconv.Itoa(analyze.IncrementalSchemaVersion))
+	assert.Contains(t, out, "Written By:     gdu "+build.Version+" on "+hostname)
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", CacheInfo: true, IncrementalPath: cachePath},
+		[]string{"test_dir/nonexistent"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "is not in the cache")
+}
+
+func TestCacheDump(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	cachePath := t.TempDir()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: cachePath, NonInteractive: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	path, err := filepath.Abs("test_dir")
+	assert.Nil(t, err)
+	fin() // the dump reads only the cache
+
+	out, err := runApp(
+		&Flags{LogFile: "/dev/null", CacheDump: CacheDump{Output: "-"}, IncrementalPath: cachePath},
+		[]string{},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	lines := strings.Split(out, "\n")
+	assert.Len(t, lines, 3)
+	var top analyze.DumpedDir
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &top))
+	assert.Equal(t, path, top.Path)
+	assert.Equal(t, 1, top.ChildCount)
+
+	dumpFile := filepath.Join(t.TempDir(), "dump.jsonl.gz")
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", CacheDump: CacheDump{Output: dumpFile, Prefix: filepath.Join(path, "nested")}, IncrementalPath: cachePath},
+		[]string{},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	file, err := os.Open(dumpFile)
+	assert.Nil(t, err)
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	assert.Nil(t, err)
+	content, err := io.ReadAll(gz)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, strings.Count(string(content), "\n"))
+	assert.Contains(t, string(content), `"path":"`+filepath.Join(path, "nested", "subnested")+`"`)
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", CacheDump: CacheDump{Prefix: "/srv"}, IncrementalPath: cachePath},
+		[]string{},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--cache-dump-prefix can be used only with --cache-dump")
+}
+
+func TestCacheMaintain(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	cachePath := t.TempDir()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: cachePath, NonInteractive: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+
+	out, err := runApp(
+		&Flags{LogFile: "/dev/null", CacheMaintain: true, CacheRetention: time.Hour, IncrementalPath: cachePath},
+		[]string{},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, "Pruned 0 cache entries and 0 scan summaries older than 1h0m0s", out)
+
+	out, err = runApp(
+		&Flags{LogFile: "/dev/null", CacheMaintain: true, CacheRetention: time.Nanosecond, IncrementalPath: cachePath},
+		[]string{},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, "Pruned 3 cache entries and 1 scan summaries older than 1ns", out)
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", CacheMaintain: true, IncrementalPath: cachePath},
+		[]string{},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--cache-maintain requires --cache-retention")
+}
+
+func TestPruneCache(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	cachePath := t.TempDir()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: cachePath, NonInteractive: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Nil(t, os.RemoveAll("test_dir/nested/subnested"))
+
+	out, err := runApp(
+		&Flags{LogFile: "/dev/null", PruneCache: true, IncrementalPath: cachePath},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "Pruned 1 cache entries of removed directories under ")
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", PruneStale: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--prune-stale can be used only with --incremental")
+}
+
+func TestClearCache(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	cachePath := t.TempDir()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: cachePath, NonInteractive: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+
+	out, err := runApp(
+		&Flags{LogFile: "/dev/null", ClearCache: true, DryRun: true, IncrementalPath: cachePath},
+		[]string{"test_dir/nested"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "/test_dir/nested/subnested\n")
+	assert.Contains(t, out, "Would remove 2 cache entries")
+
+	out, err = runApp(
+		&Flags{LogFile: "/dev/null", ClearCache: true, IncrementalPath: cachePath},
+		[]string{"test_dir/nested"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "Removed 2 cache entries")
+
+	out, err = runApp(
+		&Flags{LogFile: "/dev/null", ClearCache: true, IncrementalPath: cachePath},
+		[]string{},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Regexp(t, "Removed [1-9][0-9]* cache entries", out)
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", DryRun: true, IncrementalPath: cachePath},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--dry-run can be used only with --clear-cache")
+}
+
+func TestExcludeFiles(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	out, err := runApp(
+		&Flags{
+			LogFile: "/dev/null", UseIncremental: true, IncrementalPath: t.TempDir(),
+			NonInteractive: true, ExcludeFiles: []string{"file2"},
+		},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "nested")
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", ExcludeFiles: []string{"*.tmp"}},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--exclude-files can be used only with --incremental")
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: t.TempDir(), ExcludeFiles: []string{"[a-"}},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "invalid file pattern")
+}
+
+func TestStatsFile(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	statsFile := filepath.Join(t.TempDir(), "stats.json")
+
+	_, err := runApp(
+		&Flags{
+			LogFile: "/dev/null", UseIncremental: true, IncrementalPath: t.TempDir(),
+			NonInteractive: true, StatsFile: statsFile,
+		},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	data, err := os.ReadFile(statsFile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"generation": 1`)
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", StatsFile: statsFile},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--stats-file can be used only with --incremental")
+}
+
+func TestBirthTime(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	out, err := runApp(
+		&Flags{
+			LogFile: "/dev/null", UseIncremental: true, IncrementalPath: t.TempDir(),
+			NonInteractive: true, BirthTime: true,
+		},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "nested")
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", BirthTime: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--birth-time can be used only with --incremental")
+}
+
+func TestCacheDirInScannedTree(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	out, err := runApp(
+		&Flags{
+			LogFile: "/dev/null", UseIncremental: true, IncrementalPath: "test_dir/cache",
+			NonInteractive: true, ShowCacheStats: true,
+		},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "test_dir/cache left out of the scan")
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", CountCacheDir: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--count-cache-dir can be used only with --incremental")
+}
+
+func TestInvalidateFrom(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	storagePath := t.TempDir()
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: storagePath, NonInteractive: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+
+	pathsFile := filepath.Join(t.TempDir(), "changed")
+	assert.NoError(t, os.WriteFile(pathsFile, []byte("# changed directories\n\ntest_dir/nested/subnested\n"), 0o600))
+
+	out, err := runApp(
+		&Flags{
+			LogFile: "/dev/null", UseIncremental: true, IncrementalPath: storagePath, NonInteractive: true,
+			InvalidateFrom: pathsFile, TrustUnlisted: true, ShowCacheStats: true,
+		},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "Invalidated:      1 entries of 1 listed directories")
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, InvalidateFrom: filepath.Join(t.TempDir(), "missing")},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "reading --invalidate-from file")
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, TrustUnlisted: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--trust-unlisted can be used only with --invalidate-from")
+}
+
+func TestStartAtNonInteractive(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", NonInteractive: true, StartAt: "test_dir/nested"},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--start-at can be used only in interactive mode")
+}
+
+func TestKeepPreviousNonInteractive(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", NonInteractive: true, KeepPrevious: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--keep-previous can be used only in interactive mode")
+}
+
+func TestScanRetries(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: t.TempDir(), ScanRetries: 3, ScanRetryDelay: time.Millisecond},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		flags *Flags
+		err   string
+	}{
+		{&Flags{ScanRetries: 3}, "--scan-retries can be used only with --incremental"},
+		{&Flags{ScanRetries: -1, UseIncremental: true}, "must not be negative"},
+		{&Flags{ScanRetryDelay: -time.Second, UseIncremental: true}, "must not be negative"},
+	}
+	for _, tt := range tests {
+		tt.flags.LogFile = "/dev/null"
+		_, err := runApp(tt.flags, []string{"test_dir"}, false, testdev.DevicesInfoGetterMock{})
+		assert.ErrorContains(t, err, tt.err)
+	}
+}
+
+func TestHashVerify(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: t.TempDir(), HashVerify: []string{"test_dir/nested"}},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", HashVerify: []string{"test_dir/nested"}},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--hash-verify can be used only with --incremental")
+}
+
+func TestRefreshPath(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: t.TempDir(), RefreshPaths: []string{"test_dir/nested"}},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", RefreshPaths: []string{"test_dir/nested"}},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--refresh-path can be used only with --incremental")
+}
+
+func TestFollowDirSymlinks(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	assert.Nil(t, os.Symlink("nested", "test_dir/link"))
+
+	out, err := runApp(
+		&Flags{
+			LogFile: "/dev/null", UseIncremental: true, IncrementalPath: t.TempDir(),
+			FollowSymlinks: true, FollowDirSymlinks: true,
+		},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "/link")
+
+	tests := []struct {
+		flags *Flags
+		err   string
+	}{
+		{&Flags{FollowDirSymlinks: true, FollowSymlinks: true}, "--follow-dir-symlinks can be used only with --incremental"},
+		{&Flags{FollowDirSymlinks: true, UseIncremental: true}, "--follow-dir-symlinks can be used only with --follow-symlinks"},
+	}
+	for _, tt := range tests {
+		tt.flags.LogFile = "/dev/null"
+		_, err := runApp(tt.flags, []string{"test_dir"}, false, testdev.DevicesInfoGetterMock{})
+		assert.ErrorContains(t, err, tt.err)
+	}
+}
+
+func TestTree(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	out, err := runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: t.TempDir(), Tree: 1},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "└── nested/\n")
+	assert.NotContains(t, out, "subnested")
+	assert.Contains(t, out, "Marks: = cached")
+
+	out, err = runApp(
+		&Flags{LogFile: "/dev/null", Tree: 2},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "├── subnested/\n")
+	assert.NotContains(t, out, "Marks:")
+}
+
+func TestDiff(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	flags := &Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: t.TempDir(), Diff: 5}
+	_, err := runApp(flags, []string{"test_dir"}, false, testdev.DevicesInfoGetterMock{})
+	assert.Nil(t, err)
+
+	assert.Nil(t, os.Mkdir("test_dir/added", 0o755))
+	out, err := runApp(flags, []string{"test_dir"}, false, testdev.DevicesInfoGetterMock{})
+	assert.Nil(t, err)
+	assert.Contains(t, out, "new")
+	assert.Contains(t, out, "/test_dir/added\n")
+	assert.Contains(t, out, "1 added, 0 removed, 1 changed directories since the previous scan")
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", Diff: 5},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--diff can be used only with --incremental")
+}
+
+func TestScanWorkers(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	// the workers share the throttle
+	out, err := runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, IncrementalPath: t.TempDir(), ScanWorkers: 4, MaxIOPS: 100},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "nested")
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", ScanWorkers: 4},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--scan-workers can be used only with --incremental")
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", UseIncremental: true, ScanWorkers: -1},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--scan-workers must not be negative")
+}
+
+func TestDiffByLabels(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	storage := t.TempDir()
+	scan := func(flags *Flags) (string, error) {
+		flags.LogFile = "/dev/null"
+		flags.UseIncremental = true
+		flags.IncrementalPath = storage
+		return runApp(flags, []string{"test_dir"}, false, testdev.DevicesInfoGetterMock{})
+	}
+	_, err := scan(&Flags{ScanLabel: "pre-cleanup"})
+	assert.Nil(t, err)
+	assert.Nil(t, os.Mkdir("test_dir/added", 0o755))
+	_, err = scan(&Flags{ScanLabel: "post-cleanup"})
+	assert.Nil(t, err)
+	assert.Nil(t, os.Mkdir("test_dir/later", 0o755))
+	_, err = scan(&Flags{})
+	assert.Nil(t, err)
+
+	out, err := scan(&Flags{Diff: 5, DiffFromLabel: "pre-cleanup", DiffToLabel: "post-cleanup"})
+	assert.Nil(t, err)
+	assert.Contains(t, out, "/test_dir/added\n")
+	assert.NotContains(t, out, "later")
+	assert.Contains(t, out, `1 added, 0 removed, 1 changed directories since the scan labelled "pre-cleanup" `+
+		`until the scan labelled "post-cleanup"`)
+
+	_, err = scan(&Flags{Diff: 5, DiffFromLabel: "missing"})
+	assert.ErrorContains(t, err, `labelled "missing"`)
+
+	_, err = scan(&Flags{DiffFromLabel: "pre-cleanup"})
+	assert.ErrorContains(t, err, "--from-label can be used only with --diff")
+	_, err = scan(&Flags{Diff: 5, DiffToLabel: "post-cleanup"})
+	assert.ErrorContains(t, err, "--to-label can be used only with --from-label")
+}
+
+func TestEmptyDirs(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	assert.Nil(t, os.MkdirAll("test_dir/empty/inner", 0o755))
+
+	out, err := runApp(
+		&Flags{LogFile: "/dev/null", EmptyDirs: EmptyDirs{Show: true, Recursive: true, Script: true}},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Regexp(t, "rmdir -- '.*/test_dir/empty/inner'\nrmdir -- '.*/test_dir/empty'", out)
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", EmptyDirs: EmptyDirs{Script: true}},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, "--empty-dirs-script can be used only with --empty-dirs")
+}
+
+func TestMemoryMode(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	out, err := runApp(
+		&Flags{
+			LogFile: "/dev/null", UseIncremental: true, IncrementalPath: t.TempDir(),
+			NonInteractive: true, ShowCacheStats: true, MemoryMode: "balanced", GCPercent: 30,
+		},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "Peak Heap:")
+
+	tests := []struct {
+		flags *Flags
+		err   string
+	}{
+		{&Flags{MemoryMode: "lazy", UseIncremental: true}, "unknown memory mode"},
+		{&Flags{MemoryMode: "balanced"}, "--memory-mode can be used only with --incremental"},
+		{&Flags{MemoryMode: "balanced", UseIncremental: true, ConstGC: true}, "cannot be used at once"},
+		{&Flags{GCPercent: 30, UseIncremental: true}, "--gc-percent can be used only with --memory-mode balanced"},
+		{&Flags{GCPercent: -1, MemoryMode: "balanced", UseIncremental: true}, "--gc-percent must be positive"},
+	}
+	for _, tt := range tests {
+		tt.flags.LogFile = "/dev/null"
+		_, err := runApp(tt.flags, []string{"test_dir"}, false, testdev.DevicesInfoGetterMock{})
+		assert.ErrorContains(t, err, tt.err)
+	}
+}
+
+func TestValidationMode(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	cachePath := t.TempDir()
+	var out string
+	var err error
+	// the second run validates the entries cached by the first one
+	for range 2 {
+		out, err = runApp(
+			&Flags{
+				LogFile: "/dev/null", UseIncremental: true, IncrementalPath: cachePath,
+				NonInteractive: true, ShowCacheStats: true, ValidationMode: "mtime+count",
+			},
+			[]string{"test_dir"},
+			false,
+			testdev.DevicesInfoGetterMock{},
+		)
+		assert.Nil(t, err)
+	}
+	assert.Contains(t, out, "Validation:       3 directories listed, 0 changed")
+
+	tests := []struct {
+		flags *Flags
+		err   string
+	}{
+		{&Flags{ValidationMode: "count", UseIncremental: true}, "unknown validation mode"},
+		{&Flags{ValidationMode: "mtime+count"}, "--validation-mode can be used only with --incremental"},
+	}
+	for _, tt := range tests {
+		tt.flags.LogFile = "/dev/null"
+		_, err := runApp(tt.flags, []string{"test_dir"}, false, testdev.DevicesInfoGetterMock{})
+		assert.ErrorContains(t, err, tt.err)
+	}
+}
+
 func TestSequentialScanning(t *testing.T) {
 	fin := testdir.CreateTestDir()
 	defer fin()
@@ -352,6 +1526,38 @@ func TestAnalyzePathWithExport(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestAnalyzePathWithBinaryExport(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	output := filepath.Join(t.TempDir(), "output.gdub")
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", OutputFile: output},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+
+	// the format is recognized when reading
+	out, err := runApp(
+		&Flags{LogFile: "/dev/null", InputFile: output},
+		[]string{},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "nested")
+
+	_, err = runApp(
+		&Flags{LogFile: "/dev/null", OutputFile: output, OutputFormat: "xml"},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.ErrorContains(t, err, `unknown output format "xml"`)
+}
+
 func TestAnalyzePathWithChdir(t *testing.T) {
 	fin := testdir.CreateTestDir()
 	defer fin()
@@ -564,3 +1770,36 @@ func runApp(flags *Flags, args []string, istty bool, getter device.DevicesInfoGe
 
 	return strings.TrimSpace(buff.String()), err
 }
+
+func TestImportStorage(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	storagePath := t.TempDir()
+	cachePath := t.TempDir()
+
+	_, err := runApp(
+		&Flags{LogFile: "/dev/null", UseStorage: true, StoragePath: storagePath, NonInteractive: true},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+
+	out, err := runApp(
+		&Flags{LogFile: "/dev/null", ImportStorage: true, StoragePath: storagePath, IncrementalPath: cachePath},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "Imported 3 directories, skipped 0")
+
+	out, err = runApp(
+		&Flags{LogFile: "/dev/null", CacheTop: CacheTop{Top: 1, MinDepth: 1}, IncrementalPath: cachePath},
+		[]string{"test_dir"},
+		false,
+		testdev.DevicesInfoGetterMock{},
+	)
+	assert.Nil(t, err)
+	assert.Contains(t, out, filepath.Join("test_dir", "nested"))
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/dundee/gdu/v5/pkg/analyze"
+)
+
+// DefaultPostScanTimeout is the time --post-scan-cmd can run when --post-scan-timeout is not set
+const DefaultPostScanTimeout = time.Minute
+
+// postScanHook returns the hook of the incremental analyzer running --post-scan-cmd
+// after every scan, nil if the command is not set.
+// In the interactive mode the output of the command is discarded, it would overwrite the screen
+func (a *App) postScanHook() func(*analyze.StatsFile) error {
+	if a.Flags.PostScanCmd == "" {
+		return nil
+	}
+	var output io.Writer = os.Stderr
+	if !a.Flags.ShouldRunInNonInteractiveMode(a.Istty) {
+		output = nil
+	}
+	timeout := a.Flags.PostScanTimeout
+	if timeout <= 0 {
+		timeout = DefaultPostScanTimeout
+	}
+	return func(summary *analyze.StatsFile) error {
+		return runPostScanCmd(postScanCmd{
+			command:      a.Flags.PostScanCmd,
+			apparentSize: a.Flags.ShowApparentSize,
+			withStdin:    a.Flags.PostScanStdin,
+			output:       output,
+			timeout:      timeout,
+		}, summary)
+	}
+}
+
+// postScanCmd is the --post-scan-cmd with its options
+type postScanCmd struct {
+	command      string
+	apparentSize bool          // GDU_TOTAL_SIZE is the apparent size
+	withStdin    bool          // the summary is piped to stdin as JSON
+	output       io.Writer     // stdout and stderr of the command, discarded if nil
+	timeout      time.Duration // the command is killed when it runs longer
+}
+
+// runPostScanCmd runs the command by the shell with the summary of the scan in environment variables,
+// with withStdin also as JSON on its standard input. Output of the command goes to output (stderr),
+// so it does not mix with the output of gdu. The command is killed after the timeout
+func runPostScanCmd(hook postScanCmd, summary *analyze.StatsFile) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hook.timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", hook.command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", hook.command)
+	}
+	// children of the shell may keep the output open after it is killed
+	cmd.WaitDelay = time.Second
+
+	size := summary.Usage
+	if hook.apparentSize {
+		size = summary.Size
+	}
+	hitRate := 0.0
+	rescanned := int64(0)
+	if summary.Stats != nil {
+		hitRate = summary.Stats.HitRate()
+		rescanned = summary.Stats.DirsRescanned
+	}
+	cmd.Env = append(os.Environ(),
+		"GDU_ROOT="+summary.Root,
+		"GDU_TOTAL_SIZE="+strconv.FormatInt(size, 10),
+		"GDU_HIT_RATE="+strconv.FormatFloat(hitRate, 'f', 1, 64),
+		"GDU_DIRS_RESCANNED="+strconv.FormatInt(rescanned, 10),
+		"GDU_STATUS="+summary.Status,
+	)
+	if summary.Label != "" {
+		cmd.Env = append(cmd.Env, "GDU_LABEL="+summary.Label)
+	}
+
+	if hook.withStdin {
+		data, err := json.Marshal(summary)
+		if err != nil {
+			return fmt.Errorf("encoding scan summary: %w", err)
+		}
+		cmd.Stdin = bytes.NewReader(data)
+	}
+	cmd.Stdout = hook.output
+	cmd.Stderr = hook.output
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("post-scan command %q timed out after %s", hook.command, hook.timeout)
+		}
+		return fmt.Errorf("running post-scan command %q: %w", hook.command, err)
+	}
+	return nil
+}
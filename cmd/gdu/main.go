@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -16,6 +17,7 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"github.com/dundee/gdu/v5/cmd/gdu/app"
+	"github.com/dundee/gdu/v5/pkg/analyze"
 	"github.com/dundee/gdu/v5/pkg/device"
 )
 
@@ -25,14 +27,14 @@ var (
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "gdu [directory_to_scan]",
+	Use:   "gdu [directory_to_scan ...]",
 	Short: "Pretty fast disk usage analyzer written in Go",
 	Long: `Pretty fast disk usage analyzer written in Go.
 
 Gdu is intended primarily for SSD disks where it can fully utilize parallel processing.
 However HDDs work as well, but the performance gain is not so huge.
 `,
-	Args:         cobra.MaximumNArgs(1),
+	Args:         cobra.ArbitraryArgs,
 	SilenceUsage: true,
 	RunE:         runE,
 }
@@ -43,8 +45,11 @@ func init() {
 	flags.StringVar(&af.CfgFile, "config-file", "", "Read config from file (default is $HOME/.gdu.yaml)")
 	flags.StringVarP(&af.LogFile, "log-file", "l", "/dev/null", "Path to a logfile")
 	flags.StringVarP(&af.OutputFile, "output-file", "o", "", "Export all info into file as JSON")
-	flags.StringVarP(&af.InputFile, "input-file", "f", "", "Import analysis from JSON file")
+	flags.StringVar(&af.OutputFormat, "output-format", "", "Format of the output file: json or binary (compact, gzip-compressed; default is binary for *.gdub files, json otherwise)")
+	flags.StringVarP(&af.InputFile, "input-file", "f", "", "Import analysis from JSON file (or binary export)")
 	flags.IntVarP(&af.MaxCores, "max-cores", "m", runtime.NumCPU(), fmt.Sprintf("Set max cores that Gdu will use. %d cores available", runtime.NumCPU()))
+	flags.IntVar(&af.Nice, "nice", 0, "Lower CPU priority of the scan by setting niceness of the process (0-19)")
+	flags.BoolVar(&af.SchedIdle, "sched-idle", false, "Run the scan with SCHED_IDLE scheduling policy, i.e. only when CPU is otherwise idle (Linux only)")
 	flags.BoolVar(&af.SequentialScanning, "sequential", false, "Use sequential scanning (intended for rotating HDDs)")
 	flags.BoolVarP(&af.ShowVersion, "version", "v", false, "Print version")
 
@@ -57,7 +62,7 @@ func init() {
 	flags.BoolVarP(&af.NoHidden, "no-hidden", "H", false, "Ignore hidden directories (beginning with dot)")
 	flags.BoolVarP(
 		&af.FollowSymlinks, "follow-symlinks", "L", false,
-		"Follow symlinks for files, i.e. show the size of the file to which symlink points to (symlinks to directories are not followed)",
+		"Follow symlinks for files, i.e. show the size of the file to which symlink points to (symlinks to directories are not followed, see --follow-dir-symlinks)",
 	)
 	flags.BoolVarP(
 		&af.ShowAnnexedSize, "show-annexed-size", "A", false,
@@ -65,6 +70,8 @@ func init() {
 	)
 	flags.BoolVarP(&af.NoCross, "no-cross", "x", false, "Do not cross filesystem boundaries")
 	flags.BoolVarP(&af.ConstGC, "const-gc", "g", false, "Enable memory garbage collection during analysis with constant level set by GOGC")
+	flags.StringVar(&af.MemoryMode, "memory-mode", "", "How garbage collection runs during incremental scans: aggressive (disabled while memory is free, default), balanced (enabled at --gc-percent) or constant (as set by GOGC)")
+	flags.IntVar(&af.GCPercent, "gc-percent", 0, fmt.Sprintf("GC percent of --memory-mode balanced (default %d)", analyze.DefaultBalancedGCPercent))
 	flags.BoolVar(&af.Profiling, "enable-profiling", false, "Enable collection of profiling data and provide it on http://localhost:6060/debug/pprof/")
 
 	flags.BoolVar(&af.UseStorage, "use-storage", false, "Use persistent key-value storage for analysis data (experimental)")
@@ -73,11 +80,60 @@ func init() {
 
 	flags.BoolVar(&af.UseIncremental, "incremental", false, "Enable incremental caching to reduce I/O on subsequent scans")
 	flags.StringVar(&af.IncrementalPath, "incremental-path", "", "Path to incremental cache directory (default: $HOME/.cache/gdu/incremental)")
+	flags.DurationVar(&af.CacheRetention, "cache-retention", 0, "Remove incremental cache entries of other trees not written for longer than this (e.g. 720h) after every scan. 0 keeps them forever")
+	flags.BoolVar(&af.PruneStale, "prune-stale", false, "Remove incremental cache entries of directories under the scanned one which are gone from the filesystem after every scan")
 	flags.DurationVar(&af.CacheMaxAge, "cache-max-age", 0, "Maximum age of cache entries before refresh (e.g., 24h, 7d). 0 means no expiry")
+	flags.DurationVar(&af.FutureSkew, "future-skew", 0, "Scan again directories with mtime or cache entry later than now plus this clock skew (e.g. 1h). 0 disables the check")
+	flags.DurationVar(&af.BackwardsSkew, "backwards-skew", 0, "Scan again directories with mtime earlier than the cached one by more than this clock skew (e.g. 1h) and report them. 0 disables the check")
+	flags.BoolVar(&af.TrustCachedAhead, "trust-cached-ahead", false, "Use incremental cache entries written later than now (after the system clock was stepped backwards) instead of scanning their directories again")
 	flags.BoolVar(&af.ForceFullScan, "force-full-scan", false, "Ignore cache and perform full scan (updates cache)")
+	flags.StringSliceVar(&af.RefreshPaths, "refresh-path", []string{}, "Directories scanned without the incremental cache together with their subdirectories, while the rest is loaded from it (separated by comma)")
+	flags.StringSliceVar(&af.ExcludeFiles, "exclude-files", []string{}, "File name patterns (e.g. *.tmp) left out of the sizes in incremental mode (separated by comma)")
+	flags.StringSliceVar(&af.HashVerify, "hash-verify", []string{}, "Directories whose mtime is not trusted in incremental mode, fingerprint of their children (names, sizes and mtimes of files) is compared on cache hits (separated by comma)")
+	flags.StringVar(&af.ValidationMode, "validation-mode", "", "How incremental cache entries are checked: mtime (trust unchanged directories and their subdirectories, default) or mtime+count (list every directory and compare names of its children, e.g. for NFS with attribute caching)")
+	flags.BoolVar(&af.CacheLowMemory, "cache-low-memory", false, "Open the incremental cache with small memtables and caches, for devices with little RAM (slower writes of big scans)")
+	flags.BoolVar(&af.CountCacheDir, "count-cache-dir", false, "Count the incremental cache directory when it is located in the scanned tree (it is left out by default)")
+	flags.BoolVar(&af.BirthTime, "birth-time", false, "Read birth time of scanned items in incremental mode (Linux only, one more statx call per item)")
+	flags.BoolVar(&af.AllowVolatileCache, "allow-volatile-cache", false, "Do not warn about the incremental cache located on tmpfs, ramfs or in a location cleaned on reboot (e.g. /tmp)")
+	flags.BoolVar(&af.TrustRootMtime, "trust-root-mtime", false, "Load only the top directory from the incremental cache if its mtime did not change since the last clean scan (trusts that changes propagate to the top directory's mtime)")
+	flags.StringVar(&af.InvalidateFrom, "invalidate-from", "", "File listing directories (one per line) whose incremental cache entries are removed with their subtrees before the scan, e.g. from a feed of changes")
+	flags.BoolVar(&af.TrustUnlisted, "trust-unlisted", false, "Load directories not listed by --invalidate-from from the incremental cache without checking their mtime (dangerous, changes not listed are missed)")
 	flags.BoolVar(&af.ShowCacheStats, "show-cache-stats", false, "Display cache statistics after scan")
+	flags.BoolVar(&af.LegacyExitCode, "legacy-exit-code", false, "Exit with 0 after every finished scan, otherwise non-interactive incremental scans exit with 3 on read errors, 4 on cache errors, 5 on failed --post-scan-cmd and 130 when interrupted")
+	flags.BoolVar(&af.TraceCache, "trace-cache", false, "Log why each directory was loaded from the incremental cache or scanned (see --log-file)")
+	flags.StringVar(&af.PostScanCmd, "post-scan-cmd", "", "Run this shell command after every incremental scan with GDU_ROOT, GDU_TOTAL_SIZE, GDU_HIT_RATE, GDU_DIRS_RESCANNED, GDU_STATUS and GDU_LABEL set, its failure gives exit code 5")
+	flags.BoolVar(&af.PostScanStdin, "post-scan-stdin", false, "Pipe JSON with the scan result and cache statistics to stdin of --post-scan-cmd")
+	flags.DurationVar(&af.PostScanTimeout, "post-scan-timeout", 0, "Kill --post-scan-cmd running longer than this (default 1m)")
+	flags.StringVar(&af.ScanLabel, "scan-label", "", "Label of the incremental scan (e.g. pre-cleanup) stored with its summary, written to --stats-file and passed to --post-scan-cmd as GDU_LABEL")
+	flags.StringVar(&af.StatsFile, "stats-file", "", "Replace this file by JSON with incremental cache statistics after every scan")
+	flags.BoolVar(&af.SelfCheck, "self-check", false, "Compare the incremental scan of the given directory with the sequential one and list the differences")
+	_ = flags.MarkHidden("self-check")
+	flags.BoolVar(&af.CacheFsck, "cache-fsck", false, "Check integrity of the incremental cache (of the given directory only if there is one)")
+	flags.BoolVar(&af.CacheInfo, "cache-info", false, "Show the incremental cache entry of the given directory including the host and gdu version which wrote it, without scanning")
+	flags.BoolVar(&af.CacheRepair, "repair", false, "Remove invalid entries found by --cache-fsck")
+	flags.BoolVar(&af.CacheMaintain, "cache-maintain", false, "Remove incremental cache entries not written for longer than --cache-retention without scanning")
+	flags.BoolVar(&af.PruneCache, "prune-cache", false, "Remove incremental cache entries of directories under the given directory which are gone from the filesystem, without scanning")
+	flags.BoolVar(&af.ClearCache, "clear-cache", false, "Remove the incremental cache (of the given directory and its subdirectories only if there is one)")
+	flags.BoolVar(&af.DryRun, "dry-run", false, "Show what --clear-cache would remove without removing anything")
+	flags.IntVar(&af.CacheTop.Top, "cache-top", 0, "List top X directories by disk usage under the given directory read from the incremental cache, without scanning")
+	flags.IntVar(&af.CacheTop.MinDepth, "cache-top-min-depth", 0, "List only directories at least this deep below the given directory (with --cache-top)")
+	flags.IntVar(&af.CacheTop.MaxDepth, "cache-top-max-depth", 0, "List only directories up to this depth below the given directory (with --cache-top, 0 = unlimited)")
+	flags.BoolVar(&af.CacheTop.JSON, "cache-top-json", false, "Print the directories listed by --cache-top as JSON")
+	flags.StringVar(&af.CacheDump.Output, "cache-dump", "", "Dump the directories of the incremental cache as JSON lines into file (- for stdout, gzip-compressed for *.gz), without scanning")
+	flags.StringVar(&af.CacheDump.Prefix, "cache-dump-prefix", "", "Dump only the given directory and its subdirectories (with --cache-dump)")
+	flags.BoolVar(&af.ImportStorage, "import-storage", false, "Import the given directory from the persistent storage (--storage-path) into the incremental cache, without scanning")
+	flags.StringVar(&af.APIListen, "api-listen", "", "Serve HTTP API answering queries from the incremental cache at this address (e.g. localhost:8080)")
+	flags.StringVar(&af.APIToken, "api-token", "", "Token required by rescans requested from the HTTP API (POST /rescan is disabled without it)")
+	flags.BoolVar(&af.FollowDirSymlinks, "follow-dir-symlinks", false, "Scan symlinks to directories as the directories in incremental mode (with --follow-symlinks), directories reached again are added only as references")
+	flags.BoolVar(&af.VerifySymlinks, "verify-symlinks", false, "Resolve again symlinks of directories loaded from the incremental cache (with --follow-symlinks)")
+	flags.IntVar(&af.EstimateAbove, "estimate-above", 0, "Estimate size of directories with more than N files from a random sample of them (incremental mode, 0 = exact)")
+	flags.IntVar(&af.EstimateSample, "estimate-sample", 0, "Number of files read in estimated directories (default 100)")
+	flags.Int64Var(&af.MinItemSize, "min-item-size", 0, "Fold items smaller than this size in bytes of every directory into one <other> item in incremental mode (0 = show all)")
 	flags.IntVar(&af.MaxIOPS, "max-iops", 0, "Limit I/O operations per second to protect shared storage (0 = unlimited)")
 	flags.DurationVar(&af.IODelay, "io-delay", 0, "Add fixed delay between directory scans (e.g., 10ms, 100ms)")
+	flags.IntVar(&af.ScanRetries, "scan-retries", 0, "Retry stats and reads of directories failing with transient errors (EIO, ESTALE, ...) up to N times (incremental mode)")
+	flags.DurationVar(&af.ScanRetryDelay, "scan-retry-delay", 0, "Delay before the first retry of a failed read, doubled for every further one (default 100ms)")
+	flags.IntVar(&af.ScanWorkers, "scan-workers", 0, "Number of directories processed at the same time by incremental scans (0 = number of CPUs, 1 = one by one, e.g. for rotating HDDs)")
 
 	flags.BoolVarP(&af.ShowDisks, "show-disks", "d", false, "Show all mounted disks")
 	flags.BoolVarP(&af.ShowApparentSize, "show-apparent-size", "a", false, "Show apparent size")
@@ -90,6 +146,29 @@ func init() {
 	flags.BoolVarP(&af.NoUnicode, "no-unicode", "u", false, "Do not use Unicode symbols (for size bar)")
 	flags.BoolVarP(&af.Summarize, "summarize", "s", false, "Show only a total in non-interactive mode")
 	flags.IntVarP(&af.Top, "top", "t", 0, "Show only top X largest files in non-interactive mode")
+	flags.IntVar(&af.Offenders.Top, "offenders", 0, "Show top X directories ranked by size and growth since the baseline in non-interactive mode")
+	flags.StringVar(&af.Offenders.Baseline, "offenders-baseline", "", "JSON export of the previous scan (see --output-file) to measure the growth against")
+	flags.IntVar(&af.Offenders.Depth, "offenders-depth", analyze.DefaultOffenderOptions.MaxDepth, "Rank directories only up to this depth below the scanned directory (0 = unlimited)")
+	flags.Float64Var(&af.Offenders.SizeWeight, "offenders-size-weight", analyze.DefaultOffenderOptions.SizeWeight, "Weight of the size of directory in the ranking")
+	flags.Float64Var(&af.Offenders.GrowthWeight, "offenders-growth-weight", analyze.DefaultOffenderOptions.GrowthWeight, "Weight of the growth of directory in the ranking")
+	flags.BoolVar(&af.Offenders.JSON, "offenders-json", false, "Print the ranking of directories as JSON")
+	flags.BoolVar(&af.Duplicates.Show, "duplicates", false, "Show files with the same content in non-interactive mode")
+	flags.Int64Var(&af.Duplicates.MinSize, "duplicates-min-size", analyze.DefaultDuplicateOptions.MinSize, "Compare only files of at least this size in bytes with --duplicates")
+	flags.StringVar(&af.Duplicates.Hash, "duplicates-hash", analyze.DefaultDuplicateOptions.Hash, "Hash algorithm comparing content of files with --duplicates (sha256, sha512, sha1, md5)")
+	flags.IntVar(&af.Duplicates.MaxFiles, "duplicates-max-files", analyze.DefaultDuplicateOptions.MaxFiles, "Hash at most this number of files with --duplicates (0 = unlimited)")
+	flags.BoolVar(&af.EmptyDirs.Show, "empty-dirs", false, "List empty directories in non-interactive mode")
+	flags.BoolVar(&af.EmptyDirs.Recursive, "empty-dirs-recursive", false, "List also directories containing only empty directories (with --empty-dirs and by P in interactive mode)")
+	flags.BoolVar(&af.EmptyDirs.Script, "empty-dirs-script", false, "Print shell script removing the empty directories instead of the list (with --empty-dirs)")
+	flags.BoolVar(&af.KeepPrevious, "keep-previous", false, "Keep the directory replaced by a rescan in interactive mode to switch back to it by u (holds the old tree in memory)")
+	flags.StringVar(&af.StartAt, "start-at", "", "Open the interactive mode at this directory (or file) below the scanned one, e.g. found in a non-interactive report")
+	flags.BoolVar(&af.AgeHistogram, "age-histogram", false, "Show sizes of files by age of their mtime in non-interactive mode")
+	flags.BoolVar(&af.ByOwner, "by-owner", false, "Show usage of files by their owner in non-interactive mode")
+	flags.IntVar(&af.ByOwnerTop, "by-owner-top", 20, "Show only top X owners with --by-owner (0 = all)")
+	flags.IntVar(&af.Tree, "tree", 0, "Show the directory tree down to X levels in non-interactive mode, with --incremental marked by how directories were read")
+	flags.IntVar(&af.Diff, "diff", 0, "Show top X directories grown since the previous scan in non-interactive mode, with --incremental")
+	flags.StringVar(&af.DiffFromLabel, "from-label", "", "Compare with --diff the latest scan labelled by --scan-label with this label instead of the previous scan")
+	flags.StringVar(&af.DiffToLabel, "to-label", "", "Compare with --diff the scan of --from-label with the latest scan with this label instead of the last scan")
+	flags.BoolVar(&af.BrokenSymlinks, "broken-symlinks", false, "List symlinks which could not be followed in non-interactive mode (requires --incremental)")
 	flags.BoolVar(&af.UseSIPrefix, "si", false, "Show sizes with decimal SI prefixes (kB, MB, GB) instead of binary prefixes (KiB, MiB, GiB)")
 	flags.BoolVar(&af.NoPrefix, "no-prefix", false, "Show sizes as raw numbers without any prefixes (SI or binary) in non-interactive mode")
 	flags.BoolVar(&af.ReverseSort, "reverse-sort", false, "Reverse sorting order (smallest to largest) in non-interactive mode")
@@ -250,6 +329,10 @@ func runE(command *cobra.Command, args []string) error {
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr *app.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		os.Exit(1)
 	}
 }
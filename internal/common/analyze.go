@@ -9,7 +9,10 @@ type CurrentProgress struct {
 	TotalSize       int64
 }
 
-// ShouldDirBeIgnored whether path should be ignored
+// ShouldDirBeIgnored whether path should be ignored.
+// Name is the name of the directory and path is the scanned path as given to AnalyzeDir
+// (relative if it was relative) joined with the names of the directories below it.
+// All analyzers pass the same arguments, so ignore patterns match the same directories
 type ShouldDirBeIgnored func(name, path string) bool
 
 // Analyzer is type for dir analyzing function
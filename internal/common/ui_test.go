@@ -42,9 +42,11 @@ func (a *MockedAnalyzer) AnalyzeDir(
 	return nil
 }
 
-// GetProgressChan returns always Done
+// GetProgressChan returns always closed channel
 func (a *MockedAnalyzer) GetProgressChan() chan CurrentProgress {
-	return make(chan CurrentProgress)
+	c := make(chan CurrentProgress)
+	close(c)
+	return c
 }
 
 // GetDone returns always Done
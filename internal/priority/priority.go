@@ -0,0 +1,59 @@
+// Package priority lowers CPU priority of the running process so that the scan
+// does not slow down other work on busy hosts
+package priority
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Applied describes the priority which was set by Lower
+type Applied struct {
+	Nice      int  // niceness of the process, 0 if it was not changed
+	SchedIdle bool // SCHED_IDLE scheduling policy was set (Linux only)
+}
+
+// String returns human readable description of the applied priority
+func (a Applied) String() string {
+	parts := make([]string, 0, 2)
+	if a.Nice > 0 {
+		parts = append(parts, fmt.Sprintf("nice %d", a.Nice))
+	}
+	if a.SchedIdle {
+		parts = append(parts, "SCHED_IDLE")
+	}
+	if len(parts) == 0 {
+		return "default"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Lower sets niceness of the process to nice (if positive) and the SCHED_IDLE scheduling
+// policy if schedIdle is set. A setting which could not be applied (not permitted,
+// not supported by the platform) is reported in the returned error and the others
+// are applied anyway, so the error should be treated as a warning
+func Lower(nice int, schedIdle bool) (Applied, error) {
+	var (
+		applied Applied
+		errs    []error
+	)
+
+	if nice > 0 {
+		if err := setNice(nice); err != nil {
+			errs = append(errs, fmt.Errorf("setting niceness to %d: %w", nice, err))
+		} else {
+			applied.Nice = nice
+		}
+	}
+
+	if schedIdle {
+		if err := setSchedIdle(); err != nil {
+			errs = append(errs, fmt.Errorf("setting SCHED_IDLE policy: %w", err))
+		} else {
+			applied.SchedIdle = true
+		}
+	}
+
+	return applied, errors.Join(errs...)
+}
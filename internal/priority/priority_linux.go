@@ -0,0 +1,60 @@
+//go:build linux
+// +build linux
+
+package priority
+
+import (
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// Niceness and scheduling policy are attributes of threads on Linux,
+// so they are set for every thread of the process. Threads started later inherit them
+var (
+	threadIDs    = listThreads
+	setpriority  = unix.Setpriority
+	schedSetAttr = unix.SchedSetAttr
+)
+
+func setNice(nice int) error {
+	return forEachThread(func(tid int) error {
+		return setpriority(unix.PRIO_PROCESS, tid, nice)
+	})
+}
+
+func setSchedIdle() error {
+	attr := &unix.SchedAttr{Size: unix.SizeofSchedAttr, Policy: unix.SCHED_IDLE}
+	return forEachThread(func(tid int) error {
+		return schedSetAttr(tid, attr, 0)
+	})
+}
+
+// forEachThread calls fn for all threads of the process and returns the first error
+func forEachThread(fn func(tid int) error) error {
+	tids, err := threadIDs()
+	if err != nil {
+		return err
+	}
+	for _, tid := range tids {
+		if err := fn(tid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func listThreads() ([]int, error) {
+	entries, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return nil, err
+	}
+	tids := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		if tid, err := strconv.Atoi(entry.Name()); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids, nil
+}
@@ -0,0 +1,91 @@
+package priority
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+type call struct {
+	which, who, prio int
+}
+
+// mockSyscalls replaces the syscalls by recorders, threads of the process are 100 and 101
+func mockSyscalls(t *testing.T, priorityErr, schedErr error) (*[]call, *[]int) {
+	t.Helper()
+	origThreads, origPriority, origSched := threadIDs, setpriority, schedSetAttr
+	t.Cleanup(func() {
+		threadIDs, setpriority, schedSetAttr = origThreads, origPriority, origSched
+	})
+
+	priorityCalls := make([]call, 0)
+	schedCalls := make([]int, 0)
+	threadIDs = func() ([]int, error) { return []int{100, 101}, nil }
+	setpriority = func(which, who, prio int) error {
+		priorityCalls = append(priorityCalls, call{which, who, prio})
+		return priorityErr
+	}
+	schedSetAttr = func(pid int, attr *unix.SchedAttr, flags uint) error {
+		assert.Equal(t, uint32(unix.SCHED_IDLE), attr.Policy)
+		assert.Equal(t, uint32(unix.SizeofSchedAttr), attr.Size)
+		assert.Equal(t, uint(0), flags)
+		schedCalls = append(schedCalls, pid)
+		return schedErr
+	}
+	return &priorityCalls, &schedCalls
+}
+
+func TestLower(t *testing.T) {
+	priorityCalls, schedCalls := mockSyscalls(t, nil, nil)
+
+	applied, err := Lower(10, true)
+	assert.NoError(t, err)
+	assert.Equal(t, Applied{Nice: 10, SchedIdle: true}, applied)
+	assert.Equal(t, []call{
+		{unix.PRIO_PROCESS, 100, 10},
+		{unix.PRIO_PROCESS, 101, 10},
+	}, *priorityCalls)
+	assert.Equal(t, []int{100, 101}, *schedCalls)
+}
+
+func TestLowerOnlyNice(t *testing.T) {
+	priorityCalls, schedCalls := mockSyscalls(t, nil, nil)
+
+	applied, err := Lower(5, false)
+	assert.NoError(t, err)
+	assert.Equal(t, Applied{Nice: 5}, applied)
+	assert.Len(t, *priorityCalls, 2)
+	assert.Empty(t, *schedCalls)
+}
+
+func TestLowerNotPermitted(t *testing.T) {
+	_, schedCalls := mockSyscalls(t, unix.EPERM, nil)
+
+	applied, err := Lower(10, true)
+	assert.ErrorIs(t, err, unix.EPERM)
+	assert.ErrorContains(t, err, "setting niceness to 10")
+	assert.Equal(t, Applied{SchedIdle: true}, applied, "Policy is set even if niceness is not")
+	assert.Equal(t, []int{100, 101}, *schedCalls)
+
+	mockSyscalls(t, nil, unix.EPERM)
+	applied, err = Lower(10, true)
+	assert.ErrorContains(t, err, "setting SCHED_IDLE policy")
+	assert.Equal(t, Applied{Nice: 10}, applied)
+}
+
+func TestLowerThreadsNotListed(t *testing.T) {
+	mockSyscalls(t, nil, nil)
+	threadIDs = func() ([]int, error) { return nil, errors.New("no proc") }
+
+	applied, err := Lower(10, false)
+	assert.ErrorContains(t, err, "no proc")
+	assert.Equal(t, Applied{}, applied)
+}
+
+func TestListThreads(t *testing.T) {
+	tids, err := listThreads()
+	assert.NoError(t, err)
+	assert.Contains(t, tids, unix.Getpid())
+}
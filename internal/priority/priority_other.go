@@ -0,0 +1,14 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package priority
+
+import "errors"
+
+func setNice(_ int) error {
+	return errors.New("not supported on this platform")
+}
+
+func setSchedIdle() error {
+	return errors.New("supported only on Linux")
+}
@@ -0,0 +1,20 @@
+package priority
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppliedString(t *testing.T) {
+	assert.Equal(t, "default", Applied{}.String())
+	assert.Equal(t, "nice 10", Applied{Nice: 10}.String())
+	assert.Equal(t, "SCHED_IDLE", Applied{SchedIdle: true}.String())
+	assert.Equal(t, "nice 19, SCHED_IDLE", Applied{Nice: 19, SchedIdle: true}.String())
+}
+
+func TestLowerNothing(t *testing.T) {
+	applied, err := Lower(0, false)
+	assert.NoError(t, err)
+	assert.Equal(t, Applied{}, applied)
+}
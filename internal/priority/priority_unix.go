@@ -0,0 +1,19 @@
+//go:build darwin || freebsd || netbsd || openbsd
+// +build darwin freebsd netbsd openbsd
+
+package priority
+
+import (
+	"errors"
+	"syscall"
+)
+
+var setpriority = syscall.Setpriority
+
+func setNice(nice int) error {
+	return setpriority(syscall.PRIO_PROCESS, 0, nice)
+}
+
+func setSchedIdle() error {
+	return errors.New("supported only on Linux")
+}
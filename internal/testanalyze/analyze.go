@@ -66,9 +66,11 @@ func (a *MockedAnalyzer) AnalyzeDir(
 	return dir
 }
 
-// GetProgressChan returns always Done
+// GetProgressChan returns always closed channel
 func (a *MockedAnalyzer) GetProgressChan() chan common.CurrentProgress {
-	return make(chan common.CurrentProgress)
+	c := make(chan common.CurrentProgress)
+	close(c)
+	return c
 }
 
 // GetDone returns always Done
@@ -0,0 +1,104 @@
+package testdir
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TreeSpec describes the tree built by CreateSyntheticTree
+type TreeSpec struct {
+	Root        string // path of the created root directory, "test_dir" if empty
+	Depth       int    // levels of directories below the root
+	Breadth     int    // subdirectories of every directory above the last level
+	FilesPerDir int    // files in every directory including the root
+
+	// FileSize is the size of every file in bytes. If SizeJitter is set,
+	// sizes are picked from FileSize ± SizeJitter by a generator seeded with Seed,
+	// so the same spec always gives the same tree
+	FileSize   int
+	SizeJitter int
+	Seed       int64
+
+	// Mtime is set as access and modification time of all files and directories if not zero.
+	// Backdated trees let tests detect changes without sleeping over the mtime granularity
+	Mtime time.Time
+}
+
+// SyntheticTree lists what was created by CreateSyntheticTree
+type SyntheticTree struct {
+	Root  string
+	Dirs  []string // all directories including the root, parents before children
+	Files []string
+	Size  int64 // total size of the files
+}
+
+// CreateSyntheticTree creates directory tree by spec. Directories are named dir0, dir1, …
+// and files file0, file1, … on every level. It panics on error like CreateTestDir.
+// Returned function removes the tree
+func CreateSyntheticTree(spec TreeSpec) (*SyntheticTree, func()) {
+	if spec.Root == "" {
+		spec.Root = "test_dir"
+	}
+	tree := &SyntheticTree{Root: spec.Root}
+	random := rand.New(rand.NewSource(spec.Seed))
+
+	level := []string{spec.Root}
+	for depth := 0; depth <= spec.Depth; depth++ {
+		next := make([]string, 0, len(level)*spec.Breadth)
+		for _, dir := range level {
+			if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+				panic(err)
+			}
+			tree.Dirs = append(tree.Dirs, dir)
+
+			for i := 0; i < spec.FilesPerDir; i++ {
+				size := spec.FileSize
+				if spec.SizeJitter > 0 {
+					size += random.Intn(2*spec.SizeJitter+1) - spec.SizeJitter
+				}
+				size = max(size, 0)
+				path := filepath.Join(dir, fmt.Sprintf("file%d", i))
+				if err := os.WriteFile(path, make([]byte, size), 0o600); err != nil {
+					panic(err)
+				}
+				tree.Files = append(tree.Files, path)
+				tree.Size += int64(size)
+			}
+
+			if depth < spec.Depth {
+				for i := 0; i < spec.Breadth; i++ {
+					next = append(next, filepath.Join(dir, fmt.Sprintf("dir%d", i)))
+				}
+			}
+		}
+		level = next
+	}
+
+	if !spec.Mtime.IsZero() {
+		tree.Backdate(spec.Mtime)
+	}
+
+	return tree, func() {
+		if err := os.RemoveAll(spec.Root); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Backdate sets access and modification time of all files and directories of the tree to mtime.
+// Directories are changed after their content, so creating the content doesn't touch them anymore
+func (t *SyntheticTree) Backdate(mtime time.Time) {
+	for _, path := range t.Files {
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			panic(err)
+		}
+	}
+	for i := len(t.Dirs) - 1; i >= 0; i-- {
+		if err := os.Chtimes(t.Dirs[i], mtime, mtime); err != nil {
+			panic(err)
+		}
+	}
+}
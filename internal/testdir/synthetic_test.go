@@ -0,0 +1,50 @@
+package testdir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateSyntheticTree(t *testing.T) {
+	spec := TreeSpec{
+		Root:        filepath.Join(t.TempDir(), "tree"),
+		Depth:       2,
+		Breadth:     3,
+		FilesPerDir: 2,
+		FileSize:    100,
+		SizeJitter:  50,
+		Seed:        7,
+		Mtime:       time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	tree, cleanup := CreateSyntheticTree(spec)
+
+	assert.Len(t, tree.Dirs, 1+3+9)
+	assert.Len(t, tree.Files, 2*13)
+	assert.Equal(t, spec.Root, tree.Dirs[0])
+	assert.DirExists(t, filepath.Join(spec.Root, "dir2", "dir1"))
+
+	var size int64
+	for _, path := range append(tree.Files, tree.Dirs...) {
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.True(t, info.ModTime().Equal(spec.Mtime), path)
+		if !info.IsDir() {
+			assert.InDelta(t, 100, info.Size(), 50)
+			size += info.Size()
+		}
+	}
+	assert.Equal(t, tree.Size, size)
+
+	// the same seed gives the same sizes
+	spec.Root = filepath.Join(t.TempDir(), "tree")
+	again, cleanupAgain := CreateSyntheticTree(spec)
+	assert.Equal(t, tree.Size, again.Size)
+
+	cleanup()
+	cleanupAgain()
+	assert.NoDirExists(t, tree.Root)
+}
@@ -0,0 +1,122 @@
+package analyze
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+const (
+	day  = 24 * time.Hour
+	year = 365 * day
+)
+
+// DefaultAgeBands are the upper bounds of age buckets used by default
+var DefaultAgeBands = []time.Duration{30 * day, 90 * day, year, 2 * year, 5 * year}
+
+// AgeBucket holds files of age in the [MinAge, MaxAge) range
+type AgeBucket struct {
+	MinAge time.Duration
+	MaxAge time.Duration // zero for the last, unbounded bucket
+	Count  int
+	Size   int64
+	Usage  int64
+}
+
+// Label returns human readable age range of the bucket
+func (b AgeBucket) Label() string {
+	switch {
+	case b.MaxAge == 0:
+		return "> " + formatAge(b.MinAge)
+	case b.MinAge == 0:
+		return "< " + formatAge(b.MaxAge)
+	default:
+		return formatAge(b.MinAge) + " - " + formatAge(b.MaxAge)
+	}
+}
+
+// AgeHistogram is distribution of files by the age of their mtime
+type AgeHistogram struct {
+	Now        time.Time
+	Buckets    []AgeBucket
+	TotalCount int
+	TotalSize  int64
+	TotalUsage int64
+}
+
+// ComputeAgeHistogram buckets files of the subtree by age of their mtime relative to now.
+// Bands are ascending upper bounds of buckets, one more bucket is added for older files.
+// Directories are not counted, hard-linked files are counted once
+// and files with mtime in the future fall into the first bucket.
+// Symlinks are counted as they were scanned, i.e. as the target if symlinks were followed
+func ComputeAgeHistogram(
+	ctx context.Context, item fs.Item, now time.Time, bands []time.Duration,
+) (*AgeHistogram, error) {
+	hist := &AgeHistogram{
+		Now:     now,
+		Buckets: make([]AgeBucket, len(bands)+1),
+	}
+	var prev time.Duration
+	for i, band := range bands {
+		hist.Buckets[i].MinAge = prev
+		hist.Buckets[i].MaxAge = band
+		prev = band
+	}
+	hist.Buckets[len(bands)].MinAge = prev
+
+	linked := make(map[uint64]struct{})
+
+	err := Walk(ctx, item, func(_ string, it fs.Item, _ int) error {
+		if it.IsDir() {
+			return nil
+		}
+		if mli := it.GetMultiLinkedInode(); mli > 0 {
+			if _, ok := linked[mli]; ok {
+				return nil
+			}
+			linked[mli] = struct{}{}
+		}
+
+		bucket := &hist.Buckets[hist.bucketIndex(now.Sub(it.GetMtime()))]
+		bucket.Count++
+		bucket.Size += it.GetSize()
+		bucket.Usage += it.GetUsage()
+
+		hist.TotalCount++
+		hist.TotalSize += it.GetSize()
+		hist.TotalUsage += it.GetUsage()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hist, nil
+}
+
+func (h *AgeHistogram) bucketIndex(age time.Duration) int {
+	for i, bucket := range h.Buckets[:len(h.Buckets)-1] {
+		if age < bucket.MaxAge {
+			return i
+		}
+	}
+	return len(h.Buckets) - 1
+}
+
+func formatAge(age time.Duration) string {
+	switch {
+	case age%year == 0:
+		if age == year {
+			return "1 year"
+		}
+		return fmt.Sprintf("%d years", age/year)
+	case age%day == 0:
+		if age == day {
+			return "1 day"
+		}
+		return fmt.Sprintf("%d days", age/day)
+	default:
+		return age.String()
+	}
+}
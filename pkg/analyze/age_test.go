@@ -0,0 +1,126 @@
+package analyze
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func createAgedFiles(t *testing.T, now time.Time) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "archive")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "old"), 0o755))
+
+	files := []struct {
+		path string
+		size int
+		age  time.Duration
+	}{
+		{"fresh", 1, 10 * day},
+		{"month", 2, 60 * day},
+		{"old/year", 4, 400 * day},
+		{"old/two", 8, 800 * day},
+		{"old/ancient", 16, 6 * year},
+		{"future", 32, -day},
+	}
+	for _, f := range files {
+		path := filepath.Join(root, f.path)
+		assert.NoError(t, os.WriteFile(path, make([]byte, f.size), 0o600))
+		mtime := now.Add(-f.age)
+		assert.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+
+	// directories are not counted whatever their mtime is
+	oldDir := now.Add(-10 * year)
+	assert.NoError(t, os.Chtimes(filepath.Join(root, "old"), oldDir, oldDir))
+	return root
+}
+
+func TestComputeAgeHistogram(t *testing.T) {
+	now := time.Now()
+	root := createAgedFiles(t, now)
+
+	incremental := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	incrementalDir := incremental.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	incremental.GetDone().Wait()
+
+	// warm scan takes mtimes from the cache
+	warm := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: incremental.storagePath})
+	warmDir := warm.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	warm.GetDone().Wait()
+
+	sequential := CreateSeqAnalyzer()
+	sequentialDir := sequential.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	sequential.GetDone().Wait()
+
+	for name, dir := range map[string]fs.Item{
+		"incremental":        incrementalDir,
+		"incremental cached": warmDir,
+		"sequential":         sequentialDir,
+	} {
+		t.Run(name, func(t *testing.T) {
+			hist, err := ComputeAgeHistogram(context.Background(), dir, now, DefaultAgeBands)
+			assert.NoError(t, err)
+
+			assert.Equal(t, 6, len(hist.Buckets))
+			sizes := make([]int64, 0, len(hist.Buckets))
+			for _, bucket := range hist.Buckets {
+				sizes = append(sizes, bucket.Size)
+				assert.LessOrEqual(t, bucket.Count, 2)
+			}
+			assert.Equal(t, []int64{33, 2, 0, 4, 8, 16}, sizes)
+			assert.Equal(t, 6, hist.TotalCount)
+			assert.Equal(t, int64(63), hist.TotalSize)
+		})
+	}
+}
+
+func TestComputeAgeHistogramSubtree(t *testing.T) {
+	now := time.Now()
+	root := createAgedFiles(t, now)
+
+	dir := CreateSeqAnalyzer().AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	old := childByName(dir, "old")
+
+	hist, err := ComputeAgeHistogram(context.Background(), old, now, []time.Duration{year})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, len(hist.Buckets))
+	assert.Equal(t, 0, hist.Buckets[0].Count)
+	assert.Equal(t, 3, hist.Buckets[1].Count)
+	assert.Equal(t, int64(28), hist.Buckets[1].Size)
+}
+
+func TestComputeAgeHistogramHardlinks(t *testing.T) {
+	now := time.Now()
+	dir := &Dir{File: &File{Name: "root"}}
+	dir.Files = fs.Files{
+		&File{Name: "a", Size: 10, Mli: 5, Flag: 'H', Mtime: now, Parent: dir},
+		&File{Name: "b", Size: 10, Mli: 5, Flag: 'H', Mtime: now, Parent: dir},
+	}
+
+	hist, err := ComputeAgeHistogram(context.Background(), dir, now, DefaultAgeBands)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, hist.TotalCount)
+	assert.Equal(t, int64(10), hist.Buckets[0].Size)
+}
+
+func TestComputeAgeHistogramCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := ComputeAgeHistogram(ctx, &Dir{File: &File{Name: "root"}}, time.Now(), DefaultAgeBands)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestAgeBucketLabel(t *testing.T) {
+	assert.Equal(t, "< 30 days", AgeBucket{MaxAge: 30 * day}.Label())
+	assert.Equal(t, "90 days - 1 year", AgeBucket{MinAge: 90 * day, MaxAge: year}.Label())
+	assert.Equal(t, "> 5 years", AgeBucket{MinAge: 5 * year}.Label())
+	assert.Equal(t, "< 1h0m0s", AgeBucket{MaxAge: time.Hour}.Label())
+}
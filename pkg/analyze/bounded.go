@@ -0,0 +1,62 @@
+package analyze
+
+// DefaultMaxReportedPaths is the number of paths kept in the path lists of CacheStats
+// and in FsckResult.Problems when IncrementalOptions.MaxReportedPaths is not set
+const DefaultMaxReportedPaths = 1000
+
+// BoundedList collects items up to a limit, further items are only counted as dropped,
+// so reports collected during a scan can't exhaust memory on pathological trees.
+// It is not safe for concurrent use, owners guard it by their own locks
+type BoundedList[T any] struct {
+	limit   int
+	items   []T
+	dropped int64
+}
+
+// NewBoundedList creates a list keeping at most limit items, limit <= 0 means no limit
+func NewBoundedList[T any](limit int) *BoundedList[T] {
+	return &BoundedList[T]{limit: limit, items: make([]T, 0)}
+}
+
+// Add appends the item or counts it as dropped if the list is full.
+// It returns false if the item was dropped
+func (l *BoundedList[T]) Add(item T) bool {
+	var added bool
+	l.items, added = appendBounded(l.items, item, l.limit)
+	if !added {
+		l.dropped++
+	}
+	return added
+}
+
+// Items returns copy of the kept items in the order they were added
+func (l *BoundedList[T]) Items() []T {
+	items := make([]T, len(l.items))
+	copy(items, l.items)
+	return items
+}
+
+// Len returns number of the kept items
+func (l *BoundedList[T]) Len() int {
+	return len(l.items)
+}
+
+// Dropped returns number of the items which did not fit into the limit
+func (l *BoundedList[T]) Dropped() int64 {
+	return l.dropped
+}
+
+// Total returns number of all added items, kept or dropped
+func (l *BoundedList[T]) Total() int64 {
+	return int64(len(l.items)) + l.dropped
+}
+
+// appendBounded appends item to items unless they hold limit items already (limit <= 0 means no limit).
+// It is used by collections which keep the total count on their own.
+// It returns false if the item was not appended
+func appendBounded[T any](items []T, item T, limit int) ([]T, bool) {
+	if limit > 0 && len(items) >= limit {
+		return items, false
+	}
+	return append(items, item), true
+}
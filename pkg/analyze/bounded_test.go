@@ -0,0 +1,79 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoundedList(t *testing.T) {
+	list := NewBoundedList[int](3)
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, i < 3, list.Add(i))
+	}
+
+	assert.Equal(t, []int{0, 1, 2}, list.Items())
+	assert.Equal(t, 3, list.Len())
+	assert.Equal(t, int64(97), list.Dropped())
+	assert.Equal(t, int64(100), list.Total())
+
+	// the returned items are a copy
+	list.Items()[0] = 42
+	assert.Equal(t, 0, list.Items()[0])
+}
+
+func TestBoundedListUnlimited(t *testing.T) {
+	list := NewBoundedList[string](0)
+	for i := 0; i < 100; i++ {
+		list.Add("x")
+	}
+	assert.Equal(t, 100, list.Len())
+	assert.Equal(t, int64(0), list.Dropped())
+}
+
+func TestCacheStatsPathLimit(t *testing.T) {
+	stats := newCacheStats(2)
+	for _, path := range []string{"/a", "/b", "/c", "/d"} {
+		stats.AddNewDir(path)
+		stats.AddRemovedDir(path)
+	}
+
+	assert.Equal(t, []string{"/a", "/b"}, stats.NewDirs)
+	assert.Equal(t, int64(4), stats.NewDirsCount)
+	assert.Equal(t, int64(2), stats.NewDirsDropped())
+	assert.Equal(t, []string{"/a", "/b"}, stats.RemovedDirs)
+	assert.Equal(t, int64(2), stats.RemovedDirsDropped())
+
+	// the limit is kept by snapshots
+	snapshot := stats.Snapshot()
+	snapshot.AddNewDir("/e")
+	assert.Len(t, snapshot.NewDirs, 2)
+	assert.Equal(t, int64(3), snapshot.NewDirsDropped())
+
+	assert.Equal(t, DefaultMaxReportedPaths, NewCacheStats().pathLimit)
+}
+
+func TestCombineCacheStats(t *testing.T) {
+	first := newCacheStats(2)
+	first.IncrementCacheHits()
+	first.AddNewDir("/a/x")
+	first.AddNewDir("/a/y")
+	first.SetSummaryHit()
+	first.SetPeakHeap(100)
+	first.Storage.Reads = OperationMetrics{Count: 2, Total: 4, Max: 3}
+
+	second := newCacheStats(2)
+	second.IncrementCacheMisses()
+	second.AddNewDir("/b/z")
+	second.SetPeakHeap(50)
+	second.Storage.Reads = OperationMetrics{Count: 1, Total: 5, Max: 5}
+
+	combined := CombineCacheStats(first, second)
+	assert.Equal(t, int64(1), combined.CacheHits)
+	assert.Equal(t, int64(1), combined.CacheMisses)
+	assert.Equal(t, []string{"/a/x", "/a/y"}, combined.NewDirs)
+	assert.Equal(t, int64(1), combined.NewDirsDropped())
+	assert.False(t, combined.SummaryHit)
+	assert.Equal(t, uint64(100), combined.PeakHeap)
+	assert.Equal(t, OperationMetrics{Count: 3, Total: 9, Max: 5}, combined.Storage.Reads)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package analyze
+
+import "time"
+
+// birthTime is not supported on this platform
+func birthTime(path string) time.Time {
+	return time.Time{}
+}
@@ -0,0 +1,206 @@
+package analyze
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dundee/gdu/v5/internal/common"
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// Fields of TreeMismatch
+const (
+	MismatchSize       = "size"
+	MismatchUsage      = "usage"
+	MismatchItems      = "items"
+	MismatchFlag       = "flag"
+	MismatchKind       = "kind"       // directory in one tree, file in the other
+	MismatchMissing    = "missing"    // present only in the reference tree
+	MismatchUnexpected = "unexpected" // present only in the compared tree
+)
+
+// TreeMismatch is a difference of one item between the reference tree and the compared one
+type TreeMismatch struct {
+	Path      string
+	Field     string // one of the Mismatch* constants
+	Reference string // value in the reference tree
+	Actual    string // value in the compared tree
+}
+
+func (m TreeMismatch) String() string {
+	switch m.Field {
+	case MismatchMissing:
+		return fmt.Sprintf("%s: missing", m.Path)
+	case MismatchUnexpected:
+		return fmt.Sprintf("%s: unexpected", m.Path)
+	}
+	return fmt.Sprintf("%s: %s %s, reference %s", m.Path, m.Field, m.Actual, m.Reference)
+}
+
+// CompareOptions selects the differences reported by CompareTrees
+type CompareOptions struct {
+	// IgnoreEstimated drops differences explained by the estimation mode
+	// (see IncrementalOptions.SampleThreshold), the estimated directories are not compared
+	IgnoreEstimated bool
+
+	// Ignore drops further known differences, e.g. of files changed between the scans
+	Ignore func(TreeMismatch) bool
+}
+
+// TreeComparison is the result of CompareTrees
+type TreeComparison struct {
+	Compared   int            // number of items found in both trees
+	Mismatches []TreeMismatch // in path order (bounded by DefaultMaxReportedPaths)
+	Total      int            // number of reported mismatches
+	Ignored    int            // number of mismatches dropped as known differences
+}
+
+// MismatchesDropped returns number of mismatches which did not fit into Mismatches
+func (c *TreeComparison) MismatchesDropped() int {
+	return c.Total - len(c.Mismatches)
+}
+
+// CompareTrees compares the tree with the reference one item by item.
+// Sizes, disk usage, item counts and flags are compared, children are matched by name.
+// A difference is reported only at the item causing it, not at its ancestors.
+// Stats of both trees must be already updated (see fs.Item.UpdateStats)
+func CompareTrees(reference, actual fs.Item, opts CompareOptions) *TreeComparison {
+	c := &treeComparer{opts: opts, result: &TreeComparison{}}
+	c.compare(reference.GetPath(), reference, actual)
+	return c.result
+}
+
+type treeComparer struct {
+	opts   CompareOptions
+	result *TreeComparison
+}
+
+func (c *treeComparer) report(path, field string, reference, actual any) {
+	m := TreeMismatch{
+		Path:      path,
+		Field:     field,
+		Reference: fmt.Sprint(reference),
+		Actual:    fmt.Sprint(actual),
+	}
+	if c.opts.Ignore != nil && c.opts.Ignore(m) {
+		c.result.Ignored++
+		return
+	}
+	c.result.Total++
+	c.result.Mismatches, _ = appendBounded(c.result.Mismatches, m, DefaultMaxReportedPaths)
+}
+
+func (c *treeComparer) compare(path string, reference, actual fs.Item) {
+	c.result.Compared++
+	if reference.IsDir() != actual.IsDir() {
+		c.report(path, MismatchKind, kindOf(reference), kindOf(actual))
+		return
+	}
+	if c.opts.IgnoreEstimated && actual.GetFlag() == '~' {
+		return
+	}
+
+	// Totals of directories are compared without their children, so a difference
+	// is reported only at the item causing it and not at all its ancestors
+	refSize, refUsage, refItems := ownTotals(reference)
+	size, usage, items := ownTotals(actual)
+	if refSize != size {
+		c.report(path, MismatchSize, reference.GetSize(), actual.GetSize())
+	}
+	if refUsage != usage {
+		c.report(path, MismatchUsage, reference.GetUsage(), actual.GetUsage())
+	}
+	if refItems != items {
+		c.report(path, MismatchItems, reference.GetItemCount(), actual.GetItemCount())
+	}
+	if reference.GetFlag() != actual.GetFlag() {
+		c.report(path, MismatchFlag, string(reference.GetFlag()), string(actual.GetFlag()))
+	}
+
+	if !reference.IsDir() {
+		return
+	}
+
+	referenceChildren := make(map[string]fs.Item)
+	names := make([]string, 0)
+	for _, child := range reference.GetFiles() {
+		referenceChildren[child.GetName()] = child
+		names = append(names, child.GetName())
+	}
+	sort.Strings(names)
+	actualChildren := make(map[string]fs.Item)
+	for _, child := range actual.GetFiles() {
+		actualChildren[child.GetName()] = child
+	}
+
+	for _, name := range names {
+		childPath := joinPath(path, name)
+		child, ok := actualChildren[name]
+		if !ok {
+			c.report(childPath, MismatchMissing, kindOf(referenceChildren[name]), "")
+			continue
+		}
+		delete(actualChildren, name)
+		c.compare(childPath, referenceChildren[name], child)
+	}
+
+	unexpected := make([]string, 0, len(actualChildren))
+	for name := range actualChildren {
+		unexpected = append(unexpected, name)
+	}
+	sort.Strings(unexpected)
+	for _, name := range unexpected {
+		c.report(joinPath(path, name), MismatchUnexpected, "", kindOf(actualChildren[name]))
+	}
+}
+
+// ownTotals returns totals of the item without the totals of its children
+func ownTotals(item fs.Item) (size, usage int64, items int) {
+	size, usage, items = item.GetSize(), item.GetUsage(), item.GetItemCount()
+	for _, child := range item.GetFiles() {
+		size -= child.GetSize()
+		usage -= child.GetUsage()
+		items -= child.GetItemCount()
+	}
+	return size, usage, items
+}
+
+func kindOf(item fs.Item) string {
+	if item.IsDir() {
+		return "directory"
+	}
+	return "file"
+}
+
+// SelfCheck scans path by the incremental analyzer with opts and by the sequential analyzer
+// and compares the trees, the sequential one being the reference.
+// The cache directory located in the tree is left out of both scans (unless opts.CountCacheDir is set).
+// Files changed between the scans are reported as mismatches too
+func SelfCheck(
+	path string, opts IncrementalOptions, ignore common.ShouldDirBeIgnored, cmp CompareOptions,
+) (*TreeComparison, error) {
+	if ignore == nil {
+		ignore = func(_, _ string) bool { return false }
+	}
+
+	incremental := CreateIncrementalAnalyzer(opts)
+	actual := incremental.AnalyzeDir(path, ignore, false)
+	incremental.GetDone().Wait()
+	if result := incremental.GetScanResult(); result != nil && result.Status == ScanFailed {
+		return nil, fmt.Errorf("incremental scan of %s failed: %w", path, result.Err)
+	}
+	actual.UpdateStats(make(fs.HardLinkedItems))
+
+	referenceIgnore := ignore
+	if cacheDir := cacheDirInTree(path, opts.StoragePath); cacheDir != "" && !opts.CountCacheDir {
+		referenceIgnore = func(name, entryPath string) bool {
+			return entryPath == cacheDir || ignore(name, entryPath)
+		}
+	}
+	sequential := CreateSeqAnalyzer()
+	reference := sequential.AnalyzeDir(path, referenceIgnore, false)
+	sequential.GetDone().Wait()
+	reference.UpdateStats(make(fs.HardLinkedItems))
+
+	return CompareTrees(reference, actual, cmp), nil
+}
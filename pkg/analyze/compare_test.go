@@ -0,0 +1,112 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createCompareFixture(t *testing.T) string {
+	t.Helper()
+	root := createTraceFixture(t)
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a", "b", "data"), make([]byte, 10000), 0o600))
+	assert.NoError(t, os.Link(filepath.Join(root, "a", "b", "data"), filepath.Join(root, "c", "link")))
+	assert.NoError(t, os.Symlink("a/file", filepath.Join(root, "symlink")))
+	return root
+}
+
+func TestSelfCheck(t *testing.T) {
+	root := createCompareFixture(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	for _, scan := range []string{"cold", "warm"} {
+		result, err := SelfCheck(root, opts, nil, CompareOptions{})
+		assert.NoError(t, err)
+		assert.Empty(t, result.Mismatches, "%s scan", scan)
+		assert.Equal(t, 0, result.Total)
+		assert.Equal(t, 8, result.Compared)
+	}
+}
+
+func TestSelfCheck_InjectedDiscrepancy(t *testing.T) {
+	root := createCompareFixture(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+	_, err := SelfCheck(root, opts, nil, CompareOptions{})
+	assert.NoError(t, err)
+
+	// the cache entry of a/b lies about the size of its file, its mtime is not changed
+	dirPath := filepath.Join(root, "a", "b")
+	storage := NewIncrementalStorage(opts.StoragePath, root)
+	closeFn := mustOpen(t, storage)
+	meta, err := storage.LoadDirMetadata(dirPath)
+	assert.NoError(t, err)
+	for i := range meta.Files {
+		if meta.Files[i].Name == "data" {
+			meta.Files[i].Size = 12345
+		}
+	}
+	assert.NoError(t, storage.StoreDirMetadata(meta))
+	closeFn()
+
+	result, err := SelfCheck(root, opts, nil, CompareOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Total)
+	assert.Equal(t, []TreeMismatch{
+		{Path: filepath.Join(dirPath, "data"), Field: MismatchSize, Reference: "10000", Actual: "12345"},
+	}, result.Mismatches)
+	assert.Equal(t, filepath.Join(dirPath, "data")+": size 12345, reference 10000", result.Mismatches[0].String())
+
+	// the known difference can be filtered out
+	result, err = SelfCheck(root, opts, nil, CompareOptions{
+		Ignore: func(m TreeMismatch) bool { return m.Path == filepath.Join(dirPath, "data") },
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Total)
+	assert.Equal(t, 1, result.Ignored)
+}
+
+func TestCompareTrees_MissingAndUnexpected(t *testing.T) {
+	reference := &Dir{File: &File{Name: "root", Flag: ' '}, BasePath: "/"}
+	reference.AddFile(&File{Name: "a", Size: 1, Flag: ' ', Parent: reference})
+	reference.AddFile(&File{Name: "b", Size: 1, Flag: ' ', Parent: reference})
+	reference.AddFile(&Dir{File: &File{Name: "c", Flag: ' ', Parent: reference}})
+	reference.UpdateStats(nil)
+
+	actual := &Dir{File: &File{Name: "root", Flag: ' '}, BasePath: "/"}
+	actual.AddFile(&File{Name: "a", Size: 1, Flag: ' ', Parent: actual})
+	actual.AddFile(&File{Name: "c", Flag: ' ', Parent: actual})
+	actual.AddFile(&File{Name: "d", Size: 1, Flag: ' ', Parent: actual})
+	actual.UpdateStats(nil)
+
+	result := CompareTrees(reference, actual, CompareOptions{})
+	assert.Equal(t, []TreeMismatch{
+		{Path: "/root/b", Field: MismatchMissing, Reference: "file"},
+		{Path: "/root/c", Field: MismatchKind, Reference: "directory", Actual: "file"},
+		{Path: "/root/d", Field: MismatchUnexpected, Actual: "file"},
+	}, result.Mismatches)
+	assert.Equal(t, 3, result.Total)
+	assert.Equal(t, 3, result.Compared)
+}
+
+func TestCompareTrees_IgnoreEstimated(t *testing.T) {
+	reference := &Dir{File: &File{Name: "root", Flag: ' '}, BasePath: "/"}
+	refSampled := &Dir{File: &File{Name: "sampled", Flag: ' ', Parent: reference}}
+	refSampled.AddFile(&File{Name: "f", Size: 10, Flag: ' ', Parent: refSampled})
+	reference.AddFile(refSampled)
+	reference.UpdateStats(nil)
+
+	actual := &Dir{File: &File{Name: "root", Flag: ' '}, BasePath: "/"}
+	sampled := &Dir{
+		File:              &File{Name: "sampled", Flag: '~', Parent: actual},
+		Estimate:          &Estimate{Sampled: 1, Size: 12, Usage: 12},
+		EstimatedDirCount: 1,
+	}
+	actual.AddFile(sampled)
+	actual.UpdateStats(nil)
+
+	assert.NotZero(t, CompareTrees(reference, actual, CompareOptions{}).Total)
+	result := CompareTrees(reference, actual, CompareOptions{IgnoreEstimated: true})
+	assert.Zero(t, result.Total, "%v", result.Mismatches)
+}
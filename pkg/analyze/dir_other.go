@@ -21,3 +21,12 @@ func setDirPlatformSpecificAttrs(dir *Dir, path string) {
 	}
 	dir.Mtime = stat.ModTime()
 }
+
+func getDirIdentity(info os.FileInfo) (dirIdentity, bool) {
+	return dirIdentity{}, false
+}
+
+// changeTime is not supported on this platform, ctime is not compared
+func changeTime(_ os.FileInfo) time.Time {
+	return time.Time{}
+}
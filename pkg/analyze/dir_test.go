@@ -133,6 +133,34 @@ func TestHardlink(t *testing.T) {
 	assert.Equal(t, 'H', dir.Files[0].(*Dir).Files[1].GetFlag())
 }
 
+func TestHardlinksSaved(t *testing.T) {
+	root := &Dir{File: &File{Name: "root"}}
+	a := &Dir{File: &File{Name: "a", Parent: root}}
+	b := &Dir{File: &File{Name: "b", Parent: root}}
+	a.Files = fs.Files{&File{Name: "f1", Usage: 8192, Size: 8000, Mli: 1, Parent: a}}
+	b.Files = fs.Files{
+		&File{Name: "f2", Usage: 8192, Size: 8000, Mli: 1, Parent: b},
+		&File{Name: "f3", Usage: 4096, Size: 100, Mli: 2, Parent: b},
+	}
+	root.Files = fs.Files{
+		a, b,
+		&File{Name: "f4", Usage: 4096, Size: 100, Mli: 2, Parent: root},
+		&File{Name: "f5", Usage: 4096, Size: 100, Mli: 2, Parent: root},
+		&File{Name: "f6", Usage: 4096, Size: 100, Parent: root},
+	}
+
+	root.UpdateStats(make(fs.HardLinkedItems))
+
+	assert.Equal(t, int64(8192+4096*2), root.GetHardlinksSaved())
+	assert.Zero(t, a.GetHardlinksSaved())
+	assert.Equal(t, int64(8192), b.GetHardlinksSaved()) // f1 was counted in a
+	assert.Equal(t, int64(3*4096+8192+4096+4096), root.GetUsage())
+
+	// recomputed, not accumulated
+	root.UpdateStats(make(fs.HardLinkedItems))
+	assert.Equal(t, int64(8192+4096*2), root.GetHardlinksSaved())
+}
+
 func TestFollowSymlink(t *testing.T) {
 	fin := testdir.CreateTestDir()
 	defer fin()
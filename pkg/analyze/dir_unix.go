@@ -20,6 +20,9 @@ func setPlatformSpecificAttrs(file *File, f os.FileInfo) {
 			file.Mli = stat.Ino
 		}
 	}
+	if uid, ok := ownerOf(f); ok {
+		file.SetOwner(uid)
+	}
 }
 
 func setDirPlatformSpecificAttrs(dir *Dir, path string) {
@@ -30,3 +33,20 @@ func setDirPlatformSpecificAttrs(dir *Dir, path string) {
 
 	dir.Mtime = time.Unix(int64(stat.Mtimespec.Sec), int64(stat.Mtimespec.Nsec))
 }
+
+func getDirIdentity(info os.FileInfo) (dirIdentity, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return dirIdentity{}, false
+	}
+	return dirIdentity{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}, true
+}
+
+// changeTime returns status change time (ctime) of the item, zero time if it is not known
+func changeTime(info os.FileInfo) time.Time {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}
+	}
+	return time.Unix(int64(stat.Ctimespec.Sec), int64(stat.Ctimespec.Nsec))
+}
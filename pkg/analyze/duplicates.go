@@ -0,0 +1,227 @@
+package analyze
+
+import (
+	"context"
+	"crypto/md5"  // nolint: gosec // Why: selected by the user, not used for security
+	"crypto/sha1" // nolint: gosec // Why: selected by the user, not used for security
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// duplicateHashes are the hash algorithms accepted by DuplicateOptions.Hash
+var duplicateHashes = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+}
+
+// CheckDuplicateHash returns error if the hash algorithm is not supported by FindDuplicates
+func CheckDuplicateHash(name string) error {
+	if _, ok := duplicateHashes[name]; !ok {
+		return fmt.Errorf("unknown hash algorithm %q (use sha256, sha512, sha1 or md5)", name)
+	}
+	return nil
+}
+
+// DuplicateOptions configures FindDuplicates
+type DuplicateOptions struct {
+	MinSize  int64       // smaller files are not compared
+	Hash     string      // hash algorithm: sha256, sha512, sha1 or md5
+	MaxFiles int         // maximum number of hashed files (0 = unlimited)
+	Throttle *IOThrottle // paces opening of the hashed files (nil = no throttling)
+}
+
+// DefaultDuplicateOptions compare files of at least 1 MiB by SHA-256 and hash at most 10000 of them
+var DefaultDuplicateOptions = DuplicateOptions{
+	MinSize:  1 << 20,
+	Hash:     "sha256",
+	MaxFiles: 10000,
+}
+
+// DuplicateSet is a group of distinct files with the same content
+type DuplicateSet struct {
+	Hash        string   `json:"hash"`
+	Size        int64    `json:"size"`        // apparent size of one copy
+	Usage       int64    `json:"usage"`       // disk usage of one copy
+	Paths       []string `json:"paths"`       // in path order
+	Reclaimable int64    `json:"reclaimable"` // disk usage of all copies but one
+}
+
+// LinkedSet is a group of hard links of one file, its copies already share the data
+type LinkedSet struct {
+	Size  int64    `json:"size"`
+	Usage int64    `json:"usage"`
+	Paths []string `json:"paths"` // in path order
+}
+
+// DuplicateReport is the result of FindDuplicates
+type DuplicateReport struct {
+	Sets        []DuplicateSet // ordered by reclaimable space
+	Linked      []LinkedSet    // ordered by disk usage
+	Reclaimable int64          // sum of reclaimable space of the sets
+	Candidates  int            // files having the same size as another file
+	Hashed      int            // number of hashed files
+	Skipped     int            // candidates not hashed because of MaxFiles
+	Errors      int            // candidates which could not be read
+}
+
+// FindDuplicates looks for files with the same content in the subtree.
+// Sizes recorded in the tree (collected by the scan or read from the cache) are used
+// as a pre-filter, only files of at least MinSize having the same size as another file are read
+// and hashed. The largest candidates are hashed first until MaxFiles is reached.
+// Hard links of one file (with the same multi-linked inode) are reported as linked sets
+// and not as duplicates. Unreadable files are counted as errors and skipped.
+// Cancellation of ctx stops the hashing and its error is returned
+func FindDuplicates(ctx context.Context, item fs.Item, opts DuplicateOptions) (*DuplicateReport, error) {
+	if opts.Hash == "" {
+		opts.Hash = DefaultDuplicateOptions.Hash
+	}
+	if err := CheckDuplicateHash(opts.Hash); err != nil {
+		return nil, err
+	}
+	newHash := duplicateHashes[opts.Hash]
+
+	type candidate struct {
+		path string
+		item fs.Item
+	}
+	var files []candidate
+	links := make(map[uint64][]string)
+
+	err := Walk(ctx, item, func(path string, it fs.Item, _ int) error {
+		if it.IsDir() || it.GetSize() < opts.MinSize {
+			return nil
+		}
+		// only regular files, not symlinks and files which could not be read
+		if flag := it.GetFlag(); flag != ' ' && flag != 'H' {
+			return nil
+		}
+		if mli := it.GetMultiLinkedInode(); mli > 0 {
+			links[mli] = append(links[mli], path)
+			if len(links[mli]) > 1 {
+				return nil
+			}
+		}
+		files = append(files, candidate{path: path, item: it})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DuplicateReport{}
+	bySize := make(map[int64][]candidate)
+	for _, f := range files {
+		if mli := f.item.GetMultiLinkedInode(); mli > 0 && len(links[mli]) > 1 {
+			paths := links[mli]
+			sort.Strings(paths)
+			report.Linked = append(report.Linked, LinkedSet{
+				Size:  f.item.GetSize(),
+				Usage: f.item.GetUsage(),
+				Paths: paths,
+			})
+		}
+		bySize[f.item.GetSize()] = append(bySize[f.item.GetSize()], f)
+	}
+
+	sizes := make([]int64, 0, len(bySize))
+	for size, group := range bySize {
+		if len(group) > 1 {
+			sizes = append(sizes, size)
+			report.Candidates += len(group)
+		}
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] > sizes[j] })
+
+	for _, size := range sizes {
+		group := bySize[size]
+		if opts.MaxFiles > 0 && report.Hashed+len(group) > opts.MaxFiles {
+			report.Skipped += len(group)
+			continue
+		}
+
+		byHash := make(map[string][]candidate)
+		for _, f := range group {
+			if err := opts.Throttle.Acquire(ctx); err != nil {
+				return nil, err
+			}
+			sum, err := hashFile(ctx, f.path, newHash)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				report.Errors++
+				continue
+			}
+			report.Hashed++
+			byHash[sum] = append(byHash[sum], f)
+		}
+
+		for sum, same := range byHash {
+			if len(same) < 2 {
+				continue
+			}
+			set := DuplicateSet{Hash: sum, Size: size, Usage: same[0].item.GetUsage()}
+			for _, f := range same {
+				set.Paths = append(set.Paths, f.path)
+			}
+			sort.Strings(set.Paths)
+			set.Reclaimable = set.Usage * int64(len(same)-1)
+			report.Reclaimable += set.Reclaimable
+			report.Sets = append(report.Sets, set)
+		}
+	}
+
+	sort.Slice(report.Sets, func(i, j int) bool {
+		a, b := report.Sets[i], report.Sets[j]
+		if a.Reclaimable != b.Reclaimable {
+			return a.Reclaimable > b.Reclaimable
+		}
+		return a.Paths[0] < b.Paths[0]
+	})
+	sort.Slice(report.Linked, func(i, j int) bool {
+		a, b := report.Linked[i], report.Linked[j]
+		if a.Usage != b.Usage {
+			return a.Usage > b.Usage
+		}
+		return a.Paths[0] < b.Paths[0]
+	})
+	return report, nil
+}
+
+// hashFile returns hex-encoded hash of the content of the file,
+// the reading is stopped when ctx is cancelled
+func hashFile(ctx context.Context, path string, newHash func() hash.Hash) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := newHash()
+	buf := make([]byte, 256*1024)
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		n, err := f.Read(buf)
+		h.Write(buf[:n])
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,131 @@
+package analyze
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func createDuplicatesFixture(t *testing.T) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "dups")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "sub"), 0o755))
+
+	content := make([]byte, 2000)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	different := make([]byte, 2000)
+
+	files := map[string][]byte{
+		"original":      content,
+		"sub/copy":      content,
+		"same-size":     different, // same size, different content
+		"small":         []byte("x"),
+		"sub/small-dup": []byte("x"),
+	}
+	for path, data := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(root, path), data, 0o600))
+	}
+	assert.NoError(t, os.Link(filepath.Join(root, "original"), filepath.Join(root, "sub", "link")))
+	return root
+}
+
+func TestFindDuplicates(t *testing.T) {
+	root := createDuplicatesFixture(t)
+
+	sequential := CreateSeqAnalyzer()
+	sequentialDir := sequential.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	sequential.GetDone().Wait()
+
+	incremental := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	incremental.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	incremental.GetDone().Wait()
+
+	// warm scan takes the sizes from the cache
+	warm := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: incremental.storagePath})
+	warmDir := warm.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	warm.GetDone().Wait()
+
+	for name, dir := range map[string]fs.Item{"sequential": sequentialDir, "warm": warmDir} {
+		t.Run(name, func(t *testing.T) {
+			report, err := FindDuplicates(context.Background(), dir, DuplicateOptions{MinSize: 100})
+			assert.NoError(t, err)
+
+			assert.Len(t, report.Sets, 1)
+			set := report.Sets[0]
+			assert.Equal(t, []string{filepath.Join(root, "original"), filepath.Join(root, "sub", "copy")}, set.Paths)
+			assert.Equal(t, int64(2000), set.Size)
+			assert.Equal(t, set.Usage, set.Reclaimable)
+			assert.Equal(t, set.Reclaimable, report.Reclaimable)
+			assert.Len(t, set.Hash, 64)
+
+			assert.Equal(t, []LinkedSet{{
+				Size:  2000,
+				Usage: set.Usage,
+				Paths: []string{filepath.Join(root, "original"), filepath.Join(root, "sub", "link")},
+			}}, report.Linked)
+
+			assert.Equal(t, 3, report.Candidates)
+			assert.Equal(t, 3, report.Hashed)
+			assert.Equal(t, 0, report.Skipped)
+			assert.Equal(t, 0, report.Errors)
+		})
+	}
+}
+
+func TestFindDuplicates_Options(t *testing.T) {
+	root := createDuplicatesFixture(t)
+	analyzer := CreateSeqAnalyzer()
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	// small files are compared too
+	report, err := FindDuplicates(context.Background(), dir, DuplicateOptions{Hash: "md5"})
+	assert.NoError(t, err)
+	assert.Len(t, report.Sets, 2)
+	assert.Equal(t, []string{filepath.Join(root, "small"), filepath.Join(root, "sub", "small-dup")}, report.Sets[1].Paths)
+	assert.Len(t, report.Sets[0].Hash, 32)
+
+	// the larger candidates do not fit, the smaller ones are still hashed
+	report, err = FindDuplicates(context.Background(), dir, DuplicateOptions{MaxFiles: 2})
+	assert.NoError(t, err)
+	assert.Len(t, report.Sets, 1)
+	assert.Equal(t, 3, report.Skipped)
+	assert.Equal(t, 2, report.Hashed)
+
+	_, err = FindDuplicates(context.Background(), dir, DuplicateOptions{Hash: "crc"})
+	assert.ErrorContains(t, err, `unknown hash algorithm "crc"`)
+}
+
+func TestFindDuplicates_Cancelled(t *testing.T) {
+	root := createDuplicatesFixture(t)
+	analyzer := CreateSeqAnalyzer()
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := FindDuplicates(ctx, dir, DuplicateOptions{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestFindDuplicates_Unreadable(t *testing.T) {
+	root := createDuplicatesFixture(t)
+	analyzer := CreateSeqAnalyzer()
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	// the tree is older than the files
+	assert.NoError(t, os.Remove(filepath.Join(root, "sub", "copy")))
+
+	report, err := FindDuplicates(context.Background(), dir, DuplicateOptions{MinSize: 100})
+	assert.NoError(t, err)
+	assert.Empty(t, report.Sets)
+	assert.Equal(t, 1, report.Errors)
+	assert.Equal(t, 2, report.Hashed)
+}
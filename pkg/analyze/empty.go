@@ -0,0 +1,154 @@
+package analyze
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// EmptyDir is a directory found by FindEmptyDirs
+type EmptyDir struct {
+	Path string `json:"path"`
+	// Nested are the empty directories inside of a recursively empty directory, deepest first
+	// (in the order they can be removed in)
+	Nested []string `json:"nested,omitempty"`
+	Item   fs.Item  `json:"-"`
+}
+
+// EmptyDirReport is the result of FindEmptyDirs
+type EmptyDirReport struct {
+	Dirs      []EmptyDir // topmost empty directories in path order
+	Total     int        // number of empty directories including the nested ones
+	Recursive bool       // directories containing only empty directories are included
+}
+
+// emptyDirFinder walks the tree bottom-up, a directory is known to be empty only after its children
+type emptyDirFinder struct {
+	ctx       context.Context
+	recursive bool
+}
+
+// FindEmptyDirs lists the empty directories in the subtree (without the subtree itself).
+// With recursive, directories containing only other recursively empty directories are listed as well,
+// just the topmost of them with the nested ones. The answer is given by the tree only
+// (the 'e' flag and the children), so a tree loaded from the incremental cache is answered
+// without reading the filesystem. Directories which could not be read are never empty
+// and entries left out of the scan by ignore patterns are not known to the tree.
+// Cancellation of ctx stops the search and its error is returned
+func FindEmptyDirs(ctx context.Context, item fs.Item, recursive bool) (*EmptyDirReport, error) {
+	finder := &emptyDirFinder{ctx: ctx, recursive: recursive}
+	_, found, err := finder.children(item.GetPath(), item)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].Path < found[j].Path })
+	report := &EmptyDirReport{Dirs: found, Recursive: recursive}
+	for _, dir := range found {
+		report.Total += 1 + len(dir.Nested)
+	}
+	return report, nil
+}
+
+// dir returns true if the directory is empty, with the topmost empty directories of its subtree
+// (just the directory itself if it is empty)
+func (f *emptyDirFinder) dir(path string, dir fs.Item) (bool, []EmptyDir, error) {
+	if err := f.ctx.Err(); err != nil {
+		return false, nil, err
+	}
+
+	onlyEmpty, found, err := f.children(path, dir)
+	if err != nil {
+		return false, nil, err
+	}
+
+	var empty bool
+	switch dir.GetFlag() {
+	case 'e':
+		empty = true
+	case ' ':
+		empty = f.recursive && onlyEmpty && len(found) > 0
+	}
+	if !empty {
+		return false, found, nil
+	}
+
+	nested := make([]string, 0)
+	for _, inside := range found {
+		nested = append(nested, inside.Nested...)
+		nested = append(nested, inside.Path)
+	}
+	return true, []EmptyDir{{Path: path, Nested: nested, Item: dir}}, nil
+}
+
+// children returns true if all children of the directory are empty directories,
+// with the topmost empty directories below it
+func (f *emptyDirFinder) children(path string, dir fs.Item) (bool, []EmptyDir, error) {
+	onlyEmpty := true
+	var found []EmptyDir
+	for _, child := range dir.GetFilesLocked() {
+		if _, ok := child.(*ParentDir); ok {
+			continue
+		}
+		if !child.IsDir() {
+			onlyEmpty = false
+			continue
+		}
+		empty, childFound, err := f.dir(filepath.Join(path, child.GetName()), child)
+		if err != nil {
+			return false, nil, err
+		}
+		onlyEmpty = onlyEmpty && empty
+		found = append(found, childFound...)
+	}
+	return onlyEmpty, found, nil
+}
+
+// WriteRemovalScript writes shell script removing the directories of the report for review.
+// It uses rmdir, which refuses to remove directories which are not empty anymore
+func (r *EmptyDirReport) WriteRemovalScript(w io.Writer) error {
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&script, "# %d empty directories, review before running\n", r.Total)
+	for _, dir := range r.Dirs {
+		for _, path := range dir.Nested {
+			script.WriteString("rmdir -- " + shellQuote(path) + "\n")
+		}
+		script.WriteString("rmdir -- " + shellQuote(dir.Path) + "\n")
+	}
+	_, err := io.WriteString(w, script.String())
+	return err
+}
+
+// shellQuote quotes the string for POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// RemoveEmptyDir removes the directory and its nested empty directories from the filesystem, deepest first.
+// Only empty directories are removed (like rmdir), so a file created since the scan stops the removal
+// and is kept together with the directories holding it. Nested directories removed before are not restored
+func RemoveEmptyDir(empty EmptyDir) error {
+	paths := make([]string, 0, len(empty.Nested)+1)
+	paths = append(paths, empty.Nested...)
+	paths = append(paths, empty.Path)
+	for _, path := range paths {
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package analyze
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createEmptyDirsTree creates:
+//
+//	root/
+//	  a/            empty
+//	  b/            recursively empty
+//	    c/          empty
+//	    d/          recursively empty
+//	      e/        empty
+//	  f/            not empty
+//	    file
+//	    g/          empty
+//	  h/            not empty (contains a file deeper)
+//	    i/
+//	      file
+//	    j/          empty
+func createEmptyDirsTree(t *testing.T) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "root")
+	for _, dir := range []string{"a", "b/c", "b/d/e", "f/g", "h/i", "h/j"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o755))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "f", "file"), []byte("x"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "h", "i", "file"), []byte("x"), 0o600))
+	return root
+}
+
+func emptyDirPaths(report *EmptyDirReport) []string {
+	paths := make([]string, 0, len(report.Dirs))
+	for _, dir := range report.Dirs {
+		paths = append(paths, dir.Path)
+	}
+	return paths
+}
+
+func TestFindEmptyDirs(t *testing.T) {
+	root := createEmptyDirsTree(t)
+	analyzer := CreateSeqAnalyzer()
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	report, err := FindEmptyDirs(context.Background(), dir, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(root, "a"),
+		filepath.Join(root, "b", "c"),
+		filepath.Join(root, "b", "d", "e"),
+		filepath.Join(root, "f", "g"),
+		filepath.Join(root, "h", "j"),
+	}, emptyDirPaths(report))
+	assert.Equal(t, 5, report.Total)
+
+	report, err = FindEmptyDirs(context.Background(), dir, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(root, "a"),
+		filepath.Join(root, "b"),
+		filepath.Join(root, "f", "g"),
+		filepath.Join(root, "h", "j"),
+	}, emptyDirPaths(report))
+	assert.Equal(t, []string{
+		filepath.Join(root, "b", "c"),
+		filepath.Join(root, "b", "d", "e"),
+		filepath.Join(root, "b", "d"),
+	}, report.Dirs[1].Nested)
+	assert.Equal(t, 7, report.Total)
+
+	// the subtree itself is not listed
+	b := childByName(dir.(*Dir), "b")
+	report, err = FindEmptyDirs(context.Background(), b, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "b", "c"), filepath.Join(root, "b", "d")}, emptyDirPaths(report))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = FindEmptyDirs(ctx, dir, true)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestFindEmptyDirsFromCache(t *testing.T) {
+	root := createEmptyDirsTree(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+	scan := func() (*Dir, *IncrementalAnalyzer) {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		return dir, analyzer
+	}
+
+	cold, _ := scan()
+	coldReport, err := FindEmptyDirs(context.Background(), cold, true)
+	assert.NoError(t, err)
+
+	warm, analyzer := scan()
+	assert.Zero(t, analyzer.GetCacheStats().DirsRescanned)
+	warmReport, err := FindEmptyDirs(context.Background(), warm, true)
+	assert.NoError(t, err)
+	assert.Equal(t, emptyDirPaths(coldReport), emptyDirPaths(warmReport))
+	assert.Equal(t, 7, warmReport.Total)
+}
+
+func TestEmptyDirsRemovalScript(t *testing.T) {
+	report := &EmptyDirReport{
+		Dirs: []EmptyDir{
+			{Path: "/srv/it's"},
+			{Path: "/srv/tree", Nested: []string{"/srv/tree/a/b", "/srv/tree/a"}},
+		},
+		Total: 4,
+	}
+
+	var script bytes.Buffer
+	assert.NoError(t, report.WriteRemovalScript(&script))
+	assert.Equal(t, "#!/bin/sh\n"+
+		"# 4 empty directories, review before running\n"+
+		"rmdir -- '/srv/it'\\''s'\n"+
+		"rmdir -- '/srv/tree/a/b'\n"+
+		"rmdir -- '/srv/tree/a'\n"+
+		"rmdir -- '/srv/tree'\n", script.String())
+}
+
+func TestRemoveEmptyDir(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b", "c"), 0o755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "x", "y"), 0o755))
+
+	assert.NoError(t, RemoveEmptyDir(EmptyDir{
+		Path:   filepath.Join(root, "a"),
+		Nested: []string{filepath.Join(root, "a", "b", "c"), filepath.Join(root, "a", "b")},
+	}))
+	assert.NoDirExists(t, filepath.Join(root, "a"))
+
+	// a file created since the scan is kept with the directories holding it
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "x", "y", "file"), []byte("x"), 0o600))
+	err := RemoveEmptyDir(EmptyDir{Path: filepath.Join(root, "x"), Nested: []string{filepath.Join(root, "x", "y")}})
+	assert.Error(t, err)
+	assert.FileExists(t, filepath.Join(root, "x", "y", "file"))
+
+	// neither a file which replaced the directory is removed
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "f"), []byte("x"), 0o600))
+	assert.ErrorContains(t, RemoveEmptyDir(EmptyDir{Path: filepath.Join(root, "f")}), "is not a directory")
+	assert.FileExists(t, filepath.Join(root, "f"))
+}
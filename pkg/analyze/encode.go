@@ -26,6 +26,41 @@ func (f *Dir) EncodeJSON(writer io.Writer, topLevel bool) error {
 		buff = append(buff, []byte(`,"mtime":`)...)
 		buff = append(buff, []byte(strconv.FormatInt(f.GetMtime().Unix(), 10))...)
 	}
+	if !f.GetBtime().IsZero() {
+		buff = append(buff, []byte(`,"btime":`)...)
+		buff = append(buff, []byte(strconv.FormatInt(f.GetBtime().Unix(), 10))...)
+	}
+	if f.SelfSize > 0 {
+		buff = append(buff, []byte(`,"selfasize":`)...)
+		buff = append(buff, []byte(strconv.FormatInt(f.SelfSize, 10))...)
+	}
+	if f.SelfUsage > 0 {
+		buff = append(buff, []byte(`,"selfdsize":`)...)
+		buff = append(buff, []byte(strconv.FormatInt(f.SelfUsage, 10))...)
+	}
+	if f.ErrorCount > 0 {
+		buff = append(buff, []byte(`,"errors":`)...)
+		buff = append(buff, []byte(strconv.Itoa(f.ErrorCount))...)
+	}
+	if est := f.Estimate; est != nil {
+		buff = append(buff, []byte(`,"estimate":{"sampled":`)...)
+		buff = append(buff, []byte(strconv.Itoa(est.Sampled))...)
+		buff = append(buff, []byte(`,"skipped":`)...)
+		buff = append(buff, []byte(strconv.Itoa(est.Skipped))...)
+		buff = append(buff, []byte(`,"asize":`)...)
+		buff = append(buff, []byte(strconv.FormatInt(est.Size, 10))...)
+		buff = append(buff, []byte(`,"dsize":`)...)
+		buff = append(buff, []byte(strconv.FormatInt(est.Usage, 10))...)
+		buff = append(buff, []byte(`,"relerr":`)...)
+		buff = append(buff, []byte(strconv.FormatFloat(est.RelErr, 'g', 4, 64))...)
+		buff = append(buff, '}')
+	}
+	if f.Annotation != "" {
+		buff = append(buff, []byte(`,"note":`)...)
+		if err := addString(&buff, f.Annotation); err != nil {
+			return err
+		}
+	}
 
 	buff = append(buff, '}')
 	if f.Files.Len() > 0 {
@@ -75,10 +110,20 @@ func (f *File) EncodeJSON(writer io.Writer, topLevel bool) error {
 		buff = append(buff, []byte(`,"mtime":`)...)
 		buff = append(buff, []byte(strconv.FormatInt(f.GetMtime().Unix(), 10))...)
 	}
+	if !f.GetBtime().IsZero() {
+		buff = append(buff, []byte(`,"btime":`)...)
+		buff = append(buff, []byte(strconv.FormatInt(f.GetBtime().Unix(), 10))...)
+	}
 
 	if f.Flag == '@' {
 		buff = append(buff, []byte(`,"notreg":true`)...)
 	}
+	if f.Flag == '?' {
+		buff = append(buff, []byte(`,"notreg":true,"broken":true`)...)
+	}
+	if f.Flag == 'S' {
+		buff = append(buff, []byte(`,"notreg":true,"special":true`)...)
+	}
 	if f.Flag == 'H' {
 		buff = append(buff, []byte(`,"ino":`+strconv.FormatUint(f.Mli, 10)+`,"hlnkc":true`)...)
 	}
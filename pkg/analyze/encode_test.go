@@ -2,6 +2,7 @@ package analyze
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 	"time"
 
@@ -48,14 +49,23 @@ func TestEncode(t *testing.T) {
 		Parent: subdir,
 		Flag:   '@',
 		Mtime:  time.Date(2021, 8, 19, 0, 40, 0, 0, time.UTC),
+		Btime:  time.Date(2021, 8, 18, 0, 40, 0, 0, time.UTC),
 	}
 	file3 := &File{
 		Name: "file3",
 		Mli:  1234,
 		Flag: 'H',
 	}
+	file4 := &File{
+		Name: "dangling",
+		Flag: '?',
+	}
+	file5 := &File{
+		Name: "fifo",
+		Flag: 'S',
+	}
 	dir.Files = fs.Files{subdir}
-	subdir.Files = fs.Files{file, file2, file3}
+	subdir.Files = fs.Files{file, file2, file3, file4, file5}
 
 	var buff bytes.Buffer
 	err := dir.EncodeJSON(&buff, true)
@@ -63,6 +73,70 @@ func TestEncode(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Contains(t, buff.String(), `"name":"nested"`)
 	assert.Contains(t, buff.String(), `"mtime":1629333600`)
+	assert.Contains(t, buff.String(), `"btime":1629247200`)
+	assert.Equal(t, 1, strings.Count(buff.String(), `"btime"`))
 	assert.Contains(t, buff.String(), `"ino":1234`)
 	assert.Contains(t, buff.String(), `"hlnkc":true`)
+	assert.Contains(t, buff.String(), `{"name":"dangling","notreg":true,"broken":true}`)
+	assert.Contains(t, buff.String(), `{"name":"fifo","notreg":true,"special":true}`)
+}
+
+func TestEncodeEstimate(t *testing.T) {
+	dir := &Dir{
+		File: &File{
+			Name: "big",
+			Flag: '~',
+		},
+		BasePath: ".",
+		Estimate: &Estimate{Sampled: 5, Skipped: 45, Size: 4500, Usage: 184320, RelErr: 0.125},
+	}
+
+	var buff bytes.Buffer
+	err := dir.EncodeJSON(&buff, true)
+
+	assert.Nil(t, err)
+	assert.Contains(t, buff.String(),
+		`"estimate":{"sampled":5,"skipped":45,"asize":4500,"dsize":184320,"relerr":0.125}`)
+}
+
+func TestEncodeAnnotation(t *testing.T) {
+	dir := &Dir{
+		File: &File{
+			Name: "shared",
+			Flag: ' ',
+		},
+		BasePath:   ".",
+		Annotation: `owned by "team X"`,
+	}
+
+	var buff bytes.Buffer
+	err := dir.EncodeJSON(&buff, true)
+
+	assert.Nil(t, err)
+	assert.Contains(t, buff.String(), `"note":"owned by \"team X\""`)
+}
+
+func TestEncodeSelfSizes(t *testing.T) {
+	dir := &Dir{
+		File: &File{
+			Name:  "photos",
+			Size:  8192,
+			Usage: 12288,
+			Flag:  ' ',
+		},
+		BasePath:  ".",
+		SelfSize:  4096,
+		SelfUsage: 8192,
+	}
+
+	var buff bytes.Buffer
+	err := dir.EncodeJSON(&buff, true)
+
+	assert.Nil(t, err)
+	assert.Contains(t, buff.String(), `"selfasize":4096,"selfdsize":8192`)
+
+	dir.SelfSize, dir.SelfUsage = 0, 0
+	buff.Reset()
+	assert.Nil(t, dir.EncodeJSON(&buff, true))
+	assert.NotContains(t, buff.String(), `"self`)
 }
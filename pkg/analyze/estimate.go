@@ -0,0 +1,61 @@
+package analyze
+
+import (
+	"math"
+)
+
+// Estimate describes files of a directory which were not read.
+// Their totals are extrapolated from a random sample of the other files of the directory
+type Estimate struct {
+	Sampled int     // number of files read
+	Skipped int     // number of files not read
+	Size    int64   // extrapolated apparent size of the skipped files
+	Usage   int64   // extrapolated disk usage of the skipped files
+	RelErr  float64 // relative standard error of the total apparent size of the files
+}
+
+// newEstimate extrapolates totals of skipped files from the apparent sizes and usages
+// of the sampled ones
+func newEstimate(sizes, usages []int64, skipped int) *Estimate {
+	est := &Estimate{Sampled: len(sizes), Skipped: skipped}
+	if len(sizes) == 0 {
+		return est
+	}
+
+	n := float64(len(sizes))
+	var sumSize, sumUsage float64
+	for i := range sizes {
+		sumSize += float64(sizes[i])
+		sumUsage += float64(usages[i])
+	}
+	mean := sumSize / n
+	est.Size = int64(math.Round(mean * float64(skipped)))
+	est.Usage = int64(math.Round(sumUsage / n * float64(skipped)))
+
+	total := sumSize + float64(est.Size)
+	if len(sizes) < 2 || total == 0 {
+		return est
+	}
+
+	var variance float64
+	for _, size := range sizes {
+		variance += (float64(size) - mean) * (float64(size) - mean)
+	}
+	variance /= n - 1
+
+	// standard error of the extrapolated sum with finite population correction
+	population := n + float64(skipped)
+	stdErr := float64(skipped) * math.Sqrt(variance/n*(1-n/population))
+	est.RelErr = stdErr / total
+	return est
+}
+
+// IsEstimated returns true if totals of the directory or some of its subdirectories are estimated
+func (f *Dir) IsEstimated() bool {
+	return f.EstimatedDirCount > 0
+}
+
+// GetEstimate returns estimate of the files of the directory itself, nil if all were read
+func (f *Dir) GetEstimate() *Estimate {
+	return f.Estimate
+}
@@ -11,12 +11,14 @@ import (
 // File struct
 type File struct {
 	Mtime  time.Time
+	Btime  time.Time // birth time, zero if not known
 	Parent fs.Item
 	Name   string
 	Size   int64
 	Usage  int64
 	Mli    uint64
 	Flag   rune
+	owner  uint32 // uid of the owner plus one, zero if not known
 }
 
 // GetName returns name of dir
@@ -64,10 +66,30 @@ func (f *File) GetMtime() time.Time {
 	return f.Mtime
 }
 
+// GetBtime returns birth (creation) time of the file, zero if not known
+func (f *File) GetBtime() time.Time {
+	return f.Btime
+}
+
+// GetOwner returns uid of the owner of the file, ok is false if it is not known
+func (f *File) GetOwner() (uid uint32, ok bool) {
+	return f.owner - 1, f.owner != 0
+}
+
+// SetOwner sets uid of the owner of the file
+func (f *File) SetOwner(uid uint32) {
+	f.owner = uid + 1
+}
+
 // GetType returns name type of item
 func (f *File) GetType() string {
-	if f.Flag == '@' {
+	switch f.Flag {
+	case '@':
 		return "Other"
+	case '?':
+		return "Broken symlink"
+	case 'S':
+		return "Special file"
 	}
 	return "File"
 }
@@ -142,7 +164,29 @@ type Dir struct {
 	BasePath  string
 	Files     fs.Files
 	ItemCount int
-	m         sync.RWMutex
+	// ErrorCount is number of read errors encountered in the whole subtree
+	ErrorCount int
+	// SymlinkCount is number of symlinks in the whole subtree,
+	// BrokenSymlinkCount is number of those which could not be followed
+	SymlinkCount       int
+	BrokenSymlinkCount int
+	// DuplicateOf is path of the directory this one is identical to (e.g. bind mount),
+	// such directory has no children and does not count to the totals
+	DuplicateOf string
+	// Estimate is set if only a sample of the files was read, Files contain just the sampled ones.
+	// EstimatedDirCount is number of such directories in the whole subtree
+	Estimate          *Estimate
+	EstimatedDirCount int
+	// Annotation is the note of the directory stored in the incremental cache
+	Annotation string
+	// SelfSize and SelfUsage are totals of the files directly in the directory
+	// (including the estimated ones), without subdirectories
+	SelfSize  int64
+	SelfUsage int64
+	// HardlinksSaved is disk usage of the hard links in the whole subtree which are not counted
+	// to the totals, because another link of the same file was counted already
+	HardlinksSaved int64
+	m              sync.RWMutex
 }
 
 // AddFile add item to files
@@ -180,6 +224,21 @@ func (f *Dir) GetItemCount() int {
 	return f.ItemCount
 }
 
+// GetErrorCount returns number of read errors in the subtree
+func (f *Dir) GetErrorCount() int {
+	return f.ErrorCount
+}
+
+// GetSymlinkCount returns number of symlinks in the subtree
+func (f *Dir) GetSymlinkCount() int {
+	return f.SymlinkCount
+}
+
+// GetBrokenSymlinkCount returns number of symlinks in the subtree which could not be followed
+func (f *Dir) GetBrokenSymlinkCount() int {
+	return f.BrokenSymlinkCount
+}
+
 // IsDir returns true for dir
 func (f *Dir) IsDir() bool {
 	return true
@@ -196,6 +255,11 @@ func (f *Dir) GetPath() string {
 	return f.Name
 }
 
+// GetDuplicateOf returns path of the directory this one duplicates or empty string
+func (f *Dir) GetDuplicateOf() string {
+	return f.DuplicateOf
+}
+
 // GetItemStats returns item count, apparent usage and real usage of this dir
 func (f *Dir) GetItemStats(linkedItems fs.HardLinkedItems) (itemCount int, size, usage int64) {
 	f.UpdateStats(linkedItems)
@@ -204,14 +268,31 @@ func (f *Dir) GetItemStats(linkedItems fs.HardLinkedItems) (itemCount int, size,
 
 // UpdateStats recursively updates size and item count
 func (f *Dir) UpdateStats(linkedItems fs.HardLinkedItems) {
+	if f.DuplicateOf != "" {
+		f.ItemCount = 1
+		f.Size = 0
+		f.Usage = 0
+		f.HardlinksSaved = 0
+		return
+	}
+
 	totalSize := int64(4096)
 	totalUsage := int64(4096)
 	var itemCount int
+	var selfSize, selfUsage, saved int64
 	for _, entry := range f.GetFiles() {
 		count, size, usage := entry.GetItemStats(linkedItems)
 		totalSize += size
 		totalUsage += usage
 		itemCount += count
+		if !entry.IsDir() {
+			selfSize += size
+			selfUsage += usage
+			// already counted links of the file are returned with zero usage
+			saved += entry.GetUsage() - usage
+		} else if dir, ok := entry.(interface{ GetHardlinksSaved() int64 }); ok {
+			saved += dir.GetHardlinksSaved()
+		}
 
 		if entry.GetMtime().After(f.Mtime) {
 			f.Mtime = entry.GetMtime()
@@ -224,9 +305,87 @@ func (f *Dir) UpdateStats(linkedItems fs.HardLinkedItems) {
 			}
 		}
 	}
+	if f.Estimate != nil {
+		itemCount += f.Estimate.Skipped
+		totalSize += f.Estimate.Size
+		totalUsage += f.Estimate.Usage
+		selfSize += f.Estimate.Size
+		selfUsage += f.Estimate.Usage
+	}
 	f.ItemCount = itemCount + 1
 	f.Size = totalSize
 	f.Usage = totalUsage
+	f.SelfSize = selfSize
+	f.SelfUsage = selfUsage
+	f.HardlinksSaved = saved
+}
+
+// GetHardlinksSaved returns disk usage of the hard links in the subtree not counted to the totals
+func (f *Dir) GetHardlinksSaved() int64 {
+	return f.HardlinksSaved
+}
+
+// GetSelfSize returns apparent size of the files directly in the directory
+func (f *Dir) GetSelfSize() int64 {
+	return f.SelfSize
+}
+
+// GetSelfUsage returns disk usage of the files directly in the directory
+func (f *Dir) GetSelfUsage() int64 {
+	return f.SelfUsage
+}
+
+// computeSelfSizes sets SelfSize and SelfUsage from the loaded files
+func (f *Dir) computeSelfSizes() {
+	f.SelfSize, f.SelfUsage = 0, 0
+	for _, entry := range f.Files {
+		if !entry.IsDir() {
+			f.SelfSize += entry.GetSize()
+			f.SelfUsage += entry.GetUsage()
+		}
+	}
+	if f.Estimate != nil {
+		f.SelfSize += f.Estimate.Size
+		f.SelfUsage += f.Estimate.Usage
+	}
+}
+
+// ComputeAggregates updates item count, size and usage of the dir and its ancestors
+// after the previous version of a child subtree was replaced by the current one.
+// Unlike UpdateStats it visits only the chain of ancestors, not the whole tree.
+// Item count does not depend on hardlink deduplication, sizes are adjusted
+// by the difference of the two versions as they were computed.
+func (f *Dir) ComputeAggregates(previous, current fs.Item) {
+	var (
+		countDelta            int
+		sizeDelta, usageDelta int64
+	)
+	if previous != nil {
+		countDelta -= previous.GetItemCount()
+		sizeDelta -= previous.GetSize()
+		usageDelta -= previous.GetUsage()
+	}
+	if current != nil {
+		countDelta += current.GetItemCount()
+		sizeDelta += current.GetSize()
+		usageDelta += current.GetUsage()
+	}
+	if countDelta == 0 && sizeDelta == 0 && usageDelta == 0 {
+		return
+	}
+
+	cur := f
+	for {
+		cur.ItemCount += countDelta
+		cur.Size += sizeDelta
+		cur.Usage += usageDelta
+
+		parent, ok := cur.Parent.(*Dir)
+		if !ok {
+			break
+		}
+		cur = parent
+	}
 }
 
 // RemoveFile removes item from dir, updates size and item count
@@ -235,6 +394,10 @@ func (f *Dir) RemoveFile(item fs.Item) {
 	defer f.m.Unlock()
 
 	f.SetFiles(f.GetFiles().Remove(item))
+	if !item.IsDir() {
+		f.SelfSize -= item.GetSize()
+		f.SelfUsage -= item.GetUsage()
+	}
 
 	cur := f
 	for {
@@ -242,10 +405,59 @@ func (f *Dir) RemoveFile(item fs.Item) {
 		cur.Size -= item.GetSize()
 		cur.Usage -= item.GetUsage()
 
-		if cur.Parent == nil {
+		parent, ok := cur.Parent.(*Dir)
+		if !ok {
+			break
+		}
+		cur = parent
+	}
+}
+
+// RemoveFiles removes items from dir, updates size and item count.
+// Unlike calling RemoveFile for each of them, the files of dir are filtered only once
+func (f *Dir) RemoveFiles(items []fs.Item) {
+	if len(items) == 0 {
+		return
+	}
+
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	removed := make(map[fs.Item]struct{}, len(items))
+	var (
+		itemCount   int
+		size, usage int64
+	)
+	for _, item := range items {
+		removed[item] = struct{}{}
+		itemCount += item.GetItemCount()
+		size += item.GetSize()
+		usage += item.GetUsage()
+		if !item.IsDir() {
+			f.SelfSize -= item.GetSize()
+			f.SelfUsage -= item.GetUsage()
+		}
+	}
+
+	files := make(fs.Files, 0, max(len(f.Files)-len(items), 0))
+	for _, file := range f.Files {
+		if _, ok := removed[file]; !ok {
+			files = append(files, file)
+		}
+	}
+	f.SetFiles(files)
+
+	cur := f
+	for {
+		cur.ItemCount -= itemCount
+		cur.Size -= size
+		cur.Usage -= usage
+
+		parent, ok := cur.Parent.(*Dir)
+		if !ok {
 			break
 		}
-		cur = cur.Parent.(*Dir)
+		cur = parent
 	}
 }
 
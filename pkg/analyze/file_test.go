@@ -108,6 +108,46 @@ func TestRemove(t *testing.T) {
 	assert.Equal(t, file2, dir.Files[0])
 }
 
+func TestRemoveFiles(t *testing.T) {
+	top := &Dir{
+		File: &File{
+			Name:  "top",
+			Size:  15,
+			Usage: 24,
+		},
+		ItemCount: 5,
+	}
+	dir := &Dir{
+		File: &File{
+			Name:   "xxx",
+			Size:   10,
+			Usage:  16,
+			Parent: top,
+		},
+		ItemCount: 4,
+	}
+	top.Files = fs.Files{dir}
+
+	file := &File{Name: "yyy", Size: 2, Usage: 4, Parent: dir}
+	file2 := &File{Name: "zzz", Size: 3, Usage: 4, Parent: dir}
+	file3 := &File{Name: "www", Size: 4, Usage: 4, Parent: dir}
+	dir.Files = fs.Files{file, file2, file3}
+	dir.SelfSize, dir.SelfUsage = 9, 12
+
+	dir.RemoveFiles([]fs.Item{file3, file})
+	dir.RemoveFiles(nil)
+
+	assert.Equal(t, fs.Files{file2}, dir.Files)
+	assert.Equal(t, 2, dir.ItemCount)
+	assert.Equal(t, int64(4), dir.Size)
+	assert.Equal(t, int64(8), dir.Usage)
+	assert.Equal(t, int64(3), dir.SelfSize)
+	assert.Equal(t, int64(4), dir.SelfUsage)
+	assert.Equal(t, 3, top.ItemCount)
+	assert.Equal(t, int64(9), top.Size)
+	assert.Equal(t, int64(16), top.Usage)
+}
+
 func TestRemoveByName(t *testing.T) {
 	dir := Dir{
 		File: &File{
@@ -224,12 +264,58 @@ func TestUpdateStats(t *testing.T) {
 	}
 	dir.Files = fs.Files{file, file2}
 
+	subdir := &Dir{
+		File:      &File{Name: "sub", Parent: &dir},
+		ItemCount: 1,
+	}
+	subdir.Files = fs.Files{&File{Name: "deep", Size: 7, Parent: subdir}}
+	dir.Files = append(dir.Files, subdir)
+
 	dir.UpdateStats(nil)
 
-	assert.Equal(t, int64(4096+5), dir.Size)
+	assert.Equal(t, int64(4096+5+4096+7), dir.Size)
+	assert.Equal(t, int64(5), dir.GetSelfSize())
+	assert.Equal(t, int64(7), subdir.GetSelfSize())
 	assert.Equal(t, 42, dir.GetMtime().Minute())
 }
 
+func TestComputeAggregates(t *testing.T) {
+	top := &Dir{
+		File:      &File{Name: "top", Size: 30, Usage: 40},
+		ItemCount: 5,
+	}
+	parent := &Dir{
+		File:      &File{Name: "parent", Size: 20, Usage: 30, Parent: top},
+		ItemCount: 4,
+	}
+	previous := &Dir{
+		File:      &File{Name: "sub", Size: 10, Usage: 20, Parent: parent},
+		ItemCount: 2,
+	}
+	current := &Dir{
+		File:      &File{Name: "sub", Size: 15, Usage: 28, Parent: parent},
+		ItemCount: 4,
+	}
+	top.Files = fs.Files{parent}
+	parent.Files = fs.Files{current}
+
+	parent.ComputeAggregates(previous, current)
+
+	assert.Equal(t, 6, parent.ItemCount)
+	assert.Equal(t, int64(25), parent.Size)
+	assert.Equal(t, int64(38), parent.Usage)
+	assert.Equal(t, 7, top.ItemCount)
+	assert.Equal(t, int64(35), top.Size)
+	assert.Equal(t, int64(48), top.Usage)
+
+	// removed subtree
+	parent.ComputeAggregates(current, nil)
+
+	assert.Equal(t, 2, parent.ItemCount)
+	assert.Equal(t, 3, top.ItemCount)
+	assert.Equal(t, int64(20), top.Size)
+}
+
 func TestGetMultiLinkedInode(t *testing.T) {
 	file := &File{
 		Name: "xxx",
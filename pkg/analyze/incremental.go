@@ -2,10 +2,15 @@ package analyze
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dundee/gdu/v5/internal/common"
@@ -21,23 +26,104 @@ const (
 	DefaultDirBlockSize = 4096
 )
 
-// IncrementalAnalyzer implements Analyzer with incremental caching based on mtime
+// IncrementalAnalyzer implements Analyzer with incremental caching based on mtime.
+// Children of every directory are ordered by name (byte-wise) in both scanned
+// and cached directories unless IncrementalOptions.UnsortedChildren is set
 type IncrementalAnalyzer struct {
-	storage          *IncrementalStorage
-	storagePath      string
-	cacheMaxAge      time.Duration
-	forceFullScan    bool
-	throttle         *IOThrottle // I/O rate limiting to protect shared storage
-	stats            *CacheStats
-	progress         *common.CurrentProgress
-	progressChan     chan common.CurrentProgress
-	progressOutChan  chan common.CurrentProgress
-	progressDoneChan chan struct{}
-	doneChan         common.SignalGroup
-	wait             *WaitGroup
-	ignoreDir        common.ShouldDirBeIgnored
-	followSymlinks   bool
-	gitAnnexedSize   bool
+	storage         *IncrementalStorage
+	scanning        atomic.Bool
+	storagePath     string
+	cacheMaxAge     time.Duration
+	forceFullScan   bool
+	checkCrash      bool
+	verifyLinks     bool
+	followDirs      bool
+	unsorted        bool
+	checkDevice     bool
+	noCross         bool
+	rootDev         uint64 // device of the scanned directory with noCross, 0 if not known
+	trustRoot       bool
+	throttle        *IOThrottle // I/O rate limiting to protect shared storage
+	stats           *CacheStats
+	pump            *progressPump // progress of the running or the next scan
+	scanPump        *progressPump // progress of the running scan, used by the scanning code
+	doneChan        common.SignalGroup
+	m               sync.Mutex // guards pump, doneChan, ctx and cancel replaced by ResetProgress
+	ctx             context.Context
+	cancel          context.CancelFunc
+	result          *ScanResult
+	wait            *WaitGroup
+	ignoreDir       common.ShouldDirBeIgnored
+	followSymlinks  bool
+	gitAnnexedSize  bool
+	identify        func(os.FileInfo) (dirIdentity, bool)    // platform identity of a directory
+	visited         map[dirIdentity]string                   // directories visited in the running scan
+	visitedM        sync.Mutex                               // guards visited used by the workers
+	mounts          map[uint64]string                        // mount points of devices seen in the running scan
+	mountsM         sync.Mutex                               // guards mounts used by the workers
+	traceLimit      int                                      // limit of trace entries, negative if tracing is disabled
+	pathLimit       int                                      // limit of the path lists of CacheStats
+	trace           *DecisionTrace                           // decisions of the last scan, nil if tracing is disabled
+	recording       bool                                     // decisions of every directory are kept by the scans
+	decisions       *DecisionMap                             // decisions of the last scan, nil if not recorded
+	sampleAbove     int                                      // directories with more files are sampled, 0 if disabled
+	sampleSize      int                                      // number of files read in sampled directories
+	annotations     map[string]string                        // notes of directories loaded by the last scan
+	annotationsM    sync.Mutex                               // guards annotations used by the UI
+	specialSizes    bool                                     // count sizes of special files reported by stat
+	birthTimes      bool                                     // birth time of scanned items is read (Linux only)
+	snapshot        scanSnapshot                             // top-level items completed by the running scan
+	events          *writeEvents                             // subscribers of entries written by the scans
+	futureSkew      time.Duration                            // timestamps later than now + futureSkew are not trusted, 0 if disabled
+	futureLogged    atomic.Bool                              // timestamp in the future was already logged in the running scan
+	trustAhead      bool                                     // entries cached later than now are used, not scanned again
+	aheadLogged     atomic.Bool                              // entry cached later than now was already logged in the running scan
+	backwardsSkew   time.Duration                            // mtimes earlier than cached - backwardsSkew are reported, 0 if disabled
+	backwardsLogged atomic.Bool                              // mtime moved backwards was already logged in the running scan
+	provenance      provenance                               // host and version stamped into entries written by the running scan
+	versionLogged   atomic.Bool                              // entry of another major version was already logged in the running scan
+	maxDepth        int                                      // directories deeper below the scanned one are not read
+	excludeFiles    []string                                 // patterns of file names left out of the sizes
+	prefetchSize    int                                      // cache entries loaded ahead, 0 if disabled
+	prefetch        *prefetcher                              // loads entries of subdirectories ahead in the running scan
+	workerCount     int                                      // goroutines processing subdirectories of scanned directories
+	workers         *workerPool                              // workers of the running scan, nil if subdirectories are processed one by one
+	statsFile       string                                   // statistics are written there after every scan, empty if disabled
+	scanLabel       string                                   // label of the scans stored with their summary
+	retention       time.Duration                            // entries not written for longer are pruned after every scan, 0 if disabled
+	pruneStale      bool                                     // entries of removed directories of the scanned tree are pruned after every scan
+	removed         *removedDirs                             // directories found removed by the running scan, nil if not pruned
+	postScan        func(*StatsFile) error                   // called with the summary of every scan, nil if disabled
+	countCacheDir   bool                                     // the cache directory located in the scanned tree is not left out
+	volatileOK      bool                                     // the cache directory on volatile storage is not warned about
+	volatileLogged  bool                                     // the cache directory on volatile storage was already logged
+	memoryMode      MemoryMode                               // how GC runs during the scans
+	gcPercent       int                                      // GC percent of MemoryBalanced
+	fingerprint     uint64                                   // hash of the options changing content of cache entries
+	depthLogged     atomic.Bool                              // directory below the depth ceiling was already logged in the running scan
+	beforeSubdir    func(path string)                        // called before a listed subdirectory is processed, used by tests
+	hashPrefixes    []string                                 // directories whose content fingerprint is compared on cache hits
+	hashVerify      []string                                 // hashPrefixes as they appear in the running scan
+	refreshDirs     []string                                 // directories scanned without the cache
+	refresh         []string                                 // refreshDirs as they appear in the running scan
+	minCacheSize    int64                                    // smaller directories are not cached (with minCacheItems), 0 if not checked
+	minCacheItems   int                                      // directories with less children are not cached (with minCacheSize), 0 if not checked
+	invalidate      []string                                 // directories whose entries are removed by the next scan
+	invalidated     bool                                     // entries were invalidated before the running scan
+	trustUnlisted   bool                                     // directories with an entry are loaded without stat
+	minItemSize     int64                                    // smaller children are folded into FoldedItems, 0 if disabled
+	validation      ValidationMode                           // how cache entries are checked
+	diffing         bool                                     // changes of directories are collected by the scans
+	diff            *scanDiff                                // changes of directories of the last scan, nil if not collected
+	fromLabel       string                                   // the diff compares the scans with these labels, empty if disabled
+	toLabel         string                                   // the last scan is compared if empty
+	labelDiff       []DirDelta                               // changes between the labelled scans
+	diffErr         error                                    // why the labelled scans could not be compared
+	storageOpts     StorageOptions                           // applied to the cache database when it is opened
+	retryCount      int                                      // number of retries of reads failing with transient errors
+	retryDelay      time.Duration                            // delay before the first retry
+	statPath        func(path string) (os.FileInfo, error)   // os.Stat, replaced by tests
+	listDir         func(path string) ([]os.DirEntry, error) // readDir, replaced by tests
 }
 
 // IncrementalOptions contains configuration for IncrementalAnalyzer
@@ -47,35 +133,342 @@ type IncrementalOptions struct {
 	ForceFullScan bool
 	MaxIOPS       int           // Maximum I/O operations per second (0 = unlimited)
 	IODelay       time.Duration // Fixed delay between directory scans (0 = no delay)
+
+	// RefreshPaths lists directories which are scanned without the cache together with their
+	// subdirectories, as with ForceFullScan, while the rest of the tree is loaded from the cache.
+	// Their cache entries are replaced by the scan
+	RefreshPaths []string
+
+	// CheckAfterCrash checks the cache of the scanned directory and removes invalid entries
+	// when the previous scan did not finish
+	CheckAfterCrash bool
+
+	// VerifySymlinks resolves again symlinks of directories loaded from the cache
+	// (when following symlinks), so links broken or fixed since the scan are detected
+	VerifySymlinks bool
+
+	// FollowDirSymlinks descends into symlinks to directories (when following symlinks),
+	// which are scanned and cached under the path of the link. A directory reached again
+	// (e.g. by a link to its ancestor) is added only as a reference flagged 'D', so loops terminate
+	FollowDirSymlinks bool
+
+	// TraceDecisions records for every directory why it was loaded from the cache or scanned,
+	// see GetDecisionTrace. The decisions are written to the log as well
+	TraceDecisions bool
+	TraceLimit     int // maximum number of kept trace entries (0 = DefaultTraceLimit)
+
+	// RecordDecisions keeps the decision of every directory of the last scan, see GetDecisions.
+	// Unlike TraceDecisions the decisions are neither limited nor logged
+	RecordDecisions bool
+
+	// MaxReportedPaths limits the number of paths kept in the path lists of CacheStats
+	// (NewDirs, RemovedDirs), further ones are only counted (0 = DefaultMaxReportedPaths)
+	MaxReportedPaths int
+
+	// UnsortedChildren keeps children in the order returned by the filesystem
+	// (or stored in the cache) instead of sorting them by name.
+	// It saves sorting of huge directories, but exports of cold and warm scans may differ
+	UnsortedChildren bool
+
+	// RescanOnDeviceChange rescans directories whose cache entry was stored for another device,
+	// e.g. when a different disk is mounted at the same path.
+	// Device numbers of some filesystems (NFS, removable disks) change on remount,
+	// so all their directories are scanned again then
+	RescanOnDeviceChange bool
+
+	// NoCross leaves subdirectories on another device than the scanned directory out of the tree
+	// (mount points of other filesystems), the same way as the mount points ignored by the other
+	// analyzers with --no-cross. No cache entries are written for them and the entries of
+	// a subdirectory loaded from the cache which became a mount point since are removed.
+	// To find such mount points every subdirectory loaded from the cache is stat-ed.
+	// Cache entries of parents don't hold the mount points, so a directory below an unmounted one
+	// is found when its parent changes or with ForceFullScan or RefreshPaths
+	NoCross bool
+
+	// SampleThreshold enables the estimation mode: only SampleSize randomly chosen files
+	// of directories with more than SampleThreshold files are read, totals of the others
+	// are extrapolated. Subdirectories are always scanned. Estimated cache entries are used
+	// only in the estimation mode, a scan without it replaces them with exact ones
+	SampleThreshold int
+	SampleSize      int // number of files read in sampled directories (0 = DefaultSampleSize)
+
+	// FutureSkew enables check of timestamps in the future, e.g. of files copied from a machine
+	// with broken clock. Directories with mtime or cache entry later than now + FutureSkew
+	// are scanned again, as their entries would never expire or be compared reliably.
+	// 0 disables the check
+	FutureSkew time.Duration
+
+	// TrustCachedAhead sets the policy for cache entries written later than now (by more than a minute),
+	// usually before the system clock was stepped backwards. By default such entries are expired
+	// and their directories scanned again, with TrustCachedAhead they are used as if cached now,
+	// which is faster but misses changes made before the clock step. Entries later than
+	// now + FutureSkew are scanned again regardless
+	TrustCachedAhead bool
+
+	// BackwardsSkew enables check of directories whose mtime moved backwards, e.g. restored
+	// from a backup or modified while the system clock was behind. Directories with mtime earlier
+	// than the cached one by more than BackwardsSkew are scanned again (as any changed directory),
+	// counted and logged. 0 disables the check
+	BackwardsSkew time.Duration
+
+	// TrustRootMtime enables the summary fast path: if mtime of the scanned directory
+	// is the same as at the end of the previous scan, which completed cleanly, only its own
+	// cache entry is loaded and the rest of the tree is not walked. Subdirectories of the
+	// returned directory hold only their totals and no children.
+	// It trusts that changes deep in the tree propagate to the mtime of the top directory,
+	// which is not true on most filesystems (only direct children change the mtime),
+	// so it must be used only for trees where it is known to hold
+	TrustRootMtime bool
+
+	// MaxDepth is the ceiling protecting scans of pathological trees (e.g. runaway mkdir loops).
+	// Directories more than MaxDepth levels below the scanned directory are not read,
+	// they are shown with their own size only and the '>' flag (0 = DefaultMaxDepth)
+	MaxDepth int
+
+	// ExcludeFiles lists glob patterns (see filepath.Match) of file names which are left out
+	// of the tree and its sizes, e.g. "*.tmp" or "core.*". Directories are not matched.
+	// Cache entries written with other patterns are scanned again
+	ExcludeFiles []string
+
+	// PrefetchSize is the number of cache entries of subdirectories loaded in background
+	// while their parent is rebuilt from the cache (0 = DefaultPrefetchSize, negative disables it)
+	PrefetchSize int
+
+	// Workers is the number of goroutines processing subdirectories of scanned directories
+	// (0 = GOMAXPROCS, 1 processes them one by one). Sibling subdirectories are processed
+	// concurrently while their parent waits for them, the tree is the same as of the serial scan.
+	// The workers share the I/O throttle: MaxIOPS limits the listings of all of them together,
+	// IODelay delays every listing of every worker. Of a directory reachable by several paths
+	// (bind mounts) the path processed first holds the subtree and the other ones are references,
+	// which may be another path than in the serial scan, the totals are the same.
+	// Directories are processed one by one with FollowDirSymlinks, where such paths are common
+	Workers int
+
+	// StatsFilePath is a file replaced after every scan by JSON with the scanned path,
+	// the scan generation, its result and the cache statistics (see StatsFile),
+	// e.g. for the textfile collector of node_exporter or a log shipper.
+	// The file is replaced atomically, failures to write it are only logged
+	StatsFilePath string
+
+	// CacheRetention enables pruning of the cache after every scan: entries of directories
+	// not written for longer than CacheRetention and summaries of scans finished before it are removed
+	// (see IncrementalStorage.PruneExpired), e.g. of deleted or moved trees never scanned again.
+	// Entries of the scanned tree are kept regardless. The summary fast path (TrustRootMtime)
+	// does not prune. 0 disables the pruning
+	CacheRetention time.Duration
+
+	// PruneStale removes after every scan entries of directories which the scan found gone
+	// from the filesystem or no longer directories, together with the entries of their descendants.
+	// Only the directories missing in the listings of their parents are pruned, entries outside
+	// of the scanned tree and entries of directories removed before the previous scan are left
+	// to IncrementalStorage.PruneStale
+	PruneStale bool
+
+	// ScanLabel is a free-form label of the scans, e.g. "pre-cleanup", stored with the summary
+	// of the scanned directory (see RootSummary) and in the summary of the scan (see StatsFile)
+	ScanLabel string
+
+	// PostScanHook is called after every scan with its summary, the same as written to StatsFilePath,
+	// before waiters of the scan are released, e.g. to run a user command.
+	// Its error is logged and kept in ScanResult.HookErr, the scan is valid regardless
+	PostScanHook func(summary *StatsFile) error
+
+	// MemoryMode selects how GC runs during the scan (MemoryAggressive by default).
+	// MemoryBalanced keeps GC enabled at GCPercent (0 = DefaultBalancedGCPercent),
+	// which lowers the peak of memory on hosts where disabling GC leads to OOM kills.
+	// The constGC argument of AnalyzeDir selects MemoryConstant regardless of it
+	MemoryMode MemoryMode
+	GCPercent  int
+
+	// SpecialFileSizes counts FIFOs, sockets and device nodes with the size reported by stat.
+	// They are counted with zero size by default, same as by the other analyzers
+	SpecialFileSizes bool
+
+	// BirthTime reads the birth time of every item scanned from the filesystem with an extra
+	// statx call (Linux only). Items loaded from the cache keep the birth time they were cached with
+	BirthTime bool
+
+	// CountCacheDir counts the cache directory (StoragePath) when it is located in the scanned tree.
+	// It is left out by default, as the writes of every scan would change the tree
+	// and its parent would be scanned again every time
+	CountCacheDir bool
+
+	// AllowVolatileCache silences the warning about the cache directory located on tmpfs, ramfs
+	// or in a location cleaned on reboot (e.g. /tmp). Such cache is lost with every reboot,
+	// so the first scan after it is always cold. The cache is used either way
+	AllowVolatileCache bool
+
+	// RetryCount is the number of retries of stats and reads of directories failing with transient
+	// errors (EIO, ESTALE, EAGAIN, EINTR, ETIMEDOUT), e.g. on flaky NFS. The error is recorded
+	// only when all the retries fail. The delay before the first retry is RetryDelay
+	// (0 = DefaultRetryDelay), it doubles with every further one
+	RetryCount int
+	RetryDelay time.Duration
+
+	// HashVerifyPrefixes lists directories whose mtime is not trustworthy, e.g. stores of artifacts
+	// copied by tools preserving timestamps. Fingerprint of the direct children (names, sizes
+	// and mtimes, not the content of files) of the directories and their subdirectories is stored
+	// in their cache entries and compared on cache hits, the directory is scanned again if it differs.
+	// It costs a listing and stats of the children of every such directory, so it should be used
+	// only for a few critical paths
+	HashVerifyPrefixes []string
+
+	// MinCacheDirSize and MinCacheChildren skip cache entries of small directories whose scan
+	// costs less than loading of their entry, which shrinks the cache of trees with many of them.
+	// A directory is not cached when its size (of the whole subtree) is below MinCacheDirSize
+	// and it has less direct children than MinCacheChildren, a zero threshold is not checked.
+	// Such directories are scanned every time, their larger subdirectories are still loaded
+	// from the cache, and they are not counted as cache misses (see CacheStats.SmallDirsNotCached).
+	// Both 0 (default) cache all directories
+	MinCacheDirSize  int64
+	MinCacheChildren int
+
+	// TrustUnlisted loads directories having a cache entry without stat and without comparing
+	// their mtime, trusting that all changed directories were passed to InvalidatePaths
+	// (e.g. by a feed of changes of a storage appliance). Changes of other directories are missed
+	// until they are invalidated, so it must be used only with a complete feed of changes
+	TrustUnlisted bool
+
+	// MinItemSize folds children smaller than MinItemSize (both apparent size and usage)
+	// of every directory into one FoldedItems, so only the larger items are held in memory.
+	// Totals of the directories stay exact. The cache entries keep all the children,
+	// so the floor can be changed without scanning again. 0 (default) folds nothing
+	MinItemSize int64
+
+	// ValidationMode selects how cache entries are checked (ValidateMtime by default).
+	// ValidateMtimeCount lists every directory, also subdirectories of unchanged ones,
+	// and scans it again if names of its children differ from the cache entry
+	// (see CacheStats.ValidationReads and ValidationRescans)
+	ValidationMode ValidationMode
+
+	// Diff remembers totals of the cache entries replaced by the scan, so ComputeDiff can list
+	// directories which changed since the previous scan. It holds the totals of all the cached
+	// directories of the scanned tree in memory
+	Diff bool
+
+	// DiffFromLabel makes ComputeDiff compare the latest scan with this label (see ScanLabel)
+	// with the latest scan labelled DiffToLabel, or with the last scan if it is empty,
+	// instead of the cache entries. The scans are read from the scan history (see ListScanHistory),
+	// so only the top levels of the scanned tree are compared
+	DiffFromLabel string
+	DiffToLabel   string
+
+	// StorageOptions tune the memory used by the cache database, e.g. LowMemory for small devices
+	StorageOptions
 }
 
 // CreateIncrementalAnalyzer returns a new IncrementalAnalyzer instance
 func CreateIncrementalAnalyzer(opts IncrementalOptions) *IncrementalAnalyzer {
-	return &IncrementalAnalyzer{
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &IncrementalAnalyzer{
 		storagePath:   opts.StoragePath,
 		cacheMaxAge:   opts.CacheMaxAge,
 		forceFullScan: opts.ForceFullScan,
+		checkCrash:    opts.CheckAfterCrash,
+		verifyLinks:   opts.VerifySymlinks,
+		followDirs:    opts.FollowDirSymlinks,
+		unsorted:      opts.UnsortedChildren,
+		checkDevice:   opts.RescanOnDeviceChange,
+		noCross:       opts.NoCross,
+		trustRoot:     opts.TrustRootMtime,
+		specialSizes:  opts.SpecialFileSizes,
+		birthTimes:    opts.BirthTime,
+		futureSkew:    opts.FutureSkew,
+		trustAhead:    opts.TrustCachedAhead,
+		backwardsSkew: opts.BackwardsSkew,
+		traceLimit:    -1,
+		pathLimit:     opts.MaxReportedPaths,
+		maxDepth:      opts.MaxDepth,
+		excludeFiles:  opts.ExcludeFiles,
+		prefetchSize:  opts.PrefetchSize,
+		workerCount:   opts.Workers,
+		statsFile:     opts.StatsFilePath,
+		scanLabel:     opts.ScanLabel,
+		retention:     opts.CacheRetention,
+		pruneStale:    opts.PruneStale,
+		postScan:      opts.PostScanHook,
+		countCacheDir: opts.CountCacheDir,
+		volatileOK:    opts.AllowVolatileCache,
+		memoryMode:    opts.MemoryMode,
+		gcPercent:     opts.GCPercent,
+		fingerprint:   optionsFingerprint(opts.ExcludeFiles, opts.NoCross),
 		throttle:      NewIOThrottle(opts.MaxIOPS, opts.IODelay),
-		stats:         NewCacheStats(),
-		progress: &common.CurrentProgress{
-			ItemCount: 0,
-			TotalSize: int64(0),
-		},
-		progressChan:     make(chan common.CurrentProgress, 1),
-		progressOutChan:  make(chan common.CurrentProgress, 1),
-		progressDoneChan: make(chan struct{}),
-		doneChan:         make(common.SignalGroup),
-		wait:             (&WaitGroup{}).Init(),
+		pump:          newProgressPump(),
+		doneChan:      make(common.SignalGroup),
+		ctx:           ctx,
+		cancel:        cancel,
+		wait:          (&WaitGroup{}).Init(),
+		identify:      getDirIdentity,
+		events:        newWriteEvents(),
+		retryCount:    opts.RetryCount,
+		retryDelay:    opts.RetryDelay,
+		statPath:      os.Stat,
+		hashPrefixes:  opts.HashVerifyPrefixes,
+		refreshDirs:   opts.RefreshPaths,
+		minCacheSize:  opts.MinCacheDirSize,
+		minCacheItems: opts.MinCacheChildren,
+		trustUnlisted: opts.TrustUnlisted,
+		minItemSize:   opts.MinItemSize,
+		validation:    opts.ValidationMode,
+		diffing:       opts.Diff,
+		fromLabel:     opts.DiffFromLabel,
+		toLabel:       opts.DiffToLabel,
+		recording:     opts.RecordDecisions,
+		storageOpts:   opts.StorageOptions,
+	}
+	a.listDir = a.readDir
+	if a.retryDelay <= 0 {
+		a.retryDelay = DefaultRetryDelay
+	}
+	if a.pathLimit <= 0 {
+		a.pathLimit = DefaultMaxReportedPaths
+	}
+	if a.maxDepth <= 0 {
+		a.maxDepth = DefaultMaxDepth
+	}
+	if a.prefetchSize == 0 {
+		a.prefetchSize = DefaultPrefetchSize
+	}
+	if a.workerCount <= 0 {
+		a.workerCount = runtime.GOMAXPROCS(0)
+	}
+	a.stats = newCacheStats(a.pathLimit)
+	if opts.TraceDecisions {
+		a.traceLimit = opts.TraceLimit
+	}
+	if opts.SampleThreshold > 0 {
+		a.sampleAbove = opts.SampleThreshold
+		a.sampleSize = opts.SampleSize
+		if a.sampleSize <= 0 {
+			a.sampleSize = DefaultSampleSize
+		}
+		a.sampleSize = min(a.sampleSize, opts.SampleThreshold)
 	}
+	return a
 }
 
-// GetProgressChan returns channel for getting progress
+// GetProgressChan returns channel for getting progress of the running (or the next) scan.
+// The channel is closed when the scan ends
 func (a *IncrementalAnalyzer) GetProgressChan() chan common.CurrentProgress {
-	return a.progressOutChan
+	a.m.Lock()
+	defer a.m.Unlock()
+	return a.pump.out
+}
+
+// CurrentProgress returns the progress of the running (or the next) scan received so far,
+// the same as the last update sent to the progress channel. Unlike the channel
+// it can be read by any number of goroutines, e.g. for periodic reporting
+func (a *IncrementalAnalyzer) CurrentProgress() common.CurrentProgress {
+	a.m.Lock()
+	pump := a.pump
+	a.m.Unlock()
+	return pump.current()
 }
 
 // GetDone returns channel for checking when analysis is done
 func (a *IncrementalAnalyzer) GetDone() common.SignalGroup {
+	a.m.Lock()
+	defer a.m.Unlock()
 	return a.doneChan
 }
 
@@ -91,13 +484,53 @@ func (a *IncrementalAnalyzer) SetShowAnnexedSize(v bool) {
 
 // ResetProgress resets progress tracking
 func (a *IncrementalAnalyzer) ResetProgress() {
-	a.progress = &common.CurrentProgress{}
-	a.progressChan = make(chan common.CurrentProgress, 1)
-	a.progressOutChan = make(chan common.CurrentProgress, 1)
-	a.progressDoneChan = make(chan struct{})
+	// The running scan (if any) keeps its own pump and done channel
+	a.m.Lock()
+	a.pump = newProgressPump()
 	a.doneChan = make(common.SignalGroup)
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	a.m.Unlock()
+	a.result = nil
 	a.wait = (&WaitGroup{}).Init()
-	a.stats = NewCacheStats()
+	a.stats = newCacheStats(a.pathLimit)
+}
+
+// Cancel stops the running (or the next) scan.
+// Directories read only partially are not stored in the cache
+func (a *IncrementalAnalyzer) Cancel() {
+	a.m.Lock()
+	cancel := a.cancel
+	a.m.Unlock()
+	cancel()
+}
+
+// AnalyzeDirWithContext analyzes given path the same as AnalyzeDir, the scan is cancelled
+// (see Cancel) once ctx is done. Directories read only partially get the 'x' flag
+// and the result has the ScanCancelled status. The analyzer stays bound to ctx
+// until ResetProgress, a Cancel called before the scan still applies
+func (a *IncrementalAnalyzer) AnalyzeDirWithContext(
+	ctx context.Context, path string, ignore common.ShouldDirBeIgnored, constGC bool,
+) fs.Item {
+	a.m.Lock()
+	if a.ctx.Err() == nil {
+		// the replaced context is released, nothing waits for it yet
+		previous := a.cancel
+		a.ctx, a.cancel = context.WithCancel(ctx)
+		previous()
+	}
+	a.m.Unlock()
+	return a.AnalyzeDir(path, ignore, constGC)
+}
+
+// GetScanResult returns outcome of the last scan.
+// It returns nil until the done channel is broadcast
+func (a *IncrementalAnalyzer) GetScanResult() *ScanResult {
+	return a.result
+}
+
+// IsScanning returns true while AnalyzeDir is running
+func (a *IncrementalAnalyzer) IsScanning() bool {
+	return a.scanning.Load()
 }
 
 // GetCacheStats returns cache statistics
@@ -105,26 +538,151 @@ func (a *IncrementalAnalyzer) GetCacheStats() *CacheStats {
 	return a.stats
 }
 
-// AnalyzeDir analyzes given path with incremental caching
+// GetThrottle returns the I/O throttle of the analyzer, nil if throttling is disabled
+func (a *IncrementalAnalyzer) GetThrottle() *IOThrottle {
+	return a.throttle
+}
+
+// withStorage opens the cache for fn outside of a scan
+func (a *IncrementalAnalyzer) withStorage(path string, fn func(*IncrementalStorage) error) error {
+	if a.IsScanning() {
+		return ErrBusy
+	}
+
+	storage := NewIncrementalStorage(a.storagePath, path)
+	storage.SetStorageOptions(a.storageOpts)
+	closeFn, err := storage.Open()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	return fn(storage)
+}
+
+// AnalyzeDir analyzes given path with incremental caching.
+// It never returns nil: when the scan fails (the cache can't be opened, the root
+// can't be read, the scan panics), the returned directory has the '!' flag and
+// GetScanResult describes the failure. On every path the done signal group is broadcast
+// exactly once and the progress channel is closed, so waiters are always released.
+// The package reports problems only by the result and the log, it never writes to stderr
 func (a *IncrementalAnalyzer) AnalyzeDir(
 	path string, ignore common.ShouldDirBeIgnored, constGC bool,
 ) fs.Item {
-	if !constGC {
-		defer debug.SetGCPercent(debug.SetGCPercent(-1))
-		go manageMemoryUsage(a.doneChan)
+	// The cache is keyed by the path, so "." and the absolute path of the same directory
+	// must be the same key regardless of the working directory
+	given := path
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	return a.runScan(path, constGC, func(startTime time.Time, finish func(*ScanResult)) fs.Item {
+		a.storage = NewIncrementalStorage(a.storagePath, path)
+		a.storage.SetStorageOptions(a.storageOpts)
+		a.storage.forward = a.events
+
+		if ignore != nil {
+			ignore = ignoringAsGiven(ignore, path, given)
+		}
+		if dir, result := a.unscannedRoot(path, ignore); dir != nil {
+			a.stats.ScanEndTime = time.Now()
+			a.stats.TotalScanTime = a.stats.ScanEndTime.Sub(startTime)
+			finish(result)
+			return dir
+		}
+
+		closeFn, err := a.openStorage()
+		if err != nil {
+			// Signal completion even on error to prevent hanging
+			finish(&ScanResult{Status: ScanFailed, Err: err})
+			return failedDir(path)
+		}
+		defer closeFn()
+		defer a.beginScan()()
+
+		dir, result := a.scanRoot(path, ignore, startTime)
+		if a.retention > 0 {
+			a.pruneExpired([]string{path}, startTime)
+		}
+		finish(result)
+		return dir
+	})
+}
+
+// runScan runs scan bound to the current progress pump and done channel.
+// The done signal group is broadcast once scan calls finish, or when it returns
+// or panics without calling it. The scan is reported as of root
+func (a *IncrementalAnalyzer) runScan(
+	root string, constGC bool, scan func(startTime time.Time, finish func(*ScanResult)) fs.Item,
+) (item fs.Item) {
+	// ResetProgress replaces the channels, so bind this scan to the current ones
+	a.m.Lock()
+	doneChan := a.doneChan
+	pump := a.pump
+	a.m.Unlock()
+	a.scanPump = pump
+
+	a.scanning.Store(true)
+	peak := startPeakTracker()
+
+	var finishOnce sync.Once
+	finish := func(result *ScanResult) {
+		finishOnce.Do(func() {
+			a.snapshot.stop()
+			a.stats.SetPeakHeap(peak.finish())
+			result.Stats = a.stats.Snapshot()
+			result.Label = a.scanLabel
+			summary := newStatsFile(root, result)
+			if a.statsFile != "" {
+				writeStatsFile(a.statsFile, summary)
+			}
+			if a.postScan != nil {
+				a.runPostScan(summary, result)
+			}
+			a.result = result
+			a.scanning.Store(false)
+			pump.stop()
+			doneChan.Broadcast()
+		})
 	}
 
+	memoryMode := a.memoryMode
+	if constGC {
+		memoryMode = MemoryConstant
+	}
+	defer setMemoryMode(memoryMode, a.gcPercent, doneChan)()
+
 	startTime := time.Now()
 	a.stats.ScanStartTime = startTime
 
 	// Start progress updates early to prevent hanging if there's an error
-	go a.updateProgress()
+	pump.start()
+
+	// Release waiters and helper goroutines even if the scan panics,
+	// the panic is turned into a failed scan
+	defer func() {
+		r := recover()
+		if r == nil {
+			finish(&ScanResult{Status: ScanFailed, Err: errors.New("scan aborted")})
+			return
+		}
+		err := &ScanPanicError{Value: r, Stack: debug.Stack()}
+		log.Errorf("%v\n%s", err, err.Stack)
+		finish(&ScanResult{Status: ScanFailed, Err: err})
+		item = failedDir(root)
+	}()
+
+	return scan(startTime, finish)
+}
 
-	a.storage = NewIncrementalStorage(a.storagePath, path)
+// openStorage opens a.storage, the failure is logged with hints how to fix it
+func (a *IncrementalAnalyzer) openStorage() (func(), error) {
 	closeFn, err := a.storage.Open()
-	if err != nil {
-		// Return a descriptive error directory instead of nil
-		errMsg := fmt.Sprintf(`Failed to initialize incremental cache at %s: %v
+	if err == nil {
+		return closeFn, nil
+	}
+
+	errMsg := fmt.Sprintf(`Failed to initialize incremental cache at %s: %v
 
 Possible causes and solutions:
   1. Directory doesn't exist
@@ -148,44 +706,255 @@ To specify a different cache location, use:
 For more help, see: https://github.com/dundee/gdu#incremental-caching
 `, a.storagePath, err, a.storagePath, a.storagePath, a.storagePath)
 
-		log.Error(errMsg)
-		fmt.Fprintf(os.Stderr, "%s\n", errMsg)
+	log.Error(errMsg)
+	return nil, fmt.Errorf("initializing incremental cache at %s: %w", a.storagePath, err)
+}
+
+// unscannedRoot returns the tree of path which is not scanned with the cache, nil if it is scanned.
+// A file given by mistake is shown as it is and a directory matching ignore is dropped
+// the same way as an ignored subdirectory would be in the scan of its parent
+func (a *IncrementalAnalyzer) unscannedRoot(path string, ignore common.ShouldDirBeIgnored) (*Dir, *ScanResult) {
+	if info, err := os.Lstat(path); err == nil && !isDirTarget(path, info) {
+		return a.analyzeFile(path, info), &ScanResult{Status: ScanCompleted, SingleFile: true}
+	}
+	if ignore != nil && ignore(rootName(path), path) {
+		return ignoredRootDir(path), &ScanResult{Status: ScanCompleted, RootIgnored: true}
+	}
+	return nil, nil
+}
+
+// beginScan prepares the state shared by the roots scanned with the opened storage
+// and returns the function stopping its helpers
+func (a *IncrementalAnalyzer) beginScan() func() {
+	a.prefetch = newPrefetcher(a.storage, a.prefetchSize)
+	a.workers = nil
+	// followed links are scanned one by one, so the link holding the subtree is the one the serial scan reaches first
+	if !a.followDirs {
+		a.workers = newWorkerPool(a.workerCount)
+	}
 
-		// Signal completion even on error to prevent hanging
-		a.progressDoneChan <- struct{}{}
-		a.doneChan.Broadcast()
+	if a.checkCrash {
+		a.checkCrashedScan()
+	}
+	a.checkVolatileStorage()
+	a.futureLogged.Store(false)
+	a.aheadLogged.Store(false)
+	a.backwardsLogged.Store(false)
+	a.versionLogged.Store(false)
+	a.depthLogged.Store(false)
+	a.provenance = currentProvenance()
+	a.stats.SetProvenance(a.provenance.hostname, a.provenance.appVersion)
+	if a.traceLimit >= 0 {
+		a.trace = newDecisionTrace(a.traceLimit)
+	}
+	if a.recording {
+		a.decisions = newDecisionMap()
+	}
+	if a.diffing {
+		a.diff = newScanDiff()
+	}
+	a.labelDiff = nil
+	a.diffErr = nil
+	if a.pruneStale {
+		a.removed = &removedDirs{}
+	}
+	a.invalidated = len(a.invalidate) > 0
+	a.applyInvalidations()
 
-		return &Dir{
-			File: &File{
-				Name: filepath.Base(path),
-				Flag: '!',
-			},
-			BasePath:  filepath.Dir(path),
-			ItemCount: 0,
-			Files:     make(fs.Files, 0),
+	return func() {
+		a.prefetch.stop()
+	}
+}
+
+// scanRoot scans the directory at path with the opened storage
+// and returns its tree together with the outcome of the scan
+func (a *IncrementalAnalyzer) scanRoot(
+	path string, ignore common.ShouldDirBeIgnored, startTime time.Time,
+) (*Dir, *ScanResult) {
+	a.ignoreDir = a.ignoringCacheDir(path, ignore)
+	a.hashVerify = subtreePaths(path, a.hashPrefixes)
+	a.refresh = subtreePaths(path, a.refreshDirs)
+	a.rootDev = a.scannedDevice(path)
+	a.visited = make(map[dirIdentity]string)
+	a.mounts = make(map[uint64]string)
+
+	// the summary would skip the hash-verified, the refreshed, the invalidated and the validated directories
+	// and the new mount points
+	if a.trustRoot && !a.forceFullScan && len(a.hashVerify) == 0 && len(a.refresh) == 0 && !a.invalidated &&
+		a.validation == ValidateMtime && a.rootDev == 0 {
+		if dir := a.summaryHit(path); dir != nil {
+			a.loadAnnotations(path, dir)
+			a.tagCacheDir(path, dir)
+			a.stats.ScanEndTime = time.Now()
+			a.stats.TotalScanTime = a.stats.ScanEndTime.Sub(startTime)
+			a.stats.Storage = a.storage.Metrics()
+			result := a.scanResult(path, dir)
+			if summary, err := a.storage.LoadRootSummary(path); err == nil && summary != nil {
+				result.Generation = summary.Generation
+			}
+			a.compareLabels(path)
+			return dir, result
 		}
 	}
-	defer closeFn()
 
-	a.ignoreDir = ignore
+	if err := a.storage.MarkScanStarted(); err != nil {
+		a.stats.IncrementCacheErrors()
+		log.Printf("Warning: Failed to mark scan as started: %v", err)
+	}
 
-	dir := a.processDir(path)
+	a.snapshot.start(path)
+	dir := a.processDir(&scanBranch{}, path)
 
 	a.wait.Wait()
+	a.loadAnnotations(path, dir)
+	a.tagCacheDir(path, dir)
+	if a.trace != nil && a.trace.Dropped() > 0 {
+		log.Printf("Decision trace kept %d entries, %d more were dropped (see IncrementalOptions.TraceLimit)",
+			a.trace.Len(), a.trace.Dropped())
+	}
 
-	a.progressDoneChan <- struct{}{}
-	a.doneChan.Broadcast()
+	if err := a.storage.MarkScanFinished(); err != nil {
+		a.stats.IncrementCacheErrors()
+		log.Printf("Warning: Failed to mark scan as finished: %v", err)
+	}
 
 	a.stats.ScanEndTime = time.Now()
 	a.stats.TotalScanTime = a.stats.ScanEndTime.Sub(startTime)
+	// the metrics cover the scan, not the loads of the summary bookkeeping
+	a.stats.Storage = a.storage.Metrics()
+	if a.pruneStale {
+		a.pruneRemoved(path)
+	}
+	a.diff.collect(dir)
+	result := a.scanResult(path, dir)
+	a.storeRootSummary(path, result)
+	a.recordHistory(dir, result)
+	a.compareLabels(path)
+	return dir, result
+}
+
+// scanResult determines status of the finished scan of path
+func (a *IncrementalAnalyzer) scanResult(path string, dir *Dir) *ScanResult {
+	stats := a.stats.Snapshot()
+	result := &ScanResult{
+		Size:        dir.Size,
+		Usage:       dir.Usage,
+		ErrorCount:  dir.ErrorCount,
+		CacheErrors: stats.CacheErrors + stats.CorruptedEntries,
+	}
+	switch {
+	case a.ctx.Err() != nil:
+		result.Status = ScanCancelled
+	case dir.Flag == '!':
+		result.Status = ScanFailed
+		result.Err = fmt.Errorf("directory %s could not be read", path)
+	case dir.ErrorCount > 0:
+		result.Status = ScanCompletedWithErrors
+	default:
+		result.Status = ScanCompleted
+	}
+	return result
+}
+
+// processDir processes a single directory with incremental caching logic in the branch b of the scan
+func (a *IncrementalAnalyzer) processDir(b *scanBranch, path string) *Dir {
+	return a.accountDir(b, path, func() (*Dir, CacheDecision, uint64) {
+		if dir, dev, ok := a.resolveTrusted(b, path); ok {
+			return dir, DecisionTrusted, dev
+		}
+		dir, decision, stat := a.resolveDir(b, path, false)
+		return dir, decision, a.deviceOf(stat)
+	})
+}
+
+// processListedDir processes a subdirectory read from the listing of its parent.
+// It returns nil if the directory was removed since the listing was read
+func (a *IncrementalAnalyzer) processListedDir(b *scanBranch, path string) *Dir {
+	if a.beforeSubdir != nil {
+		a.beforeSubdir(path)
+	}
+	return a.accountDir(b, path, func() (*Dir, CacheDecision, uint64) {
+		if dir, dev, ok := a.resolveTrusted(b, path); ok {
+			return dir, DecisionTrusted, dev
+		}
+		dir, decision, stat := a.resolveDir(b, path, true)
+		return dir, decision, a.deviceOf(stat)
+	})
+}
+
+// accountDir returns the directory got by resolve and sends its progress.
+// It is the only place sending progress: the directory's totals not yet reported
+// for its subdirectories are sent once it is done, so the reported totals match
+// the resulting tree even if some directory was processed twice.
+// The directory is added to the statistics of the device returned by resolve the same way.
+// The totals and the time are tracked by the branch b of the scan running resolve
+func (a *IncrementalAnalyzer) accountDir(
+	b *scanBranch, path string, resolve func() (*Dir, CacheDecision, uint64),
+) *Dir {
+	reported := b.reported
+	accounted := b.accounted
+	start := time.Now()
+
+	if b.depth > a.maxDepth {
+		resolve = func() (*Dir, CacheDecision, uint64) {
+			return a.tooDeepDir(path)
+		}
+	}
+	b.depth++
+	defer func() { b.depth-- }()
+
+	dir, decision, dev := resolve()
+	if dir == nil {
+		return nil
+	}
+
+	// Time spent in subdirectories is accounted by their own accountDir calls.
+	// Subdirectories processed by the workers at the same time may have taken longer together
+	took := max(time.Since(start)-(b.accounted-accounted), 0)
+	b.accounted += took
+
+	progress := common.CurrentProgress{
+		CurrentItemName: path,
+		ItemCount:       dir.ItemCount - (b.reported.ItemCount - reported.ItemCount),
+		TotalSize:       dir.Size - (b.reported.TotalSize - reported.TotalSize),
+	}
+	b.reported.ItemCount += progress.ItemCount
+	b.reported.TotalSize += progress.TotalSize
+	a.scanPump.send(progress)
+	a.addDeviceStats(path, dev, decision, progress.TotalSize, took)
 
 	return dir
 }
 
-// processDir processes a single directory with incremental caching logic
-func (a *IncrementalAnalyzer) processDir(path string) *Dir {
+// ignoringAsGiven returns ignore called with the paths below root in the form
+// the other analyzers pass them: joined to the scanned path as it was given (e.g. relative),
+// not to its absolute form root used by the cache. So ignore patterns match the same directories
+func ignoringAsGiven(ignore common.ShouldDirBeIgnored, root, given string) common.ShouldDirBeIgnored {
+	if given == root {
+		return ignore
+	}
+	return func(name, path string) bool {
+		if inSubtree(path, root) {
+			path = filepath.Join(given, path[len(root):])
+		}
+		return ignore(name, path)
+	}
+}
+
+// resolveDir loads the directory from the cache or scans it.
+// Besides the directory it returns the decision made and the stat of the directory (nil on error).
+// A listed directory (read from the listing of its parent) which does not exist anymore
+// and a directory on another filesystem with NoCross are returned as nil
+func (a *IncrementalAnalyzer) resolveDir(b *scanBranch, path string, listed bool) (*Dir, CacheDecision, os.FileInfo) {
 	// Step 1: Get current filesystem state
-	stat, err := os.Stat(path)
+	stat, err := a.statRetried(path)
+	if err != nil && listed && os.IsNotExist(err) {
+		// Removed after the parent was read, e.g. by a build running in the tree
+		log.Debugf("Directory vanished during scan: %s", path)
+		a.traceDecision(path, DecisionVanished, nil, nil)
+		a.stats.IncrementVanishedDuringScan()
+		return nil, DecisionVanished, nil
+	}
 	if err != nil {
 		// Handle path errors with specific logging
 		if os.IsNotExist(err) {
@@ -195,124 +964,296 @@ func (a *IncrementalAnalyzer) processDir(path string) *Dir {
 		} else {
 			log.Printf("Error stating directory %s: %v", path, err)
 		}
-		return a.createErrorDir(path, err)
+		a.traceDecision(path, DecisionError, nil, nil)
+		return a.createErrorDir(path, err), DecisionError, nil
 	}
 	currentMtime := stat.ModTime()
 
+	// Other filesystems are left out with NoCross
+	if a.otherDevice(stat) {
+		a.leaveOutMount(path)
+		a.traceDecision(path, DecisionMount, nil, stat)
+		return nil, DecisionMount, stat
+	}
+
+	// The same directory reached by another path (bind mount) is added only as a reference
+	if canonical, ok := a.visitDir(path, stat); ok {
+		a.traceDecision(path, DecisionDuplicate, nil, stat)
+		return a.createDuplicateDir(path, canonical, stat), DecisionDuplicate, stat
+	}
+
 	// Step 2: Check if force full scan is enabled
 	if a.forceFullScan {
+		a.rememberEntry(path)
+		a.traceDecision(path, DecisionForced, nil, stat)
 		a.stats.IncrementDirsRescanned()
-		return a.scanAndCache(path, currentMtime)
+		return a.scanAndCache(b, path, stat, nil), DecisionForced, stat
+	}
+	// Only the listed subtrees are forced
+	if a.isRefreshed(path) {
+		a.rememberEntry(path)
+		a.traceDecision(path, DecisionForced, nil, stat)
+		a.stats.IncrementDirsRescanned()
+		a.stats.IncrementTotalDirs()
+		return a.scanAndCache(b, path, stat, nil), DecisionForced, stat
 	}
 
 	// Step 3: Try to load from cache
 	cached, err := a.storage.LoadDirMetadata(path)
 	if err != nil {
 		// Cache miss or error - use fallback handler
-		return a.handleCacheError(path, currentMtime, err)
+		a.traceDecision(path, DecisionMiss, nil, stat)
+		return a.handleCacheError(b, path, stat, err), DecisionMiss, stat
+	}
+	a.checkProvenance(path, cached)
+	a.diff.remember(cached)
+
+	// The directory was a duplicate in the previous scan, but it is not anymore
+	if cached.DuplicateOf != "" {
+		a.traceDecision(path, DecisionChanged, cached, stat)
+		a.stats.IncrementDirsRescanned()
+		a.stats.IncrementTotalDirs()
+		return a.scanAndCache(b, path, stat, nil), DecisionChanged, stat
+	}
+
+	// The entry was written with other excluded file patterns or NoCross
+	if cached.Fingerprint != a.fingerprint {
+		a.traceDecision(path, DecisionOptions, cached, stat)
+		a.stats.IncrementDirsRescanned()
+		a.stats.IncrementTotalDirs()
+		return a.scanAndCache(b, path, stat, cached), DecisionOptions, stat
+	}
+
+	// The entry belongs to another filesystem mounted at the same path before
+	if a.deviceChanged(cached, stat) {
+		a.traceDecision(path, DecisionChanged, cached, stat)
+		a.stats.IncrementDirsRescanned()
+		a.stats.IncrementTotalDirs()
+		return a.scanAndCache(b, path, stat, nil), DecisionChanged, stat
+	}
+
+	// Estimates are replaced by exact scan
+	if cached.Estimate != nil && a.sampleAbove == 0 {
+		a.traceDecision(path, DecisionEstimated, cached, stat)
+		a.stats.IncrementDirsRescanned()
+		a.stats.IncrementTotalDirs()
+		return a.scanAndCache(b, path, stat, cached), DecisionEstimated, stat
+	}
+
+	// Timestamps in the future can't be trusted, the entry would never expire
+	if a.inFuture(path, cached.CachedAt, currentMtime) {
+		a.traceDecision(path, DecisionFuture, cached, stat)
+		a.stats.IncrementDirsRescanned()
+		a.stats.IncrementTotalDirs()
+		return a.scanAndCache(b, path, stat, cached), DecisionFuture, stat
+	}
+
+	// The entry was written before the system clock was stepped backwards,
+	// its age is unknown, so it is expired unless such entries are trusted
+	if a.cachedAhead(path, cached.CachedAt) && !a.trustAhead {
+		a.traceDecision(path, DecisionExpired, cached, stat)
+		a.stats.IncrementCacheExpired()
+		a.stats.IncrementDirsRescanned()
+		a.stats.IncrementTotalDirs()
+		return a.scanAndCache(b, path, stat, cached), DecisionExpired, stat
 	}
 
 	// Step 4: Validate cache age if max age is set
 	if a.cacheMaxAge > 0 {
-		age := time.Since(cached.CachedAt)
-		if age > a.cacheMaxAge {
+		if cacheAge(cached.CachedAt) > a.cacheMaxAge {
+			a.traceDecision(path, DecisionExpired, cached, stat)
 			a.stats.IncrementCacheExpired()
 			a.stats.IncrementDirsRescanned() // Expired cache requires rescan
 			a.stats.IncrementTotalDirs()
-			return a.scanAndCache(path, currentMtime)
+			return a.scanAndCache(b, path, stat, cached), DecisionExpired, stat
 		}
 	}
 
-	// Step 5: Compare mtime to determine if directory changed
-	if !cached.Mtime.Equal(currentMtime) {
+	// Entries imported from the legacy storage have no mtime, they are verified by listing
+	if cached.Mtime.IsZero() && a.verifyImported(cached, stat) {
+		a.traceDecision(path, DecisionVerified, cached, stat)
+		a.stats.IncrementCacheHits()
+		a.stats.IncrementTotalDirs()
+		a.stats.AddBytesFromCache(cached.Size)
+		return a.rebuildFromCache(b, cached), DecisionVerified, stat
+	}
+
+	// Mtime moved backwards, the directory was restored from a backup or the clock was stepped
+	if a.mtimeBackwards(path, cached.Mtime, currentMtime) {
+		a.traceDecision(path, DecisionChanged, cached, stat)
+		a.stats.IncrementClockSkewRescans()
+		a.stats.IncrementDirsRescanned()
+		a.stats.IncrementTotalDirs()
+		return a.scanAndCache(b, path, stat, cached), DecisionChanged, stat
+	}
+
+	// Step 5: Compare mtime and ctime (changed also by chmod or chown) to determine if directory changed.
+	// Entries written without ctime are compared by mtime only
+	if !cached.Mtime.Equal(currentMtime) || ctimeChanged(cached, stat) {
 		// Directory modified - rescan
+		a.traceDecision(path, DecisionChanged, cached, stat)
+		a.stats.IncrementDirsRescanned()
+		a.stats.IncrementTotalDirs()
+		return a.scanAndCache(b, path, stat, cached), DecisionChanged, stat
+	}
+
+	// Attribute caching of NFS can delay the change of mtime, the listing shows it
+	if a.validation == ValidateMtimeCount && a.listingChanged(cached) {
+		a.traceDecision(path, DecisionListing, cached, stat)
+		a.stats.IncrementValidationRescans()
 		a.stats.IncrementDirsRescanned()
 		a.stats.IncrementTotalDirs()
-		return a.scanAndCache(path, currentMtime)
+		return a.scanAndCache(b, path, stat, cached), DecisionListing, stat
+	}
+
+	// Timestamps preserved by copying tools hide the change, the fingerprint of the children shows it
+	if a.isHashVerified(path) && a.contentChanged(cached) {
+		a.traceDecision(path, DecisionContent, cached, stat)
+		a.stats.IncrementHashRescans()
+		a.stats.IncrementDirsRescanned()
+		a.stats.IncrementTotalDirs()
+		return a.scanAndCache(b, path, stat, cached), DecisionContent, stat
 	}
 
 	// Step 6: Cache hit - rebuild from cache
+	a.traceDecision(path, DecisionHit, cached, stat)
 	a.stats.IncrementCacheHits()
 	a.stats.IncrementTotalDirs()
 	a.stats.AddBytesFromCache(cached.Size)
-	return a.rebuildFromCache(cached)
+	return a.rebuildFromCache(b, cached), DecisionHit, stat
 }
 
 // createErrorDir creates a directory entry for errors
 func (a *IncrementalAnalyzer) createErrorDir(path string, _ error) *Dir {
-	// Send progress update to prevent hanging
-	a.progressChan <- common.CurrentProgress{
-		CurrentItemName: path,
-		ItemCount:       0,
-		TotalSize:       0,
-	}
-
 	return &Dir{
 		File: &File{
 			Name: filepath.Base(path),
 			Flag: '!',
 		},
-		BasePath:  filepath.Dir(path),
-		ItemCount: 0,
-		Files:     make(fs.Files, 0),
+		BasePath:   filepath.Dir(path),
+		ItemCount:  0,
+		ErrorCount: 1,
+		Files:      make(fs.Files, 0),
 	}
 }
 
-// scanAndCache performs a full scan of directory and caches the results
-func (a *IncrementalAnalyzer) scanAndCache(path string, currentMtime time.Time) *Dir {
+// scanAndCache performs a full scan of directory and caches the results.
+// previous is the cache entry from the previous generation (nil if there was none)
+func (a *IncrementalAnalyzer) scanAndCache(
+	b *scanBranch, path string, stat os.FileInfo, previous *IncrementalDirMetadata,
+) *Dir {
 	scanStartTime := time.Now()
 
 	// Perform actual filesystem scan
-	dir := a.performFullScan(path)
+	dir, counts := a.performFullScan(b, path, stat, previous)
+	a.stats.AddExcludedFiles(int64(counts.excluded), counts.excludedSize)
 
 	// Build metadata for caching
 	meta := &IncrementalDirMetadata{
 		Path:         path,
-		Mtime:        currentMtime,
+		Mtime:        stat.ModTime(),
+		Btime:        dir.Btime,
+		Ctime:        changeTime(stat),
 		Size:         dir.Size,
 		Usage:        dir.Usage,
+		SelfSize:     dir.SelfSize,
+		SelfUsage:    dir.SelfUsage,
 		ItemCount:    dir.ItemCount,
 		Flag:         dir.Flag,
+		ErrorCount:   counts.errors,
 		Files:        a.extractFileMetadata(dir),
 		CachedAt:     time.Now(),
 		ScanDuration: time.Since(scanStartTime),
+
+		SymlinkCount:       counts.symlinks,
+		BrokenSymlinkCount: counts.brokenSymlinks,
+
+		Estimate: dir.Estimate,
+
+		Fingerprint:   a.fingerprint,
+		ExcludedFiles: counts.excluded,
+		ExcludedSize:  counts.excludedSize,
+	}
+	if id, ok := a.identify(stat); ok {
+		meta.Dev, meta.Ino = id.dev, id.ino
+	}
+	if counts.hasher != nil {
+		if sum, err := counts.hasher.sum(); err == nil {
+			meta.ContentHash = sum
+		} else {
+			log.Printf("Warning: Failed to compute fingerprint of %s: %v", path, err)
+		}
+	}
+	a.provenance.stamp(meta)
+
+	// Partially read directory must not replace the previous cache entry
+	if a.ctx.Err() != nil {
+		return dir
+	}
+
+	// Small directories are scanned every time, an entry stored before they got small is removed
+	if a.skipsCaching(dir) {
+		a.stats.IncrementSmallDirsNotCached()
+		if previous != nil {
+			a.deleteSmallDirEntry(path)
+		}
+		a.stats.AddBytesScanned(dir.Size)
+		a.foldSmallItems(dir)
+		return dir
 	}
 
 	// Store in cache
 	err := a.storage.StoreDirMetadata(meta)
 	if err != nil {
+		a.stats.IncrementCacheErrors()
 		log.Printf("Warning: Failed to cache %s: %v", path, err)
 	}
 
 	a.stats.AddBytesScanned(dir.Size)
+	a.foldSmallItems(dir)
 	return dir
 }
 
-// performFullScan performs an actual filesystem scan of a directory
-func (a *IncrementalAnalyzer) performFullScan(path string) *Dir {
+// dirCounts holds counters of the direct children of a directory stored in its cache entry
+type dirCounts struct {
+	errors         int // children that could not be read (+1 if ReadDir failed)
+	symlinks       int
+	brokenSymlinks int // symlinks which could not be followed
+	estimated      int // estimated directories (subtree only, the cache entry holds the Estimate)
+	excluded       int // files left out by IncrementalOptions.ExcludeFiles
+	excludedSize   int64
+	hasher         *childHasher // children of a hash-verified directory, nil for the other ones
+}
+
+// performFullScan performs an actual filesystem scan of a directory with the given stat.
+// When previous is set, subdirectories missing from it are reported as new
+// and the ones missing from the listing as removed.
+// Besides the directory it returns counters of its direct children
+func (a *IncrementalAnalyzer) performFullScan(
+	b *scanBranch, path string, stat os.FileInfo, previous *IncrementalDirMetadata,
+) (*Dir, dirCounts) {
 	var (
 		file       *File
 		err        error
 		totalSize  int64
 		totalUsage int64
 		itemCount  int
+		counts     dirCounts
 		info       os.FileInfo
 	)
 
 	a.wait.Add(1)
 	defer a.wait.Done()
 
-	// Apply I/O throttling before directory read (if enabled)
-	if a.throttle != nil {
-		if err := a.throttle.Acquire(context.Background()); err != nil {
-			// This should only happen on context cancellation, which we don't use yet
-			log.Printf("Throttle error for %s: %v", path, err)
-		}
-	}
-
-	files, err := os.ReadDir(path)
+	files, err := a.listScannedDir(path)
 	if err != nil {
 		log.Printf("Error reading directory %s: %v", path, err)
+		counts.errors++
+	}
+	listed := err == nil
+	files = a.dropDuplicateNames(path, files)
+	if listed && a.isHashVerified(path) {
+		counts.hasher = newChildHasher(len(files))
 	}
 
 	dir := &Dir{
@@ -327,16 +1268,19 @@ func (a *IncrementalAnalyzer) performFullScan(path string) *Dir {
 	parent := &ParentDir{Path: path}
 
 	setDirPlatformSpecificAttrs(dir, path)
+	if a.birthTimes {
+		dir.Btime = birthTime(path)
+	}
 
 	// Get actual directory size from filesystem
-	dirInfo, statErr := os.Stat(path)
+	dirInfo, statErr := a.statRetried(path)
 	if statErr == nil {
 		totalSize = dirInfo.Size()
-		// Try to get actual usage from platform-specific attributes
-		// This will be set by setPlatformSpecificAttrs if available
-		setPlatformSpecificAttrs(&File{}, dirInfo)
-		// For directories, we use Size() as it reflects the directory metadata size
-		totalUsage = totalSize
+		// Usage of the directory itself comes from allocated blocks where the platform
+		// provides them, otherwise the apparent size is used
+		self := &File{Usage: totalSize}
+		setPlatformSpecificAttrs(self, dirInfo)
+		totalUsage = self.Usage
 	} else {
 		// Fallback to conservative estimate if stat fails
 		log.Printf("Warning: Could not stat directory %s, using default size: %v", path, statErr)
@@ -344,29 +1288,56 @@ func (a *IncrementalAnalyzer) performFullScan(path string) *Dir {
 		totalUsage = DefaultDirBlockSize
 	}
 
-	for _, f := range files {
+	previousDirs := previousDirNames(previous)
+	subtree := dirCounts{}
+	skipped := a.skippedFiles(files)
+	var sampledSizes, sampledUsages []int64
+	// Children in the order of the listing, subdirectories are filled in once they are processed
+	items := make([]fs.Item, len(files))
+	subdirs := make([]listedSubdir, 0)
+
+	for i, f := range files {
+		if a.ctx.Err() != nil {
+			break
+		}
+
 		name := f.Name()
-		entryPath := filepath.Join(path, name)
+		entryPath := joinPath(path, name)
 
-		if f.IsDir() {
+		if f.IsDir() || a.isDirLink(f, entryPath) {
+			counts.hasher.addEntry(f)
+			_, existed := previousDirs[name]
+			delete(previousDirs, name)
 			if a.ignoreDir(name, entryPath) {
 				continue
 			}
-
-			// Recursively process subdirectories
-			subdir := a.processDir(entryPath)
-			if subdir != nil {
-				subdir.Parent = parent
-				dir.AddFile(subdir)
-				// Accumulate size from subdirectory
-				totalSize += subdir.Size
-				totalUsage += subdir.Usage
-				itemCount += subdir.ItemCount
+			if !f.IsDir() {
+				counts.symlinks++
 			}
+			subdirs = append(subdirs, listedSubdir{index: i, path: entryPath, existed: existed})
 		} else {
-			info, err = f.Info()
+			if a.isExcludedFile(name) {
+				counts.excluded++
+				info, err := f.Info()
+				if err == nil {
+					counts.excludedSize += info.Size()
+				}
+				counts.hasher.addFile(name, info, err)
+				continue
+			}
+			if _, ok := skipped[name]; ok {
+				counts.hasher.addEntry(f)
+				continue
+			}
+
+			err = a.retry(entryPath, func() error {
+				info, err = f.Info()
+				return err
+			})
+			counts.hasher.addFile(name, info, err)
 			if err != nil {
 				log.Printf("Error getting file info for %s: %v", entryPath, err)
+				counts.errors++
 				continue
 			}
 
@@ -377,38 +1348,194 @@ func (a *IncrementalAnalyzer) performFullScan(path string) *Dir {
 				Parent: parent,
 			}
 			setPlatformSpecificAttrs(file, info)
+			if a.birthTimes {
+				file.Btime = birthTime(entryPath)
+			}
+			if !a.specialSizes {
+				clearSpecialFileSize(file, info)
+			}
 
-			// Handle symlinks if enabled
-			if a.followSymlinks && info.Mode()&os.ModeSymlink != 0 {
-				infoF, err := followSymlink(entryPath, a.gitAnnexedSize)
-				if err != nil {
-					log.Printf("Error following symlink %s: %v", entryPath, err)
-				} else if infoF != nil {
-					file.Size = infoF.Size()
-					setPlatformSpecificAttrs(file, infoF)
+			if info.Mode()&os.ModeSymlink != 0 {
+				counts.symlinks++
+				// Handle symlinks if enabled
+				if a.followSymlinks && !a.resolveSymlink(file, entryPath) {
+					counts.brokenSymlinks++
 				}
 			}
 
 			totalSize += file.Size
 			totalUsage += file.Usage
 			itemCount++
-			dir.AddFile(file)
+			items[i] = file
+			a.snapshot.add(path, file)
+			if skipped != nil {
+				sampledSizes = append(sampledSizes, file.Size)
+				sampledUsages = append(sampledUsages, file.Usage)
+			}
+		}
+	}
+
+	// Recursively process subdirectories, the ones removed since the listing are left out
+	a.processSubdirs(b, path, subdirs)
+	for _, sub := range subdirs {
+		subdir := sub.dir
+		if subdir == nil {
+			continue
 		}
+		if previousDirs != nil && !sub.existed {
+			a.stats.AddNewDir(sub.path)
+			a.decisions.addNew(sub.path)
+			a.diff.add(sub.path)
+		}
+		subdir.Parent = parent
+		items[sub.index] = subdir
+		// Accumulate size from subdirectory
+		totalSize += subdir.Size
+		totalUsage += subdir.Usage
+		itemCount += subdir.ItemCount
+		subtree.errors += subdir.ErrorCount
+		subtree.symlinks += subdir.SymlinkCount
+		subtree.brokenSymlinks += subdir.BrokenSymlinkCount
+		subtree.estimated += subdir.EstimatedDirCount
+	}
+	for _, item := range items {
+		if item != nil {
+			dir.AddFile(item)
+		}
+	}
+
+	// Subdirectories left in previousDirs are gone, their descendants are not reported
+	if listed && a.ctx.Err() == nil {
+		a.addRemovedDirs(path, previousDirs)
+		a.diff.remove(previous, previousDirs)
+	}
+
+	if skipped != nil && a.ctx.Err() == nil {
+		dir.Estimate = newEstimate(sampledSizes, sampledUsages, len(skipped))
+		totalSize += dir.Estimate.Size
+		totalUsage += dir.Estimate.Usage
+		itemCount += dir.Estimate.Skipped
+		subtree.estimated++
+		if dir.Flag == ' ' {
+			dir.Flag = '~'
+		}
+	}
+
+	// The scan was cancelled before the directory (or one of its subdirectories) was read completely
+	if a.ctx.Err() != nil && dir.Flag != '!' {
+		dir.Flag = 'x'
 	}
 
 	// Set the accumulated totals on the directory
+	dir.computeSelfSizes()
 	dir.Size = totalSize
 	dir.Usage = totalUsage
 	dir.ItemCount = itemCount + 1 // +1 for the directory itself
+	dir.ErrorCount = counts.errors + subtree.errors
+	dir.SymlinkCount = counts.symlinks + subtree.symlinks
+	dir.BrokenSymlinkCount = counts.brokenSymlinks + subtree.brokenSymlinks
+	dir.EstimatedDirCount = subtree.estimated
 
-	// Update progress
-	a.progressChan <- common.CurrentProgress{
-		CurrentItemName: path,
-		ItemCount:       len(files),
-		TotalSize:       totalSize,
+	return dir, counts
+}
+
+// resolveSymlink sets size and usage of the symlink file to the ones of its target.
+// Symlink which could not be followed keeps its own size and gets the broken flag,
+// false is returned for it
+func (a *IncrementalAnalyzer) resolveSymlink(file *File, path string) bool {
+	infoF, err := followSymlink(path, a.gitAnnexedSize)
+	if err != nil {
+		log.Printf("Error following symlink %s: %v", path, err)
+		file.Flag = '?'
+		return false
+	}
+	file.Flag = '@'
+	if infoF != nil {
+		file.Size = infoF.Size()
+		setPlatformSpecificAttrs(file, infoF)
+		if !a.specialSizes {
+			clearSpecialFileSize(file, infoF)
+		}
+	}
+	return true
+}
+
+// isDirLink returns true if the entry at path is a symlink to a directory which is scanned
+// as the directory (IncrementalOptions.FollowDirSymlinks)
+func (a *IncrementalAnalyzer) isDirLink(entry os.DirEntry, path string) bool {
+	if !a.followSymlinks || !a.followDirs || entry.Type()&os.ModeSymlink == 0 {
+		return false
 	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
 
-	return dir
+// readDir returns entries of the directory sorted by name (os.ReadDir)
+// or in the directory order if sorting is disabled
+func (a *IncrementalAnalyzer) readDir(path string) ([]os.DirEntry, error) {
+	if !a.unsorted {
+		return os.ReadDir(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.ReadDir(-1)
+}
+
+// dropDuplicateNames returns the listing of the directory without entries repeating the name
+// of an earlier entry, which some network and FUSE filesystems return. Their cache keys would
+// collide and the entry of one would overwrite the other, so only the first one is kept.
+// Sorted listings are checked without allocation
+func (a *IncrementalAnalyzer) dropDuplicateNames(path string, files []os.DirEntry) []os.DirEntry {
+	sorted := true
+	for i := 1; i < len(files) && sorted; i++ {
+		sorted = files[i-1].Name() < files[i].Name()
+	}
+	if sorted {
+		return files
+	}
+
+	names := make(map[string]struct{}, len(files))
+	unique := files[:0]
+	for _, f := range files {
+		if _, ok := names[f.Name()]; ok {
+			a.stats.IncrementDuplicateNamesSkipped()
+			log.Printf("Warning: %s is listed twice in %s, only the first entry is read", f.Name(), path)
+			continue
+		}
+		names[f.Name()] = struct{}{}
+		unique = append(unique, f)
+	}
+	return unique
+}
+
+// previousDirNames returns set of subdirectory names recorded in the previous cache entry
+func previousDirNames(previous *IncrementalDirMetadata) map[string]struct{} {
+	if previous == nil {
+		return nil
+	}
+	names := make(map[string]struct{}, len(previous.Files))
+	for _, f := range previous.Files {
+		if f.IsDir {
+			names[f.Name] = struct{}{}
+		}
+	}
+	return names
+}
+
+// addRemovedDirs reports the subdirectories of path with the given names as removed
+func (a *IncrementalAnalyzer) addRemovedDirs(path string, names map[string]struct{}) {
+	removed := make([]string, 0, len(names))
+	for name := range names {
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		a.stats.AddRemovedDir(filepath.Join(path, name))
+		a.removed.add(filepath.Join(path, name))
+	}
 }
 
 // extractFileMetadata extracts file metadata from a Dir for caching
@@ -428,9 +1555,18 @@ func (a *IncrementalAnalyzer) extractFileMetadata(dir *Dir) []FileMetadata {
 			Flag:  item.GetFlag(),
 		}
 
+		if b, ok := item.(interface{ GetBtime() time.Time }); ok {
+			meta.Btime = b.GetBtime()
+		}
+
+		if item.IsDir() {
+			meta.ItemCount = item.GetItemCount()
+		}
+
 		// Store multi-link inode for hardlinks
 		if file, ok := item.(*File); ok {
 			meta.Mli = file.Mli
+			meta.Owner = file.owner
 		}
 
 		files = append(files, meta)
@@ -440,48 +1576,101 @@ func (a *IncrementalAnalyzer) extractFileMetadata(dir *Dir) []FileMetadata {
 }
 
 // rebuildFromCache reconstructs a Dir from cached metadata
-func (a *IncrementalAnalyzer) rebuildFromCache(cached *IncrementalDirMetadata) *Dir {
+func (a *IncrementalAnalyzer) rebuildFromCache(b *scanBranch, cached *IncrementalDirMetadata) *Dir {
 	log.Printf("Rebuilding from cache: %s (children: %d)", cached.Path, len(cached.Files))
 
+	if canonical, ok := a.visitCachedDir(cached); ok {
+		return a.createDuplicateDir(cached.Path, canonical, nil)
+	}
+
 	dir := &Dir{
 		File: &File{
 			Name:  filepath.Base(cached.Path),
 			Size:  cached.Size,
 			Usage: cached.Usage,
 			Mtime: cached.Mtime,
+			Btime: cached.Btime,
 			Flag:  cached.Flag,
 		},
-		BasePath:  filepath.Dir(cached.Path),
-		ItemCount: cached.ItemCount,
-		Files:     make(fs.Files, 0, len(cached.Files)),
+		BasePath:   filepath.Dir(cached.Path),
+		ItemCount:  cached.ItemCount,
+		ErrorCount: cached.ErrorCount,
+		Files:      make(fs.Files, 0, len(cached.Files)),
+
+		SymlinkCount:       cached.SymlinkCount,
+		BrokenSymlinkCount: cached.BrokenSymlinkCount,
+
+		Estimate: cached.Estimate,
+
+		SelfSize:  cached.SelfSize,
+		SelfUsage: cached.SelfUsage,
 	}
+	if cached.Estimate != nil {
+		dir.EstimatedDirCount = 1
+	}
+	a.stats.AddExcludedFiles(int64(cached.ExcludedFiles), cached.ExcludedSize)
 	parent := &ParentDir{Path: cached.Path}
+	changed := false
+
+	// Entries stored with UnsortedChildren or by older versions may not be ordered
+	byName := func(i, j int) bool { return cached.Files[i].Name < cached.Files[j].Name }
+	if !a.unsorted && !sort.SliceIsSorted(cached.Files, byName) {
+		sort.Slice(cached.Files, byName)
+	}
+	a.prefetchChildren(cached)
 
 	// Reconstruct child items from cached metadata
-	for _, fileMeta := range cached.Files {
+	for i, fileMeta := range cached.Files {
 		if fileMeta.IsDir {
 			// FIX: Load child from cache directly, don't call processDir()
 			// This prevents loading the entire tree twice into memory
-			childPath := filepath.Join(cached.Path, fileMeta.Name)
-			childCached, err := a.storage.LoadDirMetadata(childPath)
+			childPath := joinPath(cached.Path, fileMeta.Name)
+			childCached, err := a.loadCachedChild(childPath)
 			if err != nil {
-				// Child cache miss shouldn't happen in normal operation
-				// Fall back to processDir() only as last resort
-				log.Printf("Warning: Child cache miss for %s: %v", childPath, err)
-				childDir := a.processDir(childPath)
+				// Child cache miss shouldn't happen in normal operation unless small directories
+				// are not cached. Fall back to processDir() only as last resort
+				if a.skipsSmallDirs() && isNotCached(err) {
+					log.Debugf("Child %s is not cached, scanning it", childPath)
+				} else {
+					log.Printf("Warning: Child cache miss for %s: %v", childPath, err)
+				}
+				childDir := a.processDir(b, childPath)
 				if childDir != nil {
 					childDir.Parent = parent
 					dir.AddFile(childDir)
+					a.snapshot.add(cached.Path, childDir)
+					dir.addSubtreeCounts(childDir)
+					// Cached aggregates of this dir include the child as it was cached
+					dir.ComputeAggregates(cachedDirItem(fileMeta, nil, childDir), childDir)
+					changed = refreshChildFlag(&cached.Files[i], childDir) || changed
 				}
 				continue
 			}
 
+			// Mounted since the parent was cached, so the parent's totals hold the directory below it
+			if a.mountedSince(childPath) {
+				dir.ComputeAggregates(cachedDirItem(fileMeta, childCached, nil), nil)
+				continue
+			}
+
 			// Recursively rebuild child from its cache entry
 			// Note: Statistics are tracked in processDir(), not here to avoid double-counting
-			childDir := a.rebuildFromCache(childCached)
+			var childDir *Dir
+			if !a.inheritable(childPath, childCached) {
+				childDir = a.processDir(b, childPath)
+			} else {
+				a.traceDecision(childPath, DecisionInherited, childCached, nil)
+				childDir = a.accountDir(b, childPath, func() (*Dir, CacheDecision, uint64) {
+					return a.rebuildFromCache(b, childCached), DecisionInherited, childCached.Dev
+				})
+			}
 			if childDir != nil {
 				childDir.Parent = parent
 				dir.AddFile(childDir)
+				a.snapshot.add(cached.Path, childDir)
+				dir.addSubtreeCounts(childDir)
+				dir.ComputeAggregates(cachedDirItem(fileMeta, childCached, childDir), childDir)
+				changed = refreshChildFlag(&cached.Files[i], childDir) || changed
 			}
 		} else {
 			// For files, reconstruct directly from metadata
@@ -490,40 +1679,168 @@ func (a *IncrementalAnalyzer) rebuildFromCache(cached *IncrementalDirMetadata) *
 				Size:   fileMeta.Size,
 				Usage:  fileMeta.Usage,
 				Mtime:  fileMeta.Mtime,
+				Btime:  fileMeta.Btime,
 				Flag:   fileMeta.Flag,
 				Mli:    fileMeta.Mli,
 				Parent: parent,
+				owner:  fileMeta.Owner,
+			}
+			if a.verifyLinks && a.followSymlinks && (file.Flag == '@' || file.Flag == '?') {
+				changed = a.verifySymlink(dir, file, cached.Path) || changed
 			}
 			dir.AddFile(file)
+			a.snapshot.add(cached.Path, file)
 		}
 	}
 
-	// Send progress update (similar to performFullScan)
-	a.progressChan <- common.CurrentProgress{
-		CurrentItemName: cached.Path,
-		ItemCount:       len(cached.Files),
-		TotalSize:       cached.Size,
+	// Entries written before schema 3 do not hold the sizes of the direct files
+	if changed || (dir.SelfSize == 0 && dir.SelfUsage == 0) {
+		dir.computeSelfSizes()
 	}
+	if changed {
+		a.storeVerified(cached, dir)
+	}
+	a.foldSmallItems(dir)
 
 	return dir
 }
 
+// addSubtreeCounts adds counters of the child directory to the ones of dir
+func (f *Dir) addSubtreeCounts(child *Dir) {
+	f.ErrorCount += child.ErrorCount
+	f.SymlinkCount += child.SymlinkCount
+	f.BrokenSymlinkCount += child.BrokenSymlinkCount
+	f.EstimatedDirCount += child.EstimatedDirCount
+}
+
+// verifySymlink resolves again the cached symlink file of dir located in dirPath
+// and updates the file and the totals of dir. Returns true if the file changed
+func (a *IncrementalAnalyzer) verifySymlink(dir *Dir, file *File, dirPath string) bool {
+	path := filepath.Join(dirPath, file.Name)
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		// the directory itself has not changed, so the entry is still a symlink
+		return false
+	}
+
+	wasBroken := file.Flag == '?'
+	oldSize, oldUsage := file.Size, file.Usage
+	file.Size = info.Size()
+	file.Usage = 0
+	setPlatformSpecificAttrs(file, info)
+	broken := !a.resolveSymlink(file, path)
+
+	if broken == wasBroken && file.Size == oldSize && file.Usage == oldUsage {
+		return false
+	}
+
+	dir.Size += file.Size - oldSize
+	dir.Usage += file.Usage - oldUsage
+	switch {
+	case broken && !wasBroken:
+		dir.BrokenSymlinkCount++
+	case !broken && wasBroken:
+		dir.BrokenSymlinkCount--
+	}
+	return true
+}
+
+// refreshChildFlag updates the flag of the subdirectory in the cached entry of its parent
+// if the subdirectory became empty or stopped being empty since the parent was cached.
+// The parent keeps its mtime then, so its entry is used as it is and would keep the old flag.
+// Returns true if the entry of the parent has to be stored again
+func refreshChildFlag(fileMeta *FileMetadata, child *Dir) bool {
+	if (fileMeta.Flag == 'e') == (child.Flag == 'e') {
+		return false
+	}
+	fileMeta.Flag = child.Flag
+	return true
+}
+
+// storeVerified replaces the cache entry of dir after its symlinks were verified
+// or flags of its subdirectories refreshed. Only the files and the flags of the subdirectories
+// are updated, other changes of subdirectories are stored in their own entries
+func (a *IncrementalAnalyzer) storeVerified(cached *IncrementalDirMetadata, dir *Dir) {
+	files := make(map[string]fs.Item, len(dir.Files))
+	for _, item := range dir.Files {
+		if !item.IsDir() {
+			files[item.GetName()] = item
+		}
+	}
+
+	meta := *cached
+	meta.SelfSize, meta.SelfUsage = dir.SelfSize, dir.SelfUsage
+	a.provenance.stamp(&meta)
+	meta.BrokenSymlinkCount = 0
+	meta.Files = make([]FileMetadata, len(cached.Files))
+	copy(meta.Files, cached.Files)
+	for i := range meta.Files {
+		fileMeta := &meta.Files[i]
+		if item, ok := files[fileMeta.Name]; ok && !fileMeta.IsDir {
+			meta.Size += item.GetSize() - fileMeta.Size
+			meta.Usage += item.GetUsage() - fileMeta.Usage
+			fileMeta.Size = item.GetSize()
+			fileMeta.Usage = item.GetUsage()
+			fileMeta.Flag = item.GetFlag()
+		}
+		if fileMeta.Flag == '?' {
+			meta.BrokenSymlinkCount++
+		}
+	}
+
+	if err := a.storage.StoreDirMetadata(&meta); err != nil {
+		a.stats.IncrementCacheErrors()
+		log.Printf("Warning: Failed to cache %s: %v", cached.Path, err)
+	}
+}
+
+// cachedDirItem returns the child directory as it was accounted in the aggregates of its parent.
+// Entries written before ItemCount was recorded in FileMetadata fall back
+// to the child's own cache entry or, if there is none, to the current item count
+func cachedDirItem(fileMeta FileMetadata, childCached *IncrementalDirMetadata, current *Dir) *Dir {
+	itemCount := fileMeta.ItemCount
+	if itemCount == 0 {
+		if childCached != nil {
+			itemCount = childCached.ItemCount
+		} else {
+			itemCount = current.ItemCount
+		}
+	}
+	return &Dir{
+		File: &File{
+			Size:  fileMeta.Size,
+			Usage: fileMeta.Usage,
+		},
+		ItemCount: itemCount,
+	}
+}
+
 // handleCacheError handles cache read errors by falling back to full scan
-func (a *IncrementalAnalyzer) handleCacheError(path string, currentMtime time.Time, err error) *Dir {
+func (a *IncrementalAnalyzer) handleCacheError(b *scanBranch, path string, stat os.FileInfo, err error) *Dir {
 	// Distinguish between cache miss and actual errors
-	if err.Error() == "Key not found" || err.Error() == "reading cached metadata for path: "+path+": Key not found" {
+	notFound := err.Error() == "Key not found" || err.Error() == "reading cached metadata for path: "+path+": Key not found"
+	if notFound {
 		// Normal cache miss - just log at debug level
 		log.Debugf("Cache miss for %s: not in cache", path)
+	} else if errors.Is(err, ErrKeyCollision) {
+		// The entry of the other directory is replaced, both stay correct
+		a.stats.IncrementKeyCollisions()
+		log.Printf("Warning: %v, scanning again", err)
 	} else {
 		// Actual cache error - log as warning
+		a.stats.IncrementCacheErrors()
 		log.Printf("Warning: Cache error for %s: %v, falling back to full scan", path, err)
 	}
 
-	a.stats.IncrementCacheMisses()
-	a.stats.IncrementTotalDirs()
-
 	// Perform full scan as fallback
-	return a.scanAndCache(path, currentMtime)
+	dir := a.scanAndCache(b, path, stat, nil)
+
+	// Small directories are not cached, so their absence is not a miss
+	if !notFound || !a.skipsCaching(dir) {
+		a.stats.IncrementCacheMisses()
+	}
+	a.stats.IncrementTotalDirs()
+	return dir
 }
 
 // validateCachedPath checks if a cached directory path still exists on the filesystem
@@ -556,28 +1873,3 @@ func (a *IncrementalAnalyzer) validateCachedPath(path string) bool {
 
 	return true
 }
-
-// updateProgress sends progress updates to the progress channel
-// This goroutine ensures proper cleanup by checking the done signal
-// in both select statements to prevent goroutine leaks
-func (a *IncrementalAnalyzer) updateProgress() {
-	for {
-		select {
-		case <-a.progressDoneChan:
-			return
-		case progress := <-a.progressChan:
-			a.progress.CurrentItemName = progress.CurrentItemName
-			a.progress.ItemCount += progress.ItemCount
-			a.progress.TotalSize += progress.TotalSize
-
-			// Check done signal again before sending to avoid blocking on a closed channel
-			select {
-			case a.progressOutChan <- *a.progress:
-			case <-a.progressDoneChan:
-				return
-			default:
-				// Progress update dropped (non-blocking, acceptable for UI updates)
-			}
-		}
-	}
-}
@@ -0,0 +1,205 @@
+package analyze
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/dundee/gdu/v5/pkg/fs"
+	log "github.com/sirupsen/logrus"
+)
+
+// annotationKey creates a key of the note of directory at path
+func annotationKey(path string) []byte {
+	return []byte(KeyPrefixAnnotation + path)
+}
+
+// SetAnnotation stores note of directory at path, empty text removes the note.
+// Notes are not bound to scans, rescans and ClearCachePreservingHistory keep them
+func (s *IncrementalStorage) SetAnnotation(path, text string) error {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.db == nil {
+		return fmt.Errorf("storage is not open")
+	}
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		if text == "" {
+			return txn.Delete(annotationKey(path))
+		}
+		return txn.Set(annotationKey(path), []byte(text))
+	})
+}
+
+// GetAnnotation returns note of directory at path, empty string if there is none
+func (s *IncrementalStorage) GetAnnotation(path string) (string, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.db == nil {
+		return "", fmt.Errorf("storage is not open")
+	}
+
+	var text string
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(annotationKey(path))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			text = string(val)
+			return nil
+		})
+	})
+	return text, err
+}
+
+// IterateAnnotations calls fn for notes of directory at path and of all its descendants
+// in path order. Iteration stops at first error returned by fn
+func (s *IncrementalStorage) IterateAnnotations(path string, fn func(path, text string) error) error {
+	return s.Iterate(string(annotationKey(path)), func(key, value []byte) error {
+		annotated := string(key[len(KeyPrefixAnnotation):])
+		if !inSubtree(annotated, path) {
+			return nil
+		}
+		return fn(annotated, string(value))
+	})
+}
+
+// DeleteAnnotations removes notes of directory at path and of all its descendants.
+// It returns number of removed notes
+func (s *IncrementalStorage) DeleteAnnotations(path string) (int, error) {
+	keys := make([][]byte, 0)
+	err := s.IterateAnnotations(path, func(annotated, _ string) error {
+		keys = append(keys, annotationKey(annotated))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return s.deleteKeys(keys)
+}
+
+// loadAnnotations reads notes of the scanned tree and sets them to its directories
+func (a *IncrementalAnalyzer) loadAnnotations(path string, dir *Dir) {
+	annotations := make(map[string]string)
+	err := a.storage.IterateAnnotations(path, func(annotated, text string) error {
+		annotations[annotated] = text
+		if found := findDir(dir, path, annotated); found != nil {
+			found.Annotation = text
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to load annotations of %s: %v", path, err)
+	}
+
+	a.annotationsM.Lock()
+	defer a.annotationsM.Unlock()
+	a.annotations = annotations
+}
+
+// findDir returns directory at path in the tree of root read from rootPath, nil if not present
+func findDir(root *Dir, rootPath, path string) *Dir {
+	rel, err := filepath.Rel(rootPath, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+	if rel == "." {
+		return root
+	}
+
+	dir := root
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		i, ok := dir.Files.FindByName(name)
+		if !ok {
+			return nil
+		}
+		child, ok := dir.Files[i].(*Dir)
+		if !ok {
+			return nil
+		}
+		dir = child
+	}
+	return dir
+}
+
+// CountAnnotations returns number of notes of directory at path and of its descendants
+// loaded by the last scan
+func (a *IncrementalAnalyzer) CountAnnotations(path string) int {
+	a.annotationsM.Lock()
+	defer a.annotationsM.Unlock()
+
+	count := 0
+	for annotated := range a.annotations {
+		if inSubtree(annotated, path) {
+			count++
+		}
+	}
+	return count
+}
+
+// Annotate stores note of directory at path (empty text removes it)
+// and sets it to the item if it is a directory.
+// It returns ErrBusy while a scan is running
+func (a *IncrementalAnalyzer) Annotate(item fs.Item, text string) error {
+	path := item.GetPath()
+	err := a.withStorage(path, func(storage *IncrementalStorage) error {
+		return storage.SetAnnotation(path, text)
+	})
+	if err != nil {
+		return err
+	}
+
+	if dir, ok := item.(*Dir); ok {
+		dir.Annotation = text
+	}
+
+	a.annotationsM.Lock()
+	defer a.annotationsM.Unlock()
+	if a.annotations == nil {
+		a.annotations = make(map[string]string)
+	}
+	if text == "" {
+		delete(a.annotations, path)
+	} else {
+		a.annotations[path] = text
+	}
+	return nil
+}
+
+// DropAnnotations removes notes of directory at path and of all its descendants,
+// e.g. after the directory was deleted. It returns number of removed notes
+// and ErrBusy while a scan is running
+func (a *IncrementalAnalyzer) DropAnnotations(path string) (int, error) {
+	var count int
+	err := a.withStorage(path, func(storage *IncrementalStorage) error {
+		var err error
+		count, err = storage.DeleteAnnotations(path)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	a.annotationsM.Lock()
+	defer a.annotationsM.Unlock()
+	for annotated := range a.annotations {
+		if inSubtree(annotated, path) {
+			delete(a.annotations, annotated)
+		}
+	}
+	return count, nil
+}
+
+// GetAnnotation returns note of the directory, empty string if it has none
+func (f *Dir) GetAnnotation() string {
+	return f.Annotation
+}
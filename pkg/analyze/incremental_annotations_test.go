@@ -0,0 +1,159 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalStorage_Annotations(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/test/path")
+	closeFn, err := storage.Open()
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer closeFn()
+
+	text, err := storage.GetAnnotation("/test/path/a")
+	assert.NoError(t, err)
+	assert.Equal(t, "", text)
+
+	assert.NoError(t, storage.SetAnnotation("/test/path/a", "safe to delete"))
+	assert.NoError(t, storage.SetAnnotation("/test/path/a/b", "owned by team X"))
+	assert.NoError(t, storage.SetAnnotation("/test/path/ab", "keep"))
+
+	text, err = storage.GetAnnotation("/test/path/a")
+	assert.NoError(t, err)
+	assert.Equal(t, "safe to delete", text)
+
+	// update
+	assert.NoError(t, storage.SetAnnotation("/test/path/a", "safe to delete after 2026"))
+	text, err = storage.GetAnnotation("/test/path/a")
+	assert.NoError(t, err)
+	assert.Equal(t, "safe to delete after 2026", text)
+
+	annotated := make(map[string]string)
+	err = storage.IterateAnnotations("/test/path/a", func(path, text string) error {
+		annotated[path] = text
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"/test/path/a":   "safe to delete after 2026",
+		"/test/path/a/b": "owned by team X",
+	}, annotated, "Sibling with the same prefix is not in the subtree")
+
+	// empty text removes the note
+	assert.NoError(t, storage.SetAnnotation("/test/path/ab", ""))
+	text, err = storage.GetAnnotation("/test/path/ab")
+	assert.NoError(t, err)
+	assert.Equal(t, "", text)
+
+	count, err := storage.DeleteAnnotations("/test/path/a")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	text, err = storage.GetAnnotation("/test/path/a/b")
+	assert.NoError(t, err)
+	assert.Equal(t, "", text)
+}
+
+func TestIncrementalStorage_AnnotationsSurviveClear(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/test/path")
+	closeFn, err := storage.Open()
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer closeFn()
+
+	assert.NoError(t, storage.StoreDirMetadata(&IncrementalDirMetadata{Path: "/test/path", CachedAt: time.Now()}))
+	assert.NoError(t, storage.SetAnnotation("/test/path", "owned by team X"))
+
+	assert.NoError(t, storage.ClearCachePreservingHistory())
+
+	_, err = storage.LoadDirMetadata("/test/path")
+	assert.Error(t, err, "Metadata should be dropped")
+	text, err := storage.GetAnnotation("/test/path")
+	assert.NoError(t, err)
+	assert.Equal(t, "owned by team X", text)
+
+	_, err = storage.ClearCache()
+	assert.NoError(t, err)
+	text, err = storage.GetAnnotation("/test/path")
+	assert.NoError(t, err)
+	assert.Equal(t, "", text, "Full clear removes annotations as well")
+}
+
+func TestIncrementalAnalyzer_Annotations(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "root")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b"), 0o755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "c"), 0o755))
+	storagePath := t.TempDir()
+
+	analyze := func() (*Dir, *IncrementalAnalyzer) {
+		analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath})
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		return dir, analyzer
+	}
+
+	dir, analyzer := analyze()
+	a := childByName(dir, "a").(*Dir)
+	b := childByName(a, "b").(*Dir)
+	assert.Equal(t, "", a.GetAnnotation())
+	assert.Equal(t, 0, analyzer.CountAnnotations(root))
+
+	assert.NoError(t, analyzer.Annotate(a, "safe to delete"))
+	assert.NoError(t, analyzer.Annotate(b, "owned by team X"))
+	assert.Equal(t, "safe to delete", a.GetAnnotation())
+	assert.Equal(t, 2, analyzer.CountAnnotations(a.GetPath()))
+	assert.Equal(t, 1, analyzer.CountAnnotations(b.GetPath()))
+
+	// warm scan loads the notes
+	dir, analyzer = analyze()
+	a = childByName(dir, "a").(*Dir)
+	assert.Equal(t, "safe to delete", a.GetAnnotation())
+	assert.Equal(t, "owned by team X", childByName(a, "b").(*Dir).GetAnnotation())
+	assert.Equal(t, "", childByName(dir, "c").(*Dir).GetAnnotation())
+	assert.Equal(t, 2, analyzer.CountAnnotations(root))
+
+	// notes of removed directories are kept until dropped
+	assert.NoError(t, os.RemoveAll(filepath.Join(root, "a")))
+	_, analyzer = analyze()
+	assert.Equal(t, 2, analyzer.CountAnnotations(root))
+
+	count, err := analyzer.DropAnnotations(filepath.Join(root, "a"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 0, analyzer.CountAnnotations(root))
+
+	_, analyzer = analyze()
+	assert.Equal(t, 0, analyzer.CountAnnotations(root))
+}
+
+func TestIncrementalAnalyzer_AnnotateDuringScan(t *testing.T) {
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	dir := &Dir{File: &File{Name: "dir"}, BasePath: "/test"}
+
+	analyzer.scanning.Store(true)
+	assert.ErrorIs(t, analyzer.Annotate(dir, "note"), ErrBusy)
+	_, err := analyzer.DropAnnotations("/test/dir")
+	assert.ErrorIs(t, err, ErrBusy)
+	assert.Equal(t, "", dir.GetAnnotation())
+}
+
+func TestFindDir(t *testing.T) {
+	root := &Dir{File: &File{Name: "root"}, BasePath: "/"}
+	sub := &Dir{File: &File{Name: "sub", Parent: root}}
+	file := &File{Name: "file", Parent: sub}
+	root.Files = append(root.Files, sub)
+	sub.Files = append(sub.Files, file)
+
+	assert.Same(t, root, findDir(root, "/root", "/root"))
+	assert.Same(t, sub, findDir(root, "/root", "/root/sub"))
+	assert.Nil(t, findDir(root, "/root", "/root/sub/file"))
+	assert.Nil(t, findDir(root, "/root", "/root/missing"))
+	assert.Nil(t, findDir(root, "/root", "/other"))
+}
@@ -0,0 +1,84 @@
+package analyze
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/dundee/gdu/v5/internal/common"
+	log "github.com/sirupsen/logrus"
+)
+
+// ignoringCacheDir returns ignore extended by the cache directory if it is located in the tree
+// scanned from path. Every scan writes to the cache, so counting it would change the tree
+// by the scan itself and its parent would be scanned again every time.
+// The situation is logged and recorded in the statistics
+func (a *IncrementalAnalyzer) ignoringCacheDir(path string, ignore common.ShouldDirBeIgnored) common.ShouldDirBeIgnored {
+	cacheDir := cacheDirInTree(path, a.storagePath)
+	if cacheDir == "" {
+		return ignore
+	}
+	a.stats.SetCacheDirInTree(cacheDir, !a.countCacheDir)
+
+	if a.countCacheDir {
+		log.Printf("Warning: Cache directory %s is located in the scanned tree, it changes with every scan", cacheDir)
+		return ignore
+	}
+	log.Printf("Cache directory %s is located in the scanned tree, it is left out of the scan", cacheDir)
+	return func(name, entryPath string) bool {
+		return entryPath == cacheDir || ignore(name, entryPath)
+	}
+}
+
+// CacheDirNote is the note shown at the cache directory counted in the scanned tree
+// (see IncrementalOptions.CountCacheDir), so it is not mistaken for data to clean up
+const CacheDirNote = "gdu cache"
+
+// tagCacheDir marks the cache directory counted in the tree of dir scanned from path
+// with CacheDirNote, unless the user gave it a note, and records its disk usage in the statistics
+func (a *IncrementalAnalyzer) tagCacheDir(path string, dir *Dir) {
+	if !a.countCacheDir {
+		return
+	}
+	cacheDir := cacheDirInTree(path, a.storagePath)
+	if cacheDir == "" {
+		return
+	}
+	found := findDir(dir, path, cacheDir)
+	if found == nil {
+		return
+	}
+	if found.Annotation == "" {
+		found.Annotation = CacheDirNote
+	}
+	a.stats.SetCacheDirUsage(found.Usage)
+}
+
+// ContainsCacheDir returns true if the cache directory is located at path or below it,
+// so deleting path would delete the cache used by the scans. Symlinks in both paths are resolved
+func (a *IncrementalAnalyzer) ContainsCacheDir(path string) bool {
+	return inSubtree(canonicalPath(a.storagePath), canonicalPath(path))
+}
+
+// cacheDirInTree returns path of the cache directory as it appears in the scan of path,
+// empty if it is not located below path. Symlinks in both paths are resolved
+func cacheDirInTree(path, storagePath string) string {
+	root := canonicalPath(path)
+	cacheDir := canonicalPath(storagePath)
+	if cacheDir == root || !inSubtree(cacheDir, root) {
+		return ""
+	}
+	rel := strings.TrimPrefix(cacheDir[len(root):], string(filepath.Separator))
+	return joinPath(path, rel)
+}
+
+// canonicalPath returns absolute path with resolved symlinks, just the cleaned absolute path
+// if it can't be resolved
+func canonicalPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
@@ -0,0 +1,107 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+// scanWithCacheInTree scans root twice with the cache at root/.cache/gdu
+// and returns the trees and statistics of both scans
+func scanWithCacheInTree(t *testing.T, root string, count bool) (dirs []*Dir, stats []*CacheStats) {
+	t.Helper()
+	opts := IncrementalOptions{StoragePath: filepath.Join(root, ".cache", "gdu"), CountCacheDir: count}
+	for i := 0; i < 2; i++ {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		dir.UpdateStats(make(fs.HardLinkedItems))
+		dirs = append(dirs, dir)
+		stats = append(stats, analyzer.GetScanResult().Stats)
+	}
+	return dirs, stats
+}
+
+func createTreeWithCacheDir(t *testing.T) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "root")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a", "file"), make([]byte, 100), 0o600))
+	return root
+}
+
+func TestIncrementalAnalyzer_CacheDirInTree(t *testing.T) {
+	root := createTreeWithCacheDir(t)
+	cacheDir := filepath.Join(root, ".cache", "gdu")
+
+	dirs, stats := scanWithCacheInTree(t, root, false)
+
+	for i, dir := range dirs {
+		cacheParent := childByName(dir, ".cache")
+		assert.NotNil(t, cacheParent, "scan %d", i)
+		assert.Empty(t, cacheParent.GetFiles(), "the cache is left out of scan %d", i)
+		assert.Equal(t, cacheDir, stats[i].CacheDirInTree)
+		assert.True(t, stats[i].CacheDirExcluded)
+		assert.Zero(t, stats[i].CacheDirUsage)
+	}
+	assert.Equal(t, dirs[0].GetUsage(), dirs[1].GetUsage())
+	assert.Equal(t, dirs[0].GetItemCount(), dirs[1].GetItemCount())
+
+	// the warm scan is not disturbed by the writes of the cold one
+	assert.Equal(t, int64(0), stats[1].DirsRescanned)
+	assert.Equal(t, 100.0, stats[1].HitRate())
+}
+
+func TestIncrementalAnalyzer_CountCacheDir(t *testing.T) {
+	root := createTreeWithCacheDir(t)
+
+	dirs, stats := scanWithCacheInTree(t, root, true)
+
+	cacheParent := childByName(dirs[1], ".cache")
+	assert.NotNil(t, cacheParent)
+	cache := childByName(cacheParent.(*Dir), "gdu")
+	assert.NotNil(t, cache, "the cache is counted")
+	assert.NotEmpty(t, cache.GetFiles())
+	assert.Equal(t, filepath.Join(root, ".cache", "gdu"), stats[1].CacheDirInTree)
+	assert.False(t, stats[1].CacheDirExcluded)
+
+	// the cache is tagged and its usage reported separately
+	assert.Equal(t, CacheDirNote, cache.(*Dir).GetAnnotation())
+	assert.Empty(t, cacheParent.(*Dir).GetAnnotation())
+	assert.Equal(t, cache.GetUsage(), stats[1].CacheDirUsage)
+	assert.Positive(t, stats[1].CacheDirUsage)
+}
+
+func TestIncrementalAnalyzer_ContainsCacheDir(t *testing.T) {
+	root := createTreeWithCacheDir(t)
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: filepath.Join(root, ".cache", "gdu")})
+
+	assert.True(t, analyzer.ContainsCacheDir(root))
+	assert.True(t, analyzer.ContainsCacheDir(filepath.Join(root, ".cache")))
+	assert.True(t, analyzer.ContainsCacheDir(filepath.Join(root, ".cache", "gdu")))
+	assert.False(t, analyzer.ContainsCacheDir(filepath.Join(root, ".cache", "gdu", "000001.vlog")))
+	assert.False(t, analyzer.ContainsCacheDir(filepath.Join(root, "a")))
+	assert.False(t, analyzer.ContainsCacheDir(filepath.Join(root, ".ca")))
+}
+
+func TestCacheDirInTree(t *testing.T) {
+	tmp := t.TempDir()
+	root := filepath.Join(tmp, "root")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "var", "cache"), 0o755))
+	link := filepath.Join(tmp, "link")
+	assert.NoError(t, os.Symlink(root, link))
+
+	assert.Equal(t, filepath.Join(root, "var", "cache", "gdu"),
+		cacheDirInTree(root, filepath.Join(root, "var", "cache", "gdu")))
+	// symlinks are resolved, the returned path is the one seen by the scan
+	assert.Equal(t, filepath.Join(link, "var", "cache", "gdu"),
+		cacheDirInTree(link, filepath.Join(root, "var", "cache", "gdu")))
+	assert.Equal(t, "/var/cache/gdu", cacheDirInTree("/", "/var/cache/gdu"))
+
+	assert.Empty(t, cacheDirInTree(root, root))
+	assert.Empty(t, cacheDirInTree(root, root+"-cache"))
+	assert.Empty(t, cacheDirInTree(filepath.Join(root, "var"), tmp))
+}
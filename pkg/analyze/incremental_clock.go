@@ -0,0 +1,98 @@
+package analyze
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// inFuture returns true if the cache entry or the directory mtime is later than now
+// plus the allowed clock skew (IncrementalOptions.FutureSkew), so it can't be trusted.
+// Such timestamps are counted, the first one of the scan is logged as a warning
+func (a *IncrementalAnalyzer) inFuture(path string, cachedAt, mtime time.Time) bool {
+	if !a.isFuture(cachedAt, mtime) {
+		return false
+	}
+
+	a.stats.IncrementFutureTimestamps()
+	if !a.futureLogged.Swap(true) {
+		log.Warnf(
+			"Timestamp in the future found at %s (cached at %s, mtime %s), the directory is scanned again. "+
+				"Further ones are logged only at debug level",
+			path, cachedAt.Format(time.RFC3339), mtime.Format(time.RFC3339),
+		)
+	} else {
+		log.Debugf("Timestamp in the future found at %s", path)
+	}
+	return true
+}
+
+// isFuture returns true if any of the times is later than now plus the allowed clock skew
+func (a *IncrementalAnalyzer) isFuture(times ...time.Time) bool {
+	if a.futureSkew <= 0 {
+		return false
+	}
+	limit := time.Now().Add(a.futureSkew)
+	for _, t := range times {
+		if t.After(limit) {
+			return true
+		}
+	}
+	return false
+}
+
+// clockStepTolerance is how much later than now a cache entry may be written
+// before the system clock is considered to have been stepped backwards
+const clockStepTolerance = time.Minute
+
+// cachedAhead returns true if the cache entry was written later than now, usually before
+// the system clock was stepped backwards (e.g. by NTP). Such entries would never expire,
+// they are counted and the first one of the scan is logged as a warning
+func (a *IncrementalAnalyzer) cachedAhead(path string, cachedAt time.Time) bool {
+	if !cachedAt.After(time.Now().Add(clockStepTolerance)) {
+		return false
+	}
+
+	a.stats.IncrementCachedAhead()
+	if !a.aheadLogged.Swap(true) {
+		policy := "scanned again"
+		if a.trustAhead {
+			policy = "used as if cached now"
+		}
+		log.Warnf(
+			"Cache entry of %s was written later than now (cached at %s), the system clock was probably "+
+				"stepped backwards. Such entries are %s, further ones are logged only at debug level",
+			path, cachedAt.Format(time.RFC3339), policy,
+		)
+	} else {
+		log.Debugf("Cache entry written later than now found at %s", path)
+	}
+	return true
+}
+
+// mtimeBackwards returns true if the directory mtime is earlier than the cached one by more than
+// the allowed clock skew (IncrementalOptions.BackwardsSkew). The directory changed in any case,
+// but the first one of the scan is logged as a warning, as it is worth knowing
+// that a tree was restored from a backup or files were modified while the clock was behind
+func (a *IncrementalAnalyzer) mtimeBackwards(path string, cachedMtime, mtime time.Time) bool {
+	if a.backwardsSkew <= 0 || cachedMtime.IsZero() || !mtime.Before(cachedMtime.Add(-a.backwardsSkew)) {
+		return false
+	}
+
+	if !a.backwardsLogged.Swap(true) {
+		log.Warnf(
+			"Mtime of %s moved backwards by %s (cached %s, now %s), the directory is scanned again. "+
+				"Further ones are logged only at debug level",
+			path, cachedMtime.Sub(mtime).Round(time.Second),
+			cachedMtime.Format(time.RFC3339), mtime.Format(time.RFC3339),
+		)
+	} else {
+		log.Debugf("Mtime moved backwards found at %s", path)
+	}
+	return true
+}
+
+// cacheAge returns the time since the entry was cached, never negative
+func cacheAge(cachedAt time.Time) time.Duration {
+	return max(time.Since(cachedAt), 0)
+}
@@ -0,0 +1,162 @@
+package analyze
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+// collidingNames differ only in case or in Unicode normalization (NFC and NFD)
+var collidingNames = []string{"Foo", "foo", "caf\u00e9", "cafe\u0301"}
+
+// createCollidingDirs creates pairs of directories whose names differ only in case
+// or in Unicode normalization, each with a file of different size.
+// The test is skipped if the filesystem folds them to one name
+func createCollidingDirs(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for i, name := range collidingNames {
+		dir := filepath.Join(root, name)
+		if err := os.Mkdir(dir, 0o755); os.IsExist(err) {
+			t.Skip("filesystem folds names of directories")
+		} else {
+			assert.NoError(t, err)
+		}
+		data := make([]byte, (i+1)*1000)
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "file"), data, 0o600))
+	}
+	return root
+}
+
+func scanColliding(t *testing.T, root, storagePath string) (map[string]string, *CacheStats) {
+	t.Helper()
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath})
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+	return flattenTree(dir), analyzer.GetCacheStats()
+}
+
+func TestIncrementalAnalyzer_DistinctNamesKeepOwnEntries(t *testing.T) {
+	root := createCollidingDirs(t)
+	storagePath := t.TempDir()
+
+	cold, _ := scanColliding(t, root, storagePath)
+	for i, name := range collidingNames {
+		path := filepath.Join(root, name, "file")
+		assert.Regexp(t, fmt.Sprintf("^%d ", (i+1)*1000), cold[path], name)
+	}
+
+	for range 2 {
+		warm, stats := scanColliding(t, root, storagePath)
+		assert.Equal(t, cold, warm)
+		assert.Equal(t, int64(0), stats.KeyCollisions)
+		assert.Equal(t, int64(0), stats.CacheMisses)
+	}
+}
+
+func TestIncrementalAnalyzer_KeyCollision(t *testing.T) {
+	root := createCollidingDirs(t)
+	storagePath := t.TempDir()
+	cold, _ := scanColliding(t, root, storagePath)
+
+	// entry of Foo stored under the key of foo, as if the keys were case-folded
+	storage := NewIncrementalStorage(storagePath, root)
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	upper, err := storage.LoadDirMetadata(filepath.Join(root, "Foo"))
+	assert.NoError(t, err)
+	storeRawEntry(t, storage, filepath.Join(root, "foo"), encodeEntry(t, upper))
+
+	_, err = storage.LoadDirMetadata(filepath.Join(root, "foo"))
+	assert.ErrorIs(t, err, ErrKeyCollision)
+	assert.Equal(t, "cache entry for "+filepath.Join(root, "foo")+" belongs to "+filepath.Join(root, "Foo"), err.Error())
+	closeFn()
+
+	warm, stats := scanColliding(t, root, storagePath)
+	assert.Equal(t, cold, warm, "colliding entry is not merged into foo")
+	assert.Equal(t, int64(1), stats.KeyCollisions)
+	assert.Equal(t, int64(0), stats.CacheErrors)
+
+	warm, stats = scanColliding(t, root, storagePath)
+	assert.Equal(t, cold, warm)
+	assert.Equal(t, int64(0), stats.KeyCollisions, "entry of foo was written again")
+}
+
+func TestIncrementalAnalyzer_DuplicateNamesInListing(t *testing.T) {
+	root := createSubtreeFixture(t)
+	storagePath := t.TempDir()
+
+	scan := func(duplicate bool) (*Dir, *CacheStats) {
+		analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath})
+		listDir := analyzer.listDir
+		analyzer.listDir = func(path string) ([]os.DirEntry, error) {
+			files, err := listDir(path)
+			if duplicate && path == root {
+				// the subdirectory and the file are listed again, as some network filesystems do
+				files = append(files, files...)
+			}
+			return files, err
+		}
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		dir.UpdateStats(make(fs.HardLinkedItems))
+		return dir, analyzer.GetCacheStats()
+	}
+
+	expected, _ := scan(false)
+	assert.NoError(t, os.Chtimes(root, time.Now(), time.Now().Add(time.Hour)))
+
+	dir, stats := scan(true)
+	assert.Equal(t, int64(3), stats.DuplicateNamesSkipped)
+	assert.Len(t, dir.Files, 3)
+	assert.Equal(t, expected.ItemCount, dir.ItemCount)
+	assert.Equal(t, expected.Usage, dir.Usage)
+
+	// the warm scan uses the entries, the counts stay the same
+	dir, stats = scan(true)
+	assert.Zero(t, stats.DirsRescanned)
+	assert.Zero(t, stats.DuplicateNamesSkipped)
+	assert.Equal(t, expected.ItemCount, dir.ItemCount)
+	assert.Equal(t, expected.Usage, dir.Usage)
+}
+
+func TestIncrementalAnalyzer_DuplicateNamesInCache(t *testing.T) {
+	root := createSubtreeFixture(t)
+	storagePath := t.TempDir()
+	opts := IncrementalOptions{StoragePath: storagePath}
+
+	analyzer := CreateIncrementalAnalyzer(opts)
+	expected := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	// an entry with a child listed twice is corrupted, the directory is scanned again
+	path := filepath.Join(root, "a")
+	storage := NewIncrementalStorage(storagePath, root)
+	closeFn := mustOpen(t, storage)
+	meta, err := storage.LoadDirMetadata(path)
+	assert.NoError(t, err)
+	meta.Files = append(meta.Files, meta.Files[0])
+	assert.NoError(t, storage.StoreDirMetadata(meta))
+	_, err = storage.LoadDirMetadata(path)
+	assert.ErrorContains(t, err, "duplicate child")
+	closeFn()
+
+	analyzer = CreateIncrementalAnalyzer(opts)
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+	stats := analyzer.GetCacheStats()
+	assert.Equal(t, int64(1), stats.CacheErrors)
+	assert.Equal(t, expected.ItemCount, dir.ItemCount)
+	assert.Len(t, childByName(dir, "a").GetFiles(), 2)
+
+	// the entry was replaced by the scan
+	closeFn = mustOpen(t, storage)
+	defer closeFn()
+	_, err = storage.LoadDirMetadata(path)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,162 @@
+package analyze
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/dundee/gdu/v5/internal/common"
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+// captureStderr returns everything written to stderr by fn
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-output
+}
+
+func TestIncrementalAnalyzer_AnalyzeDirContract(t *testing.T) {
+	noIgnore := func(_, _ string) bool { return false }
+
+	tests := []struct {
+		name   string
+		setup  func(t *testing.T) (root, storagePath string, ignore common.ShouldDirBeIgnored)
+		status ScanStatus
+		flag   rune
+	}{
+		{
+			name: "completed",
+			setup: func(t *testing.T) (string, string, common.ShouldDirBeIgnored) {
+				return createTraceFixture(t), t.TempDir(), noIgnore
+			},
+			status: ScanCompleted,
+			flag:   ' ',
+		},
+		{
+			name: "storage open failure",
+			setup: func(t *testing.T) (string, string, common.ShouldDirBeIgnored) {
+				storagePath := filepath.Join(t.TempDir(), "file")
+				assert.NoError(t, os.WriteFile(storagePath, []byte("not a directory"), 0o600))
+				return createTraceFixture(t), storagePath, noIgnore
+			},
+			status: ScanFailed,
+			flag:   '!',
+		},
+		{
+			name: "missing root",
+			setup: func(t *testing.T) (string, string, common.ShouldDirBeIgnored) {
+				return filepath.Join(t.TempDir(), "missing"), t.TempDir(), noIgnore
+			},
+			status: ScanFailed,
+			flag:   '!',
+		},
+		{
+			name: "unreadable root",
+			setup: func(t *testing.T) (string, string, common.ShouldDirBeIgnored) {
+				if os.Geteuid() == 0 {
+					t.Skip("permissions are not checked for root")
+				}
+				root := createTraceFixture(t)
+				assert.NoError(t, os.Chmod(root, 0))
+				t.Cleanup(func() { os.Chmod(root, 0o755) })
+				return root, t.TempDir(), noIgnore
+			},
+			status: ScanFailed,
+			flag:   '!',
+		},
+		{
+			name: "panic",
+			setup: func(t *testing.T) (string, string, common.ShouldDirBeIgnored) {
+				return createTraceFixture(t), t.TempDir(), func(_, _ string) bool { panic("broken ignore function") }
+			},
+			status: ScanFailed,
+			flag:   '!',
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, storagePath, ignore := tt.setup(t)
+			goroutines := runtime.NumGoroutine()
+
+			analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath})
+			progressClosed := make(chan struct{})
+			go func() {
+				defer close(progressClosed)
+				for range analyzer.GetProgressChan() {
+				}
+			}()
+
+			var dir fs.Item
+			stderr := captureStderr(t, func() {
+				dir = analyzer.AnalyzeDir(root, ignore, false)
+			})
+
+			done := make(chan struct{})
+			go func() {
+				analyzer.GetDone().Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("done was not broadcast")
+			}
+			select {
+			case <-progressClosed:
+			case <-time.After(5 * time.Second):
+				t.Fatal("progress channel was not closed")
+			}
+
+			assert.NotNil(t, dir)
+			assert.Equal(t, tt.flag, dir.GetFlag())
+			assert.Equal(t, root, dir.GetPath())
+			result := analyzer.GetScanResult()
+			assert.Equal(t, tt.status, result.Status)
+			if tt.status == ScanFailed {
+				assert.Error(t, result.Err)
+			}
+			assert.Empty(t, stderr)
+			assert.True(t, waitForGoroutines(goroutines), "goroutines leaked")
+		})
+	}
+}
+
+func TestIncrementalAnalyzer_PanicError(t *testing.T) {
+	root := createTraceFixture(t)
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { panic("broken ignore function") }, false)
+	analyzer.GetDone().Wait()
+
+	var panicErr *ScanPanicError
+	assert.ErrorAs(t, analyzer.GetScanResult().Err, &panicErr)
+	assert.Equal(t, "broken ignore function", panicErr.Value)
+	assert.Contains(t, string(panicErr.Stack), "AnalyzeDir")
+	assert.Equal(t, "scan panicked: broken ignore function", panicErr.Error())
+	assert.False(t, analyzer.IsScanning())
+
+	// the analyzer can scan again
+	analyzer.ResetProgress()
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+	assert.Equal(t, ScanCompleted, analyzer.GetScanResult().Status)
+	assert.Len(t, dir.(*Dir).Files, 2)
+}
@@ -0,0 +1,57 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalAnalyzer_CtimeChanged(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	assert.NoError(t, os.Mkdir(sub, 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(sub, "file"), []byte("data"), 0o600))
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	scan := func() *CacheStats {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+		analyzer.GetDone().Wait()
+		return analyzer.GetScanResult().Stats
+	}
+	scan()
+	assert.Zero(t, scan().DirsRescanned)
+
+	// chmod changes ctime of the directory, not its mtime
+	before, err := os.Stat(root)
+	assert.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, os.Chmod(root, 0o700))
+	after, err := os.Stat(root)
+	assert.NoError(t, err)
+	assert.Equal(t, before.ModTime(), after.ModTime())
+
+	// the unchanged subdirectory is loaded from the cache
+	stats := scan()
+	assert.Equal(t, int64(1), stats.DirsRescanned)
+	assert.Equal(t, int64(1), stats.CacheHits)
+	assert.Zero(t, scan().DirsRescanned)
+}
+
+func TestCtimeChanged(t *testing.T) {
+	stat, err := os.Stat(t.TempDir())
+	assert.NoError(t, err)
+	ctime := changeTime(stat)
+	assert.False(t, ctime.IsZero())
+
+	assert.False(t, ctimeChanged(&IncrementalDirMetadata{Ctime: ctime}, stat))
+	assert.True(t, ctimeChanged(&IncrementalDirMetadata{Ctime: ctime.Add(-time.Second)}, stat))
+	// entries of older versions have no ctime
+	assert.False(t, ctimeChanged(&IncrementalDirMetadata{}, stat))
+}
@@ -0,0 +1,76 @@
+package analyze
+
+import "sync"
+
+// DecisionMap holds the cache decision of every directory of the last scan by its path
+// (see IncrementalOptions.RecordDecisions). Unlike DecisionTrace it is not limited and not logged.
+// Directories loaded from the cache together with their parent (DecisionInherited) are left out,
+// they got their content the same way as their parent
+type DecisionMap struct {
+	m         sync.Mutex
+	decisions map[string]CacheDecision
+	added     map[string]struct{} // directories missing in the cache entry of their parent
+}
+
+func newDecisionMap() *DecisionMap {
+	return &DecisionMap{
+		decisions: make(map[string]CacheDecision),
+		added:     make(map[string]struct{}),
+	}
+}
+
+// Decision returns the decision for the directory at path, false if none was recorded
+func (d *DecisionMap) Decision(path string) (CacheDecision, bool) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	decision, ok := d.decisions[path]
+	return decision, ok
+}
+
+// IsNew returns true if the directory at path was not present in the previous scan
+func (d *DecisionMap) IsNew(path string) bool {
+	d.m.Lock()
+	defer d.m.Unlock()
+	_, ok := d.added[path]
+	return ok
+}
+
+// Len returns number of the recorded directories
+func (d *DecisionMap) Len() int {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return len(d.decisions)
+}
+
+// Range calls fn for every recorded directory in no particular order
+func (d *DecisionMap) Range(fn func(path string, decision CacheDecision, isNew bool)) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	for path, decision := range d.decisions {
+		_, isNew := d.added[path]
+		fn(path, decision, isNew)
+	}
+}
+
+func (d *DecisionMap) add(path string, decision CacheDecision) {
+	if d == nil || decision == DecisionInherited {
+		return
+	}
+	d.m.Lock()
+	defer d.m.Unlock()
+	d.decisions[path] = decision
+}
+
+func (d *DecisionMap) addNew(path string) {
+	if d == nil {
+		return
+	}
+	d.m.Lock()
+	defer d.m.Unlock()
+	d.added[path] = struct{}{}
+}
+
+// GetDecisions returns decisions of the last scan, nil if they are not recorded
+func (a *IncrementalAnalyzer) GetDecisions() *DecisionMap {
+	return a.decisions
+}
@@ -0,0 +1,48 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalAnalyzer_RecordDecisions(t *testing.T) {
+	root := createInvalidationTree(t)
+	noIgnore := func(_, _ string) bool { return false }
+	opts := IncrementalOptions{StoragePath: t.TempDir(), RecordDecisions: true, MaxReportedPaths: 1}
+
+	analyzer := CreateIncrementalAnalyzer(opts)
+	analyzer.AnalyzeDir(root, noIgnore, false)
+	analyzer.GetDone().Wait()
+	assert.Nil(t, analyzer.GetDecisionTrace(), "decisions are recorded without tracing")
+
+	decisions := analyzer.GetDecisions()
+	assert.Equal(t, 6, decisions.Len())
+	decision, ok := decisions.Decision(filepath.Join(root, "a", "b", "c"))
+	assert.True(t, ok)
+	assert.Equal(t, DecisionMiss, decision)
+
+	for _, dir := range []string{"n1", "n2", "n3"} {
+		assert.NoError(t, os.Mkdir(filepath.Join(root, dir), 0o755))
+	}
+	analyzer = CreateIncrementalAnalyzer(opts)
+	analyzer.AnalyzeDir(root, noIgnore, false)
+	analyzer.GetDone().Wait()
+
+	decisions = analyzer.GetDecisions()
+	assert.Len(t, analyzer.GetCacheStats().NewDirs, 1)
+	for _, dir := range []string{"n1", "n2", "n3"} {
+		assert.True(t, decisions.IsNew(filepath.Join(root, dir)), "the map is not limited by MaxReportedPaths")
+	}
+	decision, _ = decisions.Decision(filepath.Join(root, "a"))
+	assert.Equal(t, DecisionHit, decision)
+	_, ok = decisions.Decision(filepath.Join(root, "a", "b"))
+	assert.False(t, ok, "inherited directories are left out")
+
+	analyzer = CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	analyzer.AnalyzeDir(root, noIgnore, false)
+	analyzer.GetDone().Wait()
+	assert.Nil(t, analyzer.GetDecisions())
+}
@@ -0,0 +1,57 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultMaxDepth is the depth ceiling used when IncrementalOptions.MaxDepth is not set.
+// Paths of deeper directories are longer than PATH_MAX of Linux even with one-character names
+const DefaultMaxDepth = 2048
+
+// tooDeepDir returns the directory below the depth ceiling with only its own size,
+// its content is not read and it is not cached
+func (a *IncrementalAnalyzer) tooDeepDir(path string) (*Dir, CacheDecision, uint64) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		log.Printf("Error stating directory %s: %v", path, err)
+		a.traceDecision(path, DecisionError, nil, nil)
+		return a.createErrorDir(path, err), DecisionError, 0
+	}
+
+	a.traceDecision(path, DecisionTooDeep, nil, stat)
+	a.stats.IncrementTooDeepDirs()
+	if !a.depthLogged.Swap(true) {
+		log.Warnf("Directory %s is more than %d levels deep, its content is not read", path, a.maxDepth)
+	}
+
+	self := &File{Usage: stat.Size()}
+	setPlatformSpecificAttrs(self, stat)
+	dir := &Dir{
+		File: &File{
+			Name:  filepath.Base(path),
+			Flag:  '>',
+			Size:  stat.Size(),
+			Usage: self.Usage,
+			Mtime: stat.ModTime(),
+		},
+		BasePath:  filepath.Dir(path),
+		ItemCount: 1,
+		Files:     make(fs.Files, 0),
+	}
+	return dir, DecisionTooDeep, a.deviceOf(stat)
+}
+
+// joinPath returns path of the entry name in directory dir.
+// Unlike filepath.Join it does not clean the result, which would walk the whole path
+// for every entry of deep trees. Paths of scanned directories are already clean
+func joinPath(dir, name string) string {
+	if strings.HasSuffix(dir, string(os.PathSeparator)) {
+		return dir + name
+	}
+	return dir + string(os.PathSeparator) + name
+}
@@ -0,0 +1,58 @@
+//go:build linux
+// +build linux
+
+package analyze
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+// createDeepChain creates chain of depth directories named "d" under root.
+// Directories are created relative to their parent, the full paths are longer than PATH_MAX
+func createDeepChain(t *testing.T, root string, depth int) {
+	t.Helper()
+	fd, err := unix.Open(root, unix.O_RDONLY|unix.O_DIRECTORY, 0)
+	assert.NoError(t, err)
+	for i := 0; i < depth; i++ {
+		assert.NoError(t, unix.Mkdirat(fd, "d", 0o755))
+		child, err := unix.Openat(fd, "d", unix.O_RDONLY|unix.O_DIRECTORY, 0)
+		assert.NoError(t, err)
+		assert.NoError(t, unix.Close(fd))
+		fd = child
+	}
+	assert.NoError(t, unix.Close(fd))
+}
+
+func TestIncrementalAnalyzer_DeepChain(t *testing.T) {
+	const depth, maxDepth = 10000, 1000
+	root := t.TempDir()
+	createDeepChain(t, root, depth)
+	opts := IncrementalOptions{StoragePath: t.TempDir(), MaxDepth: maxDepth}
+
+	for _, scan := range []string{"cold", "warm"} {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+
+		assert.Equal(t, ScanCompleted, analyzer.GetScanResult().Status, scan)
+		assert.Equal(t, int64(1), analyzer.GetCacheStats().TooDeepDirs, scan)
+
+		// every directory down to the ceiling counts itself and its descendants
+		level := 0
+		for ; level <= maxDepth; level++ {
+			if !assert.Equal(t, maxDepth+2-level, dir.ItemCount, "%s scan, level %d", scan, level) ||
+				!assert.Len(t, dir.Files, 1) {
+				return
+			}
+			dir = dir.Files[0].(*Dir)
+		}
+		assert.Equal(t, '>', dir.Flag)
+		assert.Equal(t, 1, dir.ItemCount)
+		assert.Empty(t, dir.Files, "directory below the ceiling is not read")
+		assert.Equal(t, "d", filepath.Base(dir.GetPath()))
+	}
+}
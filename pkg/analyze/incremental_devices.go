@@ -0,0 +1,157 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// addDeviceStats adds the directory processed with the decision to the statistics of its device
+// (0 if not known). size and took are the apparent size and the time of the directory
+// not accounted to its subdirectories
+func (a *IncrementalAnalyzer) addDeviceStats(
+	path string, dev uint64, decision CacheDecision, size int64, took time.Duration,
+) {
+	if dev == 0 {
+		return
+	}
+
+	delta := DeviceStats{Device: dev, Dirs: 1, ScanTime: took}
+	switch decision {
+	case DecisionHit, DecisionInherited, DecisionVerified, DecisionSummary, DecisionTrusted:
+		delta.CacheHits = 1
+		delta.BytesFromCache = size
+	case DecisionDuplicate:
+	default:
+		delta.Scanned = 1
+		delta.BytesScanned = size
+	}
+
+	a.mountsM.Lock()
+	mount, ok := a.mounts[dev]
+	if !ok && len(a.mounts) < maxTrackedDevices {
+		mount = a.mountPoint(path, dev)
+		a.mounts[dev] = mount
+	}
+	a.mountsM.Unlock()
+	delta.MountPoint = mount
+
+	a.stats.AddDeviceStats(delta)
+}
+
+// deviceOf returns device of the directory, 0 if not known
+func (a *IncrementalAnalyzer) deviceOf(stat os.FileInfo) uint64 {
+	if stat == nil {
+		return 0
+	}
+	id, ok := a.identify(stat)
+	if !ok {
+		return 0
+	}
+	return id.dev
+}
+
+// mountPoint returns the topmost ancestor of path (or path itself) located on the device
+func (a *IncrementalAnalyzer) mountPoint(path string, dev uint64) string {
+	mount, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	for {
+		parent := filepath.Dir(mount)
+		if parent == mount {
+			return mount
+		}
+		info, err := os.Stat(parent)
+		if err != nil {
+			return mount
+		}
+		if id, ok := a.identify(info); !ok || id.dev != dev {
+			return mount
+		}
+		mount = parent
+	}
+}
+
+// deviceChanged returns true if checking of devices is enabled
+// and the cache entry was stored for another device than the directory is on now
+func (a *IncrementalAnalyzer) deviceChanged(cached *IncrementalDirMetadata, stat os.FileInfo) bool {
+	if !a.checkDevice || cached.Dev == 0 {
+		return false
+	}
+	id, ok := a.identify(stat)
+	return ok && id.dev != cached.Dev
+}
+
+// ctimeChanged returns true if the cache entry was stored with another ctime than the directory
+// has now, e.g. after chmod or chown which do not change mtime. Entries of older versions
+// and platforms without ctime have none
+func ctimeChanged(cached *IncrementalDirMetadata, stat os.FileInfo) bool {
+	if cached.Ctime.IsZero() {
+		return false
+	}
+	ctime := changeTime(stat)
+	return !ctime.IsZero() && !ctime.Equal(cached.Ctime)
+}
+
+// scannedDevice returns device of the scanned directory at path if subdirectories
+// on other devices are left out (IncrementalOptions.NoCross), 0 otherwise or if it is not known
+func (a *IncrementalAnalyzer) scannedDevice(path string) uint64 {
+	if !a.noCross {
+		return 0
+	}
+	stat, err := a.statPath(path)
+	if err != nil {
+		return 0
+	}
+	return a.deviceOf(stat)
+}
+
+// otherDevice returns true if the directory is on another device than the scanned one
+// and other filesystems are left out
+func (a *IncrementalAnalyzer) otherDevice(stat os.FileInfo) bool {
+	if a.rootDev == 0 {
+		return false
+	}
+	dev := a.deviceOf(stat)
+	return dev != 0 && dev != a.rootDev
+}
+
+// onOtherDevice returns true if the directory at path is on another device than the scanned one
+// and other filesystems are left out
+func (a *IncrementalAnalyzer) onOtherDevice(path string) bool {
+	if a.rootDev == 0 {
+		return false
+	}
+	stat, err := a.statPath(path)
+	return err == nil && a.otherDevice(stat)
+}
+
+// mountedSince returns true if the subdirectory at path loaded from the cache of its parent
+// is on another device than the scanned directory now, its entries are removed then
+func (a *IncrementalAnalyzer) mountedSince(path string) bool {
+	if !a.onOtherDevice(path) {
+		return false
+	}
+	a.leaveOutMount(path)
+	a.traceDecision(path, DecisionMount, nil, nil)
+	return true
+}
+
+// leaveOutMount removes cache entries of the directory at path on another filesystem
+// and of its subdirectories, as it is left out of the tree. They were written when the directory
+// was not a mount point yet or by a scan crossing filesystems
+func (a *IncrementalAnalyzer) leaveOutMount(path string) {
+	log.Debugf("Leaving out %s on another filesystem", path)
+	removed, err := a.storage.DeleteSubtree(path)
+	if err != nil {
+		a.stats.IncrementCacheErrors()
+		log.Printf("Warning: Failed to remove cache entries of %s on another filesystem: %v", path, err)
+		return
+	}
+	if removed > 0 {
+		log.Printf("Removed %d cache entries of %s, which is on another filesystem", removed, path)
+	}
+}
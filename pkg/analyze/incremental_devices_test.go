@@ -0,0 +1,199 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// twoDevices puts directories with names starting with "nfs" on device 2, the rest on device 1
+func twoDevices(info os.FileInfo) (dirIdentity, bool) {
+	id, _ := getDirIdentity(info)
+	id.dev = 1
+	if strings.HasPrefix(info.Name(), "nfs") {
+		id.dev = 2
+	}
+	if id.ino == 0 {
+		// platform without identities
+		id.ino = uint64(len(info.Name()))
+	}
+	return id, true
+}
+
+func createDevicesFixture(t *testing.T) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "root")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "local", "sub"), 0o755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "nfs", "nfs_data"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "local", "file"), []byte("local"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "nfs", "nfs_data", "file"), []byte("remote data"), 0o600))
+	return root
+}
+
+func scanDevices(t *testing.T, root string, opts IncrementalOptions) *IncrementalAnalyzer {
+	t.Helper()
+	analyzer := CreateIncrementalAnalyzer(opts)
+	analyzer.identify = twoDevices
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+	return analyzer
+}
+
+func TestIncrementalAnalyzer_DeviceStats(t *testing.T) {
+	root := createDevicesFixture(t)
+	storagePath := t.TempDir()
+
+	analyzer := scanDevices(t, root, IncrementalOptions{StoragePath: storagePath})
+	stats := analyzer.GetCacheStats()
+
+	assert.Len(t, stats.Devices, 2)
+	local, nfs := stats.Devices[1], stats.Devices[2]
+	assert.Equal(t, int64(3), local.Dirs)
+	assert.Equal(t, int64(3), local.Scanned)
+	assert.Equal(t, int64(0), local.CacheHits)
+	assert.Equal(t, int64(2), nfs.Dirs)
+	assert.Equal(t, int64(2), nfs.Scanned)
+	assert.Equal(t, filepath.Join(root, "nfs"), nfs.MountPoint)
+	assert.Equal(t, int64(11)+2*nfsDirSize(t, root), nfs.BytesScanned)
+	assert.Equal(t, 0.0, nfs.HitRate())
+
+	devices := stats.DeviceList()
+	assert.Equal(t, []uint64{1, 2}, []uint64{devices[0].Device, devices[1].Device},
+		"Mount point of the local device is an ancestor of the root")
+
+	// warm scan, directories loaded with the root are hits on their own devices
+	analyzer = scanDevices(t, root, IncrementalOptions{StoragePath: storagePath})
+	stats = analyzer.GetCacheStats()
+	assert.Equal(t, int64(3), stats.Devices[1].CacheHits)
+	assert.Equal(t, int64(2), stats.Devices[2].CacheHits)
+	assert.Equal(t, int64(0), stats.Devices[2].Scanned)
+	assert.Equal(t, 100.0, stats.Devices[2].HitRate())
+	assert.Equal(t, int64(11)+2*nfsDirSize(t, root), stats.Devices[2].BytesFromCache)
+}
+
+func nfsDirSize(t *testing.T, root string) int64 {
+	t.Helper()
+	info, err := os.Stat(filepath.Join(root, "nfs"))
+	assert.NoError(t, err)
+	return info.Size()
+}
+
+func TestIncrementalAnalyzer_DeviceStatsSnapshot(t *testing.T) {
+	stats := NewCacheStats()
+	stats.AddDeviceStats(DeviceStats{Device: 1, MountPoint: "/", Dirs: 1, Scanned: 1, ScanTime: time.Second})
+	stats.AddDeviceStats(DeviceStats{Device: 1, MountPoint: "/", Dirs: 1, CacheHits: 1})
+
+	snapshot := stats.Snapshot()
+	stats.AddDeviceStats(DeviceStats{Device: 1, Dirs: 1})
+
+	assert.Equal(t, int64(2), snapshot.Devices[1].Dirs)
+	assert.Equal(t, 50.0, snapshot.Devices[1].HitRate())
+	assert.Equal(t, time.Second, snapshot.Devices[1].ScanTime)
+	assert.Equal(t, int64(3), stats.Devices[1].Dirs)
+}
+
+func TestIncrementalAnalyzer_DeviceStatsBounded(t *testing.T) {
+	stats := NewCacheStats()
+	for dev := uint64(1); dev <= maxTrackedDevices+10; dev++ {
+		stats.AddDeviceStats(DeviceStats{Device: dev, Dirs: 1})
+	}
+
+	assert.Len(t, stats.Devices, maxTrackedDevices+1)
+	assert.Equal(t, int64(10), stats.Devices[OtherDevices].Dirs)
+}
+
+func TestIncrementalAnalyzer_RescanOnDeviceChange(t *testing.T) {
+	root := createDevicesFixture(t)
+	storagePath := t.TempDir()
+
+	scanDevices(t, root, IncrementalOptions{StoragePath: storagePath})
+
+	// another disk is mounted at the same path
+	moved := func(info os.FileInfo) (dirIdentity, bool) {
+		id, ok := twoDevices(info)
+		if id.dev == 2 {
+			id.dev = 3
+		}
+		return id, ok
+	}
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath})
+	analyzer.identify = moved
+	analyzer.AnalyzeDir(filepath.Join(root, "nfs"), func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+	assert.Equal(t, int64(1), analyzer.GetCacheStats().CacheHits, "Device is not checked by default")
+
+	analyzer = CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath, RescanOnDeviceChange: true})
+	analyzer.identify = moved
+	analyzer.AnalyzeDir(filepath.Join(root, "nfs"), func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+	assert.Equal(t, int64(0), analyzer.GetCacheStats().CacheHits)
+	assert.Equal(t, int64(2), analyzer.GetCacheStats().Devices[3].Scanned)
+}
+
+func TestIncrementalAnalyzer_NoCross(t *testing.T) {
+	root := createDevicesFixture(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir(), NoCross: true, TraceDecisions: true}
+
+	names := func(dir *Dir) []string {
+		res := make([]string, 0, len(dir.Files))
+		for _, item := range dir.Files {
+			res = append(res, item.GetName())
+		}
+		return res
+	}
+	cachedPaths := func() []string {
+		storage := NewIncrementalStorage(opts.StoragePath, root)
+		closeFn, err := storage.Open()
+		assert.NoError(t, err)
+		defer closeFn()
+		paths := make([]string, 0)
+		for _, path := range []string{"local", "local/sub", "nfs", "nfs/nfs_data"} {
+			if _, err := storage.LoadDirMetadata(filepath.Join(root, path)); err == nil {
+				paths = append(paths, path)
+			}
+		}
+		return paths
+	}
+
+	// nfs is mounted
+	analyzer := CreateIncrementalAnalyzer(opts)
+	analyzer.identify = twoDevices
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+	assert.Equal(t, []string{"local"}, names(dir))
+	assert.Equal(t, 4, dir.ItemCount, "root, local, local/sub and local/file")
+	decisions := make(map[string]CacheDecision)
+	for _, entry := range analyzer.GetDecisionTrace().Entries() {
+		decisions[entry.Path] = entry.Decision
+	}
+	assert.Equal(t, DecisionMount, decisions[filepath.Join(root, "nfs")])
+	assert.Len(t, analyzer.GetCacheStats().Devices, 1)
+	assert.Equal(t, []string{"local", "local/sub"}, cachedPaths())
+
+	// nfs is unmounted, the entry of root does not hold the directory below the mount point,
+	// so it is found by a full scan
+	full := opts
+	full.ForceFullScan = true
+	analyzer = CreateIncrementalAnalyzer(full)
+	dir = analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+	assert.Equal(t, []string{"local", "nfs"}, names(dir))
+	assert.Equal(t, []string{"local", "local/sub", "nfs", "nfs/nfs_data"}, cachedPaths())
+	unmounted := dir.Size
+
+	// nfs is mounted again, its entries cached below the mount point are removed
+	analyzer = CreateIncrementalAnalyzer(opts)
+	analyzer.identify = twoDevices
+	dir = analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+	assert.Equal(t, int64(1), analyzer.GetCacheStats().CacheHits)
+	assert.Equal(t, []string{"local"}, names(dir))
+	assert.Equal(t, 4, dir.ItemCount)
+	assert.Less(t, dir.Size, unmounted)
+	assert.Equal(t, []string{"local", "local/sub"}, cachedPaths())
+}
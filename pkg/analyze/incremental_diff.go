@@ -0,0 +1,217 @@
+package analyze
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// DeltaStatus tells how a directory changed since the previous scan
+type DeltaStatus string
+
+const (
+	// DeltaAdded is a directory which was not present in the previous scan
+	DeltaAdded DeltaStatus = "added"
+	// DeltaRemoved is a directory of the previous scan which is gone
+	DeltaRemoved DeltaStatus = "removed"
+	// DeltaChanged is a directory whose size or item count changed
+	DeltaChanged DeltaStatus = "changed"
+)
+
+// DirDelta is the change of a directory since the previous scan (see IncrementalOptions.Diff).
+// Totals of the previous scan are zero for added directories, the current ones for removed directories
+type DirDelta struct {
+	Path     string      `json:"path"`
+	OldSize  int64       `json:"oldSize"`
+	NewSize  int64       `json:"newSize"`
+	OldUsage int64       `json:"oldUsage"`
+	NewUsage int64       `json:"newUsage"`
+	OldItems int         `json:"oldItems"`
+	NewItems int         `json:"newItems"`
+	Status   DeltaStatus `json:"status"`
+}
+
+// Growth returns the change of disk usage (or of apparent size) of the directory,
+// negative if it shrank
+func (d DirDelta) Growth(useApparentSize bool) int64 {
+	if useApparentSize {
+		return d.NewSize - d.OldSize
+	}
+	return d.NewUsage - d.OldUsage
+}
+
+// TopGrowers returns at most limit directories which grew the most (0 = all of them),
+// the largest growth first. Directories which did not grow are left out
+func TopGrowers(deltas []DirDelta, limit int, useApparentSize bool) []DirDelta {
+	growers := make([]DirDelta, 0)
+	for _, delta := range deltas {
+		if delta.Growth(useApparentSize) > 0 {
+			growers = append(growers, delta)
+		}
+	}
+	sort.SliceStable(growers, func(i, j int) bool {
+		return growers[i].Growth(useApparentSize) > growers[j].Growth(useApparentSize)
+	})
+	if limit > 0 && len(growers) > limit {
+		growers = growers[:limit]
+	}
+	return growers
+}
+
+// dirTotals are totals of a directory held by its cache entry
+type dirTotals struct {
+	size, usage int64
+	items       int
+}
+
+// scanDiff collects changes of directories of the running scan against the cache,
+// it is safe for concurrent use by the workers of the scan
+type scanDiff struct {
+	m        sync.Mutex
+	previous map[string]dirTotals // totals of the directories with a cache entry before the scan
+	added    map[string]struct{}  // directories missing in the cache entry of their parent
+	deltas   []DirDelta
+}
+
+func newScanDiff() *scanDiff {
+	return &scanDiff{
+		previous: make(map[string]dirTotals),
+		added:    make(map[string]struct{}),
+		deltas:   make([]DirDelta, 0),
+	}
+}
+
+// remember records totals of the cache entry before it is replaced by the scan
+func (d *scanDiff) remember(cached *IncrementalDirMetadata) {
+	if d == nil {
+		return
+	}
+	d.m.Lock()
+	defer d.m.Unlock()
+	d.previous[cached.Path] = dirTotals{size: cached.Size, usage: cached.Usage, items: cached.ItemCount}
+}
+
+// add records a new subdirectory, its own subdirectories are not reported
+func (d *scanDiff) add(path string) {
+	if d == nil {
+		return
+	}
+	d.m.Lock()
+	defer d.m.Unlock()
+	d.added[path] = struct{}{}
+}
+
+// remove records the subdirectories of previous with the given names as removed,
+// their descendants are not reported
+func (d *scanDiff) remove(previous *IncrementalDirMetadata, names map[string]struct{}) {
+	if d == nil || len(names) == 0 {
+		return
+	}
+	d.m.Lock()
+	defer d.m.Unlock()
+	for _, f := range previous.Files {
+		if _, ok := names[f.Name]; !ok || !f.IsDir {
+			continue
+		}
+		d.deltas = append(d.deltas, DirDelta{
+			Path:     filepath.Join(previous.Path, f.Name),
+			OldSize:  f.Size,
+			OldUsage: f.Usage,
+			OldItems: f.ItemCount,
+			Status:   DeltaRemoved,
+		})
+	}
+}
+
+// collect compares the scanned tree with the remembered totals.
+// Directories loaded with their parent from the cache are not changed, so they are not remembered
+// and neither reported
+func (d *scanDiff) collect(root *Dir) {
+	if d == nil {
+		return
+	}
+	var walk func(dir *Dir)
+	walk = func(dir *Dir) {
+		if dir.DuplicateOf != "" {
+			return
+		}
+		path := dir.GetPath()
+		current := dirTotals{size: dir.Size, usage: dir.Usage, items: dir.ItemCount}
+		if old, ok := d.previous[path]; ok && old != current {
+			d.deltas = append(d.deltas, DirDelta{
+				Path:     path,
+				OldSize:  old.size,
+				NewSize:  current.size,
+				OldUsage: old.usage,
+				NewUsage: current.usage,
+				OldItems: old.items,
+				NewItems: current.items,
+				Status:   DeltaChanged,
+			})
+		} else if _, ok := d.added[path]; ok {
+			d.deltas = append(d.deltas, DirDelta{
+				Path:     path,
+				NewSize:  current.size,
+				NewUsage: current.usage,
+				NewItems: current.items,
+				Status:   DeltaAdded,
+			})
+		}
+		for _, item := range dir.Files {
+			if subdir, ok := item.(*Dir); ok {
+				walk(subdir)
+			}
+		}
+	}
+	walk(root)
+}
+
+// rememberEntry records totals of the cache entry of the directory at path,
+// which is scanned without loading it
+func (a *IncrementalAnalyzer) rememberEntry(path string) {
+	if a.diff == nil {
+		return
+	}
+	if cached, err := a.storage.LoadDirMetadata(path); err == nil {
+		a.diff.remember(cached)
+	}
+}
+
+// ComputeDiff returns directories of the last scan whose size or item count changed
+// since the previous scan, together with the added and the removed ones, ordered by path.
+// It needs IncrementalOptions.Diff, nil is returned without it.
+// Directories are compared with their cache entries, so the first scan of a tree
+// and the summary fast path (TrustRootMtime) report no changes, and neither does a cancelled scan.
+// Entries removed by InvalidatePaths are not compared.
+// With IncrementalOptions.DiffFromLabel the labelled scans are compared instead (see DiffScans),
+// DiffError tells why they could not be
+func (a *IncrementalAnalyzer) ComputeDiff() []DirDelta {
+	if a.result == nil || a.result.Status == ScanCancelled {
+		return nil
+	}
+	if a.fromLabel != "" {
+		deltas := make([]DirDelta, len(a.labelDiff))
+		copy(deltas, a.labelDiff)
+		return deltas
+	}
+	if a.diff == nil {
+		return nil
+	}
+	deltas := make([]DirDelta, len(a.diff.deltas))
+	copy(deltas, a.diff.deltas)
+	sort.Slice(deltas, func(i, j int) bool {
+		return deltas[i].Path < deltas[j].Path
+	})
+	return deltas
+}
+
+// DiffError returns why the scans selected by IncrementalOptions.DiffFromLabel and DiffToLabel
+// could not be compared by the last scan, e.g. because no scan has the label
+func (a *IncrementalAnalyzer) DiffError() error {
+	return a.diffErr
+}
+
+// DiffLabels returns labels of the scans compared by ComputeDiff, empty if the diff is not by labels
+func (a *IncrementalAnalyzer) DiffLabels() (from, to string) {
+	return a.fromLabel, a.toLabel
+}
@@ -0,0 +1,126 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalAnalyzer_ComputeDiff(t *testing.T) {
+	root := createInvalidationTree(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir(), Diff: true}
+	noIgnore := func(_, _ string) bool { return false }
+
+	analyzer := CreateIncrementalAnalyzer(opts)
+	cold := analyzer.AnalyzeDir(root, noIgnore, false).(*Dir)
+	analyzer.GetDone().Wait()
+	assert.Empty(t, analyzer.ComputeDiff(), "the first scan has nothing to compare with")
+
+	removed := cold.Files[2].(*Dir)
+	assert.Equal(t, "x", removed.GetName())
+	assert.NoError(t, os.RemoveAll(filepath.Join(root, "x")))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "n", "m"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "n", "m", "file"), make([]byte, 10), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a", "new"), make([]byte, 100), 0o600))
+
+	analyzer = CreateIncrementalAnalyzer(opts)
+	dir := analyzer.AnalyzeDir(root, noIgnore, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	deltas := analyzer.ComputeDiff()
+	assert.Len(t, deltas, 4)
+	assert.Equal(t, DirDelta{
+		Path:     root,
+		OldSize:  cold.Size,
+		NewSize:  dir.Size,
+		OldUsage: cold.Usage,
+		NewUsage: dir.Usage,
+		OldItems: cold.ItemCount,
+		NewItems: dir.ItemCount,
+		Status:   DeltaChanged,
+	}, deltas[0])
+
+	assert.Equal(t, filepath.Join(root, "a"), deltas[1].Path)
+	assert.Equal(t, DeltaChanged, deltas[1].Status)
+	assert.Equal(t, int64(100), deltas[1].Growth(true))
+	assert.Equal(t, 1, deltas[1].NewItems-deltas[1].OldItems)
+
+	assert.Equal(t, filepath.Join(root, "n"), deltas[2].Path, "subdirectories of a new directory are not reported")
+	assert.Equal(t, DeltaAdded, deltas[2].Status)
+	assert.Zero(t, deltas[2].OldSize)
+	assert.Equal(t, dir.Files[2].GetSize(), deltas[2].NewSize)
+
+	assert.Equal(t, DirDelta{
+		Path:     filepath.Join(root, "x"),
+		OldSize:  removed.Size,
+		OldUsage: removed.Usage,
+		OldItems: removed.ItemCount,
+		Status:   DeltaRemoved,
+	}, deltas[3])
+
+	// unchanged directories are not reported
+	analyzer = CreateIncrementalAnalyzer(opts)
+	analyzer.AnalyzeDir(root, noIgnore, false)
+	analyzer.GetDone().Wait()
+	assert.Empty(t, analyzer.ComputeDiff())
+}
+
+func TestIncrementalAnalyzer_ComputeDiffRefreshed(t *testing.T) {
+	root := createInvalidationTree(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir(), Diff: true}
+	noIgnore := func(_, _ string) bool { return false }
+
+	analyzer := CreateIncrementalAnalyzer(opts)
+	analyzer.AnalyzeDir(root, noIgnore, false)
+	analyzer.GetDone().Wait()
+
+	// growing a file changes no mtime, the change is found only by the refresh
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "x", "y", "file"), make([]byte, 100), 0o600))
+
+	refreshing := opts
+	refreshing.RefreshPaths = []string{filepath.Join(root, "x")}
+	analyzer = CreateIncrementalAnalyzer(refreshing)
+	analyzer.AnalyzeDir(root, noIgnore, false)
+	analyzer.GetDone().Wait()
+
+	deltas := analyzer.ComputeDiff()
+	paths := make([]string, 0, len(deltas))
+	for _, delta := range deltas {
+		assert.Equal(t, DeltaChanged, delta.Status)
+		assert.Equal(t, int64(96), delta.Growth(true))
+		paths = append(paths, delta.Path)
+	}
+	assert.Equal(t, []string{root, filepath.Join(root, "x"), filepath.Join(root, "x", "y")}, paths)
+}
+
+func TestIncrementalAnalyzer_ComputeDiffDisabled(t *testing.T) {
+	root := createInvalidationTree(t)
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	assert.Nil(t, analyzer.ComputeDiff())
+}
+
+func TestTopGrowers(t *testing.T) {
+	deltas := []DirDelta{
+		{Path: "/a", OldSize: 10, NewSize: 20, OldUsage: 100, NewUsage: 50},
+		{Path: "/b", NewSize: 30, NewUsage: 30, Status: DeltaAdded},
+		{Path: "/c", OldSize: 5, OldUsage: 5, Status: DeltaRemoved},
+		{Path: "/d", OldSize: 1, NewSize: 11, OldUsage: 1, NewUsage: 11},
+	}
+
+	paths := func(deltas []DirDelta) []string {
+		result := make([]string, 0, len(deltas))
+		for _, delta := range deltas {
+			result = append(result, delta.Path)
+		}
+		return result
+	}
+	assert.Equal(t, []string{"/b", "/a", "/d"}, paths(TopGrowers(deltas, 0, true)))
+	assert.Equal(t, []string{"/b", "/d"}, paths(TopGrowers(deltas, 0, false)), "/a shrank on the disk")
+	assert.Equal(t, []string{"/b"}, paths(TopGrowers(deltas, 1, true)))
+	assert.Empty(t, TopGrowers(nil, 5, true))
+}
@@ -0,0 +1,122 @@
+package analyze
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// previewSampleSize is the number of keys listed by MutationPreview
+const previewSampleSize = 20
+
+// MutationPreview describes what a cache mutation would remove, it is returned by its dry run
+type MutationPreview struct {
+	Entries int      // number of keys which would be removed
+	Bytes   int64    // approximate size of the keys and their values
+	Sample  []string // first keys which would be removed, in key order
+}
+
+// SampleDropped returns number of the keys not listed in Sample
+func (p *MutationPreview) SampleDropped() int {
+	return p.Entries - len(p.Sample)
+}
+
+// keyMatcher selects keys removed by a cache mutation.
+// The destructive operations and their dry runs share it, so the preview matches the real run
+type keyMatcher struct {
+	prefixes []string
+	match    func(key []byte) bool // nil matches every key with one of the prefixes
+}
+
+// subtreeMatcher selects metadata of directory at path and of all its descendants
+// together with their pages
+func (s *IncrementalStorage) subtreeMatcher(path string) keyMatcher {
+	return keyMatcher{
+		prefixes: []string{string(s.makeKey(path)), KeyPrefixDirPage + path},
+		match: func(key []byte) bool {
+			if bytes.HasPrefix(key, []byte(KeyPrefixDirPage)) {
+				dir, ok := dirPageKeyPath(key)
+				return ok && inSubtree(dir, path)
+			}
+			return inSubtree(string(key[len(KeyPrefixDirMetadata):]), path)
+		},
+	}
+}
+
+// clearMatcher selects all keys except the schema version
+func clearMatcher() keyMatcher {
+	return keyMatcher{
+		prefixes: []string{""},
+		match: func(key []byte) bool {
+			return !bytes.Equal(key, schemaKey())
+		},
+	}
+}
+
+// clearPreservingHistoryMatcher selects all namespaces except the scan history and annotations
+func clearPreservingHistoryMatcher() keyMatcher {
+	return keyMatcher{
+		prefixes: []string{KeyPrefixDirMetadata, KeyPrefixDirPage, KeyPrefixRootSummary, KeyPrefixMarker, KeyPrefixInode},
+	}
+}
+
+// matchingKeys calls fn for every key selected by m, values are not fetched.
+// s.m must be held and the storage open
+func (s *IncrementalStorage) matchingKeys(m keyMatcher, fn func(item *badger.Item)) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for _, prefix := range m.prefixes {
+			p := []byte(prefix)
+			for it.Seek(p); it.ValidForPrefix(p); it.Next() {
+				if m.match == nil || m.match(it.Item().Key()) {
+					fn(it.Item())
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// preview returns what removing the keys selected by m would remove
+func (s *IncrementalStorage) preview(m keyMatcher) (*MutationPreview, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.db == nil {
+		return nil, fmt.Errorf("storage is not open")
+	}
+
+	result := &MutationPreview{}
+	sample := NewBoundedList[string](previewSampleSize)
+	err := s.matchingKeys(m, func(item *badger.Item) {
+		result.Entries++
+		result.Bytes += item.EstimatedSize()
+		sample.Add(string(item.Key()))
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Sample = sample.Items()
+	return result, nil
+}
+
+// DeleteSubtreeDryRun returns what DeleteSubtree(path) would remove without removing anything
+func (s *IncrementalStorage) DeleteSubtreeDryRun(path string) (*MutationPreview, error) {
+	return s.preview(s.subtreeMatcher(path))
+}
+
+// ClearCacheDryRun returns what ClearCache would remove without removing anything
+func (s *IncrementalStorage) ClearCacheDryRun() (*MutationPreview, error) {
+	return s.preview(clearMatcher())
+}
+
+// ClearCachePreservingHistoryDryRun returns what ClearCachePreservingHistory would remove
+// without removing anything
+func (s *IncrementalStorage) ClearCachePreservingHistoryDryRun() (*MutationPreview, error) {
+	return s.preview(clearPreservingHistoryMatcher())
+}
@@ -0,0 +1,93 @@
+package analyze
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// seedDryRunCache stores metadata of a small tree together with entries of other namespaces
+func seedDryRunCache(t *testing.T) (*IncrementalStorage, func()) {
+	t.Helper()
+	storage := NewIncrementalStorage(t.TempDir(), "/test")
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+
+	paths := []string{"/test", "/test/a", "/test/a/b", "/test/ab"}
+	for i := 0; i < 30; i++ {
+		paths = append(paths, fmt.Sprintf("/test/a/b/%02d", i))
+	}
+	for _, path := range paths {
+		assert.NoError(t, storage.StoreDirMetadata(&IncrementalDirMetadata{Path: path, CachedAt: time.Now()}))
+	}
+	assert.NoError(t, storage.SetAnnotation("/test/a", "keep"))
+	assert.NoError(t, storage.StoreRootSummary(&RootSummary{Path: "/test", Status: ScanCompleted}))
+	return storage, closeFn
+}
+
+func countKeys(t *testing.T, storage *IncrementalStorage) int {
+	t.Helper()
+	count := 0
+	assert.NoError(t, storage.Iterate("", func(_, _ []byte) error {
+		count++
+		return nil
+	}))
+	return count
+}
+
+func TestIncrementalStorage_DeleteSubtreeDryRun(t *testing.T) {
+	storage, closeFn := seedDryRunCache(t)
+	defer closeFn()
+
+	before := countKeys(t, storage)
+	preview, err := storage.DeleteSubtreeDryRun("/test/a")
+	assert.NoError(t, err)
+	assert.Equal(t, 32, preview.Entries)
+	assert.Positive(t, preview.Bytes)
+	assert.Len(t, preview.Sample, previewSampleSize)
+	assert.Equal(t, 32-previewSampleSize, preview.SampleDropped())
+	assert.Equal(t, KeyPrefixDirMetadata+"/test/a", preview.Sample[0])
+	assert.Equal(t, before, countKeys(t, storage), "dry run must not remove anything")
+
+	deleted, err := storage.DeleteSubtree("/test/a")
+	assert.NoError(t, err)
+	assert.Equal(t, preview.Entries, deleted)
+	assert.Equal(t, before-deleted, countKeys(t, storage))
+}
+
+func TestIncrementalStorage_ClearCacheDryRun(t *testing.T) {
+	storage, closeFn := seedDryRunCache(t)
+	defer closeFn()
+
+	preview, err := storage.ClearCacheDryRun()
+	assert.NoError(t, err)
+	assert.Equal(t, countKeys(t, storage)-1, preview.Entries, "schema version is kept")
+
+	count, err := storage.ClearCache()
+	assert.NoError(t, err)
+	assert.Equal(t, preview.Entries, count)
+}
+
+func TestIncrementalStorage_ClearCachePreservingHistoryDryRun(t *testing.T) {
+	storage, closeFn := seedDryRunCache(t)
+	defer closeFn()
+
+	before := countKeys(t, storage)
+	preview, err := storage.ClearCachePreservingHistoryDryRun()
+	assert.NoError(t, err)
+	assert.Equal(t, 35, preview.Entries)
+
+	assert.NoError(t, storage.ClearCachePreservingHistory())
+	assert.Equal(t, before-preview.Entries, countKeys(t, storage))
+	text, err := storage.GetAnnotation("/test/a")
+	assert.NoError(t, err)
+	assert.Equal(t, "keep", text)
+}
+
+func TestIncrementalStorage_DryRunClosed(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/test")
+	_, err := storage.ClearCacheDryRun()
+	assert.Error(t, err)
+}
@@ -0,0 +1,86 @@
+package analyze
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"time"
+)
+
+// DumpedDir is one line of DumpDirs, the totals of a directory read from its cache entry
+type DumpedDir struct {
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	Usage      int64     `json:"usage"`
+	ItemCount  int       `json:"item_count"`
+	Mtime      time.Time `json:"mtime"`
+	CachedAt   time.Time `json:"cached_at"`
+	ChildCount int       `json:"child_count"`
+	Flags      string    `json:"flags"` // flag of the directory as shown in the listings ('!' read error, '.' error below), empty if none
+}
+
+// DumpDirs writes one JSON object per cached directory under prefix (all directories if empty)
+// to w in key order and returns their number. Only the cache is read, so the directories
+// don't have to exist anymore and the storage can be opened by OpenReadOnly.
+// Children of large directories are counted from their pages.
+// Entries which can't be decoded are skipped, they are reported by CheckIntegrity
+func (s *IncrementalStorage) DumpDirs(prefix string, w io.Writer) (int, error) {
+	if prefix != "" {
+		prefix = filepath.Clean(prefix)
+	}
+
+	// the pages are in their own namespace, so the children of paged directories are counted first
+	pagedChildren := make(map[string]int)
+	err := s.Iterate(KeyPrefixDirPage+prefix, func(key, value []byte) error {
+		path, ok := dirPageKeyPath(key)
+		if !ok || (prefix != "" && !inSubtree(path, prefix)) {
+			return nil
+		}
+		var files []FileMetadata
+		if err := gob.NewDecoder(bytes.NewBuffer(value)).Decode(&files); err != nil {
+			return nil
+		}
+		pagedChildren[path] += len(files)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	encoder := json.NewEncoder(w)
+	count := 0
+	err = s.Iterate(KeyPrefixDirMetadata+prefix, func(key, value []byte) error {
+		path := string(key[len(KeyPrefixDirMetadata):])
+		if prefix != "" && !inSubtree(path, prefix) {
+			return nil
+		}
+		meta, err := decodeDirMetadata(path, value)
+		if err != nil {
+			return nil
+		}
+
+		dumped := DumpedDir{
+			Path:       path,
+			Size:       meta.Size,
+			Usage:      meta.Usage,
+			ItemCount:  meta.ItemCount,
+			Mtime:      meta.Mtime,
+			CachedAt:   meta.CachedAt,
+			ChildCount: len(meta.Files),
+		}
+		if meta.Pages > 0 {
+			dumped.ChildCount = pagedChildren[path]
+		}
+		if meta.Flag != 0 && meta.Flag != ' ' {
+			dumped.Flags = string(meta.Flag)
+		}
+		if err := encoder.Encode(dumped); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
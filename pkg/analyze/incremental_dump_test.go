@@ -0,0 +1,76 @@
+package analyze
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalStorage_DumpDirs(t *testing.T) {
+	dir := t.TempDir()
+	storage := NewIncrementalStorage(dir, "")
+	storage.pageSize = 10
+	closeFn := mustOpen(t, storage)
+
+	cachedAt := time.Date(2026, 10, 16, 10, 12, 3, 0, time.UTC)
+	seeded := map[string]*IncrementalDirMetadata{}
+	for path, children := range map[string]int{"/srv": 3, "/srv/big": 25, "/srv/big/sub": 0, "/srvx": 1} {
+		meta := largeDirMetadata(path, children)
+		meta.Size = int64(children) * 100
+		meta.Usage = int64(children) * 4096
+		meta.CachedAt = cachedAt
+		seeded[path] = meta
+		assert.NoError(t, storage.StoreDirMetadata(meta))
+	}
+	seeded["/srv/big/sub"].Flag = '!'
+	assert.NoError(t, storage.StoreDirMetadata(seeded["/srv/big/sub"]))
+	closeFn()
+
+	// the paths don't exist, only the cache is read
+	closeFn, err := storage.OpenReadOnly()
+	assert.NoError(t, err)
+	defer closeFn()
+
+	dump := func(prefix string) []DumpedDir {
+		var buf bytes.Buffer
+		count, err := storage.DumpDirs(prefix, &buf)
+		assert.NoError(t, err)
+
+		dirs := make([]DumpedDir, 0)
+		scanner := bufio.NewScanner(&buf)
+		for scanner.Scan() {
+			var dumped DumpedDir
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &dumped), scanner.Text())
+			dirs = append(dirs, dumped)
+		}
+		assert.Equal(t, count, len(dirs))
+		return dirs
+	}
+
+	dirs := dump("")
+	assert.Len(t, dirs, 4)
+
+	dirs = dump("/srv")
+	paths := make([]string, 0, len(dirs))
+	for _, dumped := range dirs {
+		paths = append(paths, dumped.Path)
+	}
+	assert.Equal(t, []string{"/srv", "/srv/big", "/srv/big/sub"}, paths)
+
+	big := seeded["/srv/big"]
+	assert.Equal(t, big.Size, dirs[1].Size)
+	assert.Equal(t, big.Usage, dirs[1].Usage)
+	assert.Equal(t, big.ItemCount, dirs[1].ItemCount)
+	assert.True(t, big.Mtime.Equal(dirs[1].Mtime))
+	assert.True(t, cachedAt.Equal(dirs[1].CachedAt))
+	assert.Equal(t, 25, dirs[1].ChildCount) // counted from the pages
+	assert.Equal(t, "", dirs[1].Flags)
+	assert.Equal(t, 3, dirs[0].ChildCount)
+	assert.Equal(t, "!", dirs[2].Flags)
+
+	assert.Empty(t, dump("/missing"))
+}
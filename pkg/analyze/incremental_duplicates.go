@@ -0,0 +1,91 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// dirIdentity identifies a directory regardless of the path it is reached by
+type dirIdentity struct {
+	dev uint64
+	ino uint64
+}
+
+// visitDir marks the directory as visited in the running scan.
+// If the same directory was already visited under another path, the path is returned
+func (a *IncrementalAnalyzer) visitDir(path string, stat os.FileInfo) (string, bool) {
+	id, ok := a.identify(stat)
+	if !ok {
+		return "", false
+	}
+	return a.visitIdentity(path, id)
+}
+
+// visitCachedDir is visitDir for directories rebuilt from the cache
+func (a *IncrementalAnalyzer) visitCachedDir(cached *IncrementalDirMetadata) (string, bool) {
+	if cached.Dev == 0 && cached.Ino == 0 {
+		// identity was not recorded (legacy entry or unsupported platform)
+		return "", false
+	}
+	return a.visitIdentity(cached.Path, dirIdentity{dev: cached.Dev, ino: cached.Ino})
+}
+
+func (a *IncrementalAnalyzer) visitIdentity(path string, id dirIdentity) (string, bool) {
+	a.visitedM.Lock()
+	defer a.visitedM.Unlock()
+	if a.visited == nil {
+		a.visited = make(map[dirIdentity]string)
+	}
+	if canonical, ok := a.visited[id]; ok && canonical != path {
+		return canonical, true
+	}
+	a.visited[id] = path
+	return "", false
+}
+
+// createDuplicateDir creates a reference to the directory already visited as canonical.
+// The reference is cached instead of the subtree when stat is known
+func (a *IncrementalAnalyzer) createDuplicateDir(path, canonical string, stat os.FileInfo) *Dir {
+	log.Printf("Directory %s is the same as %s, skipping", path, canonical)
+	a.stats.IncrementDuplicateDirsSkipped()
+	a.stats.IncrementTotalDirs()
+
+	dir := &Dir{
+		File: &File{
+			Name: filepath.Base(path),
+			Flag: 'D',
+		},
+		BasePath:    filepath.Dir(path),
+		ItemCount:   1,
+		DuplicateOf: canonical,
+		Files:       make(fs.Files, 0),
+	}
+
+	if stat != nil {
+		dir.Mtime = stat.ModTime()
+		meta := &IncrementalDirMetadata{
+			Path:        path,
+			Mtime:       stat.ModTime(),
+			ItemCount:   1,
+			Flag:        'D',
+			Files:       []FileMetadata{},
+			CachedAt:    time.Now(),
+			DuplicateOf: canonical,
+		}
+		if id, ok := a.identify(stat); ok {
+			meta.Dev, meta.Ino = id.dev, id.ino
+		}
+		a.provenance.stamp(meta)
+		if err := a.storage.StoreDirMetadata(meta); err != nil {
+			a.stats.IncrementCacheErrors()
+			log.Printf("Warning: Failed to cache reference %s: %v", path, err)
+		}
+	}
+
+	return dir
+}
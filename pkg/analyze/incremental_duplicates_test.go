@@ -0,0 +1,142 @@
+package analyze
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dundee/gdu/v5/internal/testdir"
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+// sameIdentityAs makes directories with the given name look like the target directory
+func sameIdentityAs(t *testing.T, name, target string) func(os.FileInfo) (dirIdentity, bool) {
+	t.Helper()
+	targetInfo, err := os.Stat(target)
+	assert.NoError(t, err)
+
+	return func(info os.FileInfo) (dirIdentity, bool) {
+		if info.Name() == name {
+			info = targetInfo
+		}
+		id, ok := getDirIdentity(info)
+		if !ok {
+			// platform without identities, use names only
+			return dirIdentity{ino: uint64(len(info.Name()))}, true
+		}
+		return id, true
+	}
+}
+
+func TestIncrementalAnalyzer_DuplicateDirs(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	assert.NoError(t, os.MkdirAll("test_dir/vol/nested", 0o755))
+	assert.NoError(t, os.WriteFile("test_dir/vol/nested/file2", []byte("go"), 0o600))
+
+	// the one reached first holds the subtree
+	opts := IncrementalOptions{StoragePath: t.TempDir(), Workers: 1}
+
+	analyzer := CreateIncrementalAnalyzer(opts)
+	analyzer.identify = sameIdentityAs(t, "vol", "test_dir/nested")
+	dir := analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	vol := childByName(dir, "vol").(*Dir)
+	assert.Equal(t, absTestPath(t, "test_dir", "nested"), vol.DuplicateOf)
+	assert.Equal(t, 'D', vol.Flag)
+	assert.Empty(t, vol.Files)
+	assert.Equal(t, int64(1), analyzer.GetCacheStats().DuplicateDirsSkipped)
+
+	// vol counts as a single item without any size
+	dir.UpdateStats(make(fs.HardLinkedItems))
+	assert.Equal(t, 6, dir.ItemCount)
+	assert.Equal(t, int64(0), vol.GetUsage())
+
+	// warm scan resolves the reference again
+	analyzer2 := CreateIncrementalAnalyzer(opts)
+	analyzer2.identify = sameIdentityAs(t, "vol", "test_dir/nested")
+	dir2 := analyzer2.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer2.GetDone().Wait()
+
+	assert.Equal(t, int64(1), analyzer2.GetCacheStats().CacheHits)
+	assert.Equal(t, int64(1), analyzer2.GetCacheStats().DuplicateDirsSkipped)
+	assert.Equal(t, absTestPath(t, "test_dir", "nested"), childByName(dir2, "vol").(*Dir).DuplicateOf)
+	assert.Equal(t, 6, dir2.ItemCount)
+}
+
+func TestIncrementalAnalyzer_DuplicateDirsWorkers(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	// vol is a bind mount of nested, so both list the same entries
+	assert.NoError(t, os.MkdirAll("test_dir/vol/subnested", 0o755))
+	assert.NoError(t, os.WriteFile("test_dir/vol/subnested/file", []byte("hello"), 0o600))
+	assert.NoError(t, os.WriteFile("test_dir/vol/file2", []byte("go"), 0o600))
+
+	scan := func(workers int) *Dir {
+		analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir(), Workers: workers})
+		analyzer.identify = sameIdentityAs(t, "vol", "test_dir/nested")
+		dir := analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		assert.Equal(t, int64(1), analyzer.GetCacheStats().DuplicateDirsSkipped)
+		return dir
+	}
+	serial := scan(1)
+	parallel := scan(4)
+
+	// either of them may hold the subtree, the totals are the same
+	references := 0
+	for _, name := range []string{"vol", "nested"} {
+		if childByName(parallel, name).(*Dir).DuplicateOf != "" {
+			references++
+		}
+	}
+	assert.Equal(t, 1, references)
+	assert.Equal(t, serial.Size, parallel.Size)
+	assert.Equal(t, serial.Usage, parallel.Usage)
+	assert.Equal(t, serial.ItemCount, parallel.ItemCount)
+}
+
+func TestIncrementalAnalyzer_DuplicateDirGone(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	assert.NoError(t, os.MkdirAll("test_dir/vol/nested", 0o755))
+	assert.NoError(t, os.WriteFile("test_dir/vol/nested/file2", []byte("go"), 0o600))
+
+	// the one reached first holds the subtree
+	opts := IncrementalOptions{StoragePath: t.TempDir(), Workers: 1}
+
+	analyzer := CreateIncrementalAnalyzer(opts)
+	analyzer.identify = sameIdentityAs(t, "vol", "test_dir/nested")
+	analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	// the bind mount was removed without changing mtime of the parent
+	analyzer2 := CreateIncrementalAnalyzer(opts)
+	dir := analyzer2.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer2.GetDone().Wait()
+
+	vol := childByName(dir, "vol").(*Dir)
+	assert.Empty(t, vol.DuplicateOf)
+	assert.Equal(t, 1, len(vol.Files))
+	assert.Equal(t, int64(0), analyzer2.GetCacheStats().DuplicateDirsSkipped)
+	assert.Equal(t, 8, dir.ItemCount)
+}
+
+func TestIncrementalAnalyzer_DuplicateOfAncestor(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	assert.NoError(t, os.Mkdir("test_dir/nested/loop", 0o755))
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	analyzer.identify = sameIdentityAs(t, "loop", "test_dir")
+	dir := analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	loop := childByName(childByName(dir, "nested").(*Dir), "loop").(*Dir)
+	assert.Equal(t, absTestPath(t, "test_dir"), loop.DuplicateOf)
+}
@@ -121,17 +121,9 @@ func TestIncrementalAnalyzer_DeletedDirectory(t *testing.T) {
 		testPath, func(_, _ string) bool { return false }, false,
 	).(*Dir)
 
-	// Drain progress channel
-	doneChan := analyzer2.GetDone()
-	progressChan := analyzer2.GetProgressChan()
-	for {
-		select {
-		case <-progressChan:
-		case <-doneChan:
-			goto done
-		}
+	// Drain progress channel, it is closed when the scan ends
+	for range analyzer2.GetProgressChan() {
 	}
-done:
 
 	// Should return error directory
 	assert.NotNil(t, dir2)
@@ -189,17 +181,9 @@ func TestIncrementalAnalyzer_PermissionDenied(t *testing.T) {
 		restrictedPath, func(_, _ string) bool { return false }, false,
 	).(*Dir)
 
-	// Drain progress channel
-	doneChan := analyzer2.GetDone()
-	progressChan := analyzer2.GetProgressChan()
-	for {
-		select {
-		case <-progressChan:
-		case <-doneChan:
-			goto done
-		}
+	// Drain progress channel, it is closed when the scan ends
+	for range analyzer2.GetProgressChan() {
 	}
-done:
 
 	// Should return error directory
 	assert.NotNil(t, dir2)
@@ -0,0 +1,89 @@
+package analyze
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// writeEvents fans out paths of written cache entries to subscribers.
+// Events are delivered without blocking the writer: if the channel of a subscriber is full,
+// the event is dropped and counted
+type writeEvents struct {
+	m    sync.Mutex
+	subs map[*subscription]struct{}
+}
+
+type subscription struct {
+	prefix  string
+	ch      chan<- string
+	dropped atomic.Int64
+}
+
+func newWriteEvents() *writeEvents {
+	return &writeEvents{subs: make(map[*subscription]struct{})}
+}
+
+// subscribe registers ch for paths in subtree of prefix (all paths if prefix is empty).
+// The returned function unsubscribes and closes ch
+func (e *writeEvents) subscribe(prefix string, ch chan<- string) func() int64 {
+	sub := &subscription{prefix: prefix, ch: ch}
+	e.m.Lock()
+	e.subs[sub] = struct{}{}
+	e.m.Unlock()
+
+	return func() int64 {
+		e.m.Lock()
+		defer e.m.Unlock()
+		if _, ok := e.subs[sub]; ok {
+			delete(e.subs, sub)
+			close(sub.ch)
+		}
+		return sub.dropped.Load()
+	}
+}
+
+// publish sends path to the subscribers of its prefixes
+func (e *writeEvents) publish(path string) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	for sub := range e.subs {
+		if sub.prefix != "" && !inSubtree(path, sub.prefix) {
+			continue
+		}
+		select {
+		case sub.ch <- path:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// closeAll unsubscribes all subscribers and closes their channels
+func (e *writeEvents) closeAll() {
+	e.m.Lock()
+	defer e.m.Unlock()
+	for sub := range e.subs {
+		close(sub.ch)
+	}
+	e.subs = make(map[*subscription]struct{})
+}
+
+// Subscribe sends path of every cache entry successfully written by the storage
+// in subtree of prefix (all entries if prefix is empty) to ch.
+// Events are best-effort: they are sent without blocking, so events not fitting
+// into the buffer of ch are dropped, and entries not written (e.g. after failed writes
+// in a degraded cache) produce no event.
+// The returned function unsubscribes and returns the number of dropped events.
+// ch is closed when unsubscribed or when the storage is closed
+func (s *IncrementalStorage) Subscribe(prefix string, ch chan<- string) (unsubscribe func() int64) {
+	return s.events.subscribe(prefix, ch)
+}
+
+// Subscribe sends path of every cache entry written by scans of the analyzer
+// in subtree of prefix (all entries if prefix is empty) to ch.
+// The subscription lasts across scans until the returned function is called,
+// which closes ch and returns the number of dropped events.
+// The delivery is best-effort the same way as of IncrementalStorage.Subscribe
+func (a *IncrementalAnalyzer) Subscribe(prefix string, ch chan<- string) (unsubscribe func() int64) {
+	return a.events.subscribe(prefix, ch)
+}
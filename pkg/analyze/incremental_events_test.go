@@ -0,0 +1,75 @@
+package analyze
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func collectEvents(ch <-chan string) []string {
+	paths := make([]string, 0)
+	for path := range ch {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestIncrementalAnalyzer_Subscribe(t *testing.T) {
+	root := createTraceFixture(t)
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+
+	sub := make(chan string, 100)
+	all := make(chan string, 100)
+	unsubscribe := analyzer.Subscribe(filepath.Join(root, "a"), sub)
+	unsubscribeAll := analyzer.Subscribe("", all)
+
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	// the channels stay open after the scan
+	assert.Equal(t, int64(0), unsubscribe())
+	assert.Equal(t, int64(0), unsubscribeAll())
+
+	assert.ElementsMatch(t, []string{filepath.Join(root, "a"), filepath.Join(root, "a", "b")}, collectEvents(sub))
+	assert.ElementsMatch(t, []string{
+		root, filepath.Join(root, "a"), filepath.Join(root, "a", "b"), filepath.Join(root, "c"),
+	}, collectEvents(all))
+
+	// unsubscribing twice is harmless
+	assert.Equal(t, int64(0), unsubscribe())
+}
+
+func TestIncrementalAnalyzer_SubscribeDropsEvents(t *testing.T) {
+	root := createTraceFixture(t)
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+
+	ch := make(chan string, 1)
+	unsubscribe := analyzer.Subscribe("", ch)
+
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	assert.Equal(t, int64(3), unsubscribe())
+	assert.Len(t, collectEvents(ch), 1)
+}
+
+func TestIncrementalStorage_Subscribe(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/test")
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+
+	ch := make(chan string, 10)
+	storage.Subscribe("/test/a", ch)
+
+	assert.NoError(t, storage.StoreDirMetadata(&IncrementalDirMetadata{Path: "/test"}))
+	assert.NoError(t, storage.StoreDirMetadata(&IncrementalDirMetadata{Path: "/test/a"}))
+	assert.NoError(t, storage.StoreDirMetadata(&IncrementalDirMetadata{Path: "/test/ab"}))
+	assert.NoError(t, storage.StoreDirMetadataBatch([]*IncrementalDirMetadata{
+		{Path: "/test/a/x"}, {Path: "/test/b"},
+	}))
+
+	// closing the storage closes the channel
+	closeFn()
+	assert.Equal(t, []string{"/test/a", "/test/a/x"}, collectEvents(ch))
+}
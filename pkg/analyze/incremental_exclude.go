@@ -0,0 +1,51 @@
+package analyze
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"slices"
+)
+
+// CheckFilePatterns returns error if any of the patterns of IncrementalOptions.ExcludeFiles is malformed
+func CheckFilePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid file pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// optionsFingerprint returns hash of the scan options changing the content of cache entries,
+// currently the excluded file patterns and leaving out of other filesystems (IncrementalOptions.NoCross).
+// It is 0 without such options, so entries written before the fingerprint was introduced match it
+func optionsFingerprint(excludeFiles []string, noCross bool) uint64 {
+	if len(excludeFiles) == 0 && !noCross {
+		return 0
+	}
+
+	patterns := slices.Clone(excludeFiles)
+	slices.Sort(patterns)
+	patterns = slices.Compact(patterns)
+
+	h := fnv.New64a()
+	for _, pattern := range patterns {
+		h.Write([]byte(pattern)) //nolint:errcheck // writes to hash never fail
+		h.Write([]byte{0})       //nolint:errcheck // writes to hash never fail
+	}
+	if noCross {
+		h.Write([]byte("\x00no-cross")) //nolint:errcheck // writes to hash never fail
+	}
+	return max(h.Sum64(), 1)
+}
+
+// isExcludedFile returns true if the file name matches any of the excluded file patterns
+func (a *IncrementalAnalyzer) isExcludedFile(name string) bool {
+	for _, pattern := range a.excludeFiles {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
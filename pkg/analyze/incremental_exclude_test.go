@@ -0,0 +1,74 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckFilePatterns(t *testing.T) {
+	assert.NoError(t, CheckFilePatterns([]string{"*.tmp", "core.*"}))
+	assert.ErrorContains(t, CheckFilePatterns([]string{"*.tmp", "[a-"}), `invalid file pattern "[a-"`)
+}
+
+func TestOptionsFingerprint(t *testing.T) {
+	assert.Equal(t, uint64(0), optionsFingerprint(nil, false))
+	assert.NotEqual(t, uint64(0), optionsFingerprint([]string{"*.tmp"}, false))
+	assert.Equal(t, optionsFingerprint([]string{"*.tmp", "core.*"}, false), optionsFingerprint([]string{"core.*", "*.tmp", "*.tmp"}, false))
+	assert.NotEqual(t, optionsFingerprint([]string{"*.tmp"}, false), optionsFingerprint([]string{"*.tmp", "core.*"}, false))
+	assert.NotEqual(t, uint64(0), optionsFingerprint(nil, true))
+	assert.NotEqual(t, optionsFingerprint([]string{"*.tmp"}, false), optionsFingerprint([]string{"*.tmp"}, true))
+}
+
+func TestIncrementalAnalyzer_ExcludeFiles(t *testing.T) {
+	root := createTraceFixture(t)
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a", "b", "x.tmp"), make([]byte, 1000), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "c", "core.123"), make([]byte, 500), 0o600))
+	storagePath := t.TempDir()
+
+	scan := func(patterns ...string) (*Dir, *CacheStats, map[string]TraceEntry) {
+		analyzer := CreateIncrementalAnalyzer(IncrementalOptions{
+			StoragePath: storagePath, ExcludeFiles: patterns, TraceDecisions: true,
+		})
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+
+		decisions := make(map[string]TraceEntry)
+		for _, entry := range analyzer.GetDecisionTrace().Entries() {
+			rel, err := filepath.Rel(root, entry.Path)
+			assert.NoError(t, err)
+			decisions[rel] = entry
+		}
+		return dir, analyzer.GetCacheStats(), decisions
+	}
+
+	full, _, _ := scan()
+
+	// files matching the patterns are left out of the totals
+	excluded, stats, decisions := scan("*.tmp", "core.*")
+	assert.Equal(t, full.Size-1500, excluded.Size)
+	assert.Equal(t, full.ItemCount-2, excluded.ItemCount)
+	assert.Equal(t, int64(2), stats.ExcludedFiles)
+	assert.Equal(t, int64(1500), stats.ExcludedBytes)
+	assert.Equal(t, DecisionOptions, decisions["."].Decision)
+	assert.Equal(t, DecisionOptions, decisions["a/b"].Decision, "entries written without the patterns are scanned again")
+	b := excluded.Files[0].(*Dir).Files[0].(*Dir)
+	assert.Empty(t, b.Files)
+
+	// the cache written with the patterns is used while they don't change
+	cached, stats, decisions := scan("core.*", "*.tmp")
+	assert.Equal(t, excluded.Size, cached.Size)
+	assert.Equal(t, int64(2), stats.ExcludedFiles, "excluded files are counted from the cache entries")
+	assert.Equal(t, int64(1500), stats.ExcludedBytes)
+	assert.Equal(t, DecisionHit, decisions["."].Decision)
+	assert.Equal(t, DecisionInherited, decisions["a/b"].Decision)
+
+	// without the patterns the rescan restores the totals
+	restored, stats, decisions := scan()
+	assert.Equal(t, full.Size, restored.Size)
+	assert.Equal(t, full.ItemCount, restored.ItemCount)
+	assert.Equal(t, int64(0), stats.ExcludedFiles)
+	assert.Equal(t, DecisionOptions, decisions["c"].Decision)
+}
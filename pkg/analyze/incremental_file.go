@@ -0,0 +1,102 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/dundee/gdu/v5/internal/common"
+	"github.com/dundee/gdu/v5/pkg/fs"
+	log "github.com/sirupsen/logrus"
+)
+
+// isDirTarget returns true if the scan target is a directory or a symlink to a directory
+func isDirTarget(path string, info os.FileInfo) bool {
+	if info.IsDir() {
+		return true
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return false
+	}
+	target, err := os.Stat(path)
+	return err == nil && target.IsDir()
+}
+
+// analyzeFile returns the tree for a scan target which is not a directory.
+// The file is wrapped in its parent directory so that it can be shown like any other result,
+// the parent directory itself is not read and nothing is stored in the cache.
+// Symlink given as the target is always resolved, dangling one is flagged as broken
+func (a *IncrementalAnalyzer) analyzeFile(path string, info os.FileInfo) *Dir {
+	parentPath := filepath.Dir(path)
+	dir := &Dir{
+		File: &File{
+			Name: filepath.Base(parentPath),
+			Flag: ' ',
+		},
+		BasePath: filepath.Dir(parentPath),
+		Files:    make(fs.Files, 0, 1),
+	}
+
+	file := &File{
+		Name:   filepath.Base(path),
+		Flag:   getFlag(info),
+		Size:   info.Size(),
+		Parent: dir,
+	}
+	setPlatformSpecificAttrs(file, info)
+	if a.birthTimes {
+		file.Btime = birthTime(path)
+	}
+	if !a.specialSizes {
+		clearSpecialFileSize(file, info)
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		dir.SymlinkCount = 1
+		if !a.resolveSymlink(file, path) {
+			dir.BrokenSymlinkCount = 1
+		}
+	}
+
+	dir.AddFile(file)
+	dir.Size = file.Size
+	dir.Usage = file.Usage
+	dir.Mtime = file.Mtime
+	dir.ItemCount = 2
+
+	a.scanPump.send(common.CurrentProgress{
+		CurrentItemName: path,
+		ItemCount:       1,
+		TotalSize:       file.Size,
+	})
+
+	return dir
+}
+
+// ignoredRootDir returns empty directory standing for the scanned directory
+// matched by the ignore function
+func ignoredRootDir(path string) *Dir {
+	log.Warnf("Scanned directory %s matches the ignore patterns, its content is not read", path)
+
+	dir := &Dir{
+		File: &File{
+			Name: filepath.Base(path),
+			Flag: 'I',
+		},
+		BasePath:  filepath.Dir(path),
+		ItemCount: 1,
+		Files:     make(fs.Files, 0),
+	}
+	if info, err := os.Lstat(path); err == nil {
+		dir.Mtime = info.ModTime()
+	}
+	return dir
+}
+
+// rootName returns name of the scanned directory as its parent would see it,
+// so that e.g. "." is not taken for a hidden directory
+func rootName(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return filepath.Base(abs)
+	}
+	return filepath.Base(path)
+}
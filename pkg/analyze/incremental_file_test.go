@@ -0,0 +1,157 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/dundee/gdu/v5/internal/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func analyzeTarget(t *testing.T, target string) (*Dir, *IncrementalAnalyzer, string) {
+	t.Helper()
+	storagePath := filepath.Join(t.TempDir(), "cache")
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath})
+	dir := analyzer.AnalyzeDir(target, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+	return dir, analyzer, storagePath
+}
+
+func TestIncrementalAnalyzer_SingleFile(t *testing.T) {
+	base := t.TempDir()
+	path := filepath.Join(base, "file")
+	assert.NoError(t, os.WriteFile(path, []byte("hello"), 0o600))
+
+	dir, analyzer, storagePath := analyzeTarget(t, path)
+
+	assert.Equal(t, base, dir.GetPath())
+	assert.Equal(t, ' ', dir.GetFlag())
+	assert.Len(t, dir.Files, 1)
+	file := dir.Files[0]
+	assert.Equal(t, "file", file.GetName())
+	assert.Equal(t, path, file.GetPath())
+	assert.Equal(t, ' ', file.GetFlag())
+	assert.Equal(t, int64(5), file.GetSize())
+	assert.Equal(t, int64(5), dir.GetSize())
+	assert.Equal(t, file.GetUsage(), dir.GetUsage())
+
+	result := analyzer.GetScanResult()
+	assert.Equal(t, ScanCompleted, result.Status)
+	assert.True(t, result.SingleFile)
+
+	_, err := os.Stat(storagePath)
+	assert.True(t, os.IsNotExist(err), "Cache is not created for a file")
+}
+
+func TestIncrementalAnalyzer_SingleFileSymlink(t *testing.T) {
+	base := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(base, "file"), []byte("hello"), 0o600))
+	path := filepath.Join(base, "link")
+	assert.NoError(t, os.Symlink("file", path))
+
+	dir, analyzer, _ := analyzeTarget(t, path)
+
+	assert.Len(t, dir.Files, 1)
+	link := dir.Files[0]
+	assert.Equal(t, "link", link.GetName())
+	assert.Equal(t, '@', link.GetFlag())
+	assert.Equal(t, int64(5), link.GetSize(), "Symlink given as target is resolved")
+	assert.Equal(t, 1, dir.GetSymlinkCount())
+	assert.Equal(t, 0, dir.GetBrokenSymlinkCount())
+	assert.True(t, analyzer.GetScanResult().SingleFile)
+}
+
+func TestIncrementalAnalyzer_SingleFileDanglingSymlink(t *testing.T) {
+	base := t.TempDir()
+	path := filepath.Join(base, "dangling")
+	assert.NoError(t, os.Symlink("missing", path))
+
+	dir, analyzer, storagePath := analyzeTarget(t, path)
+
+	assert.Len(t, dir.Files, 1)
+	assert.Equal(t, '?', dir.Files[0].GetFlag())
+	assert.Equal(t, 1, dir.GetBrokenSymlinkCount())
+	assert.Equal(t, ScanCompleted, analyzer.GetScanResult().Status)
+
+	_, err := os.Stat(storagePath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestIncrementalAnalyzer_SymlinkToDirTarget(t *testing.T) {
+	base := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(base, "dir"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(base, "dir", "file"), []byte("hello"), 0o600))
+	path := filepath.Join(base, "link")
+	assert.NoError(t, os.Symlink("dir", path))
+
+	dir, analyzer, _ := analyzeTarget(t, path)
+
+	assert.Equal(t, "link", dir.GetName())
+	assert.False(t, analyzer.GetScanResult().SingleFile)
+	assert.Equal(t, "file", dir.Files[0].GetName())
+}
+
+func TestIncrementalAnalyzer_IgnoredRoot(t *testing.T) {
+	root := createTraceFixture(t)
+	storagePath := filepath.Join(t.TempDir(), "cache")
+
+	byName := func(name, _ string) bool { return name == "root" }
+	ui := &common.UI{}
+	assert.NoError(t, ui.SetIgnoreDirPatterns([]string{"^" + regexp.QuoteMeta(filepath.Dir(root)) + "/.*"}))
+
+	for name, ignore := range map[string]common.ShouldDirBeIgnored{
+		"name": byName,
+		"path": ui.CreateIgnoreFunc(),
+	} {
+		analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath})
+		dir := analyzer.AnalyzeDir(root, ignore, false).(*Dir)
+		analyzer.GetDone().Wait()
+
+		assert.Equal(t, root, dir.GetPath(), name)
+		assert.Equal(t, 'I', dir.GetFlag(), name)
+		assert.Empty(t, dir.Files, name)
+		assert.Equal(t, 1, dir.ItemCount, name)
+		assert.Equal(t, int64(0), dir.GetSize(), name)
+
+		result := analyzer.GetScanResult()
+		assert.Equal(t, ScanCompleted, result.Status, name)
+		assert.True(t, result.RootIgnored, name)
+
+		_, err := os.Stat(storagePath)
+		assert.True(t, os.IsNotExist(err), "Cache is not created for ignored root")
+	}
+}
+
+func TestIncrementalAnalyzer_IgnoredRootNotMatching(t *testing.T) {
+	root := createTraceFixture(t)
+
+	// the pattern matches a subdirectory only, which is dropped as usual
+	ui := &common.UI{}
+	assert.NoError(t, ui.SetIgnoreDirPatterns([]string{regexp.QuoteMeta(root) + "/a$"}))
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	dir := analyzer.AnalyzeDir(root, ui.CreateIgnoreFunc(), false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	assert.Equal(t, ' ', dir.GetFlag())
+	assert.False(t, analyzer.GetScanResult().RootIgnored)
+	assert.Len(t, dir.Files, 1)
+	assert.Equal(t, "c", dir.Files[0].GetName())
+}
+
+func TestIncrementalAnalyzer_HiddenIgnoreCurrentDir(t *testing.T) {
+	root := createTraceFixture(t)
+	t.Chdir(root)
+
+	ui := &common.UI{}
+	ui.SetIgnoreHidden(true)
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	dir := analyzer.AnalyzeDir(".", ui.CreateIgnoreFunc(), false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	assert.False(t, analyzer.GetScanResult().RootIgnored, "current directory is not hidden")
+	assert.Len(t, dir.Files, 2)
+}
@@ -0,0 +1,105 @@
+package analyze
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// FoldedItemsName is the name of FoldedItems
+const FoldedItemsName = "<other>"
+
+// FoldedItems stands for the children of a directory smaller than IncrementalOptions.MinItemSize,
+// which are left out of the tree. It holds their totals, so totals of the directory stay exact.
+// It is shown with the '+' flag
+type FoldedItems struct {
+	*File
+	Count int // number of the folded children
+	Items int // number of items of the folded children including the content of directories
+}
+
+// GetType returns name type of item
+func (f *FoldedItems) GetType() string {
+	return "Folded items"
+}
+
+// GetItemCount returns number of items of the folded children
+func (f *FoldedItems) GetItemCount() int {
+	return f.Items
+}
+
+// GetItemStats returns item count, apparent usage and real usage of the folded children
+func (f *FoldedItems) GetItemStats(linkedItems fs.HardLinkedItems) (itemCount int, size, usage int64) {
+	return f.Items, f.Size, f.Usage
+}
+
+// GetFoldedCount returns number of the folded children
+func (f *FoldedItems) GetFoldedCount() int {
+	return f.Count
+}
+
+// EncodeJSON writes JSON representation of the folded children
+func (f *FoldedItems) EncodeJSON(writer io.Writer, topLevel bool) error {
+	buff := make([]byte, 0, 20)
+
+	buff = append(buff, []byte(`{"name":`)...)
+	if err := addString(&buff, f.GetName()); err != nil {
+		return err
+	}
+	if f.GetSize() > 0 {
+		buff = append(buff, []byte(`,"asize":`)...)
+		buff = append(buff, []byte(strconv.FormatInt(f.GetSize(), 10))...)
+	}
+	if f.GetUsage() > 0 {
+		buff = append(buff, []byte(`,"dsize":`)...)
+		buff = append(buff, []byte(strconv.FormatInt(f.GetUsage(), 10))...)
+	}
+	if !f.GetMtime().IsZero() {
+		buff = append(buff, []byte(`,"mtime":`)...)
+		buff = append(buff, []byte(strconv.FormatInt(f.GetMtime().Unix(), 10))...)
+	}
+	buff = append(buff, []byte(`,"folded":`+strconv.Itoa(f.Count)+`,"items":`+strconv.Itoa(f.Items))...)
+	buff = append(buff, '}')
+
+	_, err := writer.Write(buff)
+	return err
+}
+
+// foldSmallItems replaces the children of dir smaller than IncrementalOptions.MinItemSize
+// (in both apparent size and usage) by one FoldedItems holding their totals.
+// It is called after the cache entry of dir was stored, so the cache keeps all the children
+// and the floor can be changed without scanning again
+func (a *IncrementalAnalyzer) foldSmallItems(dir *Dir) {
+	if a.minItemSize <= 0 || a.ctx.Err() != nil {
+		return
+	}
+
+	var folded *FoldedItems
+	kept := make(fs.Files, 0, len(dir.Files))
+	for _, item := range dir.Files {
+		if item.GetSize() >= a.minItemSize || item.GetUsage() >= a.minItemSize {
+			kept = append(kept, item)
+			continue
+		}
+		if folded == nil {
+			folded = &FoldedItems{
+				File: &File{Name: FoldedItemsName, Flag: '+', Parent: item.GetParent()},
+			}
+		}
+		folded.Count++
+		folded.Items += item.GetItemCount()
+		folded.Size += item.GetSize()
+		folded.Usage += item.GetUsage()
+		if item.GetMtime().After(folded.Mtime) {
+			folded.Mtime = item.GetMtime()
+		}
+	}
+	if folded == nil {
+		return
+	}
+
+	dir.m.Lock()
+	dir.Files = append(kept, folded)
+	dir.m.Unlock()
+}
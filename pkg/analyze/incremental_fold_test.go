@@ -0,0 +1,99 @@
+package analyze
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createFoldTree creates a tree with one large file and some small items in the top directory
+// and in its subdirectory
+func createFoldTree(t *testing.T) string {
+	root := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "sub", "small"), 0o755))
+	files := map[string]int{
+		"large":            100000,
+		"a":                10,
+		"b":                20,
+		"sub/large":        200000,
+		"sub/c":            30,
+		"sub/small/d":      40,
+		"sub/small/e":      50,
+		"sub/small/f":      60,
+		"sub/small/g_more": 70,
+	}
+	for name, size := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(root, name), make([]byte, size), 0o600))
+	}
+	return root
+}
+
+func TestIncrementalAnalyzer_MinItemSize(t *testing.T) {
+	root := createFoldTree(t)
+	storagePath := t.TempDir()
+
+	scan := func(minItemSize int64) (*Dir, *CacheStats) {
+		analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath, MinItemSize: minItemSize})
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		return dir, analyzer.GetCacheStats()
+	}
+
+	full, _ := scan(0)
+	for _, minItemSize := range []int64{50000, 60000} {
+		// the first scan with the floor is warm, so the cache is not affected by it
+		folded, stats := scan(minItemSize)
+		assert.Zero(t, stats.CacheMisses)
+
+		assert.Equal(t, full.Size, folded.Size)
+		assert.Equal(t, full.Usage, folded.Usage)
+		assert.Equal(t, full.ItemCount, folded.ItemCount)
+		assert.Equal(t, full.SelfSize, folded.SelfSize)
+
+		names := make([]string, 0)
+		for _, item := range folded.Files {
+			names = append(names, item.GetName())
+		}
+		assert.Equal(t, []string{"large", "sub", FoldedItemsName}, names)
+
+		other := folded.Files[2].(*FoldedItems)
+		assert.Equal(t, 2, other.Count)
+		assert.Equal(t, 2, other.GetItemCount())
+		assert.Equal(t, int64(30), other.GetSize())
+		assert.Equal(t, '+', other.GetFlag())
+
+		sub := folded.Files[1].(*Dir)
+		assert.Len(t, sub.Files, 2)
+		other = sub.Files[1].(*FoldedItems)
+		assert.Equal(t, 2, other.Count, "c and the small directory")
+		assert.Equal(t, 6, other.GetItemCount(), "the small directory with its files")
+
+		index, _ := full.Files.FindByName("sub")
+		fullSub := full.Files[index].(*Dir)
+		assert.Equal(t, fullSub.Size, sub.Size)
+		assert.Equal(t, fullSub.ItemCount, sub.ItemCount)
+	}
+
+	// the cold scan with the floor stores all the children
+	assert.NoError(t, os.RemoveAll(storagePath))
+	folded, stats := scan(50000)
+	assert.Equal(t, int64(3), stats.CacheMisses)
+	assert.Equal(t, full.Size, folded.Size)
+	warm, _ := scan(0)
+	assert.Equal(t, flattenTree(full), flattenTree(warm))
+}
+
+func TestFoldedItems_EncodeJSON(t *testing.T) {
+	folded := &FoldedItems{
+		File:  &File{Name: FoldedItemsName, Flag: '+', Size: 30, Usage: 8192},
+		Count: 2,
+		Items: 6,
+	}
+
+	var buff bytes.Buffer
+	assert.NoError(t, folded.EncodeJSON(&buff, false))
+	assert.Equal(t, `{"name":"\u003cother\u003e","asize":30,"dsize":8192,"folded":2,"items":6}`, buff.String())
+}
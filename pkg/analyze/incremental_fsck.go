@@ -0,0 +1,327 @@
+package analyze
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxMtimeInFuture is the farthest cached mtime in the future considered valid
+const maxMtimeInFuture = 24 * time.Hour
+
+// ErrCorruptedEntry is matched (using errors.Is) by errors of cache entries
+// which could not be decoded or which are not valid
+var ErrCorruptedEntry = errors.New("corrupted cache entry")
+
+// CorruptedEntryError describes an invalid directory entry of the cache
+type CorruptedEntryError struct {
+	Path   string // path from the key of the entry
+	Reason string // violated invariant or "decoding failed"
+	Err    error  // decoding error, nil for violated invariants
+}
+
+func (e *CorruptedEntryError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("corrupted cache entry for %s: %s: %v", e.Path, e.Reason, e.Err)
+	}
+	return fmt.Sprintf("corrupted cache entry for %s: %s", e.Path, e.Reason)
+}
+
+// Is makes the error match ErrCorruptedEntry
+func (e *CorruptedEntryError) Is(target error) bool {
+	return target == ErrCorruptedEntry
+}
+
+// Unwrap returns the decoding error
+func (e *CorruptedEntryError) Unwrap() error {
+	return e.Err
+}
+
+// ErrKeyCollision is matched (using errors.Is) by errors of cache entries
+// found under the key of a directory but written for another path
+var ErrKeyCollision = errors.New("cache key collision")
+
+// KeyCollisionError describes an entry whose key maps to another directory,
+// e.g. "Foo" and "foo" if keys were case-folded on a case-sensitive filesystem
+type KeyCollisionError struct {
+	Path       string // path of the loaded directory
+	StoredPath string // path the entry was written for
+}
+
+func (e *KeyCollisionError) Error() string {
+	return fmt.Sprintf("cache entry for %s belongs to %s", e.Path, e.StoredPath)
+}
+
+// Is makes the error match ErrKeyCollision
+func (e *KeyCollisionError) Is(target error) bool {
+	return target == ErrKeyCollision
+}
+
+// FsckResult is summary of the cache integrity check
+type FsckResult struct {
+	Checked  int     // number of checked directory entries
+	Invalid  int     // number of invalid entries
+	Repaired int     // number of removed invalid entries
+	Problems []error // CorruptedEntryError of invalid entries (bounded by DefaultMaxReportedPaths)
+}
+
+// ProblemsDropped returns number of invalid entries which did not fit into Problems
+func (r *FsckResult) ProblemsDropped() int {
+	return r.Invalid - len(r.Problems)
+}
+
+// CheckIntegrity decodes every directory entry of the cache and validates it.
+// Only entries under the top directory are checked if the storage has one.
+// With repair set the invalid entries are removed, so the directories are rescanned next time
+func (s *IncrementalStorage) CheckIntegrity(repair bool) (*FsckResult, error) {
+	result := &FsckResult{}
+	invalid := make([]string, 0)
+	paged := make([]string, 0)
+	now := time.Now()
+	report := func(path string, err error) {
+		result.Invalid++
+		result.Problems, _ = appendBounded(result.Problems, err, DefaultMaxReportedPaths)
+		invalid = append(invalid, path)
+	}
+
+	err := s.Iterate(string(s.makeKey(s.topDir)), func(key, value []byte) error {
+		path := string(key[len(KeyPrefixDirMetadata):])
+		if s.topDir != "" && !inSubtree(path, s.topDir) {
+			return nil
+		}
+		result.Checked++
+
+		meta, err := decodeDirMetadata(path, value)
+		if err == nil && meta.Pages > 0 {
+			// children in pages are checked after the iteration
+			paged = append(paged, path)
+			return nil
+		}
+		if err == nil {
+			err = s.validateDirMetadata(path, meta, now)
+		}
+		if err != nil {
+			report(path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paged {
+		meta, err := s.LoadDirMetadata(path)
+		if err == nil {
+			err = s.validateDirMetadata(path, meta, now)
+		}
+		if err != nil {
+			report(path, err)
+		}
+	}
+
+	if !repair || len(invalid) == 0 {
+		return result, nil
+	}
+
+	for _, path := range invalid {
+		if err := s.DeleteDirMetadata(path); err != nil {
+			return result, err
+		}
+		result.Repaired++
+	}
+	return result, nil
+}
+
+// validateDirMetadata checks invariants of decoded entry stored under key of path
+func (s *IncrementalStorage) validateDirMetadata(
+	path string, meta *IncrementalDirMetadata, now time.Time,
+) error {
+	invalid := func(format string, args ...interface{}) error {
+		return &CorruptedEntryError{Path: path, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	switch {
+	case meta.Path == "":
+		return invalid("empty path")
+	case meta.Path != path:
+		return invalid("path %s does not match the key", meta.Path)
+	case s.topDir != "" && !inSubtree(meta.Path, s.topDir):
+		return invalid("path is not under %s", s.topDir)
+	case meta.ItemCount < 1:
+		return invalid("item count %d", meta.ItemCount)
+	case meta.Mtime.After(now.Add(maxMtimeInFuture)):
+		return invalid("mtime %s in the future", meta.Mtime.Format(time.RFC3339))
+	}
+
+	if name, ok := duplicateChild(meta.Files); ok {
+		return invalid("duplicate child %s", name)
+	}
+	return nil
+}
+
+// duplicateChild returns a name which is used by more children, ok is false if there is none.
+// Children sorted by name are checked without allocation
+func duplicateChild(files []FileMetadata) (name string, ok bool) {
+	sorted := true
+	for i := 1; i < len(files) && sorted; i++ {
+		sorted = files[i-1].Name < files[i].Name
+	}
+	if sorted {
+		return "", false
+	}
+
+	names := make(map[string]struct{}, len(files))
+	for _, f := range files {
+		if _, ok := names[f.Name]; ok {
+			return f.Name, true
+		}
+		names[f.Name] = struct{}{}
+	}
+	return "", false
+}
+
+// decodeDirMetadata decodes value of the directory entry of path
+func decodeDirMetadata(path string, value []byte) (*IncrementalDirMetadata, error) {
+	var meta IncrementalDirMetadata
+	if err := gob.NewDecoder(bytes.NewBuffer(value)).Decode(&meta); err != nil {
+		return nil, &CorruptedEntryError{Path: path, Reason: "decoding failed", Err: err}
+	}
+	return &meta, nil
+}
+
+// deleteKeys removes given keys and returns their number
+func (s *IncrementalStorage) deleteKeys(keys [][]byte) (int, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.db == nil {
+		return 0, fmt.Errorf("storage is not open")
+	}
+
+	if err := s.writeDeletes(keys); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
+
+// writeDeletes removes given keys in a batch, s.m must be held and the storage open
+func (s *IncrementalStorage) writeDeletes(keys [][]byte) error {
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	for _, key := range keys {
+		if err := wb.Delete(key); err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+// MarkScanStarted stores the scan-in-progress marker.
+// It stays in the cache if the process crashes before MarkScanFinished is called.
+// The cache can't be cleared until the scan is finished
+func (s *IncrementalStorage) MarkScanStarted() error {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.db == nil {
+		return fmt.Errorf("storage is not open")
+	}
+	s.scanning.Store(true)
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(markerKey(), []byte(time.Now().Format(time.RFC3339Nano)))
+	})
+}
+
+// MarkScanFinished removes the scan-in-progress marker
+func (s *IncrementalStorage) MarkScanFinished() error {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	s.scanning.Store(false)
+
+	if s.db == nil {
+		return fmt.Errorf("storage is not open")
+	}
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(markerKey())
+	})
+}
+
+// ScanMarker returns start time of the scan which did not finish (crashed).
+// The bool is false if there is no such scan
+func (s *IncrementalStorage) ScanMarker() (time.Time, bool, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.db == nil {
+		return time.Time{}, false, fmt.Errorf("storage is not open")
+	}
+
+	var started time.Time
+	found := false
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(markerKey())
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		return item.Value(func(val []byte) error {
+			started, err = time.Parse(time.RFC3339Nano, string(val))
+			return err
+		})
+	})
+	return started, found, err
+}
+
+// checkCrashedScan checks the cache of the scanned directory and removes invalid entries
+// if the previous scan did not finish
+func (a *IncrementalAnalyzer) checkCrashedScan() {
+	started, crashed, err := a.storage.ScanMarker()
+	if err != nil {
+		log.Printf("Warning: Failed to read scan marker: %v", err)
+		return
+	}
+	if !crashed {
+		return
+	}
+
+	if top := a.storage.GetTopDir(); top != "" {
+		log.Printf("Scan started at %s did not finish, checking cache of %s", started, top)
+	} else {
+		log.Printf("Scan started at %s did not finish, checking the whole cache", started)
+	}
+	result, err := a.storage.CheckIntegrity(true)
+	if err != nil {
+		log.Printf("Warning: Cache check failed: %v", err)
+		return
+	}
+	for _, problem := range result.Problems {
+		log.Printf("Removed %v", problem)
+	}
+	if rest := result.ProblemsDropped(); rest > 0 {
+		log.Printf("...and %d more", rest)
+	}
+	a.stats.AddCorruptedEntries(int64(result.Repaired))
+}
+
+// inSubtree returns true if path is dir or it is located under dir
+func inSubtree(path, dir string) bool {
+	if !strings.HasPrefix(path, dir) {
+		return false
+	}
+	rest := path[len(dir):]
+	return rest == "" ||
+		strings.HasPrefix(rest, string(filepath.Separator)) ||
+		strings.HasSuffix(dir, string(filepath.Separator))
+}
@@ -0,0 +1,238 @@
+package analyze
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+// storeRawEntry stores value under the directory key of path, bypassing StoreDirMetadata
+func storeRawEntry(t *testing.T, storage *IncrementalStorage, path string, value []byte) {
+	t.Helper()
+	err := storage.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(storage.makeKey(path), value)
+	})
+	assert.NoError(t, err)
+}
+
+func encodeEntry(t *testing.T, meta *IncrementalDirMetadata) []byte {
+	t.Helper()
+	b := &bytes.Buffer{}
+	assert.NoError(t, gob.NewEncoder(b).Encode(meta))
+	return b.Bytes()
+}
+
+// seedBrokenCache stores two valid and six invalid entries under /data
+// and one invalid entry outside of it
+func seedBrokenCache(t *testing.T, storage *IncrementalStorage) {
+	t.Helper()
+	valid := func(path string) *IncrementalDirMetadata {
+		return &IncrementalDirMetadata{
+			Path:      path,
+			Mtime:     time.Now(),
+			ItemCount: 2,
+			Files:     []FileMetadata{{Name: "a"}, {Name: "b"}},
+			CachedAt:  time.Now(),
+		}
+	}
+
+	assert.NoError(t, storage.StoreDirMetadata(valid("/data")))
+	assert.NoError(t, storage.StoreDirMetadata(valid("/data/ok")))
+
+	storeRawEntry(t, storage, "/data/garbage", []byte("not a gob"))
+
+	emptyPath := valid("/data/empty")
+	emptyPath.Path = ""
+	storeRawEntry(t, storage, "/data/empty", encodeEntry(t, emptyPath))
+
+	storeRawEntry(t, storage, "/data/moved", encodeEntry(t, valid("/data/elsewhere")))
+
+	noItems := valid("/data/noitems")
+	noItems.ItemCount = 0
+	assert.NoError(t, storage.StoreDirMetadata(noItems))
+
+	future := valid("/data/future")
+	future.Mtime = time.Now().Add(10 * 365 * 24 * time.Hour)
+	assert.NoError(t, storage.StoreDirMetadata(future))
+
+	dupNames := valid("/data/dup")
+	dupNames.Files = append(dupNames.Files, FileMetadata{Name: "a", IsDir: true})
+	assert.NoError(t, storage.StoreDirMetadata(dupNames))
+
+	other := valid("/database")
+	other.ItemCount = 0
+	assert.NoError(t, storage.StoreDirMetadata(other))
+}
+
+func TestIncrementalStorage_CheckIntegrity(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/data")
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	defer closeFn()
+
+	seedBrokenCache(t, storage)
+
+	result, err := storage.CheckIntegrity(false)
+	assert.NoError(t, err)
+	assert.Equal(t, 8, result.Checked, "/database is not under /data")
+	assert.Equal(t, 6, result.Invalid)
+	assert.Equal(t, 0, result.Repaired)
+
+	reasons := make(map[string]string)
+	for _, problem := range result.Problems {
+		assert.ErrorIs(t, problem, ErrCorruptedEntry)
+		var corrupted *CorruptedEntryError
+		assert.True(t, errors.As(problem, &corrupted))
+		reasons[corrupted.Path] = corrupted.Reason
+	}
+	assert.Equal(t, map[string]string{
+		"/data/garbage": "decoding failed",
+		"/data/empty":   "empty path",
+		"/data/moved":   "path /data/elsewhere does not match the key",
+		"/data/noitems": "item count 0",
+		"/data/future":  reasons["/data/future"],
+		"/data/dup":     "duplicate child a",
+	}, reasons)
+	assert.Contains(t, reasons["/data/future"], "in the future")
+
+	// nothing is removed without repair
+	_, err = storage.LoadDirMetadata("/data/noitems")
+	assert.NoError(t, err)
+}
+
+func TestIncrementalStorage_CheckIntegrityRepair(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/data")
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	defer closeFn()
+
+	seedBrokenCache(t, storage)
+
+	result, err := storage.CheckIntegrity(true)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, result.Invalid)
+	assert.Equal(t, 6, result.Repaired)
+
+	result, err = storage.CheckIntegrity(false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Checked)
+	assert.Equal(t, 0, result.Invalid)
+
+	_, err = storage.LoadDirMetadata("/data/ok")
+	assert.NoError(t, err)
+	_, err = storage.LoadDirMetadata("/database")
+	assert.NoError(t, err, "Entries outside of the top directory must be kept")
+}
+
+func TestIncrementalStorage_CheckIntegrityWholeCache(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "")
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	defer closeFn()
+
+	seedBrokenCache(t, storage)
+
+	result, err := storage.CheckIntegrity(true)
+	assert.NoError(t, err)
+	assert.Equal(t, 9, result.Checked)
+	assert.Equal(t, 7, result.Invalid)
+	assert.Equal(t, 7, result.Repaired)
+}
+
+func TestIncrementalStorage_LoadCorruptedEntry(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/data")
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	defer closeFn()
+
+	storeRawEntry(t, storage, "/data/garbage", []byte("not a gob"))
+
+	_, err = storage.LoadDirMetadata("/data/garbage")
+	assert.ErrorIs(t, err, ErrCorruptedEntry)
+}
+
+func TestIncrementalStorage_ScanMarker(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/data")
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	defer closeFn()
+
+	_, crashed, err := storage.ScanMarker()
+	assert.NoError(t, err)
+	assert.False(t, crashed)
+
+	assert.NoError(t, storage.MarkScanStarted())
+	started, crashed, err := storage.ScanMarker()
+	assert.NoError(t, err)
+	assert.True(t, crashed)
+	assert.WithinDuration(t, time.Now(), started, time.Minute)
+
+	assert.NoError(t, storage.MarkScanFinished())
+	_, crashed, err = storage.ScanMarker()
+	assert.NoError(t, err)
+	assert.False(t, crashed)
+}
+
+func TestIncrementalAnalyzer_CheckAfterCrash(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "root")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "sub"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "sub", "file"), []byte("hello"), 0o600))
+	storagePath := t.TempDir()
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath})
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	// simulate a scan which crashed after it stored broken entries
+	storage := NewIncrementalStorage(storagePath, root)
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	storeRawEntry(t, storage, filepath.Join(root, "sub"), []byte("not a gob"))
+	storeRawEntry(t, storage, filepath.Join(root, "gone"), []byte("not a gob"))
+	assert.NoError(t, storage.MarkScanStarted())
+	closeFn()
+
+	analyzer = CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath, CheckAfterCrash: true})
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	assert.Equal(t, int64(2), analyzer.GetCacheStats().CorruptedEntries)
+	sub := childByName(dir, "sub").(*Dir)
+	assert.NotNil(t, childByName(sub, "file"), "Removed entry is scanned again")
+
+	// the marker is cleared by the finished scan, the next one does not check again
+	closeFn, err = storage.Open()
+	assert.NoError(t, err)
+	_, crashed, err := storage.ScanMarker()
+	assert.NoError(t, err)
+	assert.False(t, crashed)
+	result, err := storage.CheckIntegrity(false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Invalid)
+	closeFn()
+}
+
+func TestIncrementalAnalyzer_NoCheckWithoutCrash(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "root")
+	assert.NoError(t, os.MkdirAll(root, 0o755))
+	storagePath := t.TempDir()
+
+	storage := NewIncrementalStorage(storagePath, root)
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	storeRawEntry(t, storage, filepath.Join(root, "gone"), []byte("not a gob"))
+	closeFn()
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath, CheckAfterCrash: true})
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	assert.Equal(t, int64(0), analyzer.GetCacheStats().CorruptedEntries)
+}
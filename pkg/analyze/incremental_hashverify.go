@@ -0,0 +1,144 @@
+package analyze
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// subtreePaths returns the directories of prefixes (e.g. IncrementalOptions.HashVerifyPrefixes)
+// as they appear in the scan of path. A prefix above the scanned directory covers all of it,
+// prefixes outside of it are left out. Symlinks in the paths are resolved
+func subtreePaths(path string, prefixes []string) []string {
+	root := canonicalPath(path)
+	paths := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		p := canonicalPath(prefix)
+		switch {
+		case inSubtree(root, p):
+			return []string{path}
+		case inSubtree(p, root):
+			rel := strings.TrimPrefix(p[len(root):], string(filepath.Separator))
+			paths = append(paths, joinPath(path, rel))
+		}
+	}
+	return paths
+}
+
+// isHashVerified returns true if the content fingerprint of the directory is compared
+// on cache hits (see IncrementalOptions.HashVerifyPrefixes)
+func (a *IncrementalAnalyzer) isHashVerified(path string) bool {
+	for _, prefix := range a.hashVerify {
+		if inSubtree(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// hashedChild is a direct child of a directory as its content fingerprint sees it
+type hashedChild struct {
+	name  string
+	size  int64
+	mtime int64
+	dir   bool
+}
+
+// childHasher collects the direct children of a hash-verified directory for its content fingerprint.
+// Files are hashed with their sizes and mtimes, subdirectories by their names only,
+// their content is covered by their own fingerprints. A nil hasher ignores the children
+type childHasher struct {
+	children []hashedChild
+	err      error // first child which could not be stat'ed, the fingerprint is not computed then
+}
+
+func newChildHasher(size int) *childHasher {
+	return &childHasher{children: make([]hashedChild, 0, size)}
+}
+
+// addDir adds the subdirectory with given name
+func (h *childHasher) addDir(name string) {
+	if h == nil {
+		return
+	}
+	h.children = append(h.children, hashedChild{name: name, dir: true})
+}
+
+// addFile adds the file with given name and its stat (err of the failed stat)
+func (h *childHasher) addFile(name string, info os.FileInfo, err error) {
+	if h == nil {
+		return
+	}
+	if err != nil {
+		if h.err == nil {
+			h.err = err
+		}
+		return
+	}
+	h.children = append(h.children, hashedChild{name: name, size: info.Size(), mtime: info.ModTime().UnixNano()})
+}
+
+// addEntry adds the listed entry, files are stat'ed
+func (h *childHasher) addEntry(f os.DirEntry) {
+	if h == nil {
+		return
+	}
+	if f.IsDir() {
+		h.addDir(f.Name())
+		return
+	}
+	info, err := f.Info()
+	h.addFile(f.Name(), info, err)
+}
+
+// sum returns hash of the children in name order.
+// It is never 0, so entries written without the fingerprint don't match it
+func (h *childHasher) sum() (uint64, error) {
+	if h.err != nil {
+		return 0, h.err
+	}
+
+	// the order of an unsorted listing depends on the filesystem
+	slices.SortFunc(h.children, func(a, b hashedChild) int { return strings.Compare(a.name, b.name) })
+
+	hash := fnv.New64a()
+	buf := make([]byte, 17)
+	for _, c := range h.children {
+		binary.LittleEndian.PutUint64(buf, uint64(c.size))
+		binary.LittleEndian.PutUint64(buf[8:], uint64(c.mtime))
+		buf[16] = 0
+		if c.dir {
+			buf[16] = 1
+		}
+		hash.Write([]byte(c.name)) //nolint:errcheck // writes to hash never fail
+		hash.Write([]byte{0})      //nolint:errcheck // writes to hash never fail
+		hash.Write(buf)            //nolint:errcheck // writes to hash never fail
+	}
+	return max(hash.Sum64(), 1), nil
+}
+
+// contentHash returns fingerprint of the direct children of the directory read from the filesystem
+// (see childHasher). Only the listing and stats of files are read, not the content of files.
+// Scans compute it from the listing they read already (see performFullScan)
+func (a *IncrementalAnalyzer) contentHash(path string) (uint64, error) {
+	files, err := a.readDirRetried(path)
+	if err != nil {
+		return 0, err
+	}
+
+	h := newChildHasher(len(files))
+	for _, f := range files {
+		h.addEntry(f)
+	}
+	return h.sum()
+}
+
+// contentChanged returns true if the fingerprint of the hash-verified directory differs
+// from the cache entry. Directory whose fingerprint could not be computed is treated as changed
+func (a *IncrementalAnalyzer) contentChanged(cached *IncrementalDirMetadata) bool {
+	sum, err := a.contentHash(cached.Path)
+	return err != nil || sum != cached.ContentHash
+}
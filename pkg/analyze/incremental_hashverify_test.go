@@ -0,0 +1,122 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rewritePreservingMtime replaces content of the file and sets back its mtime and mtime of its directory,
+// like copying tools preserving timestamps do
+func rewritePreservingMtime(t *testing.T, path string, content string) {
+	t.Helper()
+	fileInfo, err := os.Stat(path)
+	assert.NoError(t, err)
+
+	// rewriting the file changes neither mtime nor ctime of the directory
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	assert.NoError(t, os.Chtimes(path, fileInfo.ModTime(), fileInfo.ModTime()))
+}
+
+func TestIncrementalAnalyzer_HashVerifyPrefixes(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "store")
+	for _, dir := range []string{"critical/release", "plain"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o755))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "critical", "release", "artifact"), []byte("v1"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "plain", "artifact"), []byte("v1"), 0o600))
+	mtime := time.Now().Add(-time.Hour)
+	for _, dir := range []string{"critical/release", "critical", "plain", ""} {
+		assert.NoError(t, os.Chtimes(filepath.Join(root, dir), mtime, mtime))
+	}
+
+	opts := IncrementalOptions{
+		StoragePath:        t.TempDir(),
+		HashVerifyPrefixes: []string{filepath.Join(root, "critical")},
+		TraceDecisions:     true,
+	}
+	scan := func() (*Dir, *IncrementalAnalyzer) {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		return dir, analyzer
+	}
+	artifactSize := func(dir *Dir, path ...string) int64 {
+		for _, name := range path {
+			dir = childByName(dir, name).(*Dir)
+		}
+		return childByName(dir, "artifact").GetSize()
+	}
+
+	_, cold := scan()
+	assert.Zero(t, cold.GetCacheStats().HashRescans)
+
+	// nothing changed, the fingerprints match
+	_, warm := scan()
+	assert.Zero(t, warm.GetCacheStats().HashRescans)
+	assert.Zero(t, warm.GetCacheStats().DirsRescanned)
+
+	rewritePreservingMtime(t, filepath.Join(root, "critical", "release", "artifact"), "version 2")
+	rewritePreservingMtime(t, filepath.Join(root, "plain", "artifact"), "version 2")
+
+	dir, changed := scan()
+	assert.Equal(t, int64(9), artifactSize(dir, "critical", "release"))
+	assert.Equal(t, int64(2), artifactSize(dir, "plain")) // mtime is trusted outside of the prefix
+	assert.Equal(t, int64(1), changed.GetCacheStats().HashRescans)
+	assert.Equal(t, int64(1), changed.GetCacheStats().DirsRescanned)
+
+	decisions := make(map[string]CacheDecision)
+	for _, entry := range changed.GetDecisionTrace().Entries() {
+		decisions[entry.Path] = entry.Decision
+	}
+	assert.Equal(t, DecisionContent, decisions[filepath.Join(root, "critical", "release")])
+	assert.Equal(t, DecisionHit, decisions[filepath.Join(root, "critical")])
+	assert.Equal(t, DecisionInherited, decisions[filepath.Join(root, "plain")])
+
+	// the rescan stored the new fingerprint
+	dir, again := scan()
+	assert.Equal(t, int64(9), artifactSize(dir, "critical", "release"))
+	assert.Zero(t, again.GetCacheStats().HashRescans)
+}
+
+func TestIncrementalAnalyzer_HashVerifyListsOnce(t *testing.T) {
+	root := createInvalidationTree(t)
+	noIgnore := func(_, _ string) bool { return false }
+	opts := IncrementalOptions{StoragePath: t.TempDir(), HashVerifyPrefixes: []string{root}}
+
+	analyzer := CreateIncrementalAnalyzer(opts)
+	listings := make(map[string]int)
+	var m sync.Mutex
+	analyzer.listDir = func(path string) ([]os.DirEntry, error) {
+		m.Lock()
+		listings[path]++
+		m.Unlock()
+		return os.ReadDir(path)
+	}
+	analyzer.AnalyzeDir(root, noIgnore, false)
+	analyzer.GetDone().Wait()
+	for path, count := range listings {
+		assert.Equal(t, 1, count, "the fingerprint of %s is computed from the listing of the scan", path)
+	}
+
+	// the fingerprint of the scan matches the one computed on cache hits
+	analyzer = CreateIncrementalAnalyzer(opts)
+	analyzer.AnalyzeDir(root, noIgnore, false)
+	analyzer.GetDone().Wait()
+	assert.Zero(t, analyzer.GetCacheStats().HashRescans)
+	assert.Zero(t, analyzer.GetCacheStats().DirsRescanned)
+}
+
+func TestSubtreePaths(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b"), 0o755))
+
+	scanned := filepath.Join(root, "a")
+	assert.Equal(t, []string{filepath.Join(scanned, "b")}, subtreePaths(scanned, []string{filepath.Join(root, "a", "b")}))
+	assert.Equal(t, []string{scanned}, subtreePaths(scanned, []string{root}))
+	assert.Empty(t, subtreePaths(scanned, []string{filepath.Join(root, "other")}))
+}
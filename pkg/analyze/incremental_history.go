@@ -0,0 +1,267 @@
+package analyze
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// HistoryLimit is the number of scan records kept for every scanned directory,
+// the oldest ones are removed by the next scans
+const HistoryLimit = 100
+
+// historyDepth is the depth of the subdirectories whose totals are kept in the scan records,
+// historyDirs limits their number in one record
+const (
+	historyDepth = 2
+	historyDirs  = 10000
+)
+
+func init() {
+	gob.RegisterName("analyze.ScanRecord", &ScanRecord{})
+}
+
+// ScanRecord is kept in the scan history for every finished scan of a top directory
+// (see ListScanHistory). It holds the totals of the directory and of its subdirectories
+// down to Depth, so two scans can be compared by DiffScans
+type ScanRecord struct {
+	Root       string
+	Generation uint64 // the generation of the root summary stored by the scan
+	Label      string // label of the scan (see IncrementalOptions.ScanLabel)
+	Status     ScanStatus
+	FinishedAt time.Time
+	Depth      int          // depth of the deepest recorded subdirectories, the top directory is 0
+	Dirs       []HistoryDir // the top directory first
+}
+
+// HistoryDir holds the totals of a directory in a scan record
+type HistoryDir struct {
+	Path  string
+	Size  int64
+	Usage int64
+	Items int
+}
+
+// newScanRecord returns the record of the scan of the top directory dir.
+// Subdirectories are recorded by whole levels, a level which does not fit in historyDirs is left out
+func newScanRecord(dir *Dir, result *ScanResult, label string) *ScanRecord {
+	record := &ScanRecord{
+		Root:       dir.GetPath(),
+		Generation: result.Generation,
+		Label:      label,
+		Status:     result.Status,
+		FinishedAt: time.Now(),
+		Dirs:       []HistoryDir{historyDir(dir)},
+	}
+
+	level := []*Dir{dir}
+	for depth := 1; depth <= historyDepth; depth++ {
+		next := make([]*Dir, 0)
+		for _, parent := range level {
+			for _, item := range parent.Files {
+				if subdir, ok := item.(*Dir); ok && subdir.DuplicateOf == "" {
+					next = append(next, subdir)
+				}
+			}
+		}
+		if len(next) == 0 || len(record.Dirs)+len(next) > historyDirs {
+			break
+		}
+		for _, subdir := range next {
+			record.Dirs = append(record.Dirs, historyDir(subdir))
+		}
+		record.Depth = depth
+		level = next
+	}
+	return record
+}
+
+func historyDir(dir *Dir) HistoryDir {
+	return HistoryDir{Path: dir.GetPath(), Size: dir.Size, Usage: dir.Usage, Items: dir.ItemCount}
+}
+
+// StoreScanRecord adds the record to the scan history of its top directory
+// and removes the records older than the last limit ones
+func (s *IncrementalStorage) StoreScanRecord(record *ScanRecord, limit int) error {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.db == nil {
+		return fmt.Errorf("storage is not open")
+	}
+
+	b := &bytes.Buffer{}
+	if err := gob.NewEncoder(b).Encode(record); err != nil {
+		return fmt.Errorf("encoding scan record: %w", err)
+	}
+	return s.db.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(historyKey(record.Root, record.Generation), b.Bytes()); err != nil {
+			return err
+		}
+
+		keys := make([][]byte, 0)
+		it := txn.NewIterator(badger.IteratorOptions{PrefetchValues: false})
+		prefix := historyPrefix(record.Root)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			keys = append(keys, it.Item().KeyCopy(nil))
+		}
+		it.Close()
+
+		for len(keys) > limit {
+			if err := txn.Delete(keys[0]); err != nil {
+				return err
+			}
+			keys = keys[1:]
+		}
+		return nil
+	})
+}
+
+// ListScanHistory returns the records of the scans of the top directory at path, the oldest first.
+// With a label only the scans with this label are returned
+func (s *IncrementalStorage) ListScanHistory(path, label string) ([]*ScanRecord, error) {
+	records := make([]*ScanRecord, 0)
+	err := s.Iterate(string(historyPrefix(path)), func(_, value []byte) error {
+		record := &ScanRecord{}
+		if err := gob.NewDecoder(bytes.NewBuffer(value)).Decode(record); err != nil {
+			return err
+		}
+		if label == "" || record.Label == label {
+			records = append(records, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading scan history of %s: %w", path, err)
+	}
+	return records, nil
+}
+
+// latestScan returns the last recorded scan of the top directory at path with the label,
+// the last one regardless of the label if it is empty
+func (s *IncrementalStorage) latestScan(path, label string) (*ScanRecord, error) {
+	records, err := s.ListScanHistory(path, label)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		if label == "" {
+			return nil, fmt.Errorf("no scan of %s in the history", path)
+		}
+		return nil, fmt.Errorf("no scan of %s labelled %q in the history", path, label)
+	}
+	return records[len(records)-1], nil
+}
+
+// DiffScans compares the recorded scans of the same top directory and returns the directories
+// whose size or item count changed, together with the added and the removed ones, ordered by path.
+// Only the directories recorded by both scans are compared, subdirectories of an added
+// or a removed directory are not reported
+func DiffScans(from, to *ScanRecord) []DirDelta {
+	depth := min(from.Depth, to.Depth)
+	old := from.dirsTo(depth)
+	current := to.dirsTo(depth)
+	hasParent := func(dirs map[string]HistoryDir, path string) bool {
+		_, ok := dirs[filepath.Dir(path)]
+		return ok
+	}
+
+	deltas := make([]DirDelta, 0)
+	for path, dir := range current {
+		previous, ok := old[path]
+		switch {
+		case ok && (previous.Size != dir.Size || previous.Usage != dir.Usage || previous.Items != dir.Items):
+			deltas = append(deltas, DirDelta{
+				Path:     path,
+				OldSize:  previous.Size,
+				NewSize:  dir.Size,
+				OldUsage: previous.Usage,
+				NewUsage: dir.Usage,
+				OldItems: previous.Items,
+				NewItems: dir.Items,
+				Status:   DeltaChanged,
+			})
+		case !ok && hasParent(old, path):
+			deltas = append(deltas, DirDelta{
+				Path:     path,
+				NewSize:  dir.Size,
+				NewUsage: dir.Usage,
+				NewItems: dir.Items,
+				Status:   DeltaAdded,
+			})
+		}
+	}
+	for path, dir := range old {
+		if _, ok := current[path]; !ok && hasParent(current, path) {
+			deltas = append(deltas, DirDelta{
+				Path:     path,
+				OldSize:  dir.Size,
+				OldUsage: dir.Usage,
+				OldItems: dir.Items,
+				Status:   DeltaRemoved,
+			})
+		}
+	}
+	sort.Slice(deltas, func(i, j int) bool {
+		return deltas[i].Path < deltas[j].Path
+	})
+	return deltas
+}
+
+// dirsTo returns the recorded directories at most depth levels below the top directory by their path
+func (r *ScanRecord) dirsTo(depth int) map[string]HistoryDir {
+	dirs := make(map[string]HistoryDir, len(r.Dirs))
+	for _, dir := range r.Dirs {
+		if dir.Path == r.Root || strings.Count(relativeTo(r.Root, dir.Path), string(filepath.Separator)) < depth {
+			dirs[dir.Path] = dir
+		}
+	}
+	return dirs
+}
+
+// relativeTo returns path relative to root, which it is below
+func relativeTo(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// recordHistory adds the finished scan of the top directory at path to the scan history.
+// Cancelled and failed scans are not recorded, neither are the scans which stored no summary
+func (a *IncrementalAnalyzer) recordHistory(dir *Dir, result *ScanResult) {
+	if result.Generation == 0 || (result.Status != ScanCompleted && result.Status != ScanCompletedWithErrors) {
+		return
+	}
+	if err := a.storage.StoreScanRecord(newScanRecord(dir, result, a.scanLabel), HistoryLimit); err != nil {
+		a.stats.IncrementCacheErrors()
+		log.Printf("Warning: Failed to store scan history of %s: %v", dir.GetPath(), err)
+	}
+}
+
+// compareLabels compares the scans of the top directory at path selected by their labels
+// (see IncrementalOptions.DiffFromLabel), the result is returned by ComputeDiff
+func (a *IncrementalAnalyzer) compareLabels(path string) {
+	if a.fromLabel == "" {
+		return
+	}
+	from, err := a.storage.latestScan(path, a.fromLabel)
+	if err != nil {
+		a.diffErr = err
+		return
+	}
+	to, err := a.storage.latestScan(path, a.toLabel)
+	if err != nil {
+		a.diffErr = err
+		return
+	}
+	a.labelDiff = append(a.labelDiff, DiffScans(from, to)...)
+}
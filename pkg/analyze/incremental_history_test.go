@@ -0,0 +1,124 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalAnalyzer_ScanHistory(t *testing.T) {
+	root := createInvalidationTree(t)
+	storagePath := t.TempDir()
+	scan := func(opts IncrementalOptions) *IncrementalAnalyzer {
+		opts.StoragePath = storagePath
+		analyzer := CreateIncrementalAnalyzer(opts)
+		analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+		analyzer.GetDone().Wait()
+		return analyzer
+	}
+
+	scan(IncrementalOptions{ScanLabel: "pre-cleanup"})
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a", "new"), make([]byte, 100), 0o600))
+	assert.NoError(t, os.RemoveAll(filepath.Join(root, "x")))
+	scan(IncrementalOptions{ScanLabel: "post-cleanup"})
+	assert.NoError(t, os.Mkdir(filepath.Join(root, "n"), 0o755))
+	scan(IncrementalOptions{})
+
+	storage := NewIncrementalStorage(storagePath, root)
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	records, err := storage.ListScanHistory(root, "")
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	for i, label := range []string{"pre-cleanup", "post-cleanup", ""} {
+		assert.Equal(t, uint64(i+1), records[i].Generation)
+		assert.Equal(t, label, records[i].Label)
+		assert.Equal(t, root, records[i].Dirs[0].Path)
+	}
+	assert.Equal(t, 2, records[0].Depth)
+	assert.Len(t, records[0].Dirs, 5, "the top directory and two levels of subdirectories")
+
+	records, err = storage.ListScanHistory(root, "post-cleanup")
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, uint64(2), records[0].Generation)
+	closeFn()
+
+	analyzer := scan(IncrementalOptions{Diff: true, DiffFromLabel: "pre-cleanup", DiffToLabel: "post-cleanup"})
+	assert.NoError(t, analyzer.DiffError())
+	deltas := analyzer.ComputeDiff()
+	paths := make([]string, 0, len(deltas))
+	for _, delta := range deltas {
+		paths = append(paths, delta.Path)
+	}
+	assert.Equal(t, []string{root, filepath.Join(root, "a"), filepath.Join(root, "x")}, paths)
+	assert.Equal(t, int64(100), deltas[1].Growth(true))
+	assert.Equal(t, DeltaRemoved, deltas[2].Status)
+
+	// without the later label the last scan is compared
+	analyzer = scan(IncrementalOptions{Diff: true, DiffFromLabel: "post-cleanup"})
+	deltas = analyzer.ComputeDiff()
+	assert.Len(t, deltas, 2)
+	assert.Equal(t, filepath.Join(root, "n"), deltas[1].Path)
+	assert.Equal(t, DeltaAdded, deltas[1].Status)
+
+	// the latest scan with the label is used
+	scan(IncrementalOptions{ScanLabel: "pre-cleanup"})
+	analyzer = scan(IncrementalOptions{Diff: true, DiffFromLabel: "pre-cleanup", DiffToLabel: "post-cleanup"})
+	deltas = analyzer.ComputeDiff()
+	assert.Len(t, deltas, 2)
+	assert.Equal(t, filepath.Join(root, "n"), deltas[1].Path)
+	assert.Equal(t, DeltaRemoved, deltas[1].Status)
+
+	analyzer = scan(IncrementalOptions{Diff: true, DiffFromLabel: "missing"})
+	assert.ErrorContains(t, analyzer.DiffError(), `labelled "missing"`)
+	assert.Empty(t, analyzer.ComputeDiff())
+}
+
+func TestIncrementalStorage_ScanHistoryLimit(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/test")
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	defer closeFn()
+
+	for gen := uint64(1); gen <= 3; gen++ {
+		record := &ScanRecord{Root: "/test", Generation: gen, Dirs: []HistoryDir{{Path: "/test"}}}
+		assert.NoError(t, storage.StoreScanRecord(record, 2))
+	}
+	assert.NoError(t, storage.StoreScanRecord(&ScanRecord{Root: "/test/sub", Generation: 1}, 2))
+
+	records, err := storage.ListScanHistory("/test", "")
+	assert.NoError(t, err)
+	assert.Len(t, records, 2, "history of subdirectories is not included")
+	assert.Equal(t, uint64(2), records[0].Generation)
+	assert.Equal(t, uint64(3), records[1].Generation)
+}
+
+func TestDiffScans(t *testing.T) {
+	from := &ScanRecord{Root: "/r", Depth: 2, Dirs: []HistoryDir{
+		{Path: "/r", Size: 30, Items: 3},
+		{Path: "/r/a", Size: 10, Items: 1},
+		{Path: "/r/b", Size: 20, Items: 2},
+		{Path: "/r/b/c", Size: 20, Items: 1},
+	}}
+	to := &ScanRecord{Root: "/r", Depth: 1, Dirs: []HistoryDir{
+		{Path: "/r", Size: 30, Items: 3},
+		{Path: "/r/a", Size: 10, Items: 1},
+		{Path: "/r/b", Size: 20, Items: 2},
+	}}
+	assert.Empty(t, DiffScans(from, to), "only the levels recorded by both scans are compared")
+
+	to.Dirs = []HistoryDir{
+		{Path: "/r", Size: 40, Items: 3},
+		{Path: "/r/a", Size: 40, Items: 2},
+		{Path: "/r/d", Size: 0, Items: 1},
+	}
+	assert.Equal(t, []DirDelta{
+		{Path: "/r", OldSize: 30, NewSize: 40, OldItems: 3, NewItems: 3, Status: DeltaChanged},
+		{Path: "/r/a", OldSize: 10, NewSize: 40, OldItems: 1, NewItems: 2, Status: DeltaChanged},
+		{Path: "/r/b", OldSize: 20, OldItems: 2, Status: DeltaRemoved},
+		{Path: "/r/d", NewItems: 1, Status: DeltaAdded},
+	}, DiffScans(from, to))
+}
@@ -109,40 +109,35 @@ func TestIncrementalWithSequential(t *testing.T) {
 
 // TestIncrementalWithNoCross tests incremental caching respects filesystem boundaries
 func TestIncrementalWithNoCross(t *testing.T) {
-	if os.Getuid() != 0 {
-		t.Skip("skipping test that requires root privileges to create mount points")
-	}
-
 	fin := testdir.CreateTestDir()
 	defer fin()
 
 	tmpDir := t.TempDir()
 	opts := IncrementalOptions{
-		StoragePath:   tmpDir,
-		CacheMaxAge:   0,
-		ForceFullScan: false,
+		StoragePath: tmpDir,
+		NoCross:     true,
 	}
 
 	analyzer := CreateIncrementalAnalyzer(opts)
+	// nested is a mount point of another filesystem
+	analyzer.identify = func(info os.FileInfo) (dirIdentity, bool) {
+		id, _ := getDirIdentity(info)
+		id.dev = 1
+		if info.Name() == "nested" {
+			id.dev = 2
+		}
+		return id, true
+	}
 
-	// In a real scenario, we would check device IDs
-	// For this test, we just verify the analyzer works with ignore function
-	dir := analyzer.AnalyzeDir(
-		"test_dir",
-		func(name, path string) bool {
-			// Simulate crossing filesystem boundary
-			return name == "nested"
-		},
-		false,
-	).(*Dir)
+	dir := analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
 
 	<-analyzer.GetProgressChan()
 	analyzer.GetDone().Wait()
 
-	// Should only have test_dir, nested should be ignored
+	// Should only have test_dir, nested is on another filesystem
 	assert.Equal(t, "test_dir", dir.Name)
-	// ItemCount should be less since nested is ignored
-	assert.Less(t, dir.ItemCount, 5)
+	assert.Empty(t, dir.Files)
+	assert.Equal(t, 1, dir.ItemCount)
 }
 
 // TestIncrementalWithIgnoreDirs tests incremental caching with directory ignoring
@@ -168,15 +163,10 @@ func TestIncrementalWithIgnoreDirs(t *testing.T) {
 		false,
 	).(*Dir)
 
-	// Drain progress channel properly
-	progressChan := analyzer.GetProgressChan()
-	doneChan := analyzer.GetDone()
-	go func() {
-		for range progressChan {
-			// Drain
-		}
-	}()
-	doneChan.Wait()
+	// Drain progress channel, it is closed when the scan ends
+	for range analyzer.GetProgressChan() {
+	}
+	analyzer.GetDone().Wait()
 	dir.UpdateStats(make(fs.HardLinkedItems))
 
 	// Verify nested directory was ignored
@@ -195,15 +185,10 @@ func TestIncrementalWithIgnoreDirs(t *testing.T) {
 		false,
 	).(*Dir)
 
-	// Drain progress channel properly
-	progressChan2 := analyzer2.GetProgressChan()
-	doneChan2 := analyzer2.GetDone()
-	go func() {
-		for range progressChan2 {
-			// Drain
-		}
-	}()
-	doneChan2.Wait()
+	// Drain progress channel, it is closed when the scan ends
+	for range analyzer2.GetProgressChan() {
+	}
+	analyzer2.GetDone().Wait()
 	dir2.UpdateStats(make(fs.HardLinkedItems))
 
 	// Results should be identical
@@ -0,0 +1,90 @@
+package analyze
+
+import (
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// InvalidatePaths sets directories, e.g. listed by an external feed of changes, whose cache
+// entries and the entries of all their descendants are removed at the start of the next scan,
+// so they are scanned again (see IncrementalStorage.DeleteSubtree). Relative paths are resolved now,
+// paths without entries are skipped. The next scan applies them once, they are not kept for further ones.
+// With IncrementalOptions.TrustUnlisted the directories not listed are loaded without stat
+func (a *IncrementalAnalyzer) InvalidatePaths(paths []string) {
+	a.invalidate = make([]string, 0, len(paths))
+	for _, path := range paths {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		a.invalidate = append(a.invalidate, path)
+	}
+}
+
+// applyInvalidations removes the entries of the directories set by InvalidatePaths
+func (a *IncrementalAnalyzer) applyInvalidations() {
+	paths := a.invalidate
+	a.invalidate = nil
+
+	entries := 0
+	for _, path := range paths {
+		removed, err := a.storage.DeleteSubtree(path)
+		if err != nil {
+			a.stats.IncrementCacheErrors()
+			log.Printf("Warning: Failed to invalidate cache entries of %s: %v", path, err)
+			continue
+		}
+		a.stats.AddInvalidated(removed)
+		entries += removed
+	}
+	if len(paths) > 0 {
+		log.Printf("Invalidated %d cache entries of %d listed directories", entries, len(paths))
+	}
+}
+
+// isRefreshed returns true if the directory is scanned without the cache
+// (see IncrementalOptions.RefreshPaths)
+func (a *IncrementalAnalyzer) isRefreshed(path string) bool {
+	for _, prefix := range a.refresh {
+		if inSubtree(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveTrusted returns the directory at path loaded from its cache entry without stat
+// if untouched directories are trusted (IncrementalOptions.TrustUnlisted).
+// Invalidated directories have no entry, so they are resolved by resolveDir as any other miss
+func (a *IncrementalAnalyzer) resolveTrusted(b *scanBranch, path string) (*Dir, uint64, bool) {
+	if !a.trustUnlisted || a.forceFullScan {
+		return nil, 0, false
+	}
+	cached, err := a.storage.LoadDirMetadata(path)
+	if err != nil || !a.inheritable(path, cached) {
+		return nil, 0, false
+	}
+
+	a.checkProvenance(path, cached)
+	a.traceDecision(path, DecisionTrusted, cached, nil)
+	a.stats.IncrementCacheHits()
+	a.stats.IncrementTotalDirs()
+	a.stats.AddBytesFromCache(cached.Size)
+	return a.rebuildFromCache(b, cached), cached.Dev, true
+}
+
+// inheritable returns true if the cache entry of directory at path can be used
+// without checking the directory, as for a subdirectory of a cache hit.
+// References are resolved again, as the original may not be part of this scan.
+// Estimates are replaced by exact scan, imported entries are verified,
+// entries with timestamps in the future or cached later than now are checked again,
+// entries written with other excluded file patterns and entries of refreshed directories are scanned again
+// and fingerprints of hash-verified directories are compared. No entry is inherited
+// with ValidateMtimeCount, all directories are listed
+func (a *IncrementalAnalyzer) inheritable(path string, cached *IncrementalDirMetadata) bool {
+	return a.validation == ValidateMtime && cached.DuplicateOf == "" && (cached.Estimate == nil || a.sampleAbove > 0) &&
+		!cached.Mtime.IsZero() && !a.isFuture(cached.CachedAt, cached.Mtime) &&
+		(a.trustAhead || !cached.CachedAt.After(time.Now().Add(clockStepTolerance))) &&
+		cached.Fingerprint == a.fingerprint && !a.isHashVerified(path) && !a.isRefreshed(path)
+}
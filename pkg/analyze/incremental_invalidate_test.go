@@ -0,0 +1,135 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createInvalidationTree creates root/a/b/c and root/x/y with a file in each directory
+func createInvalidationTree(t *testing.T) string {
+	root := t.TempDir()
+	for _, dir := range []string{"a/b/c", "x/y"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o755))
+	}
+	for _, dir := range []string{"", "a", "a/b", "a/b/c", "x", "x/y"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(root, dir, "file"), []byte("data"), 0o600))
+	}
+	return root
+}
+
+func TestIncrementalAnalyzer_InvalidatePaths(t *testing.T) {
+	root := createInvalidationTree(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	analyzer := CreateIncrementalAnalyzer(opts)
+	cold := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	// mtime of the top directory does not change, the change is found only by the invalidation
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a", "b", "c", "new"), make([]byte, 100), 0o600))
+
+	analyzer = CreateIncrementalAnalyzer(opts)
+	analyzer.InvalidatePaths([]string{filepath.Join(root, "a", "b"), filepath.Join(root, "missing")})
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	assert.Equal(t, cold.Size+100, dir.Size)
+	stats := analyzer.GetCacheStats()
+	assert.Equal(t, int64(2), stats.InvalidatedPaths)
+	assert.Equal(t, int64(2), stats.InvalidatedEntries, "entries of a/b and a/b/c")
+	assert.Equal(t, int64(2), stats.CacheMisses)
+
+	// the invalidation is applied once
+	analyzer.ResetProgress()
+	again := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+	assert.Equal(t, dir.Size, again.Size)
+	assert.Zero(t, analyzer.GetCacheStats().InvalidatedPaths)
+	assert.Zero(t, analyzer.GetCacheStats().CacheMisses)
+}
+
+func TestIncrementalAnalyzer_TrustUnlisted(t *testing.T) {
+	root := createInvalidationTree(t)
+	storagePath := t.TempDir()
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath})
+	cold := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	// the change of the top directory is not listed, so it is missed
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a", "b", "c", "new"), make([]byte, 100), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "unlisted"), make([]byte, 1000), 0o600))
+
+	analyzer = CreateIncrementalAnalyzer(IncrementalOptions{
+		StoragePath: storagePath, TrustUnlisted: true, TraceDecisions: true,
+	})
+	var statted []string
+	var m sync.Mutex
+	analyzer.statPath = func(path string) (os.FileInfo, error) {
+		m.Lock()
+		statted = append(statted, path)
+		m.Unlock()
+		return os.Stat(path)
+	}
+	nested := filepath.Join(root, "a", "b", "c")
+	analyzer.InvalidatePaths([]string{nested})
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	assert.Equal(t, cold.Size+100, dir.Size)
+	assert.NotEmpty(t, statted)
+	for _, path := range statted {
+		assert.Equal(t, nested, path, "only the listed branch is read")
+	}
+
+	decisions := make(map[string]CacheDecision)
+	for _, entry := range analyzer.GetDecisionTrace().Entries() {
+		decisions[entry.Path] = entry.Decision
+	}
+	assert.Equal(t, DecisionTrusted, decisions[root])
+	assert.Equal(t, DecisionInherited, decisions[filepath.Join(root, "a", "b")])
+	assert.Equal(t, DecisionInherited, decisions[filepath.Join(root, "x", "y")])
+	assert.Equal(t, DecisionMiss, decisions[nested])
+
+	stats := analyzer.GetCacheStats()
+	assert.Equal(t, int64(1), stats.InvalidatedPaths)
+	assert.Equal(t, int64(1), stats.CacheMisses)
+	assert.Equal(t, int64(1), stats.CacheHits)
+}
+
+func TestIncrementalAnalyzer_RefreshPaths(t *testing.T) {
+	root := createInvalidationTree(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	analyzer := CreateIncrementalAnalyzer(opts)
+	cold := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	// growing files changes no mtime of directories, the change is found only by the refresh
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a", "b", "c", "file"), make([]byte, 100), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "x", "y", "file"), make([]byte, 100), 0o600))
+
+	refreshing := opts
+	refreshing.RefreshPaths = []string{filepath.Join(root, "a")}
+	analyzer = CreateIncrementalAnalyzer(refreshing)
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	assert.Equal(t, cold.Size+96, dir.Size, "only the refreshed subtree is read again")
+	stats := analyzer.GetCacheStats()
+	assert.Equal(t, int64(3), stats.DirsRescanned, "a, a/b and a/b/c")
+	assert.Equal(t, int64(1), stats.CacheHits, "x and x/y are inherited from the top directory")
+	assert.Equal(t, 100.0, stats.HitRate())
+
+	// the refreshed entries replaced the old ones
+	analyzer = CreateIncrementalAnalyzer(opts)
+	again := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+	assert.Equal(t, dir.Size, again.Size)
+	assert.Equal(t, int64(1), analyzer.GetCacheStats().CacheHits)
+	assert.Zero(t, analyzer.GetCacheStats().DirsRescanned)
+}
@@ -0,0 +1,202 @@
+package analyze
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// legacyImportBatchSize is the number of entries written at once by ImportLegacyStorage
+const legacyImportBatchSize = 1000
+
+// LegacyImportResult holds numbers of directories handled by ImportLegacyStorage
+type LegacyImportResult struct {
+	Converted int // directories stored in the incremental cache
+	Skipped   int // directories missing in the legacy storage or which could not be decoded
+}
+
+// ImportLegacyStorage converts directories under root stored by the stored analyzer
+// (--use-storage) at legacyPath into entries of the open incremental storage,
+// so that the first incremental scan doesn't have to read everything again.
+//
+// The legacy storage is opened read-only. It does not hold reliable mtimes of directories,
+// so the entries are stored with zero mtime and the incremental analyzer verifies each of them
+// by listing the directory before it is used. Directories which could not be converted
+// are left out of their parents and scanned by the first incremental run.
+// The directories are looked up in the legacy storage by root as given, but stored
+// under the absolute path, as the incremental analyzer scans them
+func ImportLegacyStorage(legacyPath string, storage *IncrementalStorage, root string) (LegacyImportResult, error) {
+	var result LegacyImportResult
+
+	options := badger.DefaultOptions(legacyPath).WithReadOnly(true)
+	options.Logger = nil
+	db, err := badger.Open(options)
+	if err != nil {
+		return result, fmt.Errorf("opening legacy storage: %w", err)
+	}
+	defer db.Close()
+
+	importer := &legacyImporter{
+		db: db, storage: storage, cachedAt: time.Now(), provenance: currentProvenance(), result: &result,
+	}
+	path, err := filepath.Abs(root)
+	if err != nil {
+		return result, err
+	}
+	if _, ok := importer.convert(filepath.Clean(root), path); !ok {
+		return result, fmt.Errorf("directory %s is not in the legacy storage", root)
+	}
+	if err := importer.flush(); err != nil {
+		return result, err
+	}
+	return result, importer.err
+}
+
+// legacyImporter walks directories of the legacy storage depth first
+type legacyImporter struct {
+	db         *badger.DB
+	storage    *IncrementalStorage
+	cachedAt   time.Time
+	provenance provenance
+	batch      []*IncrementalDirMetadata
+	result     *LegacyImportResult
+	err        error
+}
+
+// convert converts the directory stored under key and its subdirectories into entries of path.
+// It returns the entry of the directory, false if it was skipped
+func (l *legacyImporter) convert(key, path string) (*IncrementalDirMetadata, bool) {
+	stored, err := l.load(key)
+	if err != nil {
+		log.Printf("Skipping %s from legacy storage: %v", path, err)
+		l.result.Skipped++
+		return nil, false
+	}
+
+	meta := &IncrementalDirMetadata{
+		Path:      path,
+		Size:      DefaultDirBlockSize,
+		Usage:     DefaultDirBlockSize,
+		ItemCount: 1,
+		Flag:      stored.Flag,
+		Files:     make([]FileMetadata, 0, len(stored.Files)),
+		CachedAt:  l.cachedAt,
+	}
+	l.provenance.stamp(meta)
+	if meta.Flag == '!' {
+		meta.ErrorCount = 1
+	}
+
+	for _, item := range stored.Files {
+		if item.IsDir() {
+			child, ok := l.convert(filepath.Join(key, item.GetName()), filepath.Join(path, item.GetName()))
+			if !ok {
+				continue
+			}
+			meta.Files = append(meta.Files, FileMetadata{
+				Name:      item.GetName(),
+				IsDir:     true,
+				Size:      child.Size,
+				Usage:     child.Usage,
+				ItemCount: child.ItemCount,
+				Flag:      child.Flag,
+			})
+			meta.Size += child.Size
+			meta.Usage += child.Usage
+			meta.ItemCount += child.ItemCount
+			meta.ErrorCount += child.ErrorCount
+			continue
+		}
+
+		file, ok := item.(*File)
+		if !ok {
+			continue
+		}
+		meta.Files = append(meta.Files, FileMetadata{
+			Name:  file.Name,
+			Size:  file.Size,
+			Usage: file.Usage,
+			Mtime: file.Mtime,
+			Flag:  file.Flag,
+			Mli:   file.Mli,
+			Owner: file.owner,
+		})
+		meta.Size += file.Size
+		meta.Usage += file.Usage
+		meta.SelfSize += file.Size
+		meta.SelfUsage += file.Usage
+		meta.ItemCount++
+		switch file.Flag {
+		case '@':
+			meta.SymlinkCount++
+		case '?':
+			meta.SymlinkCount++
+			meta.BrokenSymlinkCount++
+		}
+	}
+
+	l.batch = append(l.batch, meta)
+	l.result.Converted++
+	if len(l.batch) >= legacyImportBatchSize && l.err == nil {
+		l.err = l.flush()
+	}
+	return meta, true
+}
+
+// load decodes the directory stored at path
+func (l *legacyImporter) load(path string) (*StoredDir, error) {
+	dir := &StoredDir{Dir: &Dir{}}
+	err := l.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(path))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return gob.NewDecoder(bytes.NewBuffer(val)).Decode(dir)
+		})
+	})
+	return dir, err
+}
+
+// flush writes the collected entries
+func (l *legacyImporter) flush() error {
+	if len(l.batch) == 0 {
+		return nil
+	}
+	err := l.storage.StoreDirMetadataBatch(l.batch)
+	l.batch = l.batch[:0]
+	return err
+}
+
+// verifyImported checks the entry of an imported directory, which has no mtime,
+// against the listing of the directory. If the names of the children match,
+// the entry gets the current mtime and true is returned
+func (a *IncrementalAnalyzer) verifyImported(cached *IncrementalDirMetadata, stat os.FileInfo) bool {
+	entries, err := os.ReadDir(cached.Path)
+	if err != nil || len(entries) != len(cached.Files) {
+		return false
+	}
+	names := make(map[string]bool, len(cached.Files))
+	for _, f := range cached.Files {
+		names[f.Name] = f.IsDir
+	}
+	for _, entry := range entries {
+		isDir, ok := names[entry.Name()]
+		if !ok || isDir != entry.IsDir() {
+			return false
+		}
+	}
+
+	cached.Mtime = stat.ModTime()
+	if err := a.storage.StoreDirMetadata(cached); err != nil {
+		a.stats.IncrementCacheErrors()
+		log.Printf("Failed to store verified entry of %s: %v", cached.Path, err)
+	}
+	return true
+}
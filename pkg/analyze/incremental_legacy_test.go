@@ -0,0 +1,93 @@
+package analyze
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dundee/gdu/v5/internal/testdir"
+)
+
+func TestImportLegacyStorage(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	noIgnore := func(_, _ string) bool { return false }
+
+	legacyPath := t.TempDir()
+	legacy := CreateStoredAnalyzer(legacyPath)
+	legacy.AnalyzeDir("test_dir", noIgnore, false)
+	legacy.GetDone().Wait()
+
+	opts := IncrementalOptions{StoragePath: t.TempDir(), TraceDecisions: true}
+	storage := NewIncrementalStorage(opts.StoragePath, "test_dir")
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	result, err := ImportLegacyStorage(legacyPath, storage, "test_dir")
+	assert.NoError(t, err)
+	assert.Equal(t, LegacyImportResult{Converted: 3}, result)
+
+	meta, err := storage.LoadDirMetadata(absTestPath(t, "test_dir", "nested"))
+	assert.NoError(t, err)
+	assert.True(t, meta.Mtime.IsZero())
+	assert.Equal(t, int64(7+2*DefaultDirBlockSize), meta.Size)
+	assert.Equal(t, 4, meta.ItemCount)
+	assert.Equal(t, int64(2), meta.SelfSize)
+	closeFn()
+
+	// the first incremental run verifies the imported entries and uses them
+	analyzer := CreateIncrementalAnalyzer(opts)
+	dir := analyzer.AnalyzeDir("test_dir", noIgnore, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	stats := analyzer.GetCacheStats()
+	assert.Equal(t, int64(3), stats.CacheHits)
+	assert.Equal(t, int64(0), stats.DirsRescanned)
+	for _, entry := range analyzer.GetDecisionTrace().Entries() {
+		assert.Equal(t, DecisionVerified, entry.Decision, entry.Path)
+	}
+	assert.Equal(t, 5, dir.ItemCount)
+	nested := childByName(dir, "nested").(*Dir)
+	assert.Equal(t, int64(2), childByName(nested, "file2").GetSize())
+	assert.Equal(t, int64(5), childByName(childByName(nested, "subnested").(*Dir), "file").GetSize())
+
+	// the verified entries got mtimes and are plain hits now
+	analyzer = CreateIncrementalAnalyzer(opts)
+	analyzer.AnalyzeDir("test_dir", noIgnore, false)
+	analyzer.GetDone().Wait()
+	assert.Equal(t, DecisionHit, analyzer.GetDecisionTrace().Entries()[0].Decision)
+}
+
+func TestImportLegacyStorageChangedDir(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	noIgnore := func(_, _ string) bool { return false }
+
+	legacyPath := t.TempDir()
+	legacy := CreateStoredAnalyzer(legacyPath)
+	legacy.AnalyzeDir("test_dir", noIgnore, false)
+	legacy.GetDone().Wait()
+
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+	storage := NewIncrementalStorage(opts.StoragePath, "test_dir")
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	_, err = ImportLegacyStorage(legacyPath, storage, "test_dir")
+	assert.NoError(t, err)
+
+	result, err := ImportLegacyStorage(legacyPath, storage, "missing")
+	assert.Error(t, err)
+	assert.Equal(t, LegacyImportResult{Skipped: 1}, result)
+	closeFn()
+
+	// listing differs from the imported entry
+	assert.NoError(t, os.WriteFile("test_dir/nested/new", []byte("new"), 0o600))
+
+	analyzer := CreateIncrementalAnalyzer(opts)
+	dir := analyzer.AnalyzeDir("test_dir", noIgnore, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	nested := childByName(dir, "nested").(*Dir)
+	assert.NotNil(t, childByName(nested, "new"))
+	assert.Equal(t, int64(1), analyzer.GetCacheStats().DirsRescanned)
+}
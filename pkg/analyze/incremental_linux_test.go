@@ -0,0 +1,123 @@
+//go:build linux
+// +build linux
+
+package analyze
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/dundee/gdu/v5/internal/testdir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalAnalyzer_ErrorCounts(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permission checks are bypassed for root")
+	}
+
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	// Readable but not searchable: entries can be listed, but neither
+	// file2 nor subnested can be stat'ed
+	err := os.Chmod("test_dir/nested", 0o644)
+	assert.Nil(t, err)
+	defer func() {
+		err = os.Chmod("test_dir/nested", 0o755)
+		assert.Nil(t, err)
+	}()
+
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	analyzer1 := CreateIncrementalAnalyzer(opts)
+	dir := analyzer1.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer1.GetDone().Wait()
+
+	assert.Equal(t, 2, dir.ErrorCount)
+	assert.Equal(t, ScanCompletedWithErrors, analyzer1.GetScanResult().Status)
+	assert.Equal(t, 2, analyzer1.GetScanResult().ErrorCount)
+	nested := dir.Files[0].(*Dir)
+	assert.Equal(t, "nested", nested.GetName())
+	assert.Equal(t, 2, nested.ErrorCount)
+
+	var buff bytes.Buffer
+	err = dir.EncodeJSON(&buff, true)
+	assert.Nil(t, err)
+	assert.Contains(t, buff.String(), `"errors":2`)
+
+	// Warm scan carries the cached counts forward
+	analyzer2 := CreateIncrementalAnalyzer(opts)
+	dir = analyzer2.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer2.GetDone().Wait()
+
+	assert.Equal(t, int64(1), analyzer2.GetCacheStats().CacheHits)
+	assert.Equal(t, 2, dir.ErrorCount)
+	assert.Equal(t, 2, dir.Files[0].(*Dir).ErrorCount)
+}
+
+func TestIncrementalAnalyzer_Btime(t *testing.T) {
+	before := time.Now().Add(-time.Second)
+	fin := testdir.CreateTestDir()
+	defer fin()
+	after := time.Now().Add(time.Second)
+
+	if birthTime("test_dir/nested/file2").IsZero() {
+		t.Skip("filesystem does not record birth time")
+	}
+
+	opts := IncrementalOptions{StoragePath: t.TempDir(), BirthTime: true}
+
+	analyzer1 := CreateIncrementalAnalyzer(opts)
+	cold := analyzer1.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer1.GetDone().Wait()
+
+	analyzer2 := CreateIncrementalAnalyzer(opts)
+	warm := analyzer2.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer2.GetDone().Wait()
+	assert.Equal(t, int64(1), analyzer2.GetCacheStats().CacheHits)
+
+	for _, dir := range []*Dir{cold, warm} {
+		assert.WithinRange(t, dir.Btime, before, after)
+		nested := childByName(dir, "nested").(*Dir)
+		assert.WithinRange(t, nested.Btime, before, after)
+		assert.WithinRange(t, childByName(nested, "file2").(*File).Btime, before, after)
+	}
+	assert.Equal(t, cold.Btime, warm.Btime)
+
+	// birth time is not read by default
+	analyzer3 := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	dir := analyzer3.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer3.GetDone().Wait()
+	assert.True(t, dir.Btime.IsZero())
+	assert.True(t, childByName(childByName(dir, "nested").(*Dir), "file2").(*File).Btime.IsZero())
+}
+
+func TestIncrementalAnalyzer_BindMount(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("bind mounts require root")
+	}
+
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	assert.NoError(t, os.Mkdir("test_dir/vol", 0o755))
+	if err := syscall.Mount("test_dir/nested", "test_dir/vol", "", syscall.MS_BIND, ""); err != nil {
+		t.Skipf("bind mount not permitted: %v", err)
+	}
+	defer func() {
+		assert.NoError(t, syscall.Unmount("test_dir/vol", 0))
+	}()
+
+	// the one reached first holds the subtree
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir(), Workers: 1})
+	dir := analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	vol := childByName(dir, "vol").(*Dir)
+	assert.Equal(t, absTestPath(t, "test_dir", "nested"), vol.DuplicateOf)
+	assert.Equal(t, int64(1), analyzer.GetCacheStats().DuplicateDirsSkipped)
+}
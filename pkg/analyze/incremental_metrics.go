@@ -0,0 +1,99 @@
+package analyze
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// OperationMetrics holds durations of the calls of one storage operation
+type OperationMetrics struct {
+	Count int64
+	Total time.Duration
+	Max   time.Duration
+}
+
+// Avg returns the average duration of a call
+func (m OperationMetrics) Avg() time.Duration {
+	if m.Count == 0 {
+		return 0
+	}
+	return m.Total / time.Duration(m.Count)
+}
+
+// String formats the metrics as e.g. "1.2M, avg 38µs, max 210ms"
+func (m OperationMetrics) String() string {
+	return fmt.Sprintf("%s, avg %v, max %v", formatCount(m.Count), m.Avg(), m.Max)
+}
+
+func (m *OperationMetrics) add(other OperationMetrics) {
+	m.Count += other.Count
+	m.Total += other.Total
+	m.Max = max(m.Max, other.Max)
+}
+
+// StorageMetrics holds durations of the operations of IncrementalStorage
+type StorageMetrics struct {
+	Reads   OperationMetrics // LoadDirMetadata calls including decoding
+	Decodes OperationMetrics // decoding of the loaded entries
+	Writes  OperationMetrics // StoreDirMetadata calls including encoding
+}
+
+// operationTimer accumulates durations of the calls of one operation.
+// It is safe for concurrent use and costs a few atomic operations per call
+type operationTimer struct {
+	count atomic.Int64
+	total atomic.Int64
+	max   atomic.Int64
+}
+
+// since records the call started at start
+func (t *operationTimer) since(start time.Time) {
+	d := int64(time.Since(start))
+	t.count.Add(1)
+	t.total.Add(d)
+	for {
+		current := t.max.Load()
+		if d <= current || t.max.CompareAndSwap(current, d) {
+			return
+		}
+	}
+}
+
+func (t *operationTimer) metrics() OperationMetrics {
+	return OperationMetrics{
+		Count: t.count.Load(),
+		Total: time.Duration(t.total.Load()),
+		Max:   time.Duration(t.max.Load()),
+	}
+}
+
+// storageTimers holds timers of the operations of IncrementalStorage
+type storageTimers struct {
+	reads   operationTimer
+	decodes operationTimer
+	writes  operationTimer
+}
+
+// Metrics returns durations of the storage operations since the storage was created
+func (s *IncrementalStorage) Metrics() StorageMetrics {
+	return StorageMetrics{
+		Reads:   s.timers.reads.metrics(),
+		Decodes: s.timers.decodes.metrics(),
+		Writes:  s.timers.writes.metrics(),
+	}
+}
+
+// formatCount formats count as human-readable number, e.g. 1.2M
+func formatCount(count int64) string {
+	const unit = 1000
+	if count < unit {
+		return fmt.Sprintf("%d", count)
+	}
+	div, exp := int64(unit), 0
+	for n := count / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(count)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,56 @@
+package analyze
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageMetrics(t *testing.T) {
+	root := createTraceFixture(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	scan := func() *CacheStats {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+		analyzer.GetDone().Wait()
+		return analyzer.GetCacheStats()
+	}
+
+	// root, a, a/b and c
+	const dirs = 4
+
+	cold := scan()
+	assert.Equal(t, int64(dirs), cold.Storage.Writes.Count)
+	assert.Equal(t, int64(0), cold.Storage.Decodes.Count)
+
+	warm := scan()
+	assert.Equal(t, int64(dirs), warm.Storage.Reads.Count)
+	assert.Equal(t, int64(dirs), warm.Storage.Decodes.Count)
+	assert.Equal(t, int64(0), warm.Storage.Writes.Count)
+	assert.Greater(t, warm.Storage.Reads.Total, time.Duration(0))
+	assert.GreaterOrEqual(t, warm.Storage.Reads.Max, warm.Storage.Reads.Avg())
+	assert.GreaterOrEqual(t, warm.Storage.Reads.Total, warm.Storage.Decodes.Total)
+}
+
+func TestOperationTimer(t *testing.T) {
+	timer := &operationTimer{}
+	timer.since(time.Now().Add(-2 * time.Millisecond))
+	timer.since(time.Now().Add(-4 * time.Millisecond))
+	timer.since(time.Now())
+
+	metrics := timer.metrics()
+	assert.Equal(t, int64(3), metrics.Count)
+	assert.GreaterOrEqual(t, metrics.Max, 4*time.Millisecond)
+	assert.GreaterOrEqual(t, metrics.Total, 6*time.Millisecond)
+	assert.Equal(t, metrics.Total/3, metrics.Avg())
+	assert.Equal(t, time.Duration(0), OperationMetrics{}.Avg())
+}
+
+func TestOperationMetricsString(t *testing.T) {
+	metrics := OperationMetrics{Count: 1_200_000, Total: 1_200_000 * 38 * time.Microsecond, Max: 210 * time.Millisecond}
+	assert.Equal(t, "1.2M, avg 38µs, max 210ms", metrics.String())
+	assert.Equal(t, "999", formatCount(999))
+	assert.Equal(t, "1.5K", formatCount(1500))
+}
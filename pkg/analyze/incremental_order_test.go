@@ -0,0 +1,92 @@
+package analyze
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createOrderFixture creates directories and files with names in non-alphabetical creation order
+func createOrderFixture(t *testing.T) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "root")
+	for _, dir := range []string{"zeta", "alpha/omega", "alpha/beta", "Mid"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o755))
+	}
+	for i, file := range []string{"z.txt", "a.txt", "alpha/omega/x", "alpha/b", "alpha/a", "m.txt", "_first"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(root, file), bytes.Repeat([]byte("x"), i+1), 0o600))
+	}
+	return root
+}
+
+func exportScan(t *testing.T, root string, opts IncrementalOptions) (*Dir, string) {
+	t.Helper()
+	analyzer := CreateIncrementalAnalyzer(opts)
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	var buff bytes.Buffer
+	assert.NoError(t, dir.EncodeJSON(&buff, true))
+	return dir, buff.String()
+}
+
+func TestIncrementalAnalyzer_ExportDeterministic(t *testing.T) {
+	root := createOrderFixture(t)
+	storagePath := t.TempDir()
+
+	_, cold := exportScan(t, root, IncrementalOptions{StoragePath: storagePath})
+	_, warm := exportScan(t, root, IncrementalOptions{StoragePath: storagePath})
+	assert.Equal(t, cold, warm)
+}
+
+func childNames(dir *Dir) []string {
+	names := make([]string, 0, len(dir.Files))
+	for _, item := range dir.Files {
+		names = append(names, item.GetName())
+	}
+	return names
+}
+
+func TestIncrementalAnalyzer_ChildrenSortedByName(t *testing.T) {
+	root := createOrderFixture(t)
+
+	dir, _ := exportScan(t, root, IncrementalOptions{StoragePath: t.TempDir()})
+	assert.Equal(t, []string{"Mid", "_first", "a.txt", "alpha", "m.txt", "z.txt", "zeta"}, childNames(dir))
+	assert.Equal(t, []string{"a", "b", "beta", "omega"}, childNames(childByName(dir, "alpha").(*Dir)))
+}
+
+func TestIncrementalAnalyzer_UnsortedCacheEntrySorted(t *testing.T) {
+	root := createOrderFixture(t)
+	storagePath := t.TempDir()
+
+	// cache written without sorting (or by an older version)
+	_, sorted := exportScan(t, root, IncrementalOptions{StoragePath: storagePath})
+	storage := NewIncrementalStorage(storagePath, root)
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	meta, err := storage.LoadDirMetadata(root)
+	assert.NoError(t, err)
+	for i, j := 0, len(meta.Files)-1; i < j; i, j = i+1, j-1 {
+		meta.Files[i], meta.Files[j] = meta.Files[j], meta.Files[i]
+	}
+	assert.NoError(t, storage.StoreDirMetadata(meta))
+	closeFn()
+
+	_, warm := exportScan(t, root, IncrementalOptions{StoragePath: storagePath})
+	assert.Equal(t, sorted, warm)
+
+	dir, _ := exportScan(t, root, IncrementalOptions{StoragePath: storagePath, UnsortedChildren: true})
+	assert.Equal(t, []string{"zeta", "z.txt", "m.txt", "alpha", "a.txt", "_first", "Mid"}, childNames(dir),
+		"Cached order is kept without sorting")
+}
+
+func TestIncrementalAnalyzer_UnsortedChildren(t *testing.T) {
+	root := createOrderFixture(t)
+
+	dir, _ := exportScan(t, root, IncrementalOptions{StoragePath: t.TempDir(), UnsortedChildren: true})
+	assert.ElementsMatch(t, []string{"Mid", "_first", "a.txt", "alpha", "m.txt", "z.txt", "zeta"}, childNames(dir))
+	assert.Equal(t, 13, dir.ItemCount)
+}
@@ -0,0 +1,219 @@
+package analyze
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/pkg/errors"
+)
+
+// DefaultDirPageSize is the number of children stored in one page of a large directory.
+// Entries of directories with more children hold no children themselves,
+// the children are stored in pages under KeyPrefixDirPage. So neither a write nor a read
+// of one value has to handle all of them, which would exceed the transaction size
+// of the database for directories with hundreds of thousands of files
+const DefaultDirPageSize = 10000
+
+// dirPageKey creates a key of page (numbered from 1) of children of directory at path
+func dirPageKey(path string, page int) []byte {
+	return []byte(fmt.Sprintf("%s%s#%06d", KeyPrefixDirPage, path, page))
+}
+
+// dirPageKeyPath returns path of the directory of page key, ok is false if key is not a page key
+func dirPageKeyPath(key []byte) (path string, ok bool) {
+	rest := string(key[len(KeyPrefixDirPage):])
+	pos := strings.LastIndex(rest, "#")
+	if pos < 0 {
+		return "", false
+	}
+	if _, err := strconv.Atoi(rest[pos+1:]); err != nil {
+		return "", false
+	}
+	return rest[:pos], true
+}
+
+// dirPageSize returns number of children stored in one page
+func (s *IncrementalStorage) dirPageSize() int {
+	if s.pageSize > 0 {
+		return s.pageSize
+	}
+	return DefaultDirPageSize
+}
+
+// encodedDirEntry is directory metadata encoded for writing
+type encodedDirEntry struct {
+	path  string
+	entry []byte
+	pages [][]byte // values of the pages, nil if the children are stored in the entry
+}
+
+// encodeDirEntry encodes meta into the value of its entry and, if it has more children
+// than fit into one page, into the values of its pages. Meta is not modified
+func (s *IncrementalStorage) encodeDirEntry(meta *IncrementalDirMetadata) (*encodedDirEntry, error) {
+	encoded := &encodedDirEntry{path: meta.Path}
+
+	header := *meta
+	header.Pages = 0
+	if pageSize := s.dirPageSize(); len(meta.Files) > pageSize {
+		for start := 0; start < len(meta.Files); start += pageSize {
+			b := &bytes.Buffer{}
+			page := meta.Files[start:min(start+pageSize, len(meta.Files))]
+			if err := gob.NewEncoder(b).Encode(page); err != nil {
+				return nil, errors.Wrap(err, "encoding page of directory metadata")
+			}
+			encoded.pages = append(encoded.pages, b.Bytes())
+		}
+		header.Files = nil
+		header.Pages = len(encoded.pages)
+	}
+
+	b := &bytes.Buffer{}
+	if err := gob.NewEncoder(b).Encode(&header); err != nil {
+		return nil, errors.Wrap(err, "encoding directory metadata")
+	}
+	encoded.entry = b.Bytes()
+	return encoded, nil
+}
+
+// writeDirEntries writes the entries by a write batch, which splits the writes into transactions
+// of allowed size. Pages are written before the entries referencing them and pages left over
+// from larger previous versions of the entries are removed
+func (s *IncrementalStorage) writeDirEntries(entries []*encodedDirEntry) error {
+	stale := make([][]byte, 0)
+	err := s.db.View(func(txn *badger.Txn) error {
+		for _, e := range entries {
+			keys, err := stalePageKeys(txn, e.path, len(e.pages)+1)
+			if err != nil {
+				return err
+			}
+			stale = append(stale, keys...)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	for _, e := range entries {
+		for i, page := range e.pages {
+			if err := wb.Set(dirPageKey(e.path, i+1), page); err != nil {
+				return err
+			}
+		}
+	}
+	for _, e := range entries {
+		if err := wb.Set(s.makeKey(e.path), e.entry); err != nil {
+			return err
+		}
+	}
+	for _, key := range stale {
+		if err := wb.Delete(key); err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+// stalePageKeys returns keys of pages of directory at path from page first on.
+// Pages of an entry are numbered from 1 without gaps, so the lookup stops at the first missing one
+func stalePageKeys(txn *badger.Txn, path string, first int) ([][]byte, error) {
+	keys := make([][]byte, 0)
+	for page := first; ; page++ {
+		key := dirPageKey(path, page)
+		_, err := txn.Get(key)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return keys, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+}
+
+// allPageKeys returns keys of all pages of directory at path, unlike stalePageKeys
+// it finds also pages left after a gap (e.g. in a damaged cache)
+func allPageKeys(txn *badger.Txn, path string) [][]byte {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	keys := make([][]byte, 0)
+	prefix := []byte(KeyPrefixDirPage + path + "#")
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if dir, ok := dirPageKeyPath(it.Item().Key()); ok && dir == path {
+			keys = append(keys, it.Item().KeyCopy(nil))
+		}
+	}
+	return keys
+}
+
+// loadDirPage reads page (numbered from 1) of children of directory at path
+func loadDirPage(txn *badger.Txn, path string, page int) ([]FileMetadata, error) {
+	item, err := txn.Get(dirPageKey(path, page))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, &CorruptedEntryError{Path: path, Reason: fmt.Sprintf("missing page %d", page)}
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading cached metadata for path: "+path)
+	}
+
+	var files []FileMetadata
+	err = item.Value(func(val []byte) error {
+		if err := gob.NewDecoder(bytes.NewBuffer(val)).Decode(&files); err != nil {
+			return &CorruptedEntryError{Path: path, Reason: fmt.Sprintf("decoding page %d failed", page), Err: err}
+		}
+		return nil
+	})
+	return files, err
+}
+
+// LoadDirChildrenPage loads one page (numbered from 0) of direct children of the cached directory
+// and returns them together with the number of pages.
+// Consumers needing only some of the children of a large directory use it instead of LoadDirMetadata,
+// which decodes all of them. Children of directories stored in one entry (small directories
+// and entries written before the children were paged) form a single page
+func (s *IncrementalStorage) LoadDirChildrenPage(path string, page int) ([]FileMetadata, int, error) {
+	s.checkCount()
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.db == nil {
+		return nil, 0, fmt.Errorf("storage is not open")
+	}
+	defer s.timers.reads.since(time.Now())
+
+	var (
+		files []FileMetadata
+		pages int
+	)
+	err := s.db.View(func(txn *badger.Txn) error {
+		meta, err := s.loadDirEntry(txn, path)
+		if err != nil {
+			return err
+		}
+
+		pages = max(meta.Pages, 1)
+		if page < 0 || page >= pages {
+			return fmt.Errorf("page %d of %s out of range (%d pages)", page, path, pages)
+		}
+		if meta.Pages == 0 {
+			files = meta.Files
+			return nil
+		}
+		files, err = loadDirPage(txn, path, page+1)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return files, pages, nil
+}
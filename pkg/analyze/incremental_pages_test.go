@@ -0,0 +1,159 @@
+package analyze
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func largeDirMetadata(path string, children int) *IncrementalDirMetadata {
+	meta := &IncrementalDirMetadata{
+		Path:      path,
+		Mtime:     time.Now(),
+		ItemCount: children + 1,
+		Flag:      ' ',
+		CachedAt:  time.Now(),
+		Files:     make([]FileMetadata, children),
+	}
+	for i := range meta.Files {
+		meta.Files[i] = FileMetadata{Name: fmt.Sprintf("file%07d", i), Size: int64(i), Usage: 4096, Flag: ' '}
+	}
+	return meta
+}
+
+func pageKeys(t *testing.T, storage *IncrementalStorage) []string {
+	t.Helper()
+	keys := make([]string, 0)
+	assert.NoError(t, storage.Iterate(KeyPrefixDirPage, func(key, _ []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	}))
+	return keys
+}
+
+func TestIncrementalStorage_LargeDirectory(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/big")
+	mustOpen(t, storage)
+
+	// one value holding all the children would exceed the transaction size
+	meta := largeDirMetadata("/big", 500000)
+	assert.NoError(t, storage.StoreDirMetadata(meta))
+	assert.Len(t, pageKeys(t, storage), 50)
+
+	loaded, err := storage.LoadDirMetadata("/big")
+	assert.NoError(t, err)
+	assert.Len(t, loaded.Files, 500000)
+	assert.Equal(t, meta.Files[0], loaded.Files[0])
+	assert.Equal(t, meta.Files[499999], loaded.Files[499999])
+	assert.Equal(t, 50, loaded.Pages)
+	assert.Equal(t, 500001, loaded.ItemCount)
+
+	files, pages, err := storage.LoadDirChildrenPage("/big", 49)
+	assert.NoError(t, err)
+	assert.Equal(t, 50, pages)
+	assert.Len(t, files, DefaultDirPageSize)
+	assert.Equal(t, "file0490000", files[0].Name)
+	assert.Equal(t, "file0499999", files[len(files)-1].Name)
+
+	_, _, err = storage.LoadDirChildrenPage("/big", 50)
+	assert.ErrorContains(t, err, "page 50 of /big out of range (50 pages)")
+
+	// the batch write splits the pages into transactions too
+	assert.NoError(t, storage.StoreDirMetadataBatch([]*IncrementalDirMetadata{
+		largeDirMetadata("/big", 300000), largeDirMetadata("/big/sub", 10),
+	}))
+	assert.Len(t, pageKeys(t, storage), 30)
+	loaded, err = storage.LoadDirMetadata("/big")
+	assert.NoError(t, err)
+	assert.Len(t, loaded.Files, 300000)
+}
+
+func TestIncrementalStorage_PagesOfShrinkingDirectory(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/dir")
+	storage.pageSize = 10
+	mustOpen(t, storage)
+
+	assert.NoError(t, storage.StoreDirMetadata(largeDirMetadata("/dir", 25)))
+	assert.NoError(t, storage.StoreDirMetadata(largeDirMetadata("/dir#000001", 11)))
+	assert.Len(t, pageKeys(t, storage), 5)
+
+	// pages left over from the larger version are removed
+	assert.NoError(t, storage.StoreDirMetadata(largeDirMetadata("/dir", 15)))
+	assert.Equal(t, []string{
+		"page:/dir#000001", "page:/dir#000001#000001", "page:/dir#000001#000002", "page:/dir#000002",
+	}, pageKeys(t, storage))
+
+	assert.NoError(t, storage.StoreDirMetadata(largeDirMetadata("/dir", 5)))
+	files, pages, err := storage.LoadDirChildrenPage("/dir", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pages)
+	assert.Len(t, files, 5)
+	assert.Len(t, pageKeys(t, storage), 2)
+
+	// pages of a directory named like a page key are its own
+	assert.NoError(t, storage.DeleteDirMetadata("/dir#000001"))
+	assert.Empty(t, pageKeys(t, storage))
+
+	assert.NoError(t, storage.StoreDirMetadata(largeDirMetadata("/dir/sub", 25)))
+	preview, err := storage.DeleteSubtreeDryRun("/dir")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, preview.Entries)
+	removed, err := storage.DeleteSubtree("/dir")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, removed)
+	assert.Empty(t, pageKeys(t, storage))
+}
+
+func TestIncrementalStorage_LoadsEntriesWithoutPages(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/old")
+	storage.pageSize = 10
+	mustOpen(t, storage)
+
+	// entry of schema 3 holds all the children however many there are
+	meta := largeDirMetadata("/old", 25)
+	b := &bytes.Buffer{}
+	assert.NoError(t, gob.NewEncoder(b).Encode(meta))
+	assert.NoError(t, storage.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(storage.makeKey("/old"), b.Bytes())
+	}))
+
+	loaded, err := storage.LoadDirMetadata("/old")
+	assert.NoError(t, err)
+	assert.Len(t, loaded.Files, 25)
+	assert.Zero(t, loaded.Pages)
+
+	files, pages, err := storage.LoadDirChildrenPage("/old", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pages)
+	assert.Len(t, files, 25)
+}
+
+func TestIncrementalStorage_FsckMissingPage(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/dir")
+	storage.pageSize = 10
+	mustOpen(t, storage)
+
+	assert.NoError(t, storage.StoreDirMetadata(largeDirMetadata("/dir", 25)))
+	result, err := storage.CheckIntegrity(false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Checked)
+	assert.Zero(t, result.Invalid)
+
+	assert.NoError(t, storage.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(dirPageKey("/dir", 2))
+	}))
+	_, err = storage.LoadDirMetadata("/dir")
+	assert.ErrorIs(t, err, ErrCorruptedEntry)
+
+	result, err = storage.CheckIntegrity(true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Invalid)
+	assert.Equal(t, 1, result.Repaired)
+	assert.ErrorContains(t, result.Problems[0], "corrupted cache entry for /dir: missing page 2")
+	assert.Empty(t, pageKeys(t, storage))
+}
@@ -0,0 +1,132 @@
+package analyze
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultPrefetchSize is the number of cache entries loaded ahead
+// when IncrementalOptions.PrefetchSize is not set
+const DefaultPrefetchSize = 256
+
+// prefetchWorkers is the maximum number of concurrent loads of the prefetcher
+const prefetchWorkers = 8
+
+// prefetcher loads cache entries of subdirectories in background while their parent
+// is being rebuilt from the cache, so the following recursion mostly finds them in memory.
+// It keeps at most size entries, the oldest ones are dropped first.
+// It is shared by the workers of the scan
+type prefetcher struct {
+	storage *IncrementalStorage
+	size    int
+	m       sync.Mutex // guards entries and order
+	entries map[string]*list.Element
+	order   *list.List // of *prefetchedEntry, oldest first
+	workers chan struct{}
+	wait    sync.WaitGroup
+}
+
+type prefetchedEntry struct {
+	path string
+	done chan struct{}
+	meta *IncrementalDirMetadata
+	err  error
+}
+
+// newPrefetcher returns prefetcher keeping size entries, nil if size is not positive
+func newPrefetcher(storage *IncrementalStorage, size int) *prefetcher {
+	if size <= 0 {
+		return nil
+	}
+	return &prefetcher{
+		storage: storage,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		workers: make(chan struct{}, prefetchWorkers),
+	}
+}
+
+// prefetch starts loading of the entries of paths which are not loaded yet
+func (p *prefetcher) prefetch(paths []string) {
+	if p == nil {
+		return
+	}
+	// the later ones would only push out the first ones
+	if len(paths) > p.size {
+		paths = paths[:p.size]
+	}
+	p.m.Lock()
+	defer p.m.Unlock()
+	for _, path := range paths {
+		if _, ok := p.entries[path]; ok {
+			continue
+		}
+		if p.order.Len() >= p.size {
+			oldest := p.order.Front()
+			delete(p.entries, oldest.Value.(*prefetchedEntry).path)
+			p.order.Remove(oldest)
+		}
+
+		entry := &prefetchedEntry{path: path, done: make(chan struct{})}
+		p.entries[path] = p.order.PushBack(entry)
+		p.wait.Add(1)
+		go func() {
+			defer p.wait.Done()
+			defer close(entry.done)
+			p.workers <- struct{}{}
+			defer func() { <-p.workers }()
+			entry.meta, entry.err = p.storage.LoadDirMetadata(entry.path)
+		}()
+	}
+}
+
+// take returns the loaded entry of path and forgets it, nil if it was not prefetched
+func (p *prefetcher) take(path string) *prefetchedEntry {
+	if p == nil {
+		return nil
+	}
+	p.m.Lock()
+	elem, ok := p.entries[path]
+	if ok {
+		delete(p.entries, path)
+		p.order.Remove(elem)
+	}
+	p.m.Unlock()
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*prefetchedEntry)
+	<-entry.done
+	return entry
+}
+
+// stop waits for the running loads, so the storage can be closed
+func (p *prefetcher) stop() {
+	if p == nil {
+		return
+	}
+	p.wait.Wait()
+}
+
+// loadCachedChild returns cache entry of the subdirectory at path, from memory if it was prefetched
+func (a *IncrementalAnalyzer) loadCachedChild(path string) (*IncrementalDirMetadata, error) {
+	if entry := a.prefetch.take(path); entry != nil {
+		return entry.meta, entry.err
+	}
+	return a.storage.LoadDirMetadata(path)
+}
+
+// prefetchChildren starts loading of cache entries of subdirectories of the cached directory
+func (a *IncrementalAnalyzer) prefetchChildren(cached *IncrementalDirMetadata) {
+	if a.prefetch == nil {
+		return
+	}
+	paths := make([]string, 0)
+	for _, fileMeta := range cached.Files {
+		if fileMeta.IsDir {
+			paths = append(paths, joinPath(cached.Path, fileMeta.Name))
+		}
+	}
+	a.prefetch.prefetch(paths)
+}
@@ -0,0 +1,129 @@
+package analyze
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createWideTree creates levels of directories, each with fanout subdirectories and one file
+func createWideTree(tb testing.TB, root string, fanout, levels int) {
+	tb.Helper()
+	assert.NoError(tb, os.WriteFile(filepath.Join(root, "file"), []byte("data"), 0o600))
+	if levels == 0 {
+		return
+	}
+	for i := 0; i < fanout; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("d%d", i))
+		assert.NoError(tb, os.Mkdir(dir, 0o755))
+		createWideTree(tb, dir, fanout, levels-1)
+	}
+}
+
+// flattenTree returns size, usage, item count and flag of every item under dir by path
+func flattenTree(dir *Dir) map[string]string {
+	items := make(map[string]string)
+	var walk func(item *Dir)
+	walk = func(item *Dir) {
+		items[item.GetPath()] = fmt.Sprintf("%d %d %d %c", item.Size, item.Usage, item.ItemCount, item.Flag)
+		for _, child := range item.Files {
+			if sub, ok := child.(*Dir); ok {
+				walk(sub)
+				continue
+			}
+			items[child.GetPath()] = fmt.Sprintf("%d %d %c", child.GetSize(), child.GetUsage(), child.GetFlag())
+		}
+	}
+	walk(dir)
+	return items
+}
+
+func TestIncrementalAnalyzer_PrefetchSameResult(t *testing.T) {
+	root := t.TempDir()
+	createWideTree(t, root, 4, 3)
+	storagePath := t.TempDir()
+
+	scan := func(prefetchSize int) (map[string]string, *CacheStats) {
+		analyzer := CreateIncrementalAnalyzer(IncrementalOptions{
+			StoragePath:  storagePath,
+			PrefetchSize: prefetchSize,
+		})
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		return flattenTree(dir), analyzer.GetCacheStats()
+	}
+
+	cold, _ := scan(-1)
+	serial, serialStats := scan(-1)
+	// smaller than the number of subdirectories, so entries are evicted
+	for _, size := range []int{3, 0} {
+		prefetched, stats := scan(size)
+		assert.Equal(t, cold, prefetched, "size %d", size)
+		assert.Equal(t, serial, prefetched, "size %d", size)
+		assert.Equal(t, serialStats.TotalDirs, stats.TotalDirs)
+		assert.Equal(t, serialStats.CacheHits, stats.CacheHits)
+		assert.Equal(t, serialStats.CacheMisses, stats.CacheMisses)
+		assert.Equal(t, serialStats.BytesFromCache, stats.BytesFromCache)
+		assert.Equal(t, int64(0), stats.CacheMisses)
+	}
+}
+
+func TestPrefetcher_Evicts(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/test")
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	defer closeFn()
+	assert.NoError(t, storage.StoreDirMetadata(&IncrementalDirMetadata{Path: "/test/a", Size: 1}))
+
+	p := newPrefetcher(storage, 2)
+	p.prefetch([]string{"/test/a", "/test/b"})
+	p.prefetch([]string{"/test/b", "/test/c"})
+	assert.Equal(t, 2, p.order.Len())
+
+	assert.Nil(t, p.take("/test/a"), "oldest entry is evicted")
+	entry := p.take("/test/b")
+	assert.NotNil(t, entry)
+	assert.Error(t, entry.err, "misses are kept as well")
+	assert.Nil(t, p.take("/test/b"), "entry is taken once")
+
+	// at most size paths are prefetched at once
+	p.prefetch([]string{"/test/a", "/test/x", "/test/y"})
+	assert.Equal(t, 2, p.order.Len())
+	entry = p.take("/test/a")
+	assert.NotNil(t, entry)
+	assert.NoError(t, entry.err)
+	assert.Equal(t, int64(1), entry.meta.Size)
+	p.stop()
+
+	assert.Nil(t, newPrefetcher(storage, 0))
+	assert.Nil(t, newPrefetcher(storage, -1).take("/test/a"), "nil prefetcher is disabled")
+}
+
+// benchmarkWarmScan measures warm scans of about 100k directories
+func benchmarkWarmScan(b *testing.B, prefetchSize int) {
+	root := b.TempDir()
+	createWideTree(b, root, 10, 5)
+	opts := IncrementalOptions{StoragePath: b.TempDir(), PrefetchSize: prefetchSize}
+
+	analyzer := CreateIncrementalAnalyzer(opts)
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+		analyzer.GetDone().Wait()
+	}
+}
+
+func BenchmarkWarmScanSerial(b *testing.B) {
+	benchmarkWarmScan(b, -1)
+}
+
+func BenchmarkWarmScanPrefetch(b *testing.B) {
+	benchmarkWarmScan(b, 0)
+}
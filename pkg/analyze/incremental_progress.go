@@ -0,0 +1,118 @@
+package analyze
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/dundee/gdu/v5/internal/common"
+)
+
+// progressPump forwards progress updates of a single scan to its consumer.
+// The out channel is closed once the pump is stopped,
+// so consumers can simply range over it
+type progressPump struct {
+	in        chan common.CurrentProgress
+	out       chan common.CurrentProgress
+	done      chan struct{}
+	stopped   chan struct{}
+	startOnce sync.Once
+	stopOnce  sync.Once
+	progress  common.CurrentProgress
+	latest    atomic.Pointer[common.CurrentProgress] // copy of progress readable by other goroutines
+}
+
+func newProgressPump() *progressPump {
+	return &progressPump{
+		in:      make(chan common.CurrentProgress, 1),
+		out:     make(chan common.CurrentProgress, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+// start starts forwarding of updates, calling it repeatedly has no effect
+func (p *progressPump) start() {
+	p.startOnce.Do(func() {
+		go p.run()
+	})
+}
+
+// stop forwards updates sent so far, closes the out channel
+// and waits until the forwarding goroutine exits.
+// It is idempotent and can be called even if the pump was never started
+func (p *progressPump) stop() {
+	p.start()
+	p.stopOnce.Do(func() {
+		close(p.done)
+	})
+	<-p.stopped
+}
+
+// send passes update to the pump, it never blocks once the pump is stopped
+func (p *progressPump) send(progress common.CurrentProgress) {
+	select {
+	case p.in <- progress:
+	case <-p.done:
+	}
+}
+
+func (p *progressPump) run() {
+	defer close(p.stopped)
+	defer close(p.out)
+
+	for {
+		select {
+		case <-p.done:
+			p.flush()
+			return
+		case progress := <-p.in:
+			p.add(progress)
+			p.forward()
+		}
+	}
+}
+
+// flush forwards updates left in the in channel when the pump was stopped
+func (p *progressPump) flush() {
+	for {
+		select {
+		case progress := <-p.in:
+			p.add(progress)
+		default:
+			p.forward()
+			return
+		}
+	}
+}
+
+func (p *progressPump) add(progress common.CurrentProgress) {
+	p.progress.CurrentItemName = progress.CurrentItemName
+	p.progress.ItemCount += progress.ItemCount
+	p.progress.TotalSize += progress.TotalSize
+	latest := p.progress
+	p.latest.Store(&latest)
+}
+
+// current returns the totals received so far, it can be called from any goroutine
+func (p *progressPump) current() common.CurrentProgress {
+	if latest := p.latest.Load(); latest != nil {
+		return *latest
+	}
+	return common.CurrentProgress{}
+}
+
+// forward never blocks, an update not yet read by the consumer
+// is replaced with the current one so the latest progress is always delivered
+func (p *progressPump) forward() {
+	for {
+		select {
+		case p.out <- p.progress:
+			return
+		default:
+		}
+		select {
+		case <-p.out:
+		default:
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package analyze
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dundee/gdu/v5/internal/common"
+	"github.com/dundee/gdu/v5/internal/testdir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressPump(t *testing.T) {
+	pump := newProgressPump()
+	pump.start()
+	pump.start()
+
+	pump.send(common.CurrentProgress{CurrentItemName: "a", ItemCount: 1, TotalSize: 10})
+	pump.send(common.CurrentProgress{CurrentItemName: "b", ItemCount: 2, TotalSize: 20})
+	pump.stop()
+	pump.stop()
+
+	var last common.CurrentProgress
+	for progress := range pump.out {
+		last = progress
+	}
+	assert.Equal(t, "b", last.CurrentItemName)
+	assert.Equal(t, 3, last.ItemCount)
+	assert.Equal(t, int64(30), last.TotalSize)
+
+	// sending to a stopped pump does not block
+	pump.send(common.CurrentProgress{ItemCount: 1})
+}
+
+func TestProgressPumpStopWithoutStart(t *testing.T) {
+	pump := newProgressPump()
+	pump.stop()
+
+	_, ok := <-pump.out
+	assert.True(t, ok, "final progress is flushed")
+	_, ok = <-pump.out
+	assert.False(t, ok)
+}
+
+func TestProgressPumpConcurrentStop(t *testing.T) {
+	pump := newProgressPump()
+	pump.start()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			pump.send(common.CurrentProgress{ItemCount: 1})
+		}()
+		go func() {
+			defer wg.Done()
+			pump.stop()
+		}()
+	}
+	wg.Wait()
+
+	for range pump.out {
+	}
+}
+
+func TestIncrementalAnalyzer_ProgressChanClosedAtScanEnd(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+
+	// channel can be obtained before the scan starts
+	progressChan := analyzer.GetProgressChan()
+
+	var last common.CurrentProgress
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for progress := range progressChan {
+			last = progress
+		}
+	}()
+
+	dir := analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	<-done
+	analyzer.GetDone().Wait()
+
+	assert.Equal(t, "test_dir", dir.GetName())
+	assert.Greater(t, last.ItemCount, 0)
+
+	// a fresh channel is used for the next scan
+	analyzer.ResetProgress()
+	assert.NotEqual(t, progressChan, analyzer.GetProgressChan())
+}
+
+// scanWithProgress runs the scan and returns the resulting dir and the last progress
+func scanWithProgress(t *testing.T, analyzer *IncrementalAnalyzer, path string) (*Dir, common.CurrentProgress) {
+	t.Helper()
+	analyzer.ResetProgress()
+	progressChan := analyzer.GetProgressChan()
+
+	var last common.CurrentProgress
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for progress := range progressChan {
+			last = progress
+		}
+	}()
+
+	dir := analyzer.AnalyzeDir(path, func(_, _ string) bool { return false }, false).(*Dir)
+	<-done
+	analyzer.GetDone().Wait()
+	return dir, last
+}
+
+func TestIncrementalAnalyzer_ProgressMatchesTree(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	assert.NoError(t, os.MkdirAll("test_dir/other/deep", 0o755))
+	assert.NoError(t, os.WriteFile("test_dir/other/deep/file", []byte("hello"), 0o600))
+	storagePath := t.TempDir()
+
+	assertProgress := func(name string) {
+		analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath})
+		dir, last := scanWithProgress(t, analyzer, "test_dir")
+		assert.Equal(t, dir.ItemCount, last.ItemCount, name)
+		assert.Equal(t, dir.Size, last.TotalSize, name)
+	}
+
+	assertProgress("cold")
+	assertProgress("warm")
+
+	// root changes, its subdirectories are loaded from the cache
+	assert.NoError(t, os.WriteFile("test_dir/new", []byte("new file"), 0o600))
+	assert.NoError(t, os.Chtimes("test_dir", time.Now(), time.Now().Add(time.Hour)))
+	// and one of them is missing in the cache
+	storage := NewIncrementalStorage(storagePath, "test_dir")
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	assert.NoError(t, storage.DeleteDirMetadata("test_dir/other/deep"))
+	closeFn()
+
+	assertProgress("mixed")
+}
+
+func TestIncrementalAnalyzer_CurrentProgress(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{
+		StoragePath: t.TempDir(),
+		IODelay:     5 * time.Millisecond,
+	})
+	assert.Equal(t, common.CurrentProgress{}, analyzer.CurrentProgress())
+
+	// several readers poll the progress while the throttled scan runs, run with -race
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var previous common.CurrentProgress
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				progress := analyzer.CurrentProgress()
+				assert.GreaterOrEqual(t, progress.ItemCount, previous.ItemCount)
+				assert.GreaterOrEqual(t, progress.TotalSize, previous.TotalSize)
+				previous = progress
+			}
+		}()
+	}
+
+	dir := analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+	close(stop)
+	wg.Wait()
+
+	progress := analyzer.CurrentProgress()
+	assert.Equal(t, dir.ItemCount, progress.ItemCount)
+	assert.Equal(t, dir.Size, progress.TotalSize)
+
+	analyzer.ResetProgress()
+	assert.Equal(t, common.CurrentProgress{}, analyzer.CurrentProgress())
+}
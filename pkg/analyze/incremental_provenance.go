@@ -0,0 +1,84 @@
+package analyze
+
+import (
+	"os"
+	"strings"
+
+	"github.com/dundee/gdu/v5/build"
+	log "github.com/sirupsen/logrus"
+)
+
+// provenance identifies the host and the version of gdu writing cache entries
+type provenance struct {
+	hostname   string
+	appVersion string
+}
+
+// currentProvenance returns provenance of the running process.
+// The hostname is empty if it can't be determined
+func currentProvenance() provenance {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Debugf("Failed to get hostname: %v", err)
+	}
+	return provenance{hostname: hostname, appVersion: build.Version}
+}
+
+// stamp sets the provenance of the cache entry
+func (p provenance) stamp(meta *IncrementalDirMetadata) {
+	meta.Hostname = p.hostname
+	meta.AppVersion = p.appVersion
+}
+
+// majorVersion returns the major version of gdu version string (e.g. "5" of "v5.29.0"),
+// empty string if the version is not a release one (e.g. "development")
+func majorVersion(version string) string {
+	version = strings.TrimPrefix(version, "v")
+	major, _, _ := strings.Cut(version, ".")
+	if major == "" || strings.Trim(major, "0123456789") != "" {
+		return ""
+	}
+	return major
+}
+
+// checkProvenance records provenance of the cache entry loaded from the previous scan.
+// Provenance of the entry of the scanned directory is kept in the statistics.
+// Entries written by another major version of gdu are counted,
+// the first one of the scan is logged as a warning
+func (a *IncrementalAnalyzer) checkProvenance(path string, cached *IncrementalDirMetadata) {
+	if path == a.storage.GetTopDir() {
+		a.stats.SetPreviousProvenance(cached.Hostname, cached.AppVersion)
+	}
+
+	cachedMajor := majorVersion(cached.AppVersion)
+	currentMajor := majorVersion(a.provenance.appVersion)
+	if cachedMajor == "" || currentMajor == "" || cachedMajor == currentMajor {
+		return
+	}
+
+	a.stats.IncrementVersionMismatches()
+	if !a.versionLogged.Swap(true) {
+		log.Warnf(
+			"Cache entry of %s was written by gdu %s on %s, running %s. "+
+				"Further ones are logged only at debug level",
+			path, cached.AppVersion, cached.Hostname, a.provenance.appVersion,
+		)
+	} else {
+		log.Debugf("Cache entry of %s was written by gdu %s", path, cached.AppVersion)
+	}
+}
+
+// FormatProvenance describes the writer of cache entries, e.g. "gdu v5.29.0 on host".
+// Entries written before the provenance was recorded are described as "unknown"
+func FormatProvenance(hostname, appVersion string) string {
+	if appVersion == "" && hostname == "" {
+		return "unknown"
+	}
+	if appVersion == "" {
+		appVersion = "unknown version"
+	}
+	if hostname == "" {
+		hostname = "unknown host"
+	}
+	return "gdu " + appVersion + " on " + hostname
+}
@@ -0,0 +1,90 @@
+package analyze
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dundee/gdu/v5/build"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalStorage_ProvenanceRoundTrip(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/test")
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	defer closeFn()
+
+	assert.NoError(t, storage.StoreDirMetadata(&IncrementalDirMetadata{
+		Path:       "/test",
+		ItemCount:  1,
+		Hostname:   "nfs-client-1",
+		AppVersion: "v5.29.0",
+	}))
+
+	loaded, err := storage.LoadDirMetadata("/test")
+	assert.NoError(t, err)
+	assert.Equal(t, "nfs-client-1", loaded.Hostname)
+	assert.Equal(t, "v5.29.0", loaded.AppVersion)
+}
+
+func TestIncrementalAnalyzer_Provenance(t *testing.T) {
+	defer func(version string) { build.Version = version }(build.Version)
+	build.Version = "v5.29.0"
+	hostname, _ := os.Hostname()
+
+	root := createTraceFixture(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+	scan := func() *CacheStats {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+		analyzer.GetDone().Wait()
+		return analyzer.GetCacheStats()
+	}
+
+	stats := scan()
+	assert.Equal(t, hostname, stats.Hostname)
+	assert.Equal(t, "v5.29.0", stats.AppVersion)
+	assert.Empty(t, stats.PreviousAppVersion)
+
+	storage := NewIncrementalStorage(opts.StoragePath, root)
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	meta, err := storage.LoadDirMetadata(root)
+	assert.NoError(t, err)
+	assert.Equal(t, hostname, meta.Hostname)
+	assert.Equal(t, "v5.29.0", meta.AppVersion)
+
+	// entry written by another host and major version
+	meta.Hostname, meta.AppVersion = "other-host", "v4.11.0"
+	assert.NoError(t, storage.StoreDirMetadata(meta))
+	closeFn()
+
+	stats = scan()
+	assert.Equal(t, "other-host", stats.PreviousHostname)
+	assert.Equal(t, "v4.11.0", stats.PreviousAppVersion)
+	assert.Equal(t, int64(1), stats.VersionMismatches)
+
+	// same major version is not reported
+	closeFn, err = storage.Open()
+	assert.NoError(t, err)
+	meta.AppVersion = "v5.1.0"
+	assert.NoError(t, storage.StoreDirMetadata(meta))
+	closeFn()
+
+	stats = scan()
+	assert.Equal(t, "v5.1.0", stats.PreviousAppVersion)
+	assert.Equal(t, int64(0), stats.VersionMismatches)
+}
+
+func TestMajorVersion(t *testing.T) {
+	assert.Equal(t, "5", majorVersion("v5.29.0"))
+	assert.Equal(t, "5", majorVersion("5.29.0-3-gabcdef"))
+	assert.Equal(t, "", majorVersion("development"))
+	assert.Equal(t, "", majorVersion(""))
+}
+
+func TestFormatProvenance(t *testing.T) {
+	assert.Equal(t, "gdu v5.29.0 on host", FormatProvenance("host", "v5.29.0"))
+	assert.Equal(t, "gdu v5.29.0 on unknown host", FormatProvenance("", "v5.29.0"))
+	assert.Equal(t, "unknown", FormatProvenance("", ""))
+}
@@ -0,0 +1,213 @@
+package analyze
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/dgraph-io/badger/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// whence values of lseek finding data and holes in sparse files
+const (
+	seekData = 3
+	seekHole = 4
+)
+
+// snapshotAttempts limits the number of snapshots taken by OpenReadOnly
+// when the files change under the copy (e.g. compaction of the running writer)
+const snapshotAttempts = 3
+
+// OpenReadOnly opens the cache for reading only, so several readers can use it at once.
+// If the cache is held by a writer (a running scan) or needs recovery after a crash,
+// a snapshot of its files is taken into a temporary directory and opened instead.
+// The snapshot may miss the latest writes of the running scan.
+// Returned function closes the database and removes the snapshot.
+//
+// Readers opening the cache directly hold a shared lock until closed,
+// a writer started in the meantime fails to open it
+func (s *IncrementalStorage) OpenReadOnly() (CloseFunc, error) {
+	db, err := badger.Open(s.options.apply(readOnlyOptions(s.storagePath)))
+	if err != nil && needsSnapshot(err) {
+		log.Debugf("Cache at %s is in use, reading snapshot of it: %v", s.storagePath, err)
+		return s.openSnapshot()
+	}
+	if err != nil && strings.Contains(err.Error(), "no manifest found") {
+		return nil, fmt.Errorf("no cache found at %s, run a scan with --incremental first: %w", s.storagePath, err)
+	}
+	if err != nil {
+		return nil, s.openError(err)
+	}
+
+	s.db = db
+	return s.closeFunc(""), nil
+}
+
+// openSnapshot copies the cache into a temporary directory and opens the copy read-only
+func (s *IncrementalStorage) openSnapshot() (CloseFunc, error) {
+	var err error
+	for attempt := 0; attempt < snapshotAttempts; attempt++ {
+		var dir string
+		dir, err = os.MkdirTemp("", "gdu-cache-snapshot-")
+		if err != nil {
+			return nil, fmt.Errorf("creating snapshot of cache at %s: %w", s.storagePath, err)
+		}
+
+		var db *badger.DB
+		if err = copyCacheFiles(s.storagePath, dir); err == nil {
+			db, err = openSnapshotDB(dir, s.options)
+		}
+		if err == nil {
+			s.db = db
+			return s.closeFunc(dir), nil
+		}
+
+		log.Debugf("Snapshot of cache at %s failed (attempt %d): %v", s.storagePath, attempt+1, err)
+		if rmErr := os.RemoveAll(dir); rmErr != nil {
+			log.Printf("Removing cache snapshot %s: %v", dir, rmErr)
+		}
+	}
+	return nil, fmt.Errorf("reading snapshot of cache at %s: %w", s.storagePath, err)
+}
+
+// closeFunc returns function closing the currently open database and removing snapshotDir if set
+func (s *IncrementalStorage) closeFunc(snapshotDir string) CloseFunc {
+	db := s.db
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.m.Lock()
+			defer s.m.Unlock()
+			if s.db == db {
+				s.db.Close()
+				s.db = nil
+				s.events.closeAll()
+			}
+			if snapshotDir != "" {
+				if err := os.RemoveAll(snapshotDir); err != nil {
+					log.Printf("Removing cache snapshot %s: %v", snapshotDir, err)
+				}
+			}
+		})
+	}
+}
+
+func readOnlyOptions(path string) badger.Options {
+	options := badger.DefaultOptions(path).WithReadOnly(true)
+	options.Logger = nil
+	return options
+}
+
+// needsSnapshot returns true if the read-only open failed because of the writer
+// holding the lock or because the logs have to be truncated, which is not possible read-only
+func needsSnapshot(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "Cannot acquire directory lock") ||
+		strings.Contains(msg, badger.ErrTruncateNeeded.Error())
+}
+
+// openSnapshotDB opens the copied cache in dir. It is opened for writing first
+// to truncate logs cut in the middle of a write, then reopened read-only
+func openSnapshotDB(dir string, tuning StorageOptions) (*badger.DB, error) {
+	options := badger.DefaultOptions(dir)
+	options.Logger = nil
+	db, err := badger.Open(tuning.apply(options))
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Close(); err != nil {
+		return nil, err
+	}
+	return badger.Open(tuning.apply(readOnlyOptions(dir)))
+}
+
+// copyCacheFiles copies files of the database in src to dst. The manifest is copied
+// first, so the tables listed in it are still present when they are linked.
+// Tables are immutable and hardlinked if possible, logs are copied.
+// Files removed by the writer during the copy are skipped
+func copyCacheFiles(src, dst string) error {
+	if err := copyFile(filepath.Join(src, badger.ManifestFilename), filepath.Join(dst, badger.ManifestFilename)); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == badger.ManifestFilename || name == "LOCK" {
+			continue
+		}
+
+		from, to := filepath.Join(src, name), filepath.Join(dst, name)
+		if filepath.Ext(name) == ".sst" && os.Link(from, to) == nil {
+			continue
+		}
+		if err := copyFile(from, to); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile copies src to dst keeping holes of sparse files.
+// Badger preallocates its logs (gigabytes for the value log), so only the written parts are copied
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := copyData(out, in, info.Size()); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// copyData copies the data regions of in to the same offsets of out.
+// Systems without SEEK_DATA support get the whole file copied
+func copyData(out, in *os.File, size int64) error {
+	for offset := int64(0); offset < size; {
+		start, err := in.Seek(offset, seekData)
+		if errors.Is(err, syscall.ENXIO) {
+			break // only hole up to the end
+		}
+		if err != nil {
+			start = offset
+		}
+		end, err := in.Seek(start, seekHole)
+		if err != nil {
+			end = size
+		}
+
+		if _, err := in.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := out.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(out, in, end-start); err != nil && err != io.EOF {
+			return err
+		}
+		offset = end
+	}
+	return out.Truncate(size)
+}
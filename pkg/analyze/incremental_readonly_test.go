@@ -0,0 +1,135 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dundee/gdu/v5/internal/testdir"
+)
+
+func TestOpenReadOnly(t *testing.T) {
+	storagePath := t.TempDir()
+	writer := NewIncrementalStorage(storagePath, "/data")
+	closeFn, err := writer.Open()
+	assert.NoError(t, err)
+	assert.NoError(t, writer.StoreDirMetadata(&IncrementalDirMetadata{Path: "/data", Usage: 10}))
+	closeFn()
+
+	// several readers at once
+	first := NewIncrementalStorage(storagePath, "/data")
+	closeFirst, err := first.OpenReadOnly()
+	assert.NoError(t, err)
+	second := NewIncrementalStorage(storagePath, "/data")
+	closeSecond, err := second.OpenReadOnly()
+	assert.NoError(t, err)
+
+	for _, storage := range []*IncrementalStorage{first, second} {
+		meta, err := storage.LoadDirMetadata("/data")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10), meta.Usage)
+		assert.Error(t, storage.StoreDirMetadata(&IncrementalDirMetadata{Path: "/data/a"}))
+	}
+	closeFirst()
+	closeSecond()
+	assert.False(t, first.IsOpen())
+}
+
+func TestOpenReadOnlyWhileWriterIsOpen(t *testing.T) {
+	storagePath := t.TempDir()
+	writer := NewIncrementalStorage(storagePath, "/data")
+	closeWriter, err := writer.Open()
+	assert.NoError(t, err)
+	defer closeWriter()
+	assert.NoError(t, writer.StoreDirMetadata(&IncrementalDirMetadata{Path: "/data", Usage: 10}))
+
+	reader := NewIncrementalStorage(storagePath, "/data")
+	closeReader, err := reader.OpenReadOnly()
+	assert.NoError(t, err)
+
+	meta, err := reader.LoadDirMetadata("/data")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), meta.Usage)
+	assert.Error(t, reader.StoreDirMetadata(&IncrementalDirMetadata{Path: "/data/a"}))
+
+	// writes done after the snapshot are not visible to the reader
+	assert.NoError(t, writer.StoreDirMetadata(&IncrementalDirMetadata{Path: "/data/b", Usage: 5}))
+	_, err = reader.LoadDirMetadata("/data/b")
+	assert.Error(t, err)
+
+	snapshots, err := filepath.Glob(filepath.Join(os.TempDir(), "gdu-cache-snapshot-*"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, snapshots)
+
+	closeReader()
+	for _, dir := range snapshots {
+		_, err := os.Stat(dir)
+		assert.True(t, os.IsNotExist(err), "Snapshot %s is removed", dir)
+	}
+
+	meta, err = writer.LoadDirMetadata("/data/b")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), meta.Usage)
+}
+
+func TestOpenReadOnlyNoCache(t *testing.T) {
+	_, err := NewIncrementalStorage(t.TempDir(), "/data").OpenReadOnly()
+	assert.Error(t, err)
+	_, err = NewIncrementalStorage(filepath.Join(t.TempDir(), "missing"), "/data").OpenReadOnly()
+	assert.Error(t, err)
+}
+
+func TestOpenReadOnlyDuringScan(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "root")
+	testdir.CreateSyntheticTree(testdir.TreeSpec{
+		Root: root, Depth: 4, Breadth: 5, FilesPerDir: 3, FileSize: 100,
+		Mtime: time.Now().Add(-time.Hour),
+	})
+	storagePath := t.TempDir()
+
+	scan := func() int64 {
+		analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath, ForceFullScan: true})
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+		analyzer.GetDone().Wait()
+		return dir.GetSize()
+	}
+	size := scan()
+
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			scan()
+		}
+	}()
+
+	reads := 0
+	for running := true; running; reads++ {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+
+		storage := NewIncrementalStorage(storagePath, root)
+		closeFn, err := storage.OpenReadOnly()
+		if !assert.NoError(t, err) {
+			break
+		}
+		meta, err := storage.LoadDirMetadata(root)
+		assert.NoError(t, err)
+		if meta != nil {
+			assert.Equal(t, size, meta.Size)
+		}
+		closeFn()
+	}
+	wg.Wait()
+	assert.Greater(t, reads, 0)
+}
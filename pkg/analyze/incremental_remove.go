@@ -0,0 +1,17 @@
+package analyze
+
+import (
+	"path/filepath"
+)
+
+// InvalidateRemoved drops cache entries of the removed (or partially removed) item
+// and of the directory it was removed from, so both are scanned again by the next scan.
+// It returns ErrBusy while a scan is running
+func (a *IncrementalAnalyzer) InvalidateRemoved(path string) error {
+	return a.withStorage(path, func(storage *IncrementalStorage) error {
+		if _, err := storage.DeleteSubtree(path); err != nil {
+			return err
+		}
+		return storage.DeleteDirMetadata(filepath.Dir(path))
+	})
+}
@@ -0,0 +1,124 @@
+package analyze
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RescanStatus describes the state of RescanQueue
+type RescanStatus struct {
+	LastScanEnd time.Time // end of the last finished rescan, zero if there was none
+	DirtyPaths  int       // paths waiting for a rescan
+	Errors      int       // rescans which failed or could not read part of the tree
+}
+
+// RescanQueue collects paths to be rescanned in background by the incremental analyzer
+// and records the outcome of the rescans. It is safe for concurrent use
+type RescanQueue struct {
+	storagePath string
+	m           sync.Mutex
+	dirty       []string
+	queued      map[string]struct{}
+	lastScanEnd time.Time
+	errors      int
+	wake        chan struct{}
+}
+
+// NewRescanQueue returns queue of rescans using the incremental cache at storagePath
+func NewRescanQueue(storagePath string) *RescanQueue {
+	return &RescanQueue{
+		storagePath: storagePath,
+		queued:      make(map[string]struct{}),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Enqueue adds path to the queue, it returns false if the path is already waiting
+func (q *RescanQueue) Enqueue(path string) bool {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	if _, ok := q.queued[path]; ok {
+		return false
+	}
+	q.queued[path] = struct{}{}
+	q.dirty = append(q.dirty, path)
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// Status returns the current state of the queue
+func (q *RescanQueue) Status() RescanStatus {
+	q.m.Lock()
+	defer q.m.Unlock()
+	return RescanStatus{
+		LastScanEnd: q.lastScanEnd,
+		DirtyPaths:  len(q.dirty),
+		Errors:      q.errors,
+	}
+}
+
+// Run rescans the queued paths one by one until ctx is done
+func (q *RescanQueue) Run(ctx context.Context) {
+	for {
+		path, ok := q.next()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.wake:
+				continue
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		q.finish(path, q.rescan(path))
+	}
+}
+
+// next returns the first queued path, it stays queued until the rescan finishes
+func (q *RescanQueue) next() (string, bool) {
+	q.m.Lock()
+	defer q.m.Unlock()
+	if len(q.dirty) == 0 {
+		return "", false
+	}
+	return q.dirty[0], true
+}
+
+// finish removes the rescanned path from the queue and records the outcome
+func (q *RescanQueue) finish(path string, result *ScanResult) {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	q.dirty = q.dirty[1:]
+	delete(q.queued, path)
+	q.lastScanEnd = time.Now()
+	if result == nil || result.Status != ScanCompleted {
+		q.errors++
+	}
+}
+
+// rescan reads the whole subtree of path again and updates its cache entries
+func (q *RescanQueue) rescan(path string) *ScanResult {
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{
+		StoragePath:   q.storagePath,
+		ForceFullScan: true,
+	})
+	analyzer.AnalyzeDir(path, func(_, _ string) bool { return false }, true)
+	analyzer.GetDone().Wait()
+
+	result := analyzer.GetScanResult()
+	if result != nil && result.Status != ScanCompleted {
+		log.Printf("Rescan of %s %s: %v", path, result.Status, result.Err)
+	}
+	return result
+}
@@ -0,0 +1,38 @@
+package analyze
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRescanQueue(t *testing.T) {
+	root := createTraceFixture(t)
+	queue := NewRescanQueue(t.TempDir())
+
+	assert.True(t, queue.Enqueue(root))
+	assert.False(t, queue.Enqueue(root))
+	assert.True(t, queue.Enqueue(filepath.Join(root, "missing")))
+	assert.Equal(t, RescanStatus{DirtyPaths: 2}, queue.Status())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		queue.Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return queue.Status().DirtyPaths == 0
+	}, 10*time.Second, 10*time.Millisecond)
+
+	status := queue.Status()
+	assert.False(t, status.LastScanEnd.IsZero())
+	assert.Equal(t, 1, status.Errors, "The missing directory could not be read")
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,83 @@
+package analyze
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// ScanStatus describes how a scan has finished
+type ScanStatus int
+
+const (
+	// ScanCompleted means the whole tree was read without errors
+	ScanCompleted ScanStatus = iota
+	// ScanCompletedWithErrors means the scan finished but some items could not be read
+	ScanCompletedWithErrors
+	// ScanCancelled means the scan was stopped before it could finish
+	ScanCancelled
+	// ScanFailed means no usable result was produced (cache init failed, root unreadable)
+	ScanFailed
+)
+
+// String returns human readable name of the status
+func (s ScanStatus) String() string {
+	switch s {
+	case ScanCompleted:
+		return "completed"
+	case ScanCompletedWithErrors:
+		return "completed with errors"
+	case ScanCancelled:
+		return "cancelled"
+	case ScanFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// ScanResult is the outcome of a finished scan
+type ScanResult struct {
+	Status      ScanStatus
+	Err         error       // cause of the failure, set only for ScanFailed
+	ErrorCount  int         // number of read errors in the scanned tree
+	Stats       *CacheStats // snapshot of cache statistics at the end of the scan
+	SingleFile  bool        // the scanned path is not a directory, the tree holds just the file
+	RootIgnored bool        // the scanned directory matches the ignore function, its content was not read
+	Generation  uint64      // number of the scans of the directory recorded in the cache, 0 if not recorded
+	Size        int64       // apparent size of the scanned tree
+	Usage       int64       // disk usage of the scanned tree
+	Label       string      // label of the scan (see IncrementalOptions.ScanLabel)
+
+	// CacheErrors is the number of failed reads and writes of the cache and of entries removed
+	// as corrupted. The tree is complete regardless, but the cache is degraded
+	CacheErrors int64
+
+	// HookErr is the error returned by IncrementalOptions.PostScanHook.
+	// The scan is valid regardless
+	HookErr error
+}
+
+// ScanPanicError is the error of the scan result when the scan panicked
+type ScanPanicError struct {
+	Value interface{} // value passed to panic
+	Stack []byte      // stack of the panicking goroutine
+}
+
+func (e *ScanPanicError) Error() string {
+	return fmt.Sprintf("scan panicked: %v", e.Value)
+}
+
+// failedDir returns empty directory standing for the scanned directory at path
+// when the scan failed
+func failedDir(path string) *Dir {
+	return &Dir{
+		File: &File{
+			Name: filepath.Base(path),
+			Flag: '!',
+		},
+		BasePath: filepath.Dir(path),
+		Files:    make(fs.Files, 0),
+	}
+}
@@ -0,0 +1,116 @@
+package analyze
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// PruneResult is the result of PruneExpired
+type PruneResult struct {
+	Entries   int // directory entries removed together with their pages
+	Summaries int // summaries of scanned top directories removed
+}
+
+// PruneExpired removes entries of directories cached before olderThan and summaries of scans
+// finished before it, e.g. of deleted or moved trees which are never scanned again.
+// Entries of the directories keep and of their descendants are kept regardless, as a scan of them
+// does not rewrite the entries of unchanged directories. An empty path protects nothing.
+// Other namespaces (annotations, markers) are not touched and entries which can't be decoded
+// are left to CheckIntegrity. It waits for running operations of the storage to finish
+// and returns ErrBusy if a scan using the storage is running
+func (s *IncrementalStorage) PruneExpired(olderThan time.Time, keep ...string) (*PruneResult, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if err := s.checkClearable(); err != nil {
+		return nil, err
+	}
+
+	kept := func(path string) bool {
+		for _, dir := range keep {
+			if dir != "" && inSubtree(path, dir) {
+				return true
+			}
+		}
+		return false
+	}
+
+	result := &PruneResult{}
+	keys := make([][]byte, 0)
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(KeyPrefixDirMetadata)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			path := string(it.Item().Key()[len(prefix):])
+			if kept(path) {
+				continue
+			}
+			var meta *IncrementalDirMetadata
+			err := it.Item().Value(func(val []byte) error {
+				var err error
+				meta, err = decodeDirMetadata(path, val)
+				return err
+			})
+			if err != nil || !meta.CachedAt.Before(olderThan) {
+				continue
+			}
+			keys = append(keys, it.Item().KeyCopy(nil))
+			if meta.Pages > 0 {
+				keys = append(keys, allPageKeys(txn, path)...)
+			}
+			result.Entries++
+		}
+
+		prefix = []byte(KeyPrefixRootSummary)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			summary := &RootSummary{}
+			err := it.Item().Value(func(val []byte) error {
+				return gob.NewDecoder(bytes.NewBuffer(val)).Decode(summary)
+			})
+			if err != nil || kept(summary.Path) || !summary.FinishedAt.Before(olderThan) {
+				continue
+			}
+			keys = append(keys, it.Item().KeyCopy(nil))
+			result.Summaries++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.writeDeletes(keys); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// pruneExpired removes entries not written for the retention period after the scan of roots
+// started at startTime. It runs once after all the roots are scanned, as the entries of their
+// unchanged directories are not rewritten by the scan. The scanned trees are kept even if the scan
+// took longer than the retention and so are the entries written by the scan outside of them
+// (e.g. of bind mounted directories)
+func (a *IncrementalAnalyzer) pruneExpired(roots []string, startTime time.Time) {
+	olderThan := time.Now().Add(-a.retention)
+	if startTime.Before(olderThan) {
+		olderThan = startTime
+	}
+
+	result, err := a.storage.PruneExpired(olderThan, roots...)
+	if err != nil {
+		a.stats.IncrementCacheErrors()
+		log.Printf("Warning: Failed to prune cache entries older than %s: %v", a.retention, err)
+		return
+	}
+	a.stats.AddPruned(result.Entries, result.Summaries)
+	if result.Entries > 0 || result.Summaries > 0 {
+		log.Printf("Pruned %d cache entries and %d scan summaries older than %s",
+			result.Entries, result.Summaries, a.retention)
+	}
+}
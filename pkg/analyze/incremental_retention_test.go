@@ -0,0 +1,147 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalStorage_PruneExpired(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "")
+	storage.pageSize = 10
+	mustOpen(t, storage)
+
+	old := time.Now().Add(-48 * time.Hour)
+	store := func(path string, children int, cachedAt time.Time) {
+		meta := largeDirMetadata(path, children)
+		meta.CachedAt = cachedAt
+		assert.NoError(t, storage.StoreDirMetadata(meta))
+	}
+	store("/old", 25, old)
+	store("/old/sub", 1, old)
+	store("/fresh", 1, time.Now())
+	store("/keep", 1, old)
+	store("/keep/sub", 25, old)
+	for _, path := range []string{"/old", "/keep"} {
+		assert.NoError(t, storage.StoreRootSummary(&RootSummary{Path: path, FinishedAt: old}))
+	}
+	assert.NoError(t, storage.StoreRootSummary(&RootSummary{Path: "/fresh", FinishedAt: time.Now()}))
+
+	result, err := storage.PruneExpired(time.Now().Add(-24*time.Hour), "/keep")
+	assert.NoError(t, err)
+	assert.Equal(t, &PruneResult{Entries: 2, Summaries: 1}, result)
+
+	for _, path := range []string{"/old", "/old/sub"} {
+		_, err := storage.LoadDirMetadata(path)
+		assert.Error(t, err, path)
+	}
+	for _, path := range []string{"/fresh", "/keep", "/keep/sub"} {
+		_, err := storage.LoadDirMetadata(path)
+		assert.NoError(t, err, path)
+	}
+	// pages of the removed entry are removed with it
+	assert.Equal(t, []string{
+		"page:/keep/sub#000001", "page:/keep/sub#000002", "page:/keep/sub#000003",
+	}, pageKeys(t, storage))
+	summary, err := storage.LoadRootSummary("/old")
+	assert.NoError(t, err)
+	assert.Nil(t, summary)
+
+	// nothing is protected without keep
+	result, err = storage.PruneExpired(time.Now().Add(-24*time.Hour), "")
+	assert.NoError(t, err)
+	assert.Equal(t, &PruneResult{Entries: 2, Summaries: 1}, result)
+
+	assert.NoError(t, storage.MarkScanStarted())
+	_, err = storage.PruneExpired(time.Now(), "")
+	assert.ErrorIs(t, err, ErrBusy)
+}
+
+func TestIncrementalAnalyzer_CacheRetention(t *testing.T) {
+	tmp := t.TempDir()
+	scanned := createTraceFixture(t)
+	gone := filepath.Join(tmp, "gone")
+	assert.NoError(t, os.MkdirAll(filepath.Join(gone, "sub"), 0o755))
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	scan := func(root string, opts IncrementalOptions) *CacheStats {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+		analyzer.GetDone().Wait()
+		return analyzer.GetScanResult().Stats
+	}
+	scan(scanned, opts)
+	scan(gone, opts)
+	assert.NoError(t, os.RemoveAll(gone))
+
+	// neither tree was written for two days, the unchanged entries
+	// of the scanned one are not rewritten by the warm scan
+	old := time.Now().Add(-48 * time.Hour)
+	for _, path := range []string{scanned, filepath.Join(scanned, "a"), gone, filepath.Join(gone, "sub")} {
+		setCachedAt(t, opts.StoragePath, "", path, old)
+	}
+
+	opts.CacheRetention = 24 * time.Hour
+	stats := scan(scanned, opts)
+	assert.Equal(t, int64(2), stats.PrunedEntries)
+	assert.Equal(t, int64(0), stats.PrunedSummaries, "the summary of the gone tree is fresh")
+	assert.Equal(t, int64(0), stats.DirsRescanned)
+
+	storage := NewIncrementalStorage(opts.StoragePath, "")
+	closeFn := mustOpen(t, storage)
+	for _, path := range []string{gone, filepath.Join(gone, "sub")} {
+		_, err := storage.LoadDirMetadata(path)
+		assert.Error(t, err, path)
+	}
+	_, err := storage.LoadDirMetadata(filepath.Join(scanned, "a"))
+	assert.NoError(t, err)
+	closeFn()
+
+	// the entries written by the scan are kept even if it takes longer than the retention
+	fresh := createTraceFixture(t)
+	opts.CacheRetention = time.Nanosecond
+	scan(fresh, opts)
+	storage = NewIncrementalStorage(opts.StoragePath, "")
+	mustOpen(t, storage)
+	for _, path := range []string{fresh, filepath.Join(fresh, "a", "b")} {
+		_, err := storage.LoadDirMetadata(path)
+		assert.NoError(t, err, path)
+	}
+	_, err = storage.LoadDirMetadata(scanned)
+	assert.Error(t, err, "the other tree was not written since")
+}
+
+func TestIncrementalAnalyzer_CacheRetentionRoots(t *testing.T) {
+	first := createTraceFixture(t)
+	second := createInvalidationTree(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+	noIgnore := func(_, _ string) bool { return false }
+
+	analyzer := CreateIncrementalAnalyzer(opts)
+	analyzer.AnalyzeDirs([]string{first, second}, noIgnore, false)
+	analyzer.GetDone().Wait()
+
+	// the warm scan rewrites no entries of either root
+	old := time.Now().Add(-48 * time.Hour)
+	for _, path := range []string{first, filepath.Join(first, "a"), second, filepath.Join(second, "x", "y")} {
+		setCachedAt(t, opts.StoragePath, "", path, old)
+	}
+
+	opts.CacheRetention = 24 * time.Hour
+	analyzer = CreateIncrementalAnalyzer(opts)
+	analyzer.AnalyzeDirs([]string{first, second}, noIgnore, false)
+	analyzer.GetDone().Wait()
+	stats := analyzer.GetScanResult().Stats
+	assert.Equal(t, int64(0), stats.PrunedEntries, "the entries of the other root are kept")
+	assert.Equal(t, int64(0), stats.DirsRescanned)
+
+	storage := NewIncrementalStorage(opts.StoragePath, "")
+	mustOpen(t, storage)
+	for _, path := range []string{first, filepath.Join(first, "a"), second, filepath.Join(second, "x", "y")} {
+		_, err := storage.LoadDirMetadata(path)
+		assert.NoError(t, err, path)
+	}
+}
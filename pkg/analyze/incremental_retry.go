@@ -0,0 +1,78 @@
+package analyze
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultRetryDelay is the delay before the first retry when IncrementalOptions.RetryDelay is not set
+const DefaultRetryDelay = 100 * time.Millisecond
+
+// transientErrors are the errno classes which network filesystems (NFS, SMB) return
+// for reads which succeed when repeated a moment later
+var transientErrors = []error{
+	syscall.EIO,
+	syscall.ESTALE,
+	syscall.EAGAIN,
+	syscall.EINTR,
+	syscall.ETIMEDOUT,
+}
+
+// isTransientErr returns true if the read failing with err is worth retrying
+func isTransientErr(err error) bool {
+	for _, transient := range transientErrors {
+		if errors.Is(err, transient) {
+			return true
+		}
+	}
+	return false
+}
+
+// retry calls read until it succeeds, fails with an error which is not transient
+// or the retries (IncrementalOptions.RetryCount) are exhausted, the error of the last call is returned.
+// The delay before the retries doubles with every one of them.
+// A stale file handle (ESTALE) of the path is dropped by a fresh stat of its parent before the retry
+func (a *IncrementalAnalyzer) retry(path string, read func() error) error {
+	err := read()
+	delay := a.retryDelay
+	for i := 0; i < a.retryCount && err != nil && isTransientErr(err); i++ {
+		log.Debugf("Retrying read of %s in %v: %v", path, delay, err)
+		a.stats.IncrementRetries()
+
+		select {
+		case <-a.ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+
+		if errors.Is(err, syscall.ESTALE) {
+			_, _ = a.statPath(filepath.Dir(path))
+		}
+		err = read()
+	}
+	return err
+}
+
+// statRetried returns stat of the path, transient errors are retried
+func (a *IncrementalAnalyzer) statRetried(path string) (stat os.FileInfo, err error) {
+	err = a.retry(path, func() error {
+		stat, err = a.statPath(path)
+		return err
+	})
+	return stat, err
+}
+
+// readDirRetried returns entries of the directory, transient errors are retried
+func (a *IncrementalAnalyzer) readDirRetried(path string) (files []os.DirEntry, err error) {
+	err = a.retry(path, func() error {
+		files, err = a.listDir(path)
+		return err
+	})
+	return files, err
+}
@@ -0,0 +1,125 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/dundee/gdu/v5/internal/testdir"
+	"github.com/stretchr/testify/assert"
+)
+
+// failFirst makes the first n reads of path fail with errno
+func failFirst(path string, n int, errno syscall.Errno) func(string) error {
+	return func(p string) error {
+		if p != path || n == 0 {
+			return nil
+		}
+		n--
+		return &os.PathError{Op: "read", Path: p, Err: errno}
+	}
+}
+
+// flakyAnalyzer returns incremental analyzer whose directory reads and stats fail as set by the arguments
+func flakyAnalyzer(opts IncrementalOptions, failList, failStat func(string) error) *IncrementalAnalyzer {
+	analyzer := CreateIncrementalAnalyzer(opts)
+	listDir, statPath := analyzer.listDir, analyzer.statPath
+	analyzer.listDir = func(path string) ([]os.DirEntry, error) {
+		if err := failList(path); err != nil {
+			return nil, err
+		}
+		return listDir(path)
+	}
+	analyzer.statPath = func(path string) (os.FileInfo, error) {
+		if err := failStat(path); err != nil {
+			return nil, err
+		}
+		return statPath(path)
+	}
+	return analyzer
+}
+
+func TestIncrementalAnalyzer_RetryTransientErrors(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	nested := absTestPath(t, "test_dir", "nested")
+	subnested := filepath.Join(nested, "subnested")
+	parentStats := 0
+
+	opts := IncrementalOptions{StoragePath: t.TempDir(), RetryCount: 3, RetryDelay: time.Millisecond}
+	staleStat := failFirst(subnested, 1, syscall.ESTALE)
+	analyzer := flakyAnalyzer(opts, failFirst(nested, 2, syscall.EIO), func(path string) error {
+		if path == nested {
+			parentStats++
+		}
+		return staleStat(path)
+	})
+
+	dir := analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	assert.Equal(t, ' ', dir.GetFlag())
+	assert.Zero(t, dir.ErrorCount)
+	assert.Equal(t, 5, dir.ItemCount)
+	assert.Equal(t, ' ', childByName(dir, "nested").GetFlag())
+	assert.Equal(t, int64(3), analyzer.GetCacheStats().Retries)
+
+	// the stale handle of subnested was dropped by stat of its parent
+	// (besides the stats of nested itself when it was processed and scanned)
+	assert.Equal(t, 3, parentStats)
+
+	// the clean result was cached
+	warm := CreateIncrementalAnalyzer(opts)
+	cached := warm.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	warm.GetDone().Wait()
+	assert.Equal(t, int64(1), warm.GetCacheStats().CacheHits)
+	assert.Zero(t, cached.ErrorCount)
+}
+
+func TestIncrementalAnalyzer_RetriesExhausted(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	nested := absTestPath(t, "test_dir", "nested")
+	opts := IncrementalOptions{StoragePath: t.TempDir(), RetryCount: 2, RetryDelay: time.Millisecond}
+	analyzer := flakyAnalyzer(opts, failFirst(nested, 3, syscall.EIO), failFirst("", 0, 0))
+
+	dir := analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	assert.Equal(t, '!', childByName(dir, "nested").GetFlag())
+	assert.Equal(t, 1, dir.ErrorCount)
+	assert.Equal(t, int64(2), analyzer.GetCacheStats().Retries)
+}
+
+func TestIncrementalAnalyzer_PermanentErrorsNotRetried(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	nested := absTestPath(t, "test_dir", "nested")
+	opts := IncrementalOptions{StoragePath: t.TempDir(), RetryCount: 3, RetryDelay: time.Millisecond}
+	analyzer := flakyAnalyzer(opts, failFirst(nested, 1, syscall.EACCES), failFirst("", 0, 0))
+
+	dir := analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	assert.Equal(t, '!', childByName(dir, "nested").GetFlag())
+	assert.Zero(t, analyzer.GetCacheStats().Retries)
+}
+
+func TestIncrementalAnalyzer_RetryDisabledByDefault(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	nested := absTestPath(t, "test_dir", "nested")
+	analyzer := flakyAnalyzer(IncrementalOptions{StoragePath: t.TempDir()}, failFirst(nested, 1, syscall.EIO), failFirst("", 0, 0))
+
+	dir := analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	assert.Equal(t, '!', childByName(dir, "nested").GetFlag())
+	assert.Zero(t, analyzer.GetCacheStats().Retries)
+}
@@ -0,0 +1,118 @@
+package analyze
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dundee/gdu/v5/internal/common"
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// RootsDirName is the name of the directory returned by AnalyzeDirs
+const RootsDirName = "<roots>"
+
+// AnalyzeDirs analyzes given paths in one scan sharing the cache, which is opened only once.
+// It returns a directory named RootsDirName whose children are the trees of the paths
+// in the given order, their paths stay the same as if they were scanned by AnalyzeDir.
+// The progress, the cache statistics and GetScanResult cover all the paths, the result
+// has the worst status of the paths. The done signal group is broadcast once all the paths
+// are scanned. A path failing to scan gets the '!' flag, the other ones are scanned regardless
+func (a *IncrementalAnalyzer) AnalyzeDirs(
+	paths []string, ignore common.ShouldDirBeIgnored, constGC bool,
+) fs.Item {
+	roots := make([]string, len(paths))
+	for i, path := range paths {
+		roots[i] = path
+		if abs, err := filepath.Abs(path); err == nil {
+			roots[i] = abs
+		}
+	}
+
+	return a.runScan(strings.Join(roots, string(filepath.ListSeparator)), constGC,
+		func(startTime time.Time, finish func(*ScanResult)) fs.Item {
+			top := &Dir{
+				File:  &File{Name: RootsDirName, Flag: ' '},
+				Files: make(fs.Files, 0, len(roots)),
+			}
+
+			// the keys hold full paths, so the roots share the storage;
+			// the crashed scan may have been of any of them, so the whole cache is checked
+			a.storage = NewIncrementalStorage(a.storagePath, "")
+			a.storage.SetStorageOptions(a.storageOpts)
+			a.storage.forward = a.events
+			closeFn, err := a.openStorage()
+			if err != nil {
+				for _, path := range roots {
+					addRoot(top, failedDir(path))
+				}
+				finish(&ScanResult{Status: ScanFailed, Err: err})
+				return top
+			}
+			defer closeFn()
+			defer a.beginScan()()
+
+			results := make([]*ScanResult, 0, len(roots))
+			for i, path := range roots {
+				rootIgnore := ignore
+				if ignore != nil {
+					rootIgnore = ignoringAsGiven(ignore, path, paths[i])
+				}
+				dir, result := a.unscannedRoot(path, rootIgnore)
+				if dir == nil {
+					a.storage.topDir = path
+					dir, result = a.scanRoot(path, rootIgnore, startTime)
+				}
+				addRoot(top, dir)
+				results = append(results, result)
+			}
+			a.storage.topDir = ""
+			if a.retention > 0 {
+				a.pruneExpired(roots, startTime)
+			}
+
+			a.stats.ScanEndTime = time.Now()
+			a.stats.TotalScanTime = a.stats.ScanEndTime.Sub(startTime)
+			finish(combineResults(results, a.stats.Snapshot()))
+			return top
+		})
+}
+
+// addRoot adds the tree of a root to the directory returned by AnalyzeDirs
+func addRoot(top, dir *Dir) {
+	dir.Parent = top
+	top.AddFile(dir)
+	top.ItemCount += dir.ItemCount
+	top.Size += dir.Size
+	top.Usage += dir.Usage
+	top.ErrorCount += dir.ErrorCount
+	if dir.Mtime.After(top.Mtime) {
+		top.Mtime = dir.Mtime
+	}
+}
+
+// combineResults returns the result of the scan of several roots,
+// stats are statistics of the whole scan
+func combineResults(results []*ScanResult, stats *CacheStats) *ScanResult {
+	combined := &ScanResult{
+		Status:      ScanCompleted,
+		CacheErrors: stats.CacheErrors + stats.CorruptedEntries,
+	}
+	errs := make([]error, 0)
+	for _, result := range results {
+		combined.Size += result.Size
+		combined.Usage += result.Usage
+		combined.ErrorCount += result.ErrorCount
+		if result.Status > combined.Status {
+			combined.Status = result.Status
+		}
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+	}
+	if combined.Status == ScanFailed {
+		combined.Err = errors.Join(errs...)
+	}
+	return combined
+}
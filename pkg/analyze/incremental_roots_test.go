@@ -0,0 +1,112 @@
+package analyze
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalAnalyzer_AnalyzeDirs(t *testing.T) {
+	first := createInvalidationTree(t)
+	second := createInvalidationTree(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+	noIgnore := func(_, _ string) bool { return false }
+
+	analyzer := CreateIncrementalAnalyzer(opts)
+	top := analyzer.AnalyzeDirs([]string{first, second}, noIgnore, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	assert.Equal(t, RootsDirName, top.GetName())
+	assert.Len(t, top.Files, 2)
+	assert.Equal(t, first, top.Files[0].GetPath())
+	assert.Equal(t, second, top.Files[1].GetPath())
+	assert.Equal(t, top, top.Files[0].GetParent())
+	assert.Equal(t, top.Files[0].GetSize()+top.Files[1].GetSize(), top.Size)
+	assert.Equal(t, top.Files[0].GetItemCount()+top.Files[1].GetItemCount(), top.ItemCount)
+
+	// the progress covers both roots
+	assert.Equal(t, top.ItemCount, analyzer.CurrentProgress().ItemCount)
+	assert.Equal(t, top.Size, analyzer.CurrentProgress().TotalSize)
+
+	result := analyzer.GetScanResult()
+	assert.Equal(t, ScanCompleted, result.Status)
+	assert.Equal(t, top.Size, result.Size)
+	assert.Equal(t, int64(12), result.Stats.TotalDirs)
+
+	// the cache of each root is the same as if it was scanned alone
+	analyzer = CreateIncrementalAnalyzer(opts)
+	dir := analyzer.AnalyzeDir(second, noIgnore, false).(*Dir)
+	analyzer.GetDone().Wait()
+	assert.Equal(t, top.Files[1].GetSize(), dir.Size)
+	assert.Equal(t, int64(1), analyzer.GetCacheStats().CacheHits)
+	assert.Zero(t, analyzer.GetCacheStats().DirsRescanned)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(first, "new"), make([]byte, 100), 0o600))
+	analyzer = CreateIncrementalAnalyzer(opts)
+	again := analyzer.AnalyzeDirs([]string{first, second}, noIgnore, false).(*Dir)
+	analyzer.GetDone().Wait()
+	assert.Equal(t, top.Size+100, again.Size)
+	stats := analyzer.GetScanResult().Stats
+	assert.Equal(t, int64(1), stats.DirsRescanned, "only the top directory of the first root changed")
+	assert.Equal(t, int64(3), stats.CacheHits, "a and x of the first root and the second root")
+}
+
+func TestIncrementalAnalyzer_AnalyzeDirsFailedRoot(t *testing.T) {
+	root := createInvalidationTree(t)
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	top := analyzer.AnalyzeDirs([]string{missing, root}, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	assert.Len(t, top.Files, 2)
+	assert.Equal(t, '!', top.Files[0].GetFlag())
+	assert.Equal(t, ' ', top.Files[1].GetFlag(), "the other roots are scanned regardless")
+	assert.Equal(t, top.Files[1].GetSize(), top.Size)
+
+	result := analyzer.GetScanResult()
+	assert.Equal(t, ScanFailed, result.Status)
+	assert.ErrorContains(t, result.Err, missing)
+}
+
+func TestIncrementalAnalyzer_AnalyzeDirsStorageFailure(t *testing.T) {
+	root := createInvalidationTree(t)
+	storagePath := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(storagePath, []byte("not a directory"), 0o600))
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath})
+	top := analyzer.AnalyzeDirs([]string{root}, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	assert.Len(t, top.Files, 1)
+	assert.Equal(t, '!', top.Files[0].GetFlag())
+	assert.Equal(t, ScanFailed, analyzer.GetScanResult().Status)
+}
+
+func TestCombineResults(t *testing.T) {
+	errFirst, errSecond := errors.New("first"), errors.New("second")
+	stats := &CacheStats{CacheErrors: 1, CorruptedEntries: 2}
+
+	result := combineResults([]*ScanResult{
+		{Status: ScanCompleted, Size: 1, Usage: 10},
+		{Status: ScanCompletedWithErrors, Size: 2, Usage: 20, ErrorCount: 3},
+	}, stats)
+	assert.Equal(t, ScanCompletedWithErrors, result.Status)
+	assert.Equal(t, int64(3), result.Size)
+	assert.Equal(t, int64(30), result.Usage)
+	assert.Equal(t, 3, result.ErrorCount)
+	assert.Equal(t, int64(3), result.CacheErrors)
+	assert.NoError(t, result.Err)
+
+	result = combineResults([]*ScanResult{
+		{Status: ScanFailed, Err: errFirst},
+		{Status: ScanCancelled},
+		{Status: ScanFailed, Err: errSecond},
+	}, stats)
+	assert.Equal(t, ScanFailed, result.Status)
+	assert.ErrorIs(t, result.Err, errFirst)
+	assert.ErrorIs(t, result.Err, errSecond)
+}
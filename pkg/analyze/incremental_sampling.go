@@ -0,0 +1,36 @@
+package analyze
+
+import (
+	"math/rand/v2"
+	"os"
+)
+
+// DefaultSampleSize is the number of files read in sampled directories
+// when IncrementalOptions.SampleSize is not set
+const DefaultSampleSize = 100
+
+// skippedFiles returns names of the files which are not read when the directory
+// has more files than the sample threshold, nil if all files are read.
+// Subdirectories and excluded files are never skipped
+func (a *IncrementalAnalyzer) skippedFiles(entries []os.DirEntry) map[string]struct{} {
+	if a.sampleAbove <= 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && !a.isExcludedFile(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) <= a.sampleAbove {
+		return nil
+	}
+
+	rand.Shuffle(len(names), func(i, j int) { names[i], names[j] = names[j], names[i] })
+	skipped := make(map[string]struct{}, len(names)-a.sampleSize)
+	for _, name := range names[a.sampleSize:] {
+		skipped[name] = struct{}{}
+	}
+	return skipped
+}
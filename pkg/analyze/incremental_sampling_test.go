@@ -0,0 +1,127 @@
+package analyze
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+// createSamplingFixture creates root/big with 50 files of the same size and a subdirectory
+// and root/small with 3 files
+func createSamplingFixture(t *testing.T) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "root")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "big", "sub"), 0o755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "small"), 0o755))
+	for i := 0; i < 50; i++ {
+		path := filepath.Join(root, "big", fmt.Sprintf("file%02d", i))
+		assert.NoError(t, os.WriteFile(path, make([]byte, 100), 0o600))
+	}
+	for i := 0; i < 3; i++ {
+		path := filepath.Join(root, "small", fmt.Sprintf("file%d", i))
+		assert.NoError(t, os.WriteFile(path, make([]byte, 10), 0o600))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "big", "sub", "file"), []byte("hello"), 0o600))
+	return root
+}
+
+func analyzeSampled(t *testing.T, root, storagePath string, threshold int) (*Dir, *IncrementalAnalyzer) {
+	t.Helper()
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{
+		StoragePath:     storagePath,
+		SampleThreshold: threshold,
+		SampleSize:      5,
+		TraceDecisions:  true,
+	})
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+	return dir, analyzer
+}
+
+func TestIncrementalAnalyzer_Sampling(t *testing.T) {
+	root := createSamplingFixture(t)
+	storagePath := t.TempDir()
+
+	exact, _ := analyzeSampled(t, root, t.TempDir(), 0)
+	dir, _ := analyzeSampled(t, root, storagePath, 10)
+
+	big := childByName(dir, "big").(*Dir)
+	assert.Equal(t, '~', big.GetFlag())
+	assert.Equal(t, &Estimate{Sampled: 5, Skipped: 45, Size: 4500, Usage: big.Estimate.Usage}, big.Estimate)
+	assert.Len(t, big.Files, 6, "Sampled files and the subdirectory")
+	assert.NotNil(t, childByName(big, "sub"))
+	assert.True(t, big.IsEstimated())
+
+	small := childByName(dir, "small").(*Dir)
+	assert.Nil(t, small.Estimate)
+	assert.False(t, small.IsEstimated())
+	assert.Len(t, small.Files, 3)
+
+	// the files are of the same size, so the estimate is exact
+	assert.Equal(t, ' ', dir.GetFlag())
+	assert.True(t, dir.IsEstimated())
+	assert.Equal(t, 1, dir.EstimatedDirCount)
+	assert.Equal(t, exact.GetSize(), dir.GetSize())
+	assert.Equal(t, exact.GetUsage(), dir.GetUsage())
+	assert.Equal(t, exact.GetItemCount(), dir.GetItemCount())
+
+	// totals are kept by UpdateStats
+	dir.UpdateStats(make(fs.HardLinkedItems))
+	exact.UpdateStats(make(fs.HardLinkedItems))
+	assert.Equal(t, exact.GetSize(), dir.GetSize())
+	assert.Equal(t, exact.GetItemCount(), dir.GetItemCount())
+
+	// warm scan in the estimation mode uses the estimate
+	dir, analyzer := analyzeSampled(t, root, storagePath, 10)
+	assert.Equal(t, int64(1), analyzer.GetCacheStats().CacheHits)
+	big = childByName(dir, "big").(*Dir)
+	assert.Equal(t, '~', big.GetFlag())
+	assert.Equal(t, 45, big.Estimate.Skipped)
+	assert.Equal(t, 1, dir.EstimatedDirCount)
+	assert.Equal(t, exact.GetSize(), dir.GetSize())
+}
+
+func TestIncrementalAnalyzer_ExactScanReplacesEstimate(t *testing.T) {
+	root := createSamplingFixture(t)
+	storagePath := t.TempDir()
+
+	analyzeSampled(t, root, storagePath, 10)
+
+	dir, analyzer := analyzeSampled(t, root, storagePath, 0)
+	decisions := make(map[string]CacheDecision)
+	for _, entry := range analyzer.GetDecisionTrace().Entries() {
+		decisions[entry.Path] = entry.Decision
+	}
+	assert.Equal(t, DecisionHit, decisions[root])
+	assert.Equal(t, DecisionEstimated, decisions[filepath.Join(root, "big")])
+
+	big := childByName(dir, "big").(*Dir)
+	assert.Equal(t, ' ', big.GetFlag())
+	assert.Nil(t, big.Estimate)
+	assert.Len(t, big.Files, 51)
+	assert.False(t, dir.IsEstimated())
+
+	// the exact entry is stored and used by the estimation mode as well
+	dir, analyzer = analyzeSampled(t, root, storagePath, 10)
+	for _, entry := range analyzer.GetDecisionTrace().Entries() {
+		assert.NotEqual(t, DecisionChanged, entry.Decision, entry.Path)
+	}
+	assert.Len(t, childByName(dir, "big").(*Dir).Files, 51)
+	assert.False(t, dir.IsEstimated())
+}
+
+func TestNewEstimate(t *testing.T) {
+	est := newEstimate([]int64{10, 10, 10, 10}, []int64{4096, 4096, 4096, 4096}, 6)
+	assert.Equal(t, &Estimate{Sampled: 4, Skipped: 6, Size: 60, Usage: 6 * 4096}, est)
+
+	est = newEstimate([]int64{0, 100}, []int64{0, 4096}, 2)
+	assert.Equal(t, int64(100), est.Size)
+	assert.Equal(t, int64(4096), est.Usage)
+	assert.InDelta(t, 0.354, est.RelErr, 0.001)
+
+	assert.Equal(t, &Estimate{Skipped: 3}, newEstimate(nil, nil, 3))
+}
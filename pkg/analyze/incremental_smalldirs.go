@@ -0,0 +1,43 @@
+package analyze
+
+import (
+	"errors"
+
+	"github.com/dgraph-io/badger/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// skipsSmallDirs returns true if small directories are not cached,
+// see IncrementalOptions.MinCacheDirSize and MinCacheChildren
+func (a *IncrementalAnalyzer) skipsSmallDirs() bool {
+	return a.minCacheSize > 0 || a.minCacheItems > 0
+}
+
+// skipsCaching returns true if the scanned dir is below all the set thresholds
+// of small directories, so its cache entry is not stored
+func (a *IncrementalAnalyzer) skipsCaching(dir *Dir) bool {
+	if !a.skipsSmallDirs() {
+		return false
+	}
+	if a.minCacheSize > 0 && dir.Size >= a.minCacheSize {
+		return false
+	}
+	if a.minCacheItems > 0 && len(dir.Files) >= a.minCacheItems {
+		return false
+	}
+	return true
+}
+
+// deleteSmallDirEntry removes the entry of the directory at path stored before it got
+// below the thresholds, so it is not used instead of the scan when its parent is loaded
+func (a *IncrementalAnalyzer) deleteSmallDirEntry(path string) {
+	if err := a.storage.DeleteDirMetadata(path); err != nil {
+		a.stats.IncrementCacheErrors()
+		log.Printf("Warning: Failed to remove cache entry of small directory %s: %v", path, err)
+	}
+}
+
+// isNotCached returns true if err of LoadDirMetadata means that there is no entry
+func isNotCached(err error) bool {
+	return errors.Is(err, badger.ErrKeyNotFound)
+}
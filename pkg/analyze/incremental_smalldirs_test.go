@@ -0,0 +1,152 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createSmallDirsTree creates a tree where big, few/deep and the top directory have five children
+// and the other directories less than three
+func createSmallDirsTree(t *testing.T) string {
+	root := t.TempDir()
+	for _, dir := range []string{"big", "tiny1", "tiny2", "tiny3/nested", "few/deep"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o755))
+	}
+	for _, dir := range []string{"big", "few/deep"} {
+		for _, name := range []string{"a", "b", "c", "d", "e"} {
+			content := []byte(strings.Repeat("x", 4000))
+			assert.NoError(t, os.WriteFile(filepath.Join(root, dir, name), content, 0o600))
+		}
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "tiny1", "file"), []byte("x"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "tiny3", "nested", "file"), []byte("x"), 0o600))
+	return root
+}
+
+func TestIncrementalAnalyzer_MinCacheChildren(t *testing.T) {
+	root := createSmallDirsTree(t)
+
+	scan := func(opts IncrementalOptions) (map[string]string, *CacheStats) {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		return flattenTree(dir), analyzer.GetCacheStats()
+	}
+
+	all, _ := scan(IncrementalOptions{StoragePath: t.TempDir()})
+
+	opts := IncrementalOptions{StoragePath: t.TempDir(), MinCacheChildren: 3}
+	cold, stats := scan(opts)
+	assert.Equal(t, all, cold)
+	assert.Equal(t, int64(5), stats.SmallDirsNotCached)
+	assert.Equal(t, int64(3), stats.CacheMisses)
+
+	// the cached larger directory below the small one is loaded from the cache
+	warm, stats := scan(opts)
+	assert.Equal(t, all, warm)
+	assert.Equal(t, int64(5), stats.SmallDirsNotCached)
+	assert.Zero(t, stats.CacheMisses)
+	assert.Equal(t, int64(2), stats.CacheHits)
+	assert.Equal(t, 100.0, stats.HitRate())
+
+	storage := NewIncrementalStorage(opts.StoragePath, "")
+	closeFn := mustOpen(t, storage)
+	defer closeFn()
+	for _, dir := range []string{"", "big", "few/deep"} {
+		_, err := storage.LoadDirMetadata(filepath.Join(root, dir))
+		assert.NoError(t, err, dir)
+	}
+	for _, dir := range []string{"tiny1", "tiny2", "tiny3", "tiny3/nested", "few"} {
+		_, err := storage.LoadDirMetadata(filepath.Join(root, dir))
+		assert.True(t, isNotCached(err), dir)
+	}
+}
+
+func TestIncrementalAnalyzer_MinCacheDirSize(t *testing.T) {
+	root := createSmallDirsTree(t)
+	storagePath := t.TempDir()
+
+	scan := func(opts IncrementalOptions) (map[string]string, *CacheStats) {
+		if opts.StoragePath == "" {
+			opts.StoragePath = storagePath
+		}
+		analyzer := CreateIncrementalAnalyzer(opts)
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		return flattenTree(dir), analyzer.GetCacheStats()
+	}
+
+	// all directories are cached first
+	all, _ := scan(IncrementalOptions{})
+
+	// changed small directory loses its entry
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "tiny1", "other"), []byte("x"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "new"), []byte("x"), 0o600))
+	delete(all, filepath.Join(root, "tiny1"))
+	delete(all, root)
+
+	opts := IncrementalOptions{MinCacheDirSize: 10000}
+	changed, stats := scan(opts)
+	assert.Equal(t, int64(1), stats.SmallDirsNotCached)
+	assert.Zero(t, stats.CacheMisses)
+	for path, item := range all {
+		assert.Equal(t, item, changed[path], path)
+	}
+
+	full, _ := scan(IncrementalOptions{StoragePath: t.TempDir()})
+	warm, stats := scan(opts)
+	assert.Equal(t, full, warm)
+	assert.Equal(t, int64(1), stats.SmallDirsNotCached)
+	assert.Zero(t, stats.CacheMisses)
+
+	storage := NewIncrementalStorage(storagePath, "")
+	closeFn := mustOpen(t, storage)
+	defer closeFn()
+	_, err := storage.LoadDirMetadata(filepath.Join(root, "tiny1"))
+	assert.True(t, isNotCached(err))
+	_, err = storage.LoadDirMetadata(filepath.Join(root, "tiny2"))
+	assert.NoError(t, err, "unchanged directories keep their entries")
+}
+
+// benchmarkSmallDirsCache measures warm scans of about 11k directories
+// and reports the number of cache entries
+func benchmarkSmallDirsCache(b *testing.B, minChildren int) {
+	root := b.TempDir()
+	createWideTree(b, root, 10, 4)
+	opts := IncrementalOptions{StoragePath: b.TempDir(), MinCacheChildren: minChildren}
+
+	analyzer := CreateIncrementalAnalyzer(opts)
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	storage := NewIncrementalStorage(opts.StoragePath, "")
+	closeFn, err := storage.Open()
+	assert.NoError(b, err)
+	entries := 0
+	assert.NoError(b, storage.Iterate(KeyPrefixDirMetadata, func(_, _ []byte) error {
+		entries++
+		return nil
+	}))
+	closeFn()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+		analyzer.GetDone().Wait()
+	}
+	b.ReportMetric(float64(entries), "entries")
+}
+
+func BenchmarkWarmScanAllDirsCached(b *testing.B) {
+	benchmarkSmallDirsCache(b, 0)
+}
+
+// leaf directories holding only one file are not cached
+func BenchmarkWarmScanSmallDirsSkipped(b *testing.B) {
+	benchmarkSmallDirsCache(b, 2)
+}
@@ -0,0 +1,79 @@
+package analyze
+
+import (
+	"sync"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// SnapshotItem holds totals of a top-level item completed during the running scan
+type SnapshotItem struct {
+	Name      string
+	IsDir     bool
+	Size      int64
+	Usage     int64
+	ItemCount int
+}
+
+// ScanSnapshot holds the top-level items of the scanned directory completed so far.
+// It is a copy, it does not change when the scan continues
+type ScanSnapshot struct {
+	Path  string
+	Items []SnapshotItem
+}
+
+// scanSnapshot collects top-level items of the running scan, it is safe for concurrent use
+type scanSnapshot struct {
+	m       sync.Mutex
+	path    string
+	items   []SnapshotItem
+	running bool
+}
+
+// start begins collecting items of the scan of path
+func (s *scanSnapshot) start(path string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.path = path
+	s.items = nil
+	s.running = true
+}
+
+// stop drops the collected items, the full tree replaces them once the scan is done
+func (s *scanSnapshot) stop() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.items = nil
+	s.running = false
+}
+
+// add records the completed item if it is a direct child of the scanned directory
+func (s *scanSnapshot) add(parentPath string, item fs.Item) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if !s.running || parentPath != s.path {
+		return
+	}
+	s.items = append(s.items, SnapshotItem{
+		Name:      item.GetName(),
+		IsDir:     item.IsDir(),
+		Size:      item.GetSize(),
+		Usage:     item.GetUsage(),
+		ItemCount: item.GetItemCount(),
+	})
+}
+
+// GetScanSnapshot returns copy of the top-level items completed by the running scan
+// (name and totals only, not their subtrees). It is safe to call it from another goroutine
+// while the scan runs. False is returned when no scan is running,
+// the tree returned by AnalyzeDir should be used then
+func (a *IncrementalAnalyzer) GetScanSnapshot() (ScanSnapshot, bool) {
+	a.snapshot.m.Lock()
+	defer a.snapshot.m.Unlock()
+	if !a.snapshot.running {
+		return ScanSnapshot{}, false
+	}
+	items := make([]SnapshotItem, len(a.snapshot.items))
+	copy(items, a.snapshot.items)
+	return ScanSnapshot{Path: a.snapshot.path, Items: items}, true
+}
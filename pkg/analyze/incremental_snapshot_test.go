@@ -0,0 +1,74 @@
+package analyze
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalAnalyzer_ScanSnapshot(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "root")
+	for i := 0; i < 5; i++ {
+		sub := filepath.Join(root, fmt.Sprintf("dir%d", i), "nested")
+		assert.NoError(t, os.MkdirAll(sub, 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(sub, "file"), make([]byte, 100*(i+1)), 0o600))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "file"), []byte("hello"), 0o600))
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{
+		StoragePath: t.TempDir(),
+		IODelay:     20 * time.Millisecond,
+	})
+	_, ok := analyzer.GetScanSnapshot()
+	assert.False(t, ok)
+
+	snapshots := make(chan []ScanSnapshot)
+	go func() {
+		var taken []ScanSnapshot
+		done := analyzer.GetDone()
+		for {
+			select {
+			case <-done:
+				snapshots <- taken
+				return
+			case <-time.After(5 * time.Millisecond):
+				if snapshot, ok := analyzer.GetScanSnapshot(); ok {
+					taken = append(taken, snapshot)
+				}
+			}
+		}
+	}()
+
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+	taken := <-snapshots
+
+	_, ok = analyzer.GetScanSnapshot()
+	assert.False(t, ok, "The full tree replaces the snapshot")
+
+	assert.NotEmpty(t, taken)
+	partial := false
+	previous := []SnapshotItem{}
+	for _, snapshot := range taken {
+		assert.Equal(t, root, snapshot.Path)
+		assert.GreaterOrEqual(t, len(snapshot.Items), len(previous))
+		assert.Equal(t, previous, snapshot.Items[:len(previous)], "Completed items do not change")
+		if len(snapshot.Items) > 0 && len(snapshot.Items) < len(dir.Files) {
+			partial = true
+		}
+		previous = snapshot.Items
+	}
+	assert.True(t, partial, "Snapshot taken in the middle of the scan")
+
+	for _, item := range previous {
+		child := childByName(dir, item.Name)
+		assert.Equal(t, child.GetSize(), item.Size)
+		assert.Equal(t, child.GetUsage(), item.Usage)
+		assert.Equal(t, child.GetItemCount(), item.ItemCount)
+		assert.Equal(t, child.IsDir(), item.IsDir)
+	}
+}
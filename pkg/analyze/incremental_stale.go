@@ -0,0 +1,190 @@
+package analyze
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/dgraph-io/badger/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// StaleResult is the result of PruneStale
+type StaleResult struct {
+	Entries int   // directory entries removed together with their pages
+	Bytes   int64 // estimated size of the removed keys and values
+}
+
+// PruneStale removes entries of directories under top (including top itself) which are gone
+// from the filesystem or are no longer directories (e.g. were replaced by a file or a broken symlink).
+// Symlinks to directories are kept, as their entries are read when following them (--follow-dir-symlinks).
+// Entries of directories outside of top are left alone, as they may belong to trees
+// which are not mounted at the moment. Directories which can't be checked for another reason
+// (e.g. permission denied) are kept and entries which can't be decoded are left to CheckIntegrity.
+// It waits for running operations of the storage to finish
+// and returns ErrBusy if a scan using the storage is running
+func (s *IncrementalStorage) PruneStale(top string) (*StaleResult, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if err := s.checkClearable(); err != nil {
+		return nil, err
+	}
+
+	result := &StaleResult{}
+	keys := make([][]byte, 0)
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(KeyPrefixDirMetadata)
+		for it.Seek([]byte(KeyPrefixDirMetadata + top)); it.ValidForPrefix(prefix); it.Next() {
+			path := string(it.Item().Key()[len(prefix):])
+			if !inSubtree(path, top) {
+				// keys of siblings sharing the prefix (e.g. /a-b of /a) are mixed with keys of the subtree
+				if strings.HasPrefix(path, top) {
+					continue
+				}
+				break
+			}
+			if !isStale(path) {
+				continue
+			}
+			var meta *IncrementalDirMetadata
+			err := it.Item().Value(func(val []byte) error {
+				var err error
+				meta, err = decodeDirMetadata(path, val)
+				return err
+			})
+			if err != nil {
+				continue
+			}
+			keys = append(keys, it.Item().KeyCopy(nil))
+			result.Bytes += it.Item().EstimatedSize()
+			if meta.Pages > 0 {
+				for _, key := range allPageKeys(txn, path) {
+					if item, err := txn.Get(key); err == nil {
+						result.Bytes += item.EstimatedSize()
+					}
+					keys = append(keys, key)
+				}
+			}
+			result.Entries++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.writeDeletes(keys); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// isStale returns true if path does not exist or is neither a directory nor a symlink to one
+func isStale(path string) bool {
+	info, err := os.Lstat(path)
+	if err == nil && info.Mode()&os.ModeSymlink != 0 {
+		info, err = os.Stat(path)
+	}
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	return !info.IsDir()
+}
+
+// PruneStaleEntries removes cache entries of directories under path which are gone
+// from the filesystem (see IncrementalStorage.PruneStale) and returns the number of removed entries
+// and their estimated size. It returns ErrBusy while a scan is running
+func (a *IncrementalAnalyzer) PruneStaleEntries(path string) (entries int, bytes int64, err error) {
+	err = a.withStorage(path, func(storage *IncrementalStorage) error {
+		result, err := storage.PruneStale(path)
+		if err != nil {
+			return err
+		}
+		entries, bytes = result.Entries, result.Bytes
+		return nil
+	})
+	return entries, bytes, err
+}
+
+// PruneRemoved removes entries of the directories at paths and of all their descendants
+// together with their pages, e.g. of directories found removed by a scan.
+// Unlike PruneStale it does not check the filesystem
+func (s *IncrementalStorage) PruneRemoved(paths []string) (*StaleResult, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.db == nil {
+		return nil, fmt.Errorf("storage is not open")
+	}
+
+	result := &StaleResult{}
+	keys := make([][]byte, 0)
+	for _, path := range paths {
+		err := s.matchingKeys(s.subtreeMatcher(path), func(item *badger.Item) {
+			keys = append(keys, item.KeyCopy(nil))
+			result.Bytes += item.EstimatedSize()
+			if bytes.HasPrefix(item.Key(), []byte(KeyPrefixDirMetadata)) {
+				result.Entries++
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.writeDeletes(keys); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// removedDirs collects the directories found removed by the running scan
+// (see IncrementalOptions.PruneStale), nil if they are not collected
+type removedDirs struct {
+	m     sync.Mutex
+	paths []string
+}
+
+func (r *removedDirs) add(path string) {
+	if r == nil {
+		return
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.paths = append(r.paths, path)
+}
+
+// take returns the collected directories and starts collecting again
+func (r *removedDirs) take() []string {
+	r.m.Lock()
+	defer r.m.Unlock()
+	paths := r.paths
+	r.paths = nil
+	return paths
+}
+
+// pruneRemoved removes entries of the directories found removed by the scan of path
+// and of their descendants (see IncrementalOptions.PruneStale). The scan found them
+// missing in the listings of their parents, so they are not checked again
+func (a *IncrementalAnalyzer) pruneRemoved(path string) {
+	removed := a.removed.take()
+	if len(removed) == 0 {
+		return
+	}
+	result, err := a.storage.PruneRemoved(removed)
+	if err != nil {
+		a.stats.IncrementCacheErrors()
+		log.Printf("Warning: Failed to prune cache entries of removed directories: %v", err)
+		return
+	}
+	a.stats.AddStalePruned(result.Entries, result.Bytes)
+	if result.Entries > 0 {
+		log.Printf("Pruned %d cache entries of removed directories under %s", result.Entries, path)
+	}
+}
@@ -0,0 +1,124 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalStorage_PruneStale(t *testing.T) {
+	tmp := t.TempDir()
+	top := filepath.Join(tmp, "top")
+	for _, dir := range []string{"kept", "file", "top-sibling"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(top, dir), 0o755))
+	}
+	assert.NoError(t, os.MkdirAll(filepath.Join(tmp, "top-sibling"), 0o755))
+	// the directory was replaced by a file
+	assert.NoError(t, os.Remove(filepath.Join(top, "file")))
+	assert.NoError(t, os.WriteFile(filepath.Join(top, "file"), []byte("x"), 0o644))
+	// the directory was replaced by a broken symlink
+	assert.NoError(t, os.Symlink(filepath.Join(tmp, "missing"), filepath.Join(top, "broken")))
+
+	storage := NewIncrementalStorage(t.TempDir(), "")
+	storage.pageSize = 10
+	mustOpen(t, storage)
+	for _, path := range []string{
+		top,
+		filepath.Join(top, "kept"),
+		filepath.Join(top, "file"),
+		filepath.Join(top, "broken"),
+		filepath.Join(top, "gone"),
+		filepath.Join(top, "gone", "sub"),
+		filepath.Join(tmp, "top-sibling", "gone"), // outside of top, sharing its prefix
+		filepath.Join(tmp, "outside"),
+	} {
+		assert.NoError(t, storage.StoreDirMetadata(largeDirMetadata(path, 1)))
+	}
+	assert.NoError(t, storage.StoreDirMetadata(largeDirMetadata(filepath.Join(top, "paged"), 25)))
+
+	result, err := storage.PruneStale(top)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, result.Entries)
+	assert.Greater(t, result.Bytes, int64(0))
+
+	for _, path := range []string{"file", "broken", "gone", "gone/sub", "paged"} {
+		_, err := storage.LoadDirMetadata(filepath.Join(top, path))
+		assert.Error(t, err, path)
+	}
+	for _, path := range []string{
+		top, filepath.Join(top, "kept"), filepath.Join(tmp, "top-sibling", "gone"), filepath.Join(tmp, "outside"),
+	} {
+		_, err := storage.LoadDirMetadata(path)
+		assert.NoError(t, err, path)
+	}
+	// pages of the removed entry are removed with it
+	assert.Empty(t, pageKeys(t, storage))
+
+	result, err = storage.PruneStale(top)
+	assert.NoError(t, err)
+	assert.Equal(t, &StaleResult{}, result)
+
+	assert.NoError(t, storage.MarkScanStarted())
+	_, err = storage.PruneStale(top)
+	assert.ErrorIs(t, err, ErrBusy)
+}
+
+func TestIncrementalAnalyzer_PruneStale(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"gone/sub", "file", "kept/sub", "old"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o755))
+	}
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	scan := func(opts IncrementalOptions) *CacheStats {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+		analyzer.GetDone().Wait()
+		return analyzer.GetScanResult().Stats
+	}
+	scan(opts)
+
+	// the entries of the removed directories are not read by the scan and stay without pruning
+	assert.NoError(t, os.Remove(filepath.Join(root, "old")))
+	stats := scan(opts)
+	assert.Equal(t, int64(0), stats.StaleEntries)
+	entries, bytes, err := CreateIncrementalAnalyzer(opts).PruneStaleEntries(filepath.Join(root, "kept"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, entries)
+	assert.Equal(t, int64(0), bytes)
+
+	assert.NoError(t, os.RemoveAll(filepath.Join(root, "gone")))
+	assert.NoError(t, os.Remove(filepath.Join(root, "file")))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "file"), []byte("x"), 0o600))
+	opts.PruneStale = true
+	stats = scan(opts)
+	assert.Equal(t, int64(3), stats.StaleEntries, "gone, gone/sub and the directory replaced by file")
+	assert.Greater(t, stats.StaleBytes, int64(0))
+
+	// the cached directories are not checked again, the one removed before the previous scan is left
+	entries, _, err = CreateIncrementalAnalyzer(opts).PruneStaleEntries(root)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, entries)
+}
+
+func TestIncrementalStorage_PruneRemoved(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "")
+	storage.pageSize = 10
+	mustOpen(t, storage)
+	for _, path := range []string{"/top", "/top/gone/sub", "/top/gone-sibling"} {
+		assert.NoError(t, storage.StoreDirMetadata(largeDirMetadata(path, 1)))
+	}
+	assert.NoError(t, storage.StoreDirMetadata(largeDirMetadata("/top/gone", 25)))
+
+	result, err := storage.PruneRemoved([]string{"/top/gone", "/top/missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Entries)
+	assert.Greater(t, result.Bytes, int64(0))
+	assert.Empty(t, pageKeys(t, storage))
+	for _, path := range []string{"/top", "/top/gone-sibling"} {
+		_, err := storage.LoadDirMetadata(path)
+		assert.NoError(t, err, path)
+	}
+}
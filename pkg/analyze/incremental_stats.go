@@ -2,10 +2,48 @@ package analyze
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
 
+// maxTrackedDevices limits the number of devices with own statistics in CacheStats.Devices
+const maxTrackedDevices = 64
+
+// OtherDevices is the key of CacheStats.Devices aggregating devices over the limit
+const OtherDevices = ^uint64(0)
+
+// DeviceStats holds statistics of the directories located on one device.
+// Directories loaded from the cache together with their parent count as hits
+type DeviceStats struct {
+	Device         uint64
+	MountPoint     string // topmost directory on the device, empty if not known
+	Dirs           int64
+	CacheHits      int64
+	Scanned        int64 // directories read from the filesystem (missing, changed, expired or forced)
+	BytesFromCache int64
+	BytesScanned   int64
+	ScanTime       time.Duration // time spent in the directories, without subdirectories on other devices
+}
+
+// HitRate calculates the cache hit rate of the device as a percentage
+func (d *DeviceStats) HitRate() float64 {
+	total := d.CacheHits + d.Scanned
+	if total == 0 {
+		return 0
+	}
+	return float64(d.CacheHits) / float64(total) * 100
+}
+
+func (d *DeviceStats) add(delta *DeviceStats) {
+	d.Dirs += delta.Dirs
+	d.CacheHits += delta.CacheHits
+	d.Scanned += delta.Scanned
+	d.BytesFromCache += delta.BytesFromCache
+	d.BytesScanned += delta.BytesScanned
+	d.ScanTime += delta.ScanTime
+}
+
 // CacheStats tracks statistics for incremental caching
 type CacheStats struct {
 	TotalDirs      int64
@@ -20,12 +58,149 @@ type CacheStats struct {
 	TotalScanTime  time.Duration
 	CacheLoadTime  time.Duration
 
-	mu sync.RWMutex
+	// DuplicateDirsSkipped counts directories already visited under another path
+	// (bind mounts), which were added as references instead of being scanned again
+	DuplicateDirsSkipped int64
+
+	// CorruptedEntries counts invalid cache entries removed after a crashed scan
+	CorruptedEntries int64
+
+	// CacheErrors counts failed reads (other than misses) and writes of cache entries.
+	// The scanned tree is not affected, but the next scan may need to read more
+	CacheErrors int64
+
+	// FutureTimestamps counts directories with mtime or cache entry in the future,
+	// which were scanned again (see IncrementalOptions.FutureSkew)
+	FutureTimestamps int64
+
+	// CachedAhead counts cache entries written later than now, usually before the system clock
+	// was stepped backwards (see IncrementalOptions.TrustCachedAhead)
+	CachedAhead int64
+
+	// ClockSkewRescans counts directories with mtime earlier than the cached one,
+	// which were scanned again (see IncrementalOptions.BackwardsSkew)
+	ClockSkewRescans int64
+
+	// VanishedDuringScan counts directories removed between reading the listing
+	// of their parent and reading them, which were left out of the tree
+	VanishedDuringScan int64
+
+	// ExcludedFiles and ExcludedBytes count files left out of the tree
+	// by IncrementalOptions.ExcludeFiles and their apparent size
+	ExcludedFiles int64
+	ExcludedBytes int64
+
+	// TooDeepDirs counts directories below the depth ceiling (IncrementalOptions.MaxDepth),
+	// whose content was not read
+	TooDeepDirs int64
+
+	// KeyCollisions counts cache entries found under the key of a directory
+	// but written for another path, the directories were scanned again
+	KeyCollisions int64
+
+	// InvalidatedPaths counts directories passed to IncrementalAnalyzer.InvalidatePaths
+	// whose entries were removed before the scan, InvalidatedEntries the removed entries
+	// (of the directories, their descendants and their pages)
+	InvalidatedPaths   int64
+	InvalidatedEntries int64
+
+	// SmallDirsNotCached counts directories scanned without storing their cache entry,
+	// being below IncrementalOptions.MinCacheDirSize and MinCacheChildren.
+	// They are not counted as cache misses
+	SmallDirsNotCached int64
+
+	// DuplicateNamesSkipped counts entries of listings repeating the name of an earlier entry
+	// of the same directory (returned by some network and FUSE filesystems), only the first was read
+	DuplicateNamesSkipped int64
+
+	// HashRescans counts hash-verified directories (see IncrementalOptions.HashVerifyPrefixes)
+	// scanned again because the fingerprint of their children changed although their mtime did not
+	HashRescans int64
+
+	// ValidationReads counts directories listed to validate their cache entries and ValidationRescans
+	// the ones scanned again because the listing differed (see IncrementalOptions.ValidationMode)
+	ValidationReads   int64
+	ValidationRescans int64
+
+	// Retries counts reads of directories and files repeated after transient errors
+	// (see IncrementalOptions.RetryCount)
+	Retries int64
+
+	// PrunedEntries and PrunedSummaries count directory entries and scan summaries removed
+	// after the scan as older than the retention (see IncrementalOptions.CacheRetention)
+	PrunedEntries   int64
+	PrunedSummaries int64
+
+	// StaleEntries counts entries of directories of the scanned tree removed after the scan
+	// as gone from the filesystem (see IncrementalOptions.PruneStale), StaleBytes their estimated size
+	StaleEntries int64
+	StaleBytes   int64
+
+	// NewDirs lists directories that did not exist in the previous generation
+	// (bounded by IncrementalOptions.MaxReportedPaths, NewDirsCount holds the total number)
+	NewDirs      []string
+	NewDirsCount int64
+
+	// RemovedDirs lists directories of the previous generation which are gone,
+	// only the topmost directory of a removed subtree is listed
+	// (bounded by IncrementalOptions.MaxReportedPaths, RemovedDirsCount holds the total number)
+	RemovedDirs      []string
+	RemovedDirsCount int64
+
+	// SummaryHit is set if the top directory matched the summary of the previous clean scan
+	// and the tree was not walked (see IncrementalOptions.TrustRootMtime)
+	SummaryHit bool
+
+	// Devices aggregates the statistics by device of the directories
+	// (bounded by maxTrackedDevices, further devices are aggregated under OtherDevices)
+	Devices map[uint64]*DeviceStats
+
+	// Hostname and AppVersion identify the host and the version of gdu running the scan,
+	// they are stored in the cache entries it writes
+	Hostname   string
+	AppVersion string
+
+	// PreviousHostname and PreviousAppVersion identify the writer of the cache entry
+	// of the scanned directory loaded from the previous scan (empty if there was none)
+	PreviousHostname   string
+	PreviousAppVersion string
+
+	// VersionMismatches counts cache entries loaded from the previous scan
+	// which were written by another major version of gdu
+	VersionMismatches int64
+
+	// Storage holds durations of the cache reads and writes, set when the scan finishes
+	Storage StorageMetrics
+
+	// PeakHeap is the largest size of the heap sampled during the scan in bytes,
+	// including garbage not collected yet (see IncrementalOptions.MemoryMode)
+	PeakHeap uint64
+
+	// CacheDirInTree is the cache directory if it is located in the scanned tree,
+	// CacheDirExcluded is set if it was left out of the scan (see IncrementalOptions.CountCacheDir)
+	CacheDirInTree   string
+	CacheDirExcluded bool
+
+	// CacheDirUsage is the disk usage of the cache directory counted in the scanned tree,
+	// the part of the totals taken by the cache itself. 0 if it was left out
+	CacheDirUsage int64
+
+	// VolatileStorage describes why the cache directory is likely lost on reboot
+	// (tmpfs, ramfs or a location cleaned by the system), empty if it is not
+	VolatileStorage string
+
+	pathLimit int // limit of the path lists
+	mu        sync.RWMutex
 }
 
 // NewCacheStats creates a new CacheStats instance
 func NewCacheStats() *CacheStats {
-	return &CacheStats{}
+	return newCacheStats(DefaultMaxReportedPaths)
+}
+
+// newCacheStats creates a new CacheStats instance keeping at most pathLimit paths in each path list
+func newCacheStats(pathLimit int) *CacheStats {
+	return &CacheStats{pathLimit: pathLimit}
 }
 
 // IncrementTotalDirs increments the total directories counter
@@ -77,6 +252,424 @@ func (s *CacheStats) AddBytesScanned(bytes int64) {
 	s.BytesScanned += bytes
 }
 
+// IncrementDuplicateDirsSkipped increments the skipped duplicate directories counter
+func (s *CacheStats) IncrementDuplicateDirsSkipped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.DuplicateDirsSkipped++
+}
+
+// AddCorruptedEntries adds to the removed invalid cache entries counter
+func (s *CacheStats) AddCorruptedEntries(count int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.CorruptedEntries += count
+}
+
+// IncrementCacheErrors increments the counter of failed reads and writes of the cache
+func (s *CacheStats) IncrementCacheErrors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.CacheErrors++
+}
+
+// IncrementFutureTimestamps increments the counter of directories with timestamps in the future
+func (s *CacheStats) IncrementFutureTimestamps() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.FutureTimestamps++
+}
+
+// IncrementCachedAhead increments the counter of cache entries written later than now
+func (s *CacheStats) IncrementCachedAhead() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.CachedAhead++
+}
+
+// IncrementClockSkewRescans increments the counter of directories with mtime moved backwards
+func (s *CacheStats) IncrementClockSkewRescans() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ClockSkewRescans++
+}
+
+// IncrementVanishedDuringScan increments the counter of directories removed during the scan
+func (s *CacheStats) IncrementVanishedDuringScan() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.VanishedDuringScan++
+}
+
+// AddExcludedFiles adds files left out by the excluded file patterns
+func (s *CacheStats) AddExcludedFiles(count, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ExcludedFiles += count
+	s.ExcludedBytes += size
+}
+
+// IncrementTooDeepDirs increments the counter of directories below the depth ceiling
+func (s *CacheStats) IncrementTooDeepDirs() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.TooDeepDirs++
+}
+
+// IncrementKeyCollisions increments the counter of entries written for another path
+func (s *CacheStats) IncrementKeyCollisions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.KeyCollisions++
+}
+
+// AddInvalidated records a directory invalidated before the scan with the number of removed entries
+func (s *CacheStats) AddInvalidated(entries int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.InvalidatedPaths++
+	s.InvalidatedEntries += int64(entries)
+}
+
+// IncrementSmallDirsNotCached increments the counter of small directories scanned without caching
+func (s *CacheStats) IncrementSmallDirsNotCached() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.SmallDirsNotCached++
+}
+
+// IncrementDuplicateNamesSkipped increments the counter of skipped entries repeating a name
+func (s *CacheStats) IncrementDuplicateNamesSkipped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.DuplicateNamesSkipped++
+}
+
+// IncrementHashRescans increments the counter of directories scanned again because of changed fingerprint
+func (s *CacheStats) IncrementHashRescans() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.HashRescans++
+}
+
+// IncrementValidationReads increments the counter of directories listed to validate their cache entries
+func (s *CacheStats) IncrementValidationReads() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ValidationReads++
+}
+
+// IncrementValidationRescans increments the counter of directories scanned again because of changed listing
+func (s *CacheStats) IncrementValidationRescans() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ValidationRescans++
+}
+
+// IncrementRetries increments the counter of reads repeated after transient errors
+func (s *CacheStats) IncrementRetries() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Retries++
+}
+
+// AddPruned adds numbers of entries and summaries removed as older than the retention
+func (s *CacheStats) AddPruned(entries, summaries int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.PrunedEntries += int64(entries)
+	s.PrunedSummaries += int64(summaries)
+}
+
+// AddStalePruned adds number and estimated size of entries removed as gone from the filesystem
+func (s *CacheStats) AddStalePruned(entries int, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.StaleEntries += int64(entries)
+	s.StaleBytes += bytes
+}
+
+// SetSummaryHit records that the tree was loaded from the summary of the previous scan
+func (s *CacheStats) SetSummaryHit() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.SummaryHit = true
+}
+
+// SetPeakHeap sets the largest size of the heap seen during the scan
+func (s *CacheStats) SetPeakHeap(size uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.PeakHeap = size
+}
+
+// SetCacheDirInTree records the cache directory located in the scanned tree
+func (s *CacheStats) SetCacheDirInTree(path string, excluded bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.CacheDirInTree = path
+	s.CacheDirExcluded = excluded
+}
+
+// SetCacheDirUsage records the disk usage of the cache directory counted in the scanned tree
+func (s *CacheStats) SetCacheDirUsage(usage int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.CacheDirUsage = usage
+}
+
+// SetVolatileStorage records the reason why the cache directory is likely lost on reboot
+func (s *CacheStats) SetVolatileStorage(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.VolatileStorage = reason
+}
+
+// SetProvenance sets the host and the version of gdu running the scan
+func (s *CacheStats) SetProvenance(hostname, appVersion string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Hostname = hostname
+	s.AppVersion = appVersion
+}
+
+// SetPreviousProvenance sets the writer of the cache entry of the scanned directory
+func (s *CacheStats) SetPreviousProvenance(hostname, appVersion string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.PreviousHostname = hostname
+	s.PreviousAppVersion = appVersion
+}
+
+// IncrementVersionMismatches increments the counter of entries written by another major version
+func (s *CacheStats) IncrementVersionMismatches() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.VersionMismatches++
+}
+
+// AddNewDir records a directory which was not present in the previous generation
+func (s *CacheStats) AddNewDir(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.NewDirsCount++
+	s.NewDirs, _ = appendBounded(s.NewDirs, path, s.pathLimit)
+}
+
+// AddRemovedDir records a directory of the previous generation which is not present anymore
+func (s *CacheStats) AddRemovedDir(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.RemovedDirsCount++
+	s.RemovedDirs, _ = appendBounded(s.RemovedDirs, path, s.pathLimit)
+}
+
+// NewDirsDropped returns number of new directories which did not fit into NewDirs
+func (s *CacheStats) NewDirsDropped() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.NewDirsCount - int64(len(s.NewDirs))
+}
+
+// RemovedDirsDropped returns number of removed directories which did not fit into RemovedDirs
+func (s *CacheStats) RemovedDirsDropped() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.RemovedDirsCount - int64(len(s.RemovedDirs))
+}
+
+// AddDeviceStats adds the statistics of a directory to the ones of its device
+func (s *CacheStats) AddDeviceStats(delta DeviceStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Devices == nil {
+		s.Devices = make(map[uint64]*DeviceStats)
+	}
+	device, ok := s.Devices[delta.Device]
+	if !ok && len(s.Devices) >= maxTrackedDevices {
+		device, ok = s.Devices[OtherDevices]
+		if !ok {
+			device = &DeviceStats{Device: OtherDevices}
+			s.Devices[OtherDevices] = device
+		}
+	} else if !ok {
+		device = &DeviceStats{Device: delta.Device, MountPoint: delta.MountPoint}
+		s.Devices[delta.Device] = device
+	}
+	device.add(&delta)
+}
+
+// DeviceList returns statistics of the devices ordered by mount point
+func (s *CacheStats) DeviceList() []DeviceStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	devices := make([]DeviceStats, 0, len(s.Devices))
+	for _, device := range s.Devices {
+		devices = append(devices, *device)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].MountPoint != devices[j].MountPoint {
+			return devices[i].MountPoint < devices[j].MountPoint
+		}
+		return devices[i].Device < devices[j].Device
+	})
+	return devices
+}
+
+// Snapshot returns a copy of the statistics which is not updated anymore
+func (s *CacheStats) Snapshot() *CacheStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var devices map[uint64]*DeviceStats
+	if s.Devices != nil {
+		devices = make(map[uint64]*DeviceStats, len(s.Devices))
+		for dev, device := range s.Devices {
+			deviceCopy := *device
+			devices[dev] = &deviceCopy
+		}
+	}
+
+	return &CacheStats{
+		TotalDirs:      s.TotalDirs,
+		CacheHits:      s.CacheHits,
+		CacheMisses:    s.CacheMisses,
+		CacheExpired:   s.CacheExpired,
+		DirsRescanned:  s.DirsRescanned,
+		BytesFromCache: s.BytesFromCache,
+		BytesScanned:   s.BytesScanned,
+		ScanStartTime:  s.ScanStartTime,
+		ScanEndTime:    s.ScanEndTime,
+		TotalScanTime:  s.TotalScanTime,
+		CacheLoadTime:  s.CacheLoadTime,
+		NewDirs:        append([]string(nil), s.NewDirs...),
+		NewDirsCount:   s.NewDirsCount,
+		Devices:        devices,
+		Storage:        s.Storage,
+		PeakHeap:       s.PeakHeap,
+
+		DuplicateDirsSkipped:  s.DuplicateDirsSkipped,
+		CorruptedEntries:      s.CorruptedEntries,
+		CacheErrors:           s.CacheErrors,
+		VanishedDuringScan:    s.VanishedDuringScan,
+		TooDeepDirs:           s.TooDeepDirs,
+		KeyCollisions:         s.KeyCollisions,
+		DuplicateNamesSkipped: s.DuplicateNamesSkipped,
+		SmallDirsNotCached:    s.SmallDirsNotCached,
+		InvalidatedPaths:      s.InvalidatedPaths,
+		InvalidatedEntries:    s.InvalidatedEntries,
+		Retries:               s.Retries,
+		PrunedEntries:         s.PrunedEntries,
+		PrunedSummaries:       s.PrunedSummaries,
+		StaleEntries:          s.StaleEntries,
+		StaleBytes:            s.StaleBytes,
+		HashRescans:           s.HashRescans,
+		ValidationReads:       s.ValidationReads,
+		ValidationRescans:     s.ValidationRescans,
+		ExcludedFiles:         s.ExcludedFiles,
+		ExcludedBytes:         s.ExcludedBytes,
+		FutureTimestamps:      s.FutureTimestamps,
+		CachedAhead:           s.CachedAhead,
+		ClockSkewRescans:      s.ClockSkewRescans,
+		RemovedDirs:           append([]string(nil), s.RemovedDirs...),
+		RemovedDirsCount:      s.RemovedDirsCount,
+		Hostname:              s.Hostname,
+		AppVersion:            s.AppVersion,
+		PreviousHostname:      s.PreviousHostname,
+		PreviousAppVersion:    s.PreviousAppVersion,
+		VersionMismatches:     s.VersionMismatches,
+		SummaryHit:            s.SummaryHit,
+		CacheDirInTree:        s.CacheDirInTree,
+		CacheDirExcluded:      s.CacheDirExcluded,
+		CacheDirUsage:         s.CacheDirUsage,
+		VolatileStorage:       s.VolatileStorage,
+		pathLimit:             s.pathLimit,
+	}
+}
+
+// CombineCacheStats returns statistics of scans of several top directories run one after another.
+// Counters and scan times are summed, the path lists keep the limit of the first statistics.
+// Provenance of the previous scans is left out, it differs by directory
+func CombineCacheStats(stats ...*CacheStats) *CacheStats {
+	combined := newCacheStats(DefaultMaxReportedPaths)
+	for i, s := range stats {
+		s = s.Snapshot()
+		if i == 0 {
+			combined.pathLimit = s.pathLimit
+			combined.ScanStartTime = s.ScanStartTime
+			combined.Hostname = s.Hostname
+			combined.AppVersion = s.AppVersion
+			combined.SummaryHit = true
+		}
+
+		combined.TotalDirs += s.TotalDirs
+		combined.CacheHits += s.CacheHits
+		combined.CacheMisses += s.CacheMisses
+		combined.CacheExpired += s.CacheExpired
+		combined.DirsRescanned += s.DirsRescanned
+		combined.BytesFromCache += s.BytesFromCache
+		combined.BytesScanned += s.BytesScanned
+		combined.TotalScanTime += s.TotalScanTime
+		combined.CacheLoadTime += s.CacheLoadTime
+		combined.DuplicateDirsSkipped += s.DuplicateDirsSkipped
+		combined.CorruptedEntries += s.CorruptedEntries
+		combined.CacheErrors += s.CacheErrors
+		combined.FutureTimestamps += s.FutureTimestamps
+		combined.CachedAhead += s.CachedAhead
+		combined.ClockSkewRescans += s.ClockSkewRescans
+		combined.VanishedDuringScan += s.VanishedDuringScan
+		combined.ExcludedFiles += s.ExcludedFiles
+		combined.ExcludedBytes += s.ExcludedBytes
+		combined.TooDeepDirs += s.TooDeepDirs
+		combined.KeyCollisions += s.KeyCollisions
+		combined.DuplicateNamesSkipped += s.DuplicateNamesSkipped
+		combined.SmallDirsNotCached += s.SmallDirsNotCached
+		combined.InvalidatedPaths += s.InvalidatedPaths
+		combined.InvalidatedEntries += s.InvalidatedEntries
+		combined.Retries += s.Retries
+		combined.PrunedEntries += s.PrunedEntries
+		combined.PrunedSummaries += s.PrunedSummaries
+		combined.StaleEntries += s.StaleEntries
+		combined.StaleBytes += s.StaleBytes
+		combined.HashRescans += s.HashRescans
+		combined.ValidationReads += s.ValidationReads
+		combined.ValidationRescans += s.ValidationRescans
+		combined.VersionMismatches += s.VersionMismatches
+		combined.NewDirsCount += s.NewDirsCount
+		combined.RemovedDirsCount += s.RemovedDirsCount
+		combined.SummaryHit = combined.SummaryHit && s.SummaryHit
+		combined.PeakHeap = max(combined.PeakHeap, s.PeakHeap)
+		if combined.CacheDirInTree == "" {
+			combined.CacheDirInTree = s.CacheDirInTree
+			combined.CacheDirExcluded = s.CacheDirExcluded
+			combined.CacheDirUsage = s.CacheDirUsage
+		}
+		if combined.VolatileStorage == "" {
+			combined.VolatileStorage = s.VolatileStorage
+		}
+		if s.ScanEndTime.After(combined.ScanEndTime) {
+			combined.ScanEndTime = s.ScanEndTime
+		}
+
+		combined.Storage.Reads.add(s.Storage.Reads)
+		combined.Storage.Decodes.add(s.Storage.Decodes)
+		combined.Storage.Writes.add(s.Storage.Writes)
+
+		for _, path := range s.NewDirs {
+			combined.NewDirs, _ = appendBounded(combined.NewDirs, path, combined.pathLimit)
+		}
+		for _, path := range s.RemovedDirs {
+			combined.RemovedDirs, _ = appendBounded(combined.RemovedDirs, path, combined.pathLimit)
+		}
+		for _, device := range s.DeviceList() {
+			combined.AddDeviceStats(device)
+		}
+	}
+	return combined
+}
+
 // HitRate calculates the cache hit rate as a percentage
 func (s *CacheStats) HitRate() float64 {
 	s.mu.RLock()
@@ -120,8 +713,8 @@ func (s *CacheStats) String() string {
 		s.TotalDirs,
 		s.DirsRescanned,
 		s.CacheExpired,
-		s.TotalScanTime-s.CacheLoadTime,
-		s.TotalScanTime,
+		max(s.TotalScanTime-s.CacheLoadTime, 0),
+		max(s.TotalScanTime, 0),
 	)
 }
 
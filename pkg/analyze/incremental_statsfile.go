@@ -0,0 +1,89 @@
+package analyze
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// StatsFile is the content of IncrementalOptions.StatsFilePath written after every scan
+// and the summary passed to IncrementalOptions.PostScanHook.
+// Root and Generation tell files of several scanned directories apart
+type StatsFile struct {
+	Root       string      `json:"root"`
+	Generation uint64      `json:"generation"` // 0 if the scan was not recorded in the cache
+	Label      string      `json:"label,omitempty"`
+	Status     string      `json:"status"`
+	Error      string      `json:"error,omitempty"`
+	ErrorCount int         `json:"errorCount"`
+	Size       int64       `json:"size"`
+	Usage      int64       `json:"usage"`
+	WrittenAt  time.Time   `json:"writtenAt"`
+	Stats      *CacheStats `json:"stats"`
+}
+
+// newStatsFile returns summary of the finished scan of root
+func newStatsFile(root string, result *ScanResult) *StatsFile {
+	content := &StatsFile{
+		Root:       root,
+		Generation: result.Generation,
+		Label:      result.Label,
+		Status:     result.Status.String(),
+		ErrorCount: result.ErrorCount,
+		Size:       result.Size,
+		Usage:      result.Usage,
+		WrittenAt:  time.Now(),
+		Stats:      result.Stats,
+	}
+	if result.Err != nil {
+		content.Error = result.Err.Error()
+	}
+	return content
+}
+
+// writeStatsFile replaces the file at path by the summary of the finished scan.
+// The data are written into a temporary file in the same directory which is then renamed,
+// so readers see either the previous or the new content
+func writeStatsFile(path string, content *StatsFile) {
+	if err := replaceFile(path, func(f *os.File) error {
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(content)
+	}); err != nil {
+		log.Printf("Warning: Failed to write stats file %s: %v", path, err)
+	}
+}
+
+// runPostScan calls the post-scan hook with the summary of the finished scan,
+// its failure is logged and kept in the result
+func (a *IncrementalAnalyzer) runPostScan(summary *StatsFile, result *ScanResult) {
+	if err := a.postScan(summary); err != nil {
+		log.Printf("Warning: Post-scan hook of %s failed: %v", summary.Root, err)
+		result.HookErr = err
+	}
+}
+
+// replaceFile atomically replaces the file at path by the content written by write
+func replaceFile(path string, write func(f *os.File) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	// fails after the rename, the temporary file is removed only if it was not renamed
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
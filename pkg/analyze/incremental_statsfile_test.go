@@ -0,0 +1,158 @@
+package analyze
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readStatsFile(t *testing.T, path string) *StatsFile {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	content := &StatsFile{}
+	assert.NoError(t, json.Unmarshal(data, content))
+	return content
+}
+
+func TestIncrementalAnalyzer_StatsFile(t *testing.T) {
+	root := createTraceFixture(t)
+	statsDir := t.TempDir()
+	statsFile := filepath.Join(statsDir, "stats.json")
+	opts := IncrementalOptions{StoragePath: t.TempDir(), StatsFilePath: statsFile}
+
+	for gen := uint64(1); gen <= 2; gen++ {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+		analyzer.GetDone().Wait()
+
+		content := readStatsFile(t, statsFile)
+		assert.Equal(t, root, content.Root)
+		assert.Equal(t, gen, content.Generation)
+		assert.Equal(t, "completed", content.Status)
+		assert.Empty(t, content.Error)
+		assert.Equal(t, analyzer.GetCacheStats().TotalDirs, content.Stats.TotalDirs)
+		assert.False(t, content.WrittenAt.IsZero())
+		assert.Equal(t, gen, analyzer.GetScanResult().Generation)
+	}
+
+	// the previous file is replaced, no temporary files are left behind
+	entries, err := os.ReadDir(statsDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	content := readStatsFile(t, statsFile)
+	assert.Equal(t, int64(1), content.Stats.CacheHits, "second scan is warm")
+	assert.Equal(t, int64(0), content.Stats.DirsRescanned)
+}
+
+func TestIncrementalAnalyzer_StatsFileReplacedAtomically(t *testing.T) {
+	statsFile := filepath.Join(t.TempDir(), "stats.json")
+	assert.NoError(t, os.WriteFile(statsFile, []byte("previous"), 0o600))
+
+	// a reader holding the previous file keeps reading it whole
+	previous, err := os.Open(statsFile)
+	assert.NoError(t, err)
+	defer previous.Close()
+
+	writeStatsFile(statsFile, newStatsFile("/root", &ScanResult{Status: ScanCancelled, Generation: 3, Stats: &CacheStats{}}))
+
+	data := make([]byte, 100)
+	n, err := previous.Read(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "previous", string(data[:n]))
+
+	content := readStatsFile(t, statsFile)
+	assert.Equal(t, "cancelled", content.Status)
+	assert.Equal(t, uint64(3), content.Generation)
+
+	info, err := os.Stat(statsFile)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+}
+
+func TestIncrementalAnalyzer_StatsFileError(t *testing.T) {
+	root := createTraceFixture(t)
+	statsFile := filepath.Join(t.TempDir(), "missing", "stats.json")
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir(), StatsFilePath: statsFile})
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	assert.Equal(t, ScanCompleted, analyzer.GetScanResult().Status, "failed write is not fatal")
+	_, err := os.Stat(statsFile)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestIncrementalAnalyzer_PostScanHook(t *testing.T) {
+	root := createTraceFixture(t)
+	var summaries []*StatsFile
+	hookErr := errors.New("hook failed")
+	opts := IncrementalOptions{
+		StoragePath: t.TempDir(),
+		PostScanHook: func(summary *StatsFile) error {
+			summaries = append(summaries, summary)
+			if len(summaries) == 2 {
+				return hookErr
+			}
+			return nil
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+		analyzer.GetDone().Wait()
+
+		assert.Len(t, summaries, i+1, "hook is called before the scan is done")
+		summary := summaries[i]
+		assert.Equal(t, root, summary.Root)
+		assert.Equal(t, "completed", summary.Status)
+		assert.Equal(t, dir.GetUsage(), summary.Usage)
+		assert.Equal(t, dir.GetSize(), summary.Size)
+		assert.Equal(t, analyzer.GetCacheStats().CacheHits, summary.Stats.CacheHits)
+
+		result := analyzer.GetScanResult()
+		assert.Equal(t, ScanCompleted, result.Status, "failed hook is not fatal")
+		if i == 0 {
+			assert.Nil(t, result.HookErr)
+		} else {
+			assert.ErrorIs(t, result.HookErr, hookErr)
+		}
+	}
+
+	assert.Equal(t, float64(0), summaries[0].Stats.HitRate())
+	assert.Equal(t, float64(100), summaries[1].Stats.HitRate())
+}
+
+func TestIncrementalAnalyzer_ScanLabel(t *testing.T) {
+	root := createTraceFixture(t)
+	statsFile := filepath.Join(t.TempDir(), "stats.json")
+	storagePath := t.TempDir()
+
+	for gen, label := range []string{"pre-cleanup", "post-cleanup"} {
+		analyzer := CreateIncrementalAnalyzer(IncrementalOptions{
+			StoragePath: storagePath, StatsFilePath: statsFile, ScanLabel: label,
+		})
+		analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+		analyzer.GetDone().Wait()
+
+		assert.Equal(t, label, analyzer.GetScanResult().Label)
+		content := readStatsFile(t, statsFile)
+		assert.Equal(t, label, content.Label)
+		assert.Equal(t, uint64(gen+1), content.Generation)
+	}
+
+	storage := NewIncrementalStorage(storagePath, root)
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	defer closeFn()
+	summary, err := storage.LoadRootSummary(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "post-cleanup", summary.Label)
+	assert.Equal(t, uint64(2), summary.Generation)
+}
@@ -1,18 +1,47 @@
 package analyze
 
 import (
-	"bytes"
 	"encoding/gob"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dgraph-io/badger/v3"
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
 )
 
+// IncrementalSchemaVersion is the version of the cache layout.
+// Version 1 (implicit, never stored) contained only directory metadata,
+// version 3 added sizes of the direct files (SelfSize, SelfUsage) to the directory entries,
+// version 4 stores children of large directories in pages (see DefaultDirPageSize)
+const IncrementalSchemaVersion = 4
+
+// Key prefixes of the cache namespaces.
+// Every kind of record must live under its own prefix so that iteration
+// and clearing of one namespace never touches the others
+const (
+	KeyPrefixDirMetadata = "incr:"   // directory metadata by path
+	KeyPrefixDirPage     = "page:"   // pages of children of large directories by path and page number
+	KeyPrefixHistory     = "hist:"   // scan history by top directory and generation
+	KeyPrefixRootSummary = "root:"   // summaries of scanned top directories
+	KeyPrefixMarker      = "mark:"   // markers, e.g. scan in progress
+	KeyPrefixInode       = "inode:"  // inode index
+	KeyPrefixAnnotation  = "note:"   // notes of directories by path, kept across scans
+	KeyPrefixSchema      = "schema:" // cache layout version
+)
+
+// ErrBusy is returned when the cache can't be cleared because a scan is using it
+var ErrBusy = errors.New("cache is used by a running scan")
+
+// ErrUnsupportedSchema is returned when opening a cache whose layout is newer than IncrementalSchemaVersion
+// or not known at all, the cache is left untouched
+var ErrUnsupportedSchema = errors.New("unsupported cache schema version")
+
 func init() {
 	gob.RegisterName("analyze.IncrementalDirMetadata", &IncrementalDirMetadata{})
 	gob.RegisterName("analyze.FileMetadata", &FileMetadata{})
@@ -22,24 +51,49 @@ func init() {
 type IncrementalDirMetadata struct {
 	Path         string         // Full path to directory
 	Mtime        time.Time      // Directory modification time
+	Btime        time.Time      // Directory birth time, zero if not known
+	Ctime        time.Time      // Directory status change time (chmod, chown), zero if not known
 	Size         int64          // Total apparent size
 	Usage        int64          // Total disk usage
+	SelfSize     int64          // Apparent size of direct files only, zero in entries of schema 2
+	SelfUsage    int64          // Disk usage of direct files only, zero in entries of schema 2
 	ItemCount    int            // Number of items in tree
 	Flag         rune           // Directory flag
+	ErrorCount   int            // Direct children that could not be read (+1 if ReadDir failed)
 	Files        []FileMetadata // Direct children metadata
 	CachedAt     time.Time      // When this was cached
 	ScanDuration time.Duration  // How long the scan took
+	Dev          uint64         // Device of the directory, zero if not known
+	Ino          uint64         // Inode of the directory, zero if not known
+	DuplicateOf  string         // Canonical path if the directory was a duplicate (bind mount)
+	Hostname     string         // Host which wrote the entry, empty in older entries
+	AppVersion   string         // Version of gdu which wrote the entry, empty in older entries
+
+	SymlinkCount       int // Direct children which are symlinks
+	BrokenSymlinkCount int // Direct children which are symlinks that could not be followed
+
+	Estimate *Estimate // Set if only a sample of the files was read, Files hold the sampled ones
+
+	Fingerprint   uint64 // Hash of the scan options the entry was written with (excluded file patterns), zero if none
+	ExcludedFiles int    // Direct files left out by the excluded file patterns
+	ExcludedSize  int64  // Apparent size of the excluded direct files
+	ContentHash   uint64 // Fingerprint of the direct children for IncrementalOptions.HashVerifyPrefixes, zero if not computed
+
+	Pages int // Number of pages the children are stored in, zero if they are stored in the entry itself
 }
 
 // FileMetadata contains metadata for a single file or directory
 type FileMetadata struct {
-	Name  string    // File name
-	IsDir bool      // Whether this is a directory
-	Size  int64     // Apparent size
-	Usage int64     // Disk usage
-	Mtime time.Time // Modification time
-	Flag  rune      // File flag
-	Mli   uint64    // Multi-linked inode (for hardlinks)
+	Name      string    // File name
+	IsDir     bool      // Whether this is a directory
+	Size      int64     // Apparent size
+	Usage     int64     // Disk usage
+	ItemCount int       // Number of items in subtree (directories only)
+	Mtime     time.Time // Modification time
+	Btime     time.Time // Birth time, zero if not known
+	Flag      rune      // File flag
+	Mli       uint64    // Multi-linked inode (for hardlinks)
+	Owner     uint32    // Uid of the owner plus one, zero if not known (directories, older entries)
 }
 
 // IncrementalStorage manages BadgerDB storage for incremental caching
@@ -50,6 +104,12 @@ type IncrementalStorage struct {
 	m           sync.RWMutex
 	counter     int
 	counterM    sync.Mutex
+	scanning    atomic.Bool // set between MarkScanStarted and MarkScanFinished
+	timers      storageTimers
+	events      *writeEvents // subscribers of the storage, unsubscribed when it is closed
+	forward     *writeEvents // subscribers of the analyzer using the storage, nil if none
+	pageSize    int          // children in one page of a large directory, DefaultDirPageSize if zero
+	options     StorageOptions
 }
 
 // NewIncrementalStorage creates a new incremental storage instance
@@ -57,6 +117,7 @@ func NewIncrementalStorage(storagePath, topDir string) *IncrementalStorage {
 	return &IncrementalStorage{
 		storagePath: storagePath,
 		topDir:      topDir,
+		events:      newWriteEvents(),
 	}
 }
 
@@ -72,66 +133,77 @@ func (s *IncrementalStorage) IsOpen() bool {
 	return s.db != nil
 }
 
-// Open opens the BadgerDB database with detailed error handling
-func (s *IncrementalStorage) Open() (func(), error) {
-	options := badger.DefaultOptions(s.storagePath)
-	options.Logger = nil
+// CloseFunc closes the storage opened by Open or OpenReadOnly.
+// It is safe to call it more than once, only the first call closes the database,
+// so it never closes the database opened again later by the same storage
+type CloseFunc func()
 
-	db, err := badger.Open(options)
+// Open opens the BadgerDB database with detailed error handling
+func (s *IncrementalStorage) Open() (CloseFunc, error) {
+	db, err := badger.Open(s.badgerOptions())
 	if err != nil {
-		// Provide specific error messages for common issues
-		errMsg := err.Error()
-
-		// Permission denied
-		if os.IsPermission(err) {
-			return nil, fmt.Errorf("permission denied opening cache at %s: %w", s.storagePath, err)
-		}
+		return nil, s.openError(err)
+	}
 
-		// Disk space issues
-		if strings.Contains(errMsg, "no space left") || strings.Contains(errMsg, "disk full") {
-			return nil, fmt.Errorf("insufficient disk space for cache at %s: %w", s.storagePath, err)
-		}
+	s.db = db
 
-		// Database corruption or version mismatch
-		if strings.Contains(errMsg, "corrupted") ||
-			strings.Contains(errMsg, "invalid") ||
-			strings.Contains(errMsg, "checksum") ||
-			strings.Contains(errMsg, "manifest") {
-			return nil, fmt.Errorf("cache database corrupted at %s (try deleting it with: rm -rf %s): %w",
+	if err := s.storeSchemaVersion(); err != nil {
+		s.db.Close()
+		s.db = nil
+		if errors.Is(err, ErrUnsupportedSchema) {
+			return nil, fmt.Errorf("cache at %s was written by a newer or unknown version of gdu (delete it with: rm -rf %s): %w",
 				s.storagePath, s.storagePath, err)
 		}
+		return nil, fmt.Errorf("failed to write schema version to cache at %s: %w", s.storagePath, err)
+	}
 
-		// Concurrent access (another process using the database)
-		if strings.Contains(errMsg, "Another process is using this Badger database") ||
-			strings.Contains(errMsg, "Cannot acquire directory lock") ||
-			strings.Contains(errMsg, "resource temporarily unavailable") {
-			return nil, fmt.Errorf("cache database at %s is locked by another gdu process: %w",
-				s.storagePath, err)
-		}
+	return s.closeFunc(""), nil
+}
 
-		// Directory doesn't exist
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("cache directory does not exist at %s (create it with: mkdir -p %s): %w",
-				s.storagePath, s.storagePath, err)
-		}
+// openError describes error of opening the database with hints for common issues
+func (s *IncrementalStorage) openError(err error) error {
+	// Provide specific error messages for common issues
+	errMsg := err.Error()
 
-		// Generic error with helpful context
-		return nil, fmt.Errorf("failed to open cache database at %s: %w", s.storagePath, err)
+	// Permission denied
+	if os.IsPermission(err) {
+		return fmt.Errorf("permission denied opening cache at %s: %w", s.storagePath, err)
 	}
 
-	s.db = db
+	// Disk space issues
+	if strings.Contains(errMsg, "no space left") || strings.Contains(errMsg, "disk full") {
+		return fmt.Errorf("insufficient disk space for cache at %s: %w", s.storagePath, err)
+	}
 
-	return func() {
-		s.m.Lock()
-		defer s.m.Unlock()
-		if s.db != nil {
-			s.db.Close()
-			s.db = nil
-		}
-	}, nil
+	// Database corruption or version mismatch
+	if strings.Contains(errMsg, "corrupted") ||
+		strings.Contains(errMsg, "invalid") ||
+		strings.Contains(errMsg, "checksum") ||
+		strings.Contains(errMsg, "manifest") {
+		return fmt.Errorf("cache database corrupted at %s (try deleting it with: rm -rf %s): %w",
+			s.storagePath, s.storagePath, err)
+	}
+
+	// Concurrent access (another process using the database)
+	if strings.Contains(errMsg, "Another process is using this Badger database") ||
+		strings.Contains(errMsg, "Cannot acquire directory lock") ||
+		strings.Contains(errMsg, "resource temporarily unavailable") {
+		return fmt.Errorf("cache database at %s is locked by another gdu process: %w",
+			s.storagePath, err)
+	}
+
+	// Directory doesn't exist
+	if os.IsNotExist(err) {
+		return fmt.Errorf("cache directory does not exist at %s (create it with: mkdir -p %s): %w",
+			s.storagePath, s.storagePath, err)
+	}
+
+	// Generic error with helpful context
+	return fmt.Errorf("failed to open cache database at %s: %w", s.storagePath, err)
 }
 
-// StoreDirMetadata stores directory metadata in cache
+// StoreDirMetadata stores directory metadata in cache.
+// Children of directories with more than DefaultDirPageSize children are stored in pages
 func (s *IncrementalStorage) StoreDirMetadata(meta *IncrementalDirMetadata) error {
 	s.checkCount()
 	s.m.RLock()
@@ -140,21 +212,71 @@ func (s *IncrementalStorage) StoreDirMetadata(meta *IncrementalDirMetadata) erro
 	if s.db == nil {
 		return fmt.Errorf("storage is not open")
 	}
+	defer s.timers.writes.since(time.Now())
 
-	return s.db.Update(func(txn *badger.Txn) error {
-		b := &bytes.Buffer{}
-		enc := gob.NewEncoder(b)
-		err := enc.Encode(meta)
+	encoded, err := s.encodeDirEntry(meta)
+	if err != nil {
+		return err
+	}
+
+	if len(encoded.pages) > 0 {
+		err = s.writeDirEntries([]*encodedDirEntry{encoded})
+	} else {
+		err = s.db.Update(func(txn *badger.Txn) error {
+			stale, err := stalePageKeys(txn, meta.Path, 1)
+			if err != nil {
+				return err
+			}
+			for _, key := range stale {
+				if err := txn.Delete(key); err != nil {
+					return err
+				}
+			}
+			return txn.Set(s.makeKey(meta.Path), encoded.entry)
+		})
+	}
+	if err == nil {
+		s.publishWrite(meta.Path)
+	}
+	return err
+}
+
+// StoreDirMetadataBatch stores metadata of several directories at once
+func (s *IncrementalStorage) StoreDirMetadataBatch(metas []*IncrementalDirMetadata) error {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.db == nil {
+		return fmt.Errorf("storage is not open")
+	}
+
+	entries := make([]*encodedDirEntry, 0, len(metas))
+	for _, meta := range metas {
+		encoded, err := s.encodeDirEntry(meta)
 		if err != nil {
-			return errors.Wrap(err, "encoding directory metadata")
+			return err
 		}
+		entries = append(entries, encoded)
+	}
+	if err := s.writeDirEntries(entries); err != nil {
+		return err
+	}
+	for _, meta := range metas {
+		s.publishWrite(meta.Path)
+	}
+	return nil
+}
 
-		key := s.makeKey(meta.Path)
-		return txn.Set(key, b.Bytes())
-	})
+// publishWrite notifies subscribers of the storage and of the analyzer about written entry
+func (s *IncrementalStorage) publishWrite(path string) {
+	s.events.publish(path)
+	if s.forward != nil {
+		s.forward.publish(path)
+	}
 }
 
-// LoadDirMetadata loads directory metadata from cache with error handling
+// LoadDirMetadata loads directory metadata from cache with error handling.
+// Children stored in pages are read into Files
 func (s *IncrementalStorage) LoadDirMetadata(path string) (*IncrementalDirMetadata, error) {
 	s.checkCount()
 	s.m.RLock()
@@ -163,38 +285,66 @@ func (s *IncrementalStorage) LoadDirMetadata(path string) (*IncrementalDirMetada
 	if s.db == nil {
 		return nil, fmt.Errorf("storage is not open")
 	}
+	defer s.timers.reads.since(time.Now())
 
-	var meta IncrementalDirMetadata
+	var meta *IncrementalDirMetadata
 
 	err := s.db.View(func(txn *badger.Txn) error {
-		key := s.makeKey(path)
-		item, err := txn.Get(key)
+		var err error
+		meta, err = s.loadDirEntry(txn, path)
 		if err != nil {
-			return errors.Wrap(err, "reading cached metadata for path: "+path)
+			return err
 		}
 
-		return item.Value(func(val []byte) error {
-			b := bytes.NewBuffer(val)
-			dec := gob.NewDecoder(b)
-			decodeErr := dec.Decode(&meta)
-			if decodeErr != nil {
-				// Corrupted cache entry - wrap with context
-				return fmt.Errorf("corrupted cache entry for %s (will rescan): %w", path, decodeErr)
+		if meta.Pages > 0 {
+			meta.Files = make([]FileMetadata, 0, meta.Pages*s.dirPageSize())
+		}
+		for page := 1; page <= meta.Pages; page++ {
+			files, err := loadDirPage(txn, path, page)
+			if err != nil {
+				return err
 			}
-			return nil
-		})
+			meta.Files = append(meta.Files, files...)
+		}
+
+		// Children with the same name share the key of their entry, one of them was lost
+		if name, ok := duplicateChild(meta.Files); ok {
+			return &CorruptedEntryError{Path: path, Reason: "duplicate child " + name}
+		}
+		return nil
 	})
 
+	if err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// loadDirEntry reads and validates the entry of directory at path, its pages are not read
+func (s *IncrementalStorage) loadDirEntry(txn *badger.Txn, path string) (*IncrementalDirMetadata, error) {
+	item, err := txn.Get(s.makeKey(path))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading cached metadata for path: "+path)
+	}
+
+	var meta *IncrementalDirMetadata
+	err = item.Value(func(val []byte) error {
+		defer s.timers.decodes.since(time.Now())
+		meta, err = decodeDirMetadata(path, val)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	// Validate the loaded metadata
 	if meta.Path == "" {
-		return nil, fmt.Errorf("invalid cache entry for %s: empty path", path)
+		return nil, &CorruptedEntryError{Path: path, Reason: "empty path"}
 	}
-
-	return &meta, nil
+	if meta.Path != path {
+		return nil, &KeyCollisionError{Path: path, StoredPath: meta.Path}
+	}
+	return meta, nil
 }
 
 // DeleteDirMetadata removes directory metadata from cache
@@ -207,6 +357,11 @@ func (s *IncrementalStorage) DeleteDirMetadata(path string) error {
 	}
 
 	return s.db.Update(func(txn *badger.Txn) error {
+		for _, key := range allPageKeys(txn, path) {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
 		key := s.makeKey(path)
 		return txn.Delete(key)
 	})
@@ -214,7 +369,153 @@ func (s *IncrementalStorage) DeleteDirMetadata(path string) error {
 
 // makeKey creates a BadgerDB key for a given path
 func (s *IncrementalStorage) makeKey(path string) []byte {
-	return []byte(fmt.Sprintf("incr:%s", path))
+	return []byte(KeyPrefixDirMetadata + path)
+}
+
+// historyKey creates a key of scan history record of given top directory and generation.
+// Generation is zero padded so that the keys are iterated in order
+func historyKey(topDir string, gen uint64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", historyPrefix(topDir), gen))
+}
+
+// historyPrefix is the prefix of keys of scan history records of given top directory.
+// The path is terminated so that history of its subdirectories is not included
+func historyPrefix(topDir string) []byte {
+	return []byte(KeyPrefixHistory + topDir + "\x00")
+}
+
+// rootSummaryKey creates a key of summary record of given top directory
+func rootSummaryKey(topDir string) []byte {
+	return []byte(KeyPrefixRootSummary + topDir)
+}
+
+// markerKey creates a key of the scan-in-progress marker
+func markerKey() []byte {
+	return []byte(KeyPrefixMarker + "scan")
+}
+
+// schemaKey creates a key of the schema version record
+func schemaKey() []byte {
+	return []byte(KeyPrefixSchema + "version")
+}
+
+// storeSchemaVersion writes current schema version unless it is stored already.
+// Older layouts are upgraded, their entries are readable and rewritten by the next scans.
+// A newer or unknown version is refused with ErrUnsupportedSchema instead of being overwritten
+func (s *IncrementalStorage) storeSchemaVersion() error {
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version == IncrementalSchemaVersion {
+		return nil
+	}
+	if version > IncrementalSchemaVersion || version < 1 {
+		return fmt.Errorf("%w: %d, this gdu supports up to %d", ErrUnsupportedSchema, version, IncrementalSchemaVersion)
+	}
+	log.Printf("Upgrading cache at %s from schema version %d to %d", s.storagePath, version, IncrementalSchemaVersion)
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(schemaKey(), []byte(strconv.Itoa(IncrementalSchemaVersion)))
+	})
+}
+
+// SchemaVersion returns version of the cache layout.
+// Caches created before the version was stored report 1,
+// a version which is not a number is reported as ErrUnsupportedSchema
+func (s *IncrementalStorage) SchemaVersion() (int, error) {
+	version := 1
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(schemaKey())
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			version, err = strconv.Atoi(string(val))
+			if err != nil {
+				return fmt.Errorf("%w: %q", ErrUnsupportedSchema, val)
+			}
+			return nil
+		})
+	})
+	return version, err
+}
+
+// Iterate calls fn for every key starting with prefix (one of the KeyPrefix* namespaces,
+// optionally followed by more specific part) in key order.
+// The key and value are valid only during the call of fn.
+// Iteration stops at first error returned by fn
+func (s *IncrementalStorage) Iterate(prefix string, fn func(key, value []byte) error) error {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.db == nil {
+		return fmt.Errorf("storage is not open")
+	}
+
+	return s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		p := []byte(prefix)
+		for it.Seek(p); it.ValidForPrefix(p); it.Next() {
+			item := it.Item()
+			err := item.Value(func(val []byte) error {
+				return fn(item.Key(), val)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteSubtree removes metadata of directory at path and of all its descendants.
+// Other namespaces are not touched. It returns number of removed entries
+func (s *IncrementalStorage) DeleteSubtree(path string) (int, error) {
+	keys, err := s.subtreeKeys(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.deleteKeys(keys)
+}
+
+// subtreeKeys returns keys of metadata of directory at path and of all its descendants
+func (s *IncrementalStorage) subtreeKeys(path string) ([][]byte, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.db == nil {
+		return nil, fmt.Errorf("storage is not open")
+	}
+
+	keys := make([][]byte, 0)
+	err := s.matchingKeys(s.subtreeMatcher(path), func(item *badger.Item) {
+		keys = append(keys, item.KeyCopy(nil))
+	})
+	return keys, err
+}
+
+// checkClearable returns error if the cache can't be cleared now, s.m must be held
+func (s *IncrementalStorage) checkClearable() error {
+	if s.db == nil {
+		return fmt.Errorf("storage is not open")
+	}
+	if s.scanning.Load() {
+		return ErrBusy
+	}
+	return nil
+}
+
+// resetCount restarts counting of operations between value log GC runs
+func (s *IncrementalStorage) resetCount() {
+	s.counterM.Lock()
+	defer s.counterM.Unlock()
+	s.counter = 0
 }
 
 // checkCount manages garbage collection based on operation count
@@ -235,12 +536,48 @@ func (s *IncrementalStorage) checkCount() {
 	}
 }
 
-// ClearCache removes all cached entries
-func (s *IncrementalStorage) ClearCache() error {
-	s.m.RLock()
-	defer s.m.RUnlock()
+// ClearCache removes all cached entries and returns their number.
+// It waits for running operations of the storage to finish
+// and returns ErrBusy if a scan using the storage is running
+func (s *IncrementalStorage) ClearCache() (int, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if err := s.checkClearable(); err != nil {
+		return 0, err
+	}
 
-	return s.db.DropAll()
+	count := 0
+	err := s.matchingKeys(clearMatcher(), func(*badger.Item) {
+		count++
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.db.DropAll(); err != nil {
+		return 0, err
+	}
+	s.resetCount()
+	return count, s.storeSchemaVersion()
+}
+
+// ClearCachePreservingHistory removes all cached entries except the scan history and annotations.
+// It returns ErrBusy if a scan using the storage is running
+func (s *IncrementalStorage) ClearCachePreservingHistory() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if err := s.checkClearable(); err != nil {
+		return err
+	}
+	defer s.resetCount()
+
+	prefixes := make([][]byte, 0)
+	for _, prefix := range clearPreservingHistoryMatcher().prefixes {
+		prefixes = append(prefixes, []byte(prefix))
+	}
+	return s.db.DropPrefix(prefixes...)
 }
 
 // GetCacheSize returns the approximate size of the cache in bytes
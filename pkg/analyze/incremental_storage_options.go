@@ -0,0 +1,78 @@
+package analyze
+
+import "github.com/dgraph-io/badger/v3"
+
+// StorageOptions tune the memory used by the database of the incremental cache.
+// Zero values keep the defaults of BadgerDB, which suit servers: the memtables and
+// the block cache alone take several hundred MB once the cache is open.
+// Smaller values let gdu run on small devices (e.g. a NAS with 1 GB of RAM), for the price
+// of more frequent flushes and compactions while writing and more disk reads while loading
+type StorageOptions struct {
+	// MemTableSize is the size of one memtable collecting writes before they are flushed
+	// to disk (BadgerDB keeps up to 5 of them, 64 MiB each by default).
+	// Smaller memtables are flushed more often, so scans writing many entries get slower
+	MemTableSize int64
+
+	// BlockCacheSize is the size of the cache of decompressed blocks of the tables (256 MiB by default).
+	// A smaller cache means more disk reads and decompression when loading entries
+	BlockCacheSize int64
+
+	// IndexCacheSize bounds the memory used by indexes and bloom filters of the tables,
+	// all of them are kept in memory by default. With a bound, indexes are read from disk again
+	// when evicted, which slows down lookups in big caches
+	IndexCacheSize int64
+
+	// NumCompactors is the number of concurrent compaction workers (4 by default, at least 2).
+	// Fewer workers use less memory and CPU, but the tables are merged slower,
+	// so the cache takes more space on disk between compactions
+	NumCompactors int
+
+	// LowMemory selects the conservative values of LowMemoryStorageOptions
+	// (and fewer memtables) for the options which are not set explicitly
+	LowMemory bool
+}
+
+// LowMemoryStorageOptions are the values used by StorageOptions.LowMemory,
+// they keep the memory of the open cache in tens of MB
+var LowMemoryStorageOptions = StorageOptions{
+	MemTableSize:   8 << 20,
+	BlockCacheSize: 16 << 20,
+	IndexCacheSize: 8 << 20,
+	NumCompactors:  2,
+}
+
+// lowMemoryMemtables is the number of memtables kept by StorageOptions.LowMemory
+const lowMemoryMemtables = 2
+
+// apply returns options of the database tuned by o
+func (o StorageOptions) apply(options badger.Options) badger.Options {
+	if o.LowMemory {
+		options = LowMemoryStorageOptions.apply(options).WithNumMemtables(lowMemoryMemtables)
+	}
+	if o.MemTableSize > 0 {
+		options = options.WithMemTableSize(o.MemTableSize)
+	}
+	if o.BlockCacheSize > 0 {
+		options = options.WithBlockCacheSize(o.BlockCacheSize)
+	}
+	if o.IndexCacheSize > 0 {
+		options = options.WithIndexCacheSize(o.IndexCacheSize)
+	}
+	if o.NumCompactors > 0 {
+		// BadgerDB refuses to open with a single compactor
+		options = options.WithNumCompactors(max(o.NumCompactors, 2))
+	}
+	return options
+}
+
+// SetStorageOptions sets the options applied when the database is opened
+func (s *IncrementalStorage) SetStorageOptions(opts StorageOptions) {
+	s.options = opts
+}
+
+// badgerOptions returns options of the database opened for writing
+func (s *IncrementalStorage) badgerOptions() badger.Options {
+	options := badger.DefaultOptions(s.storagePath)
+	options.Logger = nil
+	return s.options.apply(options)
+}
@@ -0,0 +1,60 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/dundee/gdu/v5/internal/testdir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageOptions(t *testing.T) {
+	defaults := badger.DefaultOptions(t.TempDir())
+
+	storage := NewIncrementalStorage(t.TempDir(), "/")
+	options := storage.badgerOptions()
+	assert.Equal(t, defaults.MemTableSize, options.MemTableSize)
+	assert.Equal(t, defaults.BlockCacheSize, options.BlockCacheSize)
+	assert.Equal(t, defaults.IndexCacheSize, options.IndexCacheSize)
+	assert.Equal(t, defaults.NumCompactors, options.NumCompactors)
+	assert.Nil(t, options.Logger)
+
+	storage.SetStorageOptions(StorageOptions{LowMemory: true})
+	options = storage.badgerOptions()
+	assert.Equal(t, int64(8<<20), options.MemTableSize)
+	assert.Equal(t, int64(16<<20), options.BlockCacheSize)
+	assert.Equal(t, int64(8<<20), options.IndexCacheSize)
+	assert.Equal(t, 2, options.NumCompactors)
+	assert.Equal(t, 2, options.NumMemtables)
+
+	// explicit values win over the preset
+	storage.SetStorageOptions(StorageOptions{LowMemory: true, MemTableSize: 32 << 20, NumCompactors: 1})
+	options = storage.badgerOptions()
+	assert.Equal(t, int64(32<<20), options.MemTableSize)
+	assert.Equal(t, int64(16<<20), options.BlockCacheSize)
+	assert.Equal(t, 2, options.NumCompactors) // a single compactor is not allowed
+}
+
+func TestIncrementalAnalyzer_LowMemory(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	opts := IncrementalOptions{StoragePath: t.TempDir(), StorageOptions: StorageOptions{LowMemory: true}}
+	for _, hits := range []int64{0, 1} {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		dir := analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+
+		assert.Equal(t, ScanCompleted, analyzer.GetScanResult().Status)
+		assert.Equal(t, 5, dir.ItemCount)
+		assert.Equal(t, hits, analyzer.GetCacheStats().CacheHits)
+		assert.True(t, analyzer.storage.options.LowMemory)
+	}
+
+	storage := NewIncrementalStorage(opts.StoragePath, "test_dir")
+	storage.SetStorageOptions(opts.StorageOptions)
+	closeFn, err := storage.OpenReadOnly()
+	assert.NoError(t, err)
+	defer closeFn()
+	assert.Equal(t, int64(8<<20), storage.db.Opts().MemTableSize)
+}
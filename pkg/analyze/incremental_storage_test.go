@@ -1,23 +1,36 @@
 package analyze
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/dgraph-io/badger/v3"
 	"github.com/stretchr/testify/assert"
 )
 
-// TestIncrementalStorage_StoreLoad verifies basic store and load operations
-func TestIncrementalStorage_StoreLoad(t *testing.T) {
-	tmpDir := t.TempDir()
-	storage := NewIncrementalStorage(tmpDir, "/test/path")
-
+// mustOpen opens the storage and fails the test if it cannot.
+// The storage is closed at the end of the test unless the returned function closes it earlier
+func mustOpen(t testing.TB, storage *IncrementalStorage) CloseFunc {
+	t.Helper()
 	closeFn, err := storage.Open()
 	if err != nil {
 		t.Fatalf("Failed to open storage: %v", err)
 	}
-	defer closeFn()
+	t.Cleanup(closeFn)
+	return closeFn
+}
+
+// TestIncrementalStorage_StoreLoad verifies basic store and load operations
+func TestIncrementalStorage_StoreLoad(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage := NewIncrementalStorage(tmpDir, "/test/path")
+	mustOpen(t, storage)
 
 	// Create test metadata
 	meta := &IncrementalDirMetadata{
@@ -52,7 +65,7 @@ func TestIncrementalStorage_StoreLoad(t *testing.T) {
 	}
 
 	// Store metadata
-	err = storage.StoreDirMetadata(meta)
+	err := storage.StoreDirMetadata(meta)
 	assert.NoError(t, err, "Should store metadata without error")
 
 	// Load metadata
@@ -242,6 +255,22 @@ func TestIncrementalStorage_IsOpen(t *testing.T) {
 	assert.False(t, storage.IsOpen(), "Should not be open after close")
 }
 
+// TestIncrementalStorage_CloseTwice verifies that a stale close function
+// does not close the database opened again
+func TestIncrementalStorage_CloseTwice(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/test/path")
+
+	closeFn := mustOpen(t, storage)
+	closeFn()
+	closeFn()
+	assert.False(t, storage.IsOpen())
+
+	mustOpen(t, storage)
+	closeFn()
+	assert.True(t, storage.IsOpen(), "Stale close function should not close the reopened storage")
+	assert.NoError(t, storage.StoreDirMetadata(&IncrementalDirMetadata{Path: "/test/path"}))
+}
+
 // TestIncrementalStorage_GetTopDir verifies top directory retrieval
 func TestIncrementalStorage_GetTopDir(t *testing.T) {
 	storage := NewIncrementalStorage("/tmp/cache", "/home/user/data")
@@ -278,14 +307,192 @@ func TestIncrementalStorage_ClearCache(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Clear cache
-	err = storage.ClearCache()
+	count, err := storage.ClearCache()
 	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
 
 	// Verify it's gone
 	_, err = storage.LoadDirMetadata("/test/path/clear")
 	assert.Error(t, err)
 }
 
+// TestIncrementalStorage_ClearCachePreservingHistory verifies that only history survives clearing
+func TestIncrementalStorage_ClearCachePreservingHistory(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage := NewIncrementalStorage(tmpDir, "/test/path")
+
+	closeFn, err := storage.Open()
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer closeFn()
+
+	err = storage.StoreDirMetadata(&IncrementalDirMetadata{Path: "/test/path", CachedAt: time.Now()})
+	assert.NoError(t, err)
+	err = storage.db.Update(func(txn *badger.Txn) error {
+		for _, key := range [][]byte{
+			historyKey("/test/path", 1), historyKey("/test/path", 2), rootSummaryKey("/test/path"), markerKey(),
+		} {
+			if err := txn.Set(key, []byte("x")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	err = storage.ClearCachePreservingHistory()
+	assert.NoError(t, err)
+
+	_, err = storage.LoadDirMetadata("/test/path")
+	assert.Error(t, err, "Metadata should be dropped")
+
+	keys := make([]string, 0)
+	err = storage.Iterate("", func(key, _ []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		string(historyKey("/test/path", 1)),
+		string(historyKey("/test/path", 2)),
+		string(schemaKey()),
+	}, keys)
+
+	version, err := storage.SchemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, IncrementalSchemaVersion, version)
+}
+
+// TestIncrementalStorage_IterateNamespace verifies that iteration stays within a namespace
+func TestIncrementalStorage_IterateNamespace(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage := NewIncrementalStorage(tmpDir, "/test")
+
+	closeFn, err := storage.Open()
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer closeFn()
+
+	for _, path := range []string{"/test", "/test/a", "/test/b"} {
+		err = storage.StoreDirMetadata(&IncrementalDirMetadata{Path: path, CachedAt: time.Now()})
+		assert.NoError(t, err)
+	}
+	err = storage.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(rootSummaryKey("/test"), []byte("x"))
+	})
+	assert.NoError(t, err)
+
+	paths := make([]string, 0)
+	err = storage.Iterate(KeyPrefixDirMetadata, func(key, _ []byte) error {
+		paths = append(paths, strings.TrimPrefix(string(key), KeyPrefixDirMetadata))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/test", "/test/a", "/test/b"}, paths)
+
+	stop := errors.New("stop")
+	count := 0
+	err = storage.Iterate(KeyPrefixDirMetadata, func(_, _ []byte) error {
+		count++
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, count)
+}
+
+// TestIncrementalStorage_DeleteSubtree verifies removal of a directory and its descendants
+func TestIncrementalStorage_DeleteSubtree(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage := NewIncrementalStorage(tmpDir, "/test")
+
+	closeFn, err := storage.Open()
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer closeFn()
+
+	for _, path := range []string{"/test", "/test/a", "/test/a/b", "/test/ab"} {
+		err = storage.StoreDirMetadata(&IncrementalDirMetadata{Path: path, CachedAt: time.Now()})
+		assert.NoError(t, err)
+	}
+
+	deleted, err := storage.DeleteSubtree("/test/a")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	_, err = storage.LoadDirMetadata("/test/a/b")
+	assert.Error(t, err)
+	_, err = storage.LoadDirMetadata("/test/ab")
+	assert.NoError(t, err, "Sibling with common name prefix must be kept")
+	_, err = storage.LoadDirMetadata("/test")
+	assert.NoError(t, err)
+}
+
+// TestIncrementalStorage_SchemaVersion verifies that schema version is stored on open
+func TestIncrementalStorage_SchemaVersion(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/test")
+
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	defer closeFn()
+
+	version, err := storage.SchemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, IncrementalSchemaVersion, version)
+
+	_, err = storage.ClearCache()
+	assert.NoError(t, err)
+	version, err = storage.SchemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, IncrementalSchemaVersion, version)
+}
+
+// TestIncrementalStorage_SchemaVersionUnsupported verifies that a newer or unknown layout is not overwritten
+func TestIncrementalStorage_SchemaVersionUnsupported(t *testing.T) {
+	storeVersion := func(t *testing.T, storage *IncrementalStorage, version string) {
+		closeFn, err := storage.Open()
+		assert.NoError(t, err)
+		assert.NoError(t, storage.db.Update(func(txn *badger.Txn) error {
+			return txn.Set(schemaKey(), []byte(version))
+		}))
+		closeFn()
+	}
+
+	for _, version := range []string{strconv.Itoa(IncrementalSchemaVersion + 1), "0", "unknown"} {
+		t.Run(version, func(t *testing.T) {
+			storage := NewIncrementalStorage(t.TempDir(), "/test")
+			storeVersion(t, storage, version)
+
+			_, err := storage.Open()
+			assert.ErrorIs(t, err, ErrUnsupportedSchema)
+			assert.False(t, storage.IsOpen())
+
+			closeFn, err := storage.OpenReadOnly()
+			assert.NoError(t, err)
+			defer closeFn()
+			assert.NoError(t, storage.db.View(func(txn *badger.Txn) error {
+				item, err := txn.Get(schemaKey())
+				assert.NoError(t, err)
+				value, err := item.ValueCopy(nil)
+				assert.Equal(t, version, string(value), "the stored version is kept")
+				return err
+			}))
+		})
+	}
+
+	// an older layout is upgraded
+	storage := NewIncrementalStorage(t.TempDir(), "/test")
+	storeVersion(t, storage, "3")
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	defer closeFn()
+	version, err := storage.SchemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, IncrementalSchemaVersion, version)
+}
+
 // TestIncrementalStorage_GetCacheSize verifies cache size calculation
 func TestIncrementalStorage_GetCacheSize(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -506,8 +713,7 @@ func TestIncrementalStorage_ReopenDatabase(t *testing.T) {
 	storage1 := NewIncrementalStorage(tmpDir, "/test/path")
 
 	// First session
-	closeFn1, err := storage1.Open()
-	assert.NoError(t, err)
+	closeFn1 := mustOpen(t, storage1)
 	meta := &IncrementalDirMetadata{
 		Path:      "/test/path/persist",
 		Mtime:     time.Now(),
@@ -518,15 +724,13 @@ func TestIncrementalStorage_ReopenDatabase(t *testing.T) {
 		Files:     []FileMetadata{},
 		CachedAt:  time.Now(),
 	}
-	err = storage1.StoreDirMetadata(meta)
+	err := storage1.StoreDirMetadata(meta)
 	assert.NoError(t, err)
 	closeFn1()
 
 	// Second session (new storage instance, same path)
 	storage2 := NewIncrementalStorage(tmpDir, "/test/path")
-	closeFn2, err := storage2.Open()
-	assert.NoError(t, err)
-	defer closeFn2()
+	mustOpen(t, storage2)
 
 	// Verify data persisted
 	loaded, err := storage2.LoadDirMetadata("/test/path/persist")
@@ -687,3 +891,70 @@ func TestIncrementalStorage_NonExistentDirectory(t *testing.T) {
 	_, statErr := os.Stat(storagePath)
 	assert.NoError(t, statErr, "BadgerDB should create storage directory")
 }
+
+// TestIncrementalStorage_ClearCacheBusy verifies that the cache can't be cleared during a scan
+func TestIncrementalStorage_ClearCacheBusy(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/test")
+
+	_, err := storage.ClearCache()
+	assert.EqualError(t, err, "storage is not open")
+
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	defer closeFn()
+
+	assert.NoError(t, storage.MarkScanStarted())
+	_, err = storage.ClearCache()
+	assert.ErrorIs(t, err, ErrBusy)
+	assert.ErrorIs(t, storage.ClearCachePreservingHistory(), ErrBusy)
+
+	assert.NoError(t, storage.MarkScanFinished())
+	count, err := storage.ClearCache()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.NoError(t, storage.ClearCachePreservingHistory())
+}
+
+// TestIncrementalStorage_ClearCacheConcurrent verifies clearing while other goroutines store entries
+func TestIncrementalStorage_ClearCacheConcurrent(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/test")
+
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	defer closeFn()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				meta := &IncrementalDirMetadata{
+					Path:     fmt.Sprintf("/test/%d/%d", w, i),
+					Mtime:    time.Now(),
+					Files:    []FileMetadata{},
+					CachedAt: time.Now(),
+				}
+				assert.NoError(t, storage.StoreDirMetadata(meta))
+				// the entry may be already cleared
+				storage.LoadDirMetadata(meta.Path) //nolint:errcheck
+			}
+		}(w)
+	}
+
+	for i := 0; i < 10; i++ {
+		_, err := storage.ClearCache()
+		assert.NoError(t, err)
+	}
+	wg.Wait()
+
+	_, err = storage.ClearCache()
+	assert.NoError(t, err)
+
+	count, err := storage.ClearCache()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	version, err := storage.SchemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, IncrementalSchemaVersion, version)
+}
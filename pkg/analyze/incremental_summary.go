@@ -0,0 +1,188 @@
+package analyze
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/dundee/gdu/v5/pkg/fs"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	gob.RegisterName("analyze.RootSummary", &RootSummary{})
+}
+
+// RootSummary is stored for the scanned top directory when its scan finishes
+type RootSummary struct {
+	Path       string
+	Mtime      time.Time  // mtime of the directory stored in its cache entry
+	Status     ScanStatus // how the scan finished
+	FinishedAt time.Time
+	Generation uint64 // number of the scans of the directory which stored the summary
+	Label      string // label of the scan (see IncrementalOptions.ScanLabel)
+}
+
+// StoreRootSummary stores summary of the scan of the top directory
+func (s *IncrementalStorage) StoreRootSummary(summary *RootSummary) error {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.db == nil {
+		return fmt.Errorf("storage is not open")
+	}
+
+	b := &bytes.Buffer{}
+	if err := gob.NewEncoder(b).Encode(summary); err != nil {
+		return fmt.Errorf("encoding root summary: %w", err)
+	}
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(rootSummaryKey(summary.Path), b.Bytes())
+	})
+}
+
+// LoadRootSummary returns summary of the last scan of the top directory at path,
+// nil if there is none
+func (s *IncrementalStorage) LoadRootSummary(path string) (*RootSummary, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.db == nil {
+		return nil, fmt.Errorf("storage is not open")
+	}
+
+	var summary *RootSummary
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(rootSummaryKey(path))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			summary = &RootSummary{}
+			return gob.NewDecoder(bytes.NewBuffer(val)).Decode(summary)
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading root summary of %s: %w", path, err)
+	}
+	return summary, nil
+}
+
+// storeRootSummary records how the scan of the top directory at path finished
+func (a *IncrementalAnalyzer) storeRootSummary(path string, result *ScanResult) {
+	summary := &RootSummary{
+		Path: path, Status: result.Status, FinishedAt: time.Now(), Generation: 1, Label: a.scanLabel,
+	}
+	if previous, err := a.storage.LoadRootSummary(path); err == nil && previous != nil {
+		summary.Generation = previous.Generation + 1
+	}
+	if meta, err := a.storage.LoadDirMetadata(path); err == nil {
+		summary.Mtime = meta.Mtime
+	} else if result.Status == ScanCompleted {
+		// the tree can't be trusted without the entry of the top directory
+		summary.Status = ScanCompletedWithErrors
+	}
+
+	if err := a.storage.StoreRootSummary(summary); err != nil {
+		a.stats.IncrementCacheErrors()
+		log.Printf("Warning: Failed to store root summary of %s: %v", path, err)
+		return
+	}
+	result.Generation = summary.Generation
+}
+
+// summaryHit returns the top directory at path built only from its own cache entry
+// if mtime of the directory matches the summary of the previous scan, which completed cleanly.
+// Subdirectories hold only their totals. It returns nil if the summary can't be used
+func (a *IncrementalAnalyzer) summaryHit(path string) *Dir {
+	if _, crashed, err := a.storage.ScanMarker(); err != nil || crashed {
+		return nil
+	}
+	summary, err := a.storage.LoadRootSummary(path)
+	if err != nil || summary == nil || summary.Status != ScanCompleted {
+		return nil
+	}
+	stat, err := os.Stat(path)
+	if err != nil || !stat.ModTime().Equal(summary.Mtime) {
+		return nil
+	}
+	cached, err := a.storage.LoadDirMetadata(path)
+	if err != nil || !cached.Mtime.Equal(summary.Mtime) || cached.Estimate != nil ||
+		cached.Fingerprint != a.fingerprint {
+		return nil
+	}
+
+	return a.accountDir(&scanBranch{}, path, func() (*Dir, CacheDecision, uint64) {
+		a.traceDecision(path, DecisionSummary, cached, stat)
+		a.stats.SetSummaryHit()
+		a.stats.IncrementCacheHits()
+		a.stats.IncrementTotalDirs()
+		a.stats.AddBytesFromCache(cached.Size)
+		return a.summaryDir(cached), DecisionSummary, a.deviceOf(stat)
+	})
+}
+
+// summaryDir returns the directory with its direct children read from its cache entry.
+// Subdirectories are not loaded, they hold only the totals stored in the entry
+func (a *IncrementalAnalyzer) summaryDir(cached *IncrementalDirMetadata) *Dir {
+	dir := &Dir{
+		File: &File{
+			Name:  filepath.Base(cached.Path),
+			Size:  cached.Size,
+			Usage: cached.Usage,
+			Mtime: cached.Mtime,
+			Btime: cached.Btime,
+			Flag:  cached.Flag,
+		},
+		BasePath:   filepath.Dir(cached.Path),
+		ItemCount:  cached.ItemCount,
+		ErrorCount: cached.ErrorCount,
+		Files:      make(fs.Files, 0, len(cached.Files)),
+
+		SymlinkCount:       cached.SymlinkCount,
+		BrokenSymlinkCount: cached.BrokenSymlinkCount,
+
+		SelfSize:  cached.SelfSize,
+		SelfUsage: cached.SelfUsage,
+	}
+	parent := &ParentDir{Path: cached.Path}
+
+	byName := func(i, j int) bool { return cached.Files[i].Name < cached.Files[j].Name }
+	if !a.unsorted && !sort.SliceIsSorted(cached.Files, byName) {
+		sort.Slice(cached.Files, byName)
+	}
+
+	for _, fileMeta := range cached.Files {
+		file := &File{
+			Name:   fileMeta.Name,
+			Size:   fileMeta.Size,
+			Usage:  fileMeta.Usage,
+			Mtime:  fileMeta.Mtime,
+			Btime:  fileMeta.Btime,
+			Flag:   fileMeta.Flag,
+			Mli:    fileMeta.Mli,
+			Parent: parent,
+			owner:  fileMeta.Owner,
+		}
+		if !fileMeta.IsDir {
+			dir.AddFile(file)
+			continue
+		}
+		dir.AddFile(&Dir{
+			File:      file,
+			BasePath:  cached.Path,
+			ItemCount: max(fileMeta.ItemCount, 1),
+			Files:     make(fs.Files, 0),
+		})
+	}
+	return dir
+}
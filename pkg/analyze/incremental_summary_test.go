@@ -0,0 +1,125 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalStorage_RootSummary(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/test")
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	defer closeFn()
+
+	summary, err := storage.LoadRootSummary("/test")
+	assert.NoError(t, err)
+	assert.Nil(t, summary)
+
+	mtime := time.Now().Truncate(time.Second)
+	assert.NoError(t, storage.StoreRootSummary(&RootSummary{Path: "/test", Mtime: mtime, Status: ScanCompletedWithErrors}))
+	summary, err = storage.LoadRootSummary("/test")
+	assert.NoError(t, err)
+	assert.True(t, mtime.Equal(summary.Mtime))
+	assert.Equal(t, ScanCompletedWithErrors, summary.Status)
+}
+
+func TestIncrementalAnalyzer_SummaryHit(t *testing.T) {
+	root := createTraceFixture(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir(), TrustRootMtime: true}
+	scan := func() (*Dir, *IncrementalAnalyzer) {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		return dir, analyzer
+	}
+
+	// cold scan walks the tree and stores the summary
+	cold, analyzer := scan()
+	assert.False(t, analyzer.GetCacheStats().SummaryHit)
+	assert.Equal(t, ScanCompleted, analyzer.GetScanResult().Status)
+
+	// warm scan returns the top directory only
+	opts.TraceDecisions = true
+	warm, analyzer := scan()
+	stats := analyzer.GetCacheStats()
+	assert.True(t, stats.SummaryHit)
+	assert.Equal(t, int64(1), stats.TotalDirs)
+	assert.Equal(t, ScanCompleted, analyzer.GetScanResult().Status)
+	entries := analyzer.GetDecisionTrace().Entries()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, DecisionSummary, entries[0].Decision)
+
+	assert.Equal(t, cold.GetUsage(), warm.GetUsage())
+	assert.Equal(t, cold.GetItemCount(), warm.GetItemCount())
+	assert.Equal(t, len(cold.Files), len(warm.Files))
+	a := warm.Files[0].(*Dir)
+	assert.Equal(t, "a", a.GetName())
+	assert.Equal(t, cold.Files[0].GetUsage(), a.GetUsage())
+	assert.Equal(t, cold.Files[0].GetItemCount(), a.GetItemCount())
+	assert.Empty(t, a.Files, "subdirectories are not loaded")
+	assert.Equal(t, filepath.Join(root, "a"), a.GetPath())
+
+	// a change deep in the tree is not seen, the mode trusts the mtime of the top directory
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a", "b", "new"), []byte("data"), 0o600))
+	_, analyzer = scan()
+	assert.True(t, analyzer.GetCacheStats().SummaryHit)
+
+	// once the mtime of the top directory moves, the tree is walked again
+	assert.NoError(t, os.Mkdir(filepath.Join(root, "d"), 0o755))
+	future := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(root, future, future))
+	dir, analyzer := scan()
+	assert.False(t, analyzer.GetCacheStats().SummaryHit)
+	assert.Len(t, dir.Files, 3)
+	assert.NotEmpty(t, dir.Files[0].(*Dir).Files)
+
+	// and the summary is stored for the new mtime
+	_, analyzer = scan()
+	assert.True(t, analyzer.GetCacheStats().SummaryHit)
+
+	// forced scan ignores the summary
+	opts.ForceFullScan = true
+	_, analyzer = scan()
+	assert.False(t, analyzer.GetCacheStats().SummaryHit)
+}
+
+func TestIncrementalAnalyzer_SummaryHitDisabled(t *testing.T) {
+	root := createTraceFixture(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	for i := 0; i < 2; i++ {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		assert.False(t, analyzer.GetCacheStats().SummaryHit)
+		assert.NotEmpty(t, dir.Files[0].(*Dir).Files)
+	}
+}
+
+func TestIncrementalAnalyzer_SummaryAfterUncleanScan(t *testing.T) {
+	root := createTraceFixture(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir(), TrustRootMtime: true}
+
+	analyzer := CreateIncrementalAnalyzer(opts)
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	// the previous scan did not finish cleanly
+	storage := NewIncrementalStorage(opts.StoragePath, root)
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	summary, err := storage.LoadRootSummary(root)
+	assert.NoError(t, err)
+	summary.Status = ScanCompletedWithErrors
+	assert.NoError(t, storage.StoreRootSummary(summary))
+	closeFn()
+
+	analyzer = CreateIncrementalAnalyzer(opts)
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+	assert.False(t, analyzer.GetCacheStats().SummaryHit)
+}
@@ -0,0 +1,214 @@
+package analyze
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+// createSymlinkFixture creates root with a valid, a dangling and two looping symlinks
+// and a valid symlink in a subdirectory. The dangling one points to outside/missing,
+// which can be created without changing mtime of root
+func createSymlinkFixture(t *testing.T) (root, outside string) {
+	t.Helper()
+	base := t.TempDir()
+	root = filepath.Join(base, "root")
+	outside = filepath.Join(base, "outside")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "sub"), 0o755))
+	assert.NoError(t, os.MkdirAll(outside, 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "file"), []byte("hello"), 0o600))
+
+	assert.NoError(t, os.Symlink("file", filepath.Join(root, "valid")))
+	assert.NoError(t, os.Symlink(filepath.Join(outside, "missing"), filepath.Join(root, "dangling")))
+	assert.NoError(t, os.Symlink("loop_b", filepath.Join(root, "loop_a")))
+	assert.NoError(t, os.Symlink("loop_a", filepath.Join(root, "loop_b")))
+	assert.NoError(t, os.Symlink("../file", filepath.Join(root, "sub", "link")))
+	return root, outside
+}
+
+func analyzeSymlinks(t *testing.T, root, storagePath string, verify bool) (*Dir, *IncrementalAnalyzer) {
+	t.Helper()
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath, VerifySymlinks: verify})
+	analyzer.SetFollowSymlinks(true)
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+	return dir, analyzer
+}
+
+func TestIncrementalAnalyzer_SymlinkCounts(t *testing.T) {
+	root, _ := createSymlinkFixture(t)
+	storagePath := t.TempDir()
+
+	dir, analyzer := analyzeSymlinks(t, root, storagePath, false)
+	assert.Equal(t, int64(0), analyzer.GetCacheStats().CacheHits)
+
+	assert.Equal(t, 5, dir.GetSymlinkCount())
+	assert.Equal(t, 3, dir.GetBrokenSymlinkCount())
+	assert.Equal(t, '@', childByName(dir, "valid").GetFlag())
+	assert.Equal(t, int64(5), childByName(dir, "valid").GetSize())
+	assert.Equal(t, '?', childByName(dir, "dangling").GetFlag())
+	assert.Equal(t, '?', childByName(dir, "loop_a").GetFlag())
+	assert.Equal(t, '?', childByName(dir, "loop_b").GetFlag())
+	assert.Equal(t, "Broken symlink", childByName(dir, "loop_a").GetType())
+
+	sub := childByName(dir, "sub").(*Dir)
+	assert.Equal(t, 1, sub.GetSymlinkCount())
+	assert.Equal(t, 0, sub.GetBrokenSymlinkCount())
+
+	broken, err := CollectBrokenSymlinks(context.Background(), dir)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		filepath.Join(root, "dangling"),
+		filepath.Join(root, "loop_a"),
+		filepath.Join(root, "loop_b"),
+	}, broken)
+
+	// warm scan keeps the flags and the counts
+	dir, analyzer = analyzeSymlinks(t, root, storagePath, false)
+	assert.Equal(t, int64(1), analyzer.GetCacheStats().CacheHits)
+	assert.Equal(t, 5, dir.GetSymlinkCount())
+	assert.Equal(t, 3, dir.GetBrokenSymlinkCount())
+	assert.Equal(t, '?', childByName(dir, "dangling").GetFlag())
+	assert.Equal(t, '@', childByName(dir, "valid").GetFlag())
+}
+
+func TestIncrementalAnalyzer_SymlinksNotFollowed(t *testing.T) {
+	root, _ := createSymlinkFixture(t)
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	assert.Equal(t, 5, dir.GetSymlinkCount())
+	assert.Equal(t, 0, dir.GetBrokenSymlinkCount())
+	assert.Equal(t, '@', childByName(dir, "dangling").GetFlag())
+}
+
+func TestIncrementalAnalyzer_WarmPathKeepsBrokenFlag(t *testing.T) {
+	root, outside := createSymlinkFixture(t)
+	storagePath := t.TempDir()
+
+	analyzeSymlinks(t, root, storagePath, false)
+
+	// the target appears, root itself is not modified
+	assert.NoError(t, os.WriteFile(filepath.Join(outside, "missing"), []byte("0123456789"), 0o600))
+
+	dir, _ := analyzeSymlinks(t, root, storagePath, false)
+	assert.Equal(t, '?', childByName(dir, "dangling").GetFlag(), "Cached links are not resolved again")
+	assert.Equal(t, 3, dir.GetBrokenSymlinkCount())
+}
+
+func TestIncrementalAnalyzer_VerifySymlinks(t *testing.T) {
+	root, outside := createSymlinkFixture(t)
+	storagePath := t.TempDir()
+
+	cold, _ := analyzeSymlinks(t, root, storagePath, false)
+	danglingSize := childByName(cold, "dangling").GetSize()
+
+	assert.NoError(t, os.WriteFile(filepath.Join(outside, "missing"), []byte("0123456789"), 0o600))
+
+	dir, analyzer := analyzeSymlinks(t, root, storagePath, true)
+	assert.Equal(t, int64(1), analyzer.GetCacheStats().CacheHits)
+	dangling := childByName(dir, "dangling")
+	assert.Equal(t, '@', dangling.GetFlag())
+	assert.Equal(t, int64(10), dangling.GetSize())
+	assert.Equal(t, 2, dir.GetBrokenSymlinkCount())
+	assert.Equal(t, cold.GetSize()+10-danglingSize, dir.GetSize())
+
+	// the verified entry is stored
+	dir, _ = analyzeSymlinks(t, root, storagePath, false)
+	assert.Equal(t, '@', childByName(dir, "dangling").GetFlag())
+	assert.Equal(t, 2, dir.GetBrokenSymlinkCount())
+	assert.Equal(t, cold.GetSize()+10-danglingSize, dir.GetSize())
+
+	// and the link breaks again
+	assert.NoError(t, os.Remove(filepath.Join(outside, "missing")))
+	dir, _ = analyzeSymlinks(t, root, storagePath, true)
+	assert.Equal(t, '?', childByName(dir, "dangling").GetFlag())
+	assert.Equal(t, 3, dir.GetBrokenSymlinkCount())
+	assert.Equal(t, cold.GetSize(), dir.GetSize())
+}
+
+func analyzeDirSymlinks(t *testing.T, root, storagePath string) (*Dir, *IncrementalAnalyzer) {
+	t.Helper()
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath, FollowDirSymlinks: true})
+	analyzer.SetFollowSymlinks(true)
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+	return dir, analyzer
+}
+
+func TestIncrementalAnalyzer_FollowDirSymlinks(t *testing.T) {
+	root, outside := createSymlinkFixture(t)
+	assert.NoError(t, os.WriteFile(filepath.Join(outside, "data"), make([]byte, 100), 0o600))
+	assert.NoError(t, os.Symlink(outside, filepath.Join(root, "ext")))
+	storagePath := t.TempDir()
+
+	dir, _ := analyzeDirSymlinks(t, root, storagePath)
+	ext, ok := childByName(dir, "ext").(*Dir)
+	assert.True(t, ok, "the link is scanned as the directory")
+	assert.Equal(t, int64(100), childByName(ext, "data").GetSize())
+	assert.Equal(t, 6, dir.GetSymlinkCount())
+	assert.Equal(t, 3, dir.GetBrokenSymlinkCount())
+
+	// the entry is keyed by the path of the link
+	storage := NewIncrementalStorage(storagePath, root)
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	_, err = storage.LoadDirMetadata(filepath.Join(root, "ext"))
+	assert.NoError(t, err)
+	_, err = storage.LoadDirMetadata(outside)
+	assert.Error(t, err)
+	// and is not pruned as stale
+	result, err := storage.PruneStale(root)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Entries)
+	_, err = storage.LoadDirMetadata(filepath.Join(root, "ext"))
+	assert.NoError(t, err)
+	closeFn()
+
+	warm, analyzer := analyzeDirSymlinks(t, root, storagePath)
+	assert.Equal(t, int64(1), analyzer.GetCacheStats().CacheHits)
+	assert.Equal(t, dir.GetSize(), warm.GetSize())
+	assert.Equal(t, dir.GetItemCount(), warm.GetItemCount())
+	assert.IsType(t, &Dir{}, childByName(warm, "ext"))
+
+	// without the option the link is a file
+	dir, _ = analyzeSymlinks(t, root, t.TempDir(), false)
+	assert.Equal(t, '@', childByName(dir, "ext").GetFlag())
+	assert.False(t, childByName(dir, "ext").IsDir())
+}
+
+func TestIncrementalAnalyzer_FollowDirSymlinksLoop(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "root")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "a"), 0o755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "b"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a", "file"), make([]byte, 100), 0o600))
+	assert.NoError(t, os.Symlink("../b", filepath.Join(root, "a", "to_b")))
+	assert.NoError(t, os.Symlink("../a", filepath.Join(root, "b", "to_a")))
+	assert.NoError(t, os.Symlink(".", filepath.Join(root, "self")))
+	storagePath := t.TempDir()
+
+	dir, _ := analyzeDirSymlinks(t, root, storagePath)
+	assert.Equal(t, 'D', childByName(dir, "self").GetFlag())
+	toB := childByName(childByName(dir, "a").(*Dir), "to_b").(*Dir)
+	assert.Equal(t, 'D', childByName(toB, "to_a").GetFlag(), "a is reached again from b")
+	assert.Equal(t, 'D', childByName(dir, "b").GetFlag(), "b was scanned as a/to_b")
+	files := 0
+	assert.NoError(t, Walk(context.Background(), dir, func(_ string, item fs.Item, _ int) error {
+		if item.GetName() == "file" {
+			files++
+		}
+		return nil
+	}))
+	assert.Equal(t, 1, files, "the file is counted once")
+
+	warm, analyzer := analyzeDirSymlinks(t, root, storagePath)
+	assert.Equal(t, int64(1), analyzer.GetCacheStats().CacheHits)
+	assert.Equal(t, dir.GetSize(), warm.GetSize())
+	assert.Equal(t, dir.GetItemCount(), warm.GetItemCount())
+}
@@ -1,15 +1,19 @@
 package analyze
 
 import (
-	"fmt"
+	"context"
 	"os"
 	"path/filepath"
 	"runtime"
+	"runtime/debug"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/dundee/gdu/v5/internal/common"
 	"github.com/dundee/gdu/v5/internal/testdir"
 	"github.com/dundee/gdu/v5/pkg/fs"
 	"github.com/stretchr/testify/assert"
@@ -94,18 +98,9 @@ func TestIncrementalAnalyzer_UnchangedDirectory(t *testing.T) {
 		"test_dir", func(_, _ string) bool { return false }, false,
 	).(*Dir)
 
-	// Drain progress channel (may or may not have messages depending on cache hits)
-	doneChan := analyzer2.GetDone()
-	progressChan := analyzer2.GetProgressChan()
-	for {
-		select {
-		case <-progressChan:
-			// Progress received, continue draining
-		case <-doneChan:
-			goto done2
-		}
+	// Drain progress channel, it is closed when the scan ends
+	for range analyzer2.GetProgressChan() {
 	}
-done2:
 	// Verify cache statistics BEFORE resetting
 	stats := analyzer2.GetCacheStats()
 	assert.Greater(t, stats.CacheHits, int64(0), "Second scan should have cache hits")
@@ -294,6 +289,49 @@ func TestIncrementalAnalyzer_IgnoredDirectories(t *testing.T) {
 	assert.Equal(t, 0, len(dir.Files), "Should have no files/subdirs")
 }
 
+func TestIncrementalAnalyzer_IgnoreArgumentsMatchOtherAnalyzers(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	absRoot, err := filepath.Abs("test_dir")
+	assert.NoError(t, err)
+
+	// arguments of the calls of ignore for the subdirectories (the incremental analyzer checks
+	// the scanned directory too), subnested given in the form of the scanned path is left out
+	scan := func(analyzer common.Analyzer, root string) ([]string, int) {
+		var m sync.Mutex
+		calls := make([]string, 0)
+		ignored := filepath.Join(root, "nested", "subnested")
+		dir := analyzer.AnalyzeDir(root, func(name, path string) bool {
+			m.Lock()
+			defer m.Unlock()
+			if name != "test_dir" {
+				calls = append(calls, name+" "+path)
+			}
+			return path == ignored
+		}, true)
+		analyzer.GetDone().Wait()
+		dir.UpdateStats(make(fs.HardLinkedItems))
+		sort.Strings(calls)
+		return calls, dir.GetItemCount()
+	}
+
+	for _, root := range []string{"test_dir", "./test_dir/", absRoot} {
+		expected, expectedCount := scan(CreateAnalyzer(), root)
+		assert.Equal(t, []string{
+			"nested " + filepath.Join(root, "nested"),
+			"subnested " + filepath.Join(root, "nested", "subnested"),
+		}, expected, root)
+
+		calls, count := scan(CreateSeqAnalyzer(), root)
+		assert.Equal(t, expected, calls, root)
+		assert.Equal(t, expectedCount, count, root)
+
+		calls, count = scan(CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()}), root)
+		assert.Equal(t, expected, calls, root)
+		assert.Equal(t, expectedCount, count, root)
+	}
+}
+
 // TestIncrementalAnalyzer_ErrorHandling verifies graceful error handling
 func TestIncrementalAnalyzer_ErrorHandling(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -310,17 +348,9 @@ func TestIncrementalAnalyzer_ErrorHandling(t *testing.T) {
 		"/non/existent/path", func(_, _ string) bool { return false }, false,
 	).(*Dir)
 
-	// Drain progress channel properly
-	doneChan := analyzer.GetDone()
-	progressChan := analyzer.GetProgressChan()
-	for {
-		select {
-		case <-progressChan:
-		case <-doneChan:
-			goto done
-		}
+	// Drain progress channel, it is closed when the scan ends
+	for range analyzer.GetProgressChan() {
 	}
-done:
 	analyzer.ResetProgress()
 
 	// Verify error directory is created
@@ -430,6 +460,51 @@ func TestIncrementalAnalyzer_EmptyDirectory(t *testing.T) {
 	assert.Equal(t, 'e', dir.Flag)
 }
 
+func TestIncrementalAnalyzer_EmptyFlagTransitions(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "root")
+	toggled := filepath.Join(root, "sub", "toggled")
+	assert.NoError(t, os.MkdirAll(toggled, 0o755))
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	analyze := func(path string) *Dir {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		dir := analyzer.AnalyzeDir(path, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		dir.UpdateStats(make(fs.HardLinkedItems))
+		return dir
+	}
+	// toggled is scanned on its own first, e.g. by a rescan requested from the HTTP API,
+	// then the root, where the unchanged entry of sub is used with the new entry of toggled
+	scan := func() (shown, cached rune) {
+		t.Helper()
+		analyze(toggled)
+		dir := analyze(root)
+		shown = childByName(childByName(dir, "sub").(*Dir), "toggled").GetFlag()
+
+		storage := NewIncrementalStorage(opts.StoragePath, root)
+		closeFn := mustOpen(t, storage)
+		defer closeFn()
+		meta, err := storage.LoadDirMetadata(filepath.Join(root, "sub"))
+		assert.NoError(t, err)
+		assert.Len(t, meta.Files, 1)
+		return shown, meta.Files[0].Flag
+	}
+
+	shown, cached := scan()
+	assert.Equal(t, 'e', shown)
+	assert.Equal(t, 'e', cached)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(toggled, "file"), []byte("x"), 0o600))
+	shown, cached = scan()
+	assert.Equal(t, ' ', shown)
+	assert.Equal(t, ' ', cached)
+
+	assert.NoError(t, os.Remove(filepath.Join(toggled, "file")))
+	shown, cached = scan()
+	assert.Equal(t, 'e', shown)
+	assert.Equal(t, 'e', cached)
+}
+
 // TestIncrementalAnalyzer_ExtractFileMetadata verifies metadata extraction
 func TestIncrementalAnalyzer_ExtractFileMetadata(t *testing.T) {
 	fin := testdir.CreateTestDir()
@@ -587,17 +662,9 @@ func TestIncrementalAnalyzer_ProgressUpdateNoLeak(t *testing.T) {
 			"test_dir", func(_, _ string) bool { return false }, false,
 		).(*Dir)
 
-		// Drain progress channel
-		doneChan := analyzer.GetDone()
-		progressChan := analyzer.GetProgressChan()
-		for {
-			select {
-			case <-progressChan:
-			case <-doneChan:
-				goto done
-			}
+		// Drain progress channel, it is closed when the scan ends
+		for range analyzer.GetProgressChan() {
 		}
-	done:
 		analyzer.ResetProgress()
 		assert.NotNil(t, dir)
 	}
@@ -645,31 +712,20 @@ func TestDefaultDirBlockSizeConstant(t *testing.T) {
 // Due to the fix, rebuildFromCache() no longer calls processDir() recursively, which was
 // causing the entire tree to be loaded twice into memory. This test verifies the fix works.
 func TestIncrementalAnalyzer_MemoryUsage(t *testing.T) {
-	// Create a larger test directory to make memory differences more apparent
+	// Create a larger test directory to make memory differences more apparent:
+	// nested directories with files amplify memory usage differences.
+	// Seed 1 keeps the file sizes the same in every run
 	testDir := filepath.Join(t.TempDir(), "large_test")
-	err := os.MkdirAll(testDir, 0o755)
-	assert.NoError(t, err)
-
-	// Create multiple nested directories with files to amplify memory usage differences
-	for i := 0; i < 10; i++ {
-		dirPath := filepath.Join(testDir, fmt.Sprintf("dir%d", i))
-		err = os.MkdirAll(dirPath, 0o755)
-		assert.NoError(t, err)
-
-		// Create subdirectories
-		for j := 0; j < 5; j++ {
-			subPath := filepath.Join(dirPath, fmt.Sprintf("subdir%d", j))
-			err = os.MkdirAll(subPath, 0o755)
-			assert.NoError(t, err)
-
-			// Create files in subdirectories
-			for k := 0; k < 10; k++ {
-				filePath := filepath.Join(subPath, fmt.Sprintf("file%d.txt", k))
-				err = os.WriteFile(filePath, []byte("test content"), 0o644)
-				assert.NoError(t, err)
-			}
-		}
-	}
+	_, cleanup := testdir.CreateSyntheticTree(testdir.TreeSpec{
+		Root:        testDir,
+		Depth:       2,
+		Breadth:     7,
+		FilesPerDir: 10,
+		FileSize:    12,
+		SizeJitter:  8,
+		Seed:        1,
+	})
+	defer cleanup()
 
 	tmpDir := t.TempDir()
 
@@ -688,17 +744,9 @@ func TestIncrementalAnalyzer_MemoryUsage(t *testing.T) {
 
 	analyzer1.AnalyzeDir(testDir, func(_, _ string) bool { return false }, false)
 
-	// Drain progress channel
-	doneChan := analyzer1.GetDone()
-	progressChan := analyzer1.GetProgressChan()
-	for {
-		select {
-		case <-progressChan:
-		case <-doneChan:
-			goto done1
-		}
+	// Drain progress channel, it is closed when the scan ends
+	for range analyzer1.GetProgressChan() {
 	}
-done1:
 
 	// Capture memory after first scan
 	runtime.GC()
@@ -717,17 +765,9 @@ done1:
 
 	analyzer2.AnalyzeDir(testDir, func(_, _ string) bool { return false }, false)
 
-	// Drain progress channel
-	doneChan2 := analyzer2.GetDone()
-	progressChan2 := analyzer2.GetProgressChan()
-	for {
-		select {
-		case <-progressChan2:
-		case <-doneChan2:
-			goto done2
-		}
+	// Drain progress channel, it is closed when the scan ends
+	for range analyzer2.GetProgressChan() {
 	}
-done2:
 
 	// Capture memory after second scan
 	runtime.GC()
@@ -754,3 +794,720 @@ done2:
 	assert.Greater(t, stats.CacheHits, int64(0), "Should have cache hits on second run")
 	t.Logf("Cache hit rate: %.2f%%", stats.HitRate())
 }
+
+// TestIncrementalAnalyzer_NewDirsSinceLastScan verifies that directories created
+// between two scans are reported as new
+func TestIncrementalAnalyzer_NewDirsSinceLastScan(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	// Backdate parents so that creating new dirs surely changes their mtime
+	past := time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes("test_dir", past, past))
+	assert.NoError(t, os.Chtimes("test_dir/nested", past, past))
+
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	analyzer1 := CreateIncrementalAnalyzer(opts)
+	analyzer1.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false)
+	analyzer1.GetDone().Wait()
+	assert.Equal(t, int64(0), analyzer1.GetCacheStats().NewDirsCount, "Cold scan should not report new dirs")
+
+	assert.NoError(t, os.Mkdir("test_dir/added", 0o755))
+	assert.NoError(t, os.MkdirAll("test_dir/nested/added2/deeper", 0o755))
+
+	analyzer2 := CreateIncrementalAnalyzer(opts)
+	analyzer2.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false)
+	analyzer2.GetDone().Wait()
+
+	stats := analyzer2.GetCacheStats()
+	assert.Equal(t, int64(2), stats.NewDirsCount)
+	assert.ElementsMatch(t, []string{
+		absTestPath(t, "test_dir", "added"),
+		absTestPath(t, "test_dir", "nested", "added2"),
+	}, stats.NewDirs)
+}
+
+// TestIncrementalAnalyzer_MaxReportedPaths verifies that new dirs over the limit are only counted
+func TestIncrementalAnalyzer_MaxReportedPaths(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	past := time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes("test_dir", past, past))
+
+	opts := IncrementalOptions{StoragePath: t.TempDir(), MaxReportedPaths: 2}
+
+	analyzer1 := CreateIncrementalAnalyzer(opts)
+	analyzer1.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false)
+	analyzer1.GetDone().Wait()
+
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		assert.NoError(t, os.Mkdir(filepath.Join("test_dir", name), 0o755))
+	}
+
+	analyzer2 := CreateIncrementalAnalyzer(opts)
+	analyzer2.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false)
+	analyzer2.GetDone().Wait()
+
+	stats := analyzer2.GetCacheStats()
+	assert.Len(t, stats.NewDirs, 2)
+	assert.Equal(t, int64(5), stats.NewDirsCount)
+	assert.Equal(t, int64(3), stats.NewDirsDropped())
+
+	// the limit is kept after the stats are reset
+	analyzer2.ResetProgress()
+	assert.Equal(t, 2, analyzer2.GetCacheStats().pathLimit)
+}
+
+// TestIncrementalAnalyzer_RemovedDirsSinceLastScan verifies that a removed subtree
+// is reported once by its topmost directory
+func TestIncrementalAnalyzer_RemovedDirsSinceLastScan(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	assert.NoError(t, os.MkdirAll("test_dir/gone/deeper/deepest", 0o755))
+	assert.NoError(t, os.MkdirAll("test_dir/nested/gone2/deeper", 0o755))
+	assert.NoError(t, os.MkdirAll("test_dir/renamed", 0o755))
+	past := time.Now().Add(-time.Hour)
+	for _, dir := range []string{"test_dir", "test_dir/nested", "test_dir/nested/subnested"} {
+		assert.NoError(t, os.Chtimes(dir, past, past))
+	}
+
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	analyzer1 := CreateIncrementalAnalyzer(opts)
+	analyzer1.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false)
+	analyzer1.GetDone().Wait()
+	assert.Equal(t, int64(0), analyzer1.GetCacheStats().RemovedDirsCount, "Cold scan should not report removed dirs")
+
+	assert.NoError(t, os.RemoveAll("test_dir/gone"))
+	assert.NoError(t, os.RemoveAll("test_dir/nested/gone2"))
+	assert.NoError(t, os.Rename("test_dir/renamed", "test_dir/renamed2"))
+
+	analyzer2 := CreateIncrementalAnalyzer(opts)
+	analyzer2.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false)
+	analyzer2.GetDone().Wait()
+
+	stats := analyzer2.GetCacheStats()
+	assert.Equal(t, int64(3), stats.RemovedDirsCount)
+	assert.ElementsMatch(t, []string{
+		absTestPath(t, "test_dir", "gone"),
+		absTestPath(t, "test_dir", "nested", "gone2"),
+		absTestPath(t, "test_dir", "renamed"),
+	}, stats.RemovedDirs)
+	assert.Equal(t, []string{absTestPath(t, "test_dir", "renamed2")}, stats.NewDirs)
+
+	// nothing changed since
+	analyzer3 := CreateIncrementalAnalyzer(opts)
+	analyzer3.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false)
+	analyzer3.GetDone().Wait()
+	assert.Equal(t, int64(0), analyzer3.GetCacheStats().RemovedDirsCount)
+}
+
+func TestIncrementalAnalyzer_NoGoroutineLeakAcrossScans(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	defer debug.SetGCPercent(debug.SetGCPercent(100))
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	baseline := runtime.NumGoroutine()
+
+	for i := 0; i < 20; i++ {
+		analyzer.ResetProgress()
+		analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false)
+		analyzer.GetDone().Wait()
+	}
+
+	assert.True(t, waitForGoroutines(baseline), "goroutines leaked by repeated scans")
+	assert.Equal(t, 100, debug.SetGCPercent(100), "GC percent should be restored")
+}
+
+func TestIncrementalAnalyzer_RestoresGCOnPanic(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	defer debug.SetGCPercent(debug.SetGCPercent(100))
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	baseline := runtime.NumGoroutine()
+
+	assert.NotPanics(t, func() {
+		analyzer.AnalyzeDir("test_dir", func(name, _ string) bool {
+			if name == "subnested" {
+				panic("ignore func failed")
+			}
+			return false
+		}, false)
+	})
+
+	analyzer.GetDone().Wait()
+	assert.Equal(t, ScanFailed, analyzer.GetScanResult().Status)
+	assert.Equal(t, 100, debug.SetGCPercent(100), "GC percent should be restored")
+	assert.True(t, waitForGoroutines(baseline), "goroutines leaked by panicking scan")
+}
+
+// waitForGoroutines waits until number of goroutines drops to given count
+func waitForGoroutines(count int) bool {
+	for i := 0; i < 500; i++ {
+		if runtime.NumGoroutine() <= count {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestIncrementalAnalyzer_ScanResult(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	assert.Nil(t, analyzer.GetScanResult())
+	assert.False(t, analyzer.IsScanning())
+
+	analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+	assert.False(t, analyzer.IsScanning())
+
+	result := analyzer.GetScanResult()
+	assert.Equal(t, ScanCompleted, result.Status)
+	assert.Nil(t, result.Err)
+	assert.Equal(t, 0, result.ErrorCount)
+	assert.Equal(t, int64(3), result.Stats.TotalDirs)
+
+	// snapshot is detached from the live statistics
+	analyzer.GetCacheStats().IncrementTotalDirs()
+	assert.Equal(t, int64(3), result.Stats.TotalDirs)
+}
+
+func TestIncrementalAnalyzer_ScanResultCacheInitFailed(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	// regular file can't be used as cache directory
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: "test_dir/nested/file2"})
+	analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	result := analyzer.GetScanResult()
+	assert.Equal(t, ScanFailed, result.Status)
+	assert.NotNil(t, result.Err)
+}
+
+func TestIncrementalAnalyzer_ScanResultMissingRoot(t *testing.T) {
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	analyzer.AnalyzeDir("nonexistent_dir", func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	result := analyzer.GetScanResult()
+	assert.Equal(t, ScanFailed, result.Status)
+	assert.Contains(t, result.Err.Error(), "nonexistent_dir")
+}
+
+func TestIncrementalAnalyzer_ScanResultCancelled(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	analyzer := CreateIncrementalAnalyzer(opts)
+	analyzer.Cancel()
+	dir := analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	assert.Equal(t, ScanCancelled, analyzer.GetScanResult().Status)
+	assert.Empty(t, dir.Files)
+
+	// partial result must not be cached
+	analyzer2 := CreateIncrementalAnalyzer(opts)
+	dir = analyzer2.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer2.GetDone().Wait()
+
+	assert.Equal(t, ScanCompleted, analyzer2.GetScanResult().Status)
+	assert.Equal(t, int64(0), analyzer2.GetCacheStats().CacheHits)
+	assert.Len(t, dir.Files, 1)
+}
+
+func TestIncrementalAnalyzer_AnalyzeDirWithContext(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	analyzer := CreateIncrementalAnalyzer(opts)
+	analyzer.beforeSubdir = func(path string) {
+		if filepath.Base(path) == "nested" {
+			cancel()
+		}
+	}
+	dir := analyzer.AnalyzeDirWithContext(ctx, "test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	assert.Equal(t, ScanCancelled, analyzer.GetScanResult().Status)
+	assert.Equal(t, 'x', dir.Flag)
+	if assert.Len(t, dir.Files, 1) {
+		assert.Equal(t, 'x', dir.Files[0].GetFlag())
+	}
+
+	// the partial result was not cached
+	ctx, cancel = context.WithCancel(context.Background())
+	analyzer = CreateIncrementalAnalyzer(opts)
+	replaced := analyzer.ctx
+	dir = analyzer.AnalyzeDirWithContext(ctx, "test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+	cancel()
+
+	assert.Equal(t, ScanCompleted, analyzer.GetScanResult().Status)
+	assert.Equal(t, ' ', dir.Flag)
+	assert.Equal(t, int64(0), analyzer.GetCacheStats().CacheHits)
+	assert.ErrorIs(t, replaced.Err(), context.Canceled, "the replaced context is released")
+}
+
+func TestIncrementalAnalyzer_ItemCountFromCacheWithHardlinks(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	assert.NoError(t, os.Link("test_dir/nested/file2", "test_dir/nested/subnested/link"))
+	assert.NoError(t, os.Link("test_dir/nested/file2", "test_dir/link"))
+
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	analyzer1 := CreateIncrementalAnalyzer(opts)
+	cold := analyzer1.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer1.GetDone().Wait()
+
+	analyzer2 := CreateIncrementalAnalyzer(opts)
+	warm := analyzer2.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer2.GetDone().Wait()
+
+	assert.Equal(t, int64(1), analyzer2.GetCacheStats().CacheHits)
+	assert.Equal(t, 7, cold.ItemCount)
+	assert.Equal(t, cold.ItemCount, warm.ItemCount)
+	assert.Equal(t, childByName(cold, "nested").GetItemCount(), childByName(warm, "nested").GetItemCount())
+
+	// item count does not depend on hardlink deduplication done by UpdateStats
+	warm.UpdateStats(make(fs.HardLinkedItems))
+	assert.Equal(t, cold.ItemCount, warm.ItemCount)
+
+	// two of the three links are not counted
+	cold.UpdateStats(make(fs.HardLinkedItems))
+	usage := childByName(childByName(cold, "nested").(*Dir), "file2").GetUsage()
+	assert.Positive(t, usage)
+	assert.Equal(t, 2*usage, cold.GetHardlinksSaved())
+	assert.Equal(t, cold.GetHardlinksSaved(), warm.GetHardlinksSaved())
+}
+
+func TestIncrementalAnalyzer_HardlinksAcrossSubdirs(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"a", "b"} {
+		assert.NoError(t, os.Mkdir(filepath.Join(root, dir), 0o755))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a", "file"), make([]byte, 10000), 0o644))
+	assert.NoError(t, os.Link(filepath.Join(root, "a", "file"), filepath.Join(root, "b", "link")))
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	scan := func() *Dir {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		dir.UpdateStats(make(fs.HardLinkedItems))
+		return dir
+	}
+	cold := scan()
+	link := childByName(childByName(cold, "b").(*Dir), "link")
+	assert.NotZero(t, link.GetMultiLinkedInode())
+	assert.Equal(t, link.GetUsage(), cold.GetHardlinksSaved())
+
+	// the same totals are rebuilt from the cache and with only one of the subdirectories rescanned
+	warm := scan()
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "b", "other"), nil, 0o644))
+	partial := scan()
+	for _, dir := range []*Dir{warm, partial} {
+		assert.Equal(t, cold.Size, dir.Size)
+		assert.Equal(t, cold.Usage, dir.Usage)
+		assert.Equal(t, cold.GetHardlinksSaved(), dir.GetHardlinksSaved())
+	}
+}
+
+func TestIncrementalAnalyzer_ComputeAggregatesAfterChildRescan(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	analyzer1 := CreateIncrementalAnalyzer(opts)
+	cold := analyzer1.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer1.GetDone().Wait()
+	coldCount, coldSize := cold.ItemCount, cold.Size
+
+	// Drop the cache entry of subnested so that it has to be rescanned
+	storage := NewIncrementalStorage(opts.StoragePath, "test_dir")
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	assert.NoError(t, storage.DeleteDirMetadata(absTestPath(t, "test_dir", "nested", "subnested")))
+	closeFn()
+
+	assert.NoError(t, os.WriteFile("test_dir/nested/subnested/new", []byte("12345"), 0o600))
+
+	analyzer2 := CreateIncrementalAnalyzer(opts)
+	warm := analyzer2.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer2.GetDone().Wait()
+
+	nested := childByName(warm, "nested").(*Dir)
+	assert.Equal(t, coldCount+1, warm.ItemCount)
+	assert.Equal(t, 5, nested.ItemCount)
+	assert.Equal(t, 3, childByName(nested, "subnested").GetItemCount())
+	assert.Equal(t, coldSize+5, warm.Size)
+}
+
+// absTestPath returns the absolute path of the relative one, as the incremental analyzer stores it
+func absTestPath(t *testing.T, elem ...string) string {
+	t.Helper()
+	path, err := filepath.Abs(filepath.Join(elem...))
+	assert.NoError(t, err)
+	return path
+}
+
+func childByName(dir *Dir, name string) fs.Item {
+	i, ok := dir.Files.FindByName(name)
+	if !ok {
+		return nil
+	}
+	return dir.Files[i]
+}
+
+func TestIncrementalAnalyzer_ApparentSizeAndUsage(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	assert.NoError(t, os.WriteFile("test_dir/nested/big", make([]byte, 10000), 0o644))
+	assert.NoError(t, os.Link("test_dir/nested/file2", "test_dir/link"))
+
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+
+	analyzer1 := CreateIncrementalAnalyzer(opts)
+	cold := analyzer1.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer1.GetDone().Wait()
+
+	analyzer2 := CreateIncrementalAnalyzer(opts)
+	warm := analyzer2.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer2.GetDone().Wait()
+	assert.Equal(t, int64(1), analyzer2.GetCacheStats().CacheHits)
+
+	// the cache keeps both values as scanned
+	assertSameSizes(t, cold, warm)
+
+	// and both display modes read the same numbers as a regular scan
+	reference := CreateAnalyzer().AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+	reference.UpdateStats(make(fs.HardLinkedItems))
+	cold.UpdateStats(make(fs.HardLinkedItems))
+	warm.UpdateStats(make(fs.HardLinkedItems))
+	assertSameSizes(t, reference, cold)
+	assertSameSizes(t, reference, warm)
+	assert.Greater(t, warm.GetUsage(), int64(0))
+	assert.NotEqual(t, warm.GetSize(), warm.GetUsage())
+}
+
+// assertSameSizes compares apparent size and disk usage of both trees item by item
+func assertSameSizes(t *testing.T, expected, actual fs.Item) {
+	t.Helper()
+	assert.Equal(t, expected.GetSize(), actual.GetSize(), "apparent size of %s", expected.GetPath())
+	assert.Equal(t, expected.GetUsage(), actual.GetUsage(), "disk usage of %s", expected.GetPath())
+
+	if !expected.IsDir() {
+		return
+	}
+	for _, item := range expected.GetFiles() {
+		other := childByName(actual.(*Dir), item.GetName())
+		if !assert.NotNil(t, other, "missing %s", item.GetPath()) {
+			continue
+		}
+		assertSameSizes(t, item, other)
+	}
+}
+
+func TestIncrementalAnalyzer_SelfSizes(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+	scan := func() *Dir {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		dir := analyzer.AnalyzeDir("test_dir", func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		return dir
+	}
+	assertSelfSizes := func(dir *Dir) {
+		t.Helper()
+		nested := childByName(dir, "nested").(*Dir)
+		subnested := childByName(nested, "subnested").(*Dir)
+		assert.Equal(t, int64(0), dir.GetSelfSize())
+		assert.Equal(t, int64(2), nested.GetSelfSize())
+		assert.Equal(t, childByName(nested, "file2").GetUsage(), nested.GetSelfUsage())
+		assert.Equal(t, int64(5), subnested.GetSelfSize())
+		assert.Greater(t, nested.GetSize(), nested.GetSelfSize())
+	}
+
+	cold := scan()
+	assertSelfSizes(cold)
+
+	warm := scan()
+	assertSelfSizes(warm)
+
+	// entries written before schema 3 do not hold the self sizes
+	path := absTestPath(t, "test_dir", "nested")
+	storage := NewIncrementalStorage(opts.StoragePath, path)
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	meta, err := storage.LoadDirMetadata(path)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), meta.SelfSize)
+	meta.SelfSize, meta.SelfUsage = 0, 0
+	assert.NoError(t, storage.StoreDirMetadata(meta))
+	closeFn()
+
+	assertSelfSizes(scan())
+}
+
+func TestIncrementalAnalyzer_DirVanishedDuringScan(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "root")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "build", "obj"), 0o755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "src"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "build", "obj", "out.o"), make([]byte, 4096), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "src", "main.go"), []byte("package main"), 0o600))
+
+	storagePath := t.TempDir()
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: storagePath})
+	// remove the directory after the listing of root was read
+	analyzer.beforeSubdir = func(path string) {
+		if filepath.Base(path) == "build" {
+			assert.NoError(t, os.RemoveAll(path))
+		}
+	}
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+
+	assert.Nil(t, childByName(dir, "build"))
+	assert.NotNil(t, childByName(dir, "src"))
+	assert.Equal(t, 0, dir.ErrorCount)
+	assert.NotEqual(t, '!', dir.GetFlag())
+	assert.Equal(t, int64(1), analyzer.GetCacheStats().VanishedDuringScan)
+
+	src := childByName(dir, "src")
+	assert.Equal(t, dir.Size, src.GetSize()+dir.SelfSize+directorySize(t, root))
+	assert.Equal(t, 3, dir.ItemCount)
+
+	// nothing is cached for the vanished directory
+	storage := NewIncrementalStorage(storagePath, root)
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	defer closeFn()
+	_, err = storage.LoadDirMetadata(filepath.Join(root, "build"))
+	assert.Error(t, err)
+	meta, err := storage.LoadDirMetadata(root)
+	assert.NoError(t, err)
+	for _, f := range meta.Files {
+		assert.NotEqual(t, "build", f.Name)
+	}
+}
+
+func directorySize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	return info.Size()
+}
+
+func TestIncrementalAnalyzer_FutureTimestamps(t *testing.T) {
+	root := createTraceFixture(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir(), TraceDecisions: true, CacheMaxAge: time.Hour}
+
+	entries := traceScan(t, root, opts)
+	assert.Equal(t, DecisionMiss, entries["."].Decision)
+
+	// entry cached by a machine with clock ahead never expires when such entries are trusted
+	storage := NewIncrementalStorage(opts.StoragePath, root)
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	meta, err := storage.LoadDirMetadata(root)
+	assert.NoError(t, err)
+	meta.CachedAt = time.Now().Add(10 * 365 * 24 * time.Hour)
+	assert.NoError(t, storage.StoreDirMetadata(meta))
+	closeFn()
+
+	opts.TrustCachedAhead = true
+	assert.Equal(t, DecisionHit, traceScan(t, root, opts)["."].Decision)
+
+	// the skew check applies regardless
+	opts.FutureSkew = time.Minute
+	analyzer := CreateIncrementalAnalyzer(opts)
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+	assert.Equal(t, DecisionFuture, analyzer.GetDecisionTrace().Entries()[0].Decision)
+	assert.Equal(t, int64(1), analyzer.GetCacheStats().FutureTimestamps)
+
+	// the rescan stored a fresh entry
+	assert.Equal(t, DecisionHit, traceScan(t, root, opts)["."].Decision)
+
+	// directory with mtime in the future is scanned on every run
+	future := time.Now().Add(48 * time.Hour)
+	assert.NoError(t, os.Chtimes(filepath.Join(root, "c"), future, future))
+	forced := opts
+	forced.ForceFullScan = true
+	traceScan(t, root, forced)
+	for i := 0; i < 2; i++ {
+		entries = traceScan(t, root, opts)
+		assert.Equal(t, DecisionHit, entries["."].Decision)
+		assert.Equal(t, DecisionFuture, entries["c"].Decision)
+		assert.Equal(t, DecisionInherited, entries["a"].Decision)
+	}
+
+	// small skew is tolerated
+	soon := time.Now().Add(10 * time.Second)
+	assert.NoError(t, os.Chtimes(filepath.Join(root, "c"), soon, soon))
+	traceScan(t, root, forced)
+	assert.Equal(t, DecisionInherited, traceScan(t, root, opts)["c"].Decision)
+}
+
+// setCachedAt rewrites the time the cache entry of the directory was written at
+func setCachedAt(t *testing.T, storagePath, root, path string, cachedAt time.Time) {
+	t.Helper()
+	storage := NewIncrementalStorage(storagePath, root)
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	defer closeFn()
+	meta, err := storage.LoadDirMetadata(path)
+	assert.NoError(t, err)
+	meta.CachedAt = cachedAt
+	assert.NoError(t, storage.StoreDirMetadata(meta))
+}
+
+func TestIncrementalAnalyzer_ClockSteppedBackwards(t *testing.T) {
+	root := createTraceFixture(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir(), TraceDecisions: true, CacheMaxAge: time.Hour}
+	traceScan(t, root, opts)
+
+	// entries written before the clock was stepped back by a day
+	ahead := time.Now().Add(24 * time.Hour)
+	setCachedAt(t, opts.StoragePath, root, root, ahead)
+	setCachedAt(t, opts.StoragePath, root, filepath.Join(root, "c"), ahead)
+
+	// a few seconds are tolerated
+	setCachedAt(t, opts.StoragePath, root, filepath.Join(root, "a"), time.Now().Add(10*time.Second))
+
+	// trusted entries are used as if cached now
+	trusting := opts
+	trusting.TrustCachedAhead = true
+	analyzer := CreateIncrementalAnalyzer(trusting)
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+	stats := analyzer.GetCacheStats()
+	assert.Equal(t, int64(1), stats.CachedAhead)
+	assert.Zero(t, stats.CacheExpired)
+	assert.Zero(t, stats.DirsRescanned)
+	for _, entry := range analyzer.GetDecisionTrace().Entries() {
+		assert.GreaterOrEqual(t, entry.CacheAge, time.Duration(0))
+	}
+	assert.Equal(t, DecisionHit, analyzer.GetDecisionTrace().Entries()[0].Decision)
+
+	// by default they are expired, the children as well
+	analyzer = CreateIncrementalAnalyzer(opts)
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+	stats = analyzer.GetCacheStats()
+	assert.Equal(t, int64(2), stats.CachedAhead)
+	assert.Equal(t, int64(2), stats.CacheExpired)
+	entries := make(map[string]TraceEntry)
+	for _, entry := range analyzer.GetDecisionTrace().Entries() {
+		entries[entry.Path] = entry
+		assert.GreaterOrEqual(t, entry.CacheAge, time.Duration(0))
+	}
+	assert.Equal(t, DecisionExpired, entries[root].Decision)
+	assert.Equal(t, DecisionExpired, entries[filepath.Join(root, "c")].Decision)
+	assert.Equal(t, DecisionHit, entries[filepath.Join(root, "a")].Decision)
+	assert.NotContains(t, stats.String(), "-")
+
+	// the rescan stored fresh entries
+	entries = traceScan(t, root, opts)
+	assert.Equal(t, DecisionHit, entries["."].Decision)
+	assert.Equal(t, DecisionInherited, entries["c"].Decision)
+}
+
+func TestIncrementalAnalyzer_MtimeMovedBackwards(t *testing.T) {
+	root := createTraceFixture(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir(), TraceDecisions: true, BackwardsSkew: time.Hour}
+	traceScan(t, root, opts)
+
+	scan := func() *CacheStats {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+		analyzer.GetDone().Wait()
+		assert.Equal(t, DecisionChanged, analyzer.GetDecisionTrace().Entries()[0].Decision)
+		return analyzer.GetCacheStats()
+	}
+
+	// restored from a backup made two days ago
+	restored := time.Now().Add(-48 * time.Hour)
+	assert.NoError(t, os.Chtimes(root, restored, restored))
+	stats := scan()
+	assert.Equal(t, int64(1), stats.ClockSkewRescans)
+	assert.Equal(t, int64(1), stats.DirsRescanned)
+
+	// the rescan stored the current mtime
+	assert.Equal(t, DecisionHit, traceScan(t, root, opts)["."].Decision)
+
+	// a small skew is an ordinary change
+	earlier := restored.Add(-10 * time.Minute)
+	assert.NoError(t, os.Chtimes(root, earlier, earlier))
+	stats = scan()
+	assert.Zero(t, stats.ClockSkewRescans)
+	assert.Equal(t, int64(1), stats.DirsRescanned)
+
+	// without the check the directory is still scanned again
+	opts.BackwardsSkew = 0
+	restored = earlier.Add(-48 * time.Hour)
+	assert.NoError(t, os.Chtimes(root, restored, restored))
+	stats = scan()
+	assert.Zero(t, stats.ClockSkewRescans)
+	assert.Equal(t, int64(1), stats.DirsRescanned)
+}
+
+func TestCacheStatsStringWithoutNegativeDurations(t *testing.T) {
+	stats := &CacheStats{TotalScanTime: time.Millisecond, CacheLoadTime: 2 * time.Millisecond}
+	assert.Contains(t, stats.String(), "Scan: 0s, Total: 1ms")
+}
+
+func TestIncrementalAnalyzer_RelativePathSharesCache(t *testing.T) {
+	root := createTraceFixture(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+	scan := func(path string) (*Dir, *CacheStats) {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		dir := analyzer.AnalyzeDir(path, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		return dir, analyzer.GetCacheStats()
+	}
+
+	t.Chdir(root)
+	cold, stats := scan(".")
+	assert.Equal(t, root, cold.GetPath())
+	assert.Equal(t, "root", cold.GetName())
+	assert.Equal(t, int64(4), stats.CacheMisses)
+
+	// the same directory given by the absolute path from another working directory
+	t.Chdir(t.TempDir())
+	warm, stats := scan(root)
+	assert.Equal(t, int64(1), stats.CacheHits)
+	assert.Zero(t, stats.CacheMisses)
+	assert.Zero(t, stats.DirsRescanned)
+	assert.Equal(t, cold.GetUsage(), warm.GetUsage())
+
+	// and by a relative one
+	t.Chdir(filepath.Dir(root))
+	_, stats = scan(filepath.Join(".", "root", "a", ".."))
+	assert.Equal(t, int64(1), stats.CacheHits)
+	assert.Zero(t, stats.CacheMisses)
+}
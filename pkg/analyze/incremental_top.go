@@ -0,0 +1,90 @@
+package analyze
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CachedDirTotals are totals of a directory read from its cache entry
+type CachedDirTotals struct {
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	Usage     int64     `json:"usage"`
+	ItemCount int       `json:"itemCount"`
+	CachedAt  time.Time `json:"cachedAt"`
+}
+
+// TopDirsBySize returns up to n directories with the highest disk usage under prefix
+// read from the cache entries, without rebuilding the tree. Ties are ordered by path.
+//
+// Depth is relative to prefix: prefix itself is level 0, its children level 1.
+// Only directories from minDepth to maxDepth (0 = unlimited) are ranked.
+// The totals are per directory and include the whole subtree, not just the exclusive size,
+// so if the depth range spans more levels a directory is listed together with its ancestors
+// and their totals must not be summed.
+// Entries of duplicate directories (e.g. bind mounts) and entries which can't be decoded are skipped
+func (s *IncrementalStorage) TopDirsBySize(prefix string, n int, minDepth, maxDepth int) ([]CachedDirTotals, error) {
+	prefix = filepath.Clean(prefix)
+	top := make([]CachedDirTotals, 0, n)
+	if n <= 0 {
+		return top, nil
+	}
+
+	err := s.Iterate(string(s.makeKey(prefix)), func(key, value []byte) error {
+		path := string(key[len(KeyPrefixDirMetadata):])
+		if !inSubtree(path, prefix) {
+			return nil
+		}
+		depth := subtreeDepth(path, prefix)
+		if depth < minDepth || (maxDepth > 0 && depth > maxDepth) {
+			return nil
+		}
+		meta, err := decodeDirMetadata(path, value)
+		if err != nil || meta.DuplicateOf != "" {
+			return nil
+		}
+		totals := CachedDirTotals{
+			Path:      path,
+			Size:      meta.Size,
+			Usage:     meta.Usage,
+			ItemCount: meta.ItemCount,
+			CachedAt:  meta.CachedAt,
+		}
+		if len(top) == n && !rankedBefore(totals, top[n-1]) {
+			return nil
+		}
+
+		i := sort.Search(len(top), func(i int) bool {
+			return rankedBefore(totals, top[i])
+		})
+		if len(top) < n {
+			top = append(top, CachedDirTotals{})
+		}
+		copy(top[i+1:], top[i:len(top)-1])
+		top[i] = totals
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return top, nil
+}
+
+// rankedBefore returns true if a is ranked before b by TopDirsBySize
+func rankedBefore(a, b CachedDirTotals) bool {
+	if a.Usage != b.Usage {
+		return a.Usage > b.Usage
+	}
+	return a.Path < b.Path
+}
+
+// subtreeDepth returns level of path below dir, which must be in subtree of dir
+func subtreeDepth(path, dir string) int {
+	rest := strings.Trim(path[len(dir):], string(filepath.Separator))
+	if rest == "" {
+		return 0
+	}
+	return strings.Count(rest, string(filepath.Separator)) + 1
+}
@@ -0,0 +1,100 @@
+package analyze
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func seedTopDirsCache(t *testing.T, storage *IncrementalStorage) {
+	t.Helper()
+	usages := map[string]int64{
+		"/data":             1000,
+		"/data/a":           600,
+		"/data/a/big":       500,
+		"/data/a/small":     50,
+		"/data/b":           300,
+		"/data/b/mid":       250,
+		"/data/c":           60,
+		"/data-other":       5000, // not under /data
+		"/data/b/mid/deep1": 240,
+	}
+	for path, usage := range usages {
+		assert.NoError(t, storage.StoreDirMetadata(&IncrementalDirMetadata{
+			Path:      path,
+			Size:      usage / 2,
+			Usage:     usage,
+			ItemCount: int(usage / 10),
+			CachedAt:  time.Now(),
+		}))
+	}
+	// duplicates have no totals of their own
+	assert.NoError(t, storage.StoreDirMetadata(&IncrementalDirMetadata{
+		Path: "/data/bind", Usage: 900, CachedAt: time.Now(), DuplicateOf: "/data/a",
+	}))
+}
+
+func topPaths(top []CachedDirTotals) []string {
+	paths := make([]string, 0, len(top))
+	for _, d := range top {
+		paths = append(paths, d.Path)
+	}
+	return paths
+}
+
+func TestIncrementalStorage_TopDirsBySize(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/data")
+	closeFn, err := storage.Open()
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer closeFn()
+	seedTopDirsCache(t, storage)
+
+	top, err := storage.TopDirsBySize("/data", 3, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/data", "/data/a", "/data/a/big"}, topPaths(top))
+	assert.Equal(t, int64(600), top[1].Usage)
+	assert.Equal(t, int64(300), top[1].Size)
+	assert.Equal(t, 60, top[1].ItemCount)
+	assert.False(t, top[1].CachedAt.IsZero())
+
+	// children of the prefix only
+	top, err = storage.TopDirsBySize("/data", 10, 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/data/a", "/data/b", "/data/c"}, topPaths(top))
+
+	top, err = storage.TopDirsBySize("/data/", 2, 2, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/data/a/big", "/data/b/mid"}, topPaths(top))
+
+	top, err = storage.TopDirsBySize("/data/b", 10, 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/data/b/mid", "/data/b/mid/deep1"}, topPaths(top))
+
+	top, err = storage.TopDirsBySize("/missing", 10, 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, top)
+
+	top, err = storage.TopDirsBySize("/data", 0, 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, top)
+}
+
+func TestIncrementalStorage_TopDirsBySizeTies(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/data")
+	closeFn, err := storage.Open()
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer closeFn()
+
+	for _, path := range []string{"/data/z", "/data/y", "/data/x"} {
+		assert.NoError(t, storage.StoreDirMetadata(&IncrementalDirMetadata{Path: path, Usage: 10, CachedAt: time.Now()}))
+	}
+
+	top, err := storage.TopDirsBySize("/data", 2, 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/data/x", "/data/y"}, topPaths(top))
+}
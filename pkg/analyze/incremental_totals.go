@@ -0,0 +1,64 @@
+package analyze
+
+import (
+	"time"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// SubtreeTotals are totals of an item and all its descendants,
+// e.g. the space freed by deletion of the item
+type SubtreeTotals struct {
+	Size      int64     // apparent size
+	Usage     int64     // disk usage
+	ItemCount int       // number of items including the item itself
+	CachedAt  time.Time // when the totals were stored in the cache, zero for totals of the loaded tree
+}
+
+// IsCached returns true if the totals were read from the cache
+func (t *SubtreeTotals) IsCached() bool {
+	return !t.CachedAt.IsZero()
+}
+
+// LoadedTotals returns totals of the item as it is loaded in memory
+func LoadedTotals(item fs.Item) *SubtreeTotals {
+	return &SubtreeTotals{
+		Size:      item.GetSize(),
+		Usage:     item.GetUsage(),
+		ItemCount: item.GetItemCount(),
+	}
+}
+
+// SubtreeTotals returns totals of directory at path stored in its cache entry
+func (s *IncrementalStorage) SubtreeTotals(path string) (*SubtreeTotals, error) {
+	meta, err := s.LoadDirMetadata(path)
+	if err != nil {
+		return nil, err
+	}
+	return &SubtreeTotals{
+		Size:      meta.Size,
+		Usage:     meta.Usage,
+		ItemCount: meta.ItemCount,
+		CachedAt:  meta.CachedAt,
+	}, nil
+}
+
+// SubtreeTotals returns totals of the item read from its cache entry, so the tree is not walked.
+// Totals of the loaded item are returned if it is not a directory, it is not cached
+// or the cache can't be opened (e.g. during a scan)
+func (a *IncrementalAnalyzer) SubtreeTotals(item fs.Item) *SubtreeTotals {
+	if !item.IsDir() {
+		return LoadedTotals(item)
+	}
+
+	var totals *SubtreeTotals
+	err := a.withStorage(item.GetPath(), func(storage *IncrementalStorage) error {
+		var err error
+		totals, err = storage.SubtreeTotals(item.GetPath())
+		return err
+	})
+	if err != nil {
+		return LoadedTotals(item)
+	}
+	return totals
+}
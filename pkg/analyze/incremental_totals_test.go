@@ -0,0 +1,42 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalAnalyzer_SubtreeTotals(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "root")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "sub", "deep"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "sub", "file"), make([]byte, 1000), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "sub", "deep", "file"), make([]byte, 10), 0o600))
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+	analyzer.GetDone().Wait()
+	sub := childByName(dir, "sub").(*Dir)
+
+	totals := analyzer.SubtreeTotals(sub)
+	assert.True(t, totals.IsCached())
+	assert.Equal(t, sub.GetSize(), totals.Size)
+	assert.Equal(t, sub.GetUsage(), totals.Usage)
+	assert.Equal(t, 4, totals.ItemCount)
+
+	// files are not cached separately
+	file := childByName(sub, "file")
+	totals = analyzer.SubtreeTotals(file)
+	assert.False(t, totals.IsCached())
+	assert.Equal(t, int64(1000), totals.Size)
+	assert.Equal(t, 1, totals.ItemCount)
+
+	// directory missing from the cache
+	missing := &Dir{File: &File{Name: "missing", Size: 5, Usage: 4096}, BasePath: root, ItemCount: 1}
+	assert.Equal(t, &SubtreeTotals{Size: 5, Usage: 4096, ItemCount: 1}, analyzer.SubtreeTotals(missing))
+
+	// the cache is used by a running scan
+	analyzer.scanning.Store(true)
+	assert.False(t, analyzer.SubtreeTotals(sub).IsCached())
+}
@@ -0,0 +1,149 @@
+package analyze
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultTraceLimit is the number of trace entries kept when IncrementalOptions.TraceLimit is not set
+const DefaultTraceLimit = 10000
+
+// CacheDecision tells how the incremental analyzer got the content of a directory
+type CacheDecision string
+
+const (
+	// DecisionHit - mtime matches the cache entry, the directory was loaded from the cache
+	DecisionHit CacheDecision = "hit"
+	// DecisionInherited - the directory was loaded from the cache together with its parent
+	// (which was a hit), its own mtime was not checked
+	DecisionInherited CacheDecision = "inherited"
+	// DecisionMiss - there was no usable cache entry, the directory was scanned
+	DecisionMiss CacheDecision = "miss"
+	// DecisionExpired - the cache entry was older than the max age, the directory was scanned
+	DecisionExpired CacheDecision = "expired"
+	// DecisionForced - full scan was forced, the cache was not read
+	DecisionForced CacheDecision = "forced"
+	// DecisionChanged - mtime differs from the cache entry, the directory was scanned
+	DecisionChanged CacheDecision = "changed"
+	// DecisionEstimated - the cache entry was an estimate and the estimation mode is off,
+	// the directory was scanned
+	DecisionEstimated CacheDecision = "estimated"
+	// DecisionDuplicate - the directory was already visited by another path, added as a reference
+	DecisionDuplicate CacheDecision = "duplicate"
+	// DecisionError - the directory could not be stat'ed
+	DecisionError CacheDecision = "error"
+	// DecisionFuture - the cache entry or the directory had a timestamp in the future
+	// (beyond the allowed clock skew), the directory was scanned
+	DecisionFuture CacheDecision = "future"
+	// DecisionVerified - the cache entry imported from the legacy storage had no mtime,
+	// the listing of the directory matched it, so it was loaded from the cache
+	DecisionVerified CacheDecision = "verified"
+	// DecisionVanished - the directory was listed by its parent but removed before it was read,
+	// it is left out of the tree and the cache
+	DecisionVanished CacheDecision = "vanished"
+	// DecisionSummary - mtime of the top directory matched the summary of the previous clean scan
+	// (IncrementalOptions.TrustRootMtime), only the top directory was loaded from the cache
+	DecisionSummary CacheDecision = "summary"
+	// DecisionOptions - the cache entry was written with other excluded file patterns
+	// or IncrementalOptions.NoCross, the directory was scanned
+	DecisionOptions CacheDecision = "options"
+	// DecisionTooDeep - the directory is deeper than IncrementalOptions.MaxDepth,
+	// only its own size was read
+	DecisionTooDeep CacheDecision = "too-deep"
+	// DecisionContent - mtime matches the cache entry, but the fingerprint of the children
+	// of the hash-verified directory (IncrementalOptions.HashVerifyPrefixes) differs, the directory was scanned
+	DecisionContent CacheDecision = "content"
+	// DecisionListing - mtime matches the cache entry, but the listing of the directory differs
+	// from it (ValidateMtimeCount of IncrementalOptions.ValidationMode), the directory was scanned
+	DecisionListing CacheDecision = "listing"
+	// DecisionTrusted - the directory was not invalidated (IncrementalOptions.TrustUnlisted),
+	// it was loaded from the cache without checking its mtime
+	DecisionTrusted CacheDecision = "trusted"
+	// DecisionMount - the directory is on another filesystem than the scanned one
+	// (IncrementalOptions.NoCross), it was left out
+	DecisionMount CacheDecision = "mount"
+)
+
+// TraceEntry records the decision made for one directory
+type TraceEntry struct {
+	Path         string
+	Decision     CacheDecision
+	CachedMtime  int64         // mtime of the cache entry in UnixNano, 0 if there was none
+	CurrentMtime int64         // mtime on the filesystem in UnixNano, 0 if not checked
+	CacheAge     time.Duration // age of the cache entry, 0 if there was none
+	MaxAge       time.Duration // configured max age of cache entries, 0 if not set
+}
+
+// DecisionTrace collects decisions of one scan up to a limit, further entries are only counted
+type DecisionTrace struct {
+	m       sync.Mutex
+	entries *BoundedList[TraceEntry]
+}
+
+func newDecisionTrace(limit int) *DecisionTrace {
+	if limit <= 0 {
+		limit = DefaultTraceLimit
+	}
+	return &DecisionTrace{entries: NewBoundedList[TraceEntry](limit)}
+}
+
+// Entries returns copy of the collected entries in the order the decisions were made
+func (t *DecisionTrace) Entries() []TraceEntry {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.entries.Items()
+}
+
+// Len returns number of the collected entries
+func (t *DecisionTrace) Len() int {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.entries.Len()
+}
+
+// Dropped returns number of entries which did not fit into the limit
+func (t *DecisionTrace) Dropped() int {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return int(t.entries.Dropped())
+}
+
+func (t *DecisionTrace) add(entry TraceEntry) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.entries.Add(entry)
+}
+
+// GetDecisionTrace returns decisions of the last scan, nil if tracing is not enabled
+func (a *IncrementalAnalyzer) GetDecisionTrace() *DecisionTrace {
+	return a.trace
+}
+
+// traceDecision records the decision for path if tracing or recording of decisions is enabled.
+// cached and current may be nil if there was no cache entry or the directory was not stat'ed
+func (a *IncrementalAnalyzer) traceDecision(
+	path string, decision CacheDecision, cached *IncrementalDirMetadata, current os.FileInfo,
+) {
+	a.decisions.add(path, decision)
+	if a.trace == nil {
+		return
+	}
+
+	entry := TraceEntry{Path: path, Decision: decision, MaxAge: a.cacheMaxAge}
+	if cached != nil {
+		entry.CachedMtime = cached.Mtime.UnixNano()
+		entry.CacheAge = cacheAge(cached.CachedAt)
+	}
+	if current != nil {
+		entry.CurrentMtime = current.ModTime().UnixNano()
+	}
+	a.trace.add(entry)
+
+	log.Printf(
+		"Cache decision for %s: %s (cached mtime %d, current mtime %d, age %s, max age %s)",
+		path, decision, entry.CachedMtime, entry.CurrentMtime, entry.CacheAge, entry.MaxAge,
+	)
+}
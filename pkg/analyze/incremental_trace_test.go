@@ -0,0 +1,129 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTraceFixture(t *testing.T) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "root")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b"), 0o755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "c"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a", "file"), []byte("hello"), 0o600))
+	return root
+}
+
+func traceScan(t *testing.T, root string, opts IncrementalOptions) map[string]TraceEntry {
+	t.Helper()
+	opts.TraceDecisions = true
+	analyzer := CreateIncrementalAnalyzer(opts)
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	entries := make(map[string]TraceEntry)
+	for _, entry := range analyzer.GetDecisionTrace().Entries() {
+		rel, err := filepath.Rel(root, entry.Path)
+		assert.NoError(t, err)
+		_, ok := entries[rel]
+		assert.False(t, ok, "Directory %s is traced once", rel)
+		entries[rel] = entry
+	}
+	return entries
+}
+
+func decisions(entries map[string]TraceEntry) map[string]CacheDecision {
+	res := make(map[string]CacheDecision, len(entries))
+	for path, entry := range entries {
+		res[path] = entry.Decision
+	}
+	return res
+}
+
+func TestIncrementalAnalyzer_TraceDecisions(t *testing.T) {
+	root := createTraceFixture(t)
+	storagePath := t.TempDir()
+
+	entries := traceScan(t, root, IncrementalOptions{StoragePath: storagePath})
+	assert.Equal(t, map[string]CacheDecision{
+		".":   DecisionMiss,
+		"a":   DecisionMiss,
+		"a/b": DecisionMiss,
+		"c":   DecisionMiss,
+	}, decisions(entries))
+	assert.Equal(t, int64(0), entries["."].CachedMtime)
+	assert.NotEqual(t, int64(0), entries["."].CurrentMtime)
+
+	// unchanged tree is loaded from the cache entry of the root
+	entries = traceScan(t, root, IncrementalOptions{StoragePath: storagePath})
+	assert.Equal(t, map[string]CacheDecision{
+		".":   DecisionHit,
+		"a":   DecisionInherited,
+		"a/b": DecisionInherited,
+		"c":   DecisionInherited,
+	}, decisions(entries))
+	assert.Equal(t, entries["."].CachedMtime, entries["."].CurrentMtime)
+	assert.Greater(t, entries["."].CacheAge, time.Duration(0))
+	assert.NotEqual(t, int64(0), entries["a"].CachedMtime)
+	assert.Equal(t, int64(0), entries["a"].CurrentMtime, "Mtime of inherited directory is not checked")
+
+	// changed root is scanned, its subdirectories are checked again
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "new"), []byte("x"), 0o600))
+	assert.NoError(t, os.Chtimes(root, time.Now(), time.Now().Add(time.Hour)))
+	entries = traceScan(t, root, IncrementalOptions{StoragePath: storagePath})
+	assert.Equal(t, map[string]CacheDecision{
+		".":   DecisionChanged,
+		"a":   DecisionHit,
+		"a/b": DecisionInherited,
+		"c":   DecisionHit,
+	}, decisions(entries))
+	assert.NotEqual(t, entries["."].CachedMtime, entries["."].CurrentMtime)
+}
+
+func TestIncrementalAnalyzer_TraceForcedAndExpired(t *testing.T) {
+	root := createTraceFixture(t)
+	storagePath := t.TempDir()
+
+	entries := traceScan(t, root, IncrementalOptions{StoragePath: storagePath, ForceFullScan: true})
+	assert.Len(t, entries, 4)
+	for path, entry := range entries {
+		assert.Equal(t, DecisionForced, entry.Decision, path)
+	}
+
+	entries = traceScan(t, root, IncrementalOptions{StoragePath: storagePath, CacheMaxAge: time.Nanosecond})
+	assert.Len(t, entries, 4)
+	for path, entry := range entries {
+		assert.Equal(t, DecisionExpired, entry.Decision, path)
+		assert.Equal(t, time.Nanosecond, entry.MaxAge)
+		assert.Greater(t, entry.CacheAge, entry.MaxAge)
+	}
+}
+
+func TestIncrementalAnalyzer_TraceLimit(t *testing.T) {
+	root := createTraceFixture(t)
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{
+		StoragePath:    t.TempDir(),
+		TraceDecisions: true,
+		TraceLimit:     3,
+	})
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	assert.Len(t, analyzer.GetDecisionTrace().Entries(), 3)
+	assert.Equal(t, 1, analyzer.GetDecisionTrace().Dropped())
+}
+
+func TestIncrementalAnalyzer_TraceDisabled(t *testing.T) {
+	root := createTraceFixture(t)
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	assert.Nil(t, analyzer.GetDecisionTrace())
+}
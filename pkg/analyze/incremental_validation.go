@@ -0,0 +1,80 @@
+package analyze
+
+import "fmt"
+
+// ValidationMode selects how the incremental analyzer checks that a cache entry is still valid
+type ValidationMode int
+
+const (
+	// ValidateMtime trusts the cache entry of a directory whose mtime (and ctime) did not change,
+	// subdirectories of such a directory are loaded from the cache without checking them.
+	// It is the default mode
+	ValidateMtime ValidationMode = iota
+	// ValidateMtimeCount checks every directory and lists it even if its mtime did not change,
+	// the cache entry is used only if names of the children match it. It is meant for NFS,
+	// whose attribute caching can delay the change of mtime. It costs one read per directory
+	ValidateMtimeCount
+)
+
+var validationModeNames = map[ValidationMode]string{
+	ValidateMtime:      "mtime",
+	ValidateMtimeCount: "mtime+count",
+}
+
+// String returns name of the mode accepted by ParseValidationMode
+func (m ValidationMode) String() string {
+	if name, ok := validationModeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("ValidationMode(%d)", int(m))
+}
+
+// ParseValidationMode returns the mode of the given name, empty name selects ValidateMtime
+func ParseValidationMode(name string) (ValidationMode, error) {
+	if name == "" {
+		return ValidateMtime, nil
+	}
+	for mode, modeName := range validationModeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+	return ValidateMtime, fmt.Errorf("unknown validation mode %q (use mtime or mtime+count)", name)
+}
+
+// listingChanged lists the cached directory and returns true if names of its children
+// differ from the cache entry (see ValidateMtimeCount). Excluded files, ignored subdirectories
+// and subdirectories on other filesystems with NoCross are not cached, children which could not
+// be read and files left out of an estimate are expected to be missing from the entry
+func (a *IncrementalAnalyzer) listingChanged(cached *IncrementalDirMetadata) bool {
+	a.stats.IncrementValidationReads()
+	entries, err := a.readDirRetried(cached.Path)
+	if err != nil {
+		return true
+	}
+
+	names := make(map[string]bool, len(cached.Files))
+	for _, f := range cached.Files {
+		names[f.Name] = f.IsDir
+	}
+	matched, unknown := 0, 0
+	for _, entry := range entries {
+		name := entry.Name()
+		entryPath := joinPath(cached.Path, name)
+		entryIsDir := entry.IsDir() || a.isDirLink(entry, entryPath)
+		if entryIsDir && a.ignoreDir(name, entryPath) || !entryIsDir && a.isExcludedFile(name) {
+			continue
+		}
+		if isDir, ok := names[name]; ok && isDir == entryIsDir {
+			matched++
+		} else if !entryIsDir || !a.onOtherDevice(entryPath) {
+			unknown++
+		}
+	}
+
+	skipped := 0
+	if cached.Estimate != nil {
+		skipped = cached.Estimate.Skipped
+	}
+	return matched != len(cached.Files) || unknown < skipped || unknown > skipped+cached.ErrorCount
+}
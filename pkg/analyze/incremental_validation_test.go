@@ -0,0 +1,93 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseValidationMode(t *testing.T) {
+	for name, expected := range map[string]ValidationMode{
+		"":            ValidateMtime,
+		"mtime":       ValidateMtime,
+		"mtime+count": ValidateMtimeCount,
+	} {
+		mode, err := ParseValidationMode(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, expected, mode, name)
+	}
+	assert.Equal(t, "mtime+count", ValidateMtimeCount.String())
+
+	_, err := ParseValidationMode("count")
+	assert.ErrorContains(t, err, `unknown validation mode "count"`)
+}
+
+func TestIncrementalAnalyzer_ValidationMode(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	for _, dir := range []string{sub, filepath.Join(root, "ignored")} {
+		assert.NoError(t, os.MkdirAll(dir, 0o755))
+	}
+	for _, name := range []string{"file", "lost", "core.tmp"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(sub, name), []byte("data"), 0o600))
+	}
+	opts := IncrementalOptions{
+		StoragePath:    t.TempDir(),
+		ExcludeFiles:   []string{"*.tmp"},
+		ValidationMode: ValidateMtimeCount,
+		TraceDecisions: true,
+	}
+
+	scan := func(opts IncrementalOptions) (*Dir, *IncrementalAnalyzer) {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		dir := analyzer.AnalyzeDir(root, func(name, _ string) bool { return name == "ignored" }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		return dir, analyzer
+	}
+	scan(opts)
+
+	// excluded files and ignored directories are not expected in the entries
+	_, warm := scan(opts)
+	stats := warm.GetCacheStats()
+	assert.Equal(t, int64(3), stats.ValidationReads)
+	assert.Zero(t, stats.ValidationRescans)
+	assert.Equal(t, int64(3), stats.CacheHits)
+
+	// the entry missing a file added without change of mtime, as seen on NFS
+	storage := NewIncrementalStorage(opts.StoragePath, root)
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	meta, err := storage.LoadDirMetadata(sub)
+	assert.NoError(t, err)
+	files := meta.Files[:0]
+	for _, f := range meta.Files {
+		if f.Name != "lost" {
+			files = append(files, f)
+		}
+	}
+	meta.Files = files
+	meta.ItemCount--
+	assert.NoError(t, storage.StoreDirMetadata(meta))
+	closeFn()
+
+	// subdirectories of the unchanged directory are trusted by default
+	mtimeOpts := opts
+	mtimeOpts.ValidationMode = ValidateMtime
+	dir, trusted := scan(mtimeOpts)
+	assert.Zero(t, trusted.GetCacheStats().ValidationReads)
+	assert.Equal(t, 4, dir.ItemCount)
+
+	dir, changed := scan(opts)
+	stats = changed.GetCacheStats()
+	assert.Equal(t, int64(1), stats.ValidationRescans)
+	assert.Equal(t, int64(1), stats.DirsRescanned)
+	assert.Equal(t, 5, dir.ItemCount)
+	decisions := make(map[string]CacheDecision)
+	for _, entry := range changed.GetDecisionTrace().Entries() {
+		decisions[entry.Path] = entry.Decision
+	}
+	assert.Equal(t, DecisionListing, decisions[sub])
+	assert.Equal(t, DecisionHit, decisions[filepath.Join(root, "a")])
+}
@@ -0,0 +1,50 @@
+package analyze
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// volatileFSProbe returns name of the in-memory filesystem of path, empty if it is not one.
+// Replaced in tests
+var volatileFSProbe = volatileFSType
+
+// volatileDirs are locations usually cleaned on reboot regardless of the filesystem.
+// /var/tmp is left out, it is preserved across reboots
+var volatileDirs = []string{"/tmp", "/dev/shm", "/run", "/var/run", "/private/tmp"}
+
+// checkVolatileStorage warns in the statistics and the log if the cache directory is likely
+// lost on reboot, unless silenced by IncrementalOptions.AllowVolatileCache.
+// It is advisory only, the cache is used either way
+func (a *IncrementalAnalyzer) checkVolatileStorage() {
+	if a.volatileOK {
+		return
+	}
+	reason := volatileStorage(a.storagePath)
+	if reason == "" {
+		return
+	}
+	a.stats.SetVolatileStorage(reason)
+
+	if a.volatileLogged {
+		return
+	}
+	a.volatileLogged = true
+	log.Warnf("Cache directory %s, the cache will be lost on reboot "+
+		"and the next scan will be cold. Use --incremental-path to move it", reason)
+}
+
+// volatileStorage returns why the storage path is likely lost on reboot, empty if it is not
+func volatileStorage(storagePath string) string {
+	path := canonicalPath(storagePath)
+	if fsType := volatileFSProbe(path); fsType != "" {
+		return fmt.Sprintf("%s is on %s", path, fsType)
+	}
+	for _, dir := range volatileDirs {
+		if inSubtree(path, dir) {
+			return fmt.Sprintf("%s is under %s", path, dir)
+		}
+	}
+	return ""
+}
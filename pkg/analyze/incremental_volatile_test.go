@@ -0,0 +1,72 @@
+package analyze
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// probeVolatile makes the filesystem of paths under dir reported as fsType
+// and no location known as volatile for the rest of the test
+func probeVolatile(t *testing.T, dir, fsType string) {
+	t.Helper()
+	probe, dirs := volatileFSProbe, volatileDirs
+	t.Cleanup(func() { volatileFSProbe, volatileDirs = probe, dirs })
+
+	volatileDirs = nil
+	volatileFSProbe = func(path string) string {
+		if inSubtree(path, canonicalPath(dir)) {
+			return fsType
+		}
+		return ""
+	}
+}
+
+func TestIncrementalAnalyzer_VolatileStorage(t *testing.T) {
+	root := createTreeWithCacheDir(t)
+	storage := filepath.Join(t.TempDir(), "cache")
+	probeVolatile(t, storage, "tmpfs")
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	scan := func(opts IncrementalOptions) *CacheStats {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+		analyzer.GetDone().Wait()
+		return analyzer.GetScanResult().Stats
+	}
+
+	stats := scan(IncrementalOptions{StoragePath: storage})
+	assert.Equal(t, canonicalPath(storage)+" is on tmpfs", stats.VolatileStorage)
+	assert.Contains(t, logged.String(), "is on tmpfs, the cache will be lost on reboot")
+	// advisory only, the cache is used
+	assert.Equal(t, 100.0, scan(IncrementalOptions{StoragePath: storage}).HitRate())
+
+	logged.Reset()
+	stats = scan(IncrementalOptions{StoragePath: storage, AllowVolatileCache: true})
+	assert.Empty(t, stats.VolatileStorage)
+	assert.NotContains(t, logged.String(), "tmpfs")
+
+	stats = scan(IncrementalOptions{StoragePath: filepath.Join(t.TempDir(), "cache")})
+	assert.Empty(t, stats.VolatileStorage)
+}
+
+func TestVolatileStorage(t *testing.T) {
+	assert.NotContains(t, volatileDirs, "/var/tmp", "/var/tmp survives reboots")
+
+	tmp := t.TempDir()
+	probeVolatile(t, filepath.Join(tmp, "shm"), "ramfs")
+	volatileDirs = []string{filepath.Join(tmp, "tmp")}
+
+	assert.Equal(t, filepath.Join(tmp, "shm", "gdu")+" is on ramfs", volatileStorage(filepath.Join(tmp, "shm", "gdu")))
+	assert.Equal(t, filepath.Join(tmp, "tmp", "gdu")+" is under "+filepath.Join(tmp, "tmp"),
+		volatileStorage(filepath.Join(tmp, "tmp", "gdu")))
+	assert.Empty(t, volatileStorage(filepath.Join(tmp, "tmp-not", "gdu")))
+	assert.Empty(t, volatileStorage(filepath.Join(tmp, "cache", "gdu")))
+}
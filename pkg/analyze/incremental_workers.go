@@ -0,0 +1,143 @@
+package analyze
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dundee/gdu/v5/internal/common"
+	log "github.com/sirupsen/logrus"
+)
+
+// scanBranch is the state of the recursion of a single goroutine of the running scan.
+// Subdirectories processed by another worker get their own branch, which is joined
+// to the branch of their parent once they are done
+type scanBranch struct {
+	depth     int                    // depth of the directory being processed below the scanned one
+	reported  common.CurrentProgress // totals sent as progress by the branch
+	accounted time.Duration          // time accounted to directories by the branch
+}
+
+// fork returns the branch of a subdirectory processed by another worker
+func (b *scanBranch) fork() *scanBranch {
+	return &scanBranch{depth: b.depth}
+}
+
+// join adds the progress and the time of the finished forked branch
+func (b *scanBranch) join(forked *scanBranch) {
+	b.reported.ItemCount += forked.reported.ItemCount
+	b.reported.TotalSize += forked.reported.TotalSize
+	b.accounted += forked.accounted
+}
+
+// workerPool bounds the goroutines processing subdirectories of scanned directories.
+// A subdirectory is processed by the goroutine of its parent when all workers are busy,
+// so the workers never wait for each other and the recursion can't deadlock
+type workerPool struct {
+	slots chan struct{}
+}
+
+// newWorkerPool returns pool of workers goroutines including the one running the scan,
+// nil if workers is lower than 2 (subdirectories are processed one by one)
+func newWorkerPool(workers int) *workerPool {
+	if workers < 2 {
+		return nil
+	}
+	return &workerPool{slots: make(chan struct{}, workers-1)}
+}
+
+// tryGo runs fn in a new goroutine added to wait if a worker is free.
+// False is returned if no worker is free, fn has to be run by the caller then
+func (p *workerPool) tryGo(wait *sync.WaitGroup, fn func()) bool {
+	if p == nil {
+		return false
+	}
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		return false
+	}
+	wait.Add(1)
+	go func() {
+		defer wait.Done()
+		defer func() { <-p.slots }()
+		fn()
+	}()
+	return true
+}
+
+// listedSubdir is a subdirectory read from the listing of a scanned directory
+type listedSubdir struct {
+	index   int    // position in the listing
+	path    string // path of the subdirectory
+	existed bool   // the subdirectory was in the previous cache entry of its parent
+	dir     *Dir   // processed subdirectory, nil if it was removed since the listing or not processed
+}
+
+// processSubdirs processes the listed subdirectories of the scanned directory at path,
+// each in a free worker or in the calling goroutine, and returns once all of them are done.
+// Subdirectories not started before the scan was cancelled are left unprocessed.
+// A panic of a worker is raised again here, so it fails the scan the same way
+// as a panic of the goroutine running it
+func (a *IncrementalAnalyzer) processSubdirs(b *scanBranch, path string, subdirs []listedSubdir) {
+	var (
+		wait     sync.WaitGroup
+		m        sync.Mutex
+		panicked any
+	)
+	// the workers are done before a panic of the calling goroutine unwinds the scan
+	defer wait.Wait()
+
+	forked := make([]*scanBranch, 0)
+	for i := range subdirs {
+		if a.ctx.Err() != nil {
+			break
+		}
+		subdir := &subdirs[i]
+		branch := b.fork()
+		started := a.workers.tryGo(&wait, func() {
+			defer func() {
+				if r := recover(); r != nil {
+					m.Lock()
+					panicked = r
+					m.Unlock()
+				}
+			}()
+			a.processSubdir(branch, path, subdir)
+		})
+		if started {
+			forked = append(forked, branch)
+			continue
+		}
+		a.processSubdir(b, path, subdir)
+	}
+	wait.Wait()
+
+	for _, branch := range forked {
+		b.join(branch)
+	}
+	if panicked != nil {
+		panic(panicked)
+	}
+}
+
+// processSubdir processes the listed subdirectory of the scanned directory at path in the branch b
+func (a *IncrementalAnalyzer) processSubdir(b *scanBranch, path string, subdir *listedSubdir) {
+	subdir.dir = a.processListedDir(b, subdir.path)
+	if subdir.dir != nil {
+		a.snapshot.add(path, subdir.dir)
+	}
+}
+
+// listScannedDir returns listing of the directory at path. All workers share the I/O throttle,
+// so the listings are limited the same way as when the directories are scanned one by one
+func (a *IncrementalAnalyzer) listScannedDir(path string) ([]os.DirEntry, error) {
+	// Apply I/O throttling before directory read (if enabled)
+	if a.throttle != nil {
+		if err := a.throttle.Acquire(a.ctx); err != nil {
+			// This should only happen on cancellation
+			log.Printf("Throttle error for %s: %v", path, err)
+		}
+	}
+	return a.readDirRetried(path)
+}
@@ -0,0 +1,143 @@
+package analyze
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrementalAnalyzer_WorkersSameTree(t *testing.T) {
+	root := t.TempDir()
+	createWideTree(t, root, 4, 3)
+
+	scan := func(workers int) (*Dir, string) {
+		analyzer := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir(), Workers: workers})
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+		assert.Equal(t, ScanCompleted, analyzer.GetScanResult().Status)
+		assert.Equal(t, int64(85), analyzer.GetCacheStats().TotalDirs)
+
+		buff := &bytes.Buffer{}
+		assert.NoError(t, dir.EncodeJSON(buff, true))
+		return dir, buff.String()
+	}
+	serial, serialJSON := scan(1)
+	parallel, parallelJSON := scan(8)
+
+	assert.Equal(t, serial.Size, parallel.Size)
+	assert.Equal(t, serial.Usage, parallel.Usage)
+	assert.Equal(t, serial.ItemCount, parallel.ItemCount)
+	assert.Equal(t, 2*(1+4+16+64), parallel.ItemCount)
+	assert.Equal(t, flattenTree(serial), flattenTree(parallel))
+	assert.Equal(t, serialJSON, parallelJSON)
+}
+
+func TestIncrementalAnalyzer_WorkersThrottled(t *testing.T) {
+	root := t.TempDir()
+	createWideTree(t, root, 4, 2)
+
+	// returns the highest number of directories listed at the same time
+	scan := func(workers int) int32 {
+		analyzer := CreateIncrementalAnalyzer(IncrementalOptions{
+			StoragePath: t.TempDir(),
+			Workers:     workers,
+			MaxIOPS:     100000,
+		})
+		var listing, most atomic.Int32
+		analyzer.listDir = func(path string) ([]os.DirEntry, error) {
+			current := listing.Add(1)
+			defer listing.Add(-1)
+			for {
+				highest := most.Load()
+				if current <= highest || most.CompareAndSwap(highest, current) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return os.ReadDir(path)
+		}
+		dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*Dir)
+		analyzer.GetDone().Wait()
+
+		assert.Equal(t, ScanCompleted, analyzer.GetScanResult().Status)
+		assert.Equal(t, 2*(1+4+16), dir.ItemCount)
+		return most.Load()
+	}
+	// the workers go through the throttle instead of being dropped with it
+	assert.Greater(t, scan(4), int32(1))
+	assert.LessOrEqual(t, scan(4), int32(4))
+	assert.Equal(t, int32(1), scan(1))
+}
+
+func TestIncrementalStorage_ConcurrentStores(t *testing.T) {
+	storage := NewIncrementalStorage(t.TempDir(), "/dir")
+	storage.pageSize = 10
+	mustOpen(t, storage)
+
+	var wait sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for i := 0; i < 20; i++ {
+				// every other directory is stored in pages
+				path := fmt.Sprintf("/dir/w%d/d%d", worker, i)
+				assert.NoError(t, storage.StoreDirMetadata(largeDirMetadata(path, 5+i%2*20)))
+			}
+		}()
+	}
+	wait.Wait()
+
+	for worker := 0; worker < 8; worker++ {
+		for i := 0; i < 20; i++ {
+			meta, err := storage.LoadDirMetadata(fmt.Sprintf("/dir/w%d/d%d", worker, i))
+			if assert.NoError(t, err) {
+				assert.Len(t, meta.Files, 5+i%2*20)
+			}
+		}
+	}
+}
+
+// benchmarkColdScan scans a tree of 585 directories with an empty cache,
+// every listing takes at least latency (e.g. a round trip to an NFS server)
+func benchmarkColdScan(b *testing.B, workers int, latency time.Duration) {
+	root := b.TempDir()
+	createWideTree(b, root, 8, 3)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		opts := IncrementalOptions{StoragePath: b.TempDir(), Workers: workers}
+		b.StartTimer()
+
+		analyzer := CreateIncrementalAnalyzer(opts)
+		analyzer.listDir = func(path string) ([]os.DirEntry, error) {
+			time.Sleep(latency)
+			return os.ReadDir(path)
+		}
+		analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+		analyzer.GetDone().Wait()
+	}
+}
+
+func BenchmarkColdScanSerial(b *testing.B) {
+	benchmarkColdScan(b, 1, 0)
+}
+
+func BenchmarkColdScanParallel(b *testing.B) {
+	benchmarkColdScan(b, 8, 0)
+}
+
+func BenchmarkColdScanSerialLatency(b *testing.B) {
+	benchmarkColdScan(b, 1, time.Millisecond)
+}
+
+func BenchmarkColdScanParallelLatency(b *testing.B) {
+	benchmarkColdScan(b, 8, time.Millisecond)
+}
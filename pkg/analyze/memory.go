@@ -1,27 +1,165 @@
 package analyze
 
 import (
+	"fmt"
 	"runtime"
 	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pbnjay/memory"
 	log "github.com/sirupsen/logrus"
 )
 
+// MemoryMode selects how the garbage collector runs during a scan
+type MemoryMode int
+
+const (
+	// MemoryAggressive disables GC during the scan and enables it only when gdu uses
+	// more memory than is free on the host. It is the fastest mode and the default one
+	MemoryAggressive MemoryMode = iota
+	// MemoryBalanced keeps GC enabled at a fixed percent for the whole scan,
+	// which trades some speed for a lower peak of memory on small hosts
+	MemoryBalanced
+	// MemoryConstant leaves GC as set by GOGC, same as the constGC argument of AnalyzeDir
+	MemoryConstant
+)
+
+// DefaultBalancedGCPercent is the GC percent of MemoryBalanced if no other is set
+const DefaultBalancedGCPercent = 50
+
+var memoryModeNames = map[MemoryMode]string{
+	MemoryAggressive: "aggressive",
+	MemoryBalanced:   "balanced",
+	MemoryConstant:   "constant",
+}
+
+// String returns name of the mode accepted by ParseMemoryMode
+func (m MemoryMode) String() string {
+	if name, ok := memoryModeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("MemoryMode(%d)", int(m))
+}
+
+// ParseMemoryMode returns the mode of the given name, empty name selects MemoryAggressive
+func ParseMemoryMode(name string) (MemoryMode, error) {
+	if name == "" {
+		return MemoryAggressive, nil
+	}
+	for mode, modeName := range memoryModeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+	return MemoryAggressive, fmt.Errorf("unknown memory mode %q (use aggressive, balanced or constant)", name)
+}
+
+// setMemoryMode sets GC for a scan running until done is closed.
+// The returned function restores the previous GC percent, it must be called after done is closed
+func setMemoryMode(mode MemoryMode, gcPercent int, done <-chan struct{}) (restore func()) {
+	switch mode {
+	case MemoryConstant:
+		return func() {}
+	case MemoryBalanced:
+		if gcPercent <= 0 {
+			gcPercent = DefaultBalancedGCPercent
+		}
+		previous := debug.SetGCPercent(gcPercent)
+		return func() { debug.SetGCPercent(previous) }
+	}
+
+	managerDone := make(chan struct{})
+	go func() {
+		defer close(managerDone)
+		manageMemoryUsage(done)
+	}()
+	previous := debug.SetGCPercent(-1)
+	// wait for the memory manager so that it can't change GC percent after it is restored
+	return func() {
+		<-managerDone
+		debug.SetGCPercent(previous)
+	}
+}
+
+// heapMetric is the size of heap objects, both live and not yet collected
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// peakSampleInterval is the period of samples of the heap size
+const peakSampleInterval = 100 * time.Millisecond
+
+// peakTracker samples the size of the heap in background and keeps the largest one
+type peakTracker struct {
+	peak atomic.Uint64
+	stop chan struct{}
+	once sync.Once
+	wait sync.WaitGroup
+}
+
+// startPeakTracker starts sampling of the heap size
+func startPeakTracker() *peakTracker {
+	t := &peakTracker{stop: make(chan struct{})}
+	t.sample()
+	t.wait.Add(1)
+	go func() {
+		defer t.wait.Done()
+		ticker := time.NewTicker(peakSampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.stop:
+				return
+			case <-ticker.C:
+				t.sample()
+			}
+		}
+	}()
+	return t
+}
+
+func (t *peakTracker) sample() {
+	samples := []metrics.Sample{{Name: heapMetric}}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() != metrics.KindUint64 {
+		return
+	}
+	size := samples[0].Value.Uint64()
+	for {
+		peak := t.peak.Load()
+		if size <= peak || t.peak.CompareAndSwap(peak, size) {
+			return
+		}
+	}
+}
+
+// finish stops the sampling and returns the largest heap size seen.
+// It can be called more than once
+func (t *peakTracker) finish() uint64 {
+	t.once.Do(func() {
+		close(t.stop)
+		t.wait.Wait()
+		t.sample()
+	})
+	return t.peak.Load()
+}
+
 // set GC percentage according to memory usage and system free memory
+// until c is closed
 func manageMemoryUsage(c <-chan struct{}) {
 	disabledGC := true
 
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-c:
 			return
-		default:
+		case <-ticker.C:
 		}
 
-		time.Sleep(time.Second)
-
 		rebalanceGC(&disabledGC)
 	}
 }
@@ -1,8 +1,13 @@
 package analyze
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
 	"runtime/debug"
+	"runtime/metrics"
+	"sync/atomic"
 	"testing"
 
 	"github.com/pbnjay/memory"
@@ -25,3 +30,88 @@ func TestRebalanceGC(t *testing.T) {
 		assert.Greater(t, 0, debug.SetGCPercent(-1))
 	}
 }
+
+func TestParseMemoryMode(t *testing.T) {
+	for _, mode := range []MemoryMode{MemoryAggressive, MemoryBalanced, MemoryConstant} {
+		parsed, err := ParseMemoryMode(mode.String())
+		assert.NoError(t, err)
+		assert.Equal(t, mode, parsed)
+	}
+
+	mode, err := ParseMemoryMode("")
+	assert.NoError(t, err)
+	assert.Equal(t, MemoryAggressive, mode)
+
+	_, err = ParseMemoryMode("lazy")
+	assert.ErrorContains(t, err, "unknown memory mode")
+}
+
+// currentGCPercent returns GC percent without changing it
+func currentGCPercent() int {
+	samples := []metrics.Sample{{Name: "/gc/gogc:percent"}}
+	metrics.Read(samples)
+	return int(samples[0].Value.Uint64())
+}
+
+func TestIncrementalAnalyzer_MemoryModes(t *testing.T) {
+	defer debug.SetGCPercent(debug.SetGCPercent(77))
+
+	tests := []struct {
+		name    string
+		mode    MemoryMode
+		constGC bool
+		during  int // GC percent during the scan, 0 if not checked
+	}{
+		{name: "aggressive", mode: MemoryAggressive},
+		{name: "balanced", mode: MemoryBalanced, during: DefaultBalancedGCPercent},
+		{name: "constant", mode: MemoryConstant, during: 77},
+		{name: "constGC argument", mode: MemoryBalanced, constGC: true, during: 77},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := createTraceFixture(t)
+			analyzer := CreateIncrementalAnalyzer(IncrementalOptions{
+				StoragePath: t.TempDir(),
+				MemoryMode:  tt.mode,
+			})
+			var during atomic.Int64
+			analyzer.beforeSubdir = func(string) { during.Store(int64(currentGCPercent())) }
+
+			analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, tt.constGC)
+			analyzer.GetDone().Wait()
+
+			if tt.during != 0 {
+				assert.Equal(t, int64(tt.during), during.Load())
+			}
+			assert.Equal(t, 77, currentGCPercent(), "GC percent should be restored")
+			assert.Greater(t, analyzer.GetCacheStats().PeakHeap, uint64(0))
+		})
+	}
+}
+
+func TestIncrementalAnalyzer_BalancedPeakHeap(t *testing.T) {
+	if testing.Short() {
+		t.Skip("creates a large tree")
+	}
+	root := t.TempDir()
+	for i := 0; i < 200; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("dir%03d", i))
+		assert.NoError(t, os.Mkdir(dir, 0o755))
+		for j := 0; j < 100; j++ {
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%03d", j)), nil, 0o644))
+		}
+	}
+
+	analyzer := CreateIncrementalAnalyzer(IncrementalOptions{
+		StoragePath: t.TempDir(),
+		MemoryMode:  MemoryBalanced,
+		GCPercent:   20,
+	})
+	analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	// best-effort bound, 20k files take a few MiB with GC enabled
+	peak := analyzer.GetCacheStats().PeakHeap
+	assert.Greater(t, peak, uint64(0))
+	assert.Less(t, peak, uint64(256<<20))
+}
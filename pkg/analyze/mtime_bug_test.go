@@ -1,13 +1,14 @@
 package analyze
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/dundee/gdu/v5/internal/testdir"
 )
 
 // TestIncrementalAnalyzer_DirectoryMtimeDetection reproduces the exact bug scenario:
@@ -15,20 +16,16 @@ import (
 // 2. User adds new subdirectories
 // 3. Second scan should detect mtime change and rescan
 func TestIncrementalAnalyzer_DirectoryMtimeDetection(t *testing.T) {
-	// Create test directory with 500 empty subdirectories
+	// Create test directory with 500 empty subdirectories (dir0 … dir499),
+	// backdated so that the modification below changes the mtime without waiting
 	testRoot := filepath.Join(t.TempDir(), "gdu-test-cache")
-	err := os.Mkdir(testRoot, 0755)
-	assert.NoError(t, err)
-
-	// Create 500 initial directories
-	for i := 1; i <= 500; i++ {
-		dirPath := filepath.Join(testRoot, fmt.Sprintf("dir%d", i))
-		err = os.Mkdir(dirPath, 0755)
-		assert.NoError(t, err)
-	}
-
-	// Wait to ensure filesystem timestamp is stable
-	time.Sleep(1100 * time.Millisecond)
+	_, cleanup := testdir.CreateSyntheticTree(testdir.TreeSpec{
+		Root:    testRoot,
+		Depth:   1,
+		Breadth: 500,
+		Mtime:   time.Now().Add(-time.Hour),
+	})
+	defer cleanup()
 
 	// First scan - populate cache
 	tmpCache := t.TempDir()
@@ -41,17 +38,9 @@ func TestIncrementalAnalyzer_DirectoryMtimeDetection(t *testing.T) {
 	analyzer1 := CreateIncrementalAnalyzer(opts)
 	dir1 := analyzer1.AnalyzeDir(testRoot, func(_, _ string) bool { return false }, false).(*Dir)
 
-	// Drain progress
-	doneChan1 := analyzer1.GetDone()
-	progressChan1 := analyzer1.GetProgressChan()
-	for {
-		select {
-		case <-progressChan1:
-		case <-doneChan1:
-			goto done1
-		}
+	// Drain progress channel, it is closed when the scan ends
+	for range analyzer1.GetProgressChan() {
 	}
-done1:
 
 	stats1 := analyzer1.GetCacheStats()
 	t.Logf("First scan: Total dirs: %d, Misses: %d, Hits: %d, Rescanned: %d",
@@ -66,18 +55,12 @@ done1:
 	mtimeBefore := statBefore.ModTime()
 	t.Logf("testRoot mtime before modification: %v", mtimeBefore)
 
-	// Wait to ensure filesystem mtime granularity is exceeded
-	time.Sleep(1100 * time.Millisecond)
-
 	// Add two new directories (simulating user action: mkdir dir501 dir502)
 	err = os.Mkdir(filepath.Join(testRoot, "dir501"), 0755)
 	assert.NoError(t, err)
 	err = os.Mkdir(filepath.Join(testRoot, "dir502"), 0755)
 	assert.NoError(t, err)
 
-	// Force filesystem sync
-	time.Sleep(100 * time.Millisecond)
-
 	// Verify mtime changed
 	statAfter, err := os.Stat(testRoot)
 	assert.NoError(t, err)
@@ -89,17 +72,9 @@ done1:
 	analyzer2 := CreateIncrementalAnalyzer(opts)
 	dir2 := analyzer2.AnalyzeDir(testRoot, func(_, _ string) bool { return false }, false).(*Dir)
 
-	// Drain progress
-	doneChan2 := analyzer2.GetDone()
-	progressChan2 := analyzer2.GetProgressChan()
-	for {
-		select {
-		case <-progressChan2:
-		case <-doneChan2:
-			goto done2
-		}
+	// Drain progress channel, it is closed when the scan ends
+	for range analyzer2.GetProgressChan() {
 	}
-done2:
 
 	stats2 := analyzer2.GetCacheStats()
 	t.Logf("Second scan: Total dirs: %d, Misses: %d, Hits: %d, Rescanned: %d, Hit Rate: %.1f%%",
@@ -0,0 +1,134 @@
+package analyze
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// OffenderOptions configures ranking of directories by RankOffenders
+type OffenderOptions struct {
+	SizeWeight      float64 // weight of the size of the directory
+	GrowthWeight    float64 // weight of the growth since the baseline
+	MaxDepth        int     // deepest ranked level, children of the root are level 1 (0 = unlimited)
+	Limit           int     // number of returned directories (0 = all)
+	UseApparentSize bool    // rank by apparent size instead of disk usage
+}
+
+// DefaultOffenderOptions weight size and growth equally and rank the top 10 directories
+// of the first three levels
+var DefaultOffenderOptions = OffenderOptions{
+	SizeWeight:   1,
+	GrowthWeight: 1,
+	MaxDepth:     3,
+	Limit:        10,
+}
+
+// Offender is a directory ranked by RankOffenders
+type Offender struct {
+	Path   string  `json:"path"`
+	Size   int64   `json:"size"`
+	Growth int64   `json:"growth"`
+	New    bool    `json:"new"`
+	Score  float64 `json:"score"`
+}
+
+// RankOffenders scores directories of the current tree by a weighted combination
+// of their size and their growth since the baseline (the previous generation of the same tree)
+// and returns them ordered from the highest score.
+//
+// Both size and growth are taken as a share of the size of the whole current tree,
+// so the score of a directory holding the whole tree without any growth is SizeWeight.
+// Directories missing from the baseline are new and their whole size counts as growth,
+// nil baseline makes all directories new.
+// Directories are matched by their path relative to the root of each tree.
+// Equal scores are ordered by size and then by path
+func RankOffenders(
+	ctx context.Context, current, baseline fs.Item, opts OffenderOptions,
+) ([]Offender, error) {
+	previous := make(map[string]int64)
+	if baseline != nil {
+		err := walkDirs(ctx, baseline, opts.MaxDepth, func(rel string, item fs.Item) {
+			previous[rel] = offenderSize(item, opts)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	total := float64(offenderSize(current, opts))
+	if total == 0 {
+		total = 1
+	}
+
+	offenders := make([]Offender, 0)
+	err := walkDirs(ctx, current, opts.MaxDepth, func(rel string, item fs.Item) {
+		size := offenderSize(item, opts)
+		prev, ok := previous[rel]
+		growth := size - prev
+
+		offenders = append(offenders, Offender{
+			Path:   item.GetPath(),
+			Size:   size,
+			Growth: growth,
+			New:    !ok,
+			Score:  (opts.SizeWeight*float64(size) + opts.GrowthWeight*float64(growth)) / total,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(offenders, func(i, j int) bool {
+		a, b := offenders[i], offenders[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.Path < b.Path
+	})
+
+	if opts.Limit > 0 && len(offenders) > opts.Limit {
+		offenders = offenders[:opts.Limit]
+	}
+	return offenders, nil
+}
+
+// walkDirs calls fn for directories of the tree (without the root) up to maxDepth
+// with their path relative to the root. References to duplicate directories are skipped
+func walkDirs(ctx context.Context, root fs.Item, maxDepth int, fn func(rel string, item fs.Item)) error {
+	rootPath := root.GetPath()
+	return Walk(ctx, root, func(path string, item fs.Item, depth int) error {
+		if !item.IsDir() {
+			return nil
+		}
+		if depth == 0 {
+			return nil
+		}
+		if item.GetFlag() == 'D' {
+			return SkipDir
+		}
+
+		rel, err := filepath.Rel(rootPath, path)
+		if err != nil {
+			return err
+		}
+		fn(rel, item)
+
+		if maxDepth > 0 && depth >= maxDepth {
+			return SkipDir
+		}
+		return nil
+	})
+}
+
+func offenderSize(item fs.Item, opts OffenderOptions) int64 {
+	if opts.UseApparentSize {
+		return item.GetSize()
+	}
+	return item.GetUsage()
+}
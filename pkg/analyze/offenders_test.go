@@ -0,0 +1,158 @@
+package analyze
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+// offenderDir creates directory with given usage (apparent size is a half of it)
+func offenderDir(name string, usage int64, children ...*Dir) *Dir {
+	dir := &Dir{
+		File:  &File{Name: name, Usage: usage, Size: usage / 2},
+		Files: fs.Files{},
+	}
+	for _, child := range children {
+		child.Parent = dir
+		dir.Files = append(dir.Files, child)
+	}
+	return dir
+}
+
+func offenderGenerations() (previous, current *Dir) {
+	previous = offenderDir("data", 160,
+		offenderDir("a", 100),
+		offenderDir("b", 50),
+		offenderDir("c", 10, offenderDir("deep", 5)),
+	)
+	previous.BasePath = "/old"
+
+	current = offenderDir("data", 250,
+		offenderDir("a", 110),
+		offenderDir("b", 90),
+		offenderDir("c", 10, offenderDir("deep", 5)),
+		offenderDir("new", 40),
+	)
+	current.BasePath = "/srv"
+	return previous, current
+}
+
+func offenderPaths(offenders []Offender) []string {
+	paths := make([]string, 0, len(offenders))
+	for _, o := range offenders {
+		paths = append(paths, o.Path)
+	}
+	return paths
+}
+
+func TestRankOffenders(t *testing.T) {
+	previous, current := offenderGenerations()
+
+	offenders, err := RankOffenders(context.Background(), current, previous, DefaultOffenderOptions)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"/srv/data/b",
+		"/srv/data/a",
+		"/srv/data/new",
+		"/srv/data/c",
+		"/srv/data/c/deep",
+	}, offenderPaths(offenders))
+
+	assert.Equal(t, Offender{Path: "/srv/data/b", Size: 90, Growth: 40, Score: 0.52}, offenders[0])
+	assert.Equal(t, Offender{Path: "/srv/data/new", Size: 40, Growth: 40, New: true, Score: 0.32}, offenders[2])
+	assert.Equal(t, int64(0), offenders[3].Growth)
+	assert.False(t, offenders[3].New)
+}
+
+func TestRankOffendersWeights(t *testing.T) {
+	previous, current := offenderGenerations()
+
+	offenders, err := RankOffenders(context.Background(), current, previous, OffenderOptions{SizeWeight: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"/srv/data/a",
+		"/srv/data/b",
+		"/srv/data/new",
+		"/srv/data/c",
+		"/srv/data/c/deep",
+	}, offenderPaths(offenders))
+
+	// b and new grew by the same amount, the bigger one goes first
+	offenders, err = RankOffenders(context.Background(), current, previous, OffenderOptions{GrowthWeight: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"/srv/data/b",
+		"/srv/data/new",
+		"/srv/data/a",
+		"/srv/data/c",
+		"/srv/data/c/deep",
+	}, offenderPaths(offenders))
+}
+
+func TestRankOffendersDepthAndLimit(t *testing.T) {
+	previous, current := offenderGenerations()
+
+	offenders, err := RankOffenders(context.Background(), current, previous, OffenderOptions{
+		SizeWeight:   1,
+		GrowthWeight: 1,
+		MaxDepth:     1,
+	})
+	assert.NoError(t, err)
+	assert.NotContains(t, offenderPaths(offenders), "/srv/data/c/deep")
+	assert.Len(t, offenders, 4)
+
+	offenders, err = RankOffenders(context.Background(), current, previous, OffenderOptions{
+		SizeWeight:   1,
+		GrowthWeight: 1,
+		Limit:        2,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/srv/data/b", "/srv/data/a"}, offenderPaths(offenders))
+}
+
+func TestRankOffendersTies(t *testing.T) {
+	current := offenderDir("data", 20, offenderDir("y", 10), offenderDir("x", 10))
+
+	offenders, err := RankOffenders(context.Background(), current, nil, DefaultOffenderOptions)
+	assert.NoError(t, err)
+
+	// without baseline all directories are new
+	assert.Equal(t, []string{"data/x", "data/y"}, offenderPaths(offenders))
+	assert.True(t, offenders[0].New)
+	assert.Equal(t, 1.0, offenders[0].Score)
+}
+
+func TestRankOffendersApparentSize(t *testing.T) {
+	previous, current := offenderGenerations()
+
+	opts := DefaultOffenderOptions
+	opts.UseApparentSize = true
+	offenders, err := RankOffenders(context.Background(), current, previous, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(45), offenders[0].Size)
+	assert.Equal(t, int64(20), offenders[0].Growth)
+}
+
+func TestRankOffendersSkipsDuplicates(t *testing.T) {
+	current := offenderDir("data", 20, offenderDir("x", 20))
+	dup := offenderDir("bind", 0)
+	dup.Flag = 'D'
+	dup.Parent = current
+	current.Files = append(current.Files, dup)
+
+	offenders, err := RankOffenders(context.Background(), current, nil, DefaultOffenderOptions)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"data/x"}, offenderPaths(offenders))
+}
+
+func TestRankOffendersCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, current := offenderGenerations()
+	_, err := RankOffenders(ctx, current, nil, DefaultOffenderOptions)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,11 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package analyze
+
+import "os"
+
+// fileOwner is not supported on this platform
+func fileOwner(_ os.FileInfo) (uint32, bool) {
+	return 0, false
+}
@@ -0,0 +1,17 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package analyze
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner returns uid of the owner of the file
+func fileOwner(info os.FileInfo) (uint32, bool) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return stat.Uid, true
+	}
+	return 0, false
+}
@@ -0,0 +1,161 @@
+package analyze
+
+import (
+	"context"
+	"os/user"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// ownerOf returns uid of the owner of the file, it is replaced by tests
+var ownerOf = fileOwner
+
+// UnknownOwner is the name of the owner of files whose owner is not known
+const UnknownOwner = "unknown"
+
+// OwnerUsage holds files of one owner
+type OwnerUsage struct {
+	UID   uint32 `json:"uid"`
+	Name  string `json:"name"`  // user name, the numeric uid if it can't be resolved
+	Known bool   `json:"known"` // false for files whose owner is not known
+	Count int    `json:"files"`
+	Size  int64  `json:"size"`
+	Usage int64  `json:"usage"`
+}
+
+// OwnerReport is usage of a subtree aggregated by the owners of its files
+type OwnerReport struct {
+	Owners     []OwnerUsage
+	Dropped    int // owners left out by the limit
+	TotalCount int
+	TotalSize  int64
+	TotalUsage int64
+}
+
+// ComputeOwnerUsage aggregates files of the subtree by their owner and returns the owners
+// ordered by disk usage (by apparent size if useApparentSize is set), at most limit of them (0 = all).
+// Directories are not counted and hard-linked files are counted once, for the owner of the first link.
+// Files without known owner (imported analyses, cache entries written before the owners were recorded,
+// platforms without owners) are aggregated under UnknownOwner
+func ComputeOwnerUsage(ctx context.Context, item fs.Item, limit int, useApparentSize bool) (*OwnerReport, error) {
+	report := &OwnerReport{}
+	owners := make(map[uint32]*OwnerUsage)
+	unknown := &OwnerUsage{Name: UnknownOwner}
+	linked := make(map[uint64]struct{})
+
+	err := Walk(ctx, item, func(_ string, it fs.Item, _ int) error {
+		if it.IsDir() {
+			return nil
+		}
+		if mli := it.GetMultiLinkedInode(); mli > 0 {
+			if _, ok := linked[mli]; ok {
+				return nil
+			}
+			linked[mli] = struct{}{}
+		}
+
+		owner := unknown
+		if uid, ok := ownerOfItem(it); ok {
+			owner = owners[uid]
+			if owner == nil {
+				owner = &OwnerUsage{UID: uid, Known: true}
+				owners[uid] = owner
+			}
+		}
+		owner.Count++
+		owner.Size += it.GetSize()
+		owner.Usage += it.GetUsage()
+
+		report.TotalCount++
+		report.TotalSize += it.GetSize()
+		report.TotalUsage += it.GetUsage()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]OwnerUsage, 0, len(owners)+1)
+	for _, owner := range owners {
+		list = append(list, *owner)
+	}
+	if unknown.Count > 0 {
+		list = append(list, *unknown)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		sizeA, sizeB := a.Usage, b.Usage
+		if useApparentSize {
+			sizeA, sizeB = a.Size, b.Size
+		}
+		if sizeA != sizeB {
+			return sizeA > sizeB
+		}
+		if a.Known != b.Known {
+			return a.Known
+		}
+		return a.UID < b.UID
+	})
+	if limit > 0 && len(list) > limit {
+		report.Dropped = len(list) - limit
+		list = list[:limit]
+	}
+
+	// only the listed owners are resolved, lookups can be slow with remote user databases
+	for i := range list {
+		if list[i].Known {
+			list[i].Name = ownerNames.name(list[i].UID)
+		}
+	}
+	report.Owners = list
+	return report, nil
+}
+
+// ownerOfItem returns uid of the owner of the item, ok is false if it is not known
+func ownerOfItem(item fs.Item) (uid uint32, ok bool) {
+	if o, isOwned := item.(interface{ GetOwner() (uint32, bool) }); isOwned {
+		return o.GetOwner()
+	}
+	return 0, false
+}
+
+// ownerNameCache resolves uids to user names, the results are kept for the life of the process
+type ownerNameCache struct {
+	lookup func(uid string) (string, error)
+	names  map[uint32]string
+	m      sync.Mutex
+}
+
+// ownerNames is used by ComputeOwnerUsage, it is replaced by tests
+var ownerNames = newOwnerNameCache(lookupUserName)
+
+func newOwnerNameCache(lookup func(uid string) (string, error)) *ownerNameCache {
+	return &ownerNameCache{lookup: lookup, names: make(map[uint32]string)}
+}
+
+// name returns user name of uid, the numeric uid if it can't be resolved
+func (c *ownerNameCache) name(uid uint32) string {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if name, ok := c.names[uid]; ok {
+		return name
+	}
+	id := strconv.FormatUint(uint64(uid), 10)
+	name, err := c.lookup(id)
+	if err != nil || name == "" {
+		name = id
+	}
+	c.names[uid] = name
+	return name
+}
+
+func lookupUserName(uid string) (string, error) {
+	u, err := user.LookupId(uid)
+	if err != nil {
+		return "", err
+	}
+	return u.Username, nil
+}
@@ -0,0 +1,143 @@
+package analyze
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOwners makes files named "<user>-*" owned by the uid of the user
+func fakeOwners(t *testing.T) {
+	t.Helper()
+	uids := map[string]uint32{"alice": 1000, "bob": 1001, "carol": 1002}
+	origOwnerOf, origNames := ownerOf, ownerNames
+	ownerOf = func(info os.FileInfo) (uint32, bool) {
+		user, _, _ := strings.Cut(info.Name(), "-")
+		uid, ok := uids[user]
+		return uid, ok
+	}
+	ownerNames = newOwnerNameCache(func(uid string) (string, error) {
+		for user, id := range uids {
+			// carol is not known to the user database
+			if uid == strconv.FormatUint(uint64(id), 10) && user != "carol" {
+				return user, nil
+			}
+		}
+		return "", errors.New("unknown user")
+	})
+	t.Cleanup(func() { ownerOf, ownerNames = origOwnerOf, origNames })
+}
+
+func createOwnedFiles(t *testing.T) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "home")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "alice-dir"), 0o755))
+
+	files := []struct {
+		path string
+		size int
+	}{
+		{"alice-dir/alice-a", 100},
+		{"alice-dir/bob-b", 20},
+		{"alice-c", 300},
+		{"bob-d", 40},
+		{"carol-e", 5},
+		{"nobody", 1},
+	}
+	for _, f := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(root, f.path), make([]byte, f.size), 0o600))
+	}
+	// the second link of alice's file is not charged to bob again
+	assert.NoError(t, os.Link(filepath.Join(root, "alice-c"), filepath.Join(root, "bob-link")))
+	return root
+}
+
+func TestComputeOwnerUsage(t *testing.T) {
+	fakeOwners(t)
+	root := createOwnedFiles(t)
+
+	cold := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	coldDir := cold.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	cold.GetDone().Wait()
+
+	// warm scan takes the owners from the cache
+	warm := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: cold.storagePath})
+	warmDir := warm.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	warm.GetDone().Wait()
+	assert.Equal(t, int64(1), warm.GetCacheStats().CacheHits)
+
+	sequential := CreateSeqAnalyzer()
+	sequentialDir := sequential.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	sequential.GetDone().Wait()
+
+	for name, dir := range map[string]fs.Item{"cold": coldDir, "warm": warmDir, "sequential": sequentialDir} {
+		t.Run(name, func(t *testing.T) {
+			report, err := ComputeOwnerUsage(context.Background(), dir, 0, true)
+			assert.NoError(t, err)
+
+			assert.Equal(t, []OwnerUsage{
+				{UID: 1000, Name: "alice", Known: true, Count: 2, Size: 400},
+				{UID: 1001, Name: "bob", Known: true, Count: 2, Size: 60},
+				{UID: 1002, Name: "1002", Known: true, Count: 1, Size: 5},
+				{Name: UnknownOwner, Count: 1, Size: 1},
+			}, withoutUsage(report.Owners))
+			assert.Equal(t, 6, report.TotalCount)
+			assert.Equal(t, int64(466), report.TotalSize)
+			assert.Equal(t, 0, report.Dropped)
+		})
+	}
+}
+
+func withoutUsage(owners []OwnerUsage) []OwnerUsage {
+	for i := range owners {
+		owners[i].Usage = 0
+	}
+	return owners
+}
+
+func TestComputeOwnerUsage_Limit(t *testing.T) {
+	fakeOwners(t)
+	dir := &Dir{File: &File{Name: "root"}, BasePath: "/"}
+	files := []struct {
+		uid   uint32
+		usage int64
+	}{{1000, 10}, {1001, 30}, {1001, 30}, {1002, 20}}
+	for _, f := range files {
+		file := &File{Name: "f", Usage: f.usage, Parent: dir}
+		file.SetOwner(f.uid)
+		dir.AddFile(file)
+	}
+
+	report, err := ComputeOwnerUsage(context.Background(), dir, 2, false)
+	assert.NoError(t, err)
+	assert.Len(t, report.Owners, 2)
+	assert.Equal(t, "bob", report.Owners[0].Name)
+	assert.Equal(t, int64(60), report.Owners[0].Usage)
+	assert.Equal(t, "1002", report.Owners[1].Name)
+	assert.Equal(t, 1, report.Dropped)
+	assert.Equal(t, int64(90), report.TotalUsage)
+}
+
+func TestOwnerNameCache(t *testing.T) {
+	lookups := 0
+	cache := newOwnerNameCache(func(uid string) (string, error) {
+		lookups++
+		if uid == "0" {
+			return "root", nil
+		}
+		return "", errors.New("unknown user")
+	})
+
+	assert.Equal(t, "root", cache.name(0))
+	assert.Equal(t, "root", cache.name(0))
+	assert.Equal(t, "4242", cache.name(4242))
+	assert.Equal(t, "4242", cache.name(4242))
+	assert.Equal(t, 2, lookups)
+}
@@ -51,7 +51,7 @@ func (a *ParallelAnalyzer) SetShowAnnexedSize(v bool) {
 	a.gitAnnexedSize = v
 }
 
-// GetProgressChan returns channel for getting progress
+// GetProgressChan returns channel for getting progress, it is closed when the analysis ends
 func (a *ParallelAnalyzer) GetProgressChan() chan common.CurrentProgress {
 	return a.progressOutChan
 }
@@ -164,8 +164,9 @@ func (a *ParallelAnalyzer) processDir(path string) *Dir {
 				Parent: dir,
 			}
 			setPlatformSpecificAttrs(file, info)
+			clearSpecialFileSize(file, info)
 
-			totalSize += info.Size()
+			totalSize += file.Size
 
 			dir.AddFile(file)
 		}
@@ -191,6 +192,8 @@ func (a *ParallelAnalyzer) processDir(path string) *Dir {
 }
 
 func (a *ParallelAnalyzer) updateProgress() {
+	defer close(a.progressOutChan)
+
 	for {
 		select {
 		case <-a.progressDoneChan:
@@ -220,8 +223,25 @@ func getDirFlag(err error, items int) rune {
 }
 
 func getFlag(f os.FileInfo) rune {
-	if f.Mode()&os.ModeSymlink != 0 || f.Mode()&os.ModeSocket != 0 {
+	if f.Mode()&os.ModeSymlink != 0 {
 		return '@'
 	}
+	if isSpecialFile(f) {
+		return 'S'
+	}
 	return ' '
 }
+
+// isSpecialFile returns true for FIFOs, sockets and device nodes
+func isSpecialFile(f os.FileInfo) bool {
+	return f.Mode()&(os.ModeNamedPipe|os.ModeSocket|os.ModeDevice|os.ModeCharDevice) != 0
+}
+
+// clearSpecialFileSize sets zero size and usage to the special file,
+// the size reported by stat has no meaning for them (e.g. device nodes)
+func clearSpecialFileSize(file *File, f os.FileInfo) {
+	if isSpecialFile(f) {
+		file.Size = 0
+		file.Usage = 0
+	}
+}
@@ -53,17 +53,9 @@ func TestRebuildFromCache_DetectsNewDirsViaFallback(t *testing.T) {
 
 	dir1 := analyzer1.AnalyzeDir(testRoot, func(_, _ string) bool { return false }, false).(*Dir)
 
-	// Drain progress
-	doneChan1 := analyzer1.GetDone()
-	progressChan1 := analyzer1.GetProgressChan()
-	for {
-		select {
-		case <-progressChan1:
-		case <-doneChan1:
-			goto done1
-		}
+	// Drain progress channel, it is closed when the scan ends
+	for range analyzer1.GetProgressChan() {
 	}
-done1:
 
 	stats1 := analyzer1.GetCacheStats()
 	t.Logf("First scan stats: Total=%d, Hits=%d, Misses=%d, Rescanned=%d",
@@ -95,17 +87,9 @@ done1:
 	analyzer2 := CreateIncrementalAnalyzer(opts)
 	dir2 := analyzer2.AnalyzeDir(testRoot, func(_, _ string) bool { return false }, false).(*Dir)
 
-	// Drain progress
-	doneChan2 := analyzer2.GetDone()
-	progressChan2 := analyzer2.GetProgressChan()
-	for {
-		select {
-		case <-progressChan2:
-		case <-doneChan2:
-			goto done2
-		}
+	// Drain progress channel, it is closed when the scan ends
+	for range analyzer2.GetProgressChan() {
 	}
-done2:
 
 	stats2 := analyzer2.GetCacheStats()
 	t.Logf("Second scan stats: Total=%d, Hits=%d, Misses=%d, Rescanned=%d, HitRate=%.1f%%",
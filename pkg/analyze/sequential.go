@@ -48,7 +48,7 @@ func (a *SequentialAnalyzer) SetShowAnnexedSize(v bool) {
 	a.gitAnnexedSize = v
 }
 
-// GetProgressChan returns channel for getting progress
+// GetProgressChan returns channel for getting progress, it is closed when the analysis ends
 func (a *SequentialAnalyzer) GetProgressChan() chan common.CurrentProgress {
 	return a.progressOutChan
 }
@@ -151,8 +151,9 @@ func (a *SequentialAnalyzer) processDir(path string) *Dir {
 				Parent: dir,
 			}
 			setPlatformSpecificAttrs(file, info)
+			clearSpecialFileSize(file, info)
 
-			totalSize += info.Size()
+			totalSize += file.Size
 
 			dir.AddFile(file)
 		}
@@ -167,6 +168,8 @@ func (a *SequentialAnalyzer) processDir(path string) *Dir {
 }
 
 func (a *SequentialAnalyzer) updateProgress() {
+	defer close(a.progressOutChan)
+
 	for {
 		select {
 		case <-a.progressDoneChan:
@@ -0,0 +1,48 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+func TestSpecialFilesParity(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "root")
+	assert.NoError(t, os.MkdirAll(root, 0o755))
+	assert.NoError(t, unix.Mkfifo(filepath.Join(root, "fifo"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "file"), []byte("hello"), 0o600))
+	noIgnore := func(_, _ string) bool { return false }
+
+	seq := CreateSeqAnalyzer().AnalyzeDir(root, noIgnore, false).(*Dir)
+	seq.UpdateStats(make(fs.HardLinkedItems))
+
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+	scan := func() *Dir {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		dir := analyzer.AnalyzeDir(root, noIgnore, false).(*Dir)
+		analyzer.GetDone().Wait()
+		return dir
+	}
+	cold := scan()
+	warm := scan()
+
+	for _, dir := range []*Dir{seq, cold, warm} {
+		fifo := childByName(dir, "fifo")
+		assert.Equal(t, 'S', fifo.GetFlag())
+		assert.Equal(t, int64(0), fifo.GetSize())
+		assert.Equal(t, int64(0), fifo.GetUsage())
+		assert.Equal(t, ' ', childByName(dir, "file").GetFlag())
+		assert.Equal(t, 3, dir.ItemCount)
+	}
+	assert.Equal(t, seq.GetSize(), cold.GetSize())
+	assert.Equal(t, cold.GetSize(), warm.GetSize())
+	assert.Equal(t, cold.GetUsage(), warm.GetUsage())
+}
@@ -44,7 +44,7 @@ func CreateStoredAnalyzer(storagePath string) *StoredAnalyzer {
 	}
 }
 
-// GetProgressChan returns channel for getting progress
+// GetProgressChan returns channel for getting progress, it is closed when the analysis ends
 func (a *StoredAnalyzer) GetProgressChan() chan common.CurrentProgress {
 	return a.progressOutChan
 }
@@ -174,8 +174,9 @@ func (a *StoredAnalyzer) processDir(path string) *StoredDir {
 				Parent: parent,
 			}
 			setPlatformSpecificAttrs(file, info)
+			clearSpecialFileSize(file, info)
 
-			totalSize += info.Size()
+			totalSize += file.Size
 
 			dir.AddFile(file)
 		}
@@ -197,6 +198,8 @@ func (a *StoredAnalyzer) processDir(path string) *StoredDir {
 }
 
 func (a *StoredAnalyzer) updateProgress() {
+	defer close(a.progressOutChan)
+
 	for {
 		select {
 		case <-a.progressDoneChan:
@@ -335,12 +338,18 @@ func (f *StoredDir) UpdateStats(linkedItems fs.HardLinkedItems) {
 	totalSize := int64(4096)
 	totalUsage := int64(4096)
 	var itemCount int
+	var saved int64
 	f.cachedFiles = nil
 	for _, entry := range f.GetFiles() {
 		count, size, usage := entry.GetItemStats(linkedItems)
 		totalSize += size
 		totalUsage += usage
 		itemCount += count
+		if !entry.IsDir() {
+			saved += entry.GetUsage() - usage
+		} else if dir, ok := entry.(interface{ GetHardlinksSaved() int64 }); ok {
+			saved += dir.GetHardlinksSaved()
+		}
 
 		if entry.GetMtime().After(f.Mtime) {
 			f.Mtime = entry.GetMtime()
@@ -357,6 +366,7 @@ func (f *StoredDir) UpdateStats(linkedItems fs.HardLinkedItems) {
 	f.ItemCount = itemCount + 1
 	f.Size = totalSize
 	f.Usage = totalUsage
+	f.HardlinksSaved = saved
 	err := DefaultStorage.StoreDir(f)
 	if err != nil {
 		log.Print(err.Error())
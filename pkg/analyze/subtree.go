@@ -0,0 +1,122 @@
+package analyze
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// ReplaceSubtree puts newSub, a directory scanned again, in place of the directory
+// with the same path in the tree of root, or adds it if the directory is not there.
+// Directories on the way are linked to their parents in memory (instead of ParentDir markers)
+// and newSub is linked to its new parent. Totals of newSub are computed and the totals
+// of its ancestors are adjusted by the difference to the previous version, visiting only them.
+// If either version contains hard links, which may be counted elsewhere in the tree,
+// the totals of the whole tree are computed again instead, so the links stay counted once.
+// The previous version is left out of the tree, items holding it (e.g. the shown directory)
+// have to be pointed to newSub
+func ReplaceSubtree(root fs.Item, newSub *Dir) error {
+	rootPath, err := filepath.Abs(root.GetPath())
+	if err != nil {
+		return err
+	}
+	subPath, err := filepath.Abs(newSub.GetPath())
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(rootPath, subPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is not located below %s", subPath, rootPath)
+	}
+
+	names := strings.Split(rel, string(filepath.Separator))
+	parentItem := root
+	for _, name := range names[:len(names)-1] {
+		index, ok := parentItem.GetFiles().FindByName(name)
+		if !ok {
+			return fmt.Errorf("%s not found in %s", name, parentItem.GetPath())
+		}
+		child := parentItem.GetFiles()[index]
+		if !child.IsDir() {
+			return fmt.Errorf("%s is not a directory", child.GetPath())
+		}
+		if _, isParentDirMarker := child.GetParent().(*ParentDir); isParentDirMarker {
+			child.SetParent(parentItem)
+		}
+		parentItem = child
+	}
+	parent, ok := parentItem.(*Dir)
+	if !ok {
+		return fmt.Errorf("%s can't be modified", parentItem.GetPath())
+	}
+
+	newSub.UpdateStats(make(fs.HardLinkedItems))
+
+	var previous fs.Item
+	parent.m.Lock()
+	if index, ok := parent.Files.FindByName(newSub.Name); ok {
+		previous = parent.Files[index]
+		parent.Files[index] = newSub
+	} else {
+		parent.Files = append(parent.Files, newSub)
+	}
+	parent.m.Unlock()
+	newSub.Parent = parent
+
+	if hasHardlinks(previous) || hasHardlinks(newSub) {
+		top := parent
+		for {
+			dir, ok := top.Parent.(*Dir)
+			if !ok {
+				break
+			}
+			top = dir
+		}
+		top.UpdateStats(make(fs.HardLinkedItems))
+		return nil
+	}
+
+	parent.ComputeAggregates(previous, newSub)
+	previousDir, _ := previous.(*Dir)
+	for cur := parent; cur != nil; cur, _ = cur.Parent.(*Dir) {
+		cur.replaceSubtreeCounts(previousDir, newSub)
+	}
+	return nil
+}
+
+// replaceSubtreeCounts updates counters, mtime and flag of the ancestor f of the subtree
+// replaced by current the way UpdateStats would. Previous is nil if it was not a directory.
+// The error flag is not cleared, as UpdateStats does not clear it either
+func (f *Dir) replaceSubtreeCounts(previous, current *Dir) {
+	if previous != nil {
+		f.ErrorCount -= previous.ErrorCount
+		f.SymlinkCount -= previous.SymlinkCount
+		f.BrokenSymlinkCount -= previous.BrokenSymlinkCount
+		f.EstimatedDirCount -= previous.EstimatedDirCount
+	}
+	f.addSubtreeCounts(current)
+
+	if current.Mtime.After(f.Mtime) {
+		f.Mtime = current.Mtime
+	}
+	if (current.Flag == '!' || current.Flag == '.') && f.Flag != '!' {
+		f.Flag = '.'
+	}
+}
+
+// hasHardlinks returns true if item is a file with more links or a directory containing one
+func hasHardlinks(item fs.Item) bool {
+	switch item := item.(type) {
+	case *File:
+		return item.Mli > 0
+	case *Dir:
+		for _, child := range item.Files {
+			if hasHardlinks(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+// createSubtreeFixture creates root/a/b/c with a file at every level
+func createSubtreeFixture(t *testing.T) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "root")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b", "c"), 0o755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "other"), 0o755))
+	for _, dir := range []string{"", "a", "a/b", "a/b/c", "other"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(root, dir, "file"), make([]byte, 5000), 0o600))
+	}
+	return root
+}
+
+// analyzeSubtree scans path by the parallel analyzer and computes its totals
+func analyzeSubtree(path string) *Dir {
+	analyzer := CreateAnalyzer()
+	dir := analyzer.AnalyzeDir(path, func(_, _ string) bool { return false }, true).(*Dir)
+	analyzer.GetDone().Wait()
+	dir.UpdateStats(make(fs.HardLinkedItems))
+	return dir
+}
+
+func assertSameTotals(t *testing.T, expected, actual *Dir) {
+	t.Helper()
+	assert.Equal(t, expected.ItemCount, actual.ItemCount, actual.GetPath())
+	assert.Equal(t, expected.Size, actual.Size, actual.GetPath())
+	assert.Equal(t, expected.Usage, actual.Usage, actual.GetPath())
+}
+
+func TestReplaceSubtree(t *testing.T) {
+	root := createSubtreeFixture(t)
+	tree := analyzeSubtree(root)
+	a := childByName(tree, "a").(*Dir)
+
+	// depth 1, the subtree grows
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a", "new"), make([]byte, 10000), 0o600))
+	newA := analyzeSubtree(filepath.Join(root, "a"))
+	assert.NoError(t, ReplaceSubtree(tree, newA))
+	assert.Same(t, newA, childByName(tree, "a"))
+	assert.NotSame(t, a, childByName(tree, "a"))
+	assert.Same(t, tree, newA.GetParent())
+	assert.Equal(t, filepath.Join(root, "a"), newA.GetPath())
+	assert.Len(t, tree.Files, 3)
+	assertSameTotals(t, analyzeSubtree(root), tree)
+
+	// depth 3, the subtree shrinks
+	assert.NoError(t, os.Remove(filepath.Join(root, "a", "b", "c", "file")))
+	newC := analyzeSubtree(filepath.Join(root, "a", "b", "c"))
+	assert.NoError(t, ReplaceSubtree(tree, newC))
+	b := childByName(newA, "b").(*Dir)
+	assert.Same(t, newC, childByName(b, "c"))
+	assert.Same(t, b, newC.GetParent())
+	assertSameTotals(t, analyzeSubtree(filepath.Join(root, "a", "b")), b)
+	assertSameTotals(t, analyzeSubtree(filepath.Join(root, "a")), newA)
+	assertSameTotals(t, analyzeSubtree(root), tree)
+
+	// a directory created since the scan is added
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "a", "created"), 0o755))
+	created := analyzeSubtree(filepath.Join(root, "a", "created"))
+	assert.NoError(t, ReplaceSubtree(tree, created))
+	assert.Same(t, created, childByName(newA, "created"))
+	assertSameTotals(t, analyzeSubtree(root), tree)
+
+	assert.ErrorContains(t, ReplaceSubtree(tree, tree), "is not located below")
+	assert.ErrorContains(t, ReplaceSubtree(newA, analyzeSubtree(filepath.Join(root, "other"))), "is not located below")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "missing", "sub"), 0o755))
+	assert.ErrorContains(t, ReplaceSubtree(tree, analyzeSubtree(filepath.Join(root, "missing", "sub"))), "missing not found")
+}
+
+func TestReplaceSubtreeErrorFlag(t *testing.T) {
+	root := createSubtreeFixture(t)
+	tree := analyzeSubtree(root)
+
+	newB := analyzeSubtree(filepath.Join(root, "a", "b"))
+	newB.Flag = '!'
+	newB.ErrorCount = 1
+	assert.NoError(t, ReplaceSubtree(tree, newB))
+	assert.Equal(t, '.', childByName(tree, "a").GetFlag())
+	assert.Equal(t, '.', tree.Flag)
+	assert.Equal(t, 1, tree.ErrorCount)
+}
+
+func TestReplaceSubtreeFromCache(t *testing.T) {
+	root := createSubtreeFixture(t)
+	opts := IncrementalOptions{StoragePath: t.TempDir()}
+	scan := func(path string) *Dir {
+		analyzer := CreateIncrementalAnalyzer(opts)
+		dir := analyzer.AnalyzeDir(path, func(_, _ string) bool { return false }, true).(*Dir)
+		analyzer.GetDone().Wait()
+		dir.UpdateStats(make(fs.HardLinkedItems))
+		return dir
+	}
+	tree := scan(root)
+	a := childByName(tree, "a").(*Dir)
+	_, isParentDirMarker := a.GetParent().(*ParentDir)
+	assert.True(t, isParentDirMarker)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a", "b", "c", "new"), make([]byte, 10000), 0o600))
+	newC := scan(filepath.Join(root, "a", "b", "c"))
+	assert.NoError(t, ReplaceSubtree(tree, newC))
+
+	// the directories on the way are linked to their parents, so the totals reach the root
+	b := childByName(a, "b").(*Dir)
+	assert.Same(t, tree, a.GetParent())
+	assert.Same(t, a, b.GetParent())
+	assert.Same(t, b, newC.GetParent())
+	assertSameTotals(t, analyzeSubtree(root), tree)
+}
+
+func TestReplaceSubtreeHardlinks(t *testing.T) {
+	root := createSubtreeFixture(t)
+	assert.NoError(t, os.Link(filepath.Join(root, "file"), filepath.Join(root, "a", "b", "link")))
+	tree := analyzeSubtree(root)
+
+	// the link is counted in root, so the rescanned subtree must not count it again
+	newB := analyzeSubtree(filepath.Join(root, "a", "b"))
+	assert.NoError(t, ReplaceSubtree(tree, newB))
+	assertSameTotals(t, analyzeSubtree(root), tree)
+}
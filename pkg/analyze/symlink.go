@@ -1,30 +1,36 @@
 package analyze
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/dundee/gdu/v5/pkg/annex"
+	"github.com/dundee/gdu/v5/pkg/fs"
 )
 
+// followSymlink returns info of the target of the symlink at path, nil if the target is a directory.
+// Broken symlinks (missing target or a loop) return an error
+// unless the target is a git-annexed file not present locally and gitAnnexedSize is set
 func followSymlink(path string, gitAnnexedSize bool) (tInfo os.FileInfo, err error) {
 	target, err := filepath.EvalSymlinks(path)
 	if err != nil {
-		target, err = os.Readlink(path)
-		if err != nil {
-			return nil, err
+		link, linkErr := os.Readlink(path)
+		if linkErr != nil {
+			return nil, linkErr
 		}
-		if gitAnnexedSize && strings.Contains(target, ".git/annex/objects") {
+		if gitAnnexedSize && strings.Contains(link, ".git/annex/objects") {
 			tInfo, err = os.Lstat(path)
 			if err != nil {
 				return nil, err
 			}
 
-			name := filepath.Base(target)
+			name := filepath.Base(link)
 			tInfo = annex.AnnexedFileInfo(tInfo, name)
 			return tInfo, nil
 		}
+		return nil, err
 	}
 
 	tInfo, err = os.Lstat(target)
@@ -38,3 +44,19 @@ func followSymlink(path string, gitAnnexedSize bool) (tInfo os.FileInfo, err err
 
 	return tInfo, nil
 }
+
+// CollectBrokenSymlinks returns paths of the symlinks in the tree which could not be followed
+// ('?' flag) in the order of the walk
+func CollectBrokenSymlinks(ctx context.Context, item fs.Item) ([]string, error) {
+	paths := make([]string, 0)
+	err := Walk(ctx, item, func(path string, item fs.Item, _ int) error {
+		if !item.IsDir() && item.GetFlag() == '?' {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
@@ -23,10 +23,19 @@ func TestFollowSymlinkErr(t *testing.T) {
 	assert.Nil(t, err)
 
 	err = os.Symlink(
-		"test_dir/nested",
+		"nested",
 		"test_dir/some_dir")
 	assert.Nil(t, err)
 
+	// relative target is resolved against directory of the link, not the working directory
+	err = os.Symlink(
+		"test_dir/nested",
+		"test_dir/dangling_dir")
+	assert.Nil(t, err)
+
+	err = os.Symlink("loop", "test_dir/loop")
+	assert.Nil(t, err)
+
 	_, err = followSymlink("xxx", false)
 	assert.ErrorContains(t, err, "no such file or directory")
 
@@ -39,4 +48,10 @@ func TestFollowSymlinkErr(t *testing.T) {
 	res, err := followSymlink("test_dir/some_dir", true)
 	assert.Equal(t, nil, res)
 	assert.NoError(t, err)
+
+	_, err = followSymlink("test_dir/dangling_dir", false)
+	assert.ErrorContains(t, err, "no such file or directory")
+
+	_, err = followSymlink("test_dir/loop", false)
+	assert.Error(t, err)
 }
@@ -30,6 +30,7 @@ import (
 // -------------------
 // - Added to IncrementalAnalyzer struct as optional field
 // - Called in processDir() before os.ReadDir() operations
+// - Called by remove.ThrottledRemover before every unlink/rmdir
 // - Nil throttle = no throttling (zero overhead)
 //
 // Thread Safety:
@@ -43,11 +44,17 @@ import (
 // - Acquire() respects context cancellation
 // - Allows graceful shutdown during throttled operations
 // - Returns context.Err() if cancelled
+//
+// Pausing:
+// --------
+// - Pause() blocks all following Acquire() calls until Resume() is called
+// - Operations already past Acquire() are not interrupted
 type IOThrottle struct {
 	maxIOPS int           // Maximum I/O operations per second (0 = unlimited)
 	ioDelay time.Duration // Fixed delay between operations (0 = no delay)
 	limiter *rate.Limiter // Token bucket rate limiter (nil if maxIOPS=0)
-	mu      sync.Mutex    // Protects limiter recreation in Reset()
+	paused  chan struct{} // Closed by Resume(), nil if not paused
+	mu      sync.Mutex    // Protects limiter recreation in Reset() and paused
 }
 
 // NewIOThrottle creates a throttle with IOPS limit and/or fixed delay.
@@ -119,12 +126,23 @@ func (t *IOThrottle) Acquire(ctx context.Context) error {
 		return nil
 	}
 
-	// Apply IOPS limiting first (if enabled)
 	// Acquire a snapshot of the limiter under lock to avoid race with Reset()
 	t.mu.Lock()
 	limiter := t.limiter
+	paused := t.paused
 	t.mu.Unlock()
 
+	// Wait while paused
+	if paused != nil {
+		select {
+		case <-paused:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	// Apply IOPS limiting (if enabled)
+
 	if limiter != nil {
 		// Wait for a token from the rate limiter
 		// This blocks until:
@@ -200,3 +218,42 @@ func (t *IOThrottle) IsEnabled() bool {
 	}
 	return t.maxIOPS > 0 || t.ioDelay > 0
 }
+
+// Pause blocks following Acquire calls until Resume is called.
+// Acquire calls waiting on cancelled context return immediately
+func (t *IOThrottle) Pause() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.paused == nil {
+		t.paused = make(chan struct{})
+	}
+}
+
+// Resume releases Acquire calls blocked by Pause
+func (t *IOThrottle) Resume() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.paused != nil {
+		close(t.paused)
+		t.paused = nil
+	}
+}
+
+// IsPaused returns true between Pause and Resume
+func (t *IOThrottle) IsPaused() bool {
+	if t == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.paused != nil
+}
@@ -495,3 +495,41 @@ func BenchmarkIOThrottle_Reset(b *testing.B) {
 		throttle.Reset()
 	}
 }
+
+// TestIOThrottle_PauseResume verifies that Pause blocks Acquire until Resume
+func TestIOThrottle_PauseResume(t *testing.T) {
+	throttle := NewIOThrottle(0, time.Millisecond)
+
+	throttle.Pause()
+	throttle.Pause() // repeated pause is no-op
+	assert.True(t, throttle.IsPaused())
+
+	var acquired atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, throttle.Acquire(context.Background()))
+		acquired.Store(true)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, acquired.Load(), "Acquire should wait while paused")
+
+	throttle.Resume()
+	<-done
+	assert.True(t, acquired.Load())
+	assert.False(t, throttle.IsPaused())
+	throttle.Resume() // resume without pause is no-op
+
+	// cancellation releases paused Acquire
+	throttle.Pause()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, throttle.Acquire(ctx))
+
+	// nil throttle can't be paused
+	var disabled *IOThrottle
+	disabled.Pause()
+	assert.False(t, disabled.IsPaused())
+	disabled.Resume()
+}
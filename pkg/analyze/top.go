@@ -1,6 +1,7 @@
 package analyze
 
 import (
+	"context"
 	"sort"
 
 	"github.com/dundee/gdu/v5/pkg/fs"
@@ -32,17 +33,12 @@ func (tl *TopList) Add(file fs.Item) {
 
 func CollectTopFiles(dir fs.Item, count int) fs.Files {
 	topList := NewTopList(count)
-	walkDir(dir, topList)
-	sort.Sort(sort.Reverse(fs.ByApparentSize(topList.Items)))
-	return topList.Items
-}
-
-func walkDir(dir fs.Item, topList *TopList) {
-	for _, item := range dir.GetFiles() {
-		if item.IsDir() {
-			walkDir(item, topList)
-		} else {
+	_ = Walk(context.Background(), dir, func(_ string, item fs.Item, _ int) error {
+		if !item.IsDir() {
 			topList.Add(item)
 		}
-	}
+		return nil
+	})
+	sort.Sort(sort.Reverse(fs.ByApparentSize(topList.Items)))
+	return topList.Items
 }
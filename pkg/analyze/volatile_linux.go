@@ -0,0 +1,22 @@
+//go:build linux
+// +build linux
+
+package analyze
+
+import "golang.org/x/sys/unix"
+
+// volatileFSType returns name of the filesystem of path if it is kept in memory only,
+// empty otherwise or if it can't be found out
+func volatileFSType(path string) string {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return ""
+	}
+	switch uint32(stat.Type) {
+	case unix.TMPFS_MAGIC:
+		return "tmpfs"
+	case unix.RAMFS_MAGIC:
+		return "ramfs"
+	}
+	return ""
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package analyze
+
+// volatileFSType is not supported on this platform, only the known locations are checked
+func volatileFSType(path string) string {
+	return ""
+}
@@ -0,0 +1,70 @@
+package analyze
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// SkipDir can be returned by WalkFunc to skip a directory.
+// It is the same value as filepath.SkipDir
+var SkipDir = filepath.SkipDir
+
+// WalkFunc is called by Walk for every visited item.
+// Path is the path of the item built from the path of the walk root,
+// depth is 0 for the root and grows by one with every directory level.
+//
+// If the function returns SkipDir when called on a directory, the children of the directory are skipped.
+// If it returns SkipDir when called on a file, the remaining items of the containing directory are skipped.
+// Any other error stops the walk and is returned by Walk
+type WalkFunc func(path string, item fs.Item, depth int) error
+
+// Walk traverses the tree rooted at item depth-first, calling fn for each item
+// before its children. Children are visited in the order they are stored in the directory.
+//
+// ParentDir markers (used by analyzers instead of real parents) are never passed to fn.
+// Directories that could not be read ('!' flag) are visited like any other directory.
+// Walk checks ctx before every item and returns ctx.Err() once the context is done
+func Walk(ctx context.Context, item fs.Item, fn WalkFunc) error {
+	if _, ok := item.(*ParentDir); ok {
+		return nil
+	}
+	err := walk(ctx, item.GetPath(), item, 0, fn)
+	if errors.Is(err, SkipDir) {
+		return nil
+	}
+	return err
+}
+
+func walk(ctx context.Context, path string, item fs.Item, depth int, fn WalkFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := fn(path, item, depth); err != nil {
+		return err
+	}
+
+	if !item.IsDir() {
+		return nil
+	}
+
+	for _, child := range item.GetFilesLocked() {
+		if _, ok := child.(*ParentDir); ok {
+			continue
+		}
+		err := walk(ctx, filepath.Join(path, child.GetName()), child, depth+1, fn)
+		if errors.Is(err, SkipDir) {
+			if child.IsDir() {
+				continue
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package analyze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/dundee/gdu/v5/internal/testdir"
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func walkTestTrees(t *testing.T) map[string]fs.Item {
+	t.Helper()
+
+	// the incremental analyzer scans the absolute path
+	root := absTestPath(t, "test_dir")
+
+	incremental := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: t.TempDir()})
+	incrementalDir := incremental.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	incremental.GetDone().Wait()
+
+	// warm scan rebuilds the tree from the cache
+	warm := CreateIncrementalAnalyzer(IncrementalOptions{StoragePath: incremental.storagePath})
+	warmDir := warm.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	warm.GetDone().Wait()
+
+	sequential := CreateSeqAnalyzer()
+	sequentialDir := sequential.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	sequential.GetDone().Wait()
+
+	return map[string]fs.Item{
+		"incremental":        incrementalDir,
+		"incremental cached": warmDir,
+		"sequential":         sequentialDir,
+	}
+}
+
+func TestWalk(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	for name, dir := range walkTestTrees(t) {
+		t.Run(name, func(t *testing.T) {
+			var visited []string
+			err := Walk(context.Background(), dir, func(path string, item fs.Item, depth int) error {
+				assert.Equal(t, filepath.Base(path), item.GetName())
+				visited = append(visited, fmt.Sprintf("%d %s", depth, path))
+				return nil
+			})
+
+			assert.NoError(t, err)
+			assert.Equal(t, []string{
+				"0 " + absTestPath(t, "test_dir"),
+				"1 " + absTestPath(t, "test_dir", "nested"),
+				"2 " + absTestPath(t, "test_dir", "nested", "file2"),
+				"2 " + absTestPath(t, "test_dir", "nested", "subnested"),
+				"3 " + absTestPath(t, "test_dir", "nested", "subnested", "file"),
+			}, visited)
+		})
+	}
+}
+
+func TestWalkSkipDir(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	for name, dir := range walkTestTrees(t) {
+		t.Run(name, func(t *testing.T) {
+			var visited []string
+			err := Walk(context.Background(), dir, func(_ string, item fs.Item, _ int) error {
+				visited = append(visited, item.GetName())
+				if item.GetName() == "subnested" {
+					return SkipDir
+				}
+				return nil
+			})
+
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"test_dir", "nested", "file2", "subnested"}, visited)
+		})
+	}
+}
+
+func TestWalkSkipDirOnFile(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	for name, dir := range walkTestTrees(t) {
+		t.Run(name, func(t *testing.T) {
+			var visited []string
+			err := Walk(context.Background(), dir, func(_ string, item fs.Item, _ int) error {
+				visited = append(visited, item.GetName())
+				if item.GetName() == "file2" {
+					return SkipDir
+				}
+				return nil
+			})
+
+			// remaining items of nested are skipped
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"test_dir", "nested", "file2"}, visited)
+		})
+	}
+}
+
+func TestWalkSkipRoot(t *testing.T) {
+	dir := &Dir{File: &File{Name: "root"}, Files: fs.Files{&File{Name: "file"}}}
+
+	calls := 0
+	err := Walk(context.Background(), dir, func(_ string, _ fs.Item, _ int) error {
+		calls++
+		return SkipDir
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestWalkError(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	walkErr := errors.New("stop")
+	for name, dir := range walkTestTrees(t) {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			err := Walk(context.Background(), dir, func(_ string, item fs.Item, _ int) error {
+				calls++
+				if item.GetName() == "nested" {
+					return walkErr
+				}
+				return nil
+			})
+
+			assert.ErrorIs(t, err, walkErr)
+			assert.Equal(t, 2, calls)
+		})
+	}
+}
+
+func TestWalkCancelled(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	for name, dir := range walkTestTrees(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			calls := 0
+			err := Walk(ctx, dir, func(_ string, item fs.Item, _ int) error {
+				calls++
+				if item.GetName() == "nested" {
+					cancel()
+				}
+				return nil
+			})
+
+			assert.ErrorIs(t, err, context.Canceled)
+			assert.Equal(t, 2, calls)
+		})
+	}
+}
+
+func TestWalkErrorDir(t *testing.T) {
+	dir := &Dir{
+		File:  &File{Name: "root"},
+		Files: fs.Files{&Dir{File: &File{Name: "unreadable", Flag: '!'}}},
+	}
+
+	var flags []rune
+	err := Walk(context.Background(), dir, func(_ string, item fs.Item, _ int) error {
+		flags = append(flags, item.GetFlag())
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []rune{0, '!'}, flags)
+}
+
+func TestWalkParentDir(t *testing.T) {
+	called := false
+	err := Walk(context.Background(), &ParentDir{Path: "/tmp"}, func(_ string, _ fs.Item, _ int) error {
+		called = true
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.False(t, called)
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dundee/gdu/v5/pkg/analyze"
+)
+
+// DefaultTopDirs is the number of directories returned by /top-dirs if n is not given
+const DefaultTopDirs = 20
+
+// Health is the state of the server returned by /healthz
+type Health struct {
+	LastScanEnd time.Time `json:"lastScanEnd"` // end of the last rescan, zero if there was none
+	DirtyPaths  int       `json:"dirtyPaths"`  // paths waiting for a rescan
+	// WatcherErrors counts background rescans which failed or could not read part of the tree
+	WatcherErrors  int   `json:"watcherErrors"`
+	CacheSizeBytes int64 `json:"cacheSizeBytes"` // size of the cache files on disk
+}
+
+// Server serves HTTP API answering queries from the incremental cache without scanning.
+// Subtrees can be rescanned in background on request, see SetToken and RunRescans
+type Server struct {
+	storagePath string
+	token       string
+	rescans     *analyze.RescanQueue
+	mux         *http.ServeMux
+}
+
+// NewServer returns server of the API over the incremental cache at storagePath
+func NewServer(storagePath string) *Server {
+	s := &Server{
+		storagePath: storagePath,
+		rescans:     analyze.NewRescanQueue(storagePath),
+		mux:         http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /top-dirs", s.topDirs)
+	s.mux.HandleFunc("GET /healthz", s.healthz)
+	s.mux.HandleFunc("POST /rescan", s.rescan)
+	return s
+}
+
+// SetToken sets the token required in the "Authorization: Bearer" header of /rescan requests.
+// Rescans are disabled without it
+func (s *Server) SetToken(token string) {
+	s.token = token
+}
+
+// RunRescans rescans subtrees requested by /rescan until ctx is done
+func (s *Server) RunRescans(ctx context.Context) {
+	s.rescans.Run(ctx)
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// topDirs returns the largest directories under the prefix query parameter as JSON.
+// Parameters n, min-depth and max-depth are passed to IncrementalStorage.TopDirsBySize
+func (s *Server) topDirs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	if prefix == "" {
+		http.Error(w, "missing prefix", http.StatusBadRequest)
+		return
+	}
+
+	n, err := intParam(query.Get("n"), DefaultTopDirs)
+	if err != nil {
+		http.Error(w, "invalid n: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	minDepth, err := intParam(query.Get("min-depth"), 0)
+	if err != nil {
+		http.Error(w, "invalid min-depth: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	maxDepth, err := intParam(query.Get("max-depth"), 0)
+	if err != nil {
+		http.Error(w, "invalid max-depth: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	storage := analyze.NewIncrementalStorage(s.storagePath, prefix)
+	closeFn, err := storage.OpenReadOnly()
+	if err != nil {
+		log.Printf("Opening cache for %s: %v", r.URL, err)
+		http.Error(w, "cache is not available", http.StatusServiceUnavailable)
+		return
+	}
+	defer closeFn()
+
+	top, err := storage.TopDirsBySize(prefix, n, minDepth, maxDepth)
+	if err != nil {
+		log.Printf("Reading top directories of %s: %v", prefix, err)
+		http.Error(w, "reading cache failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(top); err != nil {
+		log.Printf("Writing response: %v", err)
+	}
+}
+
+// healthz returns Health of the server as JSON
+func (s *Server) healthz(w http.ResponseWriter, _ *http.Request) {
+	status := s.rescans.Status()
+	health := Health{
+		LastScanEnd:    status.LastScanEnd,
+		DirtyPaths:     status.DirtyPaths,
+		WatcherErrors:  status.Errors,
+		CacheSizeBytes: filesSize(s.storagePath),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(health); err != nil {
+		log.Printf("Writing response: %v", err)
+	}
+}
+
+// rescan enqueues rescan of the directory given by the path query parameter
+func (s *Server) rescan(w http.ResponseWriter, r *http.Request) {
+	if s.token == "" {
+		http.Error(w, "rescans are disabled, no token is set", http.StatusForbidden)
+		return
+	}
+	given := []byte(r.Header.Get("Authorization"))
+	if subtle.ConstantTimeCompare(given, []byte("Bearer "+s.token)) != 1 {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	path := r.URL.Query().Get("path")
+	if !filepath.IsAbs(path) {
+		http.Error(w, "path must be absolute", http.StatusBadRequest)
+		return
+	}
+	path = filepath.Clean(path)
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		http.Error(w, "path is not a directory", http.StatusBadRequest)
+		return
+	}
+
+	s.rescans.Enqueue(path)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// filesSize returns total size of files under path, errors are skipped
+func filesSize(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// intParam parses non-negative integer query parameter, def is returned if it is empty
+func intParam(value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return n, nil
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dundee/gdu/v5/pkg/analyze"
+)
+
+func seedCache(t *testing.T, storagePath string) {
+	t.Helper()
+	storage := analyze.NewIncrementalStorage(storagePath, "/data")
+	closeFn, err := storage.Open()
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer closeFn()
+
+	for path, usage := range map[string]int64{
+		"/data":       1000,
+		"/data/a":     600,
+		"/data/a/big": 500,
+		"/data/b":     300,
+	} {
+		assert.NoError(t, storage.StoreDirMetadata(&analyze.IncrementalDirMetadata{
+			Path: path, Usage: usage, ItemCount: 3, CachedAt: time.Now(),
+		}))
+	}
+}
+
+func get(t *testing.T, server http.Handler, url string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+	return rec
+}
+
+func TestTopDirs(t *testing.T) {
+	storagePath := t.TempDir()
+	seedCache(t, storagePath)
+	server := NewServer(storagePath)
+
+	rec := get(t, server, "/top-dirs?prefix=/data&n=2&min-depth=1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var top []analyze.CachedDirTotals
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &top))
+	assert.Len(t, top, 2)
+	assert.Equal(t, "/data/a", top[0].Path)
+	assert.Equal(t, int64(600), top[0].Usage)
+	assert.Equal(t, 3, top[0].ItemCount)
+	assert.Equal(t, "/data/a/big", top[1].Path)
+
+	rec = get(t, server, "/top-dirs?prefix=/data&max-depth=1")
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &top))
+	assert.Len(t, top, 3)
+}
+
+func TestTopDirsInvalidRequest(t *testing.T) {
+	server := NewServer(t.TempDir())
+
+	assert.Equal(t, http.StatusBadRequest, get(t, server, "/top-dirs").Code)
+	assert.Equal(t, http.StatusBadRequest, get(t, server, "/top-dirs?prefix=/data&n=x").Code)
+	assert.Equal(t, http.StatusBadRequest, get(t, server, "/top-dirs?prefix=/data&min-depth=-1").Code)
+	assert.Equal(t, http.StatusBadRequest, get(t, server, "/top-dirs?prefix=/data&max-depth=y").Code)
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/top-dirs?prefix=/data", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestTopDirsCacheNotAvailable(t *testing.T) {
+	// no cache was written yet
+	rec := get(t, NewServer(t.TempDir()), "/top-dirs?prefix=/data")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestTopDirsWhileWriterHoldsCache(t *testing.T) {
+	storagePath := t.TempDir()
+	seedCache(t, storagePath)
+
+	storage := analyze.NewIncrementalStorage(storagePath, "/data")
+	closeFn, err := storage.Open()
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer closeFn()
+	assert.NoError(t, storage.StoreDirMetadata(&analyze.IncrementalDirMetadata{
+		Path: "/data/c", Usage: 700, CachedAt: time.Now(),
+	}))
+
+	server := NewServer(storagePath)
+	for i := 0; i < 2; i++ {
+		rec := get(t, server, "/top-dirs?prefix=/data&n=1&min-depth=1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var top []analyze.CachedDirTotals
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &top))
+		assert.Len(t, top, 1)
+		assert.Equal(t, "/data/c", top[0].Path)
+	}
+}
+
+func health(t *testing.T, server http.Handler) Health {
+	t.Helper()
+	rec := get(t, server, "/healthz")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var h Health
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &h))
+	return h
+}
+
+func postRescan(server http.Handler, path, token string) int {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/rescan?path="+url.QueryEscape(path), nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	server.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestRescan(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "data")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "a"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a", "file"), make([]byte, 5000), 0o600))
+
+	server := NewServer(t.TempDir())
+	server.SetToken("secret")
+
+	h := health(t, server)
+	assert.Equal(t, 0, h.DirtyPaths)
+	assert.True(t, h.LastScanEnd.IsZero())
+
+	assert.Equal(t, http.StatusAccepted, postRescan(server, root, "secret"))
+	assert.Equal(t, http.StatusAccepted, postRescan(server, root+"/", "secret"))
+	assert.Equal(t, 1, health(t, server).DirtyPaths)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.RunRescans(ctx)
+
+	assert.Eventually(t, func() bool {
+		return health(t, server).DirtyPaths == 0
+	}, 10*time.Second, 10*time.Millisecond)
+
+	h = health(t, server)
+	assert.False(t, h.LastScanEnd.IsZero())
+	assert.Equal(t, 0, h.WatcherErrors)
+	assert.Greater(t, h.CacheSizeBytes, int64(0))
+
+	var top []analyze.CachedDirTotals
+	rec := get(t, server, "/top-dirs?prefix="+url.QueryEscape(root)+"&min-depth=1")
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &top))
+	assert.Len(t, top, 1)
+	assert.Equal(t, filepath.Join(root, "a"), top[0].Path)
+}
+
+func TestRescanInvalidRequest(t *testing.T) {
+	dir := t.TempDir()
+
+	server := NewServer(t.TempDir())
+	assert.Equal(t, http.StatusForbidden, postRescan(server, dir, ""))
+
+	server.SetToken("secret")
+	assert.Equal(t, http.StatusUnauthorized, postRescan(server, dir, ""))
+	assert.Equal(t, http.StatusUnauthorized, postRescan(server, dir, "wrong"))
+	assert.Equal(t, http.StatusBadRequest, postRescan(server, "relative", "secret"))
+	assert.Equal(t, http.StatusBadRequest, postRescan(server, filepath.Join(dir, "missing"), "secret"))
+	assert.Equal(t, http.StatusMethodNotAllowed, get(t, server, "/rescan?path="+dir).Code)
+	assert.Equal(t, 0, health(t, server).DirtyPaths)
+}
@@ -131,3 +131,79 @@ func (f ByMtime) Less(i, j int) bool {
 	// if item count is the same, sort by name
 	return natural.Less(f[i].GetName(), f[j].GetName())
 }
+
+// ByBtime sorts files by birth time, items without known birth time go first
+type ByBtime Files
+
+func (f ByBtime) Len() int      { return len(f) }
+func (f ByBtime) Swap(i, j int) { f[i], f[j] = f[j], f[i] }
+func (f ByBtime) Less(i, j int) bool {
+	bi, bj := GetBtime(f[i]), GetBtime(f[j])
+	if !bi.Equal(bj) {
+		return bi.Before(bj)
+	}
+	// if birth time is the same, sort by name
+	return natural.Less(f[i].GetName(), f[j].GetName())
+}
+
+// GetBtime returns birth time of the item or zero time if the item does not provide it
+func GetBtime(item Item) time.Time {
+	if b, ok := item.(interface{ GetBtime() time.Time }); ok {
+		return b.GetBtime()
+	}
+	return time.Time{}
+}
+
+// BySelfUsage sorts files by disk usage of the files directly inside them
+type BySelfUsage Files
+
+func (f BySelfUsage) Len() int      { return len(f) }
+func (f BySelfUsage) Swap(i, j int) { f[i], f[j] = f[j], f[i] }
+func (f BySelfUsage) Less(i, j int) bool {
+	ui, uj := GetSelfUsage(f[i]), GetSelfUsage(f[j])
+	if ui != uj {
+		return ui < uj
+	}
+	// if self usage is the same, sort by name
+	return natural.Less(f[i].GetName(), f[j].GetName())
+}
+
+// BySelfSize sorts files by apparent size of the files directly inside them
+type BySelfSize Files
+
+func (f BySelfSize) Len() int      { return len(f) }
+func (f BySelfSize) Swap(i, j int) { f[i], f[j] = f[j], f[i] }
+func (f BySelfSize) Less(i, j int) bool {
+	si, sj := GetSelfSize(f[i]), GetSelfSize(f[j])
+	if si != sj {
+		return si < sj
+	}
+	// if self size is the same, sort by name
+	return natural.Less(f[i].GetName(), f[j].GetName())
+}
+
+// GetSelfUsage returns disk usage of the files directly in the directory (without subdirectories),
+// a file returns its own usage
+func GetSelfUsage(item Item) int64 {
+	if s, ok := item.(interface{ GetSelfUsage() int64 }); ok {
+		return s.GetSelfUsage()
+	}
+	return item.GetUsage()
+}
+
+// GetSelfSize returns apparent size of the files directly in the directory (without subdirectories),
+// a file returns its own size
+func GetSelfSize(item Item) int64 {
+	if s, ok := item.(interface{ GetSelfSize() int64 }); ok {
+		return s.GetSelfSize()
+	}
+	return item.GetSize()
+}
+
+// IsEstimated returns true if totals of the item are extrapolated from a sample of files
+func IsEstimated(item Item) bool {
+	if e, ok := item.(interface{ IsEstimated() bool }); ok {
+		return e.IsEstimated()
+	}
+	return false
+}
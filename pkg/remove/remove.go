@@ -26,6 +26,8 @@ func EmptyFileFromDir(dir, file fs.Item) error {
 	}
 
 	cur := dir.(*analyze.Dir)
+	cur.SelfSize -= file.GetSize()
+	cur.SelfUsage -= file.GetUsage()
 	for {
 		cur.Size -= file.GetSize()
 		cur.Usage -= file.GetUsage()
@@ -0,0 +1,265 @@
+package remove
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/dundee/gdu/v5/internal/common"
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// removeBatchSize is the number of removed entries dropped from the loaded tree at once
+const removeBatchSize = 1024
+
+// ThrottledRemover removes items entry by entry, every unlink or rmdir waits for the I/O throttle.
+// Removed entries are dropped from the loaded tree as they go, so the tree matches
+// the filesystem even if the deletion is cancelled or fails in the middle
+type ThrottledRemover struct {
+	throttle   *analyze.IOThrottle
+	invalidate func(path string) error // drops cache entries of the removed item, may be nil
+	progress   chan common.CurrentProgress
+	ctx        context.Context
+	cancel     context.CancelFunc
+	m          sync.Mutex // guards ctx and cancel
+}
+
+// NewThrottledRemover returns remover pacing the deletion by throttle.
+// After every deletion (also cancelled or failed one) invalidate is called with path
+// of the removed item, e.g. IncrementalAnalyzer.InvalidateRemoved
+func NewThrottledRemover(throttle *analyze.IOThrottle, invalidate func(path string) error) *ThrottledRemover {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ThrottledRemover{
+		throttle:   throttle,
+		invalidate: invalidate,
+		progress:   make(chan common.CurrentProgress, 1),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// GetProgressChan returns channel reporting progress of the running deletion:
+// number of removed items and bytes freed so far
+func (r *ThrottledRemover) GetProgressChan() chan common.CurrentProgress {
+	return r.progress
+}
+
+// Pause pauses the running and following deletions until Resume is called
+func (r *ThrottledRemover) Pause() {
+	r.throttle.Pause()
+}
+
+// Resume resumes paused deletions
+func (r *ThrottledRemover) Resume() {
+	r.throttle.Resume()
+}
+
+// IsPaused returns true if deletions are paused
+func (r *ThrottledRemover) IsPaused() bool {
+	return r.throttle.IsPaused()
+}
+
+// Cancel stops the running deletion (or the following one if it has not started yet),
+// entries removed so far stay removed
+func (r *ThrottledRemover) Cancel() {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.cancel()
+}
+
+// ItemFromDir removes item from dir.
+// It returns context.Canceled if the deletion was cancelled
+func (r *ThrottledRemover) ItemFromDir(dir, item fs.Item) error {
+	r.m.Lock()
+	ctx := r.ctx
+	r.m.Unlock()
+	defer func() {
+		if ctx.Err() == nil {
+			return
+		}
+		// the cancellation is consumed, following deletions run again
+		r.m.Lock()
+		defer r.m.Unlock()
+		r.ctx, r.cancel = context.WithCancel(context.Background())
+	}()
+
+	d := &throttledDeletion{ThrottledRemover: r, ctx: ctx}
+	err := d.removeEntry(item, []fs.Item{dir})
+	if err == nil {
+		usage := item.GetUsage()
+		dir.RemoveFile(item)
+		d.report(item.GetPath(), 1, usage)
+	}
+
+	if r.invalidate != nil && d.reported.ItemCount > 0 {
+		if invalidateErr := r.invalidate(item.GetPath()); invalidateErr != nil && err == nil {
+			err = invalidateErr
+		}
+	}
+	return err
+}
+
+// throttledDeletion is a single run of ThrottledRemover.ItemFromDir
+type throttledDeletion struct {
+	*ThrottledRemover
+	ctx      context.Context
+	reported common.CurrentProgress
+}
+
+// removeEntry removes item (directory with all its content) from the filesystem.
+// Loaded children of a directory are dropped from the tree as they are removed,
+// the item itself is left to the caller. Ancestors are the directories above item
+// up to the one the deletion started in
+func (d *throttledDeletion) removeEntry(item fs.Item, ancestors []fs.Item) error {
+	path := item.GetPath()
+	if !item.IsDir() {
+		return d.remove(path)
+	}
+
+	if err := d.removeChildren(item, ancestors); err != nil {
+		return err
+	}
+
+	err := d.remove(path)
+	if isNotEmpty(err) {
+		// Entries not known to the loaded tree (created after the scan,
+		// or not read in the estimation mode) are removed by path
+		if err := d.removeUnknown(path); err != nil {
+			return err
+		}
+		err = d.remove(path)
+	}
+	return err
+}
+
+// removeChildren removes loaded children of dir.
+// They are dropped from the tree in batches, so huge directories are not filtered for every entry
+func (d *throttledDeletion) removeChildren(dir fs.Item, ancestors []fs.Item) error {
+	children := append(fs.Files(nil), dir.GetFilesLocked()...)
+	childAncestors := append(ancestors[:len(ancestors):len(ancestors)], dir)
+	batch := make([]fs.Item, 0, min(len(children), removeBatchSize))
+	defer func() {
+		dropFiles(dir, ancestors, batch)
+	}()
+
+	for _, child := range children {
+		if err := d.removeEntry(child, childAncestors); err != nil {
+			return err
+		}
+		batch = append(batch, child)
+		d.report(child.GetPath(), 1, child.GetUsage())
+
+		if len(batch) == removeBatchSize {
+			dropFiles(dir, ancestors, batch)
+			batch = batch[:0]
+		}
+	}
+	return nil
+}
+
+// removeUnknown removes content of directory at path which is not in the loaded tree
+func (d *throttledDeletion) removeUnknown(path string) error {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		entryPath := filepath.Join(path, entry.Name())
+		if entry.IsDir() {
+			if err := d.removeUnknown(entryPath); err != nil {
+				return err
+			}
+		}
+		if err := d.remove(entryPath); err != nil {
+			return err
+		}
+		d.report(entryPath, 1, 0)
+	}
+	return nil
+}
+
+// remove unlinks file or removes empty directory once the throttle allows it
+func (d *throttledDeletion) remove(path string) error {
+	if err := d.throttle.Acquire(d.ctx); err != nil {
+		return err
+	}
+	if err := d.ctx.Err(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// report sends progress of the deletion, the previous value is replaced if it was not read yet
+func (d *throttledDeletion) report(path string, items int, freed int64) {
+	d.reported.CurrentItemName = path
+	d.reported.ItemCount += items
+	d.reported.TotalSize += freed
+
+	select {
+	case <-d.progress:
+	default:
+	}
+	select {
+	case d.progress <- d.reported:
+	default:
+	}
+}
+
+// dropFiles removes items from the loaded dir and subtracts their totals from the ancestors.
+// Directories loaded from the incremental cache are linked to their parents only by a path marker,
+// so the totals are subtracted from the ancestors which RemoveFile does not reach
+func dropFiles(dir fs.Item, ancestors, items []fs.Item) {
+	if len(items) == 0 {
+		return
+	}
+
+	removed := &analyze.Dir{File: &analyze.File{}}
+	for _, item := range items {
+		removed.ItemCount += item.GetItemCount()
+		removed.Size += item.GetSize()
+		removed.Usage += item.GetUsage()
+	}
+
+	if d, ok := dir.(*analyze.Dir); ok {
+		d.RemoveFiles(items)
+	} else {
+		for _, item := range items {
+			dir.RemoveFile(item)
+		}
+	}
+
+	updated := make(map[fs.Item]struct{})
+	addLinked(dir, updated)
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		ancestor, ok := ancestors[i].(*analyze.Dir)
+		if !ok {
+			continue
+		}
+		if _, ok := updated[ancestor]; ok {
+			continue
+		}
+		ancestor.ComputeAggregates(removed, nil)
+		addLinked(ancestor, updated)
+	}
+}
+
+// addLinked adds dir and its ancestors reachable by parent links to the set
+func addLinked(dir fs.Item, set map[fs.Item]struct{}) {
+	for {
+		set[dir] = struct{}{}
+		parent, ok := dir.GetParent().(*analyze.Dir)
+		if !ok {
+			return
+		}
+		dir = parent
+	}
+}
+
+func isNotEmpty(err error) bool {
+	return errors.Is(err, syscall.ENOTEMPTY) || errors.Is(err, syscall.EEXIST)
+}
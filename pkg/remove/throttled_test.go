@@ -0,0 +1,160 @@
+package remove
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// createLargeTree creates root/big with dirs directories of files files each
+func createLargeTree(t *testing.T, dirs, files int) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "root")
+	for i := 0; i < dirs; i++ {
+		dir := filepath.Join(root, "big", fmt.Sprintf("d%d", i))
+		assert.NoError(t, os.MkdirAll(dir, 0o755))
+		for j := 0; j < files; j++ {
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%d", j)), []byte("data"), 0o600))
+		}
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "keep"), []byte("data"), 0o600))
+	return root
+}
+
+func scanIncremental(t *testing.T, root, storagePath string, maxIOPS int) (*analyze.IncrementalAnalyzer, *analyze.Dir) {
+	t.Helper()
+	analyzer := analyze.CreateIncrementalAnalyzer(analyze.IncrementalOptions{
+		StoragePath: storagePath,
+		MaxIOPS:     maxIOPS,
+	})
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false).(*analyze.Dir)
+	analyzer.GetDone().Wait()
+	dir.UpdateStats(make(fs.HardLinkedItems))
+	return analyzer, dir
+}
+
+func findChild(dir fs.Item, name string) fs.Item {
+	for _, item := range dir.GetFiles() {
+		if item.GetName() == name {
+			return item
+		}
+	}
+	return nil
+}
+
+func TestThrottledRemoverPacing(t *testing.T) {
+	root := createLargeTree(t, 3, 30)
+	analyzer, dir := scanIncremental(t, root, t.TempDir(), 50)
+	analyzer.GetThrottle().Reset()
+	big := findChild(dir, "big")
+	itemCount := big.GetItemCount()
+	usage := big.GetUsage()
+
+	remover := NewThrottledRemover(analyzer.GetThrottle(), analyzer.InvalidateRemoved)
+	start := time.Now()
+	err := remover.ItemFromDir(dir, big)
+	elapsed := time.Since(start)
+
+	assert.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(root, "big"))
+	assert.Nil(t, findChild(dir, "big"))
+	assert.Equal(t, 2, dir.GetItemCount())
+
+	// 94 removals, 50 of them in the initial burst
+	assert.GreaterOrEqual(t, elapsed, 800*time.Millisecond)
+
+	progress := <-remover.GetProgressChan()
+	assert.Equal(t, itemCount, progress.ItemCount)
+	assert.Equal(t, usage, progress.TotalSize)
+	assert.Equal(t, filepath.Join(root, "big"), progress.CurrentItemName)
+}
+
+func TestThrottledRemoverCancel(t *testing.T) {
+	root := createLargeTree(t, 3, 30)
+	storagePath := t.TempDir()
+	analyzer, dir := scanIncremental(t, root, storagePath, 20)
+	analyzer.GetThrottle().Reset()
+	big := findChild(dir, "big")
+
+	remover := NewThrottledRemover(analyzer.GetThrottle(), analyzer.InvalidateRemoved)
+	errs := make(chan error)
+	go func() {
+		errs <- remover.ItemFromDir(dir, big)
+	}()
+
+	for progress := range remover.GetProgressChan() {
+		if progress.ItemCount >= 25 {
+			break
+		}
+	}
+	remover.Cancel()
+	err := <-errs
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.DirExists(t, filepath.Join(root, "big"))
+
+	// the loaded tree matches what is left on the disk
+	onDisk := 0
+	assert.NoError(t, filepath.Walk(root, func(_ string, _ os.FileInfo, err error) error {
+		onDisk++
+		return err
+	}))
+	assert.Equal(t, onDisk, dir.GetItemCount())
+
+	// removed entries are rescanned instead of being read from the cache
+	_, rescanned := scanIncremental(t, root, storagePath, 0)
+	assert.Equal(t, dir.GetItemCount(), rescanned.GetItemCount())
+	assert.Equal(t, dir.GetUsage(), rescanned.GetUsage())
+	assert.Equal(t, big.GetItemCount(), findChild(rescanned, "big").GetItemCount())
+}
+
+func TestThrottledRemoverPause(t *testing.T) {
+	root := createLargeTree(t, 1, 10)
+	analyzer, dir := scanIncremental(t, root, t.TempDir(), 1000)
+	big := findChild(dir, "big")
+
+	remover := NewThrottledRemover(analyzer.GetThrottle(), analyzer.InvalidateRemoved)
+	remover.Pause()
+	assert.True(t, remover.IsPaused())
+
+	errs := make(chan error)
+	go func() {
+		errs <- remover.ItemFromDir(dir, big)
+	}()
+
+	select {
+	case <-errs:
+		t.Fatal("paused deletion finished")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.FileExists(t, filepath.Join(root, "big", "d0", "f0"))
+
+	remover.Resume()
+	assert.NoError(t, <-errs)
+	assert.NoDirExists(t, filepath.Join(root, "big"))
+}
+
+func TestThrottledRemoverUnknownEntries(t *testing.T) {
+	root := createLargeTree(t, 1, 2)
+	analyzer, dir := scanIncremental(t, root, t.TempDir(), 1000)
+	big := findChild(dir, "big")
+
+	// created after the scan
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "big", "d0", "new", "deep"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "big", "d0", "new", "file"), []byte("data"), 0o600))
+
+	remover := NewThrottledRemover(analyzer.GetThrottle(), nil)
+	err := remover.ItemFromDir(dir, big)
+
+	assert.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(root, "big"))
+	assert.Equal(t, 2, dir.GetItemCount())
+}
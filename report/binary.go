@@ -0,0 +1,340 @@
+package report
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/dundee/gdu/v5/build"
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// BinaryExtension is the file extension selecting the binary export format
+const BinaryExtension = ".gdub"
+
+// binaryMagic starts every binary export, it is followed by the format version
+// and the gzip-compressed records
+const binaryMagic = "GDUB"
+
+const binaryVersion = 1
+
+// Records of the binary format. Directory is followed by records of its items
+// and closed by recordEnd, the items hold the same fields as the JSON export
+const (
+	recordDir byte = iota + 1
+	recordFile
+	recordEnd
+)
+
+// WriteBinary writes dir in the compact binary format.
+// The records hold the same data as the JSON export, integers are varints
+// and the stream is gzip-compressed
+func WriteBinary(output io.Writer, dir fs.Item) error {
+	if _, err := io.WriteString(output, binaryMagic); err != nil {
+		return err
+	}
+	if _, err := output.Write([]byte{binaryVersion}); err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(output)
+	w := &binaryWriter{w: bufio.NewWriter(gz)}
+	w.string(build.Version)
+	w.varint(time.Now().Unix())
+	w.dir(dir, true)
+
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// binaryWriter writes records, the first error is kept and stops further writes
+type binaryWriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (w *binaryWriter) byte(b byte) {
+	if w.err == nil {
+		w.err = w.w.WriteByte(b)
+	}
+}
+
+func (w *binaryWriter) varint(v int64) {
+	if w.err == nil {
+		_, w.err = w.w.Write(w.buf[:binary.PutVarint(w.buf[:], v)])
+	}
+}
+
+func (w *binaryWriter) uvarint(v uint64) {
+	if w.err == nil {
+		_, w.err = w.w.Write(w.buf[:binary.PutUvarint(w.buf[:], v)])
+	}
+}
+
+func (w *binaryWriter) string(s string) {
+	w.uvarint(uint64(len(s)))
+	if w.err == nil {
+		_, w.err = w.w.WriteString(s)
+	}
+}
+
+// time writes Unix time, zero time is written as 0
+func (w *binaryWriter) time(t time.Time) {
+	if t.IsZero() {
+		w.varint(0)
+		return
+	}
+	w.varint(t.Unix())
+}
+
+func (w *binaryWriter) dir(item fs.Item, topLevel bool) {
+	dir := &analyze.Dir{File: &analyze.File{}}
+	switch d := item.(type) {
+	case *analyze.Dir:
+		dir = d
+	case *analyze.StoredDir:
+		dir = d.Dir
+	}
+
+	w.byte(recordDir)
+	if topLevel {
+		w.string(item.GetPath())
+	} else {
+		w.string(item.GetName())
+	}
+	w.time(item.GetMtime())
+	w.time(btimeOf(item))
+	w.varint(dir.SelfSize)
+	w.varint(dir.SelfUsage)
+	w.varint(int64(dir.ErrorCount))
+	if est := dir.Estimate; est != nil {
+		w.byte(1)
+		w.varint(int64(est.Sampled))
+		w.varint(int64(est.Skipped))
+		w.varint(est.Size)
+		w.varint(est.Usage)
+		w.uvarint(math.Float64bits(est.RelErr))
+	} else {
+		w.byte(0)
+	}
+	w.string(dir.Annotation)
+
+	for _, child := range item.GetFiles() {
+		if w.err != nil {
+			return
+		}
+		if child.IsDir() {
+			w.dir(child, false)
+			continue
+		}
+		w.file(child)
+	}
+	w.byte(recordEnd)
+}
+
+func (w *binaryWriter) file(file fs.Item) {
+	w.byte(recordFile)
+	w.string(file.GetName())
+	w.varint(file.GetSize())
+	w.varint(file.GetUsage())
+	w.time(file.GetMtime())
+	w.time(btimeOf(file))
+	w.byte(byte(file.GetFlag()))
+	if file.GetFlag() == 'H' {
+		w.uvarint(file.GetMultiLinkedInode())
+	} else {
+		w.uvarint(0)
+	}
+}
+
+// btimeOf returns birth time of the item, zero if it does not hold one
+func btimeOf(item fs.Item) time.Time {
+	if b, ok := item.(interface{ GetBtime() time.Time }); ok {
+		return b.GetBtime()
+	}
+	return time.Time{}
+}
+
+// isBinary returns true if the input starts with the magic of the binary format
+func isBinary(input *bufio.Reader) bool {
+	magic, err := input.Peek(len(binaryMagic))
+	return err == nil && string(magic) == binaryMagic
+}
+
+// readBinary reads analysis written by WriteBinary.
+// It returns the same tree as reading of the JSON export of the same analysis
+func readBinary(input *bufio.Reader) (*analyze.Dir, error) {
+	header := make([]byte, len(binaryMagic)+1)
+	if _, err := io.ReadFull(input, header); err != nil {
+		return nil, err
+	}
+	if version := header[len(binaryMagic)]; version != binaryVersion {
+		return nil, fmt.Errorf("unsupported version %d of binary export", version)
+	}
+
+	gz, err := gzip.NewReader(input)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	r := &binaryReader{r: bufio.NewReader(gz)}
+	r.string() // version of gdu
+	r.varint() // timestamp
+	if r.byte() != recordDir && r.err == nil {
+		r.err = errors.New("binary export does not start with directory")
+	}
+	dir := r.dir()
+	if r.err == nil {
+		// reading up to the end verifies the checksum of the gzip stream
+		if _, err := r.r.ReadByte(); err == nil {
+			r.err = errors.New("unexpected data after the top directory")
+		} else if err != io.EOF {
+			r.err = err
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("reading binary export: %w", r.err)
+	}
+	return dir, nil
+}
+
+// binaryReader reads records, the first error is kept and further reads return zero values
+type binaryReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (r *binaryReader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	var b byte
+	b, r.err = r.r.ReadByte()
+	return b
+}
+
+func (r *binaryReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	var v int64
+	v, r.err = binary.ReadVarint(r.r)
+	return v
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	var v uint64
+	v, r.err = binary.ReadUvarint(r.r)
+	return v
+}
+
+func (r *binaryReader) string() string {
+	n := r.uvarint()
+	if r.err != nil {
+		return ""
+	}
+	var b strings.Builder
+	if _, r.err = io.CopyN(&b, r.r, int64(n)); r.err != nil {
+		return ""
+	}
+	return b.String()
+}
+
+func (r *binaryReader) time() time.Time {
+	if t := r.varint(); t != 0 {
+		return time.Unix(t, 0)
+	}
+	return time.Time{}
+}
+
+// dir reads directory whose record tag was already read
+func (r *binaryReader) dir() *analyze.Dir {
+	dir := &analyze.Dir{
+		File: &analyze.File{
+			Flag: ' ',
+		},
+	}
+	name := r.string()
+	dir.Mtime = r.time()
+	dir.Btime = r.time()
+	dir.SelfSize = r.varint()
+	dir.SelfUsage = r.varint()
+	dir.ErrorCount = int(r.varint())
+	if r.byte() == 1 {
+		dir.Estimate = &analyze.Estimate{
+			Sampled: int(r.varint()),
+			Skipped: int(r.varint()),
+			Size:    r.varint(),
+			Usage:   r.varint(),
+			RelErr:  math.Float64frombits(r.uvarint()),
+		}
+		dir.EstimatedDirCount = 1
+		dir.Flag = '~'
+	}
+	dir.Annotation = r.string()
+
+	slashPos := strings.LastIndex(name, "/")
+	if slashPos > -1 {
+		dir.Name = name[slashPos+1:]
+		dir.BasePath = name[:slashPos+1]
+	} else {
+		dir.Name = name
+	}
+
+	for r.err == nil {
+		switch tag := r.byte(); tag {
+		case recordEnd:
+			return dir
+		case recordDir:
+			subdir := r.dir()
+			subdir.Parent = dir
+			dir.AddFile(subdir)
+			dir.EstimatedDirCount += subdir.EstimatedDirCount
+		case recordFile:
+			dir.AddFile(r.file(dir))
+		default:
+			if r.err == nil {
+				r.err = fmt.Errorf("unknown record %d", tag)
+			}
+		}
+	}
+	return dir
+}
+
+// file reads file whose record tag was already read
+func (r *binaryReader) file(parent *analyze.Dir) *analyze.File {
+	file := &analyze.File{
+		Name:   r.string(),
+		Size:   r.varint(),
+		Usage:  r.varint(),
+		Mtime:  r.time(),
+		Btime:  r.time(),
+		Flag:   rune(r.byte()),
+		Mli:    r.uvarint(),
+		Parent: parent,
+	}
+	// the JSON export keeps only these flags
+	switch file.Flag {
+	case '@', '?', 'S', 'H':
+	default:
+		file.Flag = ' '
+	}
+	return file
+}
@@ -0,0 +1,134 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/stretchr/testify/assert"
+)
+
+const binaryFixture = `
+	[1,2,{"progname":"gdu","progver":"development","timestamp":1626806293},
+	[{"name":"/home/xxx","mtime":1629333600,"note":"keep"},
+	{"name":"gdu.json","asize":33805233,"dsize":33808384},
+	{"name":"sock","notreg":true},
+	[{"name":"app","errors":3,"selfasize":11027,"selfdsize":24576},
+	{"name":"app.go","asize":4638,"dsize":8192},
+	{"name":"app_linux_test2.go","ino":1234,"hlnkc":true,"asize":1410,"dsize":4096},
+	{"name":"app_test.go","asize":4974,"dsize":8192}],
+	[{"name":"big","estimate":{"sampled":1,"skipped":3,"asize":300,"dsize":12288,"relerr":0.1}},
+	{"name":"file","asize":100,"dsize":4096}],
+	{"name":"main.go","asize":3205,"dsize":4096,"mtime":1629333600,"btime":1629247200},
+	{"name":"dangling","asize":7,"notreg":true,"broken":true},
+	{"name":"fifo","notreg":true,"special":true}]]
+`
+
+func TestBinaryRoundTrip(t *testing.T) {
+	fromJSON, err := ReadAnalysis(bytes.NewBufferString(binaryFixture))
+	assert.Nil(t, err)
+
+	var buff bytes.Buffer
+	assert.Nil(t, WriteBinary(&buff, fromJSON))
+	assert.Equal(t, binaryMagic, buff.String()[:len(binaryMagic)])
+
+	fromBinary, err := ReadAnalysis(&buff)
+	assert.Nil(t, err)
+	assert.Equal(t, fromJSON, fromBinary)
+	assert.Equal(t, '~', fromBinary.Files[3].GetFlag())
+	assert.Equal(t, "keep", fromBinary.Annotation)
+}
+
+func TestReadBinaryErrors(t *testing.T) {
+	_, err := ReadAnalysis(bytes.NewBufferString(binaryMagic + "\x02"))
+	assert.ErrorContains(t, err, "unsupported version 2")
+
+	_, err = ReadAnalysis(bytes.NewBufferString(binaryMagic))
+	assert.NotNil(t, err)
+
+	var buff bytes.Buffer
+	assert.Nil(t, WriteBinary(&buff, &analyze.Dir{File: &analyze.File{Name: "xxx"}}))
+	truncated := buff.Bytes()[:buff.Len()-10]
+	_, err = ReadAnalysis(bytes.NewBuffer(truncated))
+	assert.NotNil(t, err)
+}
+
+// createSyntheticTree returns tree of dirs directories with files files each
+func createSyntheticTree(dirs, files int) *analyze.Dir {
+	mtime := time.Unix(1629333600, 0)
+	root := &analyze.Dir{File: &analyze.File{Name: "root", Mtime: mtime}, BasePath: "/"}
+	for i := 0; i < dirs; i++ {
+		dir := &analyze.Dir{File: &analyze.File{Name: fmt.Sprintf("dir%04d", i), Mtime: mtime, Parent: root}}
+		for j := 0; j < files; j++ {
+			dir.AddFile(&analyze.File{
+				Name:   fmt.Sprintf("file%05d.dat", j),
+				Size:   int64(j * 100),
+				Usage:  int64((j*100/4096 + 1) * 4096),
+				Mtime:  mtime.Add(time.Duration(j) * time.Second),
+				Flag:   ' ',
+				Parent: dir,
+			})
+		}
+		root.AddFile(dir)
+	}
+	return root
+}
+
+func TestBinarySmallerAndFaster(t *testing.T) {
+	tree := createSyntheticTree(50, 2000)
+
+	var jsonBuff bytes.Buffer
+	jsonBuff.WriteString(`[1,2,{"progname":"gdu"},` + "\n")
+	assert.Nil(t, tree.EncodeJSON(&jsonBuff, true))
+	jsonBuff.WriteString("]\n")
+
+	var binaryBuff bytes.Buffer
+	assert.Nil(t, WriteBinary(&binaryBuff, tree))
+	assert.Less(t, binaryBuff.Len()*5, jsonBuff.Len(), "binary export is at least 5 times smaller")
+
+	start := time.Now()
+	fromJSON, err := ReadAnalysis(bytes.NewReader(jsonBuff.Bytes()))
+	assert.Nil(t, err)
+	jsonTime := time.Since(start)
+
+	start = time.Now()
+	fromBinary, err := ReadAnalysis(bytes.NewReader(binaryBuff.Bytes()))
+	assert.Nil(t, err)
+	binaryTime := time.Since(start)
+
+	assert.Equal(t, fromJSON, fromBinary)
+	t.Logf("JSON: %d bytes read in %v, binary: %d bytes read in %v",
+		jsonBuff.Len(), jsonTime, binaryBuff.Len(), binaryTime)
+}
+
+func BenchmarkReadAnalysisJSON(b *testing.B) {
+	var buff bytes.Buffer
+	buff.WriteString(`[1,2,{"progname":"gdu"},` + "\n")
+	if err := createSyntheticTree(50, 2000).EncodeJSON(&buff, true); err != nil {
+		b.Fatal(err)
+	}
+	buff.WriteString("]\n")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadAnalysis(bytes.NewReader(buff.Bytes())); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadAnalysisBinary(b *testing.B) {
+	var buff bytes.Buffer
+	if err := WriteBinary(&buff, createSyntheticTree(50, 2000)); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadAnalysis(bytes.NewReader(buff.Bytes())); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
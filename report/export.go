@@ -28,6 +28,7 @@ type UI struct {
 	red          *color.Color
 	orange       *color.Color
 	writtenChan  chan struct{}
+	binary       bool
 }
 
 // CreateExportUI creates UI for stdout
@@ -60,6 +61,11 @@ func CreateExportUI(
 	return ui
 }
 
+// UseBinaryFormat exports in the compact binary format (see WriteBinary) instead of JSON
+func (ui *UI) UseBinaryFormat() {
+	ui.binary = true
+}
+
 // StartUILoop stub
 func (ui *UI) StartUILoop() error {
 	return nil
@@ -134,10 +140,21 @@ func (ui *UI) exportDir(dir fs.Item, waitWritten *sync.WaitGroup) error {
 		err  error
 	)
 
+	if ui.binary {
+		if err := WriteBinary(ui.exportOutput, dir); err != nil {
+			return err
+		}
+		return ui.finishExport(waitWritten)
+	}
+
 	buff.Write([]byte(`[1,2,{"progname":"gdu","progver":"`))
 	buff.Write([]byte(build.Version))
 	buff.Write([]byte(`","timestamp":`))
 	buff.Write([]byte(strconv.FormatInt(time.Now().Unix(), 10)))
+	if d, ok := dir.(interface{ GetHardlinksSaved() int64 }); ok && d.GetHardlinksSaved() > 0 {
+		buff.Write([]byte(`,"hardlinks_saved":`))
+		buff.Write([]byte(strconv.FormatInt(d.GetHardlinksSaved(), 10)))
+	}
 	buff.Write([]byte("},\n"))
 
 	if err := dir.EncodeJSON(&buff, true); err != nil {
@@ -150,9 +167,13 @@ func (ui *UI) exportDir(dir fs.Item, waitWritten *sync.WaitGroup) error {
 		return err
 	}
 
+	return ui.finishExport(waitWritten)
+}
+
+// finishExport closes the output file and waits for the progress to be written
+func (ui *UI) finishExport(waitWritten *sync.WaitGroup) error {
 	if f, ok := ui.exportOutput.(*os.File); ok {
-		err = f.Close()
-		if err != nil {
+		if err := f.Close(); err != nil {
 			return err
 		}
 	}
@@ -176,7 +197,6 @@ func (ui *UI) updateProgress() {
 	progressRunes := []rune(`⠇⠏⠋⠙⠹⠸⠼⠴⠦⠧`)
 
 	progressChan := ui.Analyzer.GetProgressChan()
-	doneChan := ui.Analyzer.GetDone()
 
 	var progress common.CurrentProgress
 
@@ -185,10 +205,15 @@ func (ui *UI) updateProgress() {
 		fmt.Fprint(ui.output, emptyRow)
 
 		select {
-		case progress = <-progressChan:
-		case <-doneChan:
-			fmt.Fprint(ui.output, "\r")
-			waitingForWrite = true
+		case p, ok := <-progressChan:
+			if !ok {
+				// analysis is done
+				fmt.Fprint(ui.output, "\r")
+				waitingForWrite = true
+				progressChan = nil
+				break
+			}
+			progress = p
 		case <-ui.writtenChan:
 			fmt.Fprint(ui.output, "\r")
 			return
@@ -5,7 +5,9 @@ package report
 
 import (
 	"bytes"
+	"fmt"
 	"os"
+	"syscall"
 	"testing"
 
 	"github.com/dundee/gdu/v5/internal/testdir"
@@ -54,3 +56,24 @@ func TestReadFromStorageWithErr(t *testing.T) {
 
 	assert.ErrorContains(t, err, "Key not found")
 }
+
+func TestExportHardlinksSaved(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	assert.NoError(t, os.Link("test_dir/nested/file2", "test_dir/nested/subnested/link"))
+	assert.NoError(t, os.Link("test_dir/nested/file2", "test_dir/link"))
+	info, err := os.Stat("test_dir/nested/file2")
+	assert.NoError(t, err)
+	usage := info.Sys().(*syscall.Stat_t).Blocks * 512
+
+	output := bytes.NewBuffer(make([]byte, 10))
+	reportOutput := bytes.NewBuffer(make([]byte, 10))
+
+	ui := CreateExportUI(output, reportOutput, false, false, false, false)
+	err = ui.AnalyzePath("test_dir", nil)
+	assert.Nil(t, err)
+
+	// two of the three links are not counted
+	assert.Contains(t, reportOutput.String(), fmt.Sprintf(`,"hardlinks_saved":%d},`, 2*usage))
+}
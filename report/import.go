@@ -1,6 +1,7 @@
 package report
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
@@ -11,12 +12,18 @@ import (
 	"github.com/dundee/gdu/v5/pkg/analyze"
 )
 
-// ReadAnalysis reads analysis report from JSON file and returns directory item
+// ReadAnalysis reads analysis report from JSON file (or the binary export, see WriteBinary)
+// and returns directory item
 func ReadAnalysis(input io.Reader) (*analyze.Dir, error) {
 	var data interface{}
 
+	reader := bufio.NewReader(input)
+	if isBinary(reader) {
+		return readBinary(reader)
+	}
+
 	var buff bytes.Buffer
-	if _, err := buff.ReadFrom(input); err != nil {
+	if _, err := buff.ReadFrom(reader); err != nil {
 		return nil, err
 	}
 	if err := json.Unmarshal(buff.Bytes(), &data); err != nil {
@@ -56,6 +63,26 @@ func processDir(items []interface{}) (*analyze.Dir, error) {
 	if mtime, ok := dirMap["mtime"].(float64); ok {
 		dir.Mtime = time.Unix(int64(mtime), 0)
 	}
+	if btime, ok := dirMap["btime"].(float64); ok {
+		dir.Btime = time.Unix(int64(btime), 0)
+	}
+	if selfSize, ok := dirMap["selfasize"].(float64); ok {
+		dir.SelfSize = int64(selfSize)
+	}
+	if selfUsage, ok := dirMap["selfdsize"].(float64); ok {
+		dir.SelfUsage = int64(selfUsage)
+	}
+	if errCount, ok := dirMap["errors"].(float64); ok {
+		dir.ErrorCount = int(errCount)
+	}
+	if est, ok := dirMap["estimate"].(map[string]interface{}); ok {
+		dir.Estimate = readEstimate(est)
+		dir.EstimatedDirCount = 1
+		dir.Flag = '~'
+	}
+	if note, ok := dirMap["note"].(string); ok {
+		dir.Annotation = note
+	}
 
 	slashPos := strings.LastIndex(name, "/")
 	if slashPos > -1 {
@@ -80,7 +107,12 @@ func processDir(items []interface{}) (*analyze.Dir, error) {
 			if mtime, ok := item["mtime"].(float64); ok {
 				file.Mtime = time.Unix(int64(mtime), 0)
 			}
-			if _, ok := item["notreg"].(bool); ok {
+			if btime, ok := item["btime"].(float64); ok {
+				file.Btime = time.Unix(int64(btime), 0)
+			}
+			if _, ok := item["special"].(bool); ok {
+				file.Flag = 'S'
+			} else if _, ok := item["notreg"].(bool); ok {
 				file.Flag = '@'
 			} else {
 				file.Flag = ' '
@@ -91,6 +123,9 @@ func processDir(items []interface{}) (*analyze.Dir, error) {
 			if _, ok := item["hlnkc"].(bool); ok {
 				file.Flag = 'H'
 			}
+			if _, ok := item["broken"].(bool); ok {
+				file.Flag = '?'
+			}
 
 			file.Parent = dir
 
@@ -102,8 +137,29 @@ func processDir(items []interface{}) (*analyze.Dir, error) {
 			}
 			subdir.Parent = dir
 			dir.AddFile(subdir)
+			dir.EstimatedDirCount += subdir.EstimatedDirCount
 		}
 	}
 
 	return dir, nil
 }
+
+func readEstimate(item map[string]interface{}) *analyze.Estimate {
+	est := &analyze.Estimate{}
+	if sampled, ok := item["sampled"].(float64); ok {
+		est.Sampled = int(sampled)
+	}
+	if skipped, ok := item["skipped"].(float64); ok {
+		est.Skipped = int(skipped)
+	}
+	if asize, ok := item["asize"].(float64); ok {
+		est.Size = int64(asize)
+	}
+	if dsize, ok := item["dsize"].(float64); ok {
+		est.Usage = int64(dsize)
+	}
+	if relErr, ok := item["relerr"].(float64); ok {
+		est.RelErr = relErr
+	}
+	return est
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/stretchr/testify/assert"
@@ -21,12 +22,14 @@ func TestReadAnalysis(t *testing.T) {
 		[{"name":"/home/xxx","mtime":1629333600},
 		{"name":"gdu.json","asize":33805233,"dsize":33808384},
 		{"name":"sock","notreg":true},
-		[{"name":"app"},
+		[{"name":"app","errors":3},
 		{"name":"app.go","asize":4638,"dsize":8192},
 		{"name":"app_linux_test.go","asize":1410,"dsize":4096},
 		{"name":"app_linux_test2.go","ino":1234,"hlnkc":true,"asize":1410,"dsize":4096},
 		{"name":"app_test.go","asize":4974,"dsize":8192}],
-		{"name":"main.go","asize":3205,"dsize":4096,"mtime":1629333600}]]
+		{"name":"main.go","asize":3205,"dsize":4096,"mtime":1629333600,"btime":1629247200},
+		{"name":"dangling","asize":7,"notreg":true,"broken":true},
+		{"name":"fifo","notreg":true,"special":true}]]
 	`))
 
 	dir, err := ReadAnalysis(buff)
@@ -40,6 +43,66 @@ func TestReadAnalysis(t *testing.T) {
 	assert.Equal(t, "app_linux_test2.go", alt2.Name)
 	assert.Equal(t, uint64(1234), alt2.Mli)
 	assert.Equal(t, 'H', alt2.Flag)
+	assert.Equal(t, 3, dir.Files[2].(*analyze.Dir).ErrorCount)
+	assert.Equal(t, int64(1629247200), dir.Files[3].(*analyze.File).Btime.Unix())
+	assert.True(t, dir.Files[2].(*analyze.Dir).Btime.IsZero())
+	assert.Equal(t, '@', dir.Files[1].GetFlag())
+	assert.Equal(t, '?', dir.Files[4].GetFlag())
+	assert.Equal(t, 'S', dir.Files[5].GetFlag())
+}
+
+func TestReadAnalysisEstimate(t *testing.T) {
+	buff := bytes.NewBuffer([]byte(`
+		[1,2,{"progname":"gdu","progver":"development","timestamp":1626806293},
+		[{"name":"/home/xxx"},
+		[{"name":"big","estimate":{"sampled":1,"skipped":3,"asize":300,"dsize":12288,"relerr":0.1}},
+		{"name":"file","asize":100,"dsize":4096}],
+		[{"name":"small"},
+		{"name":"file","asize":100,"dsize":4096}]]]
+	`))
+
+	dir, err := ReadAnalysis(buff)
+	assert.Nil(t, err)
+
+	big := dir.Files[0].(*analyze.Dir)
+	assert.Equal(t, '~', big.GetFlag())
+	assert.Equal(t, &analyze.Estimate{Sampled: 1, Skipped: 3, Size: 300, Usage: 12288, RelErr: 0.1}, big.Estimate)
+	assert.True(t, dir.IsEstimated())
+	assert.False(t, dir.Files[1].(*analyze.Dir).IsEstimated())
+
+	dir.UpdateStats(make(fs.HardLinkedItems))
+	assert.Equal(t, int64(4096+400), big.GetSize())
+	assert.Equal(t, 5, big.GetItemCount())
+}
+
+func TestReadAnalysisAnnotation(t *testing.T) {
+	buff := bytes.NewBuffer([]byte(`
+		[1,2,{"progname":"gdu","progver":"development","timestamp":1626806293},
+		[{"name":"/home/xxx","note":"safe to delete"},
+		[{"name":"sub"}]]]
+	`))
+
+	dir, err := ReadAnalysis(buff)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "safe to delete", dir.GetAnnotation())
+	assert.Equal(t, "", dir.Files[0].(*analyze.Dir).GetAnnotation())
+}
+
+func TestReadAnalysisSelfSizes(t *testing.T) {
+	buff := bytes.NewBuffer([]byte(`
+		[1,2,{"progname":"gdu","progver":"development","timestamp":1626806293},
+		[{"name":"/home/xxx","selfasize":100,"selfdsize":4096},
+		{"name":"file","asize":100,"dsize":4096},
+		[{"name":"sub"}]]]
+	`))
+
+	dir, err := ReadAnalysis(buff)
+	assert.Nil(t, err)
+
+	assert.Equal(t, int64(100), dir.GetSelfSize())
+	assert.Equal(t, int64(4096), dir.GetSelfUsage())
+	assert.Equal(t, int64(0), dir.Files[1].(*analyze.Dir).GetSelfUsage())
 }
 
 func TestReadAnalysisWithEmptyInput(t *testing.T) {
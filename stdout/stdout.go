@@ -1,12 +1,19 @@
 package stdout
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/dundee/gdu/v5/internal/common"
@@ -21,14 +28,30 @@ import (
 type UI struct {
 	*common.UI
 	output         io.Writer
+	errOutput      io.Writer // notes which are not part of the report
 	red            *color.Color
 	orange         *color.Color
 	blue           *color.Color
+	green          *color.Color
 	summarize      bool
 	noPrefix       bool
 	top            int
 	reverseSort    bool
 	showCacheStats bool
+	ageHistogram   bool
+	byOwner        bool
+	ownersLimit    int
+	duplicates     *analyze.DuplicateOptions
+	offenders      *analyze.OffenderOptions
+	baseline       fs.Item
+	offendersJSON  bool
+	diffTop        int
+	brokenLinks    bool
+	emptyDirs      *emptyDirsOptions
+	priority       string
+	treeDepth      int
+	treeWidth      int
+	asciiTree      bool
 }
 
 var (
@@ -63,6 +86,7 @@ func CreateStdoutUI(
 			UseSIPrefix:      useSIPrefix,
 		},
 		output:         output,
+		errOutput:      os.Stderr,
 		summarize:      summarize,
 		noPrefix:       noPrefix,
 		top:            top,
@@ -73,6 +97,7 @@ func CreateStdoutUI(
 	ui.red = color.New(color.FgRed).Add(color.Bold)
 	ui.orange = color.New(color.FgYellow).Add(color.Bold)
 	ui.blue = color.New(color.FgBlue).Add(color.Bold)
+	ui.green = color.New(color.FgGreen).Add(color.Bold)
 
 	if !useColors {
 		color.NoColor = true
@@ -81,9 +106,66 @@ func CreateStdoutUI(
 	return ui
 }
 
+// UseOldProgressRunes uses ASCII characters for the progress and the branches of the tree
 func (ui *UI) UseOldProgressRunes() {
 	progressRunes = progressRunesOld
 	progressRunesCount = len(progressRunes)
+	ui.asciiTree = true
+}
+
+// ShowAgeHistogram prints distribution of file sizes by age instead of the directory listing
+func (ui *UI) ShowAgeHistogram() {
+	ui.ageHistogram = true
+}
+
+// ShowUsageByOwner prints usage of files by their owner instead of the directory listing,
+// at most limit owners (0 = all)
+func (ui *UI) ShowUsageByOwner(limit int) {
+	ui.byOwner = true
+	ui.ownersLimit = limit
+}
+
+// ShowDuplicates prints sets of files with the same content instead of the directory listing
+func (ui *UI) ShowDuplicates(opts analyze.DuplicateOptions) {
+	ui.duplicates = &opts
+}
+
+// ShowOffenders prints directories ranked by size and growth since the baseline
+// instead of the directory listing. Nil baseline makes all directories new
+func (ui *UI) ShowOffenders(opts analyze.OffenderOptions, baseline fs.Item, asJSON bool) {
+	ui.offenders = &opts
+	ui.baseline = baseline
+	ui.offendersJSON = asJSON
+}
+
+// ShowDiff prints top directories grown since the previous incremental scan
+// instead of the directory listing
+func (ui *UI) ShowDiff(top int) {
+	ui.diffTop = top
+}
+
+// ShowBrokenSymlinks prints paths of symlinks which could not be followed
+// instead of the directory listing
+func (ui *UI) ShowBrokenSymlinks() {
+	ui.brokenLinks = true
+}
+
+// emptyDirsOptions are options of the listing of empty directories
+type emptyDirsOptions struct {
+	recursive bool
+	script    bool
+}
+
+// ShowEmptyDirs prints empty directories instead of the directory listing.
+// With recursive, directories containing only empty directories are printed as well,
+// with script a shell script removing them is printed instead of the list
+func (ui *UI) ShowEmptyDirs(recursive, script bool) {
+	ui.emptyDirs = &emptyDirsOptions{recursive: recursive, script: script}
+}
+
+// SetPriority sets description of the CPU priority of the scan shown in cache statistics
+func (ui *UI) SetPriority(priority string) {
+	ui.priority = priority
 }
 
 // StartUILoop stub
@@ -151,6 +233,89 @@ func (ui *UI) ListDevices(getter device.DevicesInfoGetter) error {
 
 // AnalyzePath analyzes recursively disk usage in given path
 func (ui *UI) AnalyzePath(path string, _ fs.Item) error {
+	dir := ui.runAnalysis(func() fs.Item {
+		return ui.Analyzer.AnalyzeDir(path, ui.CreateIgnoreFunc(), ui.ConstGC)
+	})
+	if dir == nil {
+		return fmt.Errorf("analysis failed")
+	}
+
+	if incrementalAnalyzer, ok := ui.Analyzer.(*analyze.IncrementalAnalyzer); ok {
+		if result := incrementalAnalyzer.GetScanResult(); result != nil && result.SingleFile {
+			ui.printSingleFileNote(path)
+		} else if result != nil && result.RootIgnored {
+			ui.printIgnoredRootNote(path)
+		}
+	}
+
+	if ui.offenders == nil && ui.diffTop > 0 {
+		if err := ui.printDiff(); err != nil {
+			return err
+		}
+	} else if printed, err := ui.printAnalyzed(dir); !printed || err != nil {
+		return err
+	}
+
+	// Display cache statistics if requested and analyzer supports it
+	if ui.showCacheStats {
+		if incrementalAnalyzer, ok := ui.Analyzer.(*analyze.IncrementalAnalyzer); ok {
+			ui.printCacheStats(incrementalAnalyzer.GetCacheStats())
+		}
+	}
+
+	return nil
+}
+
+// AnalyzePaths analyzes the directories in one scan of the incremental analyzer sharing the cache,
+// every directory is cached under its own path. The output has a section for each of them,
+// the diff and the cache statistics cover the whole scan. The result of the scan is returned
+func (ui *UI) AnalyzePaths(paths []string) (*analyze.ScanResult, error) {
+	incrementalAnalyzer, ok := ui.Analyzer.(*analyze.IncrementalAnalyzer)
+	if !ok {
+		return nil, fmt.Errorf("multiple directories can be analyzed only by the incremental analyzer")
+	}
+
+	top := ui.runAnalysis(func() fs.Item {
+		return incrementalAnalyzer.AnalyzeDirs(paths, ui.CreateIgnoreFunc(), ui.ConstGC)
+	})
+	if top == nil {
+		return nil, fmt.Errorf("analysis failed")
+	}
+	result := incrementalAnalyzer.GetScanResult()
+
+	if ui.offenders == nil && ui.diffTop > 0 {
+		if err := ui.printDiff(); err != nil {
+			return result, err
+		}
+	} else {
+		roots := top.GetFiles()
+		for i, dir := range roots {
+			if i > 0 {
+				fmt.Fprintln(ui.output)
+			}
+			fmt.Fprintf(ui.output, "%s:\n", paths[i])
+			switch {
+			case dir.GetFlag() == 'I':
+				ui.printIgnoredRootNote(paths[i])
+			case !isRootOf(dir, paths[i]):
+				ui.printSingleFileNote(paths[i])
+			}
+			if _, err := ui.printAnalyzed(dir); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	if ui.showCacheStats {
+		fmt.Fprintln(ui.output)
+		fmt.Fprintf(ui.output, "Combined (%d directories):", len(paths))
+		ui.printCacheStats(incrementalAnalyzer.GetCacheStats())
+	}
+	return result, nil
+}
+
+// runAnalysis runs analyzeFn showing the progress and returns the analyzed item with updated stats
+func (ui *UI) runAnalysis(analyzeFn func() fs.Item) fs.Item {
 	var (
 		dir             fs.Item
 		wait            sync.WaitGroup
@@ -169,7 +334,7 @@ func (ui *UI) AnalyzePath(path string, _ fs.Item) error {
 	wait.Add(1)
 	go func() {
 		defer wait.Done()
-		dir = ui.Analyzer.AnalyzeDir(path, ui.CreateIgnoreFunc(), ui.ConstGC)
+		dir = analyzeFn()
 		if dir != nil {
 			dir.UpdateStats(make(fs.HardLinkedItems, 10))
 		}
@@ -177,12 +342,28 @@ func (ui *UI) AnalyzePath(path string, _ fs.Item) error {
 	}()
 
 	wait.Wait()
+	return dir
+}
 
-	if dir == nil {
-		return fmt.Errorf("analysis failed")
-	}
-
+// printAnalyzed prints the analyzed directory in the selected output mode.
+// It returns false if the output of the mode must not be followed by anything else,
+// e.g. by the cache statistics
+func (ui *UI) printAnalyzed(dir fs.Item) (bool, error) {
 	switch {
+	case ui.offenders != nil:
+		return false, ui.printOffenders(dir)
+	case ui.brokenLinks:
+		return false, ui.printBrokenSymlinks(dir)
+	case ui.emptyDirs != nil:
+		return false, ui.printEmptyDirs(dir)
+	case ui.ageHistogram:
+		ui.printAgeHistogram(dir)
+	case ui.byOwner:
+		return false, ui.printUsageByOwner(dir)
+	case ui.duplicates != nil:
+		return false, ui.printDuplicates(dir)
+	case ui.treeDepth > 0:
+		ui.printTree(dir, ui.treeMarks())
 	case ui.top > 0:
 		ui.printTopFiles(dir)
 	case ui.summarize:
@@ -190,15 +371,21 @@ func (ui *UI) AnalyzePath(path string, _ fs.Item) error {
 	default:
 		ui.showDir(dir)
 	}
+	return true, nil
+}
 
-	// Display cache statistics if requested and analyzer supports it
-	if ui.showCacheStats {
-		if incrementalAnalyzer, ok := ui.Analyzer.(*analyze.IncrementalAnalyzer); ok {
-			ui.printCacheStats(incrementalAnalyzer.GetCacheStats())
-		}
-	}
+func (ui *UI) printSingleFileNote(path string) {
+	fmt.Fprintf(ui.errOutput, "Note: %s is not a directory, showing just the file\n", path)
+}
 
-	return nil
+func (ui *UI) printIgnoredRootNote(path string) {
+	fmt.Fprintf(ui.errOutput, "Note: %s matches the ignore patterns, its content was not read\n", path)
+}
+
+// isRootOf returns true if dir is the tree of path, not the parent shown for a file given by mistake
+func isRootOf(dir fs.Item, path string) bool {
+	abs, err := filepath.Abs(path)
+	return err != nil || dir.GetPath() == abs
 }
 
 // ReadFromStorage reads analysis data from persistent key-value storage
@@ -213,6 +400,20 @@ func (ui *UI) ReadFromStorage(storagePath, path string) error {
 	}
 
 	switch {
+	case ui.offenders != nil:
+		return ui.printOffenders(dir)
+	case ui.brokenLinks:
+		return ui.printBrokenSymlinks(dir)
+	case ui.emptyDirs != nil:
+		return ui.printEmptyDirs(dir)
+	case ui.ageHistogram:
+		ui.printAgeHistogram(dir)
+	case ui.byOwner:
+		return ui.printUsageByOwner(dir)
+	case ui.duplicates != nil:
+		return ui.printDuplicates(dir)
+	case ui.treeDepth > 0:
+		ui.printTree(dir, nil)
 	case ui.top > 0:
 		ui.printTopFiles(dir)
 	case ui.summarize:
@@ -242,6 +443,281 @@ func (ui *UI) printTopFiles(file fs.Item) {
 	}
 }
 
+func (ui *UI) printAgeHistogram(dir fs.Item) {
+	hist, err := analyze.ComputeAgeHistogram(context.Background(), dir, time.Now(), analyze.DefaultAgeBands)
+	if err != nil {
+		fmt.Fprintf(ui.output, "Error computing age histogram: %v\n", err)
+		return
+	}
+
+	var lineFormat string
+	if ui.UseColors {
+		lineFormat = "%-18s %10s %20s %7s\n"
+	} else {
+		lineFormat = "%-18s %10s %9s %7s\n"
+	}
+
+	total := hist.TotalUsage
+	if ui.ShowApparentSize {
+		total = hist.TotalSize
+	}
+
+	fmt.Fprintf(ui.output, "%-18s %10s %9s %7s\n", "Age", "Files", "Size", "Share")
+	for _, bucket := range hist.Buckets {
+		size := bucket.Usage
+		if ui.ShowApparentSize {
+			size = bucket.Size
+		}
+		share := 0.0
+		if total > 0 {
+			share = float64(size) / float64(total) * 100
+		}
+		fmt.Fprintf(
+			ui.output,
+			lineFormat,
+			bucket.Label(),
+			strconv.Itoa(bucket.Count),
+			ui.formatSize(size),
+			fmt.Sprintf("%.1f%%", share),
+		)
+	}
+}
+
+func (ui *UI) printUsageByOwner(dir fs.Item) error {
+	report, err := analyze.ComputeOwnerUsage(context.Background(), dir, ui.ownersLimit, ui.ShowApparentSize)
+	if err != nil {
+		return fmt.Errorf("computing usage by owner: %w", err)
+	}
+
+	var lineFormat string
+	if ui.UseColors {
+		lineFormat = "%-18s %10s %20s %7s\n"
+	} else {
+		lineFormat = "%-18s %10s %9s %7s\n"
+	}
+
+	total := report.TotalUsage
+	if ui.ShowApparentSize {
+		total = report.TotalSize
+	}
+
+	fmt.Fprintf(ui.output, "%-18s %10s %9s %7s\n", "Owner", "Files", "Size", "Share")
+	for _, owner := range report.Owners {
+		size := owner.Usage
+		if ui.ShowApparentSize {
+			size = owner.Size
+		}
+		share := 0.0
+		if total > 0 {
+			share = float64(size) / float64(total) * 100
+		}
+		fmt.Fprintf(
+			ui.output,
+			lineFormat,
+			owner.Name,
+			strconv.Itoa(owner.Count),
+			ui.formatSize(size),
+			fmt.Sprintf("%.1f%%", share),
+		)
+	}
+	if report.Dropped > 0 {
+		fmt.Fprintf(ui.output, "...and %s more owners\n", common.FormatNumber(int64(report.Dropped)))
+	}
+	fmt.Fprintf(ui.output, lineFormat, "Total", strconv.Itoa(report.TotalCount), ui.formatSize(total), "")
+	return nil
+}
+
+func (ui *UI) printDuplicates(dir fs.Item) error {
+	// hashing of big trees takes long, interrupt stops it
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report, err := analyze.FindDuplicates(ctx, dir, *ui.duplicates)
+	if err != nil {
+		return fmt.Errorf("looking for duplicates: %w", err)
+	}
+
+	for _, set := range report.Sets {
+		fmt.Fprintf(
+			ui.output, "%s reclaimable, %d copies of %s:\n",
+			ui.formatSize(set.Reclaimable), len(set.Paths), ui.formatSize(set.Size),
+		)
+		for _, path := range set.Paths {
+			fmt.Fprintf(ui.output, "  %s\n", ui.blue.Sprint(path))
+		}
+	}
+	for _, linked := range report.Linked {
+		fmt.Fprintf(ui.output, "Already linked, %d links of %s:\n", len(linked.Paths), ui.formatSize(linked.Size))
+		for _, path := range linked.Paths {
+			fmt.Fprintf(ui.output, "  %s\n", path)
+		}
+	}
+
+	fmt.Fprintf(
+		ui.output, "%d duplicate sets, %s reclaimable (hashed %d of %d candidates)\n",
+		len(report.Sets), ui.formatSize(report.Reclaimable), report.Hashed, report.Candidates,
+	)
+	if report.Skipped > 0 {
+		fmt.Fprintf(ui.output, "%s %d candidates not hashed, over the limit of hashed files\n", ui.orange.Sprint("Warning:"), report.Skipped)
+	}
+	if report.Errors > 0 {
+		fmt.Fprintf(ui.output, "%s %d candidates could not be read\n", ui.red.Sprint("Warning:"), report.Errors)
+	}
+	return nil
+}
+
+func (ui *UI) printOffenders(dir fs.Item) error {
+	offenders, err := analyze.RankOffenders(context.Background(), dir, ui.baseline, *ui.offenders)
+	if err != nil {
+		return fmt.Errorf("ranking directories: %w", err)
+	}
+
+	if ui.offendersJSON {
+		encoder := json.NewEncoder(ui.output)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(offenders)
+	}
+
+	var lineFormat string
+	if ui.UseColors {
+		lineFormat = "%7s %20s %20s  %s\n"
+	} else {
+		lineFormat = "%7s %9s %9s  %s\n"
+	}
+
+	fmt.Fprintf(ui.output, "%7s %9s %9s  %s\n", "Score", "Size", "Growth", "Path")
+	for _, o := range offenders {
+		growth := ui.orange.Sprint("new")
+		if !o.New {
+			growth = ui.formatSize(o.Growth)
+			if o.Growth > 0 {
+				growth = "+" + growth
+			}
+		}
+		fmt.Fprintf(
+			ui.output,
+			lineFormat,
+			fmt.Sprintf("%.1f%%", o.Score*100),
+			ui.formatSize(o.Size),
+			growth,
+			ui.blue.Sprint(o.Path),
+		)
+	}
+	return nil
+}
+
+// printDiff prints directories of the last incremental scan which grew the most since the previous one,
+// or between the scans selected by labels
+func (ui *UI) printDiff() error {
+	incrementalAnalyzer, ok := ui.Analyzer.(*analyze.IncrementalAnalyzer)
+	if !ok {
+		return nil
+	}
+	if err := incrementalAnalyzer.DiffError(); err != nil {
+		return fmt.Errorf("comparing labelled scans: %w", err)
+	}
+	deltas := incrementalAnalyzer.ComputeDiff()
+	growers := analyze.TopGrowers(deltas, ui.diffTop, ui.ShowApparentSize)
+
+	var lineFormat string
+	if ui.UseColors {
+		lineFormat = "%20s %20s %20s  %s\n"
+	} else {
+		lineFormat = "%9s %9s %9s  %s\n"
+	}
+
+	fmt.Fprintf(ui.output, "%9s %9s %9s  %s\n", "Growth", "Before", "Now", "Path")
+	for _, delta := range growers {
+		before, now := delta.OldUsage, delta.NewUsage
+		if ui.ShowApparentSize {
+			before, now = delta.OldSize, delta.NewSize
+		}
+		beforeText := ui.formatSize(before)
+		if delta.Status == analyze.DeltaAdded {
+			beforeText = ui.orange.Sprint("new")
+		}
+		fmt.Fprintf(
+			ui.output,
+			lineFormat,
+			"+"+ui.formatSize(delta.Growth(ui.ShowApparentSize)),
+			beforeText,
+			ui.formatSize(now),
+			ui.blue.Sprint(delta.Path),
+		)
+	}
+
+	counts := make(map[analyze.DeltaStatus]int)
+	for _, delta := range deltas {
+		counts[delta.Status]++
+	}
+	since := "since the previous scan"
+	if from, to := incrementalAnalyzer.DiffLabels(); from != "" {
+		since = fmt.Sprintf("since the scan labelled %q", from)
+		if to != "" {
+			since += fmt.Sprintf(" until the scan labelled %q", to)
+		}
+	}
+	fmt.Fprintf(
+		ui.output, "%d added, %d removed, %d changed directories %s\n",
+		counts[analyze.DeltaAdded], counts[analyze.DeltaRemoved], counts[analyze.DeltaChanged], since,
+	)
+	return nil
+}
+
+func (ui *UI) printBrokenSymlinks(dir fs.Item) error {
+	paths, err := analyze.CollectBrokenSymlinks(context.Background(), dir)
+	if err != nil {
+		return fmt.Errorf("collecting broken symlinks: %w", err)
+	}
+	for _, path := range paths {
+		fmt.Fprintln(ui.output, ui.red.Sprint(path))
+	}
+	return nil
+}
+
+func (ui *UI) printEmptyDirs(dir fs.Item) error {
+	report, err := analyze.FindEmptyDirs(context.Background(), dir, ui.emptyDirs.recursive)
+	if err != nil {
+		return fmt.Errorf("looking for empty directories: %w", err)
+	}
+	if ui.emptyDirs.script {
+		return report.WriteRemovalScript(ui.output)
+	}
+
+	for _, empty := range report.Dirs {
+		if len(empty.Nested) > 0 {
+			fmt.Fprintf(ui.output, "%s (%d nested)\n", ui.blue.Sprint(empty.Path), len(empty.Nested))
+		} else {
+			fmt.Fprintln(ui.output, ui.blue.Sprint(empty.Path))
+		}
+	}
+	fmt.Fprintf(ui.output, "%s empty directories\n", common.FormatNumber(int64(report.Total)))
+	return nil
+}
+
+func (ui *UI) printDeviceStats(devices []analyze.DeviceStats) {
+	fmt.Fprintln(ui.output, "  Mount Points:")
+	fmt.Fprintf(ui.output, "    %-30s %8s %8s %8s %10s  %s\n",
+		"Mount Point", "Dirs", "Scanned", "Hit Rate", "Scan Time", "Scanned Bytes")
+	for _, device := range devices {
+		mount := device.MountPoint
+		switch {
+		case device.Device == analyze.OtherDevices:
+			mount = "(other devices)"
+		case mount == "":
+			mount = fmt.Sprintf("(device %d)", device.Device)
+		}
+		fmt.Fprintf(ui.output, "    %-30s %8d %8d %7.1f%% %10s  %s\n",
+			mount,
+			device.Dirs,
+			device.Scanned,
+			device.HitRate(),
+			device.ScanTime.Round(time.Millisecond),
+			ui.formatSize(device.BytesScanned),
+		)
+	}
+}
+
 func (ui *UI) printTotalItem(file fs.Item) {
 	var lineFormat string
 	if ui.UseColors {
@@ -260,7 +736,7 @@ func (ui *UI) printTotalItem(file fs.Item) {
 	fmt.Fprintf(
 		ui.output,
 		lineFormat,
-		ui.formatSize(size),
+		ui.formatItemSize(file, size),
 		file.GetName(),
 	)
 }
@@ -284,13 +760,13 @@ func (ui *UI) printItem(file fs.Item) {
 		fmt.Fprintf(ui.output,
 			lineFormat,
 			string(file.GetFlag()),
-			ui.formatSize(size),
+			ui.formatItemSize(file, size),
 			ui.blue.Sprint("/"+file.GetName()))
 	} else {
 		fmt.Fprintf(ui.output,
 			lineFormat,
 			string(file.GetFlag()),
-			ui.formatSize(size),
+			ui.formatItemSize(file, size),
 			file.GetName())
 	}
 }
@@ -312,7 +788,7 @@ func (ui *UI) printItemPath(file fs.Item) {
 
 	fmt.Fprintf(ui.output,
 		lineFormat,
-		ui.formatSize(size),
+		ui.formatItemSize(file, size),
 		file.GetPath())
 }
 
@@ -359,9 +835,24 @@ func (ui *UI) ReadAnalysis(input io.Reader) error {
 		return err
 	}
 
-	if ui.summarize {
+	switch {
+	case ui.offenders != nil:
+		return ui.printOffenders(dir)
+	case ui.brokenLinks:
+		return ui.printBrokenSymlinks(dir)
+	case ui.emptyDirs != nil:
+		return ui.printEmptyDirs(dir)
+	case ui.ageHistogram:
+		ui.printAgeHistogram(dir)
+	case ui.byOwner:
+		return ui.printUsageByOwner(dir)
+	case ui.duplicates != nil:
+		return ui.printDuplicates(dir)
+	case ui.treeDepth > 0:
+		ui.printTree(dir, nil)
+	case ui.summarize:
 		ui.printTotalItem(dir)
-	} else {
+	default:
 		ui.showDir(dir)
 	}
 
@@ -401,17 +892,14 @@ func (ui *UI) updateProgress(updateStatsDone <-chan struct{}) {
 	}
 
 	progressChan := ui.Analyzer.GetProgressChan()
-	analysisDoneChan := ui.Analyzer.GetDone()
-
-	var progress common.CurrentProgress
 
 	i := 0
 	for {
 		fmt.Fprint(ui.output, emptyRow)
 
-		select {
-		case progress = <-progressChan:
-		case <-analysisDoneChan:
+		progress, ok := <-progressChan
+		if !ok {
+			// analysis is done
 			for {
 				fmt.Fprint(ui.output, emptyRow)
 				fmt.Fprintf(ui.output, "\r %s ", string(progressRunes[i]))
@@ -443,6 +931,15 @@ func (ui *UI) updateProgress(updateStatsDone <-chan struct{}) {
 	}
 }
 
+// formatItemSize formats size of the item, estimated sizes are prefixed by "~"
+// unless raw numbers are printed
+func (ui *UI) formatItemSize(file fs.Item, size int64) string {
+	if fs.IsEstimated(file) && !ui.noPrefix {
+		return "~" + ui.formatSize(size)
+	}
+	return ui.formatSize(size)
+}
+
 func (ui *UI) formatSize(size int64) string {
 	if ui.noPrefix {
 		return ui.orange.Sprintf("%d", size)
@@ -525,6 +1022,12 @@ func (ui *UI) printCacheStats(stats *analyze.CacheStats) {
 	fmt.Fprintln(ui.output)
 	fmt.Fprintln(ui.output, "Cache Statistics:")
 
+	// Cache lost on reboot, shown first as it makes every first scan after a reboot cold
+	if stats.VolatileStorage != "" {
+		fmt.Fprintf(ui.output, "  WARNING:          %s, the cache is lost on reboot (--allow-volatile-cache)\n",
+			stats.VolatileStorage)
+	}
+
 	// Calculate hit rate (already returns percentage)
 	hitRate := stats.HitRate()
 	fmt.Fprintf(ui.output, "  Hit Rate:         %.1f%% (%d hits, %d misses)\n",
@@ -537,15 +1040,169 @@ func (ui *UI) printCacheStats(stats *analyze.CacheStats) {
 	// Directory stats
 	fmt.Fprintf(ui.output, "  Directories:      %d total, %d rescanned\n",
 		stats.TotalDirs, stats.DirsRescanned)
+	if stats.SummaryHit {
+		fmt.Fprintln(ui.output, "  Summary Hit:      only the top directory was loaded (--trust-root-mtime)")
+	}
 
 	// Performance stats
 	if stats.TotalScanTime > 0 {
 		fmt.Fprintf(ui.output, "  Scan Time:        %v\n", stats.TotalScanTime)
 	}
+	if ui.priority != "" {
+		fmt.Fprintf(ui.output, "  Priority:         %s\n", ui.priority)
+	}
+
+	// Writers of the cache entries of this scan and of the previous one
+	fmt.Fprintf(ui.output, "  Written By:       %s\n", analyze.FormatProvenance(stats.Hostname, stats.AppVersion))
+	if stats.PreviousHostname != "" || stats.PreviousAppVersion != "" {
+		fmt.Fprintf(ui.output, "  Previous Scan By: %s\n",
+			analyze.FormatProvenance(stats.PreviousHostname, stats.PreviousAppVersion))
+	}
+	if stats.VersionMismatches > 0 {
+		fmt.Fprintf(ui.output, "  Other Versions:   %d entries written by another major version\n",
+			stats.VersionMismatches)
+	}
+	if stats.Storage.Reads.Count > 0 {
+		fmt.Fprintf(ui.output, "  Cache Reads:      %s (decode avg %v)\n",
+			stats.Storage.Reads, stats.Storage.Decodes.Avg())
+	}
+	if stats.Storage.Writes.Count > 0 {
+		fmt.Fprintf(ui.output, "  Cache Writes:     %s\n", stats.Storage.Writes)
+	}
+	if stats.PeakHeap > 0 {
+		fmt.Fprintf(ui.output, "  Peak Heap:        %s\n", ui.formatSize(int64(stats.PeakHeap)))
+	}
 
 	// Bytes stats
 	if stats.BytesScanned > 0 || stats.BytesFromCache > 0 {
 		fmt.Fprintf(ui.output, "  Bytes Scanned:    %s\n", ui.formatSize(stats.BytesScanned))
 		fmt.Fprintf(ui.output, "  Bytes From Cache: %s\n", ui.formatSize(stats.BytesFromCache))
 	}
+	if stats.ExcludedFiles > 0 {
+		fmt.Fprintf(ui.output, "  Excluded Files:   %d (%s)\n", stats.ExcludedFiles, ui.formatSize(stats.ExcludedBytes))
+	}
+
+	// Statistics by mount point if the scan spans several filesystems
+	if devices := stats.DeviceList(); len(devices) > 1 {
+		ui.printDeviceStats(devices)
+	}
+
+	// Invalid entries removed after a crashed scan
+	if stats.CorruptedEntries > 0 {
+		fmt.Fprintf(ui.output, "  Corrupted:        %d entries removed\n", stats.CorruptedEntries)
+	}
+
+	// Failed reads and writes of the cache
+	if stats.CacheErrors > 0 {
+		fmt.Fprintf(ui.output, "  Cache Errors:     %d failed reads or writes\n", stats.CacheErrors)
+	}
+
+	// Directories with timestamps in the future, which were scanned again
+	if stats.FutureTimestamps > 0 {
+		fmt.Fprintf(ui.output, "  Future Times:     %d directories scanned again\n", stats.FutureTimestamps)
+	}
+
+	// Entries cached later than now, the system clock was probably stepped backwards
+	if stats.CachedAhead > 0 {
+		fmt.Fprintf(ui.output, "  Cached Ahead:     %d entries written later than now\n", stats.CachedAhead)
+	}
+
+	// Directories with mtime moved backwards, restored from a backup or the clock was stepped
+	if stats.ClockSkewRescans > 0 {
+		fmt.Fprintf(ui.output, "  Clock Skew:       %d directories with mtime moved backwards scanned again\n",
+			stats.ClockSkewRescans)
+	}
+
+	// Directories removed while the scan was running
+	if stats.VanishedDuringScan > 0 {
+		fmt.Fprintf(ui.output, "  Vanished:         %d directories removed during scan\n", stats.VanishedDuringScan)
+	}
+
+	// Directories below the depth ceiling, whose content was not read
+	if stats.TooDeepDirs > 0 {
+		fmt.Fprintf(ui.output, "  Too Deep:         %d directories not read\n", stats.TooDeepDirs)
+	}
+
+	// Cache entries written for another directory with the same key
+	if stats.KeyCollisions > 0 {
+		fmt.Fprintf(ui.output, "  Key Collisions:   %d directories scanned again\n", stats.KeyCollisions)
+	}
+
+	// Entries listed twice by the filesystem, only the first one was read
+	if stats.DuplicateNamesSkipped > 0 {
+		fmt.Fprintf(ui.output, "  Duplicate Names:  %d entries listed twice skipped\n", stats.DuplicateNamesSkipped)
+	}
+
+	// Directories below the thresholds of IncrementalOptions.MinCacheDirSize and MinCacheChildren
+	if stats.SmallDirsNotCached > 0 {
+		fmt.Fprintf(ui.output, "  Small Dirs:       %d directories scanned without caching\n", stats.SmallDirsNotCached)
+	}
+
+	// Directories with changed children whose mtime did not change
+	if stats.HashRescans > 0 {
+		fmt.Fprintf(ui.output, "  Hash Rescans:     %d directories changed with the same mtime\n", stats.HashRescans)
+	}
+
+	// Directories listed by --validation-mode mtime+count
+	if stats.ValidationReads > 0 {
+		fmt.Fprintf(ui.output, "  Validation:       %d directories listed, %d changed with the same mtime\n",
+			stats.ValidationReads, stats.ValidationRescans)
+	}
+
+	// Reads repeated after transient errors of the filesystem
+	if stats.Retries > 0 {
+		fmt.Fprintf(ui.output, "  Retries:          %d reads repeated after transient errors\n", stats.Retries)
+	}
+
+	// Entries of other trees removed as older than the retention
+	if stats.PrunedEntries > 0 || stats.PrunedSummaries > 0 {
+		fmt.Fprintf(ui.output, "  Pruned:           %d entries and %d summaries older than the retention\n",
+			stats.PrunedEntries, stats.PrunedSummaries)
+	}
+
+	// Entries of directories removed from the filesystem, pruned after the scan
+	if stats.StaleEntries > 0 {
+		fmt.Fprintf(ui.output, "  Stale Entries:    %d entries of removed directories (%s)\n",
+			stats.StaleEntries, ui.formatSize(stats.StaleBytes))
+	}
+
+	// Entries removed before the scan as listed by --invalidate-from
+	if stats.InvalidatedPaths > 0 {
+		fmt.Fprintf(ui.output, "  Invalidated:      %d entries of %d listed directories\n",
+			stats.InvalidatedEntries, stats.InvalidatedPaths)
+	}
+
+	// Cache located in the scanned tree, which the scan itself changes
+	if stats.CacheDirInTree != "" {
+		if stats.CacheDirExcluded {
+			fmt.Fprintf(ui.output, "  Cache Directory:  %s left out of the scan\n", stats.CacheDirInTree)
+		} else {
+			fmt.Fprintf(ui.output, "  Cache Directory:  %s counted (--count-cache-dir)\n", stats.CacheDirInTree)
+		}
+	}
+	if stats.CacheDirUsage > 0 {
+		fmt.Fprintf(ui.output, "  Cache Itself:     %s of the totals\n", ui.formatSize(stats.CacheDirUsage))
+	}
+
+	// Directories which did not exist in the previous generation
+	if stats.NewDirsCount > 0 {
+		fmt.Fprintf(ui.output, "  New Directories:  %d\n", stats.NewDirsCount)
+		for _, path := range stats.NewDirs {
+			fmt.Fprintf(ui.output, "    %s\n", path)
+		}
+		if rest := stats.NewDirsDropped(); rest > 0 {
+			fmt.Fprintf(ui.output, "    ...and %s more\n", common.FormatNumber(rest))
+		}
+	}
+
+	// Directories of the previous generation which are gone
+	if stats.RemovedDirsCount > 0 {
+		fmt.Fprintf(ui.output, "  Removed Directories: %d\n", stats.RemovedDirsCount)
+		for _, path := range stats.RemovedDirs {
+			fmt.Fprintf(ui.output, "    %s\n", path)
+		}
+		if rest := stats.RemovedDirsDropped(); rest > 0 {
+			fmt.Fprintf(ui.output, "    ...and %s more\n", common.FormatNumber(rest))
+		}
+	}
 }
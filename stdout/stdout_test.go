@@ -2,16 +2,21 @@ package stdout
 
 import (
 	"bytes"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/dundee/gdu/v5/internal/testanalyze"
 	"github.com/dundee/gdu/v5/internal/testdev"
 	"github.com/dundee/gdu/v5/internal/testdir"
+	"github.com/dundee/gdu/v5/pkg/analyze"
 	"github.com/dundee/gdu/v5/pkg/device"
+	"github.com/dundee/gdu/v5/pkg/fs"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -106,6 +111,188 @@ func TestShowTopBw(t *testing.T) {
 	assert.Contains(t, output.String(), "test_dir/nested/file2")
 }
 
+func TestShowAgeHistogram(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	old := time.Now().Add(-3 * 365 * 24 * time.Hour)
+	err := os.Chtimes("test_dir/nested/subnested/file", old, old)
+	assert.Nil(t, err)
+
+	output := bytes.NewBuffer(make([]byte, 0, 10))
+
+	ui := CreateStdoutUI(output, false, false, true, false, false, false, false, false, 0, false, false)
+	ui.ShowAgeHistogram()
+	err = ui.AnalyzePath("test_dir", nil)
+	assert.Nil(t, err)
+
+	lines := strings.Split(output.String(), "\n")
+	assert.Contains(t, lines[0], "Age")
+	assert.Regexp(t, `^< 30 days +1 +2 B +28\.6%$`, lines[1])
+	assert.Regexp(t, `^2 years - 5 years +1 +5 B +71\.4%$`, lines[5])
+	assert.Regexp(t, `^> 5 years +0 +0 B +0\.0%$`, lines[6])
+}
+
+func TestShowOffenders(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	baseline := analyze.CreateSeqAnalyzer().AnalyzeDir(
+		"test_dir", func(_, _ string) bool { return false }, false,
+	)
+	baseline.UpdateStats(make(fs.HardLinkedItems))
+
+	err := os.Mkdir("test_dir/logs", 0o755)
+	assert.Nil(t, err)
+
+	output := bytes.NewBuffer(make([]byte, 0, 10))
+
+	ui := CreateStdoutUI(output, false, false, true, false, false, false, false, true, 0, false, false)
+	ui.ShowOffenders(analyze.OffenderOptions{SizeWeight: 1, MaxDepth: 1}, baseline, false)
+	err = ui.AnalyzePath("test_dir", nil)
+	assert.Nil(t, err)
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Regexp(t, `^ *Score +Size +Growth +Path$`, lines[0])
+	assert.Regexp(t, ` +\d+ +0  test_dir/nested$`, lines[1])
+	assert.Regexp(t, ` +\d+ +new  test_dir/logs$`, lines[2])
+}
+
+func TestShowDiff(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	opts := analyze.IncrementalOptions{StoragePath: t.TempDir(), Diff: true}
+	scan := func() []string {
+		output := bytes.NewBuffer(make([]byte, 0, 10))
+		ui := CreateStdoutUI(output, false, false, true, false, false, false, false, true, 0, false, false)
+		ui.SetAnalyzer(analyze.CreateIncrementalAnalyzer(opts))
+		ui.ShowDiff(1)
+		assert.Nil(t, ui.AnalyzePath("test_dir", nil))
+		return strings.Split(strings.TrimSpace(output.String()), "\n")
+	}
+
+	cold := scan()
+	assert.Equal(t, []string{
+		"Growth    Before       Now  Path",
+		"0 added, 0 removed, 0 changed directories since the previous scan",
+	}, cold)
+
+	assert.Nil(t, os.MkdirAll("test_dir/logs/old", 0o755))
+	assert.Nil(t, os.WriteFile("test_dir/logs/old/log", make([]byte, 10000), 0o600))
+	warm := scan()
+	assert.Len(t, warm, 3)
+	assert.Regexp(t, `^ +\+\d+ +\d+ +\d+  /.*/test_dir$`, warm[1])
+	assert.Equal(t, "1 added, 0 removed, 1 changed directories since the previous scan", warm[2])
+}
+
+func TestShowBrokenSymlinks(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	assert.Nil(t, os.Symlink("file2", "test_dir/nested/valid"))
+	assert.Nil(t, os.Symlink("missing", "test_dir/nested/dangling"))
+
+	output := bytes.NewBuffer(make([]byte, 0, 10))
+
+	ui := CreateStdoutUI(output, false, false, false, false, false, false, false, false, 0, false, false)
+	ui.SetAnalyzer(analyze.CreateIncrementalAnalyzer(analyze.IncrementalOptions{StoragePath: t.TempDir()}))
+	ui.SetFollowSymlinks(true)
+	ui.ShowBrokenSymlinks()
+	err := ui.AnalyzePath("test_dir", nil)
+	assert.Nil(t, err)
+
+	// the incremental analyzer scans the absolute path
+	dangling, err := filepath.Abs("test_dir/nested/dangling")
+	assert.Nil(t, err)
+	assert.Equal(t, dangling+"\n", output.String())
+}
+
+func TestShowEmptyDirs(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	assert.Nil(t, os.MkdirAll("test_dir/empty/inner", 0o755))
+	assert.Nil(t, os.Mkdir("test_dir/nested/leftover", 0o755))
+
+	output := bytes.NewBuffer(make([]byte, 0, 10))
+	ui := CreateStdoutUI(output, false, false, false, false, false, false, false, false, 0, false, false)
+	ui.ShowEmptyDirs(false, false)
+	assert.Nil(t, ui.AnalyzePath("test_dir", nil))
+	assert.Equal(t, "test_dir/empty/inner\ntest_dir/nested/leftover\n2 empty directories\n", output.String())
+
+	output.Reset()
+	ui.Analyzer.ResetProgress()
+	ui.ShowEmptyDirs(true, false)
+	assert.Nil(t, ui.AnalyzePath("test_dir", nil))
+	assert.Equal(t, "test_dir/empty (1 nested)\ntest_dir/nested/leftover\n3 empty directories\n", output.String())
+
+	output.Reset()
+	ui.Analyzer.ResetProgress()
+	ui.ShowEmptyDirs(true, true)
+	assert.Nil(t, ui.AnalyzePath("test_dir", nil))
+	assert.Contains(t, output.String(), "rmdir -- 'test_dir/empty/inner'\nrmdir -- 'test_dir/empty'\n")
+}
+
+func TestAnalyzeSingleFile(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	output := bytes.NewBuffer(make([]byte, 0, 10))
+
+	ui := CreateStdoutUI(output, false, false, false, false, false, false, false, false, 0, false, false)
+	notes := bytes.NewBuffer(make([]byte, 0, 10))
+	ui.errOutput = notes
+	ui.SetAnalyzer(analyze.CreateIncrementalAnalyzer(analyze.IncrementalOptions{StoragePath: t.TempDir()}))
+	err := ui.AnalyzePath("test_dir/nested/file2", nil)
+	assert.Nil(t, err)
+
+	assert.Contains(t, notes.String(), "Note: test_dir/nested/file2 is not a directory, showing just the file\n")
+	assert.Contains(t, output.String(), "file2")
+}
+
+func TestAnalyzeIgnoredRoot(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	output := bytes.NewBuffer(make([]byte, 0, 10))
+
+	ui := CreateStdoutUI(output, false, false, false, false, false, false, false, false, 0, false, false)
+	notes := bytes.NewBuffer(make([]byte, 0, 10))
+	ui.errOutput = notes
+	ui.SetAnalyzer(analyze.CreateIncrementalAnalyzer(analyze.IncrementalOptions{StoragePath: t.TempDir()}))
+	assert.Nil(t, ui.SetIgnoreDirPatterns([]string{"test_dir"}))
+	err := ui.AnalyzePath("test_dir", nil)
+	assert.Nil(t, err)
+
+	assert.Contains(t, notes.String(), "Note: test_dir matches the ignore patterns, its content was not read\n")
+	assert.NotContains(t, output.String(), "nested")
+	assert.NotContains(t, output.String(), "Note:", "the report stays clean, e.g. for JSON")
+}
+
+func TestShowEstimatedSize(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, os.WriteFile(fmt.Sprintf("test_dir/nested/extra%d", i), []byte("x"), 0o600))
+	}
+
+	output := bytes.NewBuffer(make([]byte, 0, 10))
+
+	ui := CreateStdoutUI(output, false, false, false, false, false, false, false, false, 0, false, false)
+	ui.SetAnalyzer(analyze.CreateIncrementalAnalyzer(analyze.IncrementalOptions{
+		StoragePath:     t.TempDir(),
+		SampleThreshold: 2,
+		SampleSize:      1,
+	}))
+	err := ui.AnalyzePath("test_dir", nil)
+	assert.Nil(t, err)
+
+	assert.Regexp(t, `~ +~\d.* /nested\n`, output.String())
+}
+
 func TestAnalyzeSubdir(t *testing.T) {
 	fin := testdir.CreateTestDir()
 	defer fin()
@@ -426,3 +613,83 @@ func TestReverseSortFromAnalysisFile(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Contains(t, output.String(), "main.go")
 }
+
+func TestPrintCacheStatsByDevice(t *testing.T) {
+	output := bytes.NewBuffer(make([]byte, 0, 10))
+	ui := CreateStdoutUI(output, false, false, false, false, false, false, false, true, 0, false, false)
+
+	stats := analyze.NewCacheStats()
+	stats.AddDeviceStats(analyze.DeviceStats{
+		Device: 1, MountPoint: "/", Dirs: 10, CacheHits: 9, Scanned: 1, BytesScanned: 100, ScanTime: time.Millisecond,
+	})
+	stats.AddDeviceStats(analyze.DeviceStats{
+		Device: 2, MountPoint: "/mnt/nfs", Dirs: 4, Scanned: 4, BytesScanned: 2048, ScanTime: 3 * time.Second,
+	})
+	ui.printCacheStats(stats)
+
+	assert.Contains(t, output.String(), "Mount Points:")
+	assert.Regexp(t, `/ +10 +1 +90.0% +1ms  100\n`, output.String())
+	assert.Regexp(t, `/mnt/nfs +4 +4 +0.0% +3s  2048\n`, output.String())
+
+	// single device is not listed
+	output.Reset()
+	single := analyze.NewCacheStats()
+	single.AddDeviceStats(analyze.DeviceStats{Device: 1, MountPoint: "/", Dirs: 1})
+	ui.printCacheStats(single)
+	assert.NotContains(t, output.String(), "Mount Points:")
+}
+
+func TestPrintCacheStatsPriority(t *testing.T) {
+	output := bytes.NewBuffer(make([]byte, 0, 10))
+	ui := CreateStdoutUI(output, false, false, false, false, false, false, false, false, 0, false, false)
+
+	stats := analyze.NewCacheStats()
+	stats.TotalScanTime = time.Second
+	ui.printCacheStats(stats)
+	assert.NotContains(t, output.String(), "Priority:")
+
+	output.Reset()
+	ui.SetPriority("nice 10, SCHED_IDLE")
+	ui.printCacheStats(stats)
+	assert.Contains(t, output.String(), "  Scan Time:        1s\n  Priority:         nice 10, SCHED_IDLE\n")
+}
+
+func TestPrintCacheStatsDroppedPaths(t *testing.T) {
+	output := bytes.NewBuffer(make([]byte, 0, 10))
+	ui := CreateStdoutUI(output, false, false, false, false, false, false, false, false, 0, false, false)
+
+	stats := analyze.NewCacheStats()
+	for i := 0; i < analyze.DefaultMaxReportedPaths+1500; i++ {
+		stats.AddNewDir(fmt.Sprintf("/new/%d", i))
+	}
+	ui.printCacheStats(stats)
+
+	assert.Contains(t, output.String(), "  New Directories:  2500\n")
+	assert.Contains(t, output.String(), "    /new/999\n    ...and 1,500 more\n")
+	assert.NotContains(t, output.String(), "/new/1000\n")
+}
+
+func TestPrintCacheStatsVolatileStorage(t *testing.T) {
+	output := bytes.NewBuffer(make([]byte, 0, 10))
+	ui := CreateStdoutUI(output, false, false, false, false, false, false, false, false, 0, false, false)
+
+	stats := analyze.NewCacheStats()
+	stats.SetVolatileStorage("/tmp/gdu is on tmpfs")
+	ui.printCacheStats(stats)
+
+	assert.Contains(t, output.String(), "Cache Statistics:\n"+
+		"  WARNING:          /tmp/gdu is on tmpfs, the cache is lost on reboot (--allow-volatile-cache)\n")
+}
+
+func TestPrintCacheStatsCacheDirUsage(t *testing.T) {
+	output := bytes.NewBuffer(make([]byte, 0, 10))
+	ui := CreateStdoutUI(output, false, false, false, false, false, false, false, false, 0, false, false)
+
+	stats := analyze.NewCacheStats()
+	stats.SetCacheDirInTree("/data/.cache/gdu", false)
+	stats.SetCacheDirUsage(812 * 1024 * 1024)
+	ui.printCacheStats(stats)
+
+	assert.Contains(t, output.String(), "  Cache Directory:  /data/.cache/gdu counted (--count-cache-dir)\n"+
+		"  Cache Itself:     812.0 MiB of the totals\n")
+}
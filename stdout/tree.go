@@ -0,0 +1,170 @@
+package stdout
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// treeMark tells how the incremental analyzer got the content of a directory of the tree
+type treeMark byte
+
+const (
+	markCached  treeMark = '=' // loaded from the cache
+	markScanned treeMark = '*' // scanned, but not known to be changed (e.g. expired cache entry)
+	markChanged treeMark = '~' // scanned because it changed since the previous generation
+	markNew     treeMark = '+' // not present in the previous generation
+)
+
+// treeMarkLegend explains the marks, it is printed below the tree
+const treeMarkLegend = "= cached, * scanned, ~ changed, + new"
+
+// treeRunes are the prefixes of the tree lines: branch, last branch, continuation and blank
+var (
+	treeRunes      = [4]string{"├── ", "└── ", "│   ", "    "}
+	treeRunesASCII = [4]string{"|-- ", "`-- ", "|   ", "    "}
+)
+
+// ShowTree prints the directory tree down to the given depth instead of the directory listing.
+// Names are shortened to fit into width columns (0 = not shortened, e.g. when the output is piped)
+func (ui *UI) ShowTree(depth, width int) {
+	ui.treeDepth = depth
+	ui.treeWidth = width
+}
+
+// treeMarks returns marks of the directories of the last incremental scan by their path,
+// nil if the analyzer is not incremental or the decisions were not recorded
+func (ui *UI) treeMarks() map[string]treeMark {
+	incrementalAnalyzer, ok := ui.Analyzer.(*analyze.IncrementalAnalyzer)
+	if !ok || incrementalAnalyzer.GetDecisions() == nil {
+		return nil
+	}
+
+	decisions := incrementalAnalyzer.GetDecisions()
+	marks := make(map[string]treeMark, decisions.Len())
+	decisions.Range(func(path string, decision analyze.CacheDecision, isNew bool) {
+		switch {
+		case isNew:
+			marks[path] = markNew
+		case decision == analyze.DecisionHit, decision == analyze.DecisionVerified,
+			decision == analyze.DecisionSummary, decision == analyze.DecisionTrusted:
+			marks[path] = markCached
+		case decision == analyze.DecisionChanged, decision == analyze.DecisionContent,
+			decision == analyze.DecisionListing:
+			marks[path] = markChanged
+		default:
+			marks[path] = markScanned
+		}
+	})
+	return marks
+}
+
+// printTree prints the item and its children down to the tree depth, lines are written as the tree is walked.
+// Directories are marked by marks (nil = no marks), the ones missing there take the mark
+// of their parent if it was loaded from the cache
+func (ui *UI) printTree(dir fs.Item, marks map[string]treeMark) {
+	runes := treeRunes
+	if ui.asciiTree {
+		runes = treeRunesASCII
+	}
+
+	var walk func(item fs.Item, indent, branch string, parentMark treeMark, depth int)
+	walk = func(item fs.Item, indent, branch string, parentMark treeMark, depth int) {
+		mark := marks[item.GetPath()]
+		if mark == 0 && parentMark == markCached {
+			mark = markCached
+		}
+		ui.printTreeLine(item, indent+branch, mark, marks != nil)
+
+		if !item.IsDir() || depth >= ui.treeDepth {
+			return
+		}
+
+		files := item.GetFiles()
+		if ui.reverseSort {
+			sort.Sort(files)
+		} else {
+			sort.Sort(sort.Reverse(files))
+		}
+
+		// the children continue the branch of this item
+		switch branch {
+		case runes[0]:
+			indent += runes[2]
+		case runes[1]:
+			indent += runes[3]
+		}
+		for i, file := range files {
+			if i == len(files)-1 {
+				walk(file, indent, runes[1], mark, depth+1)
+			} else {
+				walk(file, indent, runes[0], mark, depth+1)
+			}
+		}
+	}
+	walk(dir, "", "", 0, 0)
+
+	if marks != nil {
+		fmt.Fprintf(ui.output, "\nMarks: %s\n", treeMarkLegend)
+	}
+}
+
+// printTreeLine prints one line of the tree, directories are marked and colored by their mark
+func (ui *UI) printTreeLine(item fs.Item, prefix string, mark treeMark, showMarks bool) {
+	var size int64
+	if ui.ShowApparentSize {
+		size = item.GetSize()
+	} else {
+		size = item.GetUsage()
+	}
+
+	var sizeFormat string
+	if ui.UseColors {
+		sizeFormat = "%20s"
+	} else {
+		sizeFormat = "%9s"
+	}
+
+	columns := string(item.GetFlag())
+	if showMarks {
+		if mark == 0 || !item.IsDir() {
+			mark = ' '
+		}
+		columns += string(mark)
+	}
+
+	// visible width of the columns before the name (the size column has 9 characters)
+	used := len(columns) + 11 + utf8.RuneCountInString(prefix)
+
+	name := item.GetName()
+	if item.IsDir() && prefix != "" {
+		name += "/"
+	}
+	name = shortenName(name, ui.treeWidth-used)
+
+	if item.IsDir() {
+		switch mark {
+		case markNew:
+			name = ui.green.Sprint(name)
+		case markChanged:
+			name = ui.red.Sprint(name)
+		default:
+			name = ui.blue.Sprint(name)
+		}
+	}
+
+	fmt.Fprintf(ui.output, "%s "+sizeFormat+" %s%s\n", columns, ui.formatItemSize(item, size), prefix, name)
+}
+
+// shortenName shortens the name to at most width runes, the end is replaced by "…".
+// Width below 1 leaves the name as it is
+func shortenName(name string, width int) string {
+	if width < 1 || utf8.RuneCountInString(name) <= width {
+		return name
+	}
+	runes := []rune(name)
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,132 @@
+package stdout
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dundee/gdu/v5/internal/testdir"
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+// treeFixture returns a tree with all kinds of marks:
+//
+//	/srv/fixture         scanned
+//	  docs/              cached
+//	    notes/           (inherits cached)
+//	  src/               changed
+//	    vendor/          new
+//	      lib/           scanned
+func treeFixture() (*analyze.Dir, map[string]treeMark) {
+	root := &analyze.Dir{File: &analyze.File{Name: "fixture", Flag: ' '}, BasePath: "/srv"}
+	dir := func(parent *analyze.Dir, name string) *analyze.Dir {
+		d := &analyze.Dir{File: &analyze.File{Name: name, Flag: ' ', Parent: parent}}
+		parent.AddFile(d)
+		return d
+	}
+	file := func(parent *analyze.Dir, name string, usage int64) {
+		parent.AddFile(&analyze.File{Name: name, Flag: ' ', Size: usage, Usage: usage, Parent: parent})
+	}
+
+	docs := dir(root, "docs")
+	file(docs, "report.pdf", 40960)
+	notes := dir(docs, "notes")
+	file(notes, "todo.txt", 4096)
+	src := dir(root, "src")
+	file(src, "main.go", 8192)
+	vendor := dir(src, "vendor")
+	lib := dir(vendor, "lib")
+	file(lib, "lib.go", 12288)
+	file(root, "README", 512)
+	root.UpdateStats(make(fs.HardLinkedItems))
+
+	marks := map[string]treeMark{
+		"/srv/fixture":                markScanned,
+		"/srv/fixture/docs":           markCached,
+		"/srv/fixture/src":            markChanged,
+		"/srv/fixture/src/vendor":     markNew,
+		"/srv/fixture/src/vendor/lib": markScanned,
+	}
+	return root, marks
+}
+
+func TestPrintTreeGolden(t *testing.T) {
+	noColor := color.NoColor
+	defer func() { color.NoColor = noColor }()
+
+	for _, useColors := range []bool{false, true} {
+		for depth := 1; depth <= 3; depth++ {
+			name := fmt.Sprintf("tree_depth%d", depth)
+			if useColors {
+				name += "_color"
+			}
+			t.Run(name, func(t *testing.T) {
+				output := bytes.NewBuffer(make([]byte, 0, 10))
+				ui := CreateStdoutUI(output, useColors, false, false, false, false, false, false, false, 0, false, false)
+				color.NoColor = !useColors
+				ui.ShowTree(depth, 0)
+
+				dir, marks := treeFixture()
+				ui.printTree(dir, marks)
+
+				golden := filepath.Join("testdata", name+".golden")
+				if *updateGolden {
+					assert.NoError(t, os.WriteFile(golden, output.Bytes(), 0o600))
+				}
+				expected, err := os.ReadFile(golden)
+				assert.NoError(t, err)
+				assert.Equal(t, string(expected), output.String())
+			})
+		}
+	}
+}
+
+func TestPrintTreeWidth(t *testing.T) {
+	output := bytes.NewBuffer(make([]byte, 0, 10))
+	ui := CreateStdoutUI(output, false, false, false, false, false, false, false, false, 0, false, false)
+	ui.UseOldProgressRunes()
+	ui.ShowTree(2, 24)
+
+	dir, _ := treeFixture()
+	ui.printTree(dir, nil)
+
+	assert.Contains(t, output.String(), "   40.0 KiB |   |-- rep…\n")
+	assert.Contains(t, output.String(), "    8.0 KiB |   `-- mai…\n")
+	assert.Contains(t, output.String(), "   52.0 KiB |-- docs/\n")
+	assert.NotContains(t, output.String(), "Marks:")
+}
+
+func TestShowTreeIncremental(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	opts := analyze.IncrementalOptions{StoragePath: t.TempDir(), RecordDecisions: true}
+	scan := func() string {
+		output := bytes.NewBuffer(make([]byte, 0, 10))
+		ui := CreateStdoutUI(output, false, false, false, false, false, false, false, false, 0, false, false)
+		ui.SetAnalyzer(analyze.CreateIncrementalAnalyzer(opts))
+		ui.ShowTree(3, 0)
+		assert.NoError(t, ui.AnalyzePath("test_dir", nil))
+		return output.String()
+	}
+
+	cold := scan()
+	assert.Contains(t, cold, " *  16.0 KiB └── nested/\n")
+	assert.Contains(t, cold, "    4.0 KiB     └── file2\n")
+	assert.Contains(t, cold, "Marks: "+treeMarkLegend+"\n")
+
+	assert.NoError(t, os.Mkdir(filepath.Join("test_dir", "added"), 0o755))
+	warm := scan()
+	assert.Regexp(t, `^ ~ +[\d.]+ KiB test_dir\n`, warm)
+	assert.Contains(t, warm, " =  16.0 KiB ├── nested/\n")
+	assert.Contains(t, warm, " =   8.0 KiB │   ├── subnested/\n")
+	assert.Contains(t, warm, "e+   4.0 KiB └── added/\n") // flag of the empty directory
+}
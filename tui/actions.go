@@ -2,6 +2,7 @@ package tui
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -71,31 +72,56 @@ func (ui *UI) AnalyzePath(path string, parentDir fs.Item) error {
 	go func() {
 		defer debug.FreeOSMemory()
 		currentDir := ui.Analyzer.AnalyzeDir(path, ui.CreateIgnoreFunc(), ui.ConstGC)
+		scannedAt := time.Now()
+		if incrementalAnalyzer, ok := ui.Analyzer.(*analyze.IncrementalAnalyzer); ok {
+			ui.scanResult = incrementalAnalyzer.GetScanResult()
+		}
 
 		if parentDir != nil {
 			// Check if parentDir is a ParentDir marker - if so, we can't call methods on it
 			if _, isParentDirMarker := parentDir.(*analyze.ParentDir); isParentDirMarker {
 				// ParentDir is just a marker, we can't use it as a real parent
 				// Treat this as a new top directory
-				ui.topDirPath = path
+				ui.topDirPath = currentDir.GetPath()
 				ui.topDir = currentDir
+				ui.rootStack = nil
 			} else {
 				// Real parent directory - link them together
 				currentDir.SetParent(parentDir)
 				parentDir.SetFiles(parentDir.GetFiles().RemoveByName(currentDir.GetName()))
 				parentDir.AddFile(currentDir)
+				if path == ui.topDirPath {
+					// rescanned root set by re-rooting
+					ui.topDir = currentDir
+				}
 			}
 		} else {
-			ui.topDirPath = path
+			// the path as the analyzer resolved it, the same as currentDirPath set by showDir
+			// (the incremental analyzer makes it absolute, a file is shown in its parent directory)
+			ui.topDirPath = currentDir.GetPath()
 			ui.topDir = currentDir
+			ui.rootStack = nil
 		}
 
-		ui.topDir.UpdateStats(ui.linkedItems)
+		root := ui.topDir
+		if len(ui.rootStack) > 0 {
+			// totals of the roots left by re-rooting include the rescanned subtree too
+			root = ui.rootStack[0].topDir
+		}
+		root.UpdateStats(ui.linkedItems)
 
 		ui.app.QueueUpdateDraw(func() {
 			ui.currentDir = currentDir
+			ui.scannedAt = scannedAt
+			ui.inPrevious = false
 			ui.showDir()
 			ui.pages.RemovePage("progress")
+			if ui.scanResult != nil && ui.scanResult.Status == analyze.ScanFailed && ui.scanResult.Err != nil {
+				// the analyzer only logs the cause, the tree alone shows just the '!' flag
+				ui.showErr("Scan failed", ui.scanResult.Err)
+			} else if ui.startAt != "" && parentDir == nil {
+				ui.openStartAt()
+			}
 		})
 
 		if ui.done != nil {
@@ -140,6 +166,7 @@ func (ui *UI) ReadAnalysis(input io.Reader) error {
 
 		ui.topDirPath = ui.currentDir.GetPath()
 		ui.topDir = ui.currentDir
+		ui.rootStack = nil
 
 		links := make(fs.HardLinkedItems, 10)
 		ui.topDir.UpdateStats(links)
@@ -171,6 +198,7 @@ func (ui *UI) ReadFromStorage(storagePath, path string) error {
 	ui.currentDir = dir
 	ui.topDirPath = ui.currentDir.GetPath()
 	ui.topDir = ui.currentDir
+	ui.rootStack = nil
 
 	ui.showDir()
 	return nil
@@ -201,13 +229,13 @@ func (ui *UI) deleteSelected(shouldEmpty bool) {
 		action = actionDelete
 		acting = actingDelete
 	}
-	modal := tview.NewModal().SetText(
-		cases.Title(language.English).String(acting) +
-			" " +
-			tview.Escape(selectedItem.GetName()) +
-			"...",
-	)
+	text := cases.Title(language.English).String(acting) +
+		" " +
+		tview.Escape(selectedItem.GetName()) +
+		"..."
+	modal := tview.NewModal().SetText(text)
 	ui.pages.AddPage(acting, modal, true, true)
+	stopWatching := ui.watchDeletion(modal, text)
 
 	var currentDir fs.Item
 	var deleteItems []fs.Item
@@ -228,8 +256,14 @@ func (ui *UI) deleteSelected(shouldEmpty bool) {
 		deleteFun = ui.remover
 	}
 	go func() {
+		defer stopWatching()
+		var deletedItems []fs.Item
 		for _, item := range deleteItems {
-			if err := deleteFun(currentDir, item); err != nil {
+			err := deleteFun(currentDir, item)
+			if isCancelled(err) {
+				break
+			}
+			if err != nil {
 				msg := "Can't " + action + " " + tview.Escape(selectedItem.GetName())
 				ui.app.QueueUpdateDraw(func() {
 					ui.pages.RemovePage(acting)
@@ -240,6 +274,7 @@ func (ui *UI) deleteSelected(shouldEmpty bool) {
 				}
 				return
 			}
+			deletedItems = append(deletedItems, item)
 		}
 
 		ui.app.QueueUpdateDraw(func() {
@@ -248,6 +283,7 @@ func (ui *UI) deleteSelected(shouldEmpty bool) {
 			ui.showDir()
 			ui.table.Select(min(row, ui.table.GetRowCount()-1), 0)
 			ui.table.SetOffset(min(x, ui.table.GetRowCount()-1), y)
+			ui.offerDropAnnotations(deletedItems)
 		})
 
 		if ui.done != nil {
@@ -296,6 +332,71 @@ func (ui *UI) showInfo() {
 	content += numberColor + ui.formatSize(selectedFile.GetSize(), false, true)
 	content += fmt.Sprintf(" (%s%d[-::] B)", numberColor, selectedFile.GetSize()) + "\n"
 
+	if diff := selectedFile.GetUsage() - selectedFile.GetSize(); diff != 0 {
+		linesCount++
+		sign := "+"
+		if diff < 0 {
+			sign = "-"
+			diff = -diff
+		}
+		content += "   [::b]Difference:[::-] "
+		content += numberColor + sign + ui.formatSize(diff, false, true)
+		content += fmt.Sprintf(" (%s%s%d[-::] B)", numberColor, sign, diff) + "\n"
+	}
+
+	if dup := getDuplicateOf(selectedFile); dup != "" {
+		linesCount++
+		content += "      [::b]Same as:[::-] " + tview.Escape(dup) + "\n"
+	}
+
+	linesCount++
+	content += "      [::b]Created:[::-] "
+	content += numberColor + formatBtime(selectedFile) + "[-::]\n"
+
+	if dir, ok := selectedFile.(interface{ GetErrorCount() int }); ok && dir.GetErrorCount() > 0 {
+		linesCount++
+		content += "  [::b]Read errors:[::-] "
+		content += fmt.Sprintf("%s%d[-::]", numberColor, dir.GetErrorCount()) + "\n"
+	}
+
+	if dir, ok := selectedFile.(interface {
+		GetSymlinkCount() int
+		GetBrokenSymlinkCount() int
+	}); ok && dir.GetSymlinkCount() > 0 {
+		linesCount++
+		content += "     [::b]Symlinks:[::-] "
+		content += fmt.Sprintf("%s%d[-::]", numberColor, dir.GetSymlinkCount())
+		if dir.GetBrokenSymlinkCount() > 0 {
+			content += fmt.Sprintf(" (%s%d[-::] broken)", numberColor, dir.GetBrokenSymlinkCount())
+		}
+		content += "\n"
+	}
+
+	if dir, ok := selectedFile.(interface{ GetEstimate() *analyze.Estimate }); ok && dir.GetEstimate() != nil {
+		est := dir.GetEstimate()
+		linesCount++
+		content += "    [::b]Estimated:[::-] "
+		content += fmt.Sprintf(
+			"%s%d[-::] of %s%d[-::] files read (±%s%.1f[-::]%%)",
+			numberColor, est.Sampled, numberColor, est.Sampled+est.Skipped, numberColor, est.RelErr*100,
+		) + "\n"
+	} else if fs.IsEstimated(selectedFile) {
+		linesCount++
+		content += "    [::b]Estimated:[::-] some subdirectories\n"
+	}
+
+	if dir, ok := selectedFile.(interface{ GetHardlinksSaved() int64 }); ok && dir.GetHardlinksSaved() > 0 {
+		linesCount++
+		content += "  [::b]Links saved:[::-] "
+		content += numberColor + ui.formatSize(dir.GetHardlinksSaved(), false, true)
+		content += fmt.Sprintf(" (%s%d[-::] B not counted twice)", numberColor, dir.GetHardlinksSaved()) + "\n"
+	}
+
+	if note := getAnnotation(selectedFile); note != "" {
+		linesCount++
+		content += "         [::b]Note:[::-] " + tview.Escape(note) + "\n"
+	}
+
 	if selectedFile.GetMultiLinkedInode() > 0 {
 		linkedItems := ui.linkedItems[selectedFile.GetMultiLinkedInode()]
 		linesCount += 2 + len(linkedItems)
@@ -323,7 +424,7 @@ func (ui *UI) showCacheStats() {
 	incrementalAnalyzer, ok := ui.Analyzer.(*analyze.IncrementalAnalyzer)
 	if !ok {
 		// Not using incremental mode, show message
-		text := tview.NewTextView().SetDynamicColors(true)
+		text := tview.NewTextView().SetDynamicColors(true).SetWordWrap(true)
 		text.SetBorder(true).SetBorderPadding(2, 2, 2, 2)
 		text.SetBorderColor(tcell.ColorDefault)
 		text.SetTitle(" Cache Statistics ")
@@ -334,7 +435,7 @@ func (ui *UI) showCacheStats() {
 			AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
 				AddItem(nil, 0, 1, false).
 				AddItem(text, 10, 1, false).
-				AddItem(nil, 0, 1, false), 80, 1, false).
+				AddItem(nil, 0, 1, false), ui.modalWidth(statsModalWidth), 1, false).
 			AddItem(nil, 0, 1, false)
 
 		ui.pages.AddPage("cache-stats", flex, true, true)
@@ -356,12 +457,17 @@ func (ui *UI) showCacheStats() {
 		numberColor = defaultColorBold
 	}
 
-	text := tview.NewTextView().SetDynamicColors(true)
+	// long lines (e.g. storage metrics) wrap on narrow terminals
+	text := tview.NewTextView().SetDynamicColors(true).SetWordWrap(true)
 	text.SetBorder(true).SetBorderPadding(2, 2, 2, 2)
 	text.SetBorderColor(tcell.ColorDefault)
 	text.SetTitle(" Cache Statistics ")
 
 	// Build content
+	if stats.VolatileStorage != "" {
+		content += "[yellow::b]Warning:[-::-] " + tview.Escape(stats.VolatileStorage) +
+			", the cache is lost on reboot\n\n"
+	}
 	content += "[::b]Cache Performance:[::-]\n\n"
 
 	// Hit rate
@@ -381,10 +487,96 @@ func (ui *UI) showCacheStats() {
 	content += fmt.Sprintf("%d[-::]\n", stats.TotalDirs)
 	content += "  [::b]Directories Rescanned:[::-] " + numberColor
 	content += fmt.Sprintf("%d[-::]\n", stats.DirsRescanned)
+	if stats.SummaryHit {
+		content += "        [::b]Summary Hit:[::-] only the top directory was loaded\n"
+	}
 	if stats.CacheExpired > 0 {
 		content += "      [::b]Cache Expired:[::-] " + numberColor
 		content += fmt.Sprintf("%d[-::]\n", stats.CacheExpired)
 	}
+	if stats.DuplicateDirsSkipped > 0 {
+		content += " [::b]Duplicates Skipped:[::-] " + numberColor
+		content += fmt.Sprintf("%d[-::]\n", stats.DuplicateDirsSkipped)
+	}
+	if stats.CorruptedEntries > 0 {
+		content += "  [::b]Corrupted Entries:[::-] " + numberColor
+		content += fmt.Sprintf("%d[-::]\n", stats.CorruptedEntries)
+	}
+	if stats.CacheErrors > 0 {
+		content += "       [::b]Cache Errors:[::-] " + numberColor
+		content += fmt.Sprintf("%d[-::]\n", stats.CacheErrors)
+	}
+	if stats.FutureTimestamps > 0 {
+		content += "  [::b]Future Timestamps:[::-] " + numberColor
+		content += fmt.Sprintf("%d[-::]\n", stats.FutureTimestamps)
+	}
+	if stats.CachedAhead > 0 {
+		content += "       [::b]Cached Ahead:[::-] " + numberColor
+		content += fmt.Sprintf("%d[-::]\n", stats.CachedAhead)
+	}
+	if stats.ClockSkewRescans > 0 {
+		content += " [::b]Clock Skew Rescans:[::-] " + numberColor
+		content += fmt.Sprintf("%d[-::]\n", stats.ClockSkewRescans)
+	}
+	if stats.VanishedDuringScan > 0 {
+		content += " [::b]Vanished During Scan:[::-] " + numberColor
+		content += fmt.Sprintf("%d[-::]\n", stats.VanishedDuringScan)
+	}
+	if stats.TooDeepDirs > 0 {
+		content += "      [::b]Too Deep Dirs:[::-] " + numberColor
+		content += fmt.Sprintf("%d[-::]\n", stats.TooDeepDirs)
+	}
+	if stats.KeyCollisions > 0 {
+		content += "     [::b]Key Collisions:[::-] " + numberColor
+		content += fmt.Sprintf("%d[-::]\n", stats.KeyCollisions)
+	}
+	if stats.DuplicateNamesSkipped > 0 {
+		content += "    [::b]Duplicate Names:[::-] " + numberColor
+		content += fmt.Sprintf("%d[-::]\n", stats.DuplicateNamesSkipped)
+	}
+	if stats.SmallDirsNotCached > 0 {
+		content += "         [::b]Small Dirs:[::-] " + numberColor
+		content += fmt.Sprintf("%d[-::]\n", stats.SmallDirsNotCached)
+	}
+	if stats.HashRescans > 0 {
+		content += "       [::b]Hash Rescans:[::-] " + numberColor
+		content += fmt.Sprintf("%d[-::]\n", stats.HashRescans)
+	}
+	if stats.ValidationReads > 0 {
+		content += "         [::b]Validation:[::-] " + numberColor
+		content += fmt.Sprintf("%d[-::] listings, %s%d[-::] rescans\n",
+			stats.ValidationReads, numberColor, stats.ValidationRescans)
+	}
+	if stats.Retries > 0 {
+		content += "            [::b]Retries:[::-] " + numberColor
+		content += fmt.Sprintf("%d[-::]\n", stats.Retries)
+	}
+	if stats.PrunedEntries > 0 || stats.PrunedSummaries > 0 {
+		content += "             [::b]Pruned:[::-] " + numberColor
+		content += fmt.Sprintf("%d[-::] entries, %s%d[-::] summaries\n",
+			stats.PrunedEntries, numberColor, stats.PrunedSummaries)
+	}
+	if stats.StaleEntries > 0 {
+		content += "      [::b]Stale Entries:[::-] " + numberColor
+		content += fmt.Sprintf("%d (%s)[-::]\n", stats.StaleEntries, ui.formatSize(stats.StaleBytes, false, true))
+	}
+	if stats.InvalidatedPaths > 0 {
+		content += "        [::b]Invalidated:[::-] " + numberColor
+		content += fmt.Sprintf("%d[-::] entries of %s%d[-::] directories\n",
+			stats.InvalidatedEntries, numberColor, stats.InvalidatedPaths)
+	}
+	if stats.CacheDirInTree != "" {
+		content += "    [::b]Cache Directory:[::-] " + tview.Escape(stats.CacheDirInTree)
+		if stats.CacheDirExcluded {
+			content += " left out of the scan\n"
+		} else {
+			content += " counted\n"
+		}
+	}
+	if stats.CacheDirUsage > 0 {
+		content += "       [::b]Cache Itself:[::-] " + numberColor
+		content += ui.formatSize(stats.CacheDirUsage, false, true) + "[-::] of the totals\n"
+	}
 
 	// Data stats
 	if stats.BytesScanned > 0 || stats.BytesFromCache > 0 {
@@ -394,6 +586,10 @@ func (ui *UI) showCacheStats() {
 		content += " [::b]Bytes From Cache:[::-] " + numberColor
 		content += ui.formatSize(stats.BytesFromCache, false, true) + "[-::]\n"
 	}
+	if stats.ExcludedFiles > 0 {
+		content += "   [::b]Excluded Files:[::-] " + numberColor
+		content += fmt.Sprintf("%d (%s)[-::]\n", stats.ExcludedFiles, ui.formatSize(stats.ExcludedBytes, false, true))
+	}
 
 	// Performance stats
 	if stats.TotalScanTime > 0 {
@@ -401,6 +597,31 @@ func (ui *UI) showCacheStats() {
 		content += "       [::b]Scan Time:[::-] " + numberColor
 		content += fmt.Sprintf("%v[-::]\n", stats.TotalScanTime)
 	}
+	if stats.Storage.Reads.Count > 0 {
+		content += "     [::b]Cache Reads:[::-] " + numberColor
+		content += stats.Storage.Reads.String() + "[-::]\n"
+	}
+	if stats.Storage.Writes.Count > 0 {
+		content += "    [::b]Cache Writes:[::-] " + numberColor
+		content += stats.Storage.Writes.String() + "[-::]\n"
+	}
+	if stats.PeakHeap > 0 {
+		content += "       [::b]Peak Heap:[::-] " + numberColor
+		content += ui.formatSize(int64(stats.PeakHeap), false, true) + "[-::]\n"
+	}
+
+	// Provenance of the cache entries
+	content += "\n[::b]Provenance:[::-]\n\n"
+	content += "      [::b]Written By:[::-] "
+	content += tview.Escape(analyze.FormatProvenance(stats.Hostname, stats.AppVersion)) + "\n"
+	if stats.PreviousHostname != "" || stats.PreviousAppVersion != "" {
+		content += "[::b]Previous Scan By:[::-] "
+		content += tview.Escape(analyze.FormatProvenance(stats.PreviousHostname, stats.PreviousAppVersion)) + "\n"
+	}
+	if stats.VersionMismatches > 0 {
+		content += "  [::b]Other Versions:[::-] " + numberColor
+		content += fmt.Sprintf("%d[-::]\n", stats.VersionMismatches)
+	}
 
 	text.SetText(content)
 
@@ -410,12 +631,160 @@ func (ui *UI) showCacheStats() {
 		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
 			AddItem(nil, 0, 1, false).
 			AddItem(text, linesCount, 1, false).
-			AddItem(nil, 0, 1, false), 80, 1, false).
+			AddItem(nil, 0, 1, false), ui.modalWidth(statsModalWidth), 1, false).
 		AddItem(nil, 0, 1, false)
 
 	ui.pages.AddPage("cache-stats", flex, true, true)
 }
 
+func (ui *UI) showAgeHistogram() {
+	if ui.currentDir == nil {
+		return
+	}
+
+	// histogram of the selected directory, or of the current one when a file is selected
+	var dir fs.Item = ui.currentDir
+	row, column := ui.table.GetSelection()
+	if selected, ok := ui.table.GetCell(row, column).GetReference().(fs.Item); ok && selected.IsDir() {
+		dir = selected
+	}
+
+	hist, err := analyze.ComputeAgeHistogram(context.Background(), dir, time.Now(), analyze.DefaultAgeBands)
+	if err != nil {
+		ui.showErr("Error computing age histogram", err)
+		return
+	}
+
+	var content, numberColor string
+	if ui.UseColors {
+		numberColor = fmt.Sprintf(
+			"[%s::b]",
+			ui.resultRow.NumberColor,
+		)
+	} else {
+		numberColor = defaultColorBold
+	}
+
+	text := tview.NewTextView().SetDynamicColors(true)
+	text.SetBorder(true).SetBorderPadding(2, 2, 2, 2)
+	text.SetBorderColor(tcell.ColorDefault)
+	text.SetTitle(" File Age ")
+
+	total := hist.TotalUsage
+	if ui.ShowApparentSize {
+		total = hist.TotalSize
+	}
+
+	content += "[::b]" + tview.Escape(dir.GetPath()) + "[::-]\n\n"
+	for _, bucket := range hist.Buckets {
+		size := bucket.Usage
+		if ui.ShowApparentSize {
+			size = bucket.Size
+		}
+		var share float64
+		if total > 0 {
+			share = float64(size) / float64(total) * 100
+		}
+		content += fmt.Sprintf("[::b]%18s:[::-] ", bucket.Label())
+		content += numberColor + fmt.Sprintf("%8d[-::] files ", bucket.Count)
+		content += numberColor + fmt.Sprintf("%12s[-::] ", ui.formatSize(size, false, true))
+		content += fmt.Sprintf("%5.1f%%\n", share)
+	}
+	content += fmt.Sprintf("\n[::b]%18s:[::-] ", "Total")
+	content += numberColor + fmt.Sprintf("%8d[-::] files ", hist.TotalCount)
+	content += numberColor + ui.formatSize(total, false, true) + "[-::]\n"
+
+	text.SetText(content)
+
+	linesCount := len(hist.Buckets) + 10
+	flex := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(text, linesCount, 1, false).
+			AddItem(nil, 0, 1, false), 80, 1, false).
+		AddItem(nil, 0, 1, false)
+
+	ui.pages.AddPage("age-histogram", flex, true, true)
+}
+
+// ownersModalLimit is the number of owners listed by the usage by owner modal
+const ownersModalLimit = 20
+
+func (ui *UI) showUsageByOwner() {
+	if ui.currentDir == nil {
+		return
+	}
+
+	// owners of the selected directory, or of the current one when a file is selected
+	var dir fs.Item = ui.currentDir
+	row, column := ui.table.GetSelection()
+	if selected, ok := ui.table.GetCell(row, column).GetReference().(fs.Item); ok && selected.IsDir() {
+		dir = selected
+	}
+
+	report, err := analyze.ComputeOwnerUsage(context.Background(), dir, ownersModalLimit, ui.ShowApparentSize)
+	if err != nil {
+		ui.showErr("Error computing usage by owner", err)
+		return
+	}
+
+	var content, numberColor string
+	if ui.UseColors {
+		numberColor = fmt.Sprintf(
+			"[%s::b]",
+			ui.resultRow.NumberColor,
+		)
+	} else {
+		numberColor = defaultColorBold
+	}
+
+	text := tview.NewTextView().SetDynamicColors(true)
+	text.SetBorder(true).SetBorderPadding(2, 2, 2, 2)
+	text.SetBorderColor(tcell.ColorDefault)
+	text.SetTitle(" Usage by Owner ")
+
+	total := report.TotalUsage
+	if ui.ShowApparentSize {
+		total = report.TotalSize
+	}
+
+	content += "[::b]" + tview.Escape(dir.GetPath()) + "[::-]\n\n"
+	for _, owner := range report.Owners {
+		size := owner.Usage
+		if ui.ShowApparentSize {
+			size = owner.Size
+		}
+		var share float64
+		if total > 0 {
+			share = float64(size) / float64(total) * 100
+		}
+		content += fmt.Sprintf("[::b]%18s:[::-] ", tview.Escape(owner.Name))
+		content += numberColor + fmt.Sprintf("%8d[-::] files ", owner.Count)
+		content += numberColor + fmt.Sprintf("%12s[-::] ", ui.formatSize(size, false, true))
+		content += fmt.Sprintf("%5.1f%%\n", share)
+	}
+	if report.Dropped > 0 {
+		content += fmt.Sprintf("%18s  ...and %d more owners\n", "", report.Dropped)
+	}
+	content += fmt.Sprintf("\n[::b]%18s:[::-] ", "Total")
+	content += numberColor + fmt.Sprintf("%8d[-::] files ", report.TotalCount)
+	content += numberColor + ui.formatSize(total, false, true) + "[-::]\n"
+
+	text.SetText(content)
+
+	linesCount := len(report.Owners) + 11
+	flex := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(text, linesCount, 1, false).
+			AddItem(nil, 0, 1, false), 80, 1, false).
+		AddItem(nil, 0, 1, false)
+
+	ui.pages.AddPage("owners", flex, true, true)
+}
+
 func (ui *UI) openItem() {
 	row, column := ui.table.GetSelection()
 	selectedFile, ok := ui.table.GetCell(row, column).GetReference().(fs.Item)
@@ -490,6 +859,10 @@ func (ui *UI) exportAnalysis() {
 		buff.Write([]byte(build.Version))
 		buff.Write([]byte(`","timestamp":`))
 		buff.Write([]byte(strconv.FormatInt(time.Now().Unix(), 10)))
+		if dir, ok := ui.topDir.(interface{ GetHardlinksSaved() int64 }); ok && dir.GetHardlinksSaved() > 0 {
+			buff.Write([]byte(`,"hardlinks_saved":`))
+			buff.Write([]byte(strconv.FormatInt(dir.GetHardlinksSaved(), 10)))
+		}
 		buff.Write([]byte("},\n"))
 
 		file, err := os.Create(ui.exportName)
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"errors"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/dundee/gdu/v5/internal/testanalyze"
 	"github.com/dundee/gdu/v5/internal/testapp"
@@ -12,6 +14,7 @@ import (
 	"github.com/dundee/gdu/v5/pkg/analyze"
 	"github.com/dundee/gdu/v5/pkg/fs"
 	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -115,6 +118,29 @@ func TestAnalyzePathBW(t *testing.T) {
 	assert.Contains(t, ui.table.GetCell(0, 0).Text, "ccc")
 }
 
+func TestAnalyzePathCacheFailure(t *testing.T) {
+	storagePath := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(storagePath, []byte("not a directory"), 0o600))
+
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(false)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, false, false, false)
+	ui.Analyzer = analyze.CreateIncrementalAnalyzer(analyze.IncrementalOptions{StoragePath: storagePath})
+	ui.done = make(chan struct{})
+
+	assert.Nil(t, ui.AnalyzePath(t.TempDir(), nil))
+	<-ui.done // wait for analyzer
+
+	for _, f := range ui.app.(*testapp.MockedApp).GetUpdateDraws() {
+		f()
+	}
+
+	assert.True(t, ui.pages.HasPage("error"), "the cause is shown, not just the '!' flag")
+	assert.Contains(t, ui.footerLabel.GetText(false), "Scan failed")
+}
+
 func TestAnalyzePathWithParentDir(t *testing.T) {
 	parentDir := &analyze.Dir{
 		File: &analyze.File{
@@ -148,6 +174,61 @@ func TestAnalyzePathWithParentDir(t *testing.T) {
 	assert.Contains(t, ui.table.GetCell(1, 0).Text, "ccc")
 }
 
+func TestAnalyzePathSingleFile(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, true, true, false)
+	ui.Analyzer = analyze.CreateIncrementalAnalyzer(analyze.IncrementalOptions{StoragePath: t.TempDir()})
+	ui.done = make(chan struct{})
+	err := ui.AnalyzePath("test_dir/nested/file2", nil)
+	assert.Nil(t, err)
+
+	<-ui.done // wait for analyzer
+
+	for _, f := range ui.app.(*testapp.MockedApp).GetUpdateDraws() {
+		f()
+	}
+
+	nested, err := filepath.Abs("test_dir/nested")
+	assert.Nil(t, err)
+	assert.Equal(t, nested, ui.topDirPath)
+	assert.Equal(t, 1, ui.table.GetRowCount())
+	assert.Contains(t, ui.table.GetCell(0, 0).Text, "file2")
+}
+
+func TestAnalyzePathIncrementalRelative(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, true, true, false)
+	ui.Analyzer = analyze.CreateIncrementalAnalyzer(analyze.IncrementalOptions{StoragePath: t.TempDir()})
+	ui.done = make(chan struct{})
+	assert.Nil(t, ui.AnalyzePath("test_dir", nil))
+
+	<-ui.done // wait for analyzer
+
+	for _, f := range ui.app.(*testapp.MockedApp).GetUpdateDraws() {
+		f()
+	}
+
+	// the analyzer made the path absolute, the top directory is still recognized
+	root, err := filepath.Abs("test_dir")
+	assert.Nil(t, err)
+	assert.Equal(t, root, ui.topDirPath)
+	assert.Equal(t, ui.topDirPath, ui.currentDirPath)
+	assert.NotContains(t, ui.table.GetCell(0, 0).Text, "/..")
+	assert.Contains(t, ui.table.GetCell(0, 0).Text, "nested")
+}
+
 func TestReadAnalysis(t *testing.T) {
 	simScreen := testapp.CreateSimScreen()
 	defer simScreen.Fini()
@@ -461,6 +542,183 @@ func TestShowInfoWithHardlinks(t *testing.T) {
 	assert.False(t, ui.pages.HasPage("info"))
 }
 
+func TestShowInfoSizeDifference(t *testing.T) {
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, true, false, false, false, false)
+
+	dir := &analyze.Dir{
+		File: &analyze.File{
+			Name:  "test_dir",
+			Usage: 8192,
+			Size:  8192,
+		},
+		BasePath: ".",
+	}
+	file := &analyze.File{
+		Name:   "sparse",
+		Usage:  4096,
+		Size:   1 << 20,
+		Parent: dir,
+	}
+	dir.Files = fs.Files{file}
+
+	ui.currentDir = dir
+	ui.currentDirPath = dir.GetPath()
+	ui.topDirPath = dir.GetPath()
+	ui.showDir()
+	ui.table.Select(0, 0)
+	ui.showInfo()
+
+	assert.True(t, ui.pages.HasPage("info"))
+	_, page := ui.pages.GetFrontPage()
+	text := page.(*tview.Flex).GetItem(1).(*tview.Flex).GetItem(1).(*tview.TextView).GetText(true)
+	assert.Contains(t, text, "Difference: -1020.0 KiB (-1044480 B)")
+}
+
+func TestShowInfoSymlinks(t *testing.T) {
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, false, false, false, false)
+
+	dir := &analyze.Dir{
+		File:     &analyze.File{Name: "test_dir"},
+		BasePath: ".",
+	}
+	sub := &analyze.Dir{
+		File:               &analyze.File{Name: "sub", Parent: dir},
+		SymlinkCount:       3,
+		BrokenSymlinkCount: 2,
+	}
+	dir.Files = fs.Files{sub}
+
+	ui.currentDir = dir
+	ui.currentDirPath = dir.GetPath()
+	ui.topDirPath = dir.GetPath()
+	ui.showDir()
+	ui.table.Select(0, 0)
+	ui.showInfo()
+
+	_, page := ui.pages.GetFrontPage()
+	text := page.(*tview.Flex).GetItem(1).(*tview.Flex).GetItem(1).(*tview.TextView).GetText(true)
+	assert.Contains(t, text, "Symlinks: 3 (2 broken)")
+}
+
+func TestShowHardlinksSaved(t *testing.T) {
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, false, false, false, false)
+
+	dir := &analyze.Dir{
+		File:     &analyze.File{Name: "test_dir"},
+		BasePath: ".",
+	}
+	sub := &analyze.Dir{File: &analyze.File{Name: "sub", Parent: dir}}
+	sub.Files = fs.Files{
+		&analyze.File{Name: "a", Usage: 8192, Size: 8000, Mli: 1, Parent: sub},
+		&analyze.File{Name: "b", Usage: 8192, Size: 8000, Mli: 1, Parent: sub},
+	}
+	dir.Files = fs.Files{sub, &analyze.File{Name: "c", Usage: 8192, Size: 8000, Mli: 1, Parent: dir}}
+	dir.UpdateStats(make(fs.HardLinkedItems))
+
+	ui.currentDir = dir
+	ui.currentDirPath = dir.GetPath()
+	ui.topDirPath = dir.GetPath()
+	ui.showDir()
+	assert.Contains(t, ui.footerLabel.GetText(true), "Hardlinks saved: 16.0 KiB")
+
+	ui.table.Select(0, 0)
+	ui.showInfo()
+
+	_, page := ui.pages.GetFrontPage()
+	text := page.(*tview.Flex).GetItem(1).(*tview.Flex).GetItem(1).(*tview.TextView).GetText(true)
+	assert.Contains(t, text, "Links saved: 8.0 KiB (8192 B not counted twice)")
+}
+
+func TestShowAgeHistogram(t *testing.T) {
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, false, false, false)
+
+	now := time.Now()
+	dir := &analyze.Dir{
+		File:     &analyze.File{Name: "test_dir"},
+		BasePath: ".",
+	}
+	sub := &analyze.Dir{
+		File: &analyze.File{Name: "sub", Parent: dir},
+	}
+	sub.Files = fs.Files{
+		&analyze.File{Name: "new", Size: 100, Mtime: now, Parent: sub},
+		&analyze.File{Name: "old", Size: 300, Mtime: now.AddDate(-10, 0, 0), Parent: sub},
+	}
+	dir.Files = fs.Files{
+		sub,
+		&analyze.File{Name: "top", Size: 1000, Mtime: now, Parent: dir},
+	}
+
+	ui.currentDir = dir
+	ui.currentDirPath = dir.GetPath()
+	ui.topDirPath = dir.GetPath()
+	ui.showDir()
+	ui.table.Select(1, 0) // sub, sorted after "top" by size
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'A', 0))
+
+	assert.True(t, ui.pages.HasPage("age-histogram"))
+	_, page := ui.pages.GetFrontPage()
+	text := page.(*tview.Flex).GetItem(1).(*tview.Flex).GetItem(1).(*tview.TextView).GetText(true)
+	assert.Contains(t, text, "sub")
+	assert.Contains(t, text, "< 30 days:        1 files   100 B  25.0%")
+	assert.Contains(t, text, "> 5 years:        1 files   300 B  75.0%")
+	assert.Contains(t, text, "Total:        2 files 400 B")
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'q', 0))
+	assert.False(t, ui.pages.HasPage("age-histogram"))
+}
+
+func TestShowUsageByOwner(t *testing.T) {
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, false, false, false)
+
+	dir := &analyze.Dir{
+		File:     &analyze.File{Name: "test_dir"},
+		BasePath: ".",
+	}
+	first := &analyze.File{Name: "first", Size: 300, Parent: dir}
+	first.SetOwner(0)
+	second := &analyze.File{Name: "second", Size: 100, Parent: dir}
+	dir.Files = fs.Files{first, second}
+
+	ui.currentDir = dir
+	ui.currentDirPath = dir.GetPath()
+	ui.topDirPath = dir.GetPath()
+	ui.showDir()
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'U', 0))
+
+	assert.True(t, ui.pages.HasPage("owners"))
+	_, page := ui.pages.GetFrontPage()
+	text := page.(*tview.Flex).GetItem(1).(*tview.Flex).GetItem(1).(*tview.TextView).GetText(true)
+	assert.Contains(t, text, "test_dir")
+	assert.Contains(t, text, "unknown:        1 files   100 B  25.0%")
+	assert.Contains(t, text, "Total:        2 files 400 B")
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'q', 0))
+	assert.False(t, ui.pages.HasPage("owners"))
+}
+
 func TestShowInfoWithoutCurrentDir(t *testing.T) {
 	fin := testdir.CreateTestDir()
 	defer fin()
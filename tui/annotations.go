@@ -0,0 +1,130 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// annotator is implemented by analyzers storing notes of directories (incremental mode)
+type annotator interface {
+	Annotate(item fs.Item, text string) error
+	CountAnnotations(path string) int
+	DropAnnotations(path string) (int, error)
+}
+
+// getAnnotation returns note of the item or empty string
+func getAnnotation(item fs.Item) string {
+	if dir, ok := item.(interface{ GetAnnotation() string }); ok {
+		return dir.GetAnnotation()
+	}
+	return ""
+}
+
+func (ui *UI) editAnnotation() *tview.Form {
+	if ui.currentDir == nil {
+		return nil
+	}
+
+	row, column := ui.table.GetSelection()
+	selectedItem, ok := ui.table.GetCell(row, column).GetReference().(fs.Item)
+	if !ok || !selectedItem.IsDir() {
+		return nil
+	}
+
+	a, ok := ui.Analyzer.(annotator)
+	if !ok {
+		ui.showErr("Notes are not available", errors.New("run gdu with --incremental to store them"))
+		return nil
+	}
+
+	text := getAnnotation(selectedItem)
+	form := tview.NewForm().
+		AddInputField("Note", text, 50, nil, func(v string) {
+			text = v
+		}).
+		AddButton("Save", func() {
+			ui.saveAnnotation(a, selectedItem, text)
+		}).
+		SetButtonsAlign(tview.AlignCenter)
+	form.SetBorder(true).
+		SetTitle(" Note of " + tview.Escape(selectedItem.GetName()) + " ").
+		SetInputCapture(func(key *tcell.EventKey) *tcell.EventKey {
+			if key.Key() == tcell.KeyEsc {
+				ui.pages.RemovePage("note")
+				ui.app.SetFocus(ui.table)
+				return nil
+			}
+			return key
+		})
+	flex := modal(form, 70, 7)
+	ui.pages.AddPage("note", flex, true, true)
+	ui.app.SetFocus(form)
+	return form
+}
+
+func (ui *UI) saveAnnotation(a annotator, item fs.Item, text string) {
+	ui.pages.RemovePage("note")
+	ui.app.SetFocus(ui.table)
+
+	if err := a.Annotate(item, text); err != nil {
+		ui.showErr("Error saving note", err)
+		return
+	}
+
+	row, column := ui.table.GetSelection()
+	ui.showDir()
+	ui.table.Select(row, column)
+}
+
+// offerDropAnnotations asks whether notes of the deleted directories should be dropped as well
+func (ui *UI) offerDropAnnotations(deleted []fs.Item) {
+	a, ok := ui.Analyzer.(annotator)
+	if !ok {
+		return
+	}
+
+	paths := make([]string, 0)
+	count := 0
+	for _, item := range deleted {
+		if !item.IsDir() {
+			continue
+		}
+		if n := a.CountAnnotations(item.GetPath()); n > 0 {
+			paths = append(paths, item.GetPath())
+			count += n
+		}
+	}
+	if count == 0 {
+		return
+	}
+
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("Drop [::b]%d[::-] notes of the deleted directories?", count)).
+		AddButtons([]string{"no", "yes"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			ui.pages.RemovePage("confirm")
+			ui.app.SetFocus(ui.table)
+			if buttonIndex != 1 {
+				return
+			}
+			for _, path := range paths {
+				if _, err := a.DropAnnotations(path); err != nil {
+					ui.showErr("Error dropping notes", err)
+					return
+				}
+			}
+		})
+
+	if !ui.UseColors {
+		modal.SetBackgroundColor(tcell.ColorGray)
+	} else {
+		modal.SetBackgroundColor(tcell.ColorBlack)
+	}
+	modal.SetBorderColor(tcell.ColorDefault)
+
+	ui.pages.AddPage("confirm", modal, true, true)
+}
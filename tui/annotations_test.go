@@ -0,0 +1,126 @@
+package tui
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dundee/gdu/v5/internal/testanalyze"
+	"github.com/dundee/gdu/v5/internal/testapp"
+	"github.com/dundee/gdu/v5/internal/testdir"
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/stretchr/testify/assert"
+)
+
+func getIncrementalMockedApp(t *testing.T, storagePath string) *UI {
+	t.Helper()
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, false, false, false)
+	ui.Analyzer = analyze.CreateIncrementalAnalyzer(analyze.IncrementalOptions{StoragePath: storagePath})
+	ui.done = make(chan struct{})
+	err := ui.AnalyzePath("test_dir", nil)
+	assert.Nil(t, err)
+
+	<-ui.done // wait for analyzer
+
+	for _, f := range ui.app.(*testapp.MockedApp).GetUpdateDraws() {
+		f()
+	}
+	return ui
+}
+
+func TestEditAnnotation(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	storagePath := t.TempDir()
+	ui := getIncrementalMockedApp(t, storagePath)
+	ui.table.Select(0, 0)
+
+	form := ui.editAnnotation()
+	assert.True(t, ui.pages.HasPage("note"))
+
+	inputFn := form.GetFormItemByLabel("Note").(*tview.InputField).InputHandler()
+	for _, r := range "keep" {
+		inputFn(tcell.NewEventKey(tcell.KeyRune, r, 0), nil)
+	}
+	form.GetButton(0).InputHandler()(tcell.NewEventKey(tcell.KeyEnter, 0, 0), nil)
+
+	assert.False(t, ui.pages.HasPage("note"))
+	nested := ui.table.GetCell(0, 0).GetReference().(fs.Item)
+	assert.Equal(t, "keep", getAnnotation(nested))
+	assert.NotContains(t, ui.table.GetCell(0, 0).Text, "# keep")
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'N', 0))
+	assert.Contains(t, ui.table.GetCell(0, 0).Text, "# keep")
+
+	ui.showInfo()
+	_, page := ui.pages.GetFrontPage()
+	text := page.(*tview.Flex).GetItem(1).(*tview.Flex).GetItem(1).(*tview.TextView).GetText(true)
+	assert.Contains(t, text, "Note: keep")
+
+	// the note is loaded by the next scan
+	ui = getIncrementalMockedApp(t, storagePath)
+	assert.Equal(t, "keep", getAnnotation(ui.table.GetCell(0, 0).GetReference().(fs.Item)))
+}
+
+func TestEditAnnotationWithoutIncremental(t *testing.T) {
+	ui := getAnalyzedPathMockedApp(t, false, true, true)
+	ui.table.Select(0, 0)
+
+	assert.Nil(t, ui.editAnnotation())
+	assert.True(t, ui.pages.HasPage("error"))
+}
+
+func TestDropAnnotationsAfterDelete(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	ui := getIncrementalMockedApp(t, t.TempDir())
+	ui.table.Select(0, 0)
+	nested := ui.table.GetCell(0, 0).GetReference().(fs.Item)
+	a := ui.Analyzer.(*analyze.IncrementalAnalyzer)
+	assert.NoError(t, a.Annotate(nested, "safe to delete"))
+	assert.NoError(t, a.Annotate(nested.(*analyze.Dir).Files[0], "owned by team X"))
+
+	ui.deleteSelected(false)
+	<-ui.done
+	for _, f := range ui.app.(*testapp.MockedApp).GetUpdateDraws() {
+		f()
+	}
+
+	assert.NoDirExists(t, "test_dir/nested")
+	assert.True(t, ui.pages.HasPage("confirm"))
+	_, page := ui.pages.GetFrontPage()
+	modal := page.(*tview.Modal)
+	var focused tview.Primitive
+	var setFocus func(p tview.Primitive)
+	setFocus = func(p tview.Primitive) { // like tview.Application.SetFocus
+		if focused != nil {
+			focused.Blur()
+		}
+		focused = p
+		p.Focus(setFocus)
+	}
+	modal.Focus(setFocus)
+	modal.InputHandler()(tcell.NewEventKey(tcell.KeyTab, 0, 0), setFocus) // "yes"
+	modal.InputHandler()(tcell.NewEventKey(tcell.KeyEnter, 0, 0), setFocus)
+
+	assert.False(t, ui.pages.HasPage("confirm"))
+	assert.False(t, ui.pages.HasPage("error"))
+	assert.Equal(t, 0, a.CountAnnotations("test_dir"))
+}
+
+func TestOfferDropAnnotationsWithoutNotes(t *testing.T) {
+	ui := getAnalyzedPathMockedApp(t, false, true, true)
+	ui.Analyzer = &testanalyze.MockedAnalyzer{}
+
+	ui.offerDropAnnotations([]fs.Item{ui.currentDir})
+
+	assert.False(t, ui.pages.HasPage("confirm"))
+}
@@ -0,0 +1,60 @@
+package tui
+
+import (
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// cacheKeeper is implemented by analyzers storing their cache on disk (incremental mode)
+type cacheKeeper interface {
+	ContainsCacheDir(path string) bool
+}
+
+// itemsToDelete returns the marked items, or the selected one if none is marked
+func (ui *UI) itemsToDelete(selected fs.Item) []fs.Item {
+	if len(ui.markedRows) == 0 {
+		return []fs.Item{selected}
+	}
+	items := make([]fs.Item, 0, len(ui.markedRows))
+	for row := range ui.markedRows {
+		if item, ok := ui.table.GetCell(row, 0).GetReference().(fs.Item); ok {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// keepsCacheDir shows a warning instead of deleting the items if one of them contains
+// the cache directory of the analyzer, deleting it would corrupt the cache used by the scans.
+// Returns true if the deletion is stopped
+func (ui *UI) keepsCacheDir(items []fs.Item) bool {
+	keeper, ok := ui.Analyzer.(cacheKeeper)
+	if !ok {
+		return false
+	}
+	for _, item := range items {
+		if !keeper.ContainsCacheDir(item.GetPath()) {
+			continue
+		}
+
+		modal := tview.NewModal().
+			SetText("\"" + tview.Escape(item.GetName()) + "\" contains the gdu cache used by the scans.\n\n" +
+				"Deleting it would corrupt the cache, remove it with --clear-cache instead.").
+			AddButtons([]string{"ok"}).
+			SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+				ui.pages.RemovePage("cache-warning")
+			})
+
+		if !ui.UseColors {
+			modal.SetBackgroundColor(tcell.ColorGray)
+		} else {
+			modal.SetBackgroundColor(tcell.ColorBlack)
+		}
+		modal.SetBorderColor(tcell.ColorDefault)
+
+		ui.pages.AddPage("cache-warning", modal, true, true)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package tui
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dundee/gdu/v5/internal/testapp"
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteProtectsCacheDir(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "tree")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "data"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "data", "file"), []byte("x"), 0o600))
+
+	analyzer := analyze.CreateIncrementalAnalyzer(analyze.IncrementalOptions{
+		StoragePath:   filepath.Join(root, ".cache", "gdu"),
+		CountCacheDir: true,
+	})
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, false, false, false)
+	ui.Analyzer = analyzer
+	ui.askBeforeDelete = true
+
+	ui.currentDir = dir
+	ui.currentDirPath = dir.GetPath()
+	ui.topDirPath = dir.GetPath()
+	ui.showDir()
+
+	rowOf := func(name string) int {
+		for row := 0; row < ui.table.GetRowCount(); row++ {
+			if item, ok := ui.table.GetCell(row, 0).GetReference().(fs.Item); ok && item.GetName() == name {
+				return row
+			}
+		}
+		return -1
+	}
+
+	// the parent of the cache can't be deleted
+	ui.table.Select(rowOf(".cache"), 0)
+	ui.handleDelete(false)
+	assert.True(t, ui.pages.HasPage("cache-warning"))
+	assert.False(t, ui.pages.HasPage("confirm"))
+	ui.pages.RemovePage("cache-warning")
+
+	// neither with other marked items
+	ui.markedRows[rowOf(".cache")] = struct{}{}
+	ui.markedRows[rowOf("data")] = struct{}{}
+	ui.handleDelete(true)
+	assert.True(t, ui.pages.HasPage("cache-warning"))
+	assert.False(t, ui.pages.HasPage("confirm"))
+	ui.pages.RemovePage("cache-warning")
+
+	// other items are deleted as before
+	delete(ui.markedRows, rowOf(".cache"))
+	ui.handleDelete(false)
+	assert.False(t, ui.pages.HasPage("cache-warning"))
+	assert.True(t, ui.pages.HasPage("confirm"))
+	assert.DirExists(t, filepath.Join(root, ".cache", "gdu"))
+}
@@ -0,0 +1,139 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// SetDuplicateOptions sets options of looking for duplicates of the selected directory by 'D'
+func (ui *UI) SetDuplicateOptions(opts analyze.DuplicateOptions) {
+	ui.duplicateOptions = opts
+}
+
+// showDuplicates looks for duplicates of the selected directory in background,
+// the progress modal can be closed by Esc which cancels the hashing
+func (ui *UI) showDuplicates() {
+	if ui.currentDir == nil {
+		return
+	}
+
+	// duplicates of the selected directory, or of the current one when a file is selected
+	var dir fs.Item = ui.currentDir
+	row, column := ui.table.GetSelection()
+	if selected, ok := ui.table.GetCell(row, column).GetReference().(fs.Item); ok && selected.IsDir() {
+		dir = selected
+	}
+
+	text := tview.NewTextView().
+		SetText("Looking for duplicates...\n(Esc to cancel)").
+		SetTextAlign(tview.AlignCenter)
+	text.SetBorder(true).SetTitle(" Duplicates ")
+	ui.pages.AddPage("finding-duplicates", modal(text, 50, 4), true, true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ui.cancelDuplicates = cancel
+	opts := ui.duplicateOptions
+
+	go func() {
+		report, err := analyze.FindDuplicates(ctx, dir, opts)
+		cancel()
+
+		ui.app.QueueUpdateDraw(func() {
+			ui.pages.RemovePage("finding-duplicates")
+			ui.cancelDuplicates = nil
+			switch {
+			case errors.Is(err, context.Canceled):
+				ui.app.SetFocus(ui.table)
+			case err != nil:
+				ui.showErr("Error looking for duplicates", err)
+			default:
+				ui.showDuplicateReport(dir, report)
+			}
+		})
+		if ui.done != nil {
+			ui.done <- struct{}{}
+		}
+	}()
+}
+
+// handleDuplicatesControl cancels looking for duplicates by Esc
+func (ui *UI) handleDuplicatesControl(key *tcell.EventKey) *tcell.EventKey {
+	if key.Key() == tcell.KeyEsc && ui.cancelDuplicates != nil {
+		ui.cancelDuplicates()
+		return nil
+	}
+	return key
+}
+
+func (ui *UI) showDuplicateReport(dir fs.Item, report *analyze.DuplicateReport) {
+	var numberColor string
+	if ui.UseColors {
+		numberColor = fmt.Sprintf(
+			"[%s::b]",
+			ui.resultRow.NumberColor,
+		)
+	} else {
+		numberColor = defaultColorBold
+	}
+
+	var content strings.Builder
+	content.WriteString("[::b]" + tview.Escape(dir.GetPath()) + "[::-]\n\n")
+	for _, set := range report.Sets {
+		content.WriteString(numberColor + ui.formatSize(set.Reclaimable, false, true) + "[-::] reclaimable, ")
+		content.WriteString(fmt.Sprintf("%d copies of %s:\n", len(set.Paths), ui.formatSize(set.Size, false, true)))
+		for _, path := range set.Paths {
+			content.WriteString("  " + tview.Escape(path) + "\n")
+		}
+	}
+	for _, linked := range report.Linked {
+		content.WriteString(fmt.Sprintf(
+			"Already linked, %d links of %s:\n", len(linked.Paths), ui.formatSize(linked.Size, false, true),
+		))
+		for _, path := range linked.Paths {
+			content.WriteString("  " + tview.Escape(path) + "\n")
+		}
+	}
+	if len(report.Sets) > 0 || len(report.Linked) > 0 {
+		content.WriteString("\n")
+	}
+
+	content.WriteString(fmt.Sprintf("[::b]Duplicate sets:[::-] %s%d[-::]\n", numberColor, len(report.Sets)))
+	content.WriteString("[::b]Reclaimable:[::-] " + numberColor + ui.formatSize(report.Reclaimable, false, true) + "[-::]\n")
+	content.WriteString(fmt.Sprintf("[::b]Hashed:[::-] %d of %d candidates\n", report.Hashed, report.Candidates))
+	if report.Skipped > 0 {
+		content.WriteString(fmt.Sprintf("[::b]Not hashed:[::-] %d (over the limit of hashed files)\n", report.Skipped))
+	}
+	if report.Errors > 0 {
+		content.WriteString(fmt.Sprintf("[::b]Unreadable:[::-] %d\n", report.Errors))
+	}
+
+	text := tview.NewTextView().SetDynamicColors(true)
+	text.SetBorder(true).SetBorderPadding(2, 2, 2, 2)
+	text.SetBorderColor(tcell.ColorDefault)
+	text.SetTitle(" Duplicates ")
+	text.SetScrollable(true)
+	text.SetText(content.String())
+
+	height := strings.Count(content.String(), "\n") + 7
+	if _, screenHeight := ui.screen.Size(); height > screenHeight {
+		height = screenHeight
+	}
+
+	flex := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(text, height, 1, false).
+			AddItem(nil, 0, 1, false), 100, 1, false).
+		AddItem(nil, 0, 1, false)
+
+	ui.pages.AddPage("duplicates", flex, true, true)
+	ui.app.SetFocus(text)
+}
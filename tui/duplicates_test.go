@@ -0,0 +1,77 @@
+package tui
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dundee/gdu/v5/internal/testapp"
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShowDuplicates(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "dups")
+	assert.NoError(t, os.Mkdir(root, 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "a"), []byte("same"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "b"), []byte("same"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "c"), []byte("diff"), 0o600))
+
+	analyzer := analyze.CreateSeqAnalyzer()
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+	dir.UpdateStats(nil)
+
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, false, false, false)
+	ui.SetDuplicateOptions(analyze.DuplicateOptions{})
+	ui.done = make(chan struct{})
+
+	ui.currentDir = dir
+	ui.currentDirPath = dir.GetPath()
+	ui.topDirPath = dir.GetPath()
+	ui.showDir()
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'D', 0))
+	assert.True(t, ui.pages.HasPage("finding-duplicates"))
+
+	<-ui.done // wait for hashing
+	for _, f := range ui.app.(*testapp.MockedApp).GetUpdateDraws() {
+		f()
+	}
+
+	assert.False(t, ui.pages.HasPage("finding-duplicates"))
+	assert.True(t, ui.pages.HasPage("duplicates"))
+	_, page := ui.pages.GetFrontPage()
+	text := page.(*tview.Flex).GetItem(1).(*tview.Flex).GetItem(1).(*tview.TextView).GetText(true)
+	assert.Contains(t, text, "2 copies of 4 B:\n  "+filepath.Join(root, "a")+"\n  "+filepath.Join(root, "b")+"\n")
+	assert.Contains(t, text, "Hashed: 3 of 3 candidates")
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'q', 0))
+	assert.False(t, ui.pages.HasPage("duplicates"))
+}
+
+func TestCancelDuplicates(t *testing.T) {
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, false, false, false)
+
+	cancelled := false
+	ui.cancelDuplicates = func() { cancelled = true }
+	ui.pages.AddPage("finding-duplicates", tview.NewTextView(), true, true)
+
+	// other keys do not trigger actions under the modal
+	assert.NotNil(t, ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'D', 0)))
+	assert.False(t, cancelled)
+
+	assert.Nil(t, ui.keyPressed(tcell.NewEventKey(tcell.KeyEsc, 0, 0)))
+	assert.True(t, cancelled)
+}
@@ -0,0 +1,160 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// SetEmptyDirsRecursive makes the listing of empty directories by 'P' include directories
+// containing only empty directories
+func (ui *UI) SetEmptyDirsRecursive(recursive bool) {
+	ui.emptyDirsRecursive = recursive
+}
+
+// showEmptyDirs lists empty directories of the selected directory, or of the current one
+// when a file is selected. Only the analyzed tree is read, so it is quick
+func (ui *UI) showEmptyDirs() {
+	if ui.currentDir == nil {
+		return
+	}
+
+	var dir fs.Item = ui.currentDir
+	row, column := ui.table.GetSelection()
+	if selected, ok := ui.table.GetCell(row, column).GetReference().(fs.Item); ok && selected.IsDir() {
+		dir = selected
+	}
+
+	report, err := analyze.FindEmptyDirs(context.Background(), dir, ui.emptyDirsRecursive)
+	if err != nil {
+		ui.showErr("Error looking for empty directories", err)
+		return
+	}
+	ui.emptyDirs = report
+
+	var numberColor string
+	if ui.UseColors {
+		numberColor = fmt.Sprintf(
+			"[%s::b]",
+			ui.resultRow.NumberColor,
+		)
+	} else {
+		numberColor = defaultColorBold
+	}
+
+	var content strings.Builder
+	content.WriteString("[::b]" + tview.Escape(dir.GetPath()) + "[::-]\n\n")
+	for _, empty := range report.Dirs {
+		content.WriteString(tview.Escape(empty.Path))
+		if len(empty.Nested) > 0 {
+			content.WriteString(fmt.Sprintf(" (%d nested)", len(empty.Nested)))
+		}
+		content.WriteString("\n")
+	}
+	if len(report.Dirs) > 0 {
+		content.WriteString("\n")
+	}
+
+	content.WriteString(fmt.Sprintf("[::b]Empty directories:[::-] %s%d[-::]\n", numberColor, report.Total))
+	if report.Total > 0 && !ui.noDelete && !ui.showingPrevious() {
+		content.WriteString("Press [::b]d[::-] to delete them\n")
+	}
+
+	text := tview.NewTextView().SetDynamicColors(true)
+	text.SetBorder(true).SetBorderPadding(2, 2, 2, 2)
+	text.SetBorderColor(tcell.ColorDefault)
+	text.SetTitle(" Empty directories ")
+	text.SetScrollable(true)
+	text.SetText(content.String())
+
+	height := strings.Count(content.String(), "\n") + 7
+	if _, screenHeight := ui.screen.Size(); height > screenHeight {
+		height = screenHeight
+	}
+
+	flex := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(text, height, 1, false).
+			AddItem(nil, 0, 1, false), 100, 1, false).
+		AddItem(nil, 0, 1, false)
+
+	ui.pages.AddPage("empty-dirs", flex, true, true)
+	ui.app.SetFocus(text)
+}
+
+// handleEmptyDirsControl offers deletion of the listed empty directories by 'd'.
+// Directories of the previous view may not exist anymore, so they are not offered
+func (ui *UI) handleEmptyDirsControl(key *tcell.EventKey) *tcell.EventKey {
+	if key.Rune() != 'd' || ui.noDelete || ui.emptyDirs == nil || ui.emptyDirs.Total == 0 ||
+		ui.showingPrevious() {
+		return key
+	}
+
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("Are you sure you want to delete [::b]%d[::-] empty directories?", ui.emptyDirs.Total)).
+		AddButtons([]string{"no", "yes"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			ui.pages.RemovePage("confirm")
+			if buttonIndex == 1 {
+				ui.pages.RemovePage("empty-dirs")
+				ui.deleteEmptyDirs(ui.emptyDirs)
+			}
+		})
+
+	if !ui.UseColors {
+		modal.SetBackgroundColor(tcell.ColorGray)
+	} else {
+		modal.SetBackgroundColor(tcell.ColorBlack)
+	}
+	modal.SetBorderColor(tcell.ColorDefault)
+
+	ui.pages.AddPage("confirm", modal, true, true)
+	return nil
+}
+
+// deleteEmptyDirs removes the topmost directories of the report with their nested ones.
+// The tree may come from the cache, so only directories which are still empty are removed
+// (deepest first, like rmdir), the ones which are not are left in place and reported
+func (ui *UI) deleteEmptyDirs(report *analyze.EmptyDirReport) {
+	modal := tview.NewModal().SetText("Deleting empty directories...")
+	ui.pages.AddPage(actingDelete, modal, true, true)
+
+	go func() {
+		var deletedItems []fs.Item
+		var failed int
+		var lastErr error
+		for _, empty := range report.Dirs {
+			if err := analyze.RemoveEmptyDir(empty); err != nil {
+				failed++
+				lastErr = err
+				continue
+			}
+			empty.Item.GetParent().RemoveFile(empty.Item)
+			deletedItems = append(deletedItems, empty.Item)
+		}
+
+		ui.app.QueueUpdateDraw(func() {
+			ui.pages.RemovePage(actingDelete)
+			ui.emptyDirs = nil
+			row, _ := ui.table.GetSelection()
+			ui.showDir()
+			ui.table.Select(min(row, ui.table.GetRowCount()-1), 0)
+			if lastErr != nil {
+				ui.showErr(fmt.Sprintf("Can't delete %d of the directories", failed), lastErr)
+			} else {
+				ui.offerDropAnnotations(deletedItems)
+			}
+		})
+
+		if ui.done != nil {
+			ui.done <- struct{}{}
+		}
+	}()
+}
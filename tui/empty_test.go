@@ -0,0 +1,105 @@
+package tui
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dundee/gdu/v5/internal/testapp"
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShowAndDeleteEmptyDirs(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "tree")
+	for _, dir := range []string{"empty/inner", "full", "late"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o755))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "full", "file"), []byte("x"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "readme"), []byte("x"), 0o600))
+
+	analyzer := analyze.CreateSeqAnalyzer()
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+	dir.UpdateStats(nil)
+
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, false, false, false)
+	ui.SetEmptyDirsRecursive(true)
+	ui.done = make(chan struct{})
+
+	ui.currentDir = dir
+	ui.currentDirPath = dir.GetPath()
+	ui.topDirPath = dir.GetPath()
+	ui.showDir()
+
+	// with a file selected, the current directory is searched
+	for row := 0; row < ui.table.GetRowCount(); row++ {
+		if item, ok := ui.table.GetCell(row, 0).GetReference().(fs.Item); ok && !item.IsDir() {
+			ui.table.Select(row, 0)
+		}
+	}
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'P', 0))
+	assert.True(t, ui.pages.HasPage("empty-dirs"))
+	_, page := ui.pages.GetFrontPage()
+	text := page.(*tview.Flex).GetItem(1).(*tview.Flex).GetItem(1).(*tview.TextView).GetText(true)
+	assert.Contains(t, text, filepath.Join(root, "empty")+" (1 nested)\n"+filepath.Join(root, "late")+"\n")
+	assert.Contains(t, text, "Empty directories: 3\n")
+
+	// a file created since the scan keeps the directory in place
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "late", "file"), []byte("x"), 0o600))
+
+	assert.Nil(t, ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'd', 0)))
+	assert.True(t, ui.pages.HasPage("confirm"))
+	ui.pages.RemovePage("confirm")
+	ui.pages.RemovePage("empty-dirs")
+	ui.deleteEmptyDirs(ui.emptyDirs)
+
+	<-ui.done // wait for deletion
+	for _, f := range ui.app.(*testapp.MockedApp).GetUpdateDraws() {
+		f()
+	}
+
+	assert.False(t, ui.pages.HasPage("empty-dirs"))
+	assert.NoDirExists(t, filepath.Join(root, "empty"))
+	assert.DirExists(t, filepath.Join(root, "late"))
+	assert.True(t, ui.pages.HasPage("error"))
+}
+
+func TestEmptyDirsDeletionDisabled(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "tree")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "empty"), 0o755))
+
+	analyzer := analyze.CreateSeqAnalyzer()
+	dir := analyzer.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	analyzer.GetDone().Wait()
+	dir.UpdateStats(nil)
+
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, false, false, false)
+	ui.SetNoDelete()
+
+	ui.currentDir = dir
+	ui.currentDirPath = dir.GetPath()
+	ui.topDirPath = dir.GetPath()
+	ui.showDir()
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'P', 0))
+	assert.NotNil(t, ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'd', 0)))
+	assert.False(t, ui.pages.HasPage("confirm"))
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyEsc, 0, 0))
+	assert.False(t, ui.pages.HasPage("empty-dirs"))
+	assert.DirExists(t, filepath.Join(root, "empty"))
+}
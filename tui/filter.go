@@ -9,6 +9,7 @@ func (ui *UI) hideFilterInput() {
 	ui.filterValue = ""
 	ui.footer.Clear()
 	ui.footer.AddItem(ui.footerLabel, 0, 1, false)
+	ui.addScanSummary()
 	ui.app.SetFocus(ui.table)
 	ui.filteringInput = nil
 	ui.filtering = false
@@ -50,6 +51,7 @@ func (ui *UI) showFilterInput() {
 		ui.footer.Clear()
 		ui.footer.AddItem(ui.filteringInput, 0, 1, true)
 		ui.footer.AddItem(ui.footerLabel, 0, 5, false)
+		ui.addScanSummary()
 	}
 	ui.app.SetFocus(ui.filteringInput)
 	ui.filtering = true
@@ -0,0 +1,25 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// getFoldedCount returns number of the children folded into the item (see analyze.FoldedItems),
+// 0 if it is a real file or directory
+func getFoldedCount(item fs.Item) int {
+	if folded, ok := item.(interface{ GetFoldedCount() int }); ok {
+		return folded.GetFoldedCount()
+	}
+	return 0
+}
+
+// foldedName returns the name shown for the folded children, e.g. "<other: 12 items, 3.4 MiB>"
+func (ui *UI) foldedName(item fs.Item, count int) string {
+	size := item.GetUsage()
+	if ui.ShowApparentSize {
+		size = item.GetSize()
+	}
+	return fmt.Sprintf("<other: %d items, %s>", count, ui.formatPlainSize(size))
+}
@@ -44,11 +44,11 @@ func (ui *UI) formatFileRow(item fs.Item, maxUsage, maxSize int64, marked, ignor
 		row += defaultColorBold
 	}
 
+	size := item.GetUsage()
 	if ui.ShowApparentSize {
-		row += fmt.Sprintf("%15s", ui.formatSize(item.GetSize(), false, true))
-	} else {
-		row += fmt.Sprintf("%15s", ui.formatSize(item.GetUsage(), false, true))
+		size = item.GetSize()
 	}
+	row += fmt.Sprintf("%15s", estimatedPrefix(item)+ui.formatSize(size, false, true))
 
 	if ui.useOldSizeBar {
 		row += " " + getUsageGraphOld(part) + " "
@@ -70,6 +70,27 @@ func (ui *UI) formatFileRow(item fs.Item, maxUsage, maxSize int64, marked, ignor
 		row += fmt.Sprintf("%11s ", ui.formatCount(countToDisplay))
 	}
 
+	// columns filled from the cache are hidden on narrow terminals
+	wide := ui.showCacheColumns()
+
+	if ui.showSelfSize && wide {
+		if ui.UseColors && !marked && !ignored {
+			row += numberColor
+		} else {
+			row += defaultColorBold
+		}
+		row += fmt.Sprintf("%15s ", ui.formatSelfSize(item))
+	}
+
+	if ui.showErrorCount && wide {
+		if ui.UseColors && !marked && !ignored {
+			row += numberColor
+		} else {
+			row += defaultColorBold
+		}
+		row += fmt.Sprintf("%6s ", formatErrorCount(item))
+	}
+
 	if ui.showMtime {
 		if ui.UseColors && !marked && !ignored {
 			row += numberColor
@@ -82,6 +103,15 @@ func (ui *UI) formatFileRow(item fs.Item, maxUsage, maxSize int64, marked, ignor
 		)
 	}
 
+	if ui.showBtime && wide {
+		if ui.UseColors && !marked && !ignored {
+			row += numberColor
+		} else {
+			row += defaultColorBold
+		}
+		row += fmt.Sprintf("%19s "+defaultColor, formatBtime(item))
+	}
+
 	if len(ui.markedRows) > 0 {
 		if marked {
 			row += string('✓')
@@ -98,10 +128,75 @@ func (ui *UI) formatFileRow(item fs.Item, maxUsage, maxSize int64, marked, ignor
 			row += defaultColorBold + "/"
 		}
 	}
-	row += tview.Escape(item.GetName())
+
+	name, suffix := item.GetName(), ""
+	if count := getFoldedCount(item); count > 0 {
+		name = ui.foldedName(item, count)
+		row += "[::i]"
+	}
+	if dup := getDuplicateOf(item); dup != "" {
+		suffix += " → same as " + dup
+	}
+	if note := getAnnotation(item); ui.showAnnotations && note != "" {
+		suffix += "  # " + note
+	}
+	if width := ui.screenWidth(); width > 0 {
+		name, suffix = fitName(name, suffix, width-tview.TaggedStringWidth(row))
+	}
+
+	row += tview.Escape(name)
+	if suffix != "" {
+		row += defaultColor + tview.Escape(suffix)
+	}
 	return row
 }
 
+// getDuplicateOf returns path of the directory the item is identical to (bind mount) or empty string
+func getDuplicateOf(item fs.Item) string {
+	if dir, ok := item.(interface{ GetDuplicateOf() string }); ok {
+		return dir.GetDuplicateOf()
+	}
+	return ""
+}
+
+// estimatedPrefix returns "~" if totals of the item are estimated from a sample
+func estimatedPrefix(item fs.Item) string {
+	if fs.IsEstimated(item) {
+		return "~"
+	}
+	return ""
+}
+
+// formatErrorCount returns number of read errors in directory subtree or empty string for files
+func formatErrorCount(item fs.Item) string {
+	dir, ok := item.(interface{ GetErrorCount() int })
+	if !ok || !item.IsDir() {
+		return defaultColor
+	}
+	return fmt.Sprintf("%d%s", dir.GetErrorCount(), defaultColor)
+}
+
+// formatSelfSize returns size of the files directly in the directory, nothing for files
+func (ui *UI) formatSelfSize(item fs.Item) string {
+	if !item.IsDir() {
+		return defaultColor
+	}
+	size := fs.GetSelfUsage(item)
+	if ui.ShowApparentSize {
+		size = fs.GetSelfSize(item)
+	}
+	return ui.formatSize(size, false, true) + defaultColor
+}
+
+// formatBtime returns birth time of the item or a dash if it is not known
+func formatBtime(item fs.Item) string {
+	btime := fs.GetBtime(item)
+	if btime.IsZero() {
+		return "—"
+	}
+	return btime.Format("2006-01-02 15:04:05")
+}
+
 func (ui *UI) formatSize(size int64, reverseColor, transparentBg bool) string {
 	var color string
 	if reverseColor {
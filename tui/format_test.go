@@ -2,10 +2,13 @@ package tui
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/dundee/gdu/v5/internal/testapp"
 	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/rivo/tview"
+	"github.com/rivo/uniseg"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -78,6 +81,67 @@ func TestEscapeName(t *testing.T) {
 	assert.Contains(t, ui.formatFileRow(file, file.GetUsage(), file.GetSize(), false, false), "Aaa [red[] bbb")
 }
 
+func TestDuplicateDir(t *testing.T) {
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, false, false, false, false)
+
+	dir := &analyze.Dir{
+		File: &analyze.File{
+			Name: "mnt",
+			Flag: 'D',
+		},
+		DuplicateOf: "/var/lib/foo",
+	}
+
+	assert.Contains(t, ui.formatFileRow(dir, 10, 10, false, false), "mnt[-::] → same as /var/lib/foo")
+}
+
+func TestEstimatedDir(t *testing.T) {
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, false, false, false, false)
+
+	dir := &analyze.Dir{
+		File: &analyze.File{
+			Name:  "big",
+			Flag:  '~',
+			Usage: 2 * 1024 * 1024,
+		},
+		EstimatedDirCount: 1,
+	}
+
+	assert.Contains(t, ui.formatFileRow(dir, dir.Usage, dir.Size, false, false), "~2.0[-::] MiB")
+	dir.EstimatedDirCount = 0
+	assert.NotContains(t, ui.formatFileRow(dir, dir.Usage, dir.Size, false, false), "~2.0")
+}
+
+func TestFoldedItems(t *testing.T) {
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, false, false, false, false)
+
+	folded := &analyze.FoldedItems{
+		File: &analyze.File{
+			Name:  analyze.FoldedItemsName,
+			Flag:  '+',
+			Usage: 3 * 1024,
+		},
+		Count: 2,
+		Items: 5,
+	}
+
+	row := ui.formatFileRow(folded, folded.Usage, folded.Size, false, false)
+	assert.True(t, strings.HasPrefix(row, "+"))
+	assert.Contains(t, row, "[::i]<other: 2 items, 3.0 KiB>")
+}
+
 func TestMarked(t *testing.T) {
 	simScreen := testapp.CreateSimScreen()
 	defer simScreen.Fini()
@@ -173,3 +237,111 @@ func TestOldSizeBar(t *testing.T) {
 
 	assert.Contains(t, ui.formatFileRow(file, dir.GetUsage(), dir.GetSize(), false, false), "[#####     ]   Aaa")
 }
+
+func TestTruncateWidth(t *testing.T) {
+	assert.Equal(t, "short", truncateWidth("short", 10))
+	assert.Equal(t, "long na…", truncateWidth("long name", 8))
+	assert.Equal(t, "日本語…", truncateWidth("日本語のファイル", 7))
+	assert.Equal(t, "日本…", truncateWidth("日本語のファイル", 6))
+	assert.Equal(t, "…", truncateWidth("日本語", 1))
+	assert.Equal(t, "", truncateWidth("name", 0))
+
+	name, suffix := fitName("mnt", " → same as /var/lib/foo", 10)
+	assert.Equal(t, "mnt", name)
+	assert.Equal(t, " → sam…", suffix)
+	name, suffix = fitName("日本語のファイル", " → same as /var/lib/foo", 8)
+	assert.Equal(t, "日本語…", name)
+	assert.Equal(t, "", suffix)
+}
+
+// screenRows draws the table of ui on screen of the given size and returns its rows
+func screenRows(t *testing.T, width int, setup func(ui *UI)) []string {
+	t.Helper()
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+	assert.NoError(t, simScreen.Init())
+	simScreen.SetSize(width, 10)
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, false, false, false)
+	setup(ui)
+
+	dir := &analyze.Dir{
+		File:     &analyze.File{Name: "root", Size: 300},
+		BasePath: "/",
+	}
+	dir.AddFile(&analyze.Dir{
+		File:        &analyze.File{Name: "日本語のとても長いディレクトリ名です", Size: 200, Parent: dir},
+		SelfSize:    100,
+		DuplicateOf: "/var/lib/foo",
+	})
+	dir.AddFile(&analyze.File{Name: "readme.txt", Size: 100, Parent: dir})
+	ui.currentDir = dir
+	ui.topDirPath = "/root"
+	ui.showDir()
+
+	ui.table.SetRect(0, 0, width, 10)
+	ui.table.Draw(simScreen)
+	simScreen.Show()
+
+	cells, w, _ := simScreen.GetContents()
+	rows := make([]string, 0, 3)
+	for y := 0; y < 3; y++ {
+		row := ""
+		for x := 0; x < w; x++ {
+			row += string(cells[y*w+x].Runes)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestNarrowTerminalLayout(t *testing.T) {
+	rows := screenRows(t, 40, func(ui *UI) {
+		ui.showSelfSize = true
+		ui.showErrorCount = true
+	})
+
+	// cache columns are hidden, the name is shortened to the width
+	assert.Contains(t, rows[0], "200 B")
+	assert.NotContains(t, rows[0], "100 B")
+	assert.Contains(t, rows[0], "/日本語")
+	assert.Contains(t, rows[0], "…")
+	assert.NotContains(t, rows[0], "same as")
+	assert.Contains(t, rows[1], "readme.txt")
+	for _, row := range rows {
+		assert.LessOrEqual(t, uniseg.StringWidth(strings.TrimRight(row, " ")), 40)
+	}
+}
+
+func TestWideTerminalLayout(t *testing.T) {
+	rows := screenRows(t, 120, func(ui *UI) {
+		ui.showSelfSize = true
+		ui.showErrorCount = true
+	})
+
+	assert.Contains(t, rows[0], "200 B")
+	assert.Contains(t, rows[0], "100 B")
+	assert.Contains(t, rows[0], "/日本語のとても長いディレクトリ名です → same as /var/lib/foo")
+	assert.NotContains(t, rows[0], "…")
+}
+
+func TestCacheStatsModalWidth(t *testing.T) {
+	for width, expected := range map[int]int{40: 40, 120: statsModalWidth} {
+		simScreen := testapp.CreateSimScreen()
+		assert.NoError(t, simScreen.Init())
+		simScreen.SetSize(width, 30)
+
+		ui := CreateUI(testapp.CreateMockedApp(true), simScreen, &bytes.Buffer{}, false, true, false, false, false)
+		ui.SetAnalyzer(analyze.CreateIncrementalAnalyzer(analyze.IncrementalOptions{StoragePath: t.TempDir()}))
+		ui.showCacheStats()
+		assert.True(t, ui.pages.HasPage("cache-stats"))
+
+		ui.pages.SetRect(0, 0, width, 30)
+		ui.pages.Draw(simScreen)
+		_, modal := ui.pages.GetFrontPage()
+		_, _, modalWidth, _ := modal.(*tview.Flex).GetItem(1).GetRect()
+		assert.Equal(t, expected, modalWidth)
+		simScreen.Fini()
+	}
+}
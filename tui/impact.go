@@ -0,0 +1,69 @@
+package tui
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// subtreeTotaler is implemented by analyzers able to return totals of a directory
+// without walking it (incremental mode)
+type subtreeTotaler interface {
+	SubtreeTotals(item fs.Item) *analyze.SubtreeTotals
+}
+
+// deletionImpact describes how much space deletion (or emptying) of the item frees
+func (ui *UI) deletionImpact(item fs.Item, shouldEmpty bool) string {
+	var totals *analyze.SubtreeTotals
+	if a, ok := ui.Analyzer.(subtreeTotaler); ok {
+		totals = a.SubtreeTotals(item)
+	} else {
+		totals = analyze.LoadedTotals(item)
+	}
+
+	count := totals.ItemCount
+	if shouldEmpty && item.IsDir() {
+		count-- // the directory itself is kept
+	}
+
+	text := "This will free [::b]" + ui.formatSize(totals.Usage, false, true) +
+		"[::-] across [::b]" + ui.formatCount(count) + "[::-] items"
+	if totals.IsCached() {
+		text += " (cached " + max(time.Since(totals.CachedAt), 0).Round(time.Second).String() + " ago)"
+	} else {
+		text += " (live data)"
+	}
+
+	if ui.hasSharedHardLinks(item) {
+		text += ".\nSome files are hard-linked from elsewhere, the freed space may be less"
+	}
+	return text + "."
+}
+
+// hasSharedHardLinks returns true if a hard-linked file inside of the item
+// has another link outside of it, so deleting the item does not free its space
+func (ui *UI) hasSharedHardLinks(item fs.Item) bool {
+	path := item.GetPath()
+	for _, linked := range ui.linkedItems {
+		inside, outside := false, false
+		for _, link := range linked {
+			if isInPath(link.GetPath(), path) {
+				inside = true
+			} else {
+				outside = true
+			}
+		}
+		if inside && outside {
+			return true
+		}
+	}
+	return false
+}
+
+// isInPath returns true if path is dir or it lies under dir
+func isInPath(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
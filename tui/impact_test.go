@@ -0,0 +1,98 @@
+package tui
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/dundee/gdu/v5/internal/testanalyze"
+	"github.com/dundee/gdu/v5/internal/testapp"
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/rivo/tview"
+	"github.com/stretchr/testify/assert"
+)
+
+func getImpactUI(t *testing.T) (*UI, *analyze.Dir) {
+	t.Helper()
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, false, false, false, false)
+	ui.Analyzer = &testanalyze.MockedAnalyzer{}
+
+	dir := &analyze.Dir{
+		File:     &analyze.File{Name: "fixture"},
+		BasePath: "/",
+	}
+	big := &analyze.Dir{
+		File:      &analyze.File{Name: "big", Usage: 3 << 30, Size: 3 << 30, Parent: dir},
+		ItemCount: 15,
+	}
+	file := &analyze.File{Name: "file", Usage: 4096, Size: 100, Mli: 1, Flag: 'H', Parent: big}
+	other := &analyze.File{Name: "other", Usage: 4096, Size: 100, Mli: 1, Flag: 'H', Parent: dir}
+	big.Files = fs.Files{file}
+	dir.Files = fs.Files{big, other}
+
+	ui.currentDir = dir
+	ui.currentDirPath = dir.GetPath()
+	ui.topDirPath = dir.GetPath()
+	ui.showDir()
+	return ui, big
+}
+
+// stripTags removes style tags from the text as the modal shows it
+func stripTags(text string) string {
+	return tview.NewTextView().SetDynamicColors(true).SetText(text).GetText(true)
+}
+
+func TestDeletionImpactLive(t *testing.T) {
+	ui, big := getImpactUI(t)
+
+	assert.Equal(t, "This will free 3.0 GiB across 15 items (live data).", stripTags(ui.deletionImpact(big, false)))
+	assert.Equal(t, "This will free 3.0 GiB across 14 items (live data).", stripTags(ui.deletionImpact(big, true)))
+}
+
+func TestDeletionImpactHardLinks(t *testing.T) {
+	ui, big := getImpactUI(t)
+	ui.linkedItems = fs.HardLinkedItems{1: fs.Files{big.Files[0], ui.currentDir.GetFiles()[1]}}
+
+	assert.Contains(t, ui.deletionImpact(big, false), "hard-linked from elsewhere, the freed space may be less")
+	assert.NotContains(t, ui.deletionImpact(ui.currentDir, false), "hard-linked")
+}
+
+func TestDeletionImpactCached(t *testing.T) {
+	ui, big := getImpactUI(t)
+	storagePath := t.TempDir()
+
+	storage := analyze.NewIncrementalStorage(storagePath, "/fixture")
+	closeFn, err := storage.Open()
+	assert.NoError(t, err)
+	err = storage.StoreDirMetadata(&analyze.IncrementalDirMetadata{
+		Path:      "/fixture/big",
+		Usage:     412 << 30,
+		Size:      400 << 30,
+		ItemCount: 1200000,
+		CachedAt:  time.Now().Add(-3 * time.Hour),
+	})
+	assert.NoError(t, err)
+	closeFn()
+
+	ui.Analyzer = analyze.CreateIncrementalAnalyzer(analyze.IncrementalOptions{StoragePath: storagePath})
+
+	assert.Equal(t,
+		"This will free 412.0 GiB across 1.2M items (cached 3h0m0s ago).",
+		stripTags(ui.deletionImpact(big, false)),
+	)
+
+	// not cached
+	assert.Equal(t,
+		"This will free 4.0 KiB across 1 items (live data).",
+		stripTags(ui.deletionImpact(ui.currentDir.GetFiles()[1], false)),
+	)
+
+	ui.table.Select(0, 0)
+	ui.confirmDeletionSelected(false)
+	assert.True(t, ui.pages.HasPage("confirm"))
+}
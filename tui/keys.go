@@ -13,7 +13,7 @@ func (ui *UI) keyPressed(key *tcell.EventKey) *tcell.EventKey {
 		return nil
 	}
 
-	if ui.pages.HasPage("file") || ui.pages.HasPage("export") {
+	if ui.pages.HasPage("file") || ui.pages.HasPage("export") || ui.pages.HasPage("note") {
 		return key // send event to primitive
 	}
 	if ui.filtering {
@@ -40,7 +40,10 @@ func (ui *UI) keyPressed(key *tcell.EventKey) *tcell.EventKey {
 	if ui.pages.HasPage("progress") ||
 		ui.pages.HasPage("deleting") ||
 		ui.pages.HasPage("emptying") {
-		return key
+		return ui.handleDeletionControl(key)
+	}
+	if ui.pages.HasPage("finding-duplicates") {
+		return ui.handleDuplicatesControl(key)
 	}
 
 	key = ui.handleHelp(key)
@@ -48,7 +51,10 @@ func (ui *UI) keyPressed(key *tcell.EventKey) *tcell.EventKey {
 		return nil
 	}
 
-	if ui.pages.HasPage("help") {
+	if ui.pages.HasPage("empty-dirs") {
+		return ui.handleEmptyDirsControl(key)
+	}
+	if ui.pages.HasPage("help") || ui.pages.HasPage("duplicates") {
 		return key
 	}
 
@@ -87,6 +93,27 @@ func (ui *UI) handleClosingModals(key *tcell.EventKey) *tcell.EventKey {
 			ui.app.SetFocus(ui.table)
 			return nil
 		}
+		if ui.pages.HasPage("age-histogram") {
+			ui.pages.RemovePage("age-histogram")
+			ui.app.SetFocus(ui.table)
+			return nil
+		}
+		if ui.pages.HasPage("owners") {
+			ui.pages.RemovePage("owners")
+			ui.app.SetFocus(ui.table)
+			return nil
+		}
+		if ui.pages.HasPage("duplicates") {
+			ui.pages.RemovePage("duplicates")
+			ui.app.SetFocus(ui.table)
+			return nil
+		}
+		if ui.pages.HasPage("empty-dirs") && !ui.pages.HasPage("confirm") {
+			ui.pages.RemovePage("empty-dirs")
+			ui.emptyDirs = nil
+			ui.app.SetFocus(ui.table)
+			return nil
+		}
 	}
 	return key
 }
@@ -220,6 +247,14 @@ func (ui *UI) handleMainActions(key *tcell.EventKey) *tcell.EventKey {
 		ui.showInfo()
 	case 'S':
 		ui.showCacheStats()
+	case 'A':
+		ui.showAgeHistogram()
+	case 'U':
+		ui.showUsageByOwner()
+	case 'D':
+		ui.showDuplicates()
+	case 'P':
+		ui.showEmptyDirs()
 	case 'a':
 		ui.ShowApparentSize = !ui.ShowApparentSize
 		if ui.currentDir != nil {
@@ -248,10 +283,45 @@ func (ui *UI) handleMainActions(key *tcell.EventKey) *tcell.EventKey {
 			ui.showDir()
 			ui.table.Select(row, column)
 		}
+	case 't':
+		ui.showBtime = !ui.showBtime
+		if ui.currentDir != nil {
+			row, column := ui.table.GetSelection()
+			ui.showDir()
+			ui.table.Select(row, column)
+		}
+	case 'f':
+		ui.showSelfSize = !ui.showSelfSize
+		if ui.currentDir != nil {
+			row, column := ui.table.GetSelection()
+			ui.showDir()
+			ui.table.Select(row, column)
+		}
+	case 'x':
+		ui.showErrorCount = !ui.showErrorCount
+		if ui.currentDir != nil {
+			row, column := ui.table.GetSelection()
+			ui.showDir()
+			ui.table.Select(row, column)
+		}
+	case 'N':
+		ui.showAnnotations = !ui.showAnnotations
+		if ui.currentDir != nil {
+			row, column := ui.table.GetSelection()
+			ui.showDir()
+			ui.table.Select(row, column)
+		}
+	case 'w':
+		ui.editAnnotation()
+		return nil
 	case 'r':
 		if ui.currentDir != nil {
 			ui.rescanDir()
 		}
+	case 'R':
+		ui.reRoot()
+	case 'u':
+		ui.togglePrevious()
 	case 'E':
 		ui.confirmExport()
 		return nil
@@ -263,6 +333,10 @@ func (ui *UI) handleMainActions(key *tcell.EventKey) *tcell.EventKey {
 		ui.setSorting("name")
 	case 'M':
 		ui.setSorting("mtime")
+	case 'T':
+		ui.setSorting("btime")
+	case 'F':
+		ui.setSorting("selfSize")
 	case '/':
 		ui.showFilterInput()
 		return nil
@@ -275,6 +349,10 @@ func (ui *UI) handleMainActions(key *tcell.EventKey) *tcell.EventKey {
 }
 
 func (ui *UI) handleLeft() {
+	if ui.atReRoot() {
+		ui.leaveRoot()
+		return
+	}
 	if ui.currentDirPath == ui.topDirPath {
 		if ui.devices != nil {
 			ui.currentDir = nil
@@ -292,7 +370,7 @@ func (ui *UI) handleLeft() {
 
 func (ui *UI) handleRight() {
 	row, column := ui.table.GetSelection()
-	if ui.currentDirPath != ui.topDirPath && row == 0 { // do not select /..
+	if ui.hasParentRow() && row == 0 { // do not select /..
 		return
 	}
 
@@ -307,10 +385,17 @@ func (ui *UI) handleDelete(shouldEmpty bool) {
 	if ui.currentDir == nil {
 		return
 	}
-	// do not allow deleting parent dir
+	// do not allow deleting parent dir and folded items, which are not files
 	row, column := ui.table.GetSelection()
 	selectedFile, ok := ui.table.GetCell(row, column).GetReference().(fs.Item)
-	if !ok || selectedFile == ui.currentDir.GetParent() {
+	if !ok || selectedFile == ui.currentDir.GetParent() || getFoldedCount(selectedFile) > 0 {
+		return
+	}
+	// items of the previous view may not exist anymore
+	if ui.showingPrevious() {
+		return
+	}
+	if ui.keepsCacheDir(ui.itemsToDelete(selectedFile)) {
 		return
 	}
 
@@ -325,10 +410,10 @@ func (ui *UI) handleMark() {
 	if ui.currentDir == nil {
 		return
 	}
-	// do not allow deleting parent dir
+	// do not allow deleting parent dir and folded items, which are not files
 	row, column := ui.table.GetSelection()
 	selectedFile, ok := ui.table.GetCell(row, column).GetReference().(fs.Item)
-	if !ok || selectedFile == ui.currentDir.GetParent() {
+	if !ok || selectedFile == ui.currentDir.GetParent() || getFoldedCount(selectedFile) > 0 {
 		return
 	}
 
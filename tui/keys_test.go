@@ -3,7 +3,9 @@ package tui
 import (
 	"bytes"
 	"errors"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/dundee/gdu/v5/internal/testanalyze"
 	"github.com/dundee/gdu/v5/internal/testapp"
@@ -211,6 +213,12 @@ func TestStopWithPrintingPath(t *testing.T) {
 func TestSpawnShell(t *testing.T) {
 	fin := testdir.CreateTestDir()
 	defer fin()
+	// the shell is spawned in the current directory, go back before test_dir is removed
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	defer func() {
+		assert.Nil(t, os.Chdir(wd))
+	}()
 	simScreen := testapp.CreateSimScreen()
 	defer simScreen.Fini()
 
@@ -224,7 +232,7 @@ func TestSpawnShell(t *testing.T) {
 	}
 
 	ui.done = make(chan struct{})
-	err := ui.AnalyzePath("test_dir", nil)
+	err = ui.AnalyzePath("test_dir", nil)
 	assert.Nil(t, err)
 
 	<-ui.done // wait for analyzer
@@ -889,6 +897,113 @@ func TestShowMtimeBW(t *testing.T) {
 	assert.True(t, ui.showMtime)
 }
 
+func TestShowErrorCount(t *testing.T) {
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, false, false, false)
+	ui.Analyzer = &testanalyze.MockedAnalyzer{}
+	ui.done = make(chan struct{})
+	err := ui.AnalyzePath("test_dir", nil)
+	assert.Nil(t, err)
+
+	<-ui.done // wait for analyzer
+
+	for _, f := range ui.app.(*testapp.MockedApp).GetUpdateDraws() {
+		f()
+	}
+
+	assert.Equal(t, "test_dir", ui.currentDir.GetName())
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'x', 0))
+
+	assert.True(t, ui.showErrorCount)
+	assert.Contains(t, ui.table.GetCell(0, 0).Text, "0")
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'x', 0))
+
+	assert.False(t, ui.showErrorCount)
+}
+
+func TestShowBtime(t *testing.T) {
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, false, false, false)
+	ui.Analyzer = &testanalyze.MockedAnalyzer{}
+	ui.done = make(chan struct{})
+	err := ui.AnalyzePath("test_dir", nil)
+	assert.Nil(t, err)
+
+	<-ui.done // wait for analyzer
+
+	for _, f := range ui.app.(*testapp.MockedApp).GetUpdateDraws() {
+		f()
+	}
+
+	ui.currentDir.GetFiles()[0].(*analyze.Dir).Btime = time.Date(2021, 8, 27, 22, 23, 24, 0, time.UTC)
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 't', 0))
+
+	assert.True(t, ui.showBtime)
+	assert.Contains(t, ui.table.GetCell(0, 0).Text, "2021-08-27 22:23:24")
+	assert.Contains(t, ui.table.GetCell(1, 0).Text, "—")
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 't', 0))
+
+	assert.False(t, ui.showBtime)
+	assert.NotContains(t, ui.table.GetCell(1, 0).Text, "—")
+}
+
+func TestShowSelfSize(t *testing.T) {
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, false, false, false, false)
+	ui.Analyzer = &testanalyze.MockedAnalyzer{}
+	ui.done = make(chan struct{})
+	err := ui.AnalyzePath("test_dir", nil)
+	assert.Nil(t, err)
+
+	<-ui.done // wait for analyzer
+
+	for _, f := range ui.app.(*testapp.MockedApp).GetUpdateDraws() {
+		f()
+	}
+
+	for _, item := range ui.currentDir.GetFiles() {
+		if dir, ok := item.(*analyze.Dir); ok {
+			dir.SelfUsage = map[string]int64{"aaa": 10, "bbb": 3000, "ccc": 20}[dir.GetName()]
+		}
+	}
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'f', 0))
+
+	assert.True(t, ui.showSelfSize)
+	assert.Contains(t, ui.table.GetCell(1, 0).Text, "2.9[-::] KiB")
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'F', 0))
+
+	assert.Equal(t, selfSizeSortKey, ui.sortBy)
+	assert.Contains(t, ui.table.GetCell(0, 0).Text, "aaa")
+	assert.Contains(t, ui.table.GetCell(1, 0).Text, "ccc")
+	assert.Contains(t, ui.table.GetCell(2, 0).Text, "ddd")
+	assert.Contains(t, ui.table.GetCell(3, 0).Text, "bbb")
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'F', 0))
+
+	assert.Contains(t, ui.table.GetCell(0, 0).Text, "bbb")
+	assert.Contains(t, ui.table.GetCell(3, 0).Text, "aaa")
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'f', 0))
+
+	assert.False(t, ui.showSelfSize)
+	assert.NotContains(t, ui.table.GetCell(0, 0).Text, "2.9[-::] KiB")
+}
+
 func TestShowRelativeBar(t *testing.T) {
 	simScreen := testapp.CreateSimScreen()
 	defer simScreen.Fini()
@@ -986,6 +1101,8 @@ func TestSorting(t *testing.T) {
 	assert.Equal(t, "name", ui.sortBy)
 	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'M', 0))
 	assert.Equal(t, "mtime", ui.sortBy)
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'T', 0))
+	assert.Equal(t, "btime", ui.sortBy)
 
 	// marking should be dropped after sorting
 	assert.Equal(t, 0, len(ui.markedRows))
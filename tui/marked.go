@@ -33,7 +33,7 @@ func (ui *UI) deleteMarked(shouldEmpty bool) {
 	}
 
 	var currentDir fs.Item
-	var markedItems []fs.Item
+	var markedItems, deletedItems []fs.Item
 	for row := range ui.markedRows {
 		item := ui.table.GetCell(row, 0).GetReference().(fs.Item)
 		markedItems = append(markedItems, item)
@@ -52,15 +52,16 @@ func (ui *UI) deleteMarked(shouldEmpty bool) {
 	var deleteFun func(fs.Item, fs.Item) error
 
 	go func() {
+	markedLoop:
 		for _, one := range markedItems {
+			text := cases.Title(language.English).String(acting) +
+				" " +
+				tview.Escape(one.GetName()) +
+				"..."
 			ui.app.QueueUpdateDraw(func() {
-				modal.SetText(
-					cases.Title(language.English).String(acting) +
-						" " +
-						tview.Escape(one.GetName()) +
-						"...",
-				)
+				modal.SetText(text)
 			})
+			stopWatching := ui.watchDeletion(modal, text)
 
 			if shouldEmpty && !one.IsDir() {
 				deleteFun = ui.emptier
@@ -80,7 +81,13 @@ func (ui *UI) deleteMarked(shouldEmpty bool) {
 			}
 
 			for _, item := range deleteItems {
-				if err := deleteFun(currentDir, item); err != nil {
+				err := deleteFun(currentDir, item)
+				if isCancelled(err) {
+					stopWatching()
+					break markedLoop
+				}
+				if err != nil {
+					stopWatching()
 					msg := "Can't " + action + " " + tview.Escape(one.GetName())
 					ui.app.QueueUpdateDraw(func() {
 						ui.pages.RemovePage(acting)
@@ -91,7 +98,9 @@ func (ui *UI) deleteMarked(shouldEmpty bool) {
 					}
 					return
 				}
+				deletedItems = append(deletedItems, item)
 			}
+			stopWatching()
 		}
 
 		ui.app.QueueUpdateDraw(func() {
@@ -101,6 +110,7 @@ func (ui *UI) deleteMarked(shouldEmpty bool) {
 			ui.showDir()
 			ui.table.Select(min(currentRow, ui.table.GetRowCount()-1), 0)
 			ui.table.SetOffset(min(x, ui.table.GetRowCount()-1), y)
+			ui.offerDropAnnotations(deletedItems)
 		})
 
 		if ui.done != nil {
@@ -23,7 +23,7 @@ func (ui *UI) onMouse(event *tcell.EventMouse, action tview.MouseAction) (*tcell
 	switch action {
 	case tview.MouseLeftDoubleClick:
 		row, column := ui.table.GetSelection()
-		if ui.currentDirPath != ui.topDirPath && row == 0 {
+		if ui.hasParentRow() && row == 0 {
 			ui.handleLeft()
 		} else {
 			selectedFile := ui.table.GetCell(row, column).GetReference().(fs.Item)
@@ -0,0 +1,82 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// previousView is the directory replaced by the last rescan, kept by SetKeepPrevious
+type previousView struct {
+	dir       fs.Item   // directory as it was before the rescan
+	scannedAt time.Time // when the directory was scanned
+	current   fs.Item   // directory shown when the previous view was opened
+}
+
+// SetKeepPrevious keeps the directory replaced by a rescan until the next rescan,
+// so the view can be switched back to it to compare the totals. It costs the memory
+// of the old tree, only one previous tree is kept
+func (ui *UI) SetKeepPrevious() {
+	ui.keepPrevious = true
+}
+
+// rememberPrevious keeps the shown directory before it is rescanned
+func (ui *UI) rememberPrevious() {
+	if !ui.keepPrevious {
+		return
+	}
+	if ui.showingPrevious() {
+		ui.currentDir = ui.previous.current
+		ui.currentDirPath = ui.currentDir.GetPath()
+	}
+	ui.previous = &previousView{dir: ui.currentDir, scannedAt: ui.scannedAt}
+	ui.inPrevious = false
+}
+
+// togglePrevious switches between the current view and the directory replaced by the last rescan
+func (ui *UI) togglePrevious() {
+	if ui.previous == nil || ui.currentDir == nil {
+		return
+	}
+	if ui.showingPrevious() {
+		ui.currentDir = ui.previous.current
+		ui.inPrevious = false
+	} else {
+		ui.previous.current = ui.currentDir
+		ui.currentDir = ui.previous.dir
+		ui.inPrevious = true
+	}
+	ui.hideFilterInput()
+	ui.markedRows = make(map[int]struct{})
+	ui.ignoredRows = make(map[int]struct{})
+	ui.showDir()
+}
+
+// showingPrevious returns true if the shown directory is in the tree replaced by the last rescan.
+// Leaving the replaced directory (e.g. going to its parent) ends the previous view
+func (ui *UI) showingPrevious() bool {
+	if !ui.inPrevious || ui.previous == nil || ui.currentDir == nil {
+		return false
+	}
+	top := ui.previous.dir.GetPath()
+	path := ui.currentDir.GetPath()
+	if path != top && !strings.HasPrefix(path, top+string(filepath.Separator)) {
+		ui.inPrevious = false
+	}
+	return ui.inPrevious
+}
+
+// previousBanner returns the banner shown above the previous view, empty in the current one
+func (ui *UI) previousBanner() string {
+	if !ui.showingPrevious() {
+		return ""
+	}
+	age := "unknown age"
+	if !ui.previous.scannedAt.IsZero() {
+		age = "scanned " + time.Since(ui.previous.scannedAt).Round(time.Second).String() + " ago"
+	}
+	return fmt.Sprintf("[black:yellow:b] PREVIOUS VIEW (%s), press u for the current one [-:-:-] ", age)
+}
@@ -0,0 +1,79 @@
+package tui
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dundee/gdu/v5/internal/testapp"
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeepPrevious(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "file"), make([]byte, 1000), 0o600))
+
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, false, false, false)
+	ui.SetKeepPrevious()
+	ui.done = make(chan struct{})
+
+	scan := func(run func()) {
+		run()
+		<-ui.done
+		for _, f := range ui.app.(*testapp.MockedApp).GetUpdateDraws() {
+			f()
+		}
+	}
+	scan(func() { assert.NoError(t, ui.AnalyzePath(root, nil)) })
+	oldSize := ui.currentDir.GetSize()
+
+	// nothing to switch to before the first rescan
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'u', 0))
+	assert.False(t, ui.showingPrevious())
+
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "new"), make([]byte, 5000), 0o600))
+	scan(ui.rescanDir)
+	newSize := ui.currentDir.GetSize()
+	assert.Equal(t, oldSize+5000, newSize)
+	assert.NotContains(t, ui.currentDirLabel.GetText(true), "PREVIOUS VIEW")
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'u', 0))
+	assert.True(t, ui.showingPrevious())
+	assert.Equal(t, oldSize, ui.currentDir.GetSize())
+	assert.Contains(t, ui.currentDirLabel.GetText(true), "PREVIOUS VIEW (scanned")
+	assert.Contains(t, ui.footerLabel.GetText(true), "Apparent size: 1000 B Items: 1")
+
+	// the items of the previous view are not deleted
+	ui.table.Select(0, 0)
+	ui.handleDelete(false)
+	assert.False(t, ui.pages.HasPage("confirm"))
+	// neither are its empty directories
+	ui.emptyDirs = &analyze.EmptyDirReport{Total: 1}
+	ui.handleEmptyDirsControl(tcell.NewEventKey(tcell.KeyRune, 'd', 0))
+	assert.False(t, ui.pages.HasPage("confirm"))
+	ui.emptyDirs = nil
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'u', 0))
+	assert.False(t, ui.showingPrevious())
+	assert.Equal(t, newSize, ui.currentDir.GetSize())
+	assert.Contains(t, ui.footerLabel.GetText(true), "Apparent size: 5.9 KiB Items: 2")
+	ui.handleDelete(false)
+	assert.True(t, ui.pages.HasPage("confirm"))
+}
+
+func TestKeepPreviousDisabled(t *testing.T) {
+	ui := getAnalyzedPathMockedApp(t, false, true, true)
+	ui.done = make(chan struct{})
+
+	ui.rescanDir()
+	<-ui.done
+
+	assert.Nil(t, ui.previous)
+}
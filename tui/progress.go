@@ -14,22 +14,10 @@ func (ui *UI) updateProgress() {
 	}
 
 	progressChan := ui.Analyzer.GetProgressChan()
-	doneChan := ui.Analyzer.GetDone()
-
-	var progress common.CurrentProgress
 	start := time.Now()
 
-	for {
-		select {
-		case progress = <-progressChan:
-		case <-doneChan:
-			ui.app.QueueUpdateDraw(func() {
-				ui.progress.SetTitle(" Finalizing... ")
-				ui.progress.SetText("Calculating disk usage...")
-			})
-			return
-		}
-
+	// the channel is closed when the analysis is done
+	for progress := range progressChan {
 		func(itemCount int, totalSize int64, currentItem string) {
 			delta := time.Since(start).Round(time.Second)
 
@@ -50,4 +38,9 @@ func (ui *UI) updateProgress() {
 
 		time.Sleep(100 * time.Millisecond)
 	}
+
+	ui.app.QueueUpdateDraw(func() {
+		ui.progress.SetTitle(" Finalizing... ")
+		ui.progress.SetText("Calculating disk usage...")
+	})
 }
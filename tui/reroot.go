@@ -0,0 +1,87 @@
+package tui
+
+import (
+	"golang.org/x/exp/slices"
+
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// rootCrumb remembers the root which was left by re-rooting the UI
+type rootCrumb struct {
+	topDir     fs.Item
+	topDirPath string
+	currentDir fs.Item // directory shown when the UI was re-rooted
+}
+
+// reRoot makes the selected directory the new top directory without rescanning it.
+// Totals and relative sizes are computed from the subtree already in memory,
+// going up from the new root returns to the previous one
+func (ui *UI) reRoot() {
+	if ui.currentDir == nil {
+		return
+	}
+	row, column := ui.table.GetSelection()
+	selected, ok := ui.table.GetCell(row, column).GetReference().(fs.Item)
+	if !ok || !selected.IsDir() || selected == ui.currentDir.GetParent() {
+		return
+	}
+	if _, isParentDir := selected.(*analyze.ParentDir); isParentDir {
+		return
+	}
+
+	ui.rootStack = append(ui.rootStack, rootCrumb{
+		topDir:     ui.topDir,
+		topDirPath: ui.topDirPath,
+		currentDir: ui.currentDir,
+	})
+	ui.topDir = selected
+	ui.topDirPath = selected.GetPath()
+
+	ui.currentDir = selected
+	ui.hideFilterInput()
+	ui.markedRows = make(map[int]struct{})
+	ui.ignoredRows = make(map[int]struct{})
+	ui.showDir()
+}
+
+// leaveRoot returns to the root which was active before the last re-rooting
+// and selects the directory the UI was re-rooted at
+func (ui *UI) leaveRoot() {
+	if len(ui.rootStack) == 0 {
+		return
+	}
+	crumb := ui.rootStack[len(ui.rootStack)-1]
+	ui.rootStack = ui.rootStack[:len(ui.rootStack)-1]
+
+	root := ui.topDir
+	ui.topDir = crumb.topDir
+	ui.topDirPath = crumb.topDirPath
+
+	ui.currentDir = crumb.currentDir
+	ui.hideFilterInput()
+	ui.markedRows = make(map[int]struct{})
+	ui.ignoredRows = make(map[int]struct{})
+	ui.showDir()
+
+	index := slices.IndexFunc(
+		ui.currentDir.GetFiles(),
+		func(v fs.Item) bool {
+			return v.GetName() == root.GetName()
+		},
+	)
+	if ui.hasParentRow() {
+		index++
+	}
+	ui.table.Select(max(index, 0), 0)
+}
+
+// atReRoot returns true if the shown directory is a root set by re-rooting
+func (ui *UI) atReRoot() bool {
+	return len(ui.rootStack) > 0 && ui.currentDirPath == ui.topDirPath
+}
+
+// hasParentRow returns true if the first row of the table is the /.. entry
+func (ui *UI) hasParentRow() bool {
+	return ui.currentDirPath != ui.topDirPath || len(ui.rootStack) > 0
+}
@@ -0,0 +1,146 @@
+package tui
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dundee/gdu/v5/internal/testapp"
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// createReRootTree returns /data with projects/foo, projects/bar and other,
+// the parent of /data is a ParentDir marker like with the incremental analyzer
+func createReRootTree() (data, projects, foo *analyze.Dir) {
+	newDir := func(name, basePath string, usage int64, parent fs.Item) *analyze.Dir {
+		return &analyze.Dir{
+			File:      &analyze.File{Name: name, Size: usage, Usage: usage, Parent: parent},
+			BasePath:  basePath,
+			ItemCount: 1,
+		}
+	}
+	addFile := func(dir *analyze.Dir, name string, usage int64) {
+		dir.AddFile(&analyze.File{Name: name, Size: usage, Usage: usage, Parent: dir})
+	}
+
+	data = newDir("data", "/", 1000, &analyze.ParentDir{Path: "/"})
+	projects = newDir("projects", "/data", 400, data)
+	foo = newDir("foo", "/data/projects", 300, projects)
+	bar := newDir("bar", "/data/projects", 100, projects)
+	addFile(foo, "big", 300)
+	addFile(bar, "small", 100)
+	projects.AddFile(foo)
+	projects.AddFile(bar)
+	data.AddFile(projects)
+	addFile(data, "other", 600)
+	return data, projects, foo
+}
+
+func createReRootUI(t *testing.T) (*UI, *analyze.Dir, *analyze.Dir) {
+	t.Helper()
+	app, simScreen := testapp.CreateTestAppWithSimScreen(120, 20)
+	t.Cleanup(simScreen.Fini)
+
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, false, false, false, false)
+	data, projects, _ := createReRootTree()
+	ui.currentDir = data
+	ui.topDir = data
+	ui.topDirPath = data.GetPath()
+	ui.showDir()
+	return ui, data, projects
+}
+
+func pressKey(ui *UI, r rune) {
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, r, 0))
+}
+
+func TestReRoot(t *testing.T) {
+	ui, _, projects := createReRootUI(t)
+
+	ui.table.Select(1, 0) // projects, other is bigger
+	assert.Equal(t, projects, ui.table.GetCell(1, 0).GetReference())
+	pressKey(ui, 'l')
+	assert.Equal(t, "/data/projects", ui.currentDirPath)
+
+	ui.table.Select(1, 0) // foo
+	pressKey(ui, 'R')
+
+	assert.Equal(t, "/data/projects/foo", ui.topDirPath)
+	assert.Equal(t, "foo", ui.topDir.GetName())
+	assert.Equal(t, ui.topDir, ui.currentDir)
+	assert.Len(t, ui.rootStack, 1)
+	assert.Contains(t, ui.table.GetCell(0, 0).Text, "/..")
+	assert.Contains(t, ui.table.GetCell(1, 0).Text, "big")
+	assert.Contains(t, ui.footerLabel.GetText(true), "Total disk usage: 300 B")
+
+	// the /.. row is not entered by right
+	ui.table.Select(0, 0)
+	pressKey(ui, 'l')
+	assert.Equal(t, "/data/projects/foo", ui.currentDirPath)
+}
+
+func TestReRootBreadcrumbBack(t *testing.T) {
+	ui, data, projects := createReRootUI(t)
+
+	ui.table.Select(1, 0)
+	pressKey(ui, 'R') // projects
+	ui.table.Select(1, 0)
+	pressKey(ui, 'R') // foo
+	assert.Len(t, ui.rootStack, 2)
+
+	// /.. of the new root leads to the previous root, not to the ParentDir marker above /data
+	ui.fileItemSelected(0, 0)
+	assert.Equal(t, "/data/projects", ui.topDirPath)
+	assert.Equal(t, projects, ui.currentDir)
+	row, _ := ui.table.GetSelection()
+	assert.Equal(t, "foo", ui.table.GetCell(row, 0).GetReference().(fs.Item).GetName())
+
+	pressKey(ui, 'h')
+	assert.Equal(t, "/data", ui.topDirPath)
+	assert.Equal(t, data, ui.topDir)
+	assert.Equal(t, data, ui.currentDir)
+	assert.Empty(t, ui.rootStack)
+	assert.NotContains(t, ui.table.GetCell(0, 0).Text, "/..")
+	row, _ = ui.table.GetSelection()
+	assert.Equal(t, projects, ui.table.GetCell(row, 0).GetReference())
+
+	// left at the original root stays there as before
+	pressKey(ui, 'h')
+	assert.Equal(t, data, ui.currentDir)
+}
+
+func TestReRootRecomputesPercentages(t *testing.T) {
+	ui, _, projects := createReRootUI(t)
+
+	// projects has 400 of 1000 B of /data
+	assert.Contains(t, ui.table.GetCell(1, 0).Text, getUsageGraph(40))
+	assert.Contains(t, ui.footerLabel.GetText(true), "Total disk usage: 1000 B")
+
+	ui.table.Select(1, 0)
+	pressKey(ui, 'R')
+
+	// foo has 300 of 400 B of the new root
+	assert.Equal(t, projects, ui.topDir)
+	assert.Contains(t, ui.table.GetCell(1, 0).Text, "foo")
+	assert.Contains(t, ui.table.GetCell(1, 0).Text, getUsageGraph(75))
+	assert.Contains(t, ui.footerLabel.GetText(true), "Total disk usage: 400 B")
+}
+
+func TestReRootIgnoresFilesAndParent(t *testing.T) {
+	ui, data, _ := createReRootUI(t)
+
+	ui.table.Select(0, 0) // other is a file
+	pressKey(ui, 'R')
+	assert.Equal(t, data, ui.topDir)
+	assert.Empty(t, ui.rootStack)
+
+	ui.table.Select(1, 0)
+	pressKey(ui, 'l')
+	ui.table.Select(0, 0) // /..
+	pressKey(ui, 'R')
+	assert.Equal(t, data, ui.topDir)
+	assert.Empty(t, ui.rootStack)
+}
@@ -0,0 +1,78 @@
+package tui
+
+import (
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/rivo/tview"
+)
+
+// maxMaterializedRows is the number of cells of directory items kept in memory,
+// cells of the other rows are created again when they are shown
+const maxMaterializedRows = 1000
+
+// dirRows is the table content listing the current directory.
+// Cells of the items are created only when the table draws or reads their rows,
+// so showing a directory with millions of items does not create millions of cells
+type dirRows struct {
+	tview.TableContentReadOnly
+	parent *tview.TableCell // cell of the ".." row, nil if there is none
+	items  []fs.Item        // shown items in the order of their rows
+	create func(row int, item fs.Item) *tview.TableCell
+	cells  map[int]*tview.TableCell // cells created so far by row
+}
+
+func newDirRows(parent *tview.TableCell, items []fs.Item, create func(int, fs.Item) *tview.TableCell) *dirRows {
+	return &dirRows{
+		parent: parent,
+		items:  items,
+		create: create,
+		cells:  make(map[int]*tview.TableCell),
+	}
+}
+
+// GetCell returns cell of the row, it is created if it was not created yet
+func (r *dirRows) GetCell(row, column int) *tview.TableCell {
+	if column != 0 || row < 0 || row >= r.GetRowCount() {
+		return nil
+	}
+	if r.parent != nil {
+		if row == 0 {
+			return r.parent
+		}
+		row--
+	}
+
+	rowIndex := row + r.firstItemRow()
+	if cell, ok := r.cells[rowIndex]; ok {
+		return cell
+	}
+	// the table asks for the visible rows only, so dropping all cells is cheap
+	if len(r.cells) >= maxMaterializedRows {
+		r.cells = make(map[int]*tview.TableCell)
+	}
+	cell := r.create(rowIndex, r.items[row])
+	r.cells[rowIndex] = cell
+	return cell
+}
+
+// GetRowCount returns number of the items and of the ".." row
+func (r *dirRows) GetRowCount() int {
+	return len(r.items) + r.firstItemRow()
+}
+
+// GetColumnCount returns 1, the whole row is one cell
+func (r *dirRows) GetColumnCount() int {
+	return 1
+}
+
+// firstItemRow returns row of the first item
+func (r *dirRows) firstItemRow() int {
+	if r.parent != nil {
+		return 1
+	}
+	return 0
+}
+
+// materialized returns number of the created cells of the items
+func (r *dirRows) materialized() int {
+	return len(r.cells)
+}
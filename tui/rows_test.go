@@ -0,0 +1,113 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/dundee/gdu/v5/internal/testapp"
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/stretchr/testify/assert"
+)
+
+const hugeDirSize = 100000
+
+// showHugeDir shows a directory with hugeDirSize files named by their index,
+// bigger files have higher index
+func showHugeDir(t *testing.T) (*UI, tcell.SimulationScreen) {
+	t.Helper()
+	parentDir := &analyze.Dir{
+		File:  &analyze.File{Name: "parent"},
+		Files: make(fs.Files, 0, 1),
+	}
+	dir := &analyze.Dir{
+		File:  &analyze.File{Name: "huge", Parent: parentDir},
+		Files: make(fs.Files, 0, hugeDirSize),
+	}
+	parentDir.AddFile(dir)
+	for i := 0; i < hugeDirSize; i++ {
+		dir.AddFile(&analyze.File{
+			Name:   fmt.Sprintf("file%06d", i),
+			Size:   int64(i + 1),
+			Usage:  int64(i + 1),
+			Parent: dir,
+		})
+	}
+	dir.UpdateStats(make(fs.HardLinkedItems))
+
+	simScreen := testapp.CreateSimScreen()
+	t.Cleanup(simScreen.Fini)
+	simScreen.SetSize(120, 40)
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, false, false, false)
+	ui.topDir = parentDir
+	ui.topDirPath = parentDir.GetPath()
+	ui.currentDir = dir
+	ui.showDir()
+	ui.table.SetRect(0, 0, 120, 40)
+	ui.table.Draw(simScreen)
+	return ui, simScreen
+}
+
+func selectedItem(ui *UI) fs.Item {
+	row, column := ui.table.GetSelection()
+	return ui.table.GetCell(row, column).GetReference().(fs.Item)
+}
+
+func TestHugeDirMaterializesVisibleRows(t *testing.T) {
+	ui, screen := showHugeDir(t)
+	rows := ui.rows
+
+	assert.Equal(t, hugeDirSize+1, ui.table.GetRowCount())
+	assert.Contains(t, ui.table.GetCell(0, 0).Text, "/..")
+	assert.LessOrEqual(t, rows.materialized(), 50, "only the visible rows are created")
+
+	// jump through the whole directory
+	for row := 0; row < ui.table.GetRowCount(); row += 997 {
+		ui.table.Select(row, 0)
+		ui.table.Draw(screen)
+		assert.LessOrEqual(t, rows.materialized(), maxMaterializedRows)
+	}
+
+	ui.table.Select(ui.table.GetRowCount()-1, 0)
+	ui.table.Draw(screen)
+	assert.Equal(t, "file000000", selectedItem(ui).GetName(), "smallest file is the last one")
+	assert.LessOrEqual(t, rows.materialized(), maxMaterializedRows)
+}
+
+func TestHugeDirSelection(t *testing.T) {
+	ui, screen := showHugeDir(t)
+
+	// sorted by size desc, the biggest file is the first one after /..
+	ui.table.Select(1, 0)
+	assert.Equal(t, fmt.Sprintf("file%06d", hugeDirSize-1), selectedItem(ui).GetName())
+
+	ui.table.InputHandler()(tcell.NewEventKey(tcell.KeyDown, 0, 0), func(tview.Primitive) {})
+	ui.table.Draw(screen)
+	assert.Equal(t, fmt.Sprintf("file%06d", hugeDirSize-2), selectedItem(ui).GetName())
+
+	// cells created again after being dropped are the same
+	ui.table.Select(50000, 0)
+	ui.table.Draw(screen)
+	name := selectedItem(ui).GetName()
+	text := ui.table.GetCell(50000, 0).Text
+	for row := 0; row < ui.table.GetRowCount(); row += 500 {
+		ui.table.GetCell(row, 0)
+	}
+	assert.Equal(t, name, ui.table.GetCell(50000, 0).GetReference().(fs.Item).GetName())
+	assert.Equal(t, text, ui.table.GetCell(50000, 0).Text)
+
+	ui.fileItemMarked(10)
+	assert.Contains(t, ui.table.GetCell(10, 0).Text, "✓")
+	assert.Equal(t, 11, func() int { row, _ := ui.table.GetSelection(); return row }())
+
+	ui.setSorting("name")
+	assert.Contains(t, ui.table.GetCell(0, 0).Text, "/..")
+	assert.Equal(t, "file000000", ui.table.GetCell(1, 0).GetReference().(fs.Item).GetName())
+	assert.Equal(t, "file099999", ui.table.GetCell(hugeDirSize, 0).GetReference().(fs.Item).GetName())
+	assert.Equal(t, hugeDirSize+1, ui.table.GetRowCount())
+}
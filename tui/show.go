@@ -10,6 +10,8 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/dundee/gdu/v5/build"
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
 )
 
 const helpText = `     [::b]up/down, k/j    [white:black:-]Move cursor up/down
@@ -18,12 +20,18 @@ const helpText = `     [::b]up/down, k/j    [white:black:-]Move cursor up/down
          [::b]left, h     [white:black:-]Go to parent directory
 
                [::b]r     [white:black:-]Rescan current directory
+               [::b]R     [white:black:-]Use selected directory as root (left goes back to previous root)
+               [::b]u     [white:black:-]Switch to the directory as it was before the last rescan and back (with --keep-previous)
                [::b]E     [white:black:-]Export analysis data to file as JSON
                [::b]/     [white:black:-]Search items by name
                [::b]a     [white:black:-]Toggle between showing disk usage and apparent size
                [::b]B     [white:black:-]Toggle bar alignment to biggest file or directory
                [::b]c     [white:black:-]Show/hide file count
                [::b]m     [white:black:-]Show/hide latest mtime
+               [::b]t     [white:black:-]Show/hide birth time (incremental mode on Linux only)
+               [::b]f     [white:black:-]Show/hide size of files directly in directory (without subdirectories)
+               [::b]x     [white:black:-]Show/hide read error count (incremental mode only)
+               [::b]N     [white:black:-]Show/hide notes of directories (incremental mode only)
                [::b]b     [white:black:-]Spawn shell in current directory
                [::b]q     [white:black:-]Quit gdu
                [::b]Q     [white:black:-]Quit gdu and print current directory path
@@ -36,13 +44,20 @@ Item under cursor:
                [::b]v     [white:black:-]Show content of file
                [::b]o     [white:black:-]Open file or directory in external program
                [::b]i     [white:black:-]Show info about item
+               [::b]w     [white:black:-]Write note of directory (incremental mode only)
                [::b]S     [white:black:-]Show cache statistics (incremental mode only)
+               [::b]A     [white:black:-]Show file age histogram of selected directory
+               [::b]U     [white:black:-]Show usage by owner of selected directory
+               [::b]D     [white:black:-]Find duplicate files in selected directory
+               [::b]P     [white:black:-]Find empty directories in selected directory (d deletes them)
 
 Sort by (twice toggles asc/desc):
                [::b]n     [white:black:-]Sort by name (asc/desc)
                [::b]s     [white:black:-]Sort by size (asc/desc)
                [::b]C     [white:black:-]Sort by file count (asc/desc)
-               [::b]M     [white:black:-]Sort by mtime (asc/desc)`
+               [::b]M     [white:black:-]Sort by mtime (asc/desc)
+               [::b]T     [white:black:-]Sort by birth time (asc/desc)
+               [::b]F     [white:black:-]Sort by size of files directly in directory (asc/desc)`
 
 // nolint: funlen // Why: complex function
 func (ui *UI) showDir() {
@@ -64,25 +79,23 @@ func (ui *UI) showDir() {
 		log.Printf("changing cwd to %s", ui.currentDirPath)
 	}
 
-	ui.currentDirLabel.SetText("[::b] --- " +
+	ui.currentDirLabel.SetText(ui.previousBanner() + "[::b] --- " +
 		tview.Escape(
 			strings.TrimPrefix(ui.currentDirPath, build.RootPathPrefix),
 		) +
 		" ---").SetDynamicColors(true)
 
-	ui.table.Clear()
-
 	rowIndex := 0
-	if ui.currentDirPath != ui.topDirPath {
+	var parentCell *tview.TableCell
+	if ui.hasParentRow() {
 		prefix := "                         "
 		if len(ui.markedRows) > 0 {
 			prefix += "  "
 		}
 
-		cell := tview.NewTableCell(prefix + "[::b]/..")
-		cell.SetReference(ui.currentDir.GetParent())
-		cell.SetStyle(tcell.Style{}.Foreground(tcell.ColorDefault))
-		ui.table.SetCell(0, 0, cell)
+		parentCell = tview.NewTableCell(prefix + "[::b]/..")
+		parentCell.SetReference(ui.currentDir.GetParent())
+		parentCell.SetStyle(tcell.Style{}.Foreground(tcell.ColorDefault))
 		rowIndex++
 	}
 
@@ -114,7 +127,8 @@ func (ui *UI) showDir() {
 		i++
 	}
 
-	for i, item := range ui.currentDir.GetFiles() {
+	items := make([]fs.Item, 0, len(ui.currentDir.GetFiles()))
+	for _, item := range ui.currentDir.GetFiles() {
 		if ui.filterValue != "" && !strings.Contains(
 			strings.ToLower(item.GetName()),
 			strings.ToLower(ui.filterValue),
@@ -122,17 +136,21 @@ func (ui *UI) showDir() {
 			continue
 		}
 
-		_, ignored := ui.ignoredRows[rowIndex]
-
-		if !ignored {
+		if _, ignored := ui.ignoredRows[rowIndex]; !ignored {
 			totalUsage += item.GetUsage()
 			totalSize += item.GetSize()
 			itemCount += item.GetItemCount()
 		}
+		items = append(items, item)
+		rowIndex++
+	}
 
-		_, marked := ui.markedRows[rowIndex]
+	// cells are created only for the rows shown by the table
+	ui.rows = newDirRows(parentCell, items, func(row int, item fs.Item) *tview.TableCell {
+		_, ignored := ui.ignoredRows[row]
+		_, marked := ui.markedRows[row]
 		cell := tview.NewTableCell(ui.formatFileRow(item, maxUsage, maxSize, marked, ignored))
-		cell.SetReference(ui.currentDir.GetFiles()[i])
+		cell.SetReference(item)
 
 		switch {
 		case ignored:
@@ -143,10 +161,19 @@ func (ui *UI) showDir() {
 		default:
 			cell.SetStyle(tcell.Style{}.Foreground(tcell.ColorDefault))
 		}
-
-		ui.table.SetCell(rowIndex, 0, cell)
-		rowIndex++
+		return cell
+	})
+	ui.table.SetContent(ui.rows)
+
+	// files of the directory itself which were not read are not listed
+	if dir, ok := ui.currentDir.(interface{ GetEstimate() *analyze.Estimate }); ok && ui.filterValue == "" {
+		if est := dir.GetEstimate(); est != nil {
+			totalUsage += est.Usage
+			totalSize += est.Size
+			itemCount += est.Skipped
+		}
 	}
+	estimated := estimatedPrefix(ui.currentDir)
 
 	var footerNumberColor, footerTextColor string
 	if ui.UseColors {
@@ -171,17 +198,40 @@ func (ui *UI) showDir() {
 			strconv.Itoa(len(ui.markedRows)) + footerTextColor
 	}
 
+	scanStatus := ""
+	if ui.scanResult != nil {
+		switch ui.scanResult.Status {
+		case analyze.ScanCompletedWithErrors:
+			scanStatus = " Scan completed with " + footerNumberColor +
+				strconv.Itoa(ui.scanResult.ErrorCount) + footerTextColor + " errors"
+		case analyze.ScanCancelled:
+			scanStatus = " Scan cancelled"
+		case analyze.ScanFailed:
+			scanStatus = " Scan failed"
+		}
+		if ui.scanResult.RootIgnored {
+			scanStatus = " Scanned directory is ignored"
+		}
+	}
+
+	saved := ""
+	if dir, ok := ui.currentDir.(interface{ GetHardlinksSaved() int64 }); ok && dir.GetHardlinksSaved() > 0 {
+		saved = " Hardlinks saved: " + footerNumberColor +
+			ui.formatSize(dir.GetHardlinksSaved(), true, false) + footerTextColor
+	}
+
 	ui.footerLabel.SetText(
-		selected + footerTextColor +
+		selected + scanStatus + saved + footerTextColor +
 			" Total disk usage: " +
-			footerNumberColor +
+			footerNumberColor + estimated +
 			ui.formatSize(totalUsage, true, false) +
 			" Apparent size: " +
-			footerNumberColor +
+			footerNumberColor + estimated +
 			ui.formatSize(totalSize, true, false) +
 			" Items: " + footerNumberColor + strconv.Itoa(itemCount) +
 			footerTextColor +
 			" Sorting by: " + ui.sortBy + " " + ui.sortOrder)
+	ui.updateScanSummary()
 
 	ui.table.Select(0, 0)
 	ui.table.ScrollToBeginning()
@@ -194,7 +244,8 @@ func (ui *UI) showDir() {
 func (ui *UI) showDevices() {
 	var totalUsage int64
 
-	ui.table.Clear()
+	ui.rows = nil
+	ui.table.SetContent(nil)
 	ui.table.SetCell(0, 0, tview.NewTableCell("Device name").SetSelectable(false))
 	ui.table.SetCell(0, 1, tview.NewTableCell("Size").SetSelectable(false))
 	ui.table.SetCell(0, 2, tview.NewTableCell("Used").SetSelectable(false))
@@ -12,6 +12,8 @@ const (
 	sizeSortKey      = "size"
 	itemCountSortKey = "itemCount"
 	mtimeSortKey     = "mtime"
+	btimeSortKey     = "btime"
+	selfSizeSortKey  = "selfSize"
 
 	ascOrder  = "asc"
 	descOrder = "desc"
@@ -85,6 +87,24 @@ func (ui *UI) sortItems() {
 			sort.Sort(fs.ByMtime(ui.currentDir.GetFiles()))
 		}
 	}
+	if ui.sortBy == btimeSortKey {
+		if ui.sortOrder == descOrder {
+			sort.Sort(sort.Reverse(fs.ByBtime(ui.currentDir.GetFiles())))
+		} else {
+			sort.Sort(fs.ByBtime(ui.currentDir.GetFiles()))
+		}
+	}
+	if ui.sortBy == selfSizeSortKey {
+		var files sort.Interface = fs.BySelfUsage(ui.currentDir.GetFiles())
+		if ui.ShowApparentSize {
+			files = fs.BySelfSize(ui.currentDir.GetFiles())
+		}
+		if ui.sortOrder == descOrder {
+			sort.Sort(sort.Reverse(files))
+		} else {
+			sort.Sort(files)
+		}
+	}
 }
 
 func (ui *UI) sortDevices() {
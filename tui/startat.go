@@ -0,0 +1,91 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+)
+
+// SetStartAt makes the UI open at the item at path after the first scan instead of its top directory,
+// e.g. at a directory found in a non-interactive report. A file is selected in its directory.
+// Relative path is resolved now, the UI may change the working directory
+func (ui *UI) SetStartAt(path string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	ui.startAt = path
+}
+
+// openStartAt shows the item set by SetStartAt, the top directory stays shown
+// with an error if it is not found in the scanned tree. It is done only once
+func (ui *UI) openStartAt() {
+	path := ui.startAt
+	ui.startAt = ""
+
+	dir, selected, err := ui.findStartAt(path)
+	if err != nil {
+		ui.showErr("Can't open the start path, showing "+ui.topDirPath, err)
+		return
+	}
+
+	ui.currentDir = dir
+	ui.showDir()
+
+	// the file, or the first item of the directory
+	index := 0
+	if selected != nil {
+		index = slices.IndexFunc(
+			ui.currentDir.GetFiles(),
+			func(v fs.Item) bool {
+				return v == selected
+			},
+		)
+	}
+	if ui.hasParentRow() {
+		index++
+	}
+	ui.table.Select(max(index, 0), 0)
+}
+
+// findStartAt returns the directory at path in the shown tree, or the directory of the file at path
+// with the file. Directories on the way are linked to their parents in memory, so going up
+// from them does not need the scan of the parent
+func (ui *UI) findStartAt(path string) (fs.Item, fs.Item, error) {
+	top, err := filepath.Abs(ui.topDirPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	rel, err := filepath.Rel(top, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, nil, fmt.Errorf("%s is not located in %s", path, ui.topDirPath)
+	}
+
+	var dir fs.Item = ui.topDir
+	if rel == "." {
+		return dir, nil, nil
+	}
+	names := strings.Split(rel, string(filepath.Separator))
+	for i, name := range names {
+		index, ok := dir.GetFiles().FindByName(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s not found in %s", name, dir.GetPath())
+		}
+		child := dir.GetFiles()[index]
+		if !child.IsDir() {
+			if i < len(names)-1 {
+				return nil, nil, fmt.Errorf("%s is not a directory", child.GetPath())
+			}
+			return dir, child, nil
+		}
+		if _, isParentDirMarker := child.GetParent().(*analyze.ParentDir); isParentDirMarker {
+			child.SetParent(dir)
+		}
+		dir = child
+	}
+	return dir, nil, nil
+}
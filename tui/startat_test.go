@@ -0,0 +1,116 @@
+package tui
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dundee/gdu/v5/internal/testapp"
+	"github.com/dundee/gdu/v5/internal/testdir"
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+// analyzeWithStartAt scans test_dir incrementally with the UI opening at startAt
+func analyzeWithStartAt(t *testing.T, startAt string) *UI {
+	t.Helper()
+	simScreen := testapp.CreateSimScreen()
+	t.Cleanup(simScreen.Fini)
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, false, false, false)
+	ui.Analyzer = analyze.CreateIncrementalAnalyzer(analyze.IncrementalOptions{StoragePath: t.TempDir()})
+	ui.SetStartAt(startAt)
+	ui.done = make(chan struct{})
+	assert.Nil(t, ui.AnalyzePath("test_dir", nil))
+
+	<-ui.done // wait for analyzer
+	for _, f := range ui.app.(*testapp.MockedApp).GetUpdateDraws() {
+		f()
+	}
+	return ui
+}
+
+func TestStartAtDir(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	root, err := filepath.Abs("test_dir")
+	assert.Nil(t, err)
+
+	ui := analyzeWithStartAt(t, filepath.Join("test_dir", "nested", "subnested"))
+
+	assert.Equal(t, filepath.Join(root, "nested", "subnested"), ui.currentDirPath)
+	assert.Equal(t, root, ui.topDirPath)
+	assert.Contains(t, ui.table.GetCell(0, 0).Text, "/..")
+	assert.Equal(t, "file", selectedItem(ui).GetName())
+
+	// going up stays in the scanned tree, the parents are not scanned again
+	ui.handleLeft()
+	assert.Equal(t, filepath.Join(root, "nested"), ui.currentDirPath)
+	assert.Equal(t, root, ui.topDirPath)
+	ui.handleLeft()
+	assert.Equal(t, root, ui.currentDirPath)
+	assert.Same(t, ui.topDir, ui.currentDir)
+	assert.Empty(t, ui.startAt, "only the first scan opens the start path")
+}
+
+func TestStartAtFile(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	root, err := filepath.Abs("test_dir")
+	assert.Nil(t, err)
+
+	ui := analyzeWithStartAt(t, filepath.Join(root, "nested", "file2"))
+
+	assert.Equal(t, filepath.Join(root, "nested"), ui.currentDirPath)
+	assert.Equal(t, "file2", selectedItem(ui).GetName())
+	assert.False(t, ui.pages.HasPage("error"))
+}
+
+func TestStartAtNotFound(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+	root, err := filepath.Abs("test_dir")
+	assert.Nil(t, err)
+
+	for _, startAt := range []string{
+		filepath.Join("test_dir", "missing"),
+		filepath.Join("test_dir", "nested", "file2", "x"),
+		filepath.Dir(root),
+	} {
+		ui := analyzeWithStartAt(t, startAt)
+
+		assert.Equal(t, root, ui.currentDirPath, startAt)
+		assert.True(t, ui.pages.HasPage("error"), startAt)
+	}
+}
+
+func TestReplaceShownSubtree(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	ui := analyzeWithStartAt(t, filepath.Join("test_dir", "nested"))
+	usage := ui.topDir.GetUsage()
+
+	// the shown directory is scanned again and spliced into the tree
+	assert.Nil(t, os.WriteFile(filepath.Join("test_dir", "nested", "new"), make([]byte, 10000), 0o600))
+	analyzer := analyze.CreateAnalyzer()
+	nested := analyzer.AnalyzeDir(ui.currentDirPath, func(_, _ string) bool { return false }, true).(*analyze.Dir)
+	analyzer.GetDone().Wait()
+	assert.Nil(t, analyze.ReplaceSubtree(ui.topDir, nested))
+
+	ui.currentDir = nested
+	ui.showDir()
+	names := make([]string, 0)
+	for row := 1; row < ui.table.GetRowCount(); row++ {
+		names = append(names, ui.table.GetCell(row, 0).GetReference().(fs.Item).GetName())
+	}
+	assert.Contains(t, names, "new")
+
+	ui.handleLeft()
+	assert.Same(t, ui.topDir, ui.currentDir)
+	assert.Same(t, nested, ui.topDir.GetFiles()[0])
+	assert.Greater(t, ui.topDir.GetUsage(), usage)
+}
@@ -0,0 +1,84 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rivo/tview"
+	"github.com/rivo/uniseg"
+)
+
+// scanSummaryShare is the part of the terminal width the scan summary may take in the footer,
+// the rest is left to the totals of the shown directory
+const scanSummaryShare = 2
+
+// scanSummaryText returns the one-line summary of the last incremental scan shown on the right
+// of the footer: totals of the scanned directory (updated by deletions), duration of the scan,
+// its hit rate and number of rescanned directories. Parts which don't fit into width cells
+// are left out from the end, zero width keeps all of them. Empty string is returned
+// if there is no summary, e.g. in other than incremental mode
+func (ui *UI) scanSummaryText(width int) string {
+	if ui.scanResult == nil || ui.scanResult.Stats == nil || ui.topDir == nil {
+		return ""
+	}
+	stats := ui.scanResult.Stats
+
+	root := ui.topDir
+	if len(ui.rootStack) > 0 {
+		root = ui.rootStack[0].topDir
+	}
+	size := root.GetUsage()
+	if ui.ShowApparentSize {
+		size = root.GetSize()
+	}
+
+	parts := []string{
+		ui.formatPlainSize(size),
+		strconv.Itoa(root.GetItemCount()) + " items",
+		"took " + stats.TotalScanTime.Round(100*time.Millisecond).String(),
+		strconv.FormatFloat(stats.HitRate(), 'f', 1, 64) + "% hits",
+		strconv.FormatInt(stats.CacheMisses, 10) + " rescanned",
+	}
+
+	text := " Scan:"
+	for i, part := range parts {
+		separator := ", "
+		if i == 0 {
+			separator = " "
+		}
+		next := text + separator + part
+		if width > 0 && uniseg.StringWidth(next)+1 > width {
+			break
+		}
+		text = next
+	}
+	if text == " Scan:" {
+		return ""
+	}
+	return text + " "
+}
+
+// updateScanSummary refreshes the scan summary in the footer, it is hidden if there is none
+func (ui *UI) updateScanSummary() {
+	text := ui.scanSummaryText(ui.screenWidth() / scanSummaryShare)
+	color := blackOnWhite
+	if ui.UseColors {
+		color = fmt.Sprintf("[%s:%s:-]", ui.footerTextColor, ui.footerBackgroundColor)
+	}
+	ui.scanSummary.SetText(color + tview.Escape(text))
+	ui.footer.ResizeItem(ui.scanSummary, uniseg.StringWidth(text), 0)
+}
+
+// addScanSummary adds the scan summary to the footer after it was cleared
+func (ui *UI) addScanSummary() {
+	ui.footer.AddItem(ui.scanSummary, uniseg.StringWidth(ui.scanSummary.GetText(true)), 0, false)
+}
+
+// formatPlainSize returns size with the prefix selected by the options and without color tags
+func (ui *UI) formatPlainSize(size int64) string {
+	if ui.UseSIPrefix {
+		return formatWithDecPrefix(size, "")
+	}
+	return formatWithBinPrefix(float64(size), "")
+}
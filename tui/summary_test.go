@@ -0,0 +1,71 @@
+package tui
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dundee/gdu/v5/internal/testapp"
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/fs"
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanSummary(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "tree")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "keep"), 0o755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "remove"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "keep", "file"), make([]byte, 2048), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "remove", "file"), make([]byte, 1024), 0o600))
+
+	opts := analyze.IncrementalOptions{StoragePath: t.TempDir()}
+	cold := analyze.CreateIncrementalAnalyzer(opts)
+	cold.AnalyzeDir(root, func(_, _ string) bool { return false }, false)
+	cold.GetDone().Wait()
+
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+	assert.NoError(t, simScreen.Init())
+	simScreen.SetSize(200, 30)
+
+	app := testapp.CreateMockedApp(true)
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, false, false, false)
+	ui.SetAnalyzer(analyze.CreateIncrementalAnalyzer(opts))
+	ui.askBeforeDelete = false
+	ui.done = make(chan struct{})
+
+	assert.NoError(t, ui.AnalyzePath(root, nil))
+	<-ui.done
+	for _, f := range ui.app.(*testapp.MockedApp).GetUpdateDraws() {
+		f()
+	}
+
+	// the duration is fixed, sizes of directories themselves depend on the filesystem
+	ui.scanResult.Stats.TotalScanTime = 1520 * time.Millisecond
+	ui.showDir()
+	size := ui.formatPlainSize(ui.topDir.GetSize())
+	assert.Equal(t, " Scan: "+size+", 5 items, took 1.5s, 100.0% hits, 0 rescanned ", ui.scanSummary.GetText(true))
+
+	// deletion updates the totals, the statistics stay those of the scan
+	for row := 0; row < ui.table.GetRowCount(); row++ {
+		if item, ok := ui.table.GetCell(row, 0).GetReference().(fs.Item); ok && item.GetName() == "remove" {
+			ui.table.Select(row, 0)
+		}
+	}
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'd', 0))
+	<-ui.done
+	for _, f := range ui.app.(*testapp.MockedApp).GetUpdateDraws() {
+		f()
+	}
+	assert.NoDirExists(t, filepath.Join(root, "remove"))
+	size = ui.formatPlainSize(ui.topDir.GetSize())
+	assert.Equal(t, " Scan: "+size+", 3 items, took 1.5s, 100.0% hits, 0 rescanned ", ui.scanSummary.GetText(true))
+
+	// the summary is shortened on narrow terminals
+	simScreen.SetSize(60, 30)
+	ui.showDir()
+	assert.Equal(t, " Scan: "+size+", 3 items ", ui.scanSummary.GetText(true))
+}
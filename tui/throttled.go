@@ -0,0 +1,81 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dundee/gdu/v5/pkg/remove"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// SetThrottledRemover sets remover pacing deletions by the I/O throttle.
+// Its progress is shown in the deletion modal, which can be paused by 'p' and cancelled by Esc
+func (ui *UI) SetThrottledRemover(r *remove.ThrottledRemover) {
+	ui.throttledRemover = r
+	ui.remover = r.ItemFromDir
+}
+
+// watchDeletion updates text of the deletion modal by progress of the throttled remover.
+// Returned function stops the updates
+func (ui *UI) watchDeletion(modal *tview.Modal, text string) func() {
+	if ui.throttledRemover == nil {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	progress := ui.throttledRemover.GetProgressChan()
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case p := <-progress:
+				var state string
+				if ui.throttledRemover.IsPaused() {
+					state = "paused, p to resume"
+				} else {
+					state = "p to pause"
+				}
+				msg := fmt.Sprintf(
+					"%s\n\n%s items removed, %s freed\n(%s, Esc to cancel)",
+					text, ui.formatCount(p.ItemCount), ui.formatSize(p.TotalSize, false, false), state,
+				)
+				ui.app.QueueUpdateDraw(func() {
+					modal.SetText(msg)
+				})
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+	}
+}
+
+// handleDeletionControl pauses, resumes or cancels the running throttled deletion
+func (ui *UI) handleDeletionControl(key *tcell.EventKey) *tcell.EventKey {
+	if ui.throttledRemover == nil || !(ui.pages.HasPage(actingDelete) || ui.pages.HasPage(actingEmpty)) {
+		return key
+	}
+
+	switch {
+	case key.Key() == tcell.KeyEsc:
+		ui.throttledRemover.Cancel()
+		ui.throttledRemover.Resume()
+		return nil
+	case key.Rune() == 'p':
+		if ui.throttledRemover.IsPaused() {
+			ui.throttledRemover.Resume()
+		} else {
+			ui.throttledRemover.Pause()
+		}
+		return nil
+	}
+	return key
+}
+
+// isCancelled returns true if the deletion was cancelled by the user
+func isCancelled(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
@@ -0,0 +1,78 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dundee/gdu/v5/internal/testapp"
+	"github.com/dundee/gdu/v5/internal/testdir"
+	"github.com/dundee/gdu/v5/pkg/analyze"
+	"github.com/dundee/gdu/v5/pkg/remove"
+)
+
+func TestDeleteSelectedThrottled(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	ui := getAnalyzedPathMockedApp(t, false, true, false)
+	ui.done = make(chan struct{})
+	ui.SetThrottledRemover(remove.NewThrottledRemover(analyze.NewIOThrottle(1000, 0), nil))
+
+	ui.table.Select(0, 0)
+	ui.deleteSelected(false)
+	<-ui.done
+
+	for _, f := range ui.app.(*testapp.MockedApp).GetUpdateDraws() {
+		f()
+	}
+
+	assert.NoDirExists(t, "test_dir/nested")
+	assert.False(t, ui.pages.HasPage("deleting"))
+	assert.Equal(t, 1, ui.currentDir.GetItemCount())
+}
+
+func TestCancelThrottledDeletion(t *testing.T) {
+	fin := testdir.CreateTestDir()
+	defer fin()
+
+	ui := getAnalyzedPathMockedApp(t, false, true, false)
+	ui.done = make(chan struct{})
+	remover := remove.NewThrottledRemover(analyze.NewIOThrottle(1000, 0), nil)
+	ui.SetThrottledRemover(remover)
+
+	ui.table.Select(0, 0)
+	remover.Pause()
+	ui.deleteSelected(false)
+	assert.True(t, ui.pages.HasPage("deleting"))
+
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyEsc, 0, 0))
+	<-ui.done
+
+	for _, f := range ui.app.(*testapp.MockedApp).GetUpdateDraws() {
+		f()
+	}
+
+	assert.DirExists(t, "test_dir/nested")
+	assert.False(t, remover.IsPaused())
+	assert.False(t, ui.pages.HasPage("deleting"))
+	assert.False(t, ui.pages.HasPage("error"))
+}
+
+func TestPauseThrottledDeletion(t *testing.T) {
+	ui := getAnalyzedPathMockedApp(t, false, true, true)
+	remover := remove.NewThrottledRemover(analyze.NewIOThrottle(1000, 0), nil)
+
+	// no effect without throttled remover
+	ui.pages.AddPage("deleting", tview.NewModal(), true, true)
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'p', 0))
+	assert.False(t, remover.IsPaused())
+
+	ui.SetThrottledRemover(remover)
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'p', 0))
+	assert.True(t, remover.IsPaused())
+	ui.keyPressed(tcell.NewEventKey(tcell.KeyRune, 'p', 0))
+	assert.False(t, remover.IsPaused())
+}
@@ -32,6 +32,7 @@ type UI struct {
 	header                  *tview.TextView
 	footer                  *tview.Flex
 	footerLabel             *tview.TextView
+	scanSummary             *tview.TextView // summary of the last incremental scan on the right of the footer
 	currentDirLabel         *tview.TextView
 	pages                   *tview.Pages
 	progress                *tview.TextView
@@ -43,21 +44,38 @@ type UI struct {
 	devices                 []*device.Device
 	topDir                  fs.Item
 	topDirPath              string
+	rootStack               []rootCrumb
 	currentDirPath          string
 	askBeforeDelete         bool
 	showItemCount           bool
 	showMtime               bool
+	showErrorCount          bool
+	showBtime               bool
+	showSelfSize            bool
+	showAnnotations         bool
 	filtering               bool
 	filterValue             string
 	sortBy                  string
 	sortOrder               string
 	done                    chan struct{}
 	remover                 func(fs.Item, fs.Item) error
+	throttledRemover        *remove.ThrottledRemover
+	duplicateOptions        analyze.DuplicateOptions
+	cancelDuplicates        func() // cancels running search for duplicates
+	emptyDirsRecursive      bool
+	startAt                 string                  // path opened after the first scan, empty if disabled
+	emptyDirs               *analyze.EmptyDirReport // listed by the open modal of empty directories
 	emptier                 func(fs.Item, fs.Item) error
 	getter                  device.DevicesInfoGetter
 	exec                    func(argv0 string, argv []string, envv []string) error
 	changeCwdFn             func(string) error
 	linkedItems             fs.HardLinkedItems
+	scanResult              *analyze.ScanResult
+	scannedAt               time.Time     // when the last scan finished
+	keepPrevious            bool          // the directory replaced by a rescan is kept, see SetKeepPrevious
+	previous                *previousView // directory replaced by the last rescan, nil if none
+	inPrevious              bool          // the previous view is shown
+	rows                    *dirRows      // content of the table showing the current directory
 	selectedTextColor       tcell.Color
 	selectedBackgroundColor tcell.Color
 	footerTextColor         string
@@ -124,6 +142,7 @@ func CreateUI(
 		askBeforeDelete:         true,
 		showItemCount:           false,
 		remover:                 remove.ItemFromDir,
+		duplicateOptions:        analyze.DefaultDuplicateOptions,
 		emptier:                 remove.EmptyFileFromDir,
 		exec:                    Execute,
 		linkedItems:             make(fs.HardLinkedItems, 10),
@@ -181,8 +200,13 @@ func CreateUI(
 	ui.footerLabel.SetBackgroundColor(tcell.GetColor(ui.footerBackgroundColor))
 	ui.footerLabel.SetText(" No items to display. ")
 
+	ui.scanSummary = tview.NewTextView().SetDynamicColors(true)
+	ui.scanSummary.SetTextColor(tcell.GetColor(ui.footerTextColor))
+	ui.scanSummary.SetBackgroundColor(tcell.GetColor(ui.footerBackgroundColor))
+
 	ui.footer = tview.NewFlex()
 	ui.footer.AddItem(ui.footerLabel, 0, 1, false)
+	ui.addScanSummary()
 
 	ui.createGrid()
 
@@ -339,6 +363,7 @@ func (ui *UI) resetSorting() {
 }
 
 func (ui *UI) rescanDir() {
+	ui.rememberPrevious()
 	ui.Analyzer.ResetProgress()
 	ui.linkedItems = make(fs.HardLinkedItems)
 	err := ui.AnalyzePath(ui.currentDirPath, ui.currentDir.GetParent())
@@ -364,6 +389,12 @@ func (ui *UI) fileItemSelected(row, column int) {
 		return
 	}
 
+	// /.. of a re-rooted directory leads back to the previous root
+	if ui.atReRoot() && row == 0 {
+		ui.leaveRoot()
+		return
+	}
+
 	// Special case: ParentDir (the ".." entry) is a marker object, not a real directory
 	// We need to detect if we're navigating to a parent via a ParentDir marker
 	// vs. navigating to an already-loaded parent directory
@@ -416,7 +447,7 @@ func (ui *UI) fileItemSelected(row, column int) {
 						return v.GetName() == origDir.GetName()
 					},
 				)
-				if ui.currentDir.GetPath() != ui.topDir.GetPath() {
+				if ui.hasParentRow() {
 					index++
 				}
 				ui.table.Select(index, 0)
@@ -488,7 +519,8 @@ func (ui *UI) confirmDeletionSelected(shouldEmpty bool) {
 				action +
 				" \"" +
 				tview.Escape(selectedFile.GetName()) +
-				"\"?",
+				"\"?\n\n" +
+				ui.deletionImpact(selectedFile, shouldEmpty),
 		).
 		AddButtons([]string{"no", "yes", "don't ask me again"}).
 		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
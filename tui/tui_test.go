@@ -69,14 +69,35 @@ func TestFooter(t *testing.T) {
 	}
 }
 
+func TestFooterWithScanErrors(t *testing.T) {
+	app := testapp.CreateMockedApp(true)
+	simScreen := testapp.CreateSimScreen()
+	defer simScreen.Fini()
+
+	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, true, false, false, false)
+
+	dir := &analyze.Dir{
+		File: &analyze.File{
+			Name: "xxx",
+		},
+		BasePath:   ".",
+		ErrorCount: 12,
+	}
+
+	ui.currentDir = dir
+	ui.scanResult = &analyze.ScanResult{Status: analyze.ScanCompletedWithErrors, ErrorCount: 12}
+	ui.showDir()
+
+	assert.Contains(t, ui.footerLabel.GetText(true), "Scan completed with 12 errors")
+}
+
 func TestUpdateProgress(t *testing.T) {
 	simScreen := testapp.CreateSimScreen()
 	defer simScreen.Fini()
 
 	app := testapp.CreateMockedApp(true)
 	ui := CreateUI(app, simScreen, &bytes.Buffer{}, false, false, false, false, false)
-	done := ui.Analyzer.GetDone()
-	done.Broadcast()
+	ui.Analyzer = &testanalyze.MockedAnalyzer{}
 	ui.updateProgress()
 	assert.True(t, true)
 }
@@ -97,7 +118,7 @@ func TestHelp(t *testing.T) {
 
 	b, _, _ := simScreen.GetContents()
 
-	cells := b[507 : 507+9]
+	cells := b[657 : 657+9]
 
 	text := []byte("directory")
 	for i, r := range cells {
@@ -118,7 +139,7 @@ func TestHelpBw(t *testing.T) {
 
 	b, _, _ := simScreen.GetContents()
 
-	cells := b[507 : 507+9]
+	cells := b[657 : 657+9]
 
 	text := []byte("directory")
 	for i, r := range cells {
@@ -0,0 +1,79 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/rivo/uniseg"
+)
+
+const (
+	ellipsis = "…"
+
+	// cacheColumnsMinWidth is the terminal width below which the columns filled
+	// from the incremental cache (self size, errors, birth time) are hidden
+	cacheColumnsMinWidth = 100
+
+	// statsModalWidth is the width of the cache statistics modal on wide terminals
+	statsModalWidth = 80
+)
+
+// truncateWidth shortens text to fit into width terminal cells, ending it with ellipsis.
+// Wide characters (e.g. CJK) take two cells and are never split
+func truncateWidth(text string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if uniseg.StringWidth(text) <= width {
+		return text
+	}
+
+	var b strings.Builder
+	used := 0
+	graphemes := uniseg.NewGraphemes(text)
+	for graphemes.Next() {
+		w := graphemes.Width()
+		if used+w > width-1 {
+			break
+		}
+		b.WriteString(graphemes.Str())
+		used += w
+	}
+	return b.String() + ellipsis
+}
+
+// fitName shortens name and the suffix following it to fit into width cells.
+// The suffix is shortened first and dropped if even the name doesn't fit
+func fitName(name, suffix string, width int) (string, string) {
+	nameWidth := uniseg.StringWidth(name)
+	if nameWidth+uniseg.StringWidth(suffix) <= width {
+		return name, suffix
+	}
+	if nameWidth >= width {
+		return truncateWidth(name, width), ""
+	}
+	return name, truncateWidth(suffix, width-nameWidth)
+}
+
+// screenWidth returns width of the terminal, zero if it is not known
+func (ui *UI) screenWidth() int {
+	if ui.screen == nil {
+		return 0
+	}
+	width, _ := ui.screen.Size()
+	return width
+}
+
+// showCacheColumns returns false if the terminal is too narrow for the columns
+// filled from the incremental cache
+func (ui *UI) showCacheColumns() bool {
+	width := ui.screenWidth()
+	return width == 0 || width >= cacheColumnsMinWidth
+}
+
+// modalWidth returns width of a modal which is width cells wide on wide terminals
+func (ui *UI) modalWidth(width int) int {
+	if screen := ui.screenWidth(); screen > 0 && screen < width {
+		return screen
+	}
+	return width
+}